@@ -156,6 +156,7 @@ func makeConfigTemplate(destination string, mode os.FileMode, confParams ConfigF
 		"ExcludeFileOutput":              false,
 		"ExcludeKafka":                   false,
 		"ExcludeLogstash":                false,
+		"ExcludePulsar":                  false,
 		"ExcludeRedis":                   false,
 		"UseObserverProcessor":           false,
 		"UseDockerMetadataProcessor":     true,
@@ -18,11 +18,14 @@
 package file
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,6 +37,9 @@ const (
 	// greater will result in an error.
 	MaxBackupsLimit = 1024
 	DateFormat      = "20060102"
+
+	// compressedExtension is appended to the name of the rotated files once compressed.
+	compressedExtension = ".gz"
 )
 
 // rotater is the interface responsible for rotating and finding files.
@@ -62,10 +68,16 @@ type Rotator struct {
 	log             Logger // Optional Logger (may be nil).
 	rotateOnStartup bool
 	redirectStderr  bool
+	compress        bool
 	clock           clock
 
 	file  *os.File
 	mutex sync.Mutex
+
+	// compressing are the rotated files being compressed in the background.
+	compressing map[string]struct{}
+	compressMu  sync.Mutex
+	compressWg  sync.WaitGroup
 }
 
 // Logger allows the rotator to write debug information.
@@ -133,6 +145,14 @@ func RedirectStderr(redirect bool) RotatorOption {
 	}
 }
 
+// Compress causes the rotated files to be compressed with gzip, the compressed
+// files count as backups. The default is false.
+func Compress(b bool) RotatorOption {
+	return func(r *Rotator) {
+		r.compress = b
+	}
+}
+
 func WithClock(clock clock) RotatorOption {
 	return func(r *Rotator) {
 		r.clock = clock
@@ -148,6 +168,7 @@ func NewFileRotator(filename string, options ...RotatorOption) (*Rotator, error)
 		interval:        0,
 		rotateOnStartup: true,
 		clock:           &realClock{},
+		compressing:     make(map[string]struct{}),
 	}
 
 	for _, opt := range options {
@@ -237,7 +258,7 @@ func (r *Rotator) openNew() error {
 		if reason == rotateReasonNoRotate {
 			return r.appendToFile()
 		}
-		if err = r.rot.Rotate(reason, t); err != nil {
+		if err = r.rotateBackups(reason, t); err != nil {
 			return errors.Wrap(err, "failed to rotate backups")
 		}
 		if err = r.purge(); err != nil {
@@ -290,15 +311,95 @@ func (r *Rotator) rotateWithTime(reason rotateReason, rotationTime time.Time) er
 		return errors.Wrap(err, "error file closing current file")
 	}
 
-	if err := r.rot.Rotate(reason, rotationTime); err != nil {
+	if err := r.rotateBackups(reason, rotationTime); err != nil {
 		return errors.Wrap(err, "failed to rotate backups")
 	}
 
 	return r.purge()
 }
 
+// rotateBackups moves to the next active file, the previous one is compressed
+// in the background when enabled so the writes do not wait for it.
+func (r *Rotator) rotateBackups(reason rotateReason, rotationTime time.Time) error {
+	rotated := r.rot.ActiveFile()
+	if err := r.rot.Rotate(reason, rotationTime); err != nil {
+		return err
+	}
+	if !r.compress || rotated == r.rot.ActiveFile() {
+		return nil
+	}
+
+	r.compressMu.Lock()
+	r.compressing[rotated] = struct{}{}
+	r.compressMu.Unlock()
+
+	r.compressWg.Add(1)
+	go func() {
+		defer r.compressWg.Done()
+
+		err := compressFile(rotated, r.permissions)
+
+		r.compressMu.Lock()
+		delete(r.compressing, rotated)
+		r.compressMu.Unlock()
+		if err != nil && r.log != nil {
+			r.log.Debugw("Failed to compress rotated file", "filename", rotated, "error", err)
+		}
+	}()
+	return nil
+}
+
+// isCompressing returns true when the rotated file, or its compressed copy,
+// is being compressed.
+func (r *Rotator) isCompressing(name string) bool {
+	r.compressMu.Lock()
+	defer r.compressMu.Unlock()
+	_, ok := r.compressing[strings.TrimSuffix(name, compressedExtension)]
+	return ok
+}
+
+// compressFile replaces the file with its gzip compressed copy.
+func compressFile(name string, perm os.FileMode) error {
+	src, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %v for compression", name)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+compressedExtension, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create compressed file for %v", name)
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cErr := zw.Close(); err == nil {
+		err = cErr
+	}
+	if cErr := dst.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(name + compressedExtension)
+		return errors.Wrapf(err, "failed to compress %v", name)
+	}
+
+	src.Close()
+	return errors.Wrapf(os.Remove(name), "failed to remove %v after compression", name)
+}
+
 func (r *Rotator) purge() error {
-	rotatedFiles := r.rot.RotatedFiles()
+	// a file being compressed is listed with its partial compressed copy, it
+	// counts once and it is not purged until it is compressed.
+	var rotatedFiles []string
+	for _, name := range r.rot.RotatedFiles() {
+		if strings.HasSuffix(name, compressedExtension) && r.isCompressing(name) {
+			continue
+		}
+		rotatedFiles = append(rotatedFiles, name)
+	}
 	count := uint(len(rotatedFiles))
 	if count <= r.maxBackups {
 		return nil
@@ -307,6 +408,9 @@ func (r *Rotator) purge() error {
 	purgeUntil := count - r.maxBackups
 	filesToPurge := rotatedFiles[:purgeUntil]
 	for _, name := range filesToPurge {
+		if r.isCompressing(name) {
+			continue
+		}
 		_, err := os.Stat(name)
 		switch {
 		case err == nil:
@@ -352,11 +456,14 @@ func (r *Rotator) Rotate() error {
 	return r.rotate(rotateReasonManualTrigger)
 }
 
-// Close closes the currently open file.
+// Close closes the currently open file, it waits for the rotated files being
+// compressed.
 func (r *Rotator) Close() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	return r.closeFile()
+	err := r.closeFile()
+	r.compressWg.Wait()
+	return err
 }
 
 func (r *Rotator) dir() string {
@@ -443,7 +550,8 @@ func (d *dateRotator) Rotate(reason rotateReason, rotateTime time.Time) error {
 	d.logOrderCache = make(map[string]logOrder, 0)
 
 	newFileNamePrefix := d.filenamePrefix + rotateTime.Format(d.format)
-	files, err := filepath.Glob(newFileNamePrefix + "*" + d.extension)
+	// compressed files are matched too so their index is not reused.
+	files, err := filepath.Glob(newFileNamePrefix + "*" + d.extension + "*")
 	if err != nil {
 		return fmt.Errorf("failed to get possible files: %+v", err)
 	}
@@ -512,6 +620,9 @@ func (d *dateRotator) OrderLog(filename string) logOrder {
 	var o logOrder
 	var err error
 
+	key := filename
+	filename = strings.TrimSuffix(filename, compressedExtension)
+
 	o.datetime, err = time.Parse(d.format, filename[d.prefixLen:d.filenameLen])
 	if err != nil {
 		return o
@@ -524,7 +635,7 @@ func (d *dateRotator) OrderLog(filename string) logOrder {
 		}
 	}
 
-	d.logOrderCache[filename] = o
+	d.logOrderCache[key] = o
 
 	return o
 }
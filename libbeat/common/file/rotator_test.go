@@ -18,9 +18,12 @@
 package file_test
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -250,6 +253,65 @@ func TestRotate(t *testing.T) {
 	AssertDirContents(t, dir, secondFile, thirdFile)
 }
 
+func TestRotateCompress(t *testing.T) {
+	dir := t.TempDir()
+
+	logname := "beatname"
+	filename := filepath.Join(dir, logname)
+
+	c := &testClock{time.Date(2021, 11, 11, 0, 0, 0, 0, time.Local)}
+	r, err := file.NewFileRotator(filename, file.MaxBackups(1), file.Compress(true), file.WithClock(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	WriteMsg(t, r)
+
+	firstFile := fmt.Sprintf("%s-%s.ndjson", logname, c.Now().Format(file.DateFormat))
+	AssertDirContents(t, dir, firstFile)
+
+	c.time = time.Date(2021, 11, 13, 0, 0, 0, 0, time.Local)
+	secondFile := fmt.Sprintf("%s-%s.ndjson", logname, c.Now().Format(file.DateFormat))
+
+	Rotate(t, r)
+	WriteMsg(t, r)
+
+	AssertEventuallyDirContents(t, dir, firstFile+".gz", secondFile)
+
+	gz, err := os.Open(filepath.Join(dir, firstFile+".gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, logMessage, string(content))
+
+	c.time = time.Date(2021, 11, 15, 0, 0, 0, 0, time.Local)
+	thirdFile := fmt.Sprintf("%s-%s.ndjson", logname, c.Now().Format(file.DateFormat))
+
+	Rotate(t, r)
+	WriteMsg(t, r)
+
+	// the compressed files count as backups, the file being compressed counts once.
+	AssertEventuallyDirContents(t, dir, secondFile+".gz", thirdFile)
+
+	// a second rotation on the same day does not reuse the name of the compressed file.
+	fourthFile := fmt.Sprintf("%s-%s-1.ndjson", logname, c.Now().Format(file.DateFormat))
+
+	Rotate(t, r)
+	WriteMsg(t, r)
+
+	AssertEventuallyDirContents(t, dir, thirdFile+".gz", fourthFile)
+}
+
 func CreateFile(t *testing.T, filename string) {
 	t.Helper()
 	f, err := os.Create(filename)
@@ -278,6 +340,32 @@ func AssertDirContents(t *testing.T, dir string, files ...string) {
 	assert.ElementsMatch(t, files, names)
 }
 
+// AssertEventuallyDirContents waits for the rotated files compressed in the
+// background.
+func AssertEventuallyDirContents(t *testing.T, dir string, files ...string) {
+	t.Helper()
+
+	var names []string
+	ok := assert.Eventually(t, func() bool {
+		f, err := os.Open(dir)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		names, err = f.Readdirnames(-1)
+		return err == nil && assert.ObjectsAreEqual(sortedCopy(files), sortedCopy(names))
+	}, 10*time.Second, 10*time.Millisecond)
+	if !ok {
+		assert.ElementsMatch(t, files, names)
+	}
+}
+
+func sortedCopy(s []string) []string {
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return c
+}
+
 func WriteMsg(t *testing.T, r *file.Rotator) {
 	t.Helper()
 
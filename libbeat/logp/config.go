@@ -53,6 +53,7 @@ type FileConfig struct {
 	Interval        time.Duration `config:"interval"`
 	RotateOnStartup bool          `config:"rotateonstartup"`
 	RedirectStderr  bool          `config:"redirect_stderr" yaml:"redirect_stderr"`
+	Compress        bool          `config:"compress" yaml:"compress"`
 }
 
 // MetricsConfig contains configuration used by the monitor to output metrics into the logstream.
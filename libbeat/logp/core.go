@@ -51,6 +51,7 @@ func init() {
 		rootLogger:   zap.NewNop(),
 		globalLogger: zap.NewNop(),
 		logger:       newLogger(zap.NewNop(), ""),
+		level:        zap.NewAtomicLevel(),
 	})
 }
 
@@ -60,6 +61,7 @@ type coreLogger struct {
 	globalLogger *zap.Logger            // Logger used by legacy global functions (e.g. logp.Info).
 	logger       *Logger                // Logger that is the basis for all logp.Loggers.
 	observedLogs *observer.ObservedLogs // Contains events generated while in observation mode (a testing mode).
+	level        zap.AtomicLevel        // Level of the output, can be changed after the configuration.
 }
 
 // Configure configures the logp package.
@@ -77,11 +79,13 @@ func ConfigureWithOutputs(cfg Config, outputs ...zapcore.Core) error {
 		err          error
 	)
 
+	level := zap.NewAtomicLevelAt(cfg.Level.ZapLevel())
+
 	// Build a single output (stderr has priority if more than one are enabled).
 	if cfg.toObserver {
-		sink, observedLogs = observer.New(cfg.Level.ZapLevel())
+		sink, observedLogs = observer.New(level)
 	} else {
-		sink, err = createLogOutput(cfg)
+		sink, err = createLogOutput(cfg, level)
 	}
 	if err != nil {
 		return errors.Wrap(err, "failed to build log output")
@@ -122,31 +126,39 @@ func ConfigureWithOutputs(cfg Config, outputs ...zapcore.Core) error {
 		globalLogger: root.WithOptions(zap.AddCallerSkip(1)),
 		logger:       newLogger(root, ""),
 		observedLogs: observedLogs,
+		level:        level,
 	})
 	return nil
 }
 
-func createLogOutput(cfg Config) (zapcore.Core, error) {
+// SetLevel changes the level of the configured output without reconfiguring
+// it, the loggers already created are affected too. The outputs given to
+// ConfigureWithOutputs keep their own level.
+func SetLevel(lvl Level) {
+	loadLogger().level.SetLevel(lvl.ZapLevel())
+}
+
+func createLogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
 	switch {
 	case cfg.toIODiscard:
-		return makeDiscardOutput(cfg)
+		return makeDiscardOutput(cfg, enab)
 	case cfg.ToStderr:
-		return makeStderrOutput(cfg)
+		return makeStderrOutput(cfg, enab)
 	case cfg.ToSyslog:
-		return makeSyslogOutput(cfg)
+		return makeSyslogOutput(cfg, enab)
 	case cfg.ToEventLog:
-		return makeEventLogOutput(cfg)
+		return makeEventLogOutput(cfg, enab)
 	case cfg.ToFiles:
-		return makeFileOutput(cfg)
+		return makeFileOutput(cfg, enab)
 	}
 
 	switch cfg.environment {
 	case SystemdEnvironment, ContainerEnvironment:
-		return makeStderrOutput(cfg)
+		return makeStderrOutput(cfg, enab)
 	case MacOSServiceEnvironment, WindowsServiceEnvironment:
 		fallthrough
 	default:
-		return makeFileOutput(cfg)
+		return makeFileOutput(cfg, enab)
 	}
 }
 
@@ -205,33 +217,33 @@ func makeOptions(cfg Config) []zap.Option {
 	return options
 }
 
-func makeStderrOutput(cfg Config) (zapcore.Core, error) {
+func makeStderrOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
 	stderr := zapcore.Lock(os.Stderr)
-	return newCore(cfg, buildEncoder(cfg), stderr, cfg.Level.ZapLevel()), nil
+	return newCore(cfg, buildEncoder(cfg), stderr, enab), nil
 }
 
-func makeDiscardOutput(cfg Config) (zapcore.Core, error) {
+func makeDiscardOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
 	discard := zapcore.AddSync(ioutil.Discard)
-	return newCore(cfg, buildEncoder(cfg), discard, cfg.Level.ZapLevel()), nil
+	return newCore(cfg, buildEncoder(cfg), discard, enab), nil
 }
 
-func makeSyslogOutput(cfg Config) (zapcore.Core, error) {
-	core, err := newSyslog(buildEncoder(cfg), cfg.Level.ZapLevel())
+func makeSyslogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+	core, err := newSyslog(buildEncoder(cfg), enab)
 	if err != nil {
 		return nil, err
 	}
 	return wrappedCore(cfg, core), nil
 }
 
-func makeEventLogOutput(cfg Config) (zapcore.Core, error) {
-	core, err := newEventLog(cfg.Beat, buildEncoder(cfg), cfg.Level.ZapLevel())
+func makeEventLogOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+	core, err := newEventLog(cfg.Beat, buildEncoder(cfg), enab)
 	if err != nil {
 		return nil, err
 	}
 	return wrappedCore(cfg, core), nil
 }
 
-func makeFileOutput(cfg Config) (zapcore.Core, error) {
+func makeFileOutput(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, error) {
 	filename := paths.Resolve(paths.Logs, filepath.Join(cfg.Files.Path, cfg.LogFilename()))
 
 	rotator, err := file.NewFileRotator(filename,
@@ -241,12 +253,13 @@ func makeFileOutput(cfg Config) (zapcore.Core, error) {
 		file.Interval(cfg.Files.Interval),
 		file.RotateOnStartup(cfg.Files.RotateOnStartup),
 		file.RedirectStderr(cfg.Files.RedirectStderr),
+		file.Compress(cfg.Files.Compress),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create file rotator")
 	}
 
-	return newCore(cfg, buildEncoder(cfg), rotator, cfg.Level.ZapLevel()), nil
+	return newCore(cfg, buildEncoder(cfg), rotator, enab), nil
 }
 
 func newCore(cfg Config, enc zapcore.Encoder, ws zapcore.WriteSyncer, enab zapcore.LevelEnabler) zapcore.Core {
@@ -93,6 +93,30 @@ func TestLoggerLevel(t *testing.T) {
 	}
 }
 
+func TestSetLevel(t *testing.T) {
+	if err := DevelopmentSetup(ToObserverOutput()); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := NewLogger("tester")
+
+	SetLevel(InfoLevel)
+	logger.Debug("debug")
+	logger.Info("info")
+	logs := ObserverLogs().TakeAll()
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "info", logs[0].Message)
+	}
+
+	// loggers created before the change follow the new level.
+	SetLevel(DebugLevel)
+	logger.Debug("debug")
+	logs = ObserverLogs().TakeAll()
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "debug", logs[0].Message)
+	}
+}
+
 func TestL(t *testing.T) {
 	if err := DevelopmentSetup(ToObserverOutput()); err != nil {
 		t.Fatal(err)
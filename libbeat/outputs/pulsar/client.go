@@ -0,0 +1,341 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/common/transport"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/outil"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/testing"
+)
+
+type client struct {
+	log      *logp.Logger
+	observer outputs.Observer
+	topic    outil.Selector
+	key      *fmtstr.EventFormatString
+	index    string
+	codec    codec.Codec
+	config   *pulsarConfig
+	mux      sync.Mutex
+
+	connect func(pulsar.ClientOptions) (pulsar.Client, error)
+	client  pulsar.Client
+	// producers are created on the first event published to their topic.
+	producers map[string]pulsar.Producer
+}
+
+type message struct {
+	msg      pulsar.ProducerMessage
+	topic    string
+	producer pulsar.Producer
+	data     publisher.Event
+}
+
+type msgRef struct {
+	client  *client
+	count   int32
+	total   int
+	batch   publisher.Batch
+	mux     sync.Mutex
+	failed  []publisher.Event
+	dropped int
+
+	err error
+}
+
+var (
+	errNoTopicsSelected = errors.New("no topic could be selected")
+)
+
+func newPulsarClient(
+	observer outputs.Observer,
+	index string,
+	topic outil.Selector,
+	writer codec.Codec,
+	config *pulsarConfig,
+) (*client, error) {
+	c := &client{
+		log:      logp.NewLogger(logSelector),
+		observer: observer,
+		topic:    topic,
+		key:      config.Key,
+		index:    strings.ToLower(index),
+		codec:    writer,
+		config:   config,
+		connect:  pulsar.NewClient,
+	}
+	return c, nil
+}
+
+func (c *client) Connect() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.log.Debugf("connect: %v", c.config.Hosts)
+
+	opts := newClientOptions(c.config)
+	opts.Logger = pulsarLogger{log: c.log}
+	client, err := c.connect(opts)
+	if err != nil {
+		c.log.Errorf("Pulsar connect fails with: %+v", err)
+		return err
+	}
+
+	c.client = client
+	c.producers = map[string]pulsar.Producer{}
+	return nil
+}
+
+func (c *client) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.log.Debug("closed pulsar client")
+
+	// client was not created before the close() was called.
+	if c.client == nil {
+		return nil
+	}
+
+	// closing the producers waits for the pending messages.
+	for _, producer := range c.producers {
+		producer.Close()
+	}
+	c.client.Close()
+	c.client = nil
+	c.producers = nil
+	return nil
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	msgs := make([]*message, 0, len(events))
+	dropped := 0
+	for i := range events {
+		msg, err := c.getEventMessage(&events[i])
+		if err != nil {
+			c.log.Errorf("Dropping event: %+v", err)
+			dropped++
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	c.observer.Dropped(dropped)
+
+	// the producers of all the topics are created before sending, the events that could be encoded
+	// are retried when a topic is not available.
+	for _, msg := range msgs {
+		producer, err := c.producer(msg.topic)
+		if err != nil {
+			retry := make([]publisher.Event, len(msgs))
+			for i, m := range msgs {
+				retry[i] = m.data
+			}
+			batch.RetryEvents(retry)
+			c.observer.Failed(len(msgs))
+			return fmt.Errorf("creating pulsar producer for topic '%v' failed: %v", msg.topic, err)
+		}
+		msg.producer = producer
+	}
+
+	if len(msgs) == 0 {
+		batch.ACK()
+		return nil
+	}
+
+	ref := &msgRef{
+		client: c,
+		count:  int32(len(msgs)),
+		total:  len(msgs),
+		batch:  batch,
+	}
+	for _, msg := range msgs {
+		msg := msg
+		msg.producer.SendAsync(ctx, &msg.msg, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+			ref.done(msg, err)
+		})
+	}
+
+	return nil
+}
+
+func (c *client) String() string {
+	return "pulsar(" + c.config.serviceURL() + ")"
+}
+
+// producer returns the producer of the topic, it is created on the first call.
+func (c *client) producer(topic string) (pulsar.Producer, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.client == nil {
+		return nil, errors.New("pulsar client is not connected")
+	}
+	if producer, ok := c.producers[topic]; ok {
+		return producer, nil
+	}
+
+	producer, err := c.client.CreateProducer(newProducerOptions(c.config, topic))
+	if err != nil {
+		return nil, err
+	}
+	c.log.Debugf("created producer for topic %v", topic)
+	c.producers[topic] = producer
+	return producer, nil
+}
+
+func (c *client) getEventMessage(data *publisher.Event) (*message, error) {
+	event := &data.Content
+	msg := &message{data: *data}
+
+	value, err := data.Cache.GetValue("topic")
+	if err == nil {
+		if c.log.IsDebug() {
+			c.log.Debugf("got event.Meta[\"topic\"] = %v", value)
+		}
+		if topic, ok := value.(string); ok {
+			msg.topic = topic
+		}
+	}
+
+	if msg.topic == "" {
+		topic, err := c.topic.Select(event)
+		if err != nil {
+			return nil, fmt.Errorf("setting pulsar topic failed with %v", err)
+		}
+		if topic == "" {
+			return nil, errNoTopicsSelected
+		}
+		msg.topic = topic
+		if _, err := data.Cache.Put("topic", topic); err != nil {
+			return nil, fmt.Errorf("setting pulsar topic in publisher event failed: %v", err)
+		}
+	}
+
+	serializedEvent, err := c.codec.Encode(c.index, event)
+	if err != nil {
+		if c.log.IsDebug() {
+			c.log.Debugf("failed event: %v", event)
+		}
+		return nil, err
+	}
+
+	buf := make([]byte, len(serializedEvent))
+	copy(buf, serializedEvent)
+	msg.msg = pulsar.ProducerMessage{
+		Payload:   buf,
+		EventTime: event.Timestamp,
+	}
+
+	if c.key != nil {
+		if key, err := c.key.Run(event); err == nil {
+			msg.msg.Key = key
+		}
+	}
+
+	return msg, nil
+}
+
+func (r *msgRef) done(msg *message, err error) {
+	if err != nil {
+		r.fail(msg, err)
+	}
+	r.dec()
+}
+
+func (r *msgRef) fail(msg *message, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var perr *pulsar.Error
+	if errors.As(err, &perr) && perr.Result() == pulsar.MessageTooBig {
+		r.client.log.Errorf("Pulsar (topic=%v): dropping too large message of size %v.",
+			msg.topic,
+			len(msg.msg.Key)+len(msg.msg.Payload))
+		r.client.observer.Dropped(1)
+		r.dropped++
+		return
+	}
+
+	r.failed = append(r.failed, msg.data)
+	if r.err == nil {
+		// Don't overwrite an existing error. This way at the end of the batch
+		// we report the first error that we saw, rather than the last one.
+		r.err = err
+	}
+}
+
+func (r *msgRef) dec() {
+	i := atomic.AddInt32(&r.count, -1)
+	if i > 0 {
+		return
+	}
+
+	r.client.log.Debug("finished pulsar batch")
+	stats := r.client.observer
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	success := r.total - len(r.failed) - r.dropped
+	if r.err != nil {
+		r.batch.RetryEvents(r.failed)
+		stats.Failed(len(r.failed))
+		if success > 0 {
+			stats.Acked(success)
+		}
+
+		r.client.log.Debugf("Pulsar publish failed with: %+v", r.err)
+	} else {
+		r.batch.ACK()
+		stats.Acked(success)
+	}
+}
+
+func (c *client) Test(d testing.Driver) {
+	if c.config.TLS.IsEnabled() {
+		d.Warn("TLS", "Pulsar output doesn't support TLS testing")
+	}
+
+	for _, host := range c.config.Hosts {
+		d.Run("Pulsar: "+host, func(d testing.Driver) {
+			netDialer := transport.TestNetDialer(d, c.config.ConnectionTimeout)
+			_, err := netDialer.Dial("tcp", host)
+			d.Error("dial up", err)
+		})
+	}
+}
+
+// Check it is an outputs.NetworkClient.
+var _ outputs.NetworkClient = &client{}
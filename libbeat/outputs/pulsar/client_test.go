@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+)
+
+type fakeClient struct {
+	pulsar.Client
+
+	mux       sync.Mutex
+	producers map[string]*fakeProducer
+	createErr error
+	sendErr   func(msg *pulsar.ProducerMessage) error
+	closed    bool
+}
+
+func (c *fakeClient) CreateProducer(opts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.createErr != nil {
+		return nil, c.createErr
+	}
+	p := &fakeProducer{client: c, topic: opts.Topic}
+	c.producers[opts.Topic] = p
+	return p, nil
+}
+
+func (c *fakeClient) Close() {
+	c.closed = true
+}
+
+type fakeProducer struct {
+	pulsar.Producer
+
+	client *fakeClient
+	topic  string
+	mux    sync.Mutex
+	sent   []*pulsar.ProducerMessage
+	closed bool
+}
+
+func (p *fakeProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	p.mux.Lock()
+	p.sent = append(p.sent, msg)
+	p.mux.Unlock()
+
+	var err error
+	if p.client.sendErr != nil {
+		err = p.client.sendErr(msg)
+	}
+	go callback(nil, msg, err)
+}
+
+func (p *fakeProducer) Close() {
+	p.closed = true
+}
+
+func newTestClient(t *testing.T, settings common.MapStr) (*client, *fakeClient) {
+	cfg := common.MustNewConfigFrom(settings)
+	config, err := readConfig(cfg)
+	require.NoError(t, err)
+	topic, err := buildTopicSelector(cfg)
+	require.NoError(t, err)
+	enc, err := codec.CreateEncoder(beat.Info{}, config.Codec)
+	require.NoError(t, err)
+
+	c, err := newPulsarClient(outputs.NewNilObserver(), "testbeat", topic, enc, config)
+	require.NoError(t, err)
+
+	fake := &fakeClient{producers: map[string]*fakeProducer{}}
+	c.connect = func(opts pulsar.ClientOptions) (pulsar.Client, error) {
+		assert.Equal(t, "pulsar://localhost:6650", opts.URL)
+		return fake, nil
+	}
+	require.NoError(t, c.Connect())
+	return c, fake
+}
+
+func publish(t *testing.T, c *client, events ...beat.Event) *outest.Batch {
+	batch := outest.NewBatch(events...)
+	done := make(chan struct{})
+	batch.OnSignal = func(_ outest.BatchSignal) { close(done) }
+
+	require.NoError(t, c.Publish(context.Background(), batch))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not acknowledged")
+	}
+	return batch
+}
+
+func TestPublish(t *testing.T) {
+	events := []beat.Event{
+		{Timestamp: time.Now(), Fields: common.MapStr{"message": "first", "service": "web"}},
+		{Timestamp: time.Now(), Fields: common.MapStr{"message": "second", "service": "db"}},
+		{Timestamp: time.Now(), Fields: common.MapStr{"message": "third", "service": "web"}},
+	}
+
+	t.Run("events are routed to their topic", func(t *testing.T) {
+		c, fake := newTestClient(t, common.MapStr{
+			"hosts": []string{"localhost:6650"},
+			"topic": "logs-%{[service]}",
+			"key":   "%{[message]}",
+		})
+
+		batch := publish(t, c, events...)
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+		require.Len(t, fake.producers, 2)
+		require.Len(t, fake.producers["logs-web"].sent, 2)
+		require.Len(t, fake.producers["logs-db"].sent, 1)
+		assert.Equal(t, "first", fake.producers["logs-web"].sent[0].Key)
+		assert.Contains(t, string(fake.producers["logs-db"].sent[0].Payload), `"message":"second"`)
+
+		require.NoError(t, c.Close())
+		assert.True(t, fake.producers["logs-web"].closed)
+		assert.True(t, fake.closed)
+	})
+
+	t.Run("failed messages are retried", func(t *testing.T) {
+		c, fake := newTestClient(t, common.MapStr{
+			"hosts": []string{"localhost:6650"},
+			"topic": "beats",
+		})
+		fake.sendErr = func(msg *pulsar.ProducerMessage) error {
+			if strings.Contains(string(msg.Payload), "second") {
+				return errors.New("send timeout")
+			}
+			return nil
+		}
+
+		batch := publish(t, c, events...)
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+		require.Len(t, batch.Signals[0].Events, 1)
+		assert.Equal(t, "second", batch.Signals[0].Events[0].Content.Fields["message"])
+	})
+
+	t.Run("batch is retried when the producer cannot be created", func(t *testing.T) {
+		c, fake := newTestClient(t, common.MapStr{
+			"hosts": []string{"localhost:6650"},
+			"topic": "beats",
+		})
+		fake.createErr = errors.New("topic not found")
+
+		batch := outest.NewBatch(events...)
+		require.Error(t, c.Publish(context.Background(), batch))
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+		assert.Len(t, batch.Signals[0].Events, len(events))
+	})
+
+	t.Run("dropped events are not retried when the producer cannot be created", func(t *testing.T) {
+		c, fake := newTestClient(t, common.MapStr{
+			"hosts": []string{"localhost:6650"},
+			"topic": "logs-%{[service]}",
+		})
+		fake.createErr = errors.New("topic not found")
+
+		noTopic := beat.Event{Timestamp: time.Now(), Fields: common.MapStr{"message": "no service"}}
+		batch := outest.NewBatch(append([]beat.Event{noTopic}, events...)...)
+		require.Error(t, c.Publish(context.Background(), batch))
+		require.Len(t, batch.Signals, 1)
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+		require.Len(t, batch.Signals[0].Events, len(events))
+		assert.Equal(t, "first", batch.Signals[0].Events[0].Content.Fields["message"])
+	})
+}
@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+)
+
+type backoffConfig struct {
+	Init time.Duration `config:"init"`
+	Max  time.Duration `config:"max"`
+}
+
+type pulsarConfig struct {
+	Hosts              []string                  `config:"hosts"                validate:"required"`
+	TLS                *tlscommon.Config         `config:"ssl"`
+	Token              string                    `config:"token"`
+	TokenFile          string                    `config:"token_file"`
+	Key                *fmtstr.EventFormatString `config:"key"`
+	Timeout            time.Duration             `config:"timeout"              validate:"min=1"`
+	ConnectionTimeout  time.Duration             `config:"connection_timeout"   validate:"min=1"`
+	OperationTimeout   time.Duration             `config:"operation_timeout"    validate:"min=1"`
+	Compression        string                    `config:"compression"`
+	CompressionLevel   string                    `config:"compression_level"`
+	BulkMaxSize        int                       `config:"bulk_max_size"        validate:"min=1"`
+	BulkMaxBytes       int                       `config:"bulk_max_bytes"       validate:"min=1"`
+	BulkFlushFrequency time.Duration             `config:"bulk_flush_frequency" validate:"min=0"`
+	MaxRetries         int                       `config:"max_retries"          validate:"min=-1,nonzero"`
+	Backoff            backoffConfig             `config:"backoff"`
+	Codec              codec.Config              `config:"codec"`
+}
+
+var compressionModes = map[string]pulsar.CompressionType{
+	"none": pulsar.NoCompression,
+	"no":   pulsar.NoCompression,
+	"off":  pulsar.NoCompression,
+	"lz4":  pulsar.LZ4,
+	"zlib": pulsar.ZLib,
+	"zstd": pulsar.ZSTD,
+}
+
+var compressionLevels = map[string]pulsar.CompressionLevel{
+	"default": pulsar.Default,
+	"faster":  pulsar.Faster,
+	"better":  pulsar.Better,
+}
+
+func defaultConfig() pulsarConfig {
+	return pulsarConfig{
+		Hosts:              nil,
+		TLS:                nil,
+		Timeout:            30 * time.Second,
+		ConnectionTimeout:  5 * time.Second,
+		OperationTimeout:   30 * time.Second,
+		Compression:        "lz4",
+		CompressionLevel:   "default",
+		BulkMaxSize:        2048,
+		BulkMaxBytes:       128 * 1024,
+		BulkFlushFrequency: 10 * time.Millisecond,
+		MaxRetries:         3,
+		Backoff: backoffConfig{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+}
+
+func readConfig(cfg *common.Config) (*pulsarConfig, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (c *pulsarConfig) Validate() error {
+	if len(c.Hosts) == 0 {
+		return errors.New("no hosts configured")
+	}
+	for _, host := range c.Hosts {
+		if strings.Contains(host, "://") {
+			return fmt.Errorf("host '%v' must be given as host:port, the scheme is set by the ssl settings", host)
+		}
+	}
+
+	if _, ok := compressionModes[strings.ToLower(c.Compression)]; !ok {
+		return fmt.Errorf("compression mode '%v' unknown", c.Compression)
+	}
+	if _, ok := compressionLevels[strings.ToLower(c.CompressionLevel)]; !ok {
+		return fmt.Errorf("compression level '%v' unknown", c.CompressionLevel)
+	}
+
+	auth := 0
+	if c.Token != "" {
+		auth++
+	}
+	if c.TokenFile != "" {
+		auth++
+	}
+
+	if c.TLS.IsEnabled() {
+		if len(c.TLS.CAs) > 1 {
+			return errors.New("only one certificate authority can be configured")
+		}
+		if len(c.TLS.CAs) == 1 && tlscommon.IsPEMString(c.TLS.CAs[0]) {
+			return errors.New("the certificate authority must be a path to a file")
+		}
+		if c.TLS.Certificate.Passphrase != "" {
+			return errors.New("encrypted keys are not supported")
+		}
+		if c.TLS.Certificate.Certificate != "" {
+			if tlscommon.IsPEMString(c.TLS.Certificate.Certificate) || tlscommon.IsPEMString(c.TLS.Certificate.Key) {
+				return errors.New("the certificate and the key must be paths to files")
+			}
+			auth++
+		}
+	}
+	if auth > 1 {
+		return errors.New("only one of token, token_file or ssl.certificate can be used for authentication")
+	}
+	return nil
+}
+
+// serviceURL returns the URL of the Pulsar service, the connection uses TLS when the ssl settings
+// are enabled.
+func (c *pulsarConfig) serviceURL() string {
+	scheme := "pulsar"
+	if c.TLS.IsEnabled() {
+		scheme = "pulsar+ssl"
+	}
+	u := url.URL{Scheme: scheme, Host: strings.Join(c.Hosts, ",")}
+	return u.String()
+}
+
+func newClientOptions(config *pulsarConfig) pulsar.ClientOptions {
+	opts := pulsar.ClientOptions{
+		URL:               config.serviceURL(),
+		ConnectionTimeout: config.ConnectionTimeout,
+		OperationTimeout:  config.OperationTimeout,
+	}
+
+	if config.TLS.IsEnabled() {
+		if len(config.TLS.CAs) == 1 {
+			opts.TLSTrustCertsFilePath = config.TLS.CAs[0]
+		}
+		switch config.TLS.VerificationMode {
+		case tlscommon.VerifyNone:
+			opts.TLSAllowInsecureConnection = true
+		case tlscommon.VerifyCertificate:
+			opts.TLSValidateHostname = false
+		default:
+			opts.TLSValidateHostname = true
+		}
+	}
+
+	switch {
+	case config.Token != "":
+		opts.Authentication = pulsar.NewAuthenticationToken(config.Token)
+	case config.TokenFile != "":
+		opts.Authentication = pulsar.NewAuthenticationTokenFromFile(config.TokenFile)
+	case config.TLS.IsEnabled() && config.TLS.Certificate.Certificate != "":
+		opts.Authentication = pulsar.NewAuthenticationTLS(config.TLS.Certificate.Certificate, config.TLS.Certificate.Key)
+	}
+	return opts
+}
+
+func newProducerOptions(config *pulsarConfig, topic string) pulsar.ProducerOptions {
+	return pulsar.ProducerOptions{
+		Topic:                   topic,
+		SendTimeout:             config.Timeout,
+		CompressionType:         compressionModes[strings.ToLower(config.Compression)],
+		CompressionLevel:        compressionLevels[strings.ToLower(config.CompressionLevel)],
+		BatchingMaxMessages:     uint(config.BulkMaxSize),
+		BatchingMaxSize:         uint(config.BulkMaxBytes),
+		BatchingMaxPublishDelay: config.BulkFlushFrequency,
+		DisableBatching:         config.BulkFlushFrequency == 0,
+	}
+}
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestConfigAcceptValid(t *testing.T) {
+	tests := map[string]common.MapStr{
+		"default config is valid": common.MapStr{},
+		"zstd with better level": common.MapStr{
+			"compression":       "zstd",
+			"compression_level": "better",
+		},
+		"token authentication": common.MapStr{
+			"token": "eyJhbGciOiJIUzI1NiJ9",
+		},
+		"tls authentication": common.MapStr{
+			"ssl.certificate_authorities": []string{"/etc/pulsar/ca.pem"},
+			"ssl.certificate":             "/etc/pulsar/client.pem",
+			"ssl.key":                     "/etc/pulsar/client-key.pem",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			c := common.MustNewConfigFrom(test)
+			c.SetString("hosts", 0, "localhost:6650")
+			if _, err := readConfig(c); err != nil {
+				t.Fatalf("Can not create test configuration: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	tests := map[string]common.MapStr{
+		"unknown compression": common.MapStr{
+			"compression": "snappy",
+		},
+		"unknown compression level": common.MapStr{
+			"compression_level": "fastest",
+		},
+		"host with scheme": common.MapStr{
+			"hosts": []string{"pulsar://localhost:6650"},
+		},
+		"token and token file": common.MapStr{
+			"token":      "eyJhbGciOiJIUzI1NiJ9",
+			"token_file": "/etc/pulsar/token",
+		},
+		"token and tls authentication": common.MapStr{
+			"token":           "eyJhbGciOiJIUzI1NiJ9",
+			"ssl.certificate": "/etc/pulsar/client.pem",
+			"ssl.key":         "/etc/pulsar/client-key.pem",
+		},
+		"more than one certificate authority": common.MapStr{
+			"ssl.certificate_authorities": []string{"/etc/pulsar/ca.pem", "/etc/pulsar/other-ca.pem"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			c := common.MustNewConfigFrom(test)
+			if !c.HasField("hosts") {
+				c.SetString("hosts", 0, "localhost:6650")
+			}
+			_, err := readConfig(c)
+			if err == nil {
+				t.Fatalf("Can create test configuration from invalid input")
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Run("plain connection", func(t *testing.T) {
+		config, err := readConfig(common.MustNewConfigFrom(common.MapStr{
+			"hosts": []string{"broker1:6650", "broker2:6650"},
+			"token": "my-token",
+		}))
+		require.NoError(t, err)
+
+		opts := newClientOptions(config)
+		assert.Equal(t, "pulsar://broker1:6650,broker2:6650", opts.URL)
+		assert.NotNil(t, opts.Authentication)
+		assert.Equal(t, 5*time.Second, opts.ConnectionTimeout)
+	})
+
+	t.Run("tls connection", func(t *testing.T) {
+		config, err := readConfig(common.MustNewConfigFrom(common.MapStr{
+			"hosts":                       []string{"broker1:6651"},
+			"ssl.certificate_authorities": []string{"/etc/pulsar/ca.pem"},
+			"ssl.verification_mode":       "none",
+		}))
+		require.NoError(t, err)
+
+		opts := newClientOptions(config)
+		assert.Equal(t, "pulsar+ssl://broker1:6651", opts.URL)
+		assert.Equal(t, "/etc/pulsar/ca.pem", opts.TLSTrustCertsFilePath)
+		assert.True(t, opts.TLSAllowInsecureConnection)
+		assert.Nil(t, opts.Authentication)
+	})
+
+	t.Run("producer batching and compression", func(t *testing.T) {
+		config, err := readConfig(common.MustNewConfigFrom(common.MapStr{
+			"hosts":                []string{"broker1:6650"},
+			"compression":          "ZLIB",
+			"bulk_max_size":        100,
+			"bulk_flush_frequency": "0s",
+		}))
+		require.NoError(t, err)
+
+		opts := newProducerOptions(config, "persistent://public/default/beats")
+		assert.Equal(t, "persistent://public/default/beats", opts.Topic)
+		assert.Equal(t, pulsar.ZLib, opts.CompressionType)
+		assert.Equal(t, uint(100), opts.BatchingMaxMessages)
+		assert.True(t, opts.DisableBatching)
+	})
+}
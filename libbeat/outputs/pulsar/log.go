@@ -0,0 +1,63 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar/log"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+)
+
+// pulsarLogger forwards the logs of the Pulsar client to the logger of the output.
+type pulsarLogger struct {
+	log *logp.Logger
+}
+
+func (pl pulsarLogger) SubLogger(fields log.Fields) log.Logger {
+	return pl.withFields(fields)
+}
+
+func (pl pulsarLogger) WithFields(fields log.Fields) log.Entry {
+	return pl.withFields(fields)
+}
+
+func (pl pulsarLogger) WithField(name string, value interface{}) log.Entry {
+	return pulsarLogger{log: pl.log.With(name, value)}
+}
+
+func (pl pulsarLogger) WithError(err error) log.Entry {
+	return pulsarLogger{log: pl.log.With("error", err)}
+}
+
+func (pl pulsarLogger) withFields(fields log.Fields) pulsarLogger {
+	args := make([]interface{}, 0, 2*len(fields))
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	return pulsarLogger{log: pl.log.With(args...)}
+}
+
+func (pl pulsarLogger) Debug(args ...interface{}) { pl.log.Debug(args...) }
+func (pl pulsarLogger) Info(args ...interface{})  { pl.log.Info(args...) }
+func (pl pulsarLogger) Warn(args ...interface{})  { pl.log.Warn(args...) }
+func (pl pulsarLogger) Error(args ...interface{}) { pl.log.Error(args...) }
+
+func (pl pulsarLogger) Debugf(format string, args ...interface{}) { pl.log.Debugf(format, args...) }
+func (pl pulsarLogger) Infof(format string, args ...interface{})  { pl.log.Infof(format, args...) }
+func (pl pulsarLogger) Warnf(format string, args ...interface{})  { pl.log.Warnf(format, args...) }
+func (pl pulsarLogger) Errorf(format string, args ...interface{}) { pl.log.Errorf(format, args...) }
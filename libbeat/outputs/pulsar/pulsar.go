@@ -0,0 +1,77 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/outil"
+)
+
+const (
+	logSelector = "pulsar"
+)
+
+func init() {
+	outputs.RegisterType("pulsar", makePulsar)
+}
+
+func makePulsar(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	log := logp.NewLogger(logSelector)
+	log.Debug("initialize pulsar output")
+
+	config, err := readConfig(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	topic, err := buildTopicSelector(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	codec, err := codec.CreateEncoder(beat, config.Codec)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	client, err := newPulsarClient(observer, beat.IndexPrefix, topic, codec, config)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	return outputs.Success(config.BulkMaxSize, config.MaxRetries, outputs.WithBackoff(client, config.Backoff.Init, config.Backoff.Max))
+}
+
+func buildTopicSelector(cfg *common.Config) (outil.Selector, error) {
+	return outil.BuildSelectorFromConfig(cfg, outil.Settings{
+		Key:              "topic",
+		MultiKey:         "topics",
+		EnableSingleOnly: true,
+		FailEmpty:        true,
+		Case:             outil.SelectorKeepCase,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/elastic/beats/v7/libbeat/keystore"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
@@ -15,11 +16,14 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/reexec"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/upgrade"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config/operations"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/systemd"
 )
 
 // Application is the application interface implemented by the different running mode.
@@ -43,6 +47,11 @@ func New(log *logger.Logger, reexec reexecManager, statusCtrl status.Controller,
 	// Load configuration from disk to understand in which mode of operation
 	// we must start the elastic-agent, the mode of operation cannot be changed without restarting the
 	// elastic-agent.
+	agentKeystore, err := operations.LoadKeystore()
+	if err != nil {
+		return nil, err
+	}
+
 	pathConfigFile := paths.ConfigFile()
 	rawConfig, err := config.LoadFile(pathConfigFile)
 	if err != nil {
@@ -53,13 +62,14 @@ func New(log *logger.Logger, reexec reexecManager, statusCtrl status.Controller,
 		return nil, err
 	}
 
-	return createApplication(log, pathConfigFile, rawConfig, reexec, statusCtrl, uc, agentInfo)
+	return createApplication(log, pathConfigFile, rawConfig, agentKeystore, reexec, statusCtrl, uc, agentInfo)
 }
 
 func createApplication(
 	log *logger.Logger,
 	pathConfigFile string,
 	rawConfig *config.Config,
+	agentKeystore keystore.Keystore,
 	reexec reexecManager,
 	statusCtrl status.Controller,
 	uc upgraderControl,
@@ -74,7 +84,7 @@ func createApplication(
 
 	if configuration.IsStandalone(cfg.Fleet) {
 		log.Info("Agent is managed locally")
-		return newLocal(ctx, log, paths.ConfigFile(), rawConfig, reexec, statusCtrl, uc, agentInfo)
+		return newLocal(ctx, log, paths.ConfigFile(), rawConfig, agentKeystore, reexec, statusCtrl, uc, agentInfo)
 	}
 
 	// not in standalone; both modes require reading the fleet.yml configuration file
@@ -95,7 +105,7 @@ func createApplication(
 
 func mergeFleetConfig(rawConfig *config.Config) (storage.Store, *configuration.Configuration, error) {
 	path := paths.AgentConfigFile()
-	store := storage.NewDiskStore(path)
+	store := storage.NewFleetConfigStore(path)
 	reader, err := store.Load()
 	if err != nil {
 		return store, nil, errors.New(err, "could not initialize config store",
@@ -136,3 +146,15 @@ func mergeFleetConfig(rawConfig *config.Config) (storage.Store, *configuration.C
 
 	return store, cfg, nil
 }
+
+// notifyReady notifies systemd that the agent is ready once a policy is applied, either the
+// cached policy or the first one received.
+func notifyReady(emit pipeline.EmitterFunc) pipeline.EmitterFunc {
+	return func(c *config.Config) error {
+		if err := emit(c); err != nil {
+			return err
+		}
+		systemd.Ready()
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+// changeDebounce is the time waited after the last change of the files before reloading the
+// configuration, editors and configuration management tools often write a file in several steps.
+const changeDebounce = 500 * time.Millisecond
+
+// changeNotifier notifies the changes of the files in the directories of the configuration. The
+// directories are watched rather than the files, to see the new files and the files replaced by
+// a rename.
+type changeNotifier struct {
+	log     *logger.Logger
+	watcher *fsnotify.Watcher
+	changes chan struct{}
+	done    chan struct{}
+}
+
+func newChangeNotifier(log *logger.Logger, patterns ...string) (*changeNotifier, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.New(err, "could not create the watcher of the configuration files", errors.TypeFilesystem)
+	}
+
+	dirs := make(map[string]struct{})
+	for _, pattern := range patterns {
+		if len(pattern) == 0 {
+			continue
+		}
+		dir := filepath.Dir(pattern)
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+		dirs[dir] = struct{}{}
+		if err := watcher.Add(dir); err != nil {
+			// a missing directory is only seen by the periodic check of the files.
+			log.Debugf("Could not watch the configuration directory %s: %s", dir, err)
+		}
+	}
+
+	n := &changeNotifier{
+		log:     log,
+		watcher: watcher,
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go n.run()
+	return n, nil
+}
+
+// Changes returns a channel receiving a value once the files stop changing.
+func (n *changeNotifier) Changes() <-chan struct{} {
+	return n.changes
+}
+
+func (n *changeNotifier) run() {
+	debounce := time.NewTimer(changeDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case event, ok := <-n.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			n.log.Debugf("Configuration file %s changed: %s", event.Name, event.Op)
+			debounce.Reset(changeDebounce)
+		case err, ok := <-n.watcher.Errors:
+			if !ok {
+				return
+			}
+			n.log.Debugf("Error watching the configuration files: %s", err)
+		case <-debounce.C:
+			select {
+			case n.changes <- struct{}{}:
+			default:
+				// a reload is already pending.
+			}
+		}
+	}
+}
+
+// Close stops watching the files.
+func (n *changeNotifier) Close() error {
+	close(n.done)
+	return n.watcher.Close()
+}
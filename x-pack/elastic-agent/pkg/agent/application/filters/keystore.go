@@ -0,0 +1,70 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package filters
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/keystore"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+// secretRegex matches the plain variables, variables with a default value or a list of
+// alternatives are left to the rendering of the inputs.
+var secretRegex = regexp.MustCompile(`\${([^{}|'"]+)}`)
+
+// KeystoreResolver replaces the variables of the inputs named after a secret of the keystore with
+// the secret, the other variables are kept for the rendering of the inputs.
+//
+// The variables outside of the inputs are resolved when the configuration is unpacked.
+func KeystoreResolver(store keystore.Keystore) func(*logger.Logger, *transpiler.AST) error {
+	return func(_ *logger.Logger, ast *transpiler.AST) error {
+		inputsNode, found := transpiler.Lookup(ast, "inputs")
+		if !found {
+			return nil
+		}
+
+		inputs, ok := inputsNode.Value().(*transpiler.List)
+		if !ok {
+			return nil
+		}
+
+		resolved, err := replaceStrings(inputs, func(value string) (string, error) {
+			return replaceSecrets(store, value)
+		})
+		if err != nil {
+			return err
+		}
+		return transpiler.Insert(ast, resolved, "inputs")
+	}
+}
+
+func replaceSecrets(store keystore.Keystore, value string) (string, error) {
+	var err error
+	replaced := secretRegex.ReplaceAllStringFunc(value, func(match string) string {
+		key := strings.TrimSpace(secretRegex.FindStringSubmatch(match)[1])
+		secret, retrieveErr := store.Retrieve(key)
+		if retrieveErr == keystore.ErrKeyDoesntExists {
+			return match
+		}
+		if retrieveErr != nil {
+			err = retrieveErr
+			return match
+		}
+		v, getErr := secret.Get()
+		if getErr != nil {
+			err = getErr
+			return match
+		}
+		return string(v)
+	})
+	if err != nil {
+		return "", errors.New(err, "could not read the secrets of the inputs from the keystore", errors.TypeConfig)
+	}
+	return replaced, nil
+}
@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package filters
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/keystore"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func TestKeystoreResolver(t *testing.T) {
+	store, err := keystore.NewFileKeystore(filepath.Join(t.TempDir(), "elastic-agent.keystore"))
+	require.NoError(t, err)
+	writable, err := keystore.AsWritableKeystore(store)
+	require.NoError(t, err)
+	require.NoError(t, writable.Store("API_KEY", []byte("secret")))
+
+	ast, err := transpiler.NewAST(map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{"api_key": "${API_KEY}"},
+		},
+		"inputs": []map[string]interface{}{
+			{
+				"type": "httpjson",
+				"streams": []map[string]interface{}{
+					{
+						"request.url":     "https://${host.name}/api",
+						"request.headers": []string{"Authorization: ApiKey ${API_KEY}"},
+						"token":           "${API_KEY}",
+						"missing":         "${UNKNOWN}",
+						"default":         "${API_KEY|'none'}",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	log, _ := logger.New("", false)
+	require.NoError(t, KeystoreResolver(store)(log, ast))
+
+	expected, err := transpiler.NewAST(map[string]interface{}{
+		// resolved when unpacking the configuration.
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{"api_key": "${API_KEY}"},
+		},
+		"inputs": []map[string]interface{}{
+			{
+				"type": "httpjson",
+				"streams": []map[string]interface{}{
+					{
+						"request.url":     "https://${host.name}/api",
+						"request.headers": []string{"Authorization: ApiKey secret"},
+						"token":           "secret",
+						"missing":         "${UNKNOWN}",
+						"default":         "${API_KEY|'none'}",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), ast.String())
+}
@@ -0,0 +1,62 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package filters
+
+import (
+	"context"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets"
+)
+
+// secretsKeys are the sections of the configuration where the references to the secrets are
+// resolved.
+var secretsKeys = []string{"inputs", "outputs"}
+
+// SecretsResolver replaces the references to the secrets of the external providers with the
+// secrets, a reference which cannot be resolved fails the configuration.
+func SecretsResolver(resolver *secrets.Resolver) func(*logger.Logger, *transpiler.AST) error {
+	return func(_ *logger.Logger, ast *transpiler.AST) error {
+		// a secret referenced multiple times is fetched once.
+		resolved := make(map[string]string)
+		resolve := func(value string) (string, error) {
+			if secret, ok := resolved[value]; ok {
+				return secret, nil
+			}
+			secret, err := resolver.Resolve(context.Background(), value)
+			if err != nil {
+				return "", err
+			}
+			resolved[value] = secret
+			return secret, nil
+		}
+
+		for _, key := range secretsKeys {
+			node, found := transpiler.Lookup(ast, key)
+			if !found {
+				continue
+			}
+			value, ok := node.Value().(transpiler.Node)
+			if !ok {
+				continue
+			}
+			switch value.(type) {
+			case *transpiler.Dict, *transpiler.List:
+			default:
+				continue
+			}
+
+			replaced, err := replaceStrings(value, resolve)
+			if err != nil {
+				return err
+			}
+			if err := transpiler.Insert(ast, replaced, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets"
+)
+
+type testSecretsProvider struct {
+	fetches int
+}
+
+func (p *testSecretsProvider) Fetch(_ context.Context, path, field string) (string, error) {
+	p.fetches++
+	if path == "secret/elasticsearch" && field == "password" {
+		return "changeme", nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestSecretsResolver(t *testing.T) {
+	log, _ := logger.New("", false)
+	provider := &testSecretsProvider{}
+	resolver := secrets.NewResolverWithProviders(map[string]secrets.Provider{"vault": provider})
+
+	ast, err := transpiler.NewAST(map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{
+				"hosts":    []string{"vault:9200"},
+				"password": "vault:secret/elasticsearch#password",
+			},
+		},
+		"inputs": []map[string]interface{}{
+			{
+				"type":     "mysql/metrics",
+				"password": "vault:secret/elasticsearch#password",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, SecretsResolver(resolver)(log, ast))
+	assert.Equal(t, 1, provider.fetches)
+
+	expected, err := transpiler.NewAST(map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{
+				"hosts":    []string{"vault:9200"},
+				"password": "changeme",
+			},
+		},
+		"inputs": []map[string]interface{}{
+			{
+				"type":     "mysql/metrics",
+				"password": "changeme",
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), ast.String())
+
+	t.Run("missing secret", func(t *testing.T) {
+		ast, err := transpiler.NewAST(map[string]interface{}{
+			"inputs": []map[string]interface{}{
+				{"type": "mysql/metrics", "password": "vault:secret/elasticsearch#username"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Error(t, SecretsResolver(resolver)(log, ast))
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package filters
+
+import (
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+)
+
+// replaceStrings returns a copy of the node with the string values replaced by the result of fn.
+func replaceStrings(node transpiler.Node, fn func(string) (string, error)) (transpiler.Node, error) {
+	switch n := node.(type) {
+	case *transpiler.Key:
+		value, ok := n.Value().(transpiler.Node)
+		if !ok {
+			return n, nil
+		}
+		replaced, err := replaceStrings(value, fn)
+		if err != nil {
+			return nil, err
+		}
+		return transpiler.NewKey(n.Name(), replaced), nil
+	case *transpiler.Dict:
+		nodes, err := replaceStringsList(n.Value().([]transpiler.Node), fn)
+		if err != nil {
+			return nil, err
+		}
+		return transpiler.NewDictWithProcessors(nodes, n.Processors()), nil
+	case *transpiler.List:
+		nodes, err := replaceStringsList(n.Value().([]transpiler.Node), fn)
+		if err != nil {
+			return nil, err
+		}
+		return transpiler.NewListWithProcessors(nodes, n.Processors()), nil
+	case *transpiler.StrVal:
+		value, err := fn(n.Value().(string))
+		if err != nil {
+			return nil, err
+		}
+		return transpiler.NewStrValWithProcessors(value, n.Processors()), nil
+	}
+	return node, nil
+}
+
+func replaceStringsList(nodes []transpiler.Node, fn func(string) (string, error)) ([]transpiler.Node, error) {
+	replaced := make([]transpiler.Node, 0, len(nodes))
+	for _, node := range nodes {
+		r, err := replaceStrings(node, fn)
+		if err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, r)
+	}
+	return replaced, nil
+}
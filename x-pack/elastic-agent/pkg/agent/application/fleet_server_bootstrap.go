@@ -114,6 +114,7 @@ func newFleetServerBootstrap(
 	if err != nil {
 		return nil, err
 	}
+	emit = notifyReady(emit)
 
 	discover := discoverer(pathConfigFile, cfg.Settings.Path)
 	bootstrapApp.source = newOnce(log, discover, emit)
@@ -0,0 +1,345 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	reporterConfig "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet/config"
+)
+
+type settingsStore interface {
+	Save(io.Reader) error
+	Load() (io.ReadCloser, error)
+}
+
+type severitySetter interface {
+	SetMinSeverity(reporterConfig.Severity)
+}
+
+type checkinSetter interface {
+	SetCheckinFrequency(d, jitter time.Duration)
+}
+
+// fleetSettings holds the per-agent settings changed by fleet with the SETTINGS actions, they are
+// persisted so they are applied again when the agent restarts. The monitoring settings replace the
+// monitoring settings of the policy, the policy is emitted again when they change so only the
+// monitoring programs are started or stopped.
+type fleetSettings struct {
+	log   *logger.Logger
+	store settingsStore
+
+	mx       sync.Mutex
+	current  fleetapi.AgentSettings
+	emit     pipeline.EmitterFunc
+	last     *config.Config
+	reporter severitySetter
+	gateway  checkinSetter
+}
+
+// newFleetSettings creates the per-agent settings with the settings persisted by a previous run.
+func newFleetSettings(log *logger.Logger, store settingsStore) *fleetSettings {
+	s := &fleetSettings{log: log, store: store}
+	s.current = s.load()
+	return s
+}
+
+// Emitter wraps the emitter so the monitoring settings are applied to the emitted policies.
+func (s *fleetSettings) Emitter(emit pipeline.EmitterFunc) pipeline.EmitterFunc {
+	s.mx.Lock()
+	s.emit = emit
+	s.mx.Unlock()
+
+	return func(c *config.Config) error {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+
+		s.last = c
+		return s.emitLocked()
+	}
+}
+
+// SetReporter sets the fleet reporter and applies the reporting settings.
+func (s *fleetSettings) SetReporter(r severitySetter) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.reporter = r
+	return s.applyReporting(s.current.Reporting)
+}
+
+// SetGateway sets the fleet gateway and applies the gateway settings.
+func (s *fleetSettings) SetGateway(g checkinSetter) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.gateway = g
+	return s.applyGateway(s.current.Gateway)
+}
+
+// Apply applies the settings to the running agent and persists them, the settings are validated
+// before anything is applied.
+func (s *fleetSettings) Apply(settings fleetapi.AgentSettings) error {
+	if err := validateAgentSettings(settings); err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	updated := mergeAgentSettings(s.current, settings)
+	previous := s.current
+	s.current = updated
+
+	// on error the settings applied so far are rolled back so the running agent matches the
+	// persisted settings.
+	var emitted, reported bool
+	rollback := func(err error) error {
+		s.current = previous
+		if emitted {
+			if rerr := s.emitLocked(); rerr != nil {
+				s.log.Errorf("failed to restore the previous monitoring settings: %v", rerr)
+			}
+		}
+		if reported {
+			if rerr := s.applyReporting(previous.Reporting); rerr != nil {
+				s.log.Errorf("failed to restore the previous reporting settings: %v", rerr)
+			}
+		}
+		return err
+	}
+
+	if settings.Monitoring != nil {
+		if err := s.emitLocked(); err != nil {
+			return rollback(errors.New(err, "failed to apply the monitoring settings"))
+		}
+		emitted = true
+	}
+	if err := s.applyReporting(settings.Reporting); err != nil {
+		return rollback(err)
+	}
+	reported = settings.Reporting != nil
+	if err := s.applyGateway(settings.Gateway); err != nil {
+		return rollback(err)
+	}
+
+	s.persist()
+	return nil
+}
+
+// emitLocked emits the last policy with the monitoring settings applied, must be called with the
+// settings locked.
+func (s *fleetSettings) emitLocked() error {
+	if s.emit == nil || s.last == nil {
+		return nil
+	}
+
+	c, err := applyMonitoringSettings(s.last, s.current.Monitoring)
+	if err != nil {
+		return err
+	}
+	return s.emit(c)
+}
+
+func (s *fleetSettings) applyReporting(r *fleetapi.ReportingSettings) error {
+	if r == nil || r.MinSeverity == "" || s.reporter == nil {
+		return nil
+	}
+
+	var severity reporterConfig.Severity
+	if err := severity.Unpack(r.MinSeverity); err != nil {
+		return errors.New(err, "invalid reporting settings", errors.TypeConfig)
+	}
+	s.reporter.SetMinSeverity(severity)
+	s.log.Infof("Minimum severity of the events reported to fleet changed to '%s'", severity)
+	return nil
+}
+
+func (s *fleetSettings) applyGateway(g *fleetapi.GatewaySettings) error {
+	if g == nil || s.gateway == nil {
+		return nil
+	}
+
+	frequency, jitter, err := parseGatewaySettings(g)
+	if err != nil {
+		return err
+	}
+	s.gateway.SetCheckinFrequency(frequency, jitter)
+	return nil
+}
+
+// load returns the settings persisted in the store, if the store cannot be read we start with the
+// settings of the policy.
+func (s *fleetSettings) load() fleetapi.AgentSettings {
+	var settings fleetapi.AgentSettings
+	if s.store == nil {
+		return settings
+	}
+
+	reader, err := s.store.Load()
+	if err != nil {
+		s.log.Errorf("failed to load the settings of the agent changed by fleet: %v", err)
+		return settings
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		s.log.Errorf("failed to read the settings of the agent changed by fleet: %v", err)
+		return settings
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return settings
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		s.log.Errorf("failed to decode the settings of the agent changed by fleet: %v", err)
+		return fleetapi.AgentSettings{}
+	}
+	return settings
+}
+
+// persist saves the settings into the store, must be called with the settings locked.
+func (s *fleetSettings) persist() {
+	if s.store == nil {
+		return
+	}
+
+	data, err := json.Marshal(s.current)
+	if err != nil {
+		s.log.Errorf("failed to encode the settings of the agent changed by fleet: %v", err)
+		return
+	}
+	if err := s.store.Save(bytes.NewReader(data)); err != nil {
+		s.log.Errorf("failed to persist the settings of the agent changed by fleet: %v", err)
+	}
+}
+
+// applyMonitoringSettings returns a copy of the policy with the monitoring settings replaced.
+func applyMonitoringSettings(c *config.Config, m *fleetapi.MonitoringSettings) (*config.Config, error) {
+	if m == nil || (m.Enabled == nil && m.Logs == nil && m.Metrics == nil) {
+		return c, nil
+	}
+
+	mapstr, err := c.ToMapStr()
+	if err != nil {
+		return nil, errors.New(err, "could not read the policy", errors.TypeConfig)
+	}
+	updated, err := config.NewConfigFrom(mapstr)
+	if err != nil {
+		return nil, errors.New(err, "could not copy the policy", errors.TypeConfig)
+	}
+
+	monitoring := map[string]interface{}{}
+	if m.Enabled != nil {
+		monitoring["enabled"] = *m.Enabled
+	}
+	if m.Logs != nil {
+		monitoring["logs"] = *m.Logs
+	}
+	if m.Metrics != nil {
+		monitoring["metrics"] = *m.Metrics
+	}
+	err = updated.Merge(map[string]interface{}{
+		"agent": map[string]interface{}{
+			"monitoring": monitoring,
+		},
+	})
+	if err != nil {
+		return nil, errors.New(err, "could not apply the monitoring settings", errors.TypeConfig)
+	}
+	return updated, nil
+}
+
+func validateAgentSettings(settings fleetapi.AgentSettings) error {
+	if r := settings.Reporting; r != nil && r.MinSeverity != "" {
+		var severity reporterConfig.Severity
+		if err := severity.Unpack(r.MinSeverity); err != nil {
+			return errors.New(err, "invalid reporting settings", errors.TypeConfig)
+		}
+	}
+	if g := settings.Gateway; g != nil {
+		if _, _, err := parseGatewaySettings(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseGatewaySettings(g *fleetapi.GatewaySettings) (frequency, jitter time.Duration, err error) {
+	if g.CheckinFrequency != "" {
+		if frequency, err = parsePositiveDuration(g.CheckinFrequency); err != nil {
+			return 0, 0, errors.New(err, "invalid checkin frequency", errors.TypeConfig)
+		}
+	}
+	if g.Jitter != "" {
+		if jitter, err = parsePositiveDuration(g.Jitter); err != nil {
+			return 0, 0, errors.New(err, "invalid checkin jitter", errors.TypeConfig)
+		}
+	}
+	return frequency, jitter, nil
+}
+
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("expected a positive duration and received '%s'", s)
+	}
+	return d, nil
+}
+
+// mergeAgentSettings returns the current settings updated with the settings set by the action.
+func mergeAgentSettings(current, updated fleetapi.AgentSettings) fleetapi.AgentSettings {
+	if m := updated.Monitoring; m != nil {
+		merged := fleetapi.MonitoringSettings{}
+		if current.Monitoring != nil {
+			merged = *current.Monitoring
+		}
+		if m.Enabled != nil {
+			merged.Enabled = m.Enabled
+		}
+		if m.Logs != nil {
+			merged.Logs = m.Logs
+		}
+		if m.Metrics != nil {
+			merged.Metrics = m.Metrics
+		}
+		current.Monitoring = &merged
+	}
+
+	if r := updated.Reporting; r != nil && r.MinSeverity != "" {
+		current.Reporting = &fleetapi.ReportingSettings{MinSeverity: r.MinSeverity}
+	}
+
+	if g := updated.Gateway; g != nil {
+		merged := fleetapi.GatewaySettings{}
+		if current.Gateway != nil {
+			merged = *current.Gateway
+		}
+		if g.CheckinFrequency != "" {
+			merged.CheckinFrequency = g.CheckinFrequency
+		}
+		if g.Jitter != "" {
+			merged.Jitter = g.Jitter
+		}
+		current.Gateway = &merged
+	}
+	return current
+}
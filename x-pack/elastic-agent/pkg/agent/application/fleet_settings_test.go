@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	reporterConfig "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet/config"
+)
+
+func TestFleetSettings(t *testing.T) {
+	log, _ := logger.New("", false)
+	store := &memSettingsStore{}
+
+	var emitted []map[string]interface{}
+	emit := func(c *config.Config) error {
+		m, err := c.ToMapStr()
+		require.NoError(t, err)
+		emitted = append(emitted, m)
+		return nil
+	}
+	monitoringOf := func(m map[string]interface{}) interface{} {
+		return m["agent"].(map[string]interface{})["monitoring"]
+	}
+
+	policy := config.MustNewConfigFrom(map[string]interface{}{
+		"agent": map[string]interface{}{
+			"monitoring": map[string]interface{}{"enabled": true, "logs": true, "metrics": true},
+		},
+		"outputs": map[string]interface{}{"default": map[string]interface{}{"type": "elasticsearch"}},
+	})
+
+	s := newFleetSettings(log, store)
+	reporter := &severityRecorder{}
+	gateway := &checkinRecorder{}
+	require.NoError(t, s.SetReporter(reporter))
+	require.NoError(t, s.SetGateway(gateway))
+	require.NoError(t, s.Emitter(emit)(policy))
+	require.Len(t, emitted, 1)
+
+	disabled := false
+	err := s.Apply(fleetapi.AgentSettings{
+		Monitoring: &fleetapi.MonitoringSettings{Logs: &disabled},
+		Reporting:  &fleetapi.ReportingSettings{MinSeverity: "error"},
+		Gateway:    &fleetapi.GatewaySettings{CheckinFrequency: "5m"},
+	})
+	require.NoError(t, err)
+
+	// the last policy is emitted again with the monitoring settings of the agent.
+	require.Len(t, emitted, 2)
+	assert.Equal(t, map[string]interface{}{"enabled": true, "logs": false, "metrics": true}, monitoringOf(emitted[1]))
+	assert.Equal(t, reporterConfig.SeverityError, reporter.severity)
+	assert.Equal(t, 5*time.Minute, gateway.frequency)
+	assert.Equal(t, time.Duration(0), gateway.jitter)
+
+	t.Run("settings apply to the next policies", func(t *testing.T) {
+		require.NoError(t, s.Emitter(emit)(policy))
+		assert.Equal(t, map[string]interface{}{"enabled": true, "logs": false, "metrics": true}, monitoringOf(emitted[len(emitted)-1]))
+	})
+
+	t.Run("invalid settings are rejected", func(t *testing.T) {
+		count := len(emitted)
+		assert.Error(t, s.Apply(fleetapi.AgentSettings{Reporting: &fleetapi.ReportingSettings{MinSeverity: "debug"}}))
+		assert.Error(t, s.Apply(fleetapi.AgentSettings{
+			Monitoring: &fleetapi.MonitoringSettings{Enabled: &disabled},
+			Gateway:    &fleetapi.GatewaySettings{Jitter: "-1s"},
+		}))
+		assert.Len(t, emitted, count)
+		assert.Equal(t, reporterConfig.SeverityError, reporter.severity)
+	})
+
+	t.Run("settings are restored on restart", func(t *testing.T) {
+		emitted = nil
+		restored := newFleetSettings(log, store)
+		reporter := &severityRecorder{}
+		gateway := &checkinRecorder{}
+		require.NoError(t, restored.SetReporter(reporter))
+		require.NoError(t, restored.SetGateway(gateway))
+		require.NoError(t, restored.Emitter(emit)(policy))
+
+		require.Len(t, emitted, 1)
+		assert.Equal(t, map[string]interface{}{"enabled": true, "logs": false, "metrics": true}, monitoringOf(emitted[0]))
+		assert.Equal(t, reporterConfig.SeverityError, reporter.severity)
+		assert.Equal(t, 5*time.Minute, gateway.frequency)
+	})
+}
+
+type memSettingsStore struct {
+	data string
+}
+
+func (m *memSettingsStore) Save(in io.Reader) error {
+	data, err := ioutil.ReadAll(in)
+	m.data = string(data)
+	return err
+}
+
+func (m *memSettingsStore) Load() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewBufferString(m.data)), nil
+}
+
+type severityRecorder struct {
+	severity reporterConfig.Severity
+}
+
+func (r *severityRecorder) SetMinSeverity(s reporterConfig.Severity) {
+	r.severity = s
+}
+
+type checkinRecorder struct {
+	frequency time.Duration
+	jitter    time.Duration
+}
+
+func (r *checkinRecorder) SetCheckinFrequency(d, jitter time.Duration) {
+	r.frequency, r.jitter = d, jitter
+}
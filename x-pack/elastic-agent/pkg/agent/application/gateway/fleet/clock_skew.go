@@ -0,0 +1,116 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+type clockSkewSettings struct {
+	// Threshold is the difference between the clocks of the agent and of fleet-server above which
+	// a warning is logged, zero disables the detection.
+	Threshold time.Duration `config:"threshold"`
+
+	// Adjust corrects the timestamps of the events sent to fleet-server with the skew measured on
+	// the last checkin when the skew is above the threshold.
+	Adjust bool `config:"adjust"`
+}
+
+// clockSkew keeps track of the difference between the clocks of fleet-server and of the agent,
+// it is only used by the worker of the gateway.
+type clockSkew struct {
+	log      *logger.Logger
+	settings clockSkewSettings
+	metrics  *gatewayMetrics
+	offset   time.Duration // time to add to the agent clock to get the fleet-server clock.
+	exceeded bool
+}
+
+func newClockSkew(log *logger.Logger, settings clockSkewSettings, metrics *gatewayMetrics) *clockSkew {
+	return &clockSkew{
+		log:      log,
+		settings: settings,
+		metrics:  metrics,
+	}
+}
+
+// update measures the skew from the time of fleet-server and the time of the agent when the
+// response was received, a zero server time is ignored.
+func (c *clockSkew) update(serverTime, localTime time.Time) {
+	if serverTime.IsZero() || c.settings.Threshold <= 0 {
+		return
+	}
+
+	c.offset = serverTime.Sub(localTime)
+	c.metrics.clockSkew.Set(int64(c.offset / time.Millisecond))
+
+	exceeded := abs(c.offset) > c.settings.Threshold
+	if exceeded == c.exceeded {
+		return
+	}
+	c.exceeded = exceeded
+	if exceeded {
+		c.log.Warnf("The clock of the agent differs by %s from the clock of fleet-server, the timestamps of the events may be out of order in Fleet", c.offset)
+		return
+	}
+	c.log.Infof("The clock of the agent is back in sync with the clock of fleet-server, the difference is %s", c.offset)
+}
+
+// adjust returns the events with their timestamps corrected by the skew when the adjustment is
+// enabled and the skew is above the threshold, otherwise the events are returned unchanged.
+func (c *clockSkew) adjust(events []fleetapi.SerializableEvent) []fleetapi.SerializableEvent {
+	if !c.settings.Adjust || !c.exceeded {
+		return events
+	}
+
+	adjusted := make([]fleetapi.SerializableEvent, 0, len(events))
+	for _, e := range events {
+		adjusted = append(adjusted, &adjustedEvent{
+			SerializableEvent: e,
+			ts:                e.Timestamp().Add(c.offset),
+		})
+	}
+	return adjusted
+}
+
+// adjustedEvent replaces the timestamp of an event when it is serialized.
+type adjustedEvent struct {
+	fleetapi.SerializableEvent
+	ts time.Time
+}
+
+func (e *adjustedEvent) Timestamp() time.Time {
+	return e.ts
+}
+
+func (e *adjustedEvent) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(e.SerializableEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	ts, err := json.Marshal(fleetapi.Time(e.ts))
+	if err != nil {
+		return nil, err
+	}
+	fields["timestamp"] = ts
+	return json.Marshal(fields)
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,86 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+type skewEvent struct {
+	EventType string        `json:"type"`
+	Ts        fleetapi.Time `json:"timestamp"`
+	Msg       string        `json:"message"`
+}
+
+func (e *skewEvent) Type() string         { return e.EventType }
+func (e *skewEvent) Timestamp() time.Time { return time.Time(e.Ts) }
+func (e *skewEvent) Message() string      { return e.Msg }
+
+func TestClockSkew(t *testing.T) {
+	log, _ := logger.New("", false)
+	local := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+	events := []fleetapi.SerializableEvent{
+		&skewEvent{EventType: "STATE", Ts: fleetapi.Time(local), Msg: "hello"},
+	}
+
+	t.Run("skew below the threshold is not adjusted", func(t *testing.T) {
+		m := newGatewayMetrics(monitoring.NewRegistry())
+		c := newClockSkew(log, clockSkewSettings{Threshold: time.Minute, Adjust: true}, m)
+
+		c.update(local.Add(30*time.Second), local)
+		assert.Equal(t, int64(30000), m.clockSkew.Get())
+		assert.Equal(t, events, c.adjust(events))
+	})
+
+	t.Run("skew is only reported when adjust is disabled", func(t *testing.T) {
+		m := newGatewayMetrics(monitoring.NewRegistry())
+		c := newClockSkew(log, clockSkewSettings{Threshold: time.Minute}, m)
+
+		c.update(local.Add(-time.Hour), local)
+		assert.True(t, c.exceeded)
+		assert.Equal(t, int64(-3600000), m.clockSkew.Get())
+		assert.Equal(t, events, c.adjust(events))
+	})
+
+	t.Run("skew above the threshold is adjusted", func(t *testing.T) {
+		m := newGatewayMetrics(monitoring.NewRegistry())
+		c := newClockSkew(log, clockSkewSettings{Threshold: time.Minute, Adjust: true}, m)
+
+		c.update(local.Add(time.Hour), local)
+		adjusted := c.adjust(events)
+		require.Len(t, adjusted, 1)
+		assert.Equal(t, local.Add(time.Hour), adjusted[0].Timestamp())
+
+		b, err := json.Marshal(adjusted[0])
+		require.NoError(t, err)
+		decoded := &skewEvent{}
+		require.NoError(t, json.Unmarshal(b, decoded))
+		assert.Equal(t, &skewEvent{EventType: "STATE", Ts: fleetapi.Time(local.Add(time.Hour)), Msg: "hello"}, decoded)
+
+		// back in sync, the events are no longer adjusted.
+		c.update(local, local)
+		assert.False(t, c.exceeded)
+		assert.Equal(t, events, c.adjust(events))
+	})
+
+	t.Run("missing server time is ignored", func(t *testing.T) {
+		m := newGatewayMetrics(monitoring.NewRegistry())
+		c := newClockSkew(log, clockSkewSettings{Threshold: time.Minute, Adjust: true}, m)
+
+		c.update(local.Add(time.Hour), local)
+		c.update(time.Time{}, local)
+		assert.True(t, c.exceeded)
+		assert.Equal(t, int64(3600000), m.clockSkew.Get())
+	})
+}
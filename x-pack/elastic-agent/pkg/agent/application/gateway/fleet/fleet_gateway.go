@@ -6,6 +6,7 @@ package fleet
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -21,6 +22,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/systemd"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/scheduler"
 )
@@ -28,13 +30,46 @@ import (
 // Max number of times an invalid API Key is checked
 const maxUnauthCounter int = 6
 
+// Max time to wait for the worker to exit when the gateway is stopped.
+const stopTimeout = 10 * time.Second
+
+// Time a checkin can run past its long poll timeout before the systemd watchdog considers the
+// gateway hung.
+const hungCheckinMargin = time.Minute
+
+// Maximum time the checkins are suspended by the Retry-After header of a throttled checkin.
+const maxThrottleDuration = time.Hour
+
+// Maximum number of checkins sent during a tick when the events do not fit in a single checkin,
+// the remaining events are sent on the next ticks.
+const maxCheckinChunks = 10
+
+// Time fleet-server can hold the checkins sending the events left out by the payload size limit,
+// they only carry the events and do not wait for actions.
+const chunkPollTimeout = 5 * time.Second
+
 // Default Configuration for the Fleet Gateway.
 var defaultGatewaySettings = &fleetGatewaySettings{
-	Duration: 1 * time.Second,        // time between successful calls
-	Jitter:   500 * time.Millisecond, // used as a jitter for duration
+	Duration:           1 * time.Second,         // time between successful calls
+	Jitter:             500 * time.Millisecond,  // used as a jitter for duration
+	JitterDistribution: scheduler.JitterUniform, // distribution of the jitter
+	InitialSpread:      0,                       // the first checkin only waits for the jitter
 	Backoff: backoffSettings{ // time after a failed call
-		Init: 60 * time.Second,
-		Max:  10 * time.Minute,
+		Init:       60 * time.Second,
+		Max:        10 * time.Minute,
+		MaxRetries: 10, // retries before the failure is reported and the next tick is awaited
+	},
+	MaxEvents:       1000,            // events sent per checkin, the remaining events are sent on the next checkins
+	MaxPayloadSize:  1024 * 1024,     // size of a checkin body, the default payload limit of Kibana
+	MetadataRefresh: 1 * time.Minute, // time between two refreshes of the local metadata
+	Timeouts: timeoutSettings{
+		Connect:  30 * time.Second, // time to get a connection to fleet-server
+		Request:  2 * time.Minute,  // time of the acks
+		LongPoll: 10 * time.Minute, // time of a checkin, fleet-server holds the checkin for 5 minutes by default
+	},
+	ClockSkew: clockSkewSettings{
+		Threshold: 1 * time.Minute, // difference between the agent and fleet-server clocks which is reported
+		Adjust:    false,           // timestamps of the events are kept as created by the agent
 	},
 }
 
@@ -42,19 +77,82 @@ type fleetGatewaySettings struct {
 	Duration time.Duration   `config:"checkin_frequency"`
 	Jitter   time.Duration   `config:"jitter"`
 	Backoff  backoffSettings `config:"backoff"`
+
+	// JitterDistribution is the distribution of the jitter added to the time between checkins.
+	JitterDistribution scheduler.JitterDistribution `config:"jitter_distribution"`
+
+	// InitialSpread is the window over which the first checkin is spread after the agent starts, so
+	// agents restarted together do not check in together. Zero only waits for the jitter.
+	InitialSpread time.Duration `config:"initial_spread"`
+
+	// Compression enables the gzip compression of the checkin request bodies.
+	Compression bool `config:"compression"`
+
+	// MaxEvents is the maximum number of events sent in a single checkin, zero means no limit.
+	MaxEvents int `config:"max_events"`
+
+	// MaxPayloadSize is the maximum size in bytes of the serialized body of a checkin, the events
+	// over the limit are sent with the following checkins of the same tick. Zero means no limit.
+	MaxPayloadSize int `config:"max_payload_size"`
+
+	// MetadataRefresh is the time between two refreshes of the local metadata, the metadata is
+	// only sent to fleet-server when it changed. Zero refreshes the metadata before every checkin.
+	MetadataRefresh time.Duration `config:"metadata_refresh"`
+
+	// Timeouts of the checkins and of the acks sent to fleet-server, zero disables a timeout.
+	Timeouts timeoutSettings `config:"timeouts"`
+
+	// ClockSkew configures the detection of the difference between the clocks of the agent and of
+	// fleet-server, measured with the time of the checkin responses.
+	ClockSkew clockSkewSettings `config:"clock_skew"`
 }
 
 type backoffSettings struct {
 	Init time.Duration `config:"init"`
 	Max  time.Duration `config:"max"`
+
+	// MaxRetries is the number of consecutive failed checkins retried before giving up until
+	// the next scheduled tick, zero means retry until the gateway is stopped.
+	MaxRetries int `config:"max_retries"`
 }
 
 type agentInfo interface {
 	AgentID() string
+	Tags() []string
+}
+
+// durationSetter is implemented by schedulers which allow the time between ticks to be changed
+// while running.
+type durationSetter interface {
+	SetDuration(time.Duration)
+}
+
+// varianceSetter is implemented by schedulers which allow the jitter between ticks to be changed
+// while running.
+type varianceSetter interface {
+	SetVariance(time.Duration)
+}
+
+// outcomeReceiver is implemented by schedulers which adapt the time between ticks to the outcome
+// of the checkins.
+type outcomeReceiver interface {
+	Success()
+	Failure()
+}
+
+// appliedPolicy is implemented by dispatchers which know the revision of the policy applied by the
+// agent, the revision is sent with the checkins.
+type appliedPolicy interface {
+	AppliedRevision() (policyID string, revision int64, ok bool)
 }
 
 type fleetReporter interface {
-	Events() ([]fleetapi.SerializableEvent, func())
+	EventsBatch(size int) ([]fleetapi.SerializableEvent, func(rejected ...int))
+}
+
+// pendingReporter is implemented by reporters which know the number of events not yet sent.
+type pendingReporter interface {
+	Pending() int
 }
 
 type stateStore interface {
@@ -67,6 +165,7 @@ type stateStore interface {
 
 type fleetGateway struct {
 	bgContext        context.Context
+	cancel           context.CancelFunc
 	log              *logger.Logger
 	dispatcher       pipeline.Dispatcher
 	client           client.Sender
@@ -82,6 +181,28 @@ type fleetGateway struct {
 	statusController status.Controller
 	statusReporter   status.Reporter
 	stateStore       stateStore
+	checkinFrequency time.Duration
+	// pinnedFrequency is true when the checkin frequency was set by the settings of the agent, the
+	// frequency suggested by fleet-server is ignored.
+	pinnedFrequency bool
+	freqMx          sync.Mutex
+	metrics         *gatewayMetrics
+	metadata        *metadataCollector
+	clockSkew       *clockSkew
+	probe           *systemd.Probe
+	// eventSchema is the version of the schema of the events negotiated with fleet-server.
+	eventSchema int
+	// chunked is true when the last checkin left events out to stay under the payload size limit.
+	chunked bool
+
+	pauseMx sync.Mutex
+	resumed chan struct{} // closed on resume, nil when the gateway is not paused.
+
+	// onCheckin is called after each successful checkin, nil when nothing has to be notified.
+	onCheckin func()
+	// drainTimeout bounds the last checkin sending the pending events when the gateway is
+	// stopped, zero leaves the pending events to the next run.
+	drainTimeout time.Duration
 }
 
 // New creates a new fleet gateway
@@ -97,7 +218,12 @@ func New(
 	stateStore stateStore,
 ) (gateway.FleetGateway, error) {
 
-	scheduler := scheduler.NewPeriodicJitter(defaultGatewaySettings.Duration, defaultGatewaySettings.Jitter)
+	scheduler := scheduler.NewPeriodicJitter(
+		defaultGatewaySettings.Duration,
+		defaultGatewaySettings.Jitter,
+		scheduler.WithJitterDistribution(defaultGatewaySettings.JitterDistribution),
+		scheduler.WithInitialSpread(defaultGatewaySettings.InitialSpread),
+	)
 	return newFleetGatewayWithScheduler(
 		ctx,
 		log,
@@ -127,13 +253,21 @@ func newFleetGatewayWithScheduler(
 	stateStore stateStore,
 ) (gateway.FleetGateway, error) {
 
+	ctx, cancel := context.WithCancel(ctx)
+
 	// Backoff implementation doesn't support the use of a context [cancellation]
 	// as the shutdown mechanism.
 	// So we keep a done channel that will be closed when the current context is shutdown.
 	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
 
+	metrics := newGatewayMetrics(gatewayRegistry())
 	return &fleetGateway{
 		bgContext:  ctx,
+		cancel:     cancel,
 		log:        log,
 		dispatcher: d,
 		client:     client,
@@ -147,47 +281,55 @@ func newFleetGatewayWithScheduler(
 		),
 		done:             done,
 		reporter:         r,
-		acker:            acker,
+		acker:            &timeoutAcker{acker: acker, timeouts: settings.Timeouts},
 		statusReporter:   statusController.RegisterComponent("gateway"),
 		statusController: statusController,
 		stateStore:       stateStore,
+		checkinFrequency: settings.Duration,
+		metrics:          metrics,
+		metadata:         newMetadataCollector(log, metadataScheduler(settings.MetadataRefresh), info.Metadata),
+		clockSkew:        newClockSkew(log, settings.ClockSkew, metrics),
+		probe:            systemd.NewProbe("fleet gateway checkin"),
+		eventSchema:      fleetapi.EventSchemaVersion,
 	}, nil
 }
 
 func (f *fleetGateway) worker() {
 	for {
 		select {
-		case <-f.scheduler.WaitTick():
+		case <-f.scheduler.WaitTick(f.bgContext):
+			if !f.waitResumed() {
+				continue
+			}
 			f.log.Debug("FleetGateway calling Checkin API")
 
 			// Execute the checkin call and for any errors returned by the fleet-server API
 			// the function will retry to communicate with fleet-server with an exponential delay and some
 			// jitter to help better distribute the load from a fleet of agents.
 			resp, err := f.doExecute()
+			f.reportOutcome(err)
 			if err != nil {
 				f.log.Error(err)
 				f.statusReporter.Update(state.Failed, err.Error(), nil)
 				continue
 			}
 
-			actions := make([]fleetapi.Action, len(resp.Actions))
-			for idx, a := range resp.Actions {
-				actions[idx] = a
+			f.updateCheckinFrequency(resp.CheckinFrequencySec)
+			if f.onCheckin != nil {
+				f.onCheckin()
 			}
 
-			var errMsg string
-			if err := f.dispatcher.Dispatch(f.acker, actions...); err != nil {
-				errMsg = fmt.Sprintf("failed to dispatch actions, error: %s", err)
-				f.log.Error(errMsg)
-				f.statusReporter.Update(state.Failed, errMsg, nil)
+			// acks buffered while fleet-server was unreachable are sent before the acks of the new actions.
+			if err := f.acker.Commit(f.bgContext); err != nil {
+				f.log.Errorf("failed to send the buffered acknowledgments, they are sent with the next acknowledgments: %v", err)
 			}
 
-			f.log.Debugf("FleetGateway is sleeping, next update in %s", f.settings.Duration)
-			if errMsg != "" {
-				f.statusReporter.Update(state.Failed, errMsg, nil)
-			} else {
-				f.statusReporter.Update(state.Healthy, "", nil)
-			}
+			// the actions are dispatched before the events left out by the payload size limit are
+			// sent, so a backlog of events does not delay them.
+			f.dispatch(resp.Actions)
+			f.sendChunks()
+
+			f.log.Debugf("FleetGateway is sleeping, next update in %s", f.frequency())
 
 		case <-f.bgContext.Done():
 			f.stop()
@@ -196,15 +338,160 @@ func (f *fleetGateway) worker() {
 	}
 }
 
+// reportOutcome lets schedulers which support it adapt the time until the next tick.
+func (f *fleetGateway) reportOutcome(err error) {
+	s, ok := f.scheduler.(outcomeReceiver)
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		s.Failure()
+		return
+	}
+	s.Success()
+}
+
+// updateCheckinFrequency reconfigures the scheduler when the server suggests a different time
+// between checkins.
+func (f *fleetGateway) updateCheckinFrequency(sec int) {
+	if sec <= 0 {
+		return
+	}
+
+	d := time.Duration(sec) * time.Second
+	f.freqMx.Lock()
+	defer f.freqMx.Unlock()
+	if d == f.checkinFrequency || f.pinnedFrequency {
+		return
+	}
+
+	s, ok := f.scheduler.(durationSetter)
+	if !ok {
+		f.log.Debugf("FleetGateway scheduler does not support a checkin frequency of %s suggested by fleet-server", d)
+		return
+	}
+
+	f.log.Infof("FleetGateway checkin frequency changed from %s to %s by fleet-server", f.checkinFrequency, d)
+	s.SetDuration(d)
+	f.checkinFrequency = d
+}
+
+// SetCheckinFrequency changes the time between checkins and the jitter added to it, a zero value
+// keeps the current value. The frequency takes precedence over the frequency suggested by
+// fleet-server from now on.
+func (f *fleetGateway) SetCheckinFrequency(d, jitter time.Duration) {
+	if d > 0 {
+		if s, ok := f.scheduler.(durationSetter); ok {
+			f.freqMx.Lock()
+			f.log.Infof("FleetGateway checkin frequency changed from %s to %s by the agent settings", f.checkinFrequency, d)
+			s.SetDuration(d)
+			f.checkinFrequency = d
+			f.pinnedFrequency = true
+			f.freqMx.Unlock()
+		} else {
+			f.log.Debugf("FleetGateway scheduler does not support a checkin frequency of %s", d)
+		}
+	}
+
+	if jitter > 0 {
+		if s, ok := f.scheduler.(varianceSetter); ok {
+			f.log.Infof("FleetGateway checkin jitter changed to %s by the agent settings", jitter)
+			s.SetVariance(jitter)
+		} else {
+			f.log.Debugf("FleetGateway scheduler does not support a checkin jitter of %s", jitter)
+		}
+	}
+}
+
+func (f *fleetGateway) frequency() time.Duration {
+	f.freqMx.Lock()
+	defer f.freqMx.Unlock()
+	return f.checkinFrequency
+}
+
+// dispatch dispatches the actions received with a checkin and updates the status of the gateway
+// with the outcome.
+func (f *fleetGateway) dispatch(received []fleetapi.Action) {
+	actions := make([]fleetapi.Action, len(received))
+	for idx, a := range received {
+		actions[idx] = a
+		f.metrics.actionReceived(a.Type())
+	}
+
+	if err := f.dispatcher.Dispatch(f.acker, actions...); err != nil {
+		errMsg := fmt.Sprintf("failed to dispatch actions, error: %s", err)
+		f.log.Error(errMsg)
+		errStatus := state.Failed
+		// the agent keeps running with its previous state when the handler rolled back.
+		if errors.Is(err, pipeline.ErrRolledBack) {
+			errStatus = state.Degraded
+		}
+		f.statusReporter.Update(errStatus, errMsg, nil)
+		return
+	}
+	f.statusReporter.Update(state.Healthy, "", nil)
+}
+
+// sendChunks sends the events left out of the previous checkin by the payload size limit with
+// additional checkins. fleet-server answers them without waiting for actions and they are not
+// retried, a failure leaves the remaining events to the next tick.
+func (f *fleetGateway) sendChunks() {
+	for chunk := 1; f.chunked && chunk < maxCheckinChunks && f.bgContext.Err() == nil; chunk++ {
+		f.log.Debugf("FleetGateway sending the events over the payload size limit with checkin %d", chunk+1)
+		started := f.metrics.checkinStarted()
+		ctx, done := withTimeouts(f.bgContext, f.settings.Timeouts.Connect, f.settings.Timeouts.Request)
+		resp, err := f.execute(ctx, chunkPollTimeout, false)
+		err = done(err)
+		f.metrics.checkinFinished(started, err)
+		if err != nil {
+			f.log.Errorf("FleetGateway could not send the remaining events, they are sent on the next checkin: %v", err)
+			return
+		}
+		if len(resp.Actions) > 0 {
+			f.dispatch(resp.Actions)
+		}
+	}
+}
+
 func (f *fleetGateway) doExecute() (*fleetapi.CheckinResponse, error) {
-	f.backoff.Reset()
+	// The backoff is only reset on a successful checkin, when the retry budget is exhausted the
+	// next tick will continue to wait with the duration reached by the previous retries.
+	retries := 0
 	for f.bgContext.Err() == nil {
-		// TODO: wrap with timeout context
 		f.log.Debugf("Checking started")
-		resp, err := f.execute(f.bgContext)
+		started := f.metrics.checkinStarted()
+		ctx, done := withTimeouts(f.bgContext, f.settings.Timeouts.Connect, f.settings.Timeouts.LongPoll)
+		checked := f.probe.Busy(f.hungCheckinTimeout())
+		resp, err := f.execute(ctx, 0, false)
+		checked()
+		err = done(err)
+		f.metrics.checkinFinished(started, err)
+		if d, ok := throttledFor(err); ok {
+			// a throttled checkin is not a failure, the checkins are suspended as asked by fleet-server.
+			if !f.waitThrottled(d) {
+				return nil, errors.New(
+					"execute retry loop was stopped",
+					errors.TypeNetwork,
+					errors.M(errors.MetaKeyURI, f.client.URI()),
+				)
+			}
+			continue
+		}
 		if err != nil {
+			f.recordState(err)
+			retries++
+			if f.settings.Backoff.MaxRetries > 0 && retries > f.settings.Backoff.MaxRetries {
+				return nil, errors.New(
+					err,
+					fmt.Sprintf("checkin failed after %d retries", f.settings.Backoff.MaxRetries),
+					errors.TypeNetwork,
+					errors.M(errors.MetaKeyURI, f.client.URI()),
+				)
+			}
+
 			f.log.Errorf("Could not communicate with fleet-server Checking API will retry, error: %s", err)
-			if !f.backoff.Wait() {
+			if !f.backoff.Wait() || !f.waitResumed() {
 				return nil, errors.New(
 					"execute retry loop was stopped",
 					errors.TypeNetwork,
@@ -213,20 +500,73 @@ func (f *fleetGateway) doExecute() (*fleetapi.CheckinResponse, error) {
 			}
 			continue
 		}
+		f.backoff.Reset()
+		f.recordState(nil)
+		f.clockSkew.update(resp.ServerTime, time.Now())
+		if f.metrics.checkinServedBy(resp.Host) {
+			f.log.Infof("FleetGateway checkin served by fleet-server host %s", resp.Host)
+		}
 		return resp, nil
 	}
 
 	return nil, f.bgContext.Err()
 }
 
-func (f *fleetGateway) execute(ctx context.Context) (*fleetapi.CheckinResponse, error) {
-	// get events
-	ee, ack := f.reporter.Events()
+// throttledFor returns the time to wait before the next checkin when fleet-server throttled the
+// checkin with a Retry-After header, a throttled checkin without the header is retried with the
+// backoff like any failed checkin.
+func throttledFor(err error) (time.Duration, bool) {
+	var throttled *fleetapi.ThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		return 0, false
+	}
+	if throttled.RetryAfter > maxThrottleDuration {
+		return maxThrottleDuration, true
+	}
+	return throttled.RetryAfter, true
+}
+
+// waitThrottled suspends the checkins for the time asked by fleet-server, the gateway reports a
+// degraded status meanwhile. It returns false when the gateway is stopped.
+func (f *fleetGateway) waitThrottled(d time.Duration) bool {
+	f.log.Warnf("FleetGateway checkin was throttled by fleet-server, next checkin in %s", d)
+	f.metrics.checkinsThrottled.Inc()
+	f.metrics.throttled.Set(true)
+	defer f.metrics.throttled.Set(false)
+	f.statusReporter.Update(state.Degraded, fmt.Sprintf("checkin throttled by fleet-server, next checkin in %s", d), nil)
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return f.waitResumed()
+	case <-f.bgContext.Done():
+		return false
+	}
+}
 
-	ecsMeta, err := info.Metadata()
-	if err != nil {
-		f.log.Error(errors.New("failed to load metadata", err))
+// hungCheckinTimeout returns the time after which a checkin is considered hung, the checkin is
+// never considered hung when it has no long poll timeout.
+func (f *fleetGateway) hungCheckinTimeout() time.Duration {
+	if f.settings.Timeouts.LongPoll <= 0 {
+		return 0
 	}
+	return f.settings.Timeouts.Connect + f.settings.Timeouts.LongPoll + hungCheckinMargin
+}
+
+// execute sends a checkin, a positive poll timeout asks fleet-server to answer within it instead of
+// holding the checkin until actions are available. A draining checkin keeps the ack token so the
+// actions it receives are received again by the next run.
+func (f *fleetGateway) execute(ctx context.Context, pollTimeout time.Duration, draining bool) (*fleetapi.CheckinResponse, error) {
+	// get events, when the batch is full the remaining events are carried over to the next checkin.
+	ee, ack := f.reporter.EventsBatch(f.settings.MaxEvents)
+	if f.settings.MaxEvents > 0 && len(ee) == f.settings.MaxEvents {
+		f.log.Debugf("FleetGateway sending a full batch of %d events, remaining events are sent on the next checkin", len(ee))
+	}
+	f.chunked = false
+
+	// the metadata is omitted when fleet-server already knows about it.
+	ecsMeta := f.metadata.changed()
 
 	// retrieve ack token from the store
 	ackToken := f.stateStore.AckToken()
@@ -235,13 +575,50 @@ func (f *fleetGateway) execute(ctx context.Context) (*fleetapi.CheckinResponse,
 	}
 
 	// checkin
-	cmd := fleetapi.NewCheckinCmd(f.agentInfo, f.client)
+	var sender client.Sender = f.client
+	if f.settings.Compression {
+		sender = client.NewGzipSender(sender)
+	}
+	cmd := fleetapi.NewCheckinCmd(f.agentInfo, sender)
+	// the message explains a degraded or error status with the unhealthy applications and components,
+	// a failure to dispatch the actions of the previous checkin is reported by the gateway component.
+	agentStatus := f.statusController.Status()
 	req := &fleetapi.CheckinRequest{
 		AckToken: ackToken,
-		Events:   ee,
+		Events:   f.prepareEvents(ee),
 		Metadata: ecsMeta,
-		Status:   f.statusController.StatusString(),
+		Status:   agentStatus.Status.String(),
+		Message:  agentStatus.Message,
+		Tags:     f.agentInfo.Tags(),
+
+		EventSchemaVersion: f.eventSchema,
 	}
+	if p, ok := f.dispatcher.(appliedPolicy); ok {
+		if policyID, revision, ok := p.AppliedRevision(); ok {
+			req.PolicyID = policyID
+			req.PolicyRevision = revision
+		}
+	}
+	if pollTimeout > 0 {
+		req.PollTimeout = pollTimeout.String()
+	}
+
+	chunked := false
+	if n := f.eventsFitting(req, 0); n < len(req.Events) {
+		// the batch is taken again so only the events of the chunk are acked.
+		f.log.Debugf("FleetGateway sending %d of %d events to stay under the checkin payload size limit of %d bytes", n, len(req.Events), f.settings.MaxPayloadSize)
+		ee, ack = f.reporter.EventsBatch(n)
+		req.Events = f.prepareEvents(ee)
+		if extra := len(req.Events) - n; extra > 0 {
+			// the reporter appended the event telling fleet about the dropped events, it is sent
+			// with the chunk and must fit with it.
+			n = f.eventsFitting(req, extra)
+			ee, ack = f.reporter.EventsBatch(n)
+			req.Events = f.prepareEvents(ee)
+		}
+		chunked = true
+	}
+	ee = req.Events
 
 	resp, err := cmd.Execute(ctx, req)
 	if isUnauth(err) {
@@ -263,7 +640,7 @@ func (f *fleetGateway) execute(ctx context.Context) (*fleetapi.CheckinResponse,
 	}
 
 	// Save the latest ackToken
-	if resp.AckToken != "" {
+	if resp.AckToken != "" && !draining {
 		f.stateStore.SetAckToken(resp.AckToken)
 		serr := f.stateStore.Save()
 		if serr != nil {
@@ -271,11 +648,92 @@ func (f *fleetGateway) execute(ctx context.Context) (*fleetapi.CheckinResponse,
 		}
 	}
 
-	// ack events so they are dropped from queue
-	ack()
+	f.negotiateEventSchema(resp.EventSchemaVersion)
+
+	// ack events so they are dropped from queue, the events rejected by fleet-server are kept and
+	// sent again with the next checkin.
+	ack(f.rejectedEvents(resp, len(ee))...)
+	f.metadata.markReported(ecsMeta)
+	f.chunked = chunked
 	return resp, nil
 }
 
+// prepareEvents adapts the events of the reporter to the clock and to the event schema of
+// fleet-server.
+func (f *fleetGateway) prepareEvents(ee []fleetapi.SerializableEvent) []fleetapi.SerializableEvent {
+	ee = f.clockSkew.adjust(ee)
+	return fleetapi.EventsWithSchema(ee, f.eventSchema)
+}
+
+// eventsFitting returns the number of the first events of the request which fit under the payload
+// size limit, at least one event is sent so an event larger than the limit does not block the
+// following ones. The last trailing events of the request are always sent, they are not counted
+// but their size is.
+func (f *fleetGateway) eventsFitting(req *fleetapi.CheckinRequest, trailing int) int {
+	events := req.Events[:len(req.Events)-trailing]
+	if f.settings.MaxPayloadSize <= 0 || len(events) <= 1 {
+		return len(events)
+	}
+
+	base := *req
+	base.Events = req.Events[len(events):]
+	data, err := json.Marshal(&base)
+	if err != nil {
+		return len(events)
+	}
+
+	size := len(data)
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return len(events)
+		}
+		// the events are separated by a comma.
+		size += len(data)
+		if i > 0 || trailing > 0 {
+			size++
+		}
+		if size > f.settings.MaxPayloadSize {
+			if i == 0 {
+				f.log.Warnf("FleetGateway event of %d bytes is over the checkin payload size limit of %d bytes, it is sent alone", len(data), f.settings.MaxPayloadSize)
+				return 1
+			}
+			return i
+		}
+	}
+	return len(events)
+}
+
+// negotiateEventSchema downgrades the events of the next checkins to the version of the schema
+// supported by fleet-server.
+func (f *fleetGateway) negotiateEventSchema(hint int) {
+	version := fleetapi.NegotiateEventSchema(f.eventSchema, hint)
+	if version == f.eventSchema {
+		return
+	}
+
+	f.log.Infof("fleet-server supports the version %d of the event schema, events are sent with the version %d instead of %d", hint, version, f.eventSchema)
+	f.eventSchema = version
+}
+
+// rejectedEvents returns the indexes of the events of the checkin rejected by fleet-server.
+func (f *fleetGateway) rejectedEvents(resp *fleetapi.CheckinResponse, sent int) []int {
+	if len(resp.RejectedEvents) == 0 {
+		return nil
+	}
+
+	rejected := make([]int, 0, len(resp.RejectedEvents))
+	for _, e := range resp.RejectedEvents {
+		if e.Index < 0 || e.Index >= sent {
+			f.log.Warnf("fleet-server rejected an unknown event at index %d", e.Index)
+			continue
+		}
+		f.log.Warnf("fleet-server rejected the event at index %d, it is sent again with the next checkin: %s", e.Index, e.Reason)
+		rejected = append(rejected, e.Index)
+	}
+	return rejected
+}
+
 // shouldUnenroll checks if the max number of trying an invalid key is reached
 func (f *fleetGateway) shouldUnenroll() bool {
 	return f.unauthCounter > maxUnauthCounter
@@ -286,6 +744,14 @@ func isUnauth(err error) bool {
 }
 
 func (f *fleetGateway) Start() error {
+	f.restoreState()
+
+	f.wg.Add(1)
+	go func(wg *sync.WaitGroup) {
+		defer wg.Done()
+		f.metadata.run(f.bgContext)
+	}(&f.wg)
+
 	f.wg.Add(1)
 	go func(wg *sync.WaitGroup) {
 		defer f.log.Info("Fleet gateway is stopped")
@@ -296,12 +762,141 @@ func (f *fleetGateway) Start() error {
 	return nil
 }
 
+// Stop stops the gateway, the in-flight checkin and any retry in progress are cancelled and Stop
+// waits for the worker to exit.
+func (f *fleetGateway) Stop() error {
+	f.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		f.drain()
+		return nil
+	case <-time.After(stopTimeout):
+		return errors.New(
+			fmt.Sprintf("fleet gateway did not stop after %s", stopTimeout),
+			errors.TypeUnexpected,
+		)
+	}
+}
+
+// drain sends the pending events with a last checkin bounded by the drain timeout, so agents
+// running for a short time do not lose their last events. The actions received are not
+// dispatched, a paused gateway does not drain.
+func (f *fleetGateway) drain() {
+	if f.drainTimeout <= 0 {
+		return
+	}
+	p, ok := f.reporter.(pendingReporter)
+	if !ok {
+		return
+	}
+	pending := p.Pending()
+	if pending == 0 {
+		return
+	}
+	f.pauseMx.Lock()
+	paused := f.resumed != nil
+	f.pauseMx.Unlock()
+	if paused {
+		return
+	}
+
+	f.log.Infof("Fleet gateway is sending %d pending events before stopping", pending)
+	ctx, cancel := context.WithTimeout(context.Background(), f.drainTimeout)
+	defer cancel()
+	// events over the batch size or the payload size limit are sent with additional checkins.
+	for pending > 0 && ctx.Err() == nil {
+		if _, err := f.execute(ctx, f.drainTimeout/2, true); err != nil {
+			f.log.Warnf("Fleet gateway could not send the pending events before stopping, they are sent by the next run: %v", err)
+			return
+		}
+		left := p.Pending()
+		if left >= pending {
+			// the events left were rejected by fleet-server.
+			return
+		}
+		pending = left
+	}
+}
+
+// SetDrainTimeout sets the time given to the last checkin sending the pending events when the
+// gateway is stopped, zero leaves the pending events to the next run.
+func (f *fleetGateway) SetDrainTimeout(d time.Duration) {
+	f.drainTimeout = d
+}
+
+// stop is called by the worker when the context is cancelled.
 func (f *fleetGateway) stop() {
 	f.log.Info("Fleet gateway is stopping")
 	defer f.scheduler.Stop()
 	f.statusReporter.Unregister()
-	close(f.done)
-	f.wg.Wait()
+	f.probe.Close()
+}
+
+// ForceCheckin triggers the scheduler so the next checkin starts immediately, when a checkin is
+// in progress the following one starts as soon as it completes.
+func (f *fleetGateway) ForceCheckin() {
+	f.log.Debug("FleetGateway forced checkin requested")
+	f.scheduler.Trigger()
+}
+
+// Pause suspends the checkins until Resume is called, the events reported meanwhile are kept by
+// the reporter and sent with the first checkin after the gateway is resumed.
+func (f *fleetGateway) Pause() {
+	f.pauseMx.Lock()
+	defer f.pauseMx.Unlock()
+
+	if f.resumed != nil {
+		return
+	}
+	f.log.Info("Fleet gateway is paused")
+	f.resumed = make(chan struct{})
+	f.metrics.paused.Set(true)
+}
+
+// Resume resumes the checkins, a checkin suspended by Pause starts immediately.
+func (f *fleetGateway) Resume() {
+	f.pauseMx.Lock()
+	defer f.pauseMx.Unlock()
+
+	if f.resumed == nil {
+		return
+	}
+	f.log.Info("Fleet gateway is resumed")
+	close(f.resumed)
+	f.resumed = nil
+	f.metrics.paused.Set(false)
+}
+
+// waitResumed blocks while the gateway is paused, it returns false when the gateway is stopped.
+func (f *fleetGateway) waitResumed() bool {
+	f.pauseMx.Lock()
+	resumed := f.resumed
+	f.pauseMx.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	f.log.Debug("FleetGateway checkin is waiting for the gateway to be resumed")
+	select {
+	case <-resumed:
+		return true
+	case <-f.bgContext.Done():
+		return false
+	}
+}
+
+// OnCheckin sets the function called after each successful checkin, it must be set before the
+// gateway is started.
+func (f *fleetGateway) OnCheckin(fn func()) {
+	f.onCheckin = fn
 }
 
 func (f *fleetGateway) SetClient(c client.Sender) {
@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -22,9 +23,11 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/gateway"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	noopacker "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/noop"
 	repo "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
@@ -336,6 +339,512 @@ func TestFleetGateway(t *testing.T) {
 		waitFn()
 	}))
 
+	t.Run("Events over the batch size are carried over to the next checkin", withGateway(agentInfo, &fleetGatewaySettings{
+		Duration:  5 * time.Second,
+		Backoff:   backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
+		MaxEvents: 2,
+	}, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		for i := 0; i < 3; i++ {
+			rep.Report(context.Background(), &testStateEvent{})
+		}
+
+		checkin := func(expected int) func() {
+			return ackSeq(
+				client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+					cr := &request{}
+					content, err := ioutil.ReadAll(body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					err = json.Unmarshal(content, &cr)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					require.Equal(t, expected, len(cr.Events))
+
+					resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
+					return resp, nil
+				}),
+				dispatcher.Answer(func(actions ...fleetapi.Action) error {
+					return nil
+				}),
+			)
+		}
+
+		gateway.Start()
+
+		waitFn := checkin(2)
+		scheduler.Next()
+		waitFn()
+
+		waitFn = checkin(1)
+		scheduler.Next()
+		waitFn()
+	}))
+
+	t.Run("Events over the payload size are sent with additional checkins of the same tick", withGateway(agentInfo, &fleetGatewaySettings{
+		Duration:       5 * time.Second,
+		Backoff:        backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
+		MaxPayloadSize: 25000,
+	}, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		// two events fit in a checkin, not three.
+		for i := 0; i < 5; i++ {
+			rep.Report(context.Background(), &testLargeEvent{message: strings.Repeat("a", 10000)})
+		}
+
+		var sizes []int
+		var pollTimeouts []string
+		receivedFn := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+			content, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cr := &chunkRequest{}
+			if err := json.Unmarshal(content, &cr); err != nil {
+				t.Fatal(err)
+			}
+			sizes = append(sizes, len(cr.Events))
+			pollTimeouts = append(pollTimeouts, cr.PollTimeout)
+			require.LessOrEqual(t, len(content), 25000)
+
+			resp := wrapStrToResp(http.StatusOK, fmt.Sprintf(`{ "actions": [{ "type": "POLICY_CHANGE", "id": "id%d", "data": { "policy": {} } }] }`, len(sizes)))
+			return resp, nil
+		})
+		var dispatched []string
+		dispatchedFn := dispatcher.Answer(func(actions ...fleetapi.Action) error {
+			// the actions of a checkin are dispatched before the next checkin of the tick is sent.
+			require.Equal(t, 1, len(actions))
+			require.Equal(t, len(sizes), len(dispatched)+1)
+			dispatched = append(dispatched, actions[0].ID())
+			return nil
+		})
+
+		gateway.Start()
+		scheduler.Next()
+		for i := 0; i < 3; i++ {
+			<-receivedFn
+			<-dispatchedFn
+		}
+
+		require.Equal(t, []int{2, 2, 1}, sizes)
+		require.Equal(t, []string{"id1", "id2", "id3"}, dispatched)
+		// the additional checkins are not held by fleet-server.
+		chunkTimeout := chunkPollTimeout.String()
+		require.Equal(t, []string{"", chunkTimeout, chunkTimeout}, pollTimeouts)
+	}))
+
+	t.Run("A failed additional checkin is not retried", withGateway(agentInfo, &fleetGatewaySettings{
+		Duration:       5 * time.Second,
+		Backoff:        backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
+		MaxPayloadSize: 25000,
+	}, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		for i := 0; i < 5; i++ {
+			rep.Report(context.Background(), &testLargeEvent{message: strings.Repeat("a", 10000)})
+		}
+
+		// the additional checkin of the first tick fails, the remaining events are left to the
+		// next tick.
+		checkins := []struct {
+			events      int
+			pollTimeout string
+			code        int
+		}{
+			{2, "", http.StatusOK},
+			{2, chunkPollTimeout.String(), http.StatusInternalServerError},
+			{2, "", http.StatusOK},
+			{1, chunkPollTimeout.String(), http.StatusOK},
+		}
+		var calls int
+		receivedFn := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+			content, err := ioutil.ReadAll(body)
+			require.NoError(t, err)
+			cr := &chunkRequest{}
+			require.NoError(t, json.Unmarshal(content, &cr))
+
+			require.Less(t, calls, len(checkins))
+			expected := checkins[calls]
+			calls++
+			require.Equal(t, expected.events, len(cr.Events))
+			require.Equal(t, expected.pollTimeout, cr.PollTimeout)
+			return wrapStrToResp(expected.code, `{ "actions": [] }`), nil
+		})
+		dispatchedFn := dispatcher.Answer(func(actions ...fleetapi.Action) error {
+			return nil
+		})
+
+		gateway.Start()
+		for tick := 0; tick < 2; tick++ {
+			scheduler.Next()
+			ackSeq(receivedFn, dispatchedFn, receivedFn)()
+		}
+	}))
+
+	t.Run("Events rejected by fleet-server are sent again with the next checkin", withGateway(agentInfo, settings, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		for i := 0; i < 3; i++ {
+			rep.Report(context.Background(), &testStateEvent{})
+		}
+
+		checkin := func(expected int, response string) func() {
+			return ackSeq(
+				client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+					cr := &request{}
+					content, err := ioutil.ReadAll(body)
+					require.NoError(t, err)
+					require.NoError(t, json.Unmarshal(content, &cr))
+
+					require.Equal(t, expected, len(cr.Events))
+					return wrapStrToResp(http.StatusOK, response), nil
+				}),
+				dispatcher.Answer(func(actions ...fleetapi.Action) error {
+					return nil
+				}),
+			)
+		}
+
+		gateway.Start()
+
+		waitFn := checkin(3, `{ "actions": [], "rejected_events": [{ "index": 1, "reason": "invalid payload" }] }`)
+		scheduler.Next()
+		waitFn()
+
+		waitFn = checkin(1, `{ "actions": [] }`)
+		scheduler.Next()
+		waitFn()
+
+		waitFn = checkin(0, `{ "actions": [] }`)
+		scheduler.Next()
+		waitFn()
+	}))
+
+	t.Run("Events are downgraded to the schema supported by fleet-server", withGateway(agentInfo, settings, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		checkin := func(expectedSchema int, withSequence bool, response string) func() {
+			return ackSeq(
+				client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+					cr := &struct {
+						EventSchemaVersion int                      `json:"event_schema_version"`
+						Events             []map[string]interface{} `json:"events"`
+					}{}
+					content, err := ioutil.ReadAll(body)
+					require.NoError(t, err)
+					require.NoError(t, json.Unmarshal(content, cr))
+
+					require.Equal(t, expectedSchema, cr.EventSchemaVersion)
+					require.Len(t, cr.Events, 1)
+					_, ok := cr.Events[0]["sequence"]
+					require.Equal(t, withSequence, ok)
+					return wrapStrToResp(http.StatusOK, response), nil
+				}),
+				dispatcher.Answer(func(actions ...fleetapi.Action) error {
+					return nil
+				}),
+			)
+		}
+
+		gateway.Start()
+
+		rep.Report(context.Background(), &testStateEvent{})
+		waitFn := checkin(fleetapi.EventSchemaVersion, true, `{ "actions": [], "event_schema_version": 1 }`)
+		scheduler.Next()
+		waitFn()
+
+		rep.Report(context.Background(), &testStateEvent{})
+		waitFn = checkin(fleetapi.EventSchemaV1, false, `{ "actions": [] }`)
+		scheduler.Next()
+		waitFn()
+	}))
+
+	t.Run("Checkin frequency is changed by fleet-server", func(t *testing.T) {
+		scheduler := &durationRecorderScheduler{Stepper: scheduler.NewStepper(), durations: make(chan time.Duration, 1)}
+		client := newTestingClient()
+		dispatcher := newTestingDispatcher()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			settings,
+			agentInfo,
+			client,
+			dispatcher,
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+
+		waitFn := ackSeq(
+			client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+				resp := wrapStrToResp(http.StatusOK, `{ "actions": [], "checkin_frequency_sec": 30 }`)
+				return resp, nil
+			}),
+			dispatcher.Answer(func(actions ...fleetapi.Action) error {
+				return nil
+			}),
+		)
+		gateway.Start()
+
+		scheduler.Next()
+		waitFn()
+
+		require.Equal(t, 30*time.Second, <-scheduler.durations)
+	})
+
+	t.Run("Successful checkins are notified", func(t *testing.T) {
+		scheduler := scheduler.NewStepper()
+		client := newTestingClient()
+		dispatcher := newTestingDispatcher()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			settings,
+			agentInfo,
+			client,
+			dispatcher,
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+
+		checkins := make(chan struct{}, 1)
+		gateway.(*fleetGateway).OnCheckin(func() { checkins <- struct{}{} })
+
+		waitFn := ackSeq(
+			client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+				return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+			}),
+			dispatcher.Answer(func(actions ...fleetapi.Action) error {
+				return nil
+			}),
+		)
+		gateway.Start()
+
+		scheduler.Next()
+		waitFn()
+
+		select {
+		case <-checkins:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "successful checkin not notified")
+		}
+	})
+
+	t.Run("Checkin frequency of the agent settings takes precedence", func(t *testing.T) {
+		scheduler := &durationRecorderScheduler{Stepper: scheduler.NewStepper(), durations: make(chan time.Duration, 1)}
+		client := newTestingClient()
+		dispatcher := newTestingDispatcher()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			settings,
+			agentInfo,
+			client,
+			dispatcher,
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+
+		gateway.(*fleetGateway).SetCheckinFrequency(2*time.Minute, 0)
+		require.Equal(t, 2*time.Minute, <-scheduler.durations)
+
+		waitFn := ackSeq(
+			client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+				resp := wrapStrToResp(http.StatusOK, `{ "actions": [], "checkin_frequency_sec": 30 }`)
+				return resp, nil
+			}),
+			dispatcher.Answer(func(actions ...fleetapi.Action) error {
+				return nil
+			}),
+		)
+		gateway.Start()
+
+		scheduler.Next()
+		waitFn()
+
+		select {
+		case d := <-scheduler.durations:
+			require.Failf(t, "frequency suggested by fleet-server applied", "duration: %s", d)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("Outcome of the checkins is reported to the scheduler", func(t *testing.T) {
+		scheduler := &outcomeRecorderScheduler{Stepper: scheduler.NewStepper(), outcomes: make(chan string, 1)}
+		client := newTestingClient()
+		dispatcher := newTestingDispatcher()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			&fleetGatewaySettings{
+				Duration: 5 * time.Second,
+				Backoff:  backoffSettings{Init: 10 * time.Millisecond, Max: 20 * time.Millisecond, MaxRetries: 1},
+			},
+			agentInfo,
+			client,
+			dispatcher,
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+
+		waitFn := ackSeq(
+			client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+				return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+			}),
+			dispatcher.Answer(func(actions ...fleetapi.Action) error {
+				return nil
+			}),
+		)
+		gateway.Start()
+
+		scheduler.Next()
+		waitFn()
+		require.Equal(t, "success", <-scheduler.outcomes)
+
+		clientWaitFn := client.Answer(func(_ http.Header, _ io.Reader) (*http.Response, error) {
+			return wrapStrToResp(http.StatusInternalServerError, "something is bad"), nil
+		})
+		scheduler.Next()
+		<-clientWaitFn
+		<-clientWaitFn
+		require.Equal(t, "failure", <-scheduler.outcomes)
+	})
+
+	t.Run("Forced checkin does not wait for the next tick", func(t *testing.T) {
+		scheduler := scheduler.NewPeriodicJitter(20*time.Minute, 1*time.Millisecond)
+		client := newTestingClient()
+		dispatcher := newTestingDispatcher()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			settings,
+			agentInfo,
+			client,
+			dispatcher,
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+
+		checkin := func() func() {
+			return ackSeq(
+				client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+					return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+				}),
+				dispatcher.Answer(func(actions ...fleetapi.Action) error {
+					return nil
+				}),
+			)
+		}
+
+		// first checkin only waits for the jitter.
+		waitFn := checkin()
+		gateway.Start()
+		waitFn()
+
+		waitFn = checkin()
+		gateway.ForceCheckin()
+		waitFn()
+	})
+
 	t.Run("Test the wait loop is interruptible", func(t *testing.T) {
 		// 20mins is the double of the base timeout values for golang test suites.
 		// If we cannot interrupt we will timeout.
@@ -344,155 +853,494 @@ func TestFleetGateway(t *testing.T) {
 		client := newTestingClient()
 		dispatcher := newTestingDispatcher()
 
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(context.Background())
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			&fleetGatewaySettings{
+				Duration: d,
+				Backoff:  backoffSettings{Init: 1 * time.Second, Max: 30 * time.Second},
+			},
+			agentInfo,
+			client,
+			dispatcher,
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+
+		require.NoError(t, err)
+
+		ch1 := dispatcher.Answer(func(actions ...fleetapi.Action) error { return nil })
+		ch2 := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+			resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
+			return resp, nil
+		})
+
+		gateway.Start()
+
+		// Silently dispatch action.
+		go func() {
+			for range ch1 {
+			}
+		}()
+
+		// Make sure that all API calls to the checkin API are successfull, the following will happen:
+
+		// block on the first call.
+		<-ch2
+
+		go func() {
+			// drain the channel
+			for range ch2 {
+			}
+		}()
+
+		// 1. Gateway will check the API on boot.
+		// 2. WaitTick() will block for 20 minutes.
+		// 3. Stop will should unblock the wait.
+		cancel()
+	})
+
+}
+
+func TestRetriesOnFailures(t *testing.T) {
+	agentInfo := &testAgentInfo{}
+	settings := &fleetGatewaySettings{
+		Duration: 5 * time.Second,
+		Backoff:  backoffSettings{Init: 100 * time.Millisecond, Max: 5 * time.Second},
+	}
+
+	t.Run("When the gateway fails to communicate with the checkin API we will retry",
+		withGateway(agentInfo, settings, func(
+			t *testing.T,
+			gateway gateway.FleetGateway,
+			client *testingClient,
+			dispatcher *testingDispatcher,
+			scheduler *scheduler.Stepper,
+			rep repo.Backend,
+		) {
+			fail := func(_ http.Header, _ io.Reader) (*http.Response, error) {
+				return wrapStrToResp(http.StatusInternalServerError, "something is bad"), nil
+			}
+			clientWaitFn := client.Answer(fail)
+			gateway.Start()
+
+			rep.Report(context.Background(), &testStateEvent{})
+
+			// Initial tick is done out of bound so we can block on channels.
+			scheduler.Next()
+
+			// Simulate a 500 errors for the next 3 calls.
+			<-clientWaitFn
+			<-clientWaitFn
+			<-clientWaitFn
+
+			// API recover
+			waitFn := ackSeq(
+				client.Answer(func(_ http.Header, body io.Reader) (*http.Response, error) {
+					cr := &request{}
+					content, err := ioutil.ReadAll(body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					err = json.Unmarshal(content, &cr)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					require.Equal(t, 1, len(cr.Events))
+
+					resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
+					return resp, nil
+				}),
+
+				dispatcher.Answer(func(actions ...fleetapi.Action) error {
+					require.Equal(t, 0, len(actions))
+					return nil
+				}),
+			)
+
+			waitFn()
+		}))
+
+	t.Run("Throttled checkins wait for the Retry-After of fleet-server",
+		withGateway(agentInfo, &fleetGatewaySettings{
+			Duration: 5 * time.Second,
+			Backoff:  backoffSettings{Init: 10 * time.Millisecond, Max: 20 * time.Millisecond, MaxRetries: 1},
+		}, func(
+			t *testing.T,
+			gateway gateway.FleetGateway,
+			client *testingClient,
+			dispatcher *testingDispatcher,
+			scheduler *scheduler.Stepper,
+			rep repo.Backend,
+		) {
+			throttled := client.Answer(func(_ http.Header, _ io.Reader) (*http.Response, error) {
+				resp := wrapStrToResp(http.StatusTooManyRequests, "")
+				resp.Header.Set("Retry-After", "1")
+				return resp, nil
+			})
+			gateway.Start()
+
+			scheduler.Next()
+			<-throttled
+			throttledAt := time.Now()
+
+			// the next checkin is only sent once the Retry-After elapsed, not after the backoff.
+			waitFn := ackSeq(
+				client.Answer(func(_ http.Header, _ io.Reader) (*http.Response, error) {
+					return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+				}),
+				dispatcher.Answer(func(actions ...fleetapi.Action) error {
+					return nil
+				}),
+			)
+			waitFn()
+			require.True(t, time.Since(throttledAt) >= 900*time.Millisecond)
+		}))
+
+	t.Run("The retry loop gives up when the retry budget is exhausted",
+		withGateway(agentInfo, &fleetGatewaySettings{
+			Duration: 5 * time.Second,
+			Backoff:  backoffSettings{Init: 10 * time.Millisecond, Max: 20 * time.Millisecond, MaxRetries: 2},
+		}, func(
+			t *testing.T,
+			gateway gateway.FleetGateway,
+			client *testingClient,
+			dispatcher *testingDispatcher,
+			scheduler *scheduler.Stepper,
+			rep repo.Backend,
+		) {
+			fail := func(_ http.Header, _ io.Reader) (*http.Response, error) {
+				return wrapStrToResp(http.StatusInternalServerError, "something is bad"), nil
+			}
+			clientWaitFn := client.Answer(fail)
+			gateway.Start()
+
+			scheduler.Next()
+
+			// Initial call and the 2 allowed retries.
+			<-clientWaitFn
+			<-clientWaitFn
+			<-clientWaitFn
+
+			// The worker is back waiting on the scheduler, the next tick restarts the retry loop.
+			scheduler.Next()
+			<-clientWaitFn
+		}))
+
+	t.Run("The retry loop is interruptible",
+		withGateway(agentInfo, &fleetGatewaySettings{
+			Duration: 0 * time.Second,
+			Backoff:  backoffSettings{Init: 10 * time.Minute, Max: 20 * time.Minute},
+		}, func(
+			t *testing.T,
+			gateway gateway.FleetGateway,
+			client *testingClient,
+			dispatcher *testingDispatcher,
+			scheduler *scheduler.Stepper,
+			rep repo.Backend,
+		) {
+			fail := func(_ http.Header, _ io.Reader) (*http.Response, error) {
+				return wrapStrToResp(http.StatusInternalServerError, "something is bad"), nil
+			}
+			waitChan := client.Answer(fail)
+			gateway.Start()
+
+			rep.Report(context.Background(), &testStateEvent{})
+
+			// Initial tick is done out of bound so we can block on channels.
+			scheduler.Next()
+
+			// Fail to enter retry loop, all other calls will fails and will force to wait on big initial
+			// delay.
+			<-waitChan
+
+			// non-obvious but withGateway on return will stop the gateway before returning and we should
+			// exit the retry loop. The init value of the backoff is set to exceed the test default timeout.
+		}))
+}
+
+func TestStop(t *testing.T) {
+	agentInfo := &testAgentInfo{}
+	settings := &fleetGatewaySettings{
+		Duration: 5 * time.Second,
+		Backoff:  backoffSettings{Init: 10 * time.Minute, Max: 20 * time.Minute},
+	}
+
+	t.Run("Stop cancels the in-flight checkin", func(t *testing.T) {
+		scheduler := scheduler.NewStepper()
+		client := &blockingClient{received: make(chan struct{}, 1)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		log, _ := logger.New("tst", false)
+
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
+
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			settings,
+			agentInfo,
+			client,
+			newTestingDispatcher(),
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+
+		gateway.Start()
+		scheduler.Next()
+
+		// The checkin is blocked until its context is cancelled.
+		<-client.received
+
+		require.NoError(t, gateway.Stop())
+	})
+
+	t.Run("Stop drains the pending events", withGateway(agentInfo, settings, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		g := gateway.(*fleetGateway)
+		g.SetDrainTimeout(time.Second)
+		ackToken := g.stateStore.AckToken()
+
+		gateway.Start()
+		rep.Report(context.Background(), &testStateEvent{})
+		rep.Report(context.Background(), &testStateEvent{})
+
+		client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+			cr := &struct {
+				request
+				PollTimeout string `json:"poll_timeout"`
+			}{}
+			content, err := ioutil.ReadAll(body)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(content, &cr))
+
+			require.Equal(t, 2, len(cr.Events))
+			require.Equal(t, "500ms", cr.PollTimeout)
+			return wrapStrToResp(http.StatusOK, `{ "actions": [], "ack_token": "drained" }`), nil
+		})
+		require.NoError(t, gateway.Stop())
+		<-client.received
+
+		require.Equal(t, 0, rep.(*fleetreporter.Reporter).Pending())
+		// the actions of the draining checkin are received again by the next run.
+		require.Equal(t, ackToken, g.stateStore.AckToken())
+	}))
+
+	t.Run("Drain is bounded by the drain timeout", func(t *testing.T) {
+		client := &blockingClient{received: make(chan struct{}, 1)}
 		log, _ := logger.New("tst", false)
 
 		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
 		stateStore, err := store.NewStateStore(log, diskStore)
 		require.NoError(t, err)
 
+		rep := getReporter(agentInfo, log, t)
 		gateway, err := newFleetGatewayWithScheduler(
-			ctx,
+			context.Background(),
 			log,
-			&fleetGatewaySettings{
-				Duration: d,
-				Backoff:  backoffSettings{Init: 1 * time.Second, Max: 30 * time.Second},
-			},
+			settings,
 			agentInfo,
 			client,
-			dispatcher,
-			scheduler,
-			getReporter(agentInfo, log, t),
+			newTestingDispatcher(),
+			scheduler.NewStepper(),
+			rep,
 			noopacker.NewAcker(),
 			&noopController{},
 			stateStore,
 		)
-
 		require.NoError(t, err)
-
-		ch1 := dispatcher.Answer(func(actions ...fleetapi.Action) error { return nil })
-		ch2 := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
-			resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
-			return resp, nil
-		})
+		gateway.(*fleetGateway).SetDrainTimeout(100 * time.Millisecond)
 
 		gateway.Start()
+		rep.Report(context.Background(), &testStateEvent{})
 
-		// Silently dispatch action.
-		go func() {
-			for range ch1 {
-			}
-		}()
-
-		// Make sure that all API calls to the checkin API are successfull, the following will happen:
-
-		// block on the first call.
-		<-ch2
-
-		go func() {
-			// drain the channel
-			for range ch2 {
-			}
-		}()
-
-		// 1. Gateway will check the API on boot.
-		// 2. WaitTick() will block for 20 minutes.
-		// 3. Stop will should unblock the wait.
-		cancel()
+		started := time.Now()
+		require.NoError(t, gateway.Stop())
+		<-client.received
+		require.Less(t, int64(time.Since(started)), int64(5*time.Second))
+		require.Equal(t, 1, rep.Pending())
 	})
-
 }
 
-func TestRetriesOnFailures(t *testing.T) {
+func TestPause(t *testing.T) {
 	agentInfo := &testAgentInfo{}
 	settings := &fleetGatewaySettings{
 		Duration: 5 * time.Second,
-		Backoff:  backoffSettings{Init: 100 * time.Millisecond, Max: 5 * time.Second},
+		Backoff:  backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
 	}
 
-	t.Run("When the gateway fails to communicate with the checkin API we will retry",
-		withGateway(agentInfo, settings, func(
-			t *testing.T,
-			gateway gateway.FleetGateway,
-			client *testingClient,
-			dispatcher *testingDispatcher,
-			scheduler *scheduler.Stepper,
-			rep repo.Backend,
-		) {
-			fail := func(_ http.Header, _ io.Reader) (*http.Response, error) {
-				return wrapStrToResp(http.StatusInternalServerError, "something is bad"), nil
-			}
-			clientWaitFn := client.Answer(fail)
-			gateway.Start()
+	t.Run("events are buffered and sent once resumed", withGateway(agentInfo, settings, func(
+		t *testing.T,
+		gateway gateway.FleetGateway,
+		client *testingClient,
+		dispatcher *testingDispatcher,
+		scheduler *scheduler.Stepper,
+		rep repo.Backend,
+	) {
+		waitFn := ackSeq(
+			client.Answer(func(_ http.Header, body io.Reader) (*http.Response, error) {
+				cr := &request{}
+				content, err := ioutil.ReadAll(body)
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(content, cr))
 
-			rep.Report(context.Background(), &testStateEvent{})
+				require.Equal(t, 2, len(cr.Events))
+				return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+			}),
+			dispatcher.Answer(func(actions ...fleetapi.Action) error {
+				return nil
+			}),
+		)
+		gateway.Start()
+		gateway.Pause()
 
-			// Initial tick is done out of bound so we can block on channels.
-			scheduler.Next()
+		rep.Report(context.Background(), &testStateEvent{})
+		rep.Report(context.Background(), &testStateEvent{})
 
-			// Simulate a 500 errors for the next 3 calls.
-			<-clientWaitFn
-			<-clientWaitFn
-			<-clientWaitFn
+		// The tick is received but the checkin waits for the gateway to be resumed.
+		scheduler.Next()
+		select {
+		case <-client.received:
+			t.Fatal("checkin sent while the gateway is paused")
+		case <-time.After(100 * time.Millisecond):
+		}
 
-			// API recover
-			waitFn := ackSeq(
-				client.Answer(func(_ http.Header, body io.Reader) (*http.Response, error) {
-					cr := &request{}
-					content, err := ioutil.ReadAll(body)
-					if err != nil {
-						t.Fatal(err)
-					}
-					err = json.Unmarshal(content, &cr)
-					if err != nil {
-						t.Fatal(err)
-					}
+		gateway.Resume()
+		waitFn()
+	}))
 
-					require.Equal(t, 1, len(cr.Events))
+	t.Run("a paused gateway can be stopped", func(t *testing.T) {
+		scheduler := scheduler.NewStepper()
+		client := &blockingClient{received: make(chan struct{}, 1)}
 
-					resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
-					return resp, nil
-				}),
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-				dispatcher.Answer(func(actions ...fleetapi.Action) error {
-					require.Equal(t, 0, len(actions))
-					return nil
-				}),
-			)
+		log, _ := logger.New("tst", false)
 
-			waitFn()
-		}))
+		diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+		stateStore, err := store.NewStateStore(log, diskStore)
+		require.NoError(t, err)
 
-	t.Run("The retry loop is interruptible",
-		withGateway(agentInfo, &fleetGatewaySettings{
-			Duration: 0 * time.Second,
-			Backoff:  backoffSettings{Init: 10 * time.Minute, Max: 20 * time.Minute},
-		}, func(
-			t *testing.T,
-			gateway gateway.FleetGateway,
-			client *testingClient,
-			dispatcher *testingDispatcher,
-			scheduler *scheduler.Stepper,
-			rep repo.Backend,
-		) {
-			fail := func(_ http.Header, _ io.Reader) (*http.Response, error) {
-				return wrapStrToResp(http.StatusInternalServerError, "something is bad"), nil
-			}
-			waitChan := client.Answer(fail)
-			gateway.Start()
+		gateway, err := newFleetGatewayWithScheduler(
+			ctx,
+			log,
+			settings,
+			agentInfo,
+			client,
+			newTestingDispatcher(),
+			scheduler,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
 
-			rep.Report(context.Background(), &testStateEvent{})
+		gateway.Start()
+		gateway.Pause()
+		scheduler.Next()
 
-			// Initial tick is done out of bound so we can block on channels.
-			scheduler.Next()
+		require.NoError(t, gateway.Stop())
+		select {
+		case <-client.received:
+			t.Fatal("checkin sent while the gateway is paused")
+		default:
+		}
+	})
+}
 
-			// Fail to enter retry loop, all other calls will fails and will force to wait on big initial
-			// delay.
-			<-waitChan
+func TestCheckinStatus(t *testing.T) {
+	agentInfo := &testAgentInfo{}
+	settings := &fleetGatewaySettings{
+		Duration: 5 * time.Second,
+		Backoff:  backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
+	}
 
-			// non-obvious but withGateway on return will stop the gateway before returning and we should
-			// exit the retry loop. The init value of the backoff is set to exceed the test default timeout.
-		}))
+	scheduler := scheduler.NewStepper()
+	client := newTestingClient()
+	dispatcher := newTestingDispatcher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log, _ := logger.New("tst", false)
+
+	diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+	stateStore, err := store.NewStateStore(log, diskStore)
+	require.NoError(t, err)
+
+	gateway, err := newFleetGatewayWithScheduler(
+		ctx,
+		log,
+		settings,
+		agentInfo,
+		client,
+		dispatcher,
+		scheduler,
+		getReporter(agentInfo, log, t),
+		noopacker.NewAcker(),
+		status.NewController(log),
+		stateStore,
+	)
+	require.NoError(t, err)
+
+	checkin := func(expectedStatus, expectedMessage string, dispatchErr error) {
+		waitFn := ackSeq(
+			client.Answer(func(_ http.Header, body io.Reader) (*http.Response, error) {
+				req := &fleetapi.CheckinRequest{}
+				content, err := ioutil.ReadAll(body)
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(content, req))
+
+				require.Equal(t, expectedStatus, req.Status)
+				require.Equal(t, expectedMessage, req.Message)
+				return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+			}),
+			dispatcher.Answer(func(actions ...fleetapi.Action) error {
+				return dispatchErr
+			}),
+		)
+		scheduler.Next()
+		waitFn()
+	}
+
+	gateway.Start()
+
+	// The gateway reports its status once the actions are dispatched, the failure is only visible
+	// in the following checkin.
+	checkin("online", "", errors.New("handler is broken"))
+	checkin("error", "gateway: failed to dispatch actions, error: handler is broken", nil)
+	checkin("online", "", nil)
+
+	// A handler which rolled back leaves the agent degraded instead of failed.
+	checkin("online", "", errors.Wrap(pipeline.ErrRolledBack, "policy rolled back"))
+	checkin("degraded", "gateway: failed to dispatch actions, error: policy rolled back: action was rolled back", nil)
 }
 
 func getReporter(info agentInfo, log *logger.Logger, t *testing.T) *fleetreporter.Reporter {
@@ -504,9 +1352,48 @@ func getReporter(info agentInfo, log *logger.Logger, t *testing.T) *fleetreporte
 	return fleetR
 }
 
+type blockingClient struct {
+	received chan struct{}
+}
+
+func (b *blockingClient) Send(
+	ctx context.Context,
+	_ string,
+	_ string,
+	_ url.Values,
+	_ http.Header,
+	_ io.Reader,
+) (*http.Response, error) {
+	b.received <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingClient) URI() string {
+	return "http://localhost"
+}
+
+type durationRecorderScheduler struct {
+	*scheduler.Stepper
+	durations chan time.Duration
+}
+
+func (s *durationRecorderScheduler) SetDuration(d time.Duration) {
+	s.durations <- d
+}
+
+type outcomeRecorderScheduler struct {
+	*scheduler.Stepper
+	outcomes chan string
+}
+
+func (s *outcomeRecorderScheduler) Success() { s.outcomes <- "success" }
+func (s *outcomeRecorderScheduler) Failure() { s.outcomes <- "failure" }
+
 type testAgentInfo struct{}
 
 func (testAgentInfo) AgentID() string { return "agent-secret" }
+func (testAgentInfo) Tags() []string  { return nil }
 
 type testStateEvent struct{}
 
@@ -516,6 +1403,190 @@ func (testStateEvent) Time() time.Time                 { return time.Unix(0, 1)
 func (testStateEvent) Message() string                 { return "hello" }
 func (testStateEvent) Payload() map[string]interface{} { return map[string]interface{}{"key": 1} }
 
+// testLargeEvent is a state event with a configurable message to control the size of the checkins.
+type testLargeEvent struct {
+	testStateEvent
+	message string
+}
+
+func (e testLargeEvent) Message() string { return e.message }
+
 type request struct {
 	Events []interface{} `json:"events"`
 }
+
+type chunkRequest struct {
+	Events      []interface{} `json:"events"`
+	PollTimeout string        `json:"poll_timeout"`
+}
+
+type revisionDispatcher struct {
+	*testingDispatcher
+}
+
+func (d *revisionDispatcher) AppliedRevision() (string, int64, bool) {
+	return "policy-1", 3, true
+}
+
+func TestCheckinPolicyRevision(t *testing.T) {
+	agentInfo := &testAgentInfo{}
+	settings := &fleetGatewaySettings{
+		Duration: 5 * time.Second,
+		Backoff:  backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
+	}
+
+	scheduler := scheduler.NewStepper()
+	client := newTestingClient()
+	dispatcher := newTestingDispatcher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log, _ := logger.New("tst", false)
+
+	diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+	stateStore, err := store.NewStateStore(log, diskStore)
+	require.NoError(t, err)
+
+	gateway, err := newFleetGatewayWithScheduler(
+		ctx,
+		log,
+		settings,
+		agentInfo,
+		client,
+		&revisionDispatcher{dispatcher},
+		scheduler,
+		getReporter(agentInfo, log, t),
+		noopacker.NewAcker(),
+		&noopController{},
+		stateStore,
+	)
+	require.NoError(t, err)
+
+	waitFn := ackSeq(
+		client.Answer(func(_ http.Header, body io.Reader) (*http.Response, error) {
+			req := &fleetapi.CheckinRequest{}
+			content, err := ioutil.ReadAll(body)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(content, req))
+
+			require.Equal(t, "policy-1", req.PolicyID)
+			require.Equal(t, int64(3), req.PolicyRevision)
+			return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+		}),
+		dispatcher.Answer(func(actions ...fleetapi.Action) error {
+			return nil
+		}),
+	)
+
+	gateway.Start()
+	scheduler.Next()
+	waitFn()
+	require.NoError(t, gateway.Stop())
+}
+
+// droppingReporter appends an event reporting the dropped events to every batch until a batch is
+// acked, like the fleet reporter.
+type droppingReporter struct {
+	sync.Mutex
+	events  []fleetapi.SerializableEvent
+	dropped int
+}
+
+func (r *droppingReporter) EventsBatch(size int) ([]fleetapi.SerializableEvent, func(rejected ...int)) {
+	r.Lock()
+	defer r.Unlock()
+
+	n := len(r.events)
+	if size > 0 && size < n {
+		n = size
+	}
+	batch := r.events[:n:n]
+	if r.dropped > 0 {
+		batch = append(batch, &testSizedEvent{message: strings.Repeat("d", 6000)})
+	}
+	return batch, func(...int) {
+		r.Lock()
+		defer r.Unlock()
+		r.events = r.events[n:]
+		r.dropped = 0
+	}
+}
+
+type testSizedEvent struct {
+	message string
+}
+
+func (e *testSizedEvent) Type() string         { return repo.EventTypeState }
+func (e *testSizedEvent) Timestamp() time.Time { return time.Unix(0, 1) }
+func (e *testSizedEvent) Message() string      { return e.message }
+
+func (e *testSizedEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"type": e.Type(), "message": e.message})
+}
+
+func TestCheckinPayloadSizeWithDroppedEvents(t *testing.T) {
+	agentInfo := &testAgentInfo{}
+	settings := &fleetGatewaySettings{
+		Duration:       5 * time.Second,
+		Backoff:        backoffSettings{Init: 1 * time.Second, Max: 5 * time.Second},
+		MaxPayloadSize: 25000,
+	}
+
+	scheduler := scheduler.NewStepper()
+	client := newTestingClient()
+	dispatcher := newTestingDispatcher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log, _ := logger.New("tst", false)
+
+	diskStore := storage.NewDiskStore(paths.AgentStateStoreFile())
+	stateStore, err := store.NewStateStore(log, diskStore)
+	require.NoError(t, err)
+
+	// two events fit in a checkin, but not with the event reporting the dropped events.
+	rep := &droppingReporter{dropped: 1}
+	for i := 0; i < 3; i++ {
+		rep.events = append(rep.events, &testSizedEvent{message: strings.Repeat("a", 10000)})
+	}
+
+	gateway, err := newFleetGatewayWithScheduler(
+		ctx,
+		log,
+		settings,
+		agentInfo,
+		client,
+		dispatcher,
+		scheduler,
+		rep,
+		noopacker.NewAcker(),
+		&noopController{},
+		stateStore,
+	)
+	require.NoError(t, err)
+
+	var sizes []int
+	receivedFn := client.Answer(func(_ http.Header, body io.Reader) (*http.Response, error) {
+		content, err := ioutil.ReadAll(body)
+		require.NoError(t, err)
+		cr := &request{}
+		require.NoError(t, json.Unmarshal(content, cr))
+
+		require.LessOrEqual(t, len(content), settings.MaxPayloadSize)
+		sizes = append(sizes, len(cr.Events))
+		return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+	})
+	dispatchedFn := dispatcher.Answer(func(actions ...fleetapi.Action) error {
+		return nil
+	})
+
+	gateway.Start()
+	scheduler.Next()
+	ackSeq(receivedFn, dispatchedFn, receivedFn)()
+	require.NoError(t, gateway.Stop())
+
+	// the first checkin carries one event and the dropped events, the second one the other events.
+	require.Equal(t, []int{2, 2}, sizes)
+}
@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+)
+
+// gatewayStateStore is implemented by state stores which persist the runtime state of the gateway
+// across restarts.
+type gatewayStateStore interface {
+	GatewayState() store.GatewayState
+	SetGatewayState(store.GatewayState)
+}
+
+// initialDelaySetter is implemented by schedulers which allow the time before the first tick to be
+// set.
+type initialDelaySetter interface {
+	SetInitialDelay(time.Duration)
+}
+
+// backoffState is implemented by backoffs which allow the duration of the next wait to be saved
+// and restored.
+type backoffState interface {
+	Duration() time.Duration
+	SetDuration(time.Duration)
+}
+
+// sequenceReporter is implemented by reporters which know the sequence of the last event
+// acknowledged by fleet.
+type sequenceReporter interface {
+	AckedSequence() uint64
+	RestoreSequence(acked uint64)
+}
+
+// restoreState restores the runtime state persisted by the previous run, the first checkin waits
+// for the time left until the checkin the previous run would have made so an agent restarting
+// does not stampede fleet-server. After a failed checkin the backoff goes on where it stopped.
+func (f *fleetGateway) restoreState() {
+	s, ok := f.stateStore.(gatewayStateStore)
+	if !ok {
+		return
+	}
+	st := s.GatewayState()
+	if st.LastCheckin.IsZero() {
+		return
+	}
+
+	if r, ok := f.reporter.(sequenceReporter); ok && st.AckedSequence > 0 {
+		r.RestoreSequence(st.AckedSequence)
+	}
+
+	next := f.frequency()
+	if st.LastCheckinFailed {
+		if b, ok := f.backoff.(backoffState); ok && st.Backoff > 0 {
+			b.SetDuration(st.Backoff)
+			// the equal jitter backoff waits at least half of its duration.
+			next = b.Duration() / 2
+		}
+	}
+
+	// the delay never exceeds the wait of the previous run when the clock moved backward.
+	delay := time.Until(st.LastCheckin.Add(next))
+	if delay > next {
+		delay = next
+	}
+	if delay <= 0 {
+		return
+	}
+
+	sd, ok := f.scheduler.(initialDelaySetter)
+	if !ok {
+		return
+	}
+	result := "succeeded"
+	if st.LastCheckinFailed {
+		result = "failed"
+	}
+	f.log.Infof("FleetGateway last checkin %s at %s, first checkin in %s", result, st.LastCheckin.Format(time.RFC3339), delay)
+	sd.SetInitialDelay(delay)
+}
+
+// recordState persists the outcome of a checkin attempt, the duration of the next backoff and the
+// sequence of the last event acknowledged by fleet.
+func (f *fleetGateway) recordState(checkinErr error) {
+	s, ok := f.stateStore.(gatewayStateStore)
+	if !ok {
+		return
+	}
+
+	st := store.GatewayState{
+		LastCheckin:       time.Now().UTC(),
+		LastCheckinFailed: checkinErr != nil,
+	}
+	if b, ok := f.backoff.(backoffState); ok && checkinErr != nil {
+		st.Backoff = b.Duration()
+	}
+	if r, ok := f.reporter.(sequenceReporter); ok {
+		st.AckedSequence = r.AckedSequence()
+	}
+
+	s.SetGatewayState(st)
+	if err := f.stateStore.Save(); err != nil {
+		f.log.Errorf("failed to save the gateway state, err: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/backoff"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	noopacker "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/noop"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/scheduler"
+)
+
+// delayedStepper is a stepper recording the initial delay set by the gateway.
+type delayedStepper struct {
+	*scheduler.Stepper
+	initial time.Duration
+}
+
+func (s *delayedStepper) SetInitialDelay(d time.Duration) {
+	s.initial = d
+}
+
+func TestGatewayState(t *testing.T) {
+	agentInfo := &testAgentInfo{}
+	settings := &fleetGatewaySettings{
+		Duration: 5 * time.Minute,
+		Backoff:  backoffSettings{Init: time.Minute, Max: 10 * time.Minute},
+	}
+	log, _ := logger.New("tst", false)
+
+	newGateway := func(t *testing.T, path string) (*fleetGateway, *delayedStepper, *store.StateStore) {
+		stateStore, err := store.NewStateStore(log, storage.NewDiskStore(path))
+		require.NoError(t, err)
+
+		sched := &delayedStepper{Stepper: scheduler.NewStepper()}
+		gateway, err := newFleetGatewayWithScheduler(
+			context.Background(),
+			log,
+			settings,
+			agentInfo,
+			newTestingClient(),
+			newTestingDispatcher(),
+			sched,
+			getReporter(agentInfo, log, t),
+			noopacker.NewAcker(),
+			&noopController{},
+			stateStore,
+		)
+		require.NoError(t, err)
+		return gateway.(*fleetGateway), sched, stateStore
+	}
+
+	t.Run("first checkin is not delayed without state", func(t *testing.T) {
+		g, sched, _ := newGateway(t, filepath.Join(t.TempDir(), "state.enc"))
+		g.restoreState()
+		require.Equal(t, time.Duration(0), sched.initial)
+	})
+
+	t.Run("first checkin waits for the frequency after a successful checkin", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.enc")
+		g, _, _ := newGateway(t, path)
+		g.recordState(nil)
+
+		restarted, sched, stateStore := newGateway(t, path)
+		require.False(t, stateStore.GatewayState().LastCheckinFailed)
+		restarted.restoreState()
+		require.True(t, sched.initial > 4*time.Minute && sched.initial <= 5*time.Minute, "unexpected delay %s", sched.initial)
+	})
+
+	t.Run("backoff goes on after a failed checkin", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.enc")
+		g, _, _ := newGateway(t, path)
+		g.backoff.(*backoff.EqualJitterBackoff).SetDuration(8 * time.Minute)
+		g.recordState(errors.New("fleet-server is unavailable"))
+
+		restarted, sched, stateStore := newGateway(t, path)
+		st := stateStore.GatewayState()
+		require.True(t, st.LastCheckinFailed)
+		require.Equal(t, 8*time.Minute, st.Backoff)
+
+		restarted.restoreState()
+		require.Equal(t, 8*time.Minute, restarted.backoff.(*backoff.EqualJitterBackoff).Duration())
+		require.True(t, sched.initial > 3*time.Minute && sched.initial <= 4*time.Minute, "unexpected delay %s", sched.initial)
+	})
+
+	t.Run("old checkin does not delay the first checkin", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.enc")
+		_, _, stateStore := newGateway(t, path)
+		stateStore.SetGatewayState(store.GatewayState{LastCheckin: time.Now().Add(-time.Hour), AckedSequence: 7})
+		require.NoError(t, stateStore.Save())
+
+		restarted, sched, _ := newGateway(t, path)
+		restarted.restoreState()
+		require.Equal(t, time.Duration(0), sched.initial)
+		require.Equal(t, uint64(7), restarted.reporter.(sequenceReporter).AckedSequence())
+	})
+}
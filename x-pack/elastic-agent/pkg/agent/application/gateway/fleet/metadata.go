@@ -0,0 +1,112 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/scheduler"
+)
+
+type metadataLoader func() (*info.ECSMeta, error)
+
+// metadataCollector keeps the local metadata of the host up to date and tells which metadata
+// fleet-server does not know about yet, fleet-server keeps the last metadata it received.
+type metadataCollector struct {
+	log       *logger.Logger
+	load      metadataLoader
+	scheduler scheduler.Scheduler // nil when the metadata is refreshed before every checkin.
+
+	mx           sync.Mutex
+	current      *info.ECSMeta
+	lastReported *info.ECSMeta
+}
+
+// metadataScheduler returns the scheduler refreshing the metadata, nil is returned when the
+// metadata is refreshed before every checkin.
+func metadataScheduler(refresh time.Duration) scheduler.Scheduler {
+	if refresh <= 0 {
+		return nil
+	}
+	return scheduler.NewPeriodic(refresh)
+}
+
+func newMetadataCollector(log *logger.Logger, s scheduler.Scheduler, load metadataLoader) *metadataCollector {
+	return &metadataCollector{
+		log:       log,
+		load:      load,
+		scheduler: s,
+	}
+}
+
+// run refreshes the metadata on every tick of the scheduler until the context is cancelled.
+func (c *metadataCollector) run(ctx context.Context) {
+	if c.scheduler == nil {
+		return
+	}
+	defer c.scheduler.Stop()
+
+	for {
+		select {
+		case <-c.scheduler.WaitTick(ctx):
+			c.refresh()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// changed returns the current metadata when it differs from the last metadata reported to
+// fleet-server, nil is returned when nothing changed.
+func (c *metadataCollector) changed() *info.ECSMeta {
+	c.mx.Lock()
+	loaded := c.current != nil
+	c.mx.Unlock()
+
+	if c.scheduler == nil || !loaded {
+		c.refresh()
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.current == nil || reflect.DeepEqual(c.current, c.lastReported) {
+		return nil
+	}
+	return c.current
+}
+
+// markReported records the metadata fleet-server received with a successful checkin.
+func (c *metadataCollector) markReported(meta *info.ECSMeta) {
+	if meta == nil {
+		return
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.lastReported = meta
+}
+
+func (c *metadataCollector) refresh() {
+	meta, err := c.load()
+	if err != nil {
+		// keep the previous metadata, the refresh is retried on the next tick.
+		c.log.Error(errors.New("failed to load metadata", err))
+		return
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.current != nil && !reflect.DeepEqual(c.current, meta) {
+		c.log.Debug("FleetGateway local metadata changed, it is sent with the next checkin")
+	}
+	c.current = meta
+}
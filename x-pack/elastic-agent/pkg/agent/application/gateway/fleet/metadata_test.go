@@ -0,0 +1,107 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/scheduler"
+)
+
+type testMetadataLoader struct {
+	mx       sync.Mutex
+	hostname string
+	err      error
+	calls    int
+}
+
+func (l *testMetadataLoader) set(hostname string, err error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.hostname = hostname
+	l.err = err
+}
+
+func (l *testMetadataLoader) load() (*info.ECSMeta, error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &info.ECSMeta{Host: &info.HostECSMeta{Hostname: l.hostname}}, nil
+}
+
+func (l *testMetadataLoader) loaded() int {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.calls
+}
+
+func TestMetadataCollector(t *testing.T) {
+	log, _ := logger.New("metadata", false)
+
+	t.Run("metadata is sent again only when it changed", func(t *testing.T) {
+		loader := &testMetadataLoader{hostname: "host-a"}
+		c := newMetadataCollector(log, nil, loader.load)
+
+		meta := c.changed()
+		require.NotNil(t, meta)
+		assert.Equal(t, "host-a", meta.Host.Hostname)
+
+		// not reported yet, the metadata is sent with the next checkin.
+		require.NotNil(t, c.changed())
+
+		c.markReported(meta)
+		assert.Nil(t, c.changed())
+
+		loader.set("host-b", nil)
+		meta = c.changed()
+		require.NotNil(t, meta)
+		assert.Equal(t, "host-b", meta.Host.Hostname)
+	})
+
+	t.Run("previous metadata is kept when the refresh fails", func(t *testing.T) {
+		loader := &testMetadataLoader{hostname: "host-a"}
+		c := newMetadataCollector(log, nil, loader.load)
+		c.markReported(c.changed())
+
+		loader.set("", errors.New("sysinfo unavailable"))
+		assert.Nil(t, c.changed())
+	})
+
+	t.Run("metadata is refreshed on every tick", func(t *testing.T) {
+		loader := &testMetadataLoader{hostname: "host-a"}
+		stepper := scheduler.NewStepper()
+		c := newMetadataCollector(log, stepper, loader.load)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go c.run(ctx)
+
+		meta := c.changed()
+		require.NotNil(t, meta)
+		c.markReported(meta)
+		require.Equal(t, 1, loader.loaded())
+
+		// between ticks the metadata is not loaded again.
+		loader.set("host-b", nil)
+		assert.Nil(t, c.changed())
+		assert.Equal(t, 1, loader.loaded())
+
+		stepper.Next()
+		assert.Eventually(t, func() bool { return c.changed() != nil }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, "host-b", c.changed().Host.Hostname)
+	})
+}
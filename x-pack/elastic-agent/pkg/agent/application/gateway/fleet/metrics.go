@@ -0,0 +1,122 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/libbeat/monitoring/adapter"
+)
+
+// metricsRegistryName is the name of the registry holding the gateway metrics in the stats namespace.
+const metricsRegistryName = "fleet_gateway"
+
+type gatewayMetrics struct {
+	checkinsAttempted *monitoring.Uint // Number of checkin requests sent to fleet-server, retries included.
+	checkinsSucceeded *monitoring.Uint // Number of successful checkins.
+	checkinsFailed    *monitoring.Uint // Number of failed checkins.
+	checkinDuration   metrics.Sample   // Histogram of the checkin durations in nanoseconds, long poll included.
+	lastCheckin       *monitoring.Timestamp
+	lastError         *monitoring.String // error of the last checkin, empty when it succeeded.
+	lastSuccess       *monitoring.Timestamp
+	lastHost          *monitoring.String // fleet-server host which served the last successful checkin.
+	paused            *monitoring.Bool   // True while the checkins are paused.
+	throttled         *monitoring.Bool   // True while the checkins are suspended by a 429 response of fleet-server.
+	checkinsThrottled *monitoring.Uint   // Number of checkins throttled by fleet-server.
+	clockSkew         *monitoring.Int    // Milliseconds to add to the agent clock to get the fleet-server clock.
+
+	mx      sync.Mutex
+	actions *monitoring.Registry        // Number of actions received, keyed by action type.
+	byType  map[string]*monitoring.Uint // Counters already registered in actions.
+}
+
+// gatewayRegistry returns an empty registry for the gateway metrics under the stats namespace,
+// metrics left by a previously created gateway are discarded.
+func gatewayRegistry() *monitoring.Registry {
+	parent := monitoring.GetNamespace("stats").GetRegistry()
+	if parent.GetRegistry(metricsRegistryName) != nil {
+		parent.Remove(metricsRegistryName)
+	}
+	return parent.NewRegistry(metricsRegistryName)
+}
+
+func newGatewayMetrics(reg *monitoring.Registry) *gatewayMetrics {
+	m := &gatewayMetrics{
+		checkinsAttempted: monitoring.NewUint(reg, "checkins_attempted_total"),
+		checkinsSucceeded: monitoring.NewUint(reg, "checkins_succeeded_total"),
+		checkinsFailed:    monitoring.NewUint(reg, "checkins_failed_total"),
+		checkinDuration:   metrics.NewUniformSample(1024),
+		lastCheckin:       monitoring.NewTimestamp(reg, "last_checkin"),
+		lastError:         monitoring.NewString(reg, "last_checkin_error"),
+		lastSuccess:       monitoring.NewTimestamp(reg, "last_checkin_success"),
+		lastHost:          monitoring.NewString(reg, "last_checkin_host"),
+		paused:            monitoring.NewBool(reg, "paused"),
+		throttled:         monitoring.NewBool(reg, "throttled"),
+		checkinsThrottled: monitoring.NewUint(reg, "checkins_throttled_total"),
+		clockSkew:         monitoring.NewInt(reg, "clock_skew_ms"),
+		actions:           reg.NewRegistry("actions"),
+		byType:            make(map[string]*monitoring.Uint),
+	}
+	monitoring.NewFunc(reg, "seconds_since_last_checkin_success", m.reportSinceLastSuccess)
+	adapter.NewGoMetrics(reg, "checkin_duration", adapter.Accept).
+		Register("histogram", metrics.NewHistogram(m.checkinDuration))
+	return m
+}
+
+func (m *gatewayMetrics) checkinStarted() time.Time {
+	m.checkinsAttempted.Inc()
+	return time.Now()
+}
+
+func (m *gatewayMetrics) checkinFinished(started time.Time, err error) {
+	now := time.Now()
+	m.checkinDuration.Update(int64(now.Sub(started)))
+	m.lastCheckin.Set(now)
+	if err != nil {
+		m.checkinsFailed.Inc()
+		m.lastError.Set(err.Error())
+		return
+	}
+	m.checkinsSucceeded.Inc()
+	m.lastError.Set("")
+	m.lastSuccess.Set(now)
+}
+
+// checkinServedBy records the host which served the last successful checkin, it returns true when
+// the host changed.
+func (m *gatewayMetrics) checkinServedBy(host string) bool {
+	if host == "" || m.lastHost.Get() == host {
+		return false
+	}
+	m.lastHost.Set(host)
+	return true
+}
+
+func (m *gatewayMetrics) actionReceived(actionType string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	c, ok := m.byType[actionType]
+	if !ok {
+		c = monitoring.NewUint(m.actions, actionType)
+		m.byType[actionType] = c
+	}
+	c.Inc()
+}
+
+// reportSinceLastSuccess reports the number of seconds elapsed since the last successful checkin,
+// -1 is reported when the agent never successfully checked in since it started.
+func (m *gatewayMetrics) reportSinceLastSuccess(_ monitoring.Mode, vs monitoring.Visitor) {
+	last := m.lastSuccess.Get()
+	if last.IsZero() {
+		vs.OnInt(-1)
+		return
+	}
+	vs.OnInt(int64(time.Since(last) / time.Second))
+}
@@ -0,0 +1,52 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+)
+
+func TestGatewayMetrics(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	m := newGatewayMetrics(reg)
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(-1), snapshot.Ints["seconds_since_last_checkin_success"])
+	assert.Equal(t, "", snapshot.Strings["last_checkin_success"])
+
+	m.checkinFinished(m.checkinStarted(), errors.New("fleet-server unavailable"))
+	assert.Equal(t, "fleet-server unavailable", m.lastError.Get())
+	m.checkinFinished(m.checkinStarted(), nil)
+	assert.True(t, m.checkinServedBy("fleet-1:8220"))
+	assert.False(t, m.checkinServedBy("fleet-1:8220"))
+	m.actionReceived("POLICY_CHANGE")
+	m.actionReceived("POLICY_CHANGE")
+	m.actionReceived("UNENROLL")
+
+	snapshot = monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(2), snapshot.Ints["checkins_attempted_total"])
+	assert.Equal(t, int64(1), snapshot.Ints["checkins_succeeded_total"])
+	assert.Equal(t, int64(1), snapshot.Ints["checkins_failed_total"])
+	assert.Equal(t, int64(2), snapshot.Ints["checkin_duration.histogram.count"])
+	assert.Equal(t, int64(2), snapshot.Ints["actions.POLICY_CHANGE"])
+	assert.Equal(t, int64(1), snapshot.Ints["actions.UNENROLL"])
+	assert.Equal(t, int64(0), snapshot.Ints["seconds_since_last_checkin_success"])
+	assert.NotEmpty(t, snapshot.Strings["last_checkin_success"])
+	assert.NotEmpty(t, snapshot.Strings["last_checkin"])
+	assert.Equal(t, "", snapshot.Strings["last_checkin_error"])
+	assert.Equal(t, "fleet-1:8220", snapshot.Strings["last_checkin_host"])
+}
+
+func TestGatewayRegistryIsReplaced(t *testing.T) {
+	newGatewayMetrics(gatewayRegistry()).checkinStarted()
+	m := newGatewayMetrics(gatewayRegistry())
+
+	assert.Equal(t, uint64(0), m.checkinsAttempted.Get())
+}
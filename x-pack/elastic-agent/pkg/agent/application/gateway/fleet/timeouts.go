@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+type timeoutSettings struct {
+	// Connect is the maximum time to get a connection to fleet-server.
+	Connect time.Duration `config:"connect"`
+
+	// Request is the maximum time of the requests other than the checkin, like the acks.
+	Request time.Duration `config:"request"`
+
+	// LongPoll is the maximum time of a checkin, fleet-server holds the checkin until actions
+	// are available for the agent.
+	LongPoll time.Duration `config:"long_poll"`
+}
+
+// withTimeouts returns a context cancelled when no connection is obtained within the connect
+// timeout or when the request is not completed within the request timeout, zero disables a
+// timeout. The returned function releases the context and wraps the error of the request when a
+// timeout was reached.
+func withTimeouts(ctx context.Context, connect, request time.Duration) (context.Context, func(error) error) {
+	var cancel context.CancelFunc
+	if request > 0 {
+		ctx, cancel = context.WithTimeout(ctx, request)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	if connect <= 0 {
+		return ctx, func(err error) error {
+			cancel()
+			return wrapTimeout(ctx, err, request)
+		}
+	}
+
+	var connectExceeded int32
+	timer := time.AfterFunc(connect, func() {
+		atomic.StoreInt32(&connectExceeded, 1)
+		cancel()
+	})
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { timer.Stop() },
+	})
+
+	return ctx, func(err error) error {
+		timer.Stop()
+		cancel()
+		if err != nil && atomic.LoadInt32(&connectExceeded) == 1 {
+			return errors.New(err,
+				fmt.Sprintf("could not connect to fleet-server within %s", connect),
+				errors.TypeNetwork)
+		}
+		return wrapTimeout(ctx, err, request)
+	}
+}
+
+func wrapTimeout(ctx context.Context, err error, timeout time.Duration) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errors.New(err,
+			fmt.Sprintf("request to fleet-server did not complete within %s", timeout),
+			errors.TypeNetwork)
+	}
+	return err
+}
+
+// timeoutAcker applies the connect and request timeouts to the acks sent to fleet-server.
+type timeoutAcker struct {
+	acker    store.FleetAcker
+	timeouts timeoutSettings
+}
+
+func (a *timeoutAcker) Ack(ctx context.Context, action fleetapi.Action) error {
+	ctx, done := withTimeouts(ctx, a.timeouts.Connect, a.timeouts.Request)
+	return done(a.acker.Ack(ctx, action))
+}
+
+func (a *timeoutAcker) Commit(ctx context.Context) error {
+	ctx, done := withTimeouts(ctx, a.timeouts.Connect, a.timeouts.Request)
+	return done(a.acker.Commit(ctx))
+}
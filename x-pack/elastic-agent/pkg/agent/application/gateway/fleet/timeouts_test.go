@@ -0,0 +1,63 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTimeouts(t *testing.T) {
+	send := func(ctx context.Context, url string) error {
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	t.Run("connect timeout is reached when no connection is obtained", func(t *testing.T) {
+		// the TLS handshake never completes with a listener which never answers.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		ctx, done := withTimeouts(context.Background(), 50*time.Millisecond, time.Minute)
+		err = done(send(ctx, "https://"+l.Addr().String()))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "could not connect to fleet-server within 50ms")
+	})
+
+	t.Run("request timeout is reached after the connection is obtained", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer s.Close()
+
+		ctx, done := withTimeouts(context.Background(), 20*time.Millisecond, 100*time.Millisecond)
+		err := done(send(ctx, s.URL))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "request to fleet-server did not complete within 100ms")
+	})
+
+	t.Run("no timeout is reached", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer s.Close()
+
+		ctx, done := withTimeouts(context.Background(), time.Second, time.Second)
+		require.NoError(t, done(send(ctx, s.URL)))
+	})
+}
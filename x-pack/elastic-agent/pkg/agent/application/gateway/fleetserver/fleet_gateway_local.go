@@ -84,6 +84,26 @@ func (w *fleetServerWrapper) Start() error {
 	return w.wrapped.Start()
 }
 
+// Stop stops the wrapped gateway.
+func (w *fleetServerWrapper) Stop() error {
+	return w.wrapped.Stop()
+}
+
+// ForceCheckin forces a checkin of the wrapped gateway.
+func (w *fleetServerWrapper) ForceCheckin() {
+	w.wrapped.ForceCheckin()
+}
+
+// Pause pauses the checkins of the wrapped gateway.
+func (w *fleetServerWrapper) Pause() {
+	w.wrapped.Pause()
+}
+
+// Resume resumes the checkins of the wrapped gateway.
+func (w *fleetServerWrapper) Resume() {
+	w.wrapped.Resume()
+}
+
 // SetClient sets the client for the wrapped gateway.
 func (w *fleetServerWrapper) SetClient(c client.Sender) {
 	w.wrapped.SetClient(c)
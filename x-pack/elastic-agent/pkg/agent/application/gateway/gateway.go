@@ -14,6 +14,20 @@ type FleetGateway interface {
 	// Start starts the gateway.
 	Start() error
 
+	// Stop stops the gateway, in-flight requests to Fleet are cancelled.
+	Stop() error
+
 	// Set the client for the gateway.
 	SetClient(client.Sender)
+
+	// ForceCheckin checks in with Fleet as soon as possible instead of waiting for the next
+	// scheduled checkin.
+	ForceCheckin()
+
+	// Pause suspends the checkins until Resume is called, the events are buffered meanwhile. A
+	// checkin in progress is completed.
+	Pause()
+
+	// Resume resumes the checkins suspended by Pause.
+	Resume()
 }
@@ -10,6 +10,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/elastic/go-sysinfo"
 	"github.com/gofrs/uuid"
 	"gopkg.in/yaml.v2"
 
@@ -27,11 +28,25 @@ const agentInfoKey = "agent"
 const defaultLogLevel = "info"
 const maxRetriesloadAgentInfo = 5
 
+// currentHostID returns the unique ID of the host, empty when the host has no unique ID.
+var currentHostID = func() string {
+	h, err := sysinfo.Host()
+	if err != nil {
+		return ""
+	}
+	return h.Info().UniqueID
+}
+
 type persistentAgentInfo struct {
 	ID             string                                 `json:"id" yaml:"id" config:"id"`
+	HostID         string                                 `json:"host_id,omitempty" yaml:"host_id,omitempty" config:"host_id,omitempty"`
 	Headers        map[string]string                      `json:"headers" yaml:"headers" config:"headers"`
+	Tags           []string                               `json:"tags,omitempty" yaml:"tags,omitempty" config:"tags,omitempty"`
 	LogLevel       string                                 `json:"logging.level,omitempty" yaml:"logging.level,omitempty" config:"logging.level,omitempty"`
 	MonitoringHTTP *monitoringConfig.MonitoringHTTPConfig `json:"monitoring.http,omitempty" yaml:"monitoring.http,omitempty" config:"monitoring.http,omitempty"`
+
+	// managed is true when the agent is enrolled, its ID is then assigned by Fleet.
+	managed bool
 }
 
 type ioStore interface {
@@ -52,7 +67,7 @@ func updateLogLevel(level string) error {
 	}
 
 	agentConfigFile := paths.AgentConfigFile()
-	diskStore := storage.NewDiskStore(agentConfigFile)
+	diskStore := storage.NewFleetConfigStore(agentConfigFile)
 
 	ai.LogLevel = level
 	return updateAgentInfo(diskStore, ai)
@@ -90,11 +105,13 @@ func getInfoFromStore(s ioStore, logLevel string) (*persistentAgentInfo, error)
 			errors.TypeFilesystem)
 	}
 
+	managed := fleetEnabled(configMap)
 	agentInfoSubMap, found := configMap[agentInfoKey]
 	if !found {
 		return &persistentAgentInfo{
 			LogLevel:       logLevel,
 			MonitoringHTTP: monitoringConfig.DefaultConfig().HTTP,
+			managed:        managed,
 		}, nil
 	}
 
@@ -110,11 +127,28 @@ func getInfoFromStore(s ioStore, logLevel string) (*persistentAgentInfo, error)
 	if err := cc.Unpack(&pid); err != nil {
 		return nil, errors.New(err, "failed to unpack stored config to map")
 	}
+	pid.managed = managed
 
 	return pid, nil
 }
 
+// fleetEnabled returns true when the stored configuration is enrolled in Fleet.
+func fleetEnabled(configMap map[string]interface{}) bool {
+	fleet, ok := configMap["fleet"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	enabled, _ := fleet["enabled"].(bool)
+	return enabled
+}
+
 func updateAgentInfo(s ioStore, agentInfo *persistentAgentInfo) error {
+	return storeAgentInfo(s, agentInfo, true)
+}
+
+// storeAgentInfo persists the agent information, when keepID is true the ID already present in
+// the file is kept.
+func storeAgentInfo(s ioStore, agentInfo *persistentAgentInfo, keepID bool) error {
 	agentConfigFile := paths.AgentConfigFile()
 	reader, err := s.Load()
 	if err != nil {
@@ -135,7 +169,7 @@ func updateAgentInfo(s ioStore, agentInfo *persistentAgentInfo) error {
 	}
 
 	// best effort to keep the ID
-	if agentInfoSubMap, found := configMap[agentInfoKey]; found {
+	if agentInfoSubMap, found := configMap[agentInfoKey]; found && keepID {
 		if cc, err := config.NewConfigFrom(agentInfoSubMap); err == nil {
 			pid := &persistentAgentInfo{}
 			err := cc.Unpack(&pid)
@@ -192,7 +226,7 @@ func loadAgentInfo(forceUpdate bool, logLevel string, createAgentID bool) (*pers
 	defer idLock.Unlock()
 
 	agentConfigFile := paths.AgentConfigFile()
-	diskStore := storage.NewDiskStore(agentConfigFile)
+	diskStore := storage.NewFleetConfigStore(agentConfigFile)
 
 	agentinfo, err := getInfoFromStore(diskStore, logLevel)
 	if err != nil {
@@ -200,6 +234,9 @@ func loadAgentInfo(forceUpdate bool, logLevel string, createAgentID bool) (*pers
 	}
 
 	if agentinfo != nil && !forceUpdate && (agentinfo.ID != "" || !createAgentID) {
+		if createAgentID {
+			return agentinfo, checkHost(agentinfo, diskStore)
+		}
 		return agentinfo, nil
 	}
 
@@ -216,6 +253,7 @@ func updateID(agentInfo *persistentAgentInfo, s ioStore) error {
 	if err != nil {
 		return err
 	}
+	agentInfo.HostID = currentHostID()
 
 	if err := updateAgentInfo(s, agentInfo); err != nil {
 		return errors.New(err, "storing generated agent id", errors.TypeFilesystem)
@@ -223,3 +261,82 @@ func updateID(agentInfo *persistentAgentInfo, s ioStore) error {
 
 	return nil
 }
+
+// checkHost records the host on which the ID is used. The ID of a standalone agent is regenerated
+// when the agent runs on another host, like a VM cloned from the image of an existing agent, a
+// managed agent keeps the ID assigned by Fleet until it is enrolled again.
+func checkHost(agentInfo *persistentAgentInfo, s ioStore) error {
+	hostID := currentHostID()
+	if hostID == "" || agentInfo.HostID == hostID {
+		return nil
+	}
+
+	if agentInfo.HostID == "" {
+		// the ID was generated before the host was recorded or assigned by the enrollment.
+		agentInfo.HostID = hostID
+		return updateAgentInfo(s, agentInfo)
+	}
+
+	if agentInfo.managed {
+		return nil
+	}
+	return regenerateID(agentInfo, s)
+}
+
+func regenerateID(agentInfo *persistentAgentInfo, s ioStore) error {
+	id, err := generateAgentID()
+	if err != nil {
+		return err
+	}
+	agentInfo.ID = id
+	agentInfo.HostID = currentHostID()
+
+	if err := storeAgentInfo(s, agentInfo, false); err != nil {
+		return errors.New(err, "storing regenerated agent id", errors.TypeFilesystem)
+	}
+	return nil
+}
+
+// Identity is the identity persisted by the agent.
+type Identity struct {
+	// ID is the ID of the agent, empty when no ID was generated yet.
+	ID string
+	// Managed is true when the ID was assigned by Fleet.
+	Managed bool
+	// Cloned is true when the ID was used on another host.
+	Cloned bool
+}
+
+// LoadIdentity returns the identity persisted by the agent without generating an ID.
+func LoadIdentity() (*Identity, error) {
+	ai, err := loadAgentInfoWithBackoff(false, defaultLogLevel, false)
+	if err != nil {
+		return nil, err
+	}
+
+	hostID := currentHostID()
+	return &Identity{
+		ID:      ai.ID,
+		Managed: ai.managed,
+		Cloned:  ai.HostID != "" && hostID != "" && ai.HostID != hostID,
+	}, nil
+}
+
+// RegenerateID replaces the persisted ID of the agent with a new one and returns it.
+func RegenerateID() (string, error) {
+	idLock := paths.AgentConfigFileLock()
+	if err := idLock.TryLock(); err != nil {
+		return "", err
+	}
+	defer idLock.Unlock()
+
+	diskStore := storage.NewFleetConfigStore(paths.AgentConfigFile())
+	ai, err := getInfoFromStore(diskStore, defaultLogLevel)
+	if err != nil {
+		return "", err
+	}
+	if err := regenerateID(ai, diskStore); err != nil {
+		return "", err
+	}
+	return ai.ID, nil
+}
@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package info
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
+)
+
+func TestCheckHost(t *testing.T) {
+	defaultHostID := currentHostID
+	defer func() { currentHostID = defaultHostID }()
+	hostID := "host-1"
+	currentHostID = func() string { return hostID }
+
+	load := func(t *testing.T, content string) (*persistentAgentInfo, *storage.DiskStore) {
+		dir, err := ioutil.TempDir("", "agent_id")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		fleetFile := filepath.Join(dir, "fleet.yml")
+		require.NoError(t, ioutil.WriteFile(fleetFile, []byte(content), 0600))
+		s := storage.NewDiskStore(fleetFile)
+		ai, err := getInfoFromStore(s, defaultLogLevel)
+		require.NoError(t, err)
+		require.NoError(t, checkHost(ai, s))
+
+		stored, err := getInfoFromStore(s, defaultLogLevel)
+		require.NoError(t, err)
+		assert.Equal(t, ai.ID, stored.ID)
+		return stored, s
+	}
+
+	t.Run("host is recorded", func(t *testing.T) {
+		ai, _ := load(t, "agent:\n  id: abc\n")
+		assert.Equal(t, "abc", ai.ID)
+		assert.Equal(t, "host-1", ai.HostID)
+	})
+
+	t.Run("same host", func(t *testing.T) {
+		ai, _ := load(t, "agent:\n  id: abc\n  host_id: host-1\n")
+		assert.Equal(t, "abc", ai.ID)
+	})
+
+	t.Run("standalone agent on another host", func(t *testing.T) {
+		hostID = "host-2"
+		defer func() { hostID = "host-1" }()
+
+		ai, _ := load(t, "agent:\n  id: abc\n  host_id: host-1\n")
+		assert.NotEqual(t, "abc", ai.ID)
+		assert.NotEmpty(t, ai.ID)
+		assert.Equal(t, "host-2", ai.HostID)
+	})
+
+	t.Run("managed agent on another host", func(t *testing.T) {
+		hostID = "host-2"
+		defer func() { hostID = "host-1" }()
+
+		ai, _ := load(t, "fleet:\n  enabled: true\nagent:\n  id: abc\n  host_id: host-1\n")
+		assert.Equal(t, "abc", ai.ID)
+		assert.True(t, ai.managed)
+		assert.Equal(t, "host-1", ai.HostID)
+	})
+
+	t.Run("host without unique ID", func(t *testing.T) {
+		hostID = ""
+		defer func() { hostID = "host-1" }()
+
+		ai, _ := load(t, "agent:\n  id: abc\n  host_id: host-1\n")
+		assert.Equal(t, "abc", ai.ID)
+	})
+}
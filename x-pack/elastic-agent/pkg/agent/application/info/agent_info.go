@@ -14,6 +14,7 @@ type AgentInfo struct {
 	agentID  string
 	logLevel string
 	headers  map[string]string
+	tags     []string
 }
 
 // NewAgentInfoWithLog creates a new agent information.
@@ -32,6 +33,7 @@ func NewAgentInfoWithLog(level string, createAgentID bool) (*AgentInfo, error) {
 		agentID:  agentInfo.ID,
 		logLevel: agentInfo.LogLevel,
 		headers:  agentInfo.Headers,
+		tags:     agentInfo.Tags,
 	}, nil
 }
 
@@ -91,3 +93,8 @@ func (*AgentInfo) Snapshot() bool {
 func (i *AgentInfo) Headers() map[string]string {
 	return i.headers
 }
+
+// Tags returns the tags defined for the agent when it was enrolled.
+func (i *AgentInfo) Tags() []string {
+	return i.tags
+}
@@ -7,6 +7,7 @@ package application
 import (
 	"context"
 
+	"github.com/elastic/beats/v7/libbeat/keystore"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/filters"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
@@ -24,12 +25,13 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/stack"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/server"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/dir"
 	acker "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/noop"
 	reporting "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
-	logreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/log"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
 )
 
@@ -48,11 +50,16 @@ type Local struct {
 	source      source
 	agentInfo   *info.AgentInfo
 	srv         *server.Server
+	reporter    *reporting.Reporter
+	settings    *settingsWatcher
+	// stackMonitoring ships the metrics of the agent to the monitoring cluster.
+	stackMonitoring *stack.Reporter
 }
 
 type source interface {
 	Start() error
 	Stop() error
+	Reload() error
 }
 
 // newLocal return a agent managed by local configuration.
@@ -61,6 +68,7 @@ func newLocal(
 	log *logger.Logger,
 	pathConfigFile string,
 	rawConfig *config.Config,
+	agentKeystore keystore.Keystore,
 	reexec reexecManager,
 	statusCtrl status.Controller,
 	uc upgraderControl,
@@ -83,8 +91,6 @@ func newLocal(
 		}
 	}
 
-	logR := logreporter.NewReporter(log)
-
 	localApplication := &Local{
 		log:       log,
 		agentInfo: agentInfo,
@@ -96,12 +102,17 @@ func newLocal(
 		return nil, errors.New(err, "initialize GRPC listener")
 	}
 
-	reporter := reporting.NewReporter(localApplication.bgContext, log, localApplication.agentInfo, logR)
+	reporter, err := newReporter(localApplication.bgContext, log, localApplication.agentInfo, cfg.Settings.Reporting)
+	if err != nil {
+		return nil, errors.New(err, "fail to create reporters")
+	}
+	localApplication.reporter = reporter
 
 	monitor, err := monitoring.NewMonitor(cfg.Settings)
 	if err != nil {
 		return nil, errors.New(err, "failed to initialize monitoring")
 	}
+	localApplication.stackMonitoring = stack.NewReporter(log, agentInfo.AgentID(), cfg.Settings.MonitoringConfig)
 
 	router, err := router.New(log, stream.Factory(localApplication.bgContext, agentInfo, cfg.Settings, localApplication.srv, reporter, monitor, statusCtrl))
 	if err != nil {
@@ -114,6 +125,11 @@ func newLocal(
 		return nil, errors.New(err, "failed to initialize composable controller")
 	}
 
+	secretsResolver, err := secrets.NewResolver(cfg.Settings.SecretsConfig)
+	if err != nil {
+		return nil, errors.New(err, "failed to initialize the secrets providers")
+	}
+
 	discover := discoverer(pathConfigFile, cfg.Settings.Path)
 	emit, err := emitter.New(
 		localApplication.bgContext,
@@ -122,15 +138,18 @@ func newLocal(
 		composableCtrl,
 		router,
 		&pipeline.ConfigModifiers{
-			Decorators: []pipeline.DecoratorFunc{modifiers.InjectMonitoring},
-			Filters:    []pipeline.FilterFunc{filters.StreamChecker},
+			Decorators: []pipeline.DecoratorFunc{modifiers.InjectMonitoring, modifiers.InjectProgramCredentials(modifiers.NewAPIKeyIssuer(log))},
+			Filters:    []pipeline.FilterFunc{filters.StreamChecker, filters.KeystoreResolver(agentKeystore), filters.SecretsResolver(secretsResolver)},
 		},
 		caps,
 		monitor,
+		cfg.Settings.ProcessConfig,
+		localApplication.stackMonitoring,
 	)
 	if err != nil {
 		return nil, err
 	}
+	emit = notifyReady(emit)
 
 	var cfgSource source
 	if !cfg.Settings.Reload.Enabled {
@@ -138,7 +157,19 @@ func newLocal(
 		cfgSource = newOnce(log, discover, emit)
 	} else {
 		log.Debugf("Reloading of configuration is on, frequency is set to %s", cfg.Settings.Reload.Period)
-		cfgSource = newPeriodic(log, cfg.Settings.Reload.Period, discover, emit)
+		periodic := newPeriodic(log, cfg.Settings.Reload.Period, discover, emit)
+		if cfg.Settings.Reload.Watch {
+			if err := periodic.WatchChanges(pathConfigFile, cfg.Settings.Path); err != nil {
+				log.Warnf("Changes of the configuration are only detected every %s: %s", cfg.Settings.Reload.Period, err)
+			}
+		}
+		cfgSource = periodic
+
+		// the monitoring settings are applied by the reload of the configuration.
+		localApplication.settings, err = newSettingsWatcher(log, pathConfigFile, reexec, statusCtrl, "agent.monitoring")
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	localApplication.source = cfgSource
@@ -171,22 +202,40 @@ func (l *Local) Start() error {
 	if err := l.srv.Start(); err != nil {
 		return err
 	}
+	if err := l.stackMonitoring.Start(); err != nil {
+		l.log.Warnf("Metrics of the agent are not shipped to the monitoring cluster: %v", err)
+	}
 	if err := l.source.Start(); err != nil {
 		return err
 	}
+	if l.settings != nil {
+		if err := l.settings.Start(); err != nil {
+			l.log.Warnf("Changes of the local settings are not applied until the agent is restarted: %v", err)
+		}
+	}
 
 	return nil
 }
 
 // Stop stops a local agent.
 func (l *Local) Stop() error {
+	if l.settings != nil {
+		l.settings.Stop()
+	}
 	err := l.source.Stop()
 	l.cancelCtxFn()
 	l.router.Shutdown()
+	l.stackMonitoring.Stop()
 	l.srv.Stop()
+	l.reporter.Close()
 	return err
 }
 
+// Reload reads the local configuration again and applies it right away.
+func (l *Local) Reload() error {
+	return l.source.Reload()
+}
+
 // AgentInfo retrieves agent information.
 func (l *Local) AgentInfo() *info.AgentInfo {
 	return l.agentInfo
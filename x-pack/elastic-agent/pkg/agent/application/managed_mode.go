@@ -7,6 +7,8 @@ package application
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/elastic/go-sysinfo"
 
@@ -32,20 +34,28 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/capabilities"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/composable"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/backoff"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/stack"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/server"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/systemd"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/fleet"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/lazy"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/client"
 	reporting "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
 	fleetreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet"
-	logreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/log"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
 )
 
+const (
+	upgradeAckBackoffInit = 5 * time.Second
+	upgradeAckBackoffMax  = 1 * time.Minute
+)
+
 type stateStore interface {
 	Add(fleetapi.Action)
 	AckToken() string
@@ -67,6 +77,23 @@ type Managed struct {
 	srv         *server.Server
 	stateStore  stateStore
 	upgrader    *upgrade.Upgrader
+	auditLog    *dispatcher.AuditLog
+	metrics     *metricsForwarder
+	reporter    *reporting.Reporter
+	settings    *settingsWatcher
+	// stackMonitoring ships the metrics of the agent to the monitoring cluster.
+	stackMonitoring *stack.Reporter
+	// upgradeCheckedIn is true once the watcher was told the agent checked in with fleet.
+	upgradeCheckedIn bool
+}
+
+// checkinNotifier is implemented by the gateways notifying the successful checkins.
+type checkinNotifier interface {
+	OnCheckin(func())
+}
+
+type drainSetter interface {
+	SetDrainTimeout(time.Duration)
 }
 
 func newManaged(
@@ -116,17 +143,25 @@ func newManaged(
 		return nil, errors.New(err, "starting GRPC listener", errors.TypeNetwork)
 	}
 
-	logR := logreporter.NewReporter(log)
-	fleetR, err := fleetreporter.NewReporter(agentInfo, log, cfg.Fleet.Reporting)
+	fleetReporting := *cfg.Fleet.Reporting
+	if fleetReporting.Spool.Path == "" {
+		fleetReporting.Spool.Path = paths.AgentEventsSpoolDir()
+	}
+	fleetR, err := fleetreporter.NewReporterWithStore(agentInfo, log, &fleetReporting, storage.NewDiskStore(paths.AgentEventsStoreFile()))
 	if err != nil {
 		return nil, errors.New(err, "fail to create reporters")
 	}
 
-	combinedReporter := reporting.NewReporter(managedApplication.bgContext, log, agentInfo, logR, fleetR)
+	combinedReporter, err := newReporter(managedApplication.bgContext, log, agentInfo, cfg.Settings.Reporting, fleetR)
+	if err != nil {
+		return nil, errors.New(err, "fail to create reporters")
+	}
+	managedApplication.reporter = combinedReporter
 	monitor, err := monitoring.NewMonitor(cfg.Settings)
 	if err != nil {
 		return nil, errors.New(err, "failed to initialize monitoring")
 	}
+	managedApplication.stackMonitoring = stack.NewReporter(log, agentInfo.AgentID(), cfg.Settings.MonitoringConfig)
 
 	router, err := router.New(log, stream.Factory(managedApplication.bgContext, agentInfo, cfg.Settings, managedApplication.srv, combinedReporter, monitor, statusCtrl))
 	if err != nil {
@@ -139,23 +174,51 @@ func newManaged(
 		return nil, errors.New(err, "failed to initialize composable controller")
 	}
 
-	emit, err := emitter.New(
+	secretsResolver, err := secrets.NewResolver(cfg.Settings.SecretsConfig)
+	if err != nil {
+		return nil, errors.New(err, "failed to initialize the secrets providers")
+	}
+
+	emit, render, err := emitter.NewWithRenderer(
 		managedApplication.bgContext,
 		log,
 		agentInfo,
 		composableCtrl,
 		router,
 		&pipeline.ConfigModifiers{
-			Decorators: []pipeline.DecoratorFunc{modifiers.InjectMonitoring},
-			Filters:    []pipeline.FilterFunc{filters.StreamChecker, modifiers.InjectFleet(rawConfig, sysInfo.Info(), agentInfo)},
+			Decorators: []pipeline.DecoratorFunc{modifiers.InjectMonitoring, modifiers.InjectProgramCredentials(modifiers.NewAPIKeyIssuer(log))},
+			Filters:    []pipeline.FilterFunc{filters.StreamChecker, modifiers.InjectFleet(rawConfig, sysInfo.Info(), agentInfo), filters.SecretsResolver(secretsResolver)},
 		},
 		caps,
 		monitor,
+		cfg.Settings.ProcessConfig,
+		managedApplication.stackMonitoring,
 	)
 	if err != nil {
 		return nil, err
 	}
-	acker, err := fleet.NewAcker(log, agentInfo, client)
+	emit = notifyReady(emit)
+
+	// the pinned configuration is merged last so neither the policy nor the settings changed by
+	// fleet can override it.
+	pinnedPath := cfg.Settings.Pinned.Path
+	if pinnedPath == "" {
+		pinnedPath = paths.AgentPinnedConfigFile()
+	}
+	pinned, err := newPinnedLayer(log, pinnedPath, statusCtrl)
+	if err != nil {
+		return nil, err
+	}
+	emit = pinned.Emitter(emit)
+
+	// the settings changed by fleet for this agent are applied to the policies and the fleet parts.
+	agentSettings := newFleetSettings(log, storage.NewDiskStore(paths.AgentSettingsStoreFile()))
+	emit = agentSettings.Emitter(emit)
+	if err := agentSettings.SetReporter(fleetR); err != nil {
+		log.Warnf("failed to apply the reporting settings of the agent: %v", err)
+	}
+
+	acker, err := fleet.NewAckerWithStore(log, agentInfo, client, storage.NewDiskStore(paths.AgentAcksStoreFile()))
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +226,11 @@ func newManaged(
 	batchedAcker := lazy.NewAcker(acker, log)
 
 	// Create the state store that will persist the last good policy change on disk.
-	stateStore, err := store.NewStateStoreWithMigration(log, paths.AgentActionStoreFile(), paths.AgentStateStoreFile())
+	stateKey, legacySecret, err := storage.LoadOrCreateStateKey(paths.AgentSecretFile(), filepath.Join(paths.Config(), "keystore"))
+	if err != nil {
+		return nil, errors.New(err, fmt.Sprintf("fail to read the secret '%s'", paths.AgentSecretFile()))
+	}
+	stateStore, err := store.NewStateStoreWithMigration(log, paths.AgentActionStoreFile(), paths.AgentStateStoreYmlFile(), paths.AgentStateStoreFile(), stateKey, legacySecret)
 	if err != nil {
 		return nil, errors.New(err, fmt.Sprintf("fail to read action store '%s'", paths.AgentActionStoreFile()))
 	}
@@ -175,6 +242,17 @@ func newManaged(
 		return nil, err
 	}
 
+	auditLog, err := dispatcher.NewAuditLog(log, paths.AgentAuditLogFile())
+	if err != nil {
+		return nil, err
+	}
+	managedApplication.auditLog = auditLog
+	actionDispatcher.SetAuditLog(auditLog)
+	actionDispatcher.SetCapabilities(caps)
+	if cfg.Settings.Actions != nil {
+		actionDispatcher.SetMaxConcurrent(cfg.Settings.Actions.MaxConcurrent)
+	}
+
 	managedApplication.upgrader = upgrade.NewUpgrader(
 		agentInfo,
 		cfg.Settings.DownloadConfig,
@@ -192,6 +270,8 @@ func newManaged(
 		cfg,
 		storeSaver,
 	)
+	policyChanger.SetRenderer(render)
+	policyChanger.SetStatusReporter(statusCtrl.RegisterComponentWithPersistance("policy", true))
 
 	actionDispatcher.MustRegister(
 		&fleetapi.ActionPolicyChange{},
@@ -219,13 +299,25 @@ func newManaged(
 		handlers.NewUpgrade(log, managedApplication.upgrader),
 	)
 
+	settingsHandler := handlers.NewSettings(
+		log,
+		reexec,
+		agentInfo,
+	)
+	settingsHandler.SetAgentSettings(agentSettings)
 	actionDispatcher.MustRegister(
 		&fleetapi.ActionSettings{},
-		handlers.NewSettings(
-			log,
-			reexec,
-			agentInfo,
-		),
+		settingsHandler,
+	)
+
+	actionDispatcher.MustRegister(
+		&fleetapi.ActionDiagnostics{},
+		handlers.NewDiagnostics(log, cfg.Settings.MonitoringConfig, managedApplication.Routes),
+	)
+
+	actionDispatcher.MustRegister(
+		&fleetapi.ActionUnquarantine{},
+		handlers.NewUnquarantine(log, managedApplication.Routes),
 	)
 
 	actionDispatcher.MustRegister(
@@ -250,6 +342,12 @@ func newManaged(
 		stateRestored = true
 	}
 
+	// actions replayed from disk are applied again, only actions received from now on are
+	// deduplicated.
+	actionDispatcher.SetProcessedStore(stateStore)
+	// the policy restored from disk was applied again, policies with the same revision are not.
+	actionDispatcher.SetAppliedPolicy(policyChanger)
+
 	gateway, err := fleetgateway.New(
 		managedApplication.bgContext,
 		log,
@@ -264,6 +362,13 @@ func newManaged(
 	if err != nil {
 		return nil, err
 	}
+	checkin, hasCheckin := gateway.(checkinSetter)
+	if g, ok := gateway.(checkinNotifier); ok {
+		g.OnCheckin(managedApplication.markUpgradeCheckedIn)
+	}
+	if g, ok := gateway.(drainSetter); ok {
+		g.SetDrainTimeout(fleetReporting.DrainTimeout)
+	}
 	gateway, err = localgateway.New(managedApplication.bgContext, log, cfg.Fleet, rawConfig, gateway, emit, !stateRestored)
 	if err != nil {
 		return nil, err
@@ -277,6 +382,27 @@ func newManaged(
 	}
 
 	managedApplication.gateway = gateway
+
+	settings, err := newSettingsWatcher(log, paths.ConfigFile(), reexec, statusCtrl)
+	if err != nil {
+		return nil, err
+	}
+	if hasCheckin {
+		// the settings changed by fleet take precedence over the local settings.
+		if err := settings.SetGateway(checkin); err != nil {
+			log.Warnf("failed to apply the local gateway settings: %v", err)
+		}
+		if err := agentSettings.SetGateway(checkin); err != nil {
+			log.Warnf("failed to apply the gateway settings of the agent: %v", err)
+		}
+	}
+	// the pending restart is reported to Fleet without waiting for the next checkin.
+	settings.onPending = gateway.ForceCheckin
+	managedApplication.settings = settings
+
+	if mCfg := cfg.Settings.MonitoringConfig; mCfg != nil && mCfg.ForwardMetrics != nil && mCfg.ForwardMetrics.Enabled {
+		managedApplication.metrics = newMetricsForwarder(log, mCfg.ForwardMetrics.Period, managedApplication.Routes, fleetR)
+	}
 	return managedApplication, nil
 }
 
@@ -290,6 +416,8 @@ func (m *Managed) Start() error {
 	m.log.Info("Agent is starting")
 	if m.wasUnenrolled() {
 		m.log.Warnf("agent was previously unenrolled. To reactivate please reconfigure or enroll again.")
+		// the agent is idle until it is enrolled again, it is still started for systemd.
+		systemd.Ready()
 		return nil
 	}
 
@@ -301,21 +429,74 @@ func (m *Managed) Start() error {
 	err := m.upgrader.Ack(m.bgContext)
 	if err != nil {
 		m.log.Warnf("failed to ack update %v", err)
+		go m.retryUpgradeAck()
+	}
+
+	if err := m.stackMonitoring.Start(); err != nil {
+		m.log.Warnf("Metrics of the agent are not shipped to the monitoring cluster: %v", err)
 	}
 
 	err = m.gateway.Start()
 	if err != nil {
 		return err
 	}
+
+	if m.metrics != nil {
+		go m.metrics.Run(m.bgContext)
+	}
+	if m.settings == nil {
+		return nil
+	}
+	if err := m.settings.Start(); err != nil {
+		m.log.Warnf("Changes of the local settings are not applied until the agent is restarted: %v", err)
+	}
 	return nil
 }
 
+// retryUpgradeAck acknowledges the upgrade which restarted the agent until fleet is reached, the
+// watcher rolls back the upgrade when it is not acknowledged within the grace period.
+func (m *Managed) retryUpgradeAck() {
+	b := backoff.NewEqualJitterBackoff(m.bgContext.Done(), upgradeAckBackoffInit, upgradeAckBackoffMax)
+	for b.Wait() {
+		err := m.upgrader.Ack(m.bgContext)
+		if err == nil {
+			m.log.Info("update acked")
+			return
+		}
+		m.log.Warnf("failed to ack update %v", err)
+	}
+}
+
+// markUpgradeCheckedIn tells the watcher the upgraded agent checked in with fleet so the upgrade is
+// not rolled back, it is called by the gateway after each successful checkin.
+func (m *Managed) markUpgradeCheckedIn() {
+	if m.upgradeCheckedIn {
+		return
+	}
+	if err := upgrade.MarkCheckedIn(); err != nil {
+		m.log.Warnf("failed to record the checkin of the upgraded agent: %v", err)
+		return
+	}
+	m.upgradeCheckedIn = true
+}
+
 // Stop stops a managed elastic-agent.
 func (m *Managed) Stop() error {
 	defer m.log.Info("Agent is stopped")
+	if m.settings != nil {
+		m.settings.Stop()
+	}
 	m.cancelCtxFn()
+	if err := m.gateway.Stop(); err != nil {
+		m.log.Warnf("failed to stop the fleet gateway: %v", err)
+	}
 	m.router.Shutdown()
+	m.stackMonitoring.Stop()
 	m.srv.Stop()
+	if err := m.auditLog.Close(); err != nil {
+		m.log.Warnf("failed to close the audit log: %v", err)
+	}
+	m.reporter.Close()
 	return nil
 }
 
@@ -0,0 +1,164 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/diagnostics"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/beats"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+// metricsTimeout bounds the time spent reading the stats of a single process.
+const metricsTimeout = 10 * time.Second
+
+type metricsBackend interface {
+	Report(context.Context, reporter.Event) error
+}
+
+// metricsForwarder periodically reads the stats of the processes spawned by the agent and reports
+// a summary of them to fleet, so fleet can show the health of each integration.
+type metricsForwarder struct {
+	log      *logger.Logger
+	period   time.Duration
+	routesFn func() *sorted.Set
+	backend  metricsBackend
+	statsFn  func(ctx context.Context, endpoint string) ([]byte, error)
+
+	// last sample of each process, used to compute the rates.
+	samples map[string]metricsSample
+}
+
+type metricsSample struct {
+	ts    time.Time
+	acked int64
+}
+
+func newMetricsForwarder(log *logger.Logger, period time.Duration, routesFn func() *sorted.Set, backend metricsBackend) *metricsForwarder {
+	return &metricsForwarder{
+		log:      log,
+		period:   period,
+		routesFn: routesFn,
+		backend:  backend,
+		statsFn: func(ctx context.Context, endpoint string) ([]byte, error) {
+			return diagnostics.Get(ctx, endpoint, "/stats")
+		},
+		samples: make(map[string]metricsSample),
+	}
+}
+
+// Run forwards the metrics until ctx is cancelled.
+func (f *metricsForwarder) Run(ctx context.Context) {
+	t := time.NewTicker(f.period)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		f.forward(ctx)
+	}
+}
+
+func (f *metricsForwarder) forward(ctx context.Context) {
+	procs := diagnostics.RouteProcesses(f.routesFn(), func(spec program.Spec, rk string) string {
+		return beats.MonitoringEndpoint(spec, runtime.GOOS, rk)
+	})
+
+	seen := make(map[string]struct{}, len(procs))
+	for _, p := range procs {
+		key := p.Name + "_" + p.RouteKey
+		seen[key] = struct{}{}
+
+		metrics, err := f.collect(ctx, key, p.Endpoint)
+		if err != nil {
+			f.log.Debugf("failed to read the metrics of %s for output %s: %v", p.Name, p.RouteKey, err)
+			continue
+		}
+		metrics["route_key"] = p.RouteKey
+		if err := f.backend.Report(ctx, reporter.NewMetricsRecord(p.Name, p.RouteKey, metrics)); err != nil {
+			f.log.Debugf("failed to report the metrics of %s for output %s: %v", p.Name, p.RouteKey, err)
+		}
+	}
+
+	// processes which are not running anymore start from scratch when they come back.
+	for key := range f.samples {
+		if _, ok := seen[key]; !ok {
+			delete(f.samples, key)
+		}
+	}
+}
+
+func (f *metricsForwarder) collect(ctx context.Context, key, endpoint string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, metricsTimeout)
+	defer cancel()
+
+	body, err := f.statsFn(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats common.MapStr
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return nil, err
+	}
+
+	acked := statsValue(stats, "libbeat.output.events.acked")
+	metrics := map[string]interface{}{
+		"queue": map[string]interface{}{
+			"events": statsValue(stats, "libbeat.pipeline.events.active"),
+		},
+		"output": map[string]interface{}{
+			"events": map[string]interface{}{
+				"acked":  acked,
+				"failed": statsValue(stats, "libbeat.output.events.failed"),
+				"rate":   f.rate(key, acked),
+			},
+			"write": map[string]interface{}{
+				"errors": statsValue(stats, "libbeat.output.write.errors"),
+			},
+		},
+	}
+	return metrics, nil
+}
+
+// rate returns the number of events acked per second since the previous sample, the counters of a
+// restarted process start from zero so a decreasing counter gives no rate.
+func (f *metricsForwarder) rate(key string, acked int64) float64 {
+	now := time.Now()
+	prev, ok := f.samples[key]
+	f.samples[key] = metricsSample{ts: now, acked: acked}
+	if !ok || acked < prev.acked {
+		return 0
+	}
+
+	elapsed := now.Sub(prev.ts).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(acked-prev.acked) / elapsed
+}
+
+func statsValue(stats common.MapStr, key string) int64 {
+	v, err := stats.GetValue(key)
+	if err != nil {
+		return 0
+	}
+	// numbers are decoded as float64.
+	n, ok := v.(float64)
+	if !ok {
+		return 0
+	}
+	return int64(n)
+}
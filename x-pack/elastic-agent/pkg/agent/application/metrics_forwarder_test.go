@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+)
+
+type testMetricsBackend struct{}
+
+func (b *testMetricsBackend) Report(_ context.Context, _ reporter.Event) error {
+	return nil
+}
+
+func TestMetricsForwarderCollect(t *testing.T) {
+	log, _ := logger.New("", false)
+	backend := &testMetricsBackend{}
+	f := newMetricsForwarder(log, time.Minute, nil, backend)
+
+	stats := `{"libbeat":{"pipeline":{"events":{"active":12}},"output":{"events":{"acked":100,"failed":3},"write":{"errors":2}}}}`
+	f.statsFn = func(_ context.Context, endpoint string) ([]byte, error) {
+		if endpoint == "unreachable" {
+			return nil, errors.New("connection refused")
+		}
+		return []byte(stats), nil
+	}
+
+	metrics, err := f.collect(context.Background(), "filebeat_default", "filebeat")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"queue": map[string]interface{}{"events": int64(12)},
+		"output": map[string]interface{}{
+			"events": map[string]interface{}{"acked": int64(100), "failed": int64(3), "rate": float64(0)},
+			"write":  map[string]interface{}{"errors": int64(2)},
+		},
+	}, metrics)
+
+	t.Run("rate since the previous sample", func(t *testing.T) {
+		f.samples["filebeat_default"] = metricsSample{ts: time.Now().Add(-10 * time.Second), acked: 50}
+		metrics, err := f.collect(context.Background(), "filebeat_default", "filebeat")
+		require.NoError(t, err)
+		rate := metrics["output"].(map[string]interface{})["events"].(map[string]interface{})["rate"].(float64)
+		assert.InDelta(t, 5, rate, 0.5)
+	})
+
+	t.Run("restarted process", func(t *testing.T) {
+		f.samples["filebeat_default"] = metricsSample{ts: time.Now().Add(-10 * time.Second), acked: 500}
+		metrics, err := f.collect(context.Background(), "filebeat_default", "filebeat")
+		require.NoError(t, err)
+		rate := metrics["output"].(map[string]interface{})["events"].(map[string]interface{})["rate"].(float64)
+		assert.Equal(t, float64(0), rate)
+	})
+
+	t.Run("unreachable process", func(t *testing.T) {
+		_, err := f.collect(context.Background(), "metricbeat_default", "unreachable")
+		assert.Error(t, err)
+	})
+}
@@ -34,6 +34,11 @@ func (o *once) Start() error {
 	return readfiles(files, o.emitter)
 }
 
+// Reload reads and emits the configuration again.
+func (o *once) Reload() error {
+	return o.Start()
+}
+
 func (o *once) Stop() error {
 	return nil
 }
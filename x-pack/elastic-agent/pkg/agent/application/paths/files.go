@@ -20,11 +20,52 @@ const defaultAgentFleetFile = "fleet.yml"
 // defaultAgentEnrollFile is a name of file used to enroll agent on first-start
 const defaultAgentEnrollFile = "enroll.yml"
 
+// defaultAgentEnrollmentTokenFile is a name of file written by provisioning tools to enroll a standalone agent
+const defaultAgentEnrollmentTokenFile = "enrollment_token.yml"
+
+// defaultAgentPinnedConfigFile is a name of file holding the local configuration merged over the policy
+const defaultAgentPinnedConfigFile = "pinned.yml"
+
 // defaultAgentActionStoreFile is the file that will contains the action that can be replayed after restart.
 const defaultAgentActionStoreFile = "action_store.yml"
 
-// defaultAgentStateStoreFile is the file that will contains the action that can be replayed after restart.
-const defaultAgentStateStoreFile = "state.yml"
+// defaultAgentStateStoreYmlFile is the file that will contains the action that can be replayed after restart.
+// It was stored unencrypted by previous versions and is migrated to defaultAgentStateStoreFile.
+const defaultAgentStateStoreYmlFile = "state.yml"
+
+// defaultAgentStateStoreFile is the file that will contains the action that can be replayed after restart encrypted.
+const defaultAgentStateStoreFile = "state.enc"
+
+// defaultAgentSecretFile is the file that will contains the secret used to encrypt the agent state.
+const defaultAgentSecretFile = "agent.secret"
+
+// defaultAgentEventsStoreFile is the file that will contains the events not yet acknowledged by fleet
+// and the sequence of the last reported event.
+const defaultAgentEventsStoreFile = "events.json"
+
+// defaultAgentAcksStoreFile is the file that will contains the acks buffered while fleet is unreachable.
+const defaultAgentAcksStoreFile = "acks.json"
+
+// defaultAgentEventsSpoolDir is the directory of the events spooled when the fleet reporter queue is full.
+const defaultAgentEventsSpoolDir = "events_spool"
+
+// defaultAgentSettingsStoreFile is the file that will contains the settings of the agent changed by fleet.
+const defaultAgentSettingsStoreFile = "settings.json"
+
+// defaultAgentControlTokenFile is the file that contains the token authenticating the clients of the control socket.
+const defaultAgentControlTokenFile = "control.token"
+
+// defaultAgentAuditLogFile is the name of the files recording the actions received from fleet.
+const defaultAgentAuditLogFile = "elastic-agent-audit"
+
+// defaultAgentKeystoreFile is the keystore holding the secrets referenced by the standalone configuration.
+const defaultAgentKeystoreFile = "elastic-agent.keystore"
+
+// defaultAgentUpgradeMarkerFile is the marker of the upgrade waiting to be committed by the watcher.
+const defaultAgentUpgradeMarkerFile = ".update-marker"
+
+// defaultAgentDiagnosticsDir is the name of the directory holding the diagnostics archives requested by fleet.
+const defaultAgentDiagnosticsDir = "diagnostics"
 
 // AgentConfigFile is a name of file used to store agent information
 func AgentConfigFile() string {
@@ -44,6 +85,16 @@ func AgentEnrollFile() string {
 	return filepath.Join(Config(), defaultAgentEnrollFile)
 }
 
+// AgentEnrollmentTokenFile is a name of file watched by a standalone agent to enroll into Fleet
+func AgentEnrollmentTokenFile() string {
+	return filepath.Join(Config(), defaultAgentEnrollmentTokenFile)
+}
+
+// AgentPinnedConfigFile is a name of file holding the local configuration merged over the policy
+func AgentPinnedConfigFile() string {
+	return filepath.Join(Config(), defaultAgentPinnedConfigFile)
+}
+
 // AgentCapabilitiesPath is a name of file used to store agent capabilities
 func AgentCapabilitiesPath() string {
 	return filepath.Join(Config(), defaultAgentCapabilitiesFile)
@@ -54,7 +105,65 @@ func AgentActionStoreFile() string {
 	return filepath.Join(Home(), defaultAgentActionStoreFile)
 }
 
+// AgentEventsStoreFile is the file that contains the events not yet acknowledged by fleet.
+func AgentEventsStoreFile() string {
+	return filepath.Join(Home(), defaultAgentEventsStoreFile)
+}
+
+// AgentAcksStoreFile is the file that contains the acks buffered while fleet is unreachable.
+func AgentAcksStoreFile() string {
+	return filepath.Join(Home(), defaultAgentAcksStoreFile)
+}
+
+// AgentEventsSpoolDir is the directory that contains the events spooled by the fleet reporter.
+func AgentEventsSpoolDir() string {
+	return filepath.Join(Home(), defaultAgentEventsSpoolDir)
+}
+
+// AgentStateStoreYmlFile is the file that contains the persisted state of the agent stored unencrypted by previous versions.
+func AgentStateStoreYmlFile() string {
+	return filepath.Join(Home(), defaultAgentStateStoreYmlFile)
+}
+
 // AgentStateStoreFile is the file that contains the persisted state of the agent including the action that can be replayed after restart.
 func AgentStateStoreFile() string {
 	return filepath.Join(Home(), defaultAgentStateStoreFile)
 }
+
+// AgentSecretFile is the file that contains the secret used to encrypt the persisted state of the agent.
+func AgentSecretFile() string {
+	return filepath.Join(Home(), defaultAgentSecretFile)
+}
+
+// AgentSettingsStoreFile is the file that contains the settings of the agent changed by fleet.
+func AgentSettingsStoreFile() string {
+	return filepath.Join(Home(), defaultAgentSettingsStoreFile)
+}
+
+// AgentControlTokenFile is the file that contains the token authenticating the clients of the control socket.
+func AgentControlTokenFile() string {
+	return filepath.Join(Home(), defaultAgentControlTokenFile)
+}
+
+// AgentAuditLogFile is the name of the files recording the actions received from fleet, they are
+// kept in their own directory so they are not shipped with the logs of the agent.
+func AgentAuditLogFile() string {
+	return filepath.Join(Logs(), "audit", defaultAgentAuditLogFile)
+}
+
+// AgentKeystoreFile is the keystore holding the secrets referenced by the standalone configuration,
+// it is kept next to the configuration so it is preserved on upgrade.
+func AgentKeystoreFile() string {
+	return filepath.Join(Config(), defaultAgentKeystoreFile)
+}
+
+// AgentDiagnosticsDir is the directory where the diagnostics archives requested by fleet are written.
+func AgentDiagnosticsDir() string {
+	return filepath.Join(Data(), defaultAgentDiagnosticsDir)
+}
+
+// AgentUpgradeMarkerFile is the marker of the upgrade, it exists until the watcher commits or
+// rolls back the upgrade.
+func AgentUpgradeMarkerFile() string {
+	return filepath.Join(Data(), defaultAgentUpgradeMarkerFile)
+}
@@ -21,6 +21,9 @@ type periodic struct {
 	watcher  *filewatcher.Watch
 	emitter  pipeline.EmitterFunc
 	discover discoverFunc
+	notifier *changeNotifier
+	// reload receives the requests to read the configuration right away.
+	reload chan chan error
 }
 
 func (p *periodic) Start() error {
@@ -29,6 +32,11 @@ func (p *periodic) Start() error {
 			p.log.Debugf("Failed to read configuration, error: %s", err)
 		}
 
+		var changes <-chan struct{}
+		if p.notifier != nil {
+			changes = p.notifier.Changes()
+		}
+
 	WORK:
 		for {
 			t := time.NewTimer(p.period)
@@ -37,6 +45,14 @@ func (p *periodic) Start() error {
 				t.Stop()
 				break WORK
 			case <-t.C:
+			case <-changes:
+				t.Stop()
+			case res := <-p.reload:
+				t.Stop()
+				// the whole configuration is emitted again, even when no file changed.
+				p.watcher.Invalidate()
+				res <- p.work()
+				continue
 			}
 
 			if err := p.work(); err != nil {
@@ -102,8 +118,39 @@ func (p *periodic) work() error {
 	return nil
 }
 
+// WatchChanges reloads the configuration as soon as the files in the directories of the patterns
+// change, without waiting for the next period.
+func (p *periodic) WatchChanges(patterns ...string) error {
+	n, err := newChangeNotifier(p.log, patterns...)
+	if err != nil {
+		return err
+	}
+	p.notifier = n
+	return nil
+}
+
+// Reload reads the configuration right away, without waiting for the next period.
+func (p *periodic) Reload() error {
+	res := make(chan error, 1)
+	select {
+	case <-p.done:
+		return errors.New("configuration is not watched anymore")
+	case p.reload <- res:
+	}
+
+	select {
+	case <-p.done:
+		return errors.New("configuration is not watched anymore")
+	case err := <-res:
+		return err
+	}
+}
+
 func (p *periodic) Stop() error {
 	close(p.done)
+	if p.notifier != nil {
+		return p.notifier.Close()
+	}
 	return nil
 }
 
@@ -124,6 +171,7 @@ func newPeriodic(
 		log:      log,
 		period:   period,
 		done:     make(chan struct{}),
+		reload:   make(chan chan error),
 		watcher:  w,
 		discover: discover,
 		emitter:  emitter,
@@ -0,0 +1,100 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func TestPeriodicWatchChanges(t *testing.T) {
+	log, _ := logger.New("", false)
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "elastic-agent.yml")
+	inputsPattern := filepath.Join(dir, "inputs.d", "*.yml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("outputs:\n  default:\n    type: elasticsearch\n"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "inputs.d"), 0700))
+
+	emitted := make(chan *config.Config, 10)
+	emit := func(c *config.Config) error {
+		emitted <- c
+		return nil
+	}
+
+	// the period is long enough for the reloads to only come from the watcher.
+	p := newPeriodic(log, time.Hour, discoverer(configFile, inputsPattern), emit)
+	require.NoError(t, p.WatchChanges(configFile, inputsPattern))
+	require.NoError(t, p.Start())
+	defer p.Stop()
+
+	next := func(t *testing.T) map[string]interface{} {
+		select {
+		case c := <-emitted:
+			m, err := c.ToMapStr()
+			require.NoError(t, err)
+			return m
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "configuration not reloaded")
+			return nil
+		}
+	}
+
+	first := next(t)
+	assert.Contains(t, first, "outputs")
+	assert.NotContains(t, first, "inputs")
+
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("outputs:\n  default:\n    type: elasticsearch\ninputs:\n- type: logfile\n"), 0600))
+	assert.Contains(t, next(t), "inputs")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "inputs.d", "system.yml"), []byte("agent:\n  id: agent-1\n"), 0600))
+	assert.Contains(t, next(t), "agent")
+
+	// a change which does not change the content is not emitted.
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("outputs:\n  default:\n    type: elasticsearch\ninputs:\n- type: logfile\n"), 0600))
+	select {
+	case <-emitted:
+		assert.Fail(t, "unchanged configuration emitted")
+	case <-time.After(2 * changeDebounce):
+	}
+}
+
+func TestPeriodicReload(t *testing.T) {
+	log, _ := logger.New("", false)
+	configFile := filepath.Join(t.TempDir(), "elastic-agent.yml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("outputs:\n  default:\n    type: elasticsearch\n"), 0600))
+
+	emitted := make(chan *config.Config, 10)
+	emit := func(c *config.Config) error {
+		emitted <- c
+		return nil
+	}
+
+	p := newPeriodic(log, time.Hour, discoverer(configFile), emit)
+	require.NoError(t, p.Start())
+
+	// the unchanged configuration is emitted again by each reload.
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			require.NoError(t, p.Reload())
+		}
+		select {
+		case <-emitted:
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "configuration not emitted")
+		}
+	}
+
+	require.NoError(t, p.Stop())
+	assert.Error(t, p.Reload())
+}
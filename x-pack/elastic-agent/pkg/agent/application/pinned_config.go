@@ -0,0 +1,138 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+// pinnedSectionsDenied are the sections which cannot be pinned, the connection to fleet is owned
+// by the enrollment of the agent.
+var pinnedSectionsDenied = []string{"fleet"}
+
+// pinnedLayer is the local configuration layer merged over the policies received from fleet, it
+// holds the site-local requirements which cannot be changed centrally.
+//
+// The layer takes precedence over the policy:
+//   - maps are merged key by key, the keys missing from the layer are taken from the policy
+//   - any other value of the layer, including lists, replaces the value of the policy
+//
+// A conflict is reported when the policy sets a pinned key to another value, the conflicting keys
+// are logged and the component is degraded until a policy without conflict is received.
+type pinnedLayer struct {
+	log      *logger.Logger
+	path     string
+	layer    map[string]interface{}
+	reporter status.Reporter
+}
+
+// newPinnedLayer loads the pinned layer, the policies are used as is when the file does not exist.
+// An invalid layer is an error so the agent never runs without its local requirements.
+func newPinnedLayer(log *logger.Logger, path string, statusCtrl status.Controller) (*pinnedLayer, error) {
+	p := &pinnedLayer{log: log, path: path}
+
+	rawConfig, err := config.LoadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, errors.New(err, "could not read the pinned configuration", errors.TypeConfig, errors.M(errors.MetaKeyPath, path))
+	}
+
+	layer, err := rawConfig.ToMapStr()
+	if err != nil {
+		return nil, errors.New(err, "could not parse the pinned configuration", errors.TypeConfig, errors.M(errors.MetaKeyPath, path))
+	}
+	for _, section := range pinnedSectionsDenied {
+		if _, ok := layer[section]; ok {
+			return nil, errors.New(fmt.Sprintf("section '%s' cannot be pinned", section), errors.TypeConfig, errors.M(errors.MetaKeyPath, path))
+		}
+	}
+	if len(layer) == 0 {
+		return p, nil
+	}
+
+	p.layer = layer
+	p.reporter = statusCtrl.RegisterComponent("pinned")
+	log.Infof("Pinned configuration loaded from %s, its settings take precedence over the policy", path)
+	return p, nil
+}
+
+// Emitter wraps the emitter so the pinned layer is merged over the emitted policies.
+func (p *pinnedLayer) Emitter(emit pipeline.EmitterFunc) pipeline.EmitterFunc {
+	if p.layer == nil {
+		return emit
+	}
+
+	return func(c *config.Config) error {
+		policy, err := c.ToMapStr()
+		if err != nil {
+			return errors.New(err, "could not read the policy", errors.TypeConfig)
+		}
+
+		var conflicts []string
+		merged := mergePinned("", policy, p.layer, &conflicts)
+		sort.Strings(conflicts)
+		p.report(conflicts)
+
+		updated, err := config.NewConfigFrom(merged)
+		if err != nil {
+			return errors.New(err, "could not apply the pinned configuration", errors.TypeConfig)
+		}
+		return emit(updated)
+	}
+}
+
+func (p *pinnedLayer) report(conflicts []string) {
+	if len(conflicts) == 0 {
+		p.reporter.Update(state.Healthy, "", nil)
+		return
+	}
+
+	msg := fmt.Sprintf("policy settings overridden by the pinned configuration: %s", strings.Join(conflicts, ", "))
+	p.log.Warnf("The policy conflicts with the pinned configuration %s, the pinned settings are kept: %s", p.path, strings.Join(conflicts, ", "))
+	p.reporter.Update(state.Degraded, msg, map[string]interface{}{"conflicts": conflicts})
+}
+
+// mergePinned returns the policy with the pinned layer merged over it, the keys of the policy
+// overridden with another value are added to the conflicts.
+func mergePinned(prefix string, policy, pinned map[string]interface{}, conflicts *[]string) map[string]interface{} {
+	merged := make(map[string]interface{}, len(policy))
+	for k, v := range policy {
+		merged[k] = v
+	}
+
+	for k, pinnedValue := range pinned {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		policyValue, ok := policy[k]
+		pinnedMap, pinnedIsMap := pinnedValue.(map[string]interface{})
+		policyMap, policyIsMap := policyValue.(map[string]interface{})
+		if pinnedIsMap && policyIsMap {
+			merged[k] = mergePinned(key, policyMap, pinnedMap, conflicts)
+			continue
+		}
+
+		if ok && !reflect.DeepEqual(policyValue, pinnedValue) {
+			*conflicts = append(*conflicts, key)
+		}
+		merged[k] = pinnedValue
+	}
+	return merged
+}
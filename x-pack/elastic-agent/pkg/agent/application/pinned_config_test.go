@@ -0,0 +1,109 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+func TestPinnedLayer(t *testing.T) {
+	log, _ := logger.New("", false)
+
+	newLayer := func(t *testing.T, content string) (*pinnedLayer, status.Controller, error) {
+		path := filepath.Join(t.TempDir(), "pinned.yml")
+		if content != "" {
+			require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+		}
+		statusCtrl := status.NewController(log)
+		p, err := newPinnedLayer(log, path, statusCtrl)
+		return p, statusCtrl, err
+	}
+
+	var emitted map[string]interface{}
+	emit := func(c *config.Config) error {
+		m, err := c.ToMapStr()
+		require.NoError(t, err)
+		emitted = m
+		return nil
+	}
+
+	policy := config.MustNewConfigFrom(map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{"type": "elasticsearch", "hosts": []interface{}{"central:9200"}},
+		},
+		"agent": map[string]interface{}{
+			"monitoring": map[string]interface{}{"enabled": true, "logs": true},
+		},
+		"inputs": []interface{}{map[string]interface{}{"type": "logfile"}},
+	})
+
+	t.Run("policy is used as is without pinned configuration", func(t *testing.T) {
+		p, statusCtrl, err := newLayer(t, "")
+		require.NoError(t, err)
+
+		require.NoError(t, p.Emitter(emit)(policy))
+		expected, _ := policy.ToMapStr()
+		assert.Equal(t, expected, emitted)
+		assert.Equal(t, status.Healthy, statusCtrl.StatusCode())
+	})
+
+	t.Run("pinned settings take precedence", func(t *testing.T) {
+		p, statusCtrl, err := newLayer(t, `
+outputs.default.hosts: ["site:9200"]
+agent.monitoring.logs: true
+agent.monitoring.metrics: false
+`)
+		require.NoError(t, err)
+
+		require.NoError(t, p.Emitter(emit)(policy))
+		assert.Equal(t, map[string]interface{}{
+			"default": map[string]interface{}{"type": "elasticsearch", "hosts": []interface{}{"site:9200"}},
+		}, emitted["outputs"])
+		assert.Equal(t, map[string]interface{}{
+			"monitoring": map[string]interface{}{"enabled": true, "logs": true, "metrics": false},
+		}, emitted["agent"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"type": "logfile"}}, emitted["inputs"])
+
+		// only the pinned keys set by the policy to another value are conflicts.
+		assert.Equal(t, status.Degraded, statusCtrl.StatusCode())
+		assert.Contains(t, statusCtrl.Status().Message, "outputs.default.hosts")
+		assert.NotContains(t, statusCtrl.Status().Message, "agent.monitoring")
+	})
+
+	t.Run("conflict is cleared by the next policy", func(t *testing.T) {
+		p, statusCtrl, err := newLayer(t, "agent.monitoring.enabled: false\n")
+		require.NoError(t, err)
+
+		require.NoError(t, p.Emitter(emit)(policy))
+		assert.Equal(t, status.Degraded, statusCtrl.StatusCode())
+
+		require.NoError(t, p.Emitter(emit)(config.MustNewConfigFrom(map[string]interface{}{
+			"inputs": []interface{}{},
+		})))
+		assert.Equal(t, status.Healthy, statusCtrl.StatusCode())
+		assert.Equal(t, map[string]interface{}{
+			"monitoring": map[string]interface{}{"enabled": false},
+		}, emitted["agent"])
+	})
+
+	t.Run("fleet section cannot be pinned", func(t *testing.T) {
+		_, _, err := newLayer(t, "fleet.enabled: false\n")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid pinned configuration is an error", func(t *testing.T) {
+		_, _, err := newLayer(t, "outputs: [\n")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,167 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/diagnostics"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config/operations"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/beats"
+	monitoringCfg "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+// diagnosticsTimeout bounds the time spent reading the metrics of the agent and of its processes.
+const diagnosticsTimeout = 30 * time.Second
+
+// Diagnostics handles the diagnostics requests coming from fleet, the archive is written on the
+// host of the agent and its path is reported to fleet when the action is acknowledged.
+type Diagnostics struct {
+	log           *logger.Logger
+	monitoringCfg *monitoringCfg.MonitoringConfig
+	routesFn      func() *sorted.Set
+	dir           string
+	configFn      func() (map[string]interface{}, error)
+}
+
+// NewDiagnostics creates a new Diagnostics handler.
+func NewDiagnostics(
+	log *logger.Logger,
+	monitoringCfg *monitoringCfg.MonitoringConfig,
+	routesFn func() *sorted.Set,
+) *Diagnostics {
+	return &Diagnostics{
+		log:           log,
+		monitoringCfg: monitoringCfg,
+		routesFn:      routesFn,
+		dir:           paths.AgentDiagnosticsDir(),
+		configFn:      loadRenderedConfig,
+	}
+}
+
+// Handle handles REQUEST_DIAGNOSTICS action.
+func (h *Diagnostics) Handle(ctx context.Context, a fleetapi.Action, acker store.FleetAcker) error {
+	h.log.Debugf("handlerDiagnostics: action '%+v' received", a)
+	action, ok := a.(*fleetapi.ActionDiagnostics)
+	if !ok {
+		return fmt.Errorf("invalid type, expected ActionDiagnostics and received %T", a)
+	}
+
+	archive, err := h.collect(ctx)
+	if err != nil {
+		return errors.New(err, "fail to collect diagnostics", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, h.dir))
+	}
+	action.Archive = archive
+	h.log.Infof("Diagnostics archive '%s' collected for action '%s', it may contain plain text credentials", archive, action.ActionID)
+
+	if err := acker.Ack(ctx, action); err != nil {
+		return err
+	}
+	return acker.Commit(ctx)
+}
+
+func (h *Diagnostics) collect(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return "", err
+	}
+
+	// RFC3339 format that replaces : with -, so it will work on Windows
+	name := "elastic-agent-diagnostics-" + time.Now().UTC().Format("2006-01-02T15-04-05Z07-00") + ".zip"
+	fileName := filepath.Join(h.dir, name)
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+	defer cancel()
+
+	zw := zip.NewWriter(f)
+	err = h.writeArchive(ctx, zw)
+	if cErr := zw.Close(); err == nil {
+		err = cErr
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(fileName)
+		return "", err
+	}
+	return fileName, nil
+}
+
+func (h *Diagnostics) writeArchive(ctx context.Context, zw *zip.Writer) error {
+	if err := writeYAML(zw, "meta/elastic-agent-version.yaml", release.Info()); err != nil {
+		return err
+	}
+
+	cfg, err := h.configFn()
+	if err != nil {
+		zf, zErr := zw.Create("config/elastic-agent-policy_error.txt")
+		if zErr != nil {
+			return zErr
+		}
+		if _, zErr := zf.Write([]byte(err.Error())); zErr != nil {
+			return zErr
+		}
+	} else if err := writeYAML(zw, "config/elastic-agent-policy.yaml", cfg); err != nil {
+		return err
+	}
+
+	if err := diagnostics.ZipLogs(zw); err != nil {
+		return err
+	}
+
+	var httpCfg *monitoringCfg.MonitoringHTTPConfig
+	if h.monitoringCfg != nil {
+		httpCfg = h.monitoringCfg.HTTP
+	}
+	procs := diagnostics.RouteProcesses(h.routesFn(), func(spec program.Spec, rk string) string {
+		return beats.MonitoringEndpoint(spec, runtime.GOOS, rk)
+	})
+	if err := diagnostics.ZipMetrics(ctx, zw, beats.AgentMonitoringEndpoint(runtime.GOOS, httpCfg), procs); err != nil {
+		return err
+	}
+
+	return diagnostics.ZipGoroutines(zw, "elastic-agent")
+}
+
+func writeYAML(zw *zip.Writer, name string, v interface{}) error {
+	zf, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	ye := yaml.NewEncoder(zf)
+	if err := ye.Encode(v); err != nil {
+		return err
+	}
+	return ye.Close()
+}
+
+// loadRenderedConfig loads the configuration of the agent with the policy received from fleet.
+func loadRenderedConfig() (map[string]interface{}, error) {
+	cfg, err := operations.LoadFullAgentConfig(paths.ConfigFile(), true)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ToMapStr()
+}
@@ -0,0 +1,88 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	monitoringCfg "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+func TestDiagnostics(t *testing.T) {
+	log, _ := logger.New("", false)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stats":
+			w.Write([]byte(`{"fleet_gateway":{"checkins_attempted_total":1}}`))
+		case "/status":
+			w.Write([]byte(`{"status":"HEALTHY"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	cfg := monitoringCfg.DefaultConfig()
+	cfg.HTTP = &monitoringCfg.MonitoringHTTPConfig{Enabled: true, Host: host, Port: port}
+
+	h := NewDiagnostics(log, cfg, sorted.NewSet)
+	h.dir = filepath.Join(t.TempDir(), "diagnostics")
+	h.configFn = func() (map[string]interface{}, error) {
+		return map[string]interface{}{"outputs": map[string]interface{}{"default": map[string]interface{}{"type": "elasticsearch"}}}, nil
+	}
+
+	acker := &actionsAcker{}
+	action := &fleetapi.ActionDiagnostics{ActionID: "abc123", ActionType: fleetapi.ActionTypeDiagnostics}
+	require.NoError(t, h.Handle(context.Background(), action, acker))
+
+	require.Len(t, acker.acked, 1)
+	acked, ok := acker.acked[0].(*fleetapi.ActionDiagnostics)
+	require.True(t, ok)
+	require.Equal(t, h.dir, filepath.Dir(acked.Archive))
+
+	zr, err := zip.OpenReader(acked.Archive)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{
+		"meta/elastic-agent-version.yaml",
+		"config/elastic-agent-policy.yaml",
+		"metrics/elastic-agent-status.json",
+		"goroutines/elastic-agent.txt",
+	} {
+		require.Contains(t, files, name)
+	}
+
+	require.Contains(t, files, "metrics/elastic-agent-stats.json")
+	rc, err := files["metrics/elastic-agent-stats.json"].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	stats, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"fleet_gateway":{"checkins_attempted_total":1}}`, string(stats))
+}
@@ -7,10 +7,12 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"sort"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -24,6 +26,8 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/client"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/remote"
@@ -41,6 +45,14 @@ type PolicyChange struct {
 	config    *configuration.Configuration
 	store     storage.Store
 	setters   []actions.ClientSetter
+	metrics   *policyMetrics
+	renderer  pipeline.RendererFunc
+	reporter  status.Reporter
+
+	// previous is the last policy successfully applied, it is applied again when a new policy
+	// fails to be applied. It is read by AppliedRevision concurrently to the handling of actions.
+	previousMx sync.RWMutex
+	previous   *fleetapi.ActionPolicyChange
 }
 
 // NewPolicyChange creates a new PolicyChange handler.
@@ -59,6 +71,7 @@ func NewPolicyChange(
 		config:    config,
 		store:     store,
 		setters:   setters,
+		metrics:   newPolicyMetrics(policyRegistry()),
 	}
 }
 
@@ -71,6 +84,16 @@ func (h *PolicyChange) AddSetter(cs actions.ClientSetter) {
 	h.setters = append(h.setters, cs)
 }
 
+// SetRenderer sets the function rendering the programs of the policies handled in dry run.
+func (h *PolicyChange) SetRenderer(r pipeline.RendererFunc) {
+	h.renderer = r
+}
+
+// SetStatusReporter sets the reporter marking the agent degraded when a policy is rolled back.
+func (h *PolicyChange) SetStatusReporter(r status.Reporter) {
+	h.reporter = r
+}
+
 // Handle handles policy change action.
 func (h *PolicyChange) Handle(ctx context.Context, a fleetapi.Action, acker store.FleetAcker) error {
 	h.log.Debugf("handlerPolicyChange: action '%+v' received", a)
@@ -79,23 +102,136 @@ func (h *PolicyChange) Handle(ctx context.Context, a fleetapi.Action, acker stor
 		return fmt.Errorf("invalid type, expected ActionPolicyChange and received %T", a)
 	}
 
+	if err := h.verifyPolicy(action); err != nil {
+		return err
+	}
+
 	c, err := config.NewConfigFrom(action.Policy)
 	if err != nil {
 		return errors.New(err, "could not parse the configuration from the policy", errors.TypeConfig)
 	}
 
+	if action.DryRun || (h.config.Fleet != nil && h.config.Fleet.PolicyDryRun) {
+		return h.handleDryRun(ctx, action, c, acker)
+	}
+
 	h.log.Debugf("handlerPolicyChange: emit configuration for action %+v", a)
 	err = h.handleFleetServerHosts(ctx, c)
 	if err != nil {
 		return err
 	}
 	if err := h.emitter(c); err != nil {
-		return err
+		return h.rollback(action, err)
 	}
+	h.metrics.applied(action.Policy)
+	h.previousMx.Lock()
+	h.previous = action
+	h.previousMx.Unlock()
+	h.updateStatus(state.Healthy, "")
 
 	return acker.Ack(ctx, action)
 }
 
+// AppliedRevision returns the ID and the revision of the last policy successfully applied, ok is
+// false when no policy with a revision was applied yet.
+func (h *PolicyChange) AppliedRevision() (policyID string, revision int64, ok bool) {
+	h.previousMx.RLock()
+	defer h.previousMx.RUnlock()
+
+	if h.previous == nil {
+		return "", 0, false
+	}
+	return h.previous.Revision()
+}
+
+// rollback applies the previous policy again after the policy of the action failed to be applied,
+// the agent is reported degraded with the error of the failed policy until a policy is applied.
+func (h *PolicyChange) rollback(action *fleetapi.ActionPolicyChange, applyErr error) error {
+	if h.previous == nil {
+		h.updateStatus(state.Failed, fmt.Sprintf("policy of action '%s' failed to apply: %v", action.ActionID, applyErr))
+		return applyErr
+	}
+
+	h.log.Errorf("handlerPolicyChange: policy of action '%s' failed to apply, rolling back to the policy of action '%s', error: %v", action.ActionID, h.previous.ActionID, applyErr)
+	c, err := config.NewConfigFrom(h.previous.Policy)
+	if err == nil {
+		err = h.emitter(c)
+	}
+	if err != nil {
+		h.updateStatus(state.Failed, fmt.Sprintf("policy of action '%s' failed to apply and the rollback to the policy of action '%s' failed: %v", action.ActionID, h.previous.ActionID, err))
+		return errors.New(applyErr,
+			fmt.Sprintf("rollback to the policy of action '%s' failed: %v", h.previous.ActionID, err),
+			errors.TypeConfig,
+			errors.M("action_id", action.ActionID))
+	}
+
+	msg := fmt.Sprintf("policy of action '%s' failed to apply and was rolled back to the policy of action '%s': %v", action.ActionID, h.previous.ActionID, applyErr)
+	h.updateStatus(state.Degraded, msg)
+	return errors.New(pipeline.ErrRolledBack, msg, errors.TypeConfig,
+		errors.M("action_id", action.ActionID),
+		errors.M("rollback_action_id", h.previous.ActionID))
+}
+
+func (h *PolicyChange) updateStatus(s state.Status, message string) {
+	if h.reporter != nil {
+		h.reporter.Update(s, message, nil)
+	}
+}
+
+// handleDryRun renders the programs of the policy without applying it and acknowledges the action
+// with the rendered programs. The action is not persisted so the policy is not applied on restart.
+func (h *PolicyChange) handleDryRun(ctx context.Context, action *fleetapi.ActionPolicyChange, c *config.Config, acker store.FleetAcker) error {
+	if h.renderer == nil {
+		return errors.New("dry run of policies is not supported", errors.TypeUnexpected, errors.M("action_id", action.ActionID))
+	}
+
+	h.log.Infof("handlerPolicyChange: rendering policy of action '%s' without applying it", action.ActionID)
+	programsByOutput, err := h.renderer(c)
+	if err != nil {
+		return errors.New(err, "could not render the policy", errors.TypeConfig, errors.M("action_id", action.ActionID))
+	}
+
+	rendered := make(map[string]interface{}, len(programsByOutput))
+	for output, programs := range programsByOutput {
+		configs := make(map[string]interface{}, len(programs))
+		for _, p := range programs {
+			configs[p.Identifier()] = p.Configuration()
+		}
+		rendered[output] = configs
+	}
+
+	return acker.Ack(ctx, fleetapi.NewDryRunAction(action, rendered))
+}
+
+// verifyPolicy verifies the signature of the policy when a signing key is configured, the policy
+// of the action is replaced by the signed policy so only the verified content is applied.
+func (h *PolicyChange) verifyPolicy(action *fleetapi.ActionPolicyChange) error {
+	if h.config.Fleet == nil || h.config.Fleet.SigningKey == "" {
+		return nil
+	}
+
+	if action.Signed == nil {
+		return errors.New("policy is not signed", errors.TypeSecurity, errors.M("action_id", action.ActionID))
+	}
+
+	key, err := fleetapi.ParseSigningKey([]byte(h.config.Fleet.SigningKey))
+	if err != nil {
+		return err
+	}
+
+	data, err := action.Signed.Verify(key)
+	if err != nil {
+		return errors.New(err, "could not verify the policy signature", errors.TypeSecurity, errors.M("action_id", action.ActionID))
+	}
+
+	var policy map[string]interface{}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return errors.New(err, "could not decode the signed policy", errors.TypeConfig, errors.M("action_id", action.ActionID))
+	}
+	action.Policy = policy
+	return nil
+}
+
 func (h *PolicyChange) handleFleetServerHosts(ctx context.Context, c *config.Config) (err error) {
 	// do not update fleet-server host from policy; no setters provided with local Fleet Server
 	if len(h.setters) == 0 {
@@ -6,12 +6,22 @@ package handlers
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"sync"
 	"testing"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +29,7 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	noopacker "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/noop"
 )
@@ -154,6 +165,286 @@ func TestPolicyAcked(t *testing.T) {
 	})
 }
 
+func TestPolicySignature(t *testing.T) {
+	log, _ := logger.New("", false)
+	agentInfo, _ := info.NewAgentInfo(true)
+	nullStore := &storage.NullStore{}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	signingKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	sign := func(data []byte) *fleetapi.Signed {
+		digest := sha256.Sum256(data)
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		return &fleetapi.Signed{
+			Data:      base64.StdEncoding.EncodeToString(data),
+			Signature: base64.StdEncoding.EncodeToString(signature),
+		}
+	}
+
+	newHandler := func(emitter *mockEmitter) *PolicyChange {
+		cfg := configuration.DefaultConfiguration()
+		cfg.Fleet.SigningKey = signingKey
+		return &PolicyChange{
+			log:       log,
+			emitter:   emitter.Emitter,
+			agentInfo: agentInfo,
+			config:    cfg,
+			store:     nullStore,
+		}
+	}
+
+	t.Run("signed policy is applied instead of the unsigned policy", func(t *testing.T) {
+		tacker := &testAcker{}
+		emitter := &mockEmitter{}
+
+		action := &fleetapi.ActionPolicyChange{
+			ActionID:   "abc123",
+			ActionType: "POLICY_CHANGE",
+			Policy:     map[string]interface{}{"hello": "unsigned"},
+			Signed:     sign([]byte(`{"hello":"world"}`)),
+		}
+
+		err := newHandler(emitter).Handle(context.Background(), action, tacker)
+		require.NoError(t, err)
+		require.Equal(t, config.MustNewConfigFrom(map[string]interface{}{"hello": "world"}), emitter.policy)
+		assert.Equal(t, []string{"abc123"}, tacker.Items())
+	})
+
+	t.Run("unsigned policy is rejected", func(t *testing.T) {
+		tacker := &testAcker{}
+		emitter := &mockEmitter{}
+
+		action := &fleetapi.ActionPolicyChange{
+			ActionID:   "abc123",
+			ActionType: "POLICY_CHANGE",
+			Policy:     map[string]interface{}{"hello": "world"},
+		}
+
+		err := newHandler(emitter).Handle(context.Background(), action, tacker)
+		require.Error(t, err)
+		assert.Nil(t, emitter.policy)
+		assert.Empty(t, tacker.Items())
+	})
+
+	t.Run("policy with an invalid signature is rejected", func(t *testing.T) {
+		tacker := &testAcker{}
+		emitter := &mockEmitter{}
+
+		signed := sign([]byte(`{"hello":"world"}`))
+		signed.Data = base64.StdEncoding.EncodeToString([]byte(`{"hello":"tampered"}`))
+		action := &fleetapi.ActionPolicyChange{
+			ActionID:   "abc123",
+			ActionType: "POLICY_CHANGE",
+			Signed:     signed,
+		}
+
+		err := newHandler(emitter).Handle(context.Background(), action, tacker)
+		require.True(t, errors.Is(err, fleetapi.ErrInvalidSignature))
+		assert.Nil(t, emitter.policy)
+		assert.Empty(t, tacker.Items())
+	})
+}
+
+func TestPolicyDryRun(t *testing.T) {
+	log, _ := logger.New("", false)
+	agentInfo, _ := info.NewAgentInfo(true)
+	nullStore := &storage.NullStore{}
+
+	renderer := func(c *config.Config) (map[string][]program.Program, error) {
+		ast, err := transpiler.NewAST(map[string]interface{}{"hello": "world"})
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]program.Program{
+			"default": {{Spec: program.Spec{Name: "Filebeat", Cmd: "filebeat"}, Config: ast}},
+		}, nil
+	}
+
+	newHandler := func(emitter *mockEmitter, dryRun bool) *PolicyChange {
+		cfg := configuration.DefaultConfiguration()
+		cfg.Fleet.PolicyDryRun = dryRun
+		h := &PolicyChange{
+			log:       log,
+			emitter:   emitter.Emitter,
+			agentInfo: agentInfo,
+			config:    cfg,
+			store:     nullStore,
+		}
+		h.SetRenderer(renderer)
+		return h
+	}
+
+	for name, tc := range map[string]struct {
+		actionDryRun bool
+		configDryRun bool
+	}{
+		"requested by the action":  {actionDryRun: true},
+		"enabled by configuration": {configDryRun: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			acker := &actionsAcker{}
+			emitter := &mockEmitter{}
+
+			action := &fleetapi.ActionPolicyChange{
+				ActionID:   "abc123",
+				ActionType: "POLICY_CHANGE",
+				Policy:     map[string]interface{}{"hello": "world"},
+				DryRun:     tc.actionDryRun,
+			}
+
+			err := newHandler(emitter, tc.configDryRun).Handle(context.Background(), action, acker)
+			require.NoError(t, err)
+			assert.Nil(t, emitter.policy)
+
+			require.Len(t, acker.acked, 1)
+			dryRun, ok := acker.acked[0].(*fleetapi.DryRunAction)
+			require.True(t, ok)
+			assert.Equal(t, "abc123", dryRun.ID())
+			assert.Equal(t, map[string]interface{}{
+				"default": map[string]interface{}{
+					"filebeat": map[string]interface{}{"hello": "world"},
+				},
+			}, dryRun.Programs)
+		})
+	}
+
+	t.Run("dry run fails without a renderer", func(t *testing.T) {
+		acker := &actionsAcker{}
+		emitter := &mockEmitter{}
+		h := newHandler(emitter, true)
+		h.SetRenderer(nil)
+
+		action := &fleetapi.ActionPolicyChange{
+			ActionID:   "abc123",
+			ActionType: "POLICY_CHANGE",
+			Policy:     map[string]interface{}{"hello": "world"},
+		}
+
+		require.Error(t, h.Handle(context.Background(), action, acker))
+		assert.Nil(t, emitter.policy)
+		assert.Empty(t, acker.acked)
+	})
+}
+
+func TestPolicyRollback(t *testing.T) {
+	log, _ := logger.New("", false)
+	agentInfo, _ := info.NewAgentInfo(true)
+	nullStore := &storage.NullStore{}
+
+	newHandler := func(emitter pipeline.EmitterFunc, reporter *testReporter) *PolicyChange {
+		h := &PolicyChange{
+			log:       log,
+			emitter:   emitter,
+			agentInfo: agentInfo,
+			config:    configuration.DefaultConfiguration(),
+			store:     nullStore,
+		}
+		h.SetStatusReporter(reporter)
+		return h
+	}
+
+	policyAction := func(id, value string) *fleetapi.ActionPolicyChange {
+		return &fleetapi.ActionPolicyChange{
+			ActionID:   id,
+			ActionType: "POLICY_CHANGE",
+			Policy:     map[string]interface{}{"hello": value},
+		}
+	}
+
+	t.Run("failed policy is rolled back to the previous policy", func(t *testing.T) {
+		var emitted []*config.Config
+		emitter := func(c *config.Config) error {
+			emitted = append(emitted, c)
+			if len(emitted) == 2 {
+				return errors.New("filebeat failed to start")
+			}
+			return nil
+		}
+		reporter := &testReporter{}
+		h := newHandler(emitter, reporter)
+		acker := &actionsAcker{}
+
+		require.NoError(t, h.Handle(context.Background(), policyAction("policy-1", "world"), acker))
+		assert.Equal(t, state.Healthy, reporter.status)
+
+		err := h.Handle(context.Background(), policyAction("policy-2", "broken"), acker)
+		require.True(t, errors.Is(err, pipeline.ErrRolledBack))
+		assert.Contains(t, err.Error(), "filebeat failed to start")
+
+		require.Len(t, emitted, 3)
+		assert.Equal(t, config.MustNewConfigFrom(map[string]interface{}{"hello": "world"}), emitted[2])
+		assert.Equal(t, state.Degraded, reporter.status)
+		assert.Contains(t, reporter.message, "rolled back to the policy of action 'policy-1'")
+		assert.Contains(t, reporter.message, "filebeat failed to start")
+		require.Len(t, acker.acked, 1)
+
+		// the next policy applied clears the degraded status.
+		require.NoError(t, h.Handle(context.Background(), policyAction("policy-3", "world"), acker))
+		assert.Equal(t, state.Healthy, reporter.status)
+	})
+
+	t.Run("failed policy without a previous policy", func(t *testing.T) {
+		emitter := &mockEmitter{err: errors.New("filebeat failed to start")}
+		reporter := &testReporter{}
+		h := newHandler(emitter.Emitter, reporter)
+
+		err := h.Handle(context.Background(), policyAction("policy-1", "world"), &actionsAcker{})
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, pipeline.ErrRolledBack))
+		assert.Equal(t, state.Failed, reporter.status)
+	})
+
+	t.Run("failed rollback", func(t *testing.T) {
+		calls := 0
+		emitter := func(c *config.Config) error {
+			calls++
+			if calls > 1 {
+				return errors.New("filebeat failed to start")
+			}
+			return nil
+		}
+		reporter := &testReporter{}
+		h := newHandler(emitter, reporter)
+
+		require.NoError(t, h.Handle(context.Background(), policyAction("policy-1", "world"), &actionsAcker{}))
+		err := h.Handle(context.Background(), policyAction("policy-2", "broken"), &actionsAcker{})
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, pipeline.ErrRolledBack))
+		assert.Equal(t, state.Failed, reporter.status)
+	})
+}
+
+type testReporter struct {
+	status  state.Status
+	message string
+}
+
+func (r *testReporter) Update(s state.Status, message string, _ map[string]interface{}) {
+	r.status = s
+	r.message = message
+}
+
+func (r *testReporter) Unregister() {}
+
+// actionsAcker keeps the acknowledged actions.
+type actionsAcker struct {
+	acked []fleetapi.Action
+}
+
+func (a *actionsAcker) Ack(_ context.Context, action fleetapi.Action) error {
+	a.acked = append(a.acked, action)
+	return nil
+}
+
+func (a *actionsAcker) Commit(_ context.Context) error {
+	return nil
+}
+
 type testAcker struct {
 	acked     []string
 	ackedLock sync.Mutex
@@ -187,3 +478,46 @@ func (t *testAcker) Items() []string {
 	defer t.ackedLock.Unlock()
 	return t.acked
 }
+
+func TestPolicyAppliedRevision(t *testing.T) {
+	log, _ := logger.New("", false)
+	agentInfo, _ := info.NewAgentInfo(true)
+
+	emitter := &mockEmitter{}
+	h := &PolicyChange{
+		log:       log,
+		emitter:   emitter.Emitter,
+		agentInfo: agentInfo,
+		config:    configuration.DefaultConfiguration(),
+		store:     &storage.NullStore{},
+	}
+
+	_, _, ok := h.AppliedRevision()
+	assert.False(t, ok)
+
+	action := &fleetapi.ActionPolicyChange{
+		ActionID:   "action-1",
+		ActionType: "POLICY_CHANGE",
+		Policy:     map[string]interface{}{"id": "policy-1", "revision": float64(3)},
+	}
+	require.NoError(t, h.Handle(context.Background(), action, &actionsAcker{}))
+
+	policyID, revision, ok := h.AppliedRevision()
+	require.True(t, ok)
+	assert.Equal(t, "policy-1", policyID)
+	assert.Equal(t, int64(3), revision)
+
+	t.Run("failed policy does not change the applied revision", func(t *testing.T) {
+		emitter.err = errors.New("filebeat failed to start")
+		action := &fleetapi.ActionPolicyChange{
+			ActionID:   "action-2",
+			ActionType: "POLICY_CHANGE",
+			Policy:     map[string]interface{}{"id": "policy-1", "revision": float64(4)},
+		}
+		require.Error(t, h.Handle(context.Background(), action, &actionsAcker{}))
+
+		_, revision, ok := h.AppliedRevision()
+		require.True(t, ok)
+		assert.Equal(t, int64(3), revision)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/reexec"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
@@ -20,11 +21,20 @@ type reexecManager interface {
 	ReExec(cb reexec.ShutdownCallbackFn, argOverrides ...string)
 }
 
-// Settings handles settings change coming from fleet and updates log level.
+// agentSettingsApplier applies the monitoring, reporting and gateway settings of the agent.
+type agentSettingsApplier interface {
+	Apply(fleetapi.AgentSettings) error
+}
+
+// Settings handles settings change coming from fleet and updates log level without restarting
+// the agent, the processes are restarted only when the action asks for it. The monitoring,
+// reporting and gateway settings are applied live by the agent settings applier.
 type Settings struct {
-	log       *logger.Logger
-	reexec    reexecManager
-	agentInfo *info.AgentInfo
+	log        *logger.Logger
+	reexec     reexecManager
+	agentInfo  *info.AgentInfo
+	setLevelFn func(logp.Level)
+	applier    agentSettingsApplier
 }
 
 // NewSettings creates a new Settings handler.
@@ -34,12 +44,19 @@ func NewSettings(
 	agentInfo *info.AgentInfo,
 ) *Settings {
 	return &Settings{
-		log:       log,
-		reexec:    reexec,
-		agentInfo: agentInfo,
+		log:        log,
+		reexec:     reexec,
+		agentInfo:  agentInfo,
+		setLevelFn: logger.SetLevel,
 	}
 }
 
+// SetAgentSettings sets the applier of the monitoring, reporting and gateway settings, the actions
+// changing them are rejected when it is not set.
+func (h *Settings) SetAgentSettings(applier agentSettingsApplier) {
+	h.applier = applier
+}
+
 // Handle handles SETTINGS action.
 func (h *Settings) Handle(ctx context.Context, a fleetapi.Action, acker store.FleetAcker) error {
 	h.log.Debugf("handlerUpgrade: action '%+v' received", a)
@@ -48,12 +65,31 @@ func (h *Settings) Handle(ctx context.Context, a fleetapi.Action, acker store.Fl
 		return fmt.Errorf("invalid type, expected ActionSettings and received %T", a)
 	}
 
-	if !isSupportedLogLevel(action.LogLevel) {
+	// the log level is optional when the action changes other settings.
+	var level logp.Level
+	changeLevel := action.LogLevel != "" || action.AgentSettings.IsZero()
+	if changeLevel && (!isSupportedLogLevel(action.LogLevel) || level.Unpack(action.LogLevel) != nil) {
 		return fmt.Errorf("invalid log level, expected debug|info|warning|error and received '%s'", action.LogLevel)
 	}
 
-	if err := h.agentInfo.SetLogLevel(action.LogLevel); err != nil {
-		return errors.New("failed to update log level", err)
+	if !action.AgentSettings.IsZero() {
+		if h.applier == nil {
+			return fmt.Errorf("monitoring, reporting and gateway settings are not supported by this agent")
+		}
+		if err := h.applier.Apply(action.AgentSettings); err != nil {
+			return errors.New(err, "failed to apply the settings of the agent", errors.M("action_id", action.ActionID))
+		}
+		h.log.Infof("Settings of the agent changed by action with id '%s'", action.ActionID)
+	}
+
+	if changeLevel {
+		if err := h.agentInfo.SetLogLevel(action.LogLevel); err != nil {
+			return errors.New("failed to update log level", err)
+		}
+
+		// the level persisted above is used on the next start, the agent applies it right away.
+		h.setLevelFn(level)
+		h.log.Infof("Log level changed to '%s' by action with id '%s'", action.LogLevel, action.ActionID)
 	}
 
 	if err := acker.Ack(ctx, a); err != nil {
@@ -62,7 +98,10 @@ func (h *Settings) Handle(ctx context.Context, a fleetapi.Action, acker store.Fl
 		h.log.Errorf("failed to commit acker after acknowledging action with id '%s'", action.ActionID)
 	}
 
-	h.reexec.ReExec(nil)
+	// the processes read their log level when they start.
+	if action.ApplyToProcesses {
+		h.reexec.ReExec(nil)
+	}
 	return nil
 }
 
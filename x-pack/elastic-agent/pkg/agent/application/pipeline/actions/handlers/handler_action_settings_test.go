@@ -0,0 +1,127 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/reexec"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+type testReexec struct {
+	calls int
+}
+
+func (r *testReexec) ReExec(_ reexec.ShutdownCallbackFn, _ ...string) {
+	r.calls++
+}
+
+func TestSettings(t *testing.T) {
+	top, cfgPath := paths.Top(), paths.Config()
+	defer func() {
+		paths.SetTop(top)
+		paths.SetConfig(cfgPath)
+	}()
+	paths.SetTop(t.TempDir())
+	paths.SetConfig(t.TempDir())
+
+	log, _ := logger.New("", false)
+	agentInfo, err := info.NewAgentInfo(true)
+	require.NoError(t, err)
+
+	// the level applied to the agent, nil when unchanged.
+	var applied *logp.Level
+	newHandler := func() (*Settings, *testReexec) {
+		applied = nil
+		r := &testReexec{}
+		h := NewSettings(log, r, agentInfo)
+		h.setLevelFn = func(l logp.Level) { applied = &l }
+		return h, r
+	}
+
+	t.Run("agent level changed without restart", func(t *testing.T) {
+		h, r := newHandler()
+		acker := &actionsAcker{}
+		action := &fleetapi.ActionSettings{ActionID: "abc123", ActionType: fleetapi.ActionTypeSettings, LogLevel: "debug"}
+		require.NoError(t, h.Handle(context.Background(), action, acker))
+
+		require.NotNil(t, applied)
+		require.Equal(t, logp.DebugLevel, *applied)
+		require.Equal(t, "debug", agentInfo.LogLevel())
+		require.Equal(t, 0, r.calls)
+		require.Len(t, acker.acked, 1)
+	})
+
+	t.Run("processes restarted", func(t *testing.T) {
+		h, r := newHandler()
+		acker := &actionsAcker{}
+		action := &fleetapi.ActionSettings{ActionID: "abc124", ActionType: fleetapi.ActionTypeSettings, LogLevel: "warning", ApplyToProcesses: true}
+		require.NoError(t, h.Handle(context.Background(), action, acker))
+
+		require.NotNil(t, applied)
+		require.Equal(t, logp.WarnLevel, *applied)
+		require.Equal(t, 1, r.calls)
+		require.Len(t, acker.acked, 1)
+	})
+
+	t.Run("agent settings applied without changing the level", func(t *testing.T) {
+		h, r := newHandler()
+		applier := &agentSettingsRecorder{}
+		h.SetAgentSettings(applier)
+		acker := &actionsAcker{}
+		disabled := false
+		action := &fleetapi.ActionSettings{ActionID: "abc126", ActionType: fleetapi.ActionTypeSettings, AgentSettings: fleetapi.AgentSettings{
+			Monitoring: &fleetapi.MonitoringSettings{Enabled: &disabled},
+		}}
+		require.NoError(t, h.Handle(context.Background(), action, acker))
+
+		require.Len(t, applier.applied, 1)
+		require.Equal(t, action.AgentSettings, applier.applied[0])
+		require.Nil(t, applier.applied[0].Reporting)
+		require.Nil(t, applied)
+		require.Equal(t, 0, r.calls)
+		require.Len(t, acker.acked, 1)
+	})
+
+	t.Run("agent settings not supported", func(t *testing.T) {
+		h, _ := newHandler()
+		acker := &actionsAcker{}
+		action := &fleetapi.ActionSettings{ActionID: "abc127", ActionType: fleetapi.ActionTypeSettings, LogLevel: "info", AgentSettings: fleetapi.AgentSettings{
+			Reporting: &fleetapi.ReportingSettings{MinSeverity: "error"},
+		}}
+		require.Error(t, h.Handle(context.Background(), action, acker))
+
+		require.Nil(t, applied)
+		require.Empty(t, acker.acked)
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		h, r := newHandler()
+		acker := &actionsAcker{}
+		action := &fleetapi.ActionSettings{ActionID: "abc125", ActionType: fleetapi.ActionTypeSettings, LogLevel: "verbose"}
+		require.Error(t, h.Handle(context.Background(), action, acker))
+
+		require.Nil(t, applied)
+		require.Equal(t, 0, r.calls)
+		require.Empty(t, acker.acked)
+	})
+}
+
+type agentSettingsRecorder struct {
+	applied []fleetapi.AgentSettings
+}
+
+func (r *agentSettingsRecorder) Apply(s fleetapi.AgentSettings) error {
+	r.applied = append(r.applied, s)
+	return nil
+}
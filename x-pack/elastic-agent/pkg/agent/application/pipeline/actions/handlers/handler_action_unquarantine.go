@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+type releaser interface {
+	Release(program string) ([]string, error)
+}
+
+// Unquarantine handles the requests coming from fleet to start again the programs quarantined
+// after crashing too often.
+type Unquarantine struct {
+	log      *logger.Logger
+	routesFn func() *sorted.Set
+}
+
+// NewUnquarantine creates a new Unquarantine handler.
+func NewUnquarantine(log *logger.Logger, routesFn func() *sorted.Set) *Unquarantine {
+	return &Unquarantine{
+		log:      log,
+		routesFn: routesFn,
+	}
+}
+
+// Handle handles UNQUARANTINE action.
+func (h *Unquarantine) Handle(ctx context.Context, a fleetapi.Action, acker store.FleetAcker) error {
+	h.log.Debugf("handlerUnquarantine: action '%+v' received", a)
+	action, ok := a.(*fleetapi.ActionUnquarantine)
+	if !ok {
+		return fmt.Errorf("invalid type, expected ActionUnquarantine and received %T", a)
+	}
+
+	var released []string
+	routes := h.routesFn()
+	for _, rk := range routes.Keys() {
+		route, ok := routes.Get(rk)
+		if !ok {
+			continue
+		}
+		r, ok := route.(releaser)
+		if !ok {
+			continue
+		}
+
+		names, err := r.Release(action.Program)
+		released = append(released, names...)
+		if err != nil {
+			return errors.New(err, "fail to release the quarantined programs", errors.TypeApplication, errors.M("program", action.Program))
+		}
+	}
+
+	if len(released) == 0 {
+		h.log.Infof("No quarantined program to release for action '%s'", action.ActionID)
+	} else {
+		h.log.Infof("Released quarantined programs %s for action '%s'", strings.Join(released, ", "), action.ActionID)
+	}
+
+	if err := acker.Ack(ctx, action); err != nil {
+		return err
+	}
+	return acker.Commit(ctx)
+}
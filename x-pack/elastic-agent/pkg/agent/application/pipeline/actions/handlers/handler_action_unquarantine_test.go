@@ -0,0 +1,58 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+type quarantinedStream struct {
+	quarantined []string
+	requested   []string
+}
+
+func (s *quarantinedStream) Release(program string) ([]string, error) {
+	s.requested = append(s.requested, program)
+	var released, kept []string
+	for _, name := range s.quarantined {
+		if program == "" || name == program {
+			released = append(released, name)
+		} else {
+			kept = append(kept, name)
+		}
+	}
+	s.quarantined = kept
+	return released, nil
+}
+
+func TestUnquarantine(t *testing.T) {
+	log, _ := logger.New("", false)
+
+	routes := sorted.NewSet()
+	stream := &quarantinedStream{quarantined: []string{"filebeat", "metricbeat"}}
+	routes.Add("default", stream)
+	routes.Add("other", struct{}{})
+	h := NewUnquarantine(log, func() *sorted.Set { return routes })
+
+	acker := &actionsAcker{}
+	action := &fleetapi.ActionUnquarantine{ActionID: "abc123", ActionType: fleetapi.ActionTypeUnquarantine, Program: "filebeat"}
+	require.NoError(t, h.Handle(context.Background(), action, acker))
+	assert.Equal(t, []string{"metricbeat"}, stream.quarantined)
+	assert.Len(t, acker.acked, 1)
+
+	action = &fleetapi.ActionUnquarantine{ActionID: "abc124", ActionType: fleetapi.ActionTypeUnquarantine}
+	require.NoError(t, h.Handle(context.Background(), action, acker))
+	assert.Empty(t, stream.quarantined)
+	assert.Equal(t, []string{"filebeat", ""}, stream.requested)
+	assert.Len(t, acker.acked, 2)
+}
@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+// policyRegistryName is the name of the registry holding the applied policy in the stats namespace.
+const policyRegistryName = "fleet_policy"
+
+type policyMetrics struct {
+	id       *monitoring.String // ID of the last applied policy.
+	revision *monitoring.Int    // Revision of the last applied policy.
+}
+
+// policyRegistry returns an empty registry for the applied policy under the stats namespace,
+// metrics left by a previously created handler are discarded.
+func policyRegistry() *monitoring.Registry {
+	parent := monitoring.GetNamespace("stats").GetRegistry()
+	if parent.GetRegistry(policyRegistryName) != nil {
+		parent.Remove(policyRegistryName)
+	}
+	return parent.NewRegistry(policyRegistryName)
+}
+
+func newPolicyMetrics(reg *monitoring.Registry) *policyMetrics {
+	return &policyMetrics{
+		id:       monitoring.NewString(reg, "id"),
+		revision: monitoring.NewInt(reg, "revision"),
+	}
+}
+
+// applied records the ID and the revision of the applied policy.
+func (m *policyMetrics) applied(policy map[string]interface{}) {
+	if m == nil {
+		return
+	}
+
+	id, revision, ok := fleetapi.PolicyRevision(policy)
+	if id != "" {
+		m.id.Set(id)
+	}
+	if ok {
+		m.revision.Set(revision)
+	}
+}
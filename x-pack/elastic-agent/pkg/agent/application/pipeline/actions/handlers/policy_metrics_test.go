@@ -0,0 +1,26 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+)
+
+func TestPolicyMetrics(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	m := newPolicyMetrics(reg)
+
+	m.applied(map[string]interface{}{"id": "policy-1", "revision": uint64(3)})
+	assert.Equal(t, "policy-1", m.id.Get())
+	assert.Equal(t, int64(3), m.revision.Get())
+
+	// policies decoded from JSON hold numbers as float64.
+	m.applied(map[string]interface{}{"id": "policy-1", "revision": float64(4)})
+	assert.Equal(t, int64(4), m.revision.Get())
+}
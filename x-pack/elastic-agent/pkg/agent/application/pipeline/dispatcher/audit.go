@@ -0,0 +1,142 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+const (
+	auditMaxSize    = 10 * 1024 * 1024 // size of an audit file before it is rotated
+	auditMaxBackups = 7                // number of rotated audit files kept
+)
+
+const (
+	auditEventReceived   = "received"
+	auditEventDispatched = "dispatched"
+
+	auditResultSuccess   = "success"
+	auditResultFailed    = "failed"
+	auditResultDuplicate = "duplicate"
+	auditResultUnchanged = "unchanged"
+)
+
+// auditEntry is a line of the audit log.
+type auditEntry struct {
+	Timestamp  time.Time `json:"@timestamp"`
+	Event      string    `json:"event"`
+	ActionID   string    `json:"action_id"`
+	ActionType string    `json:"action_type"`
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   string    `json:"duration,omitempty"`
+}
+
+// AuditLog records the actions received from Fleet and the result of their dispatch, one JSON
+// document per line, in files separated from the logs of the agent. Entries are only appended.
+type AuditLog struct {
+	mx  sync.Mutex
+	log *logger.Logger
+	w   io.WriteCloser
+}
+
+// NewAuditLog opens the audit log, the files are rotated once they reach 10MB and the last 7
+// rotated files are kept.
+func NewAuditLog(log *logger.Logger, filename string) (*AuditLog, error) {
+	rotator, err := file.NewFileRotator(filename,
+		file.MaxSizeBytes(auditMaxSize),
+		file.MaxBackups(auditMaxBackups),
+		file.Permissions(0600),
+		file.RotateOnStartup(false),
+	)
+	if err != nil {
+		return nil, errors.New(err, "failed to open the audit log", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, filename))
+	}
+	return newAuditLog(log, rotator), nil
+}
+
+func newAuditLog(log *logger.Logger, w io.WriteCloser) *AuditLog {
+	return &AuditLog{log: log, w: w}
+}
+
+// Close closes the audit log.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.w.Close()
+}
+
+func (a *AuditLog) received(action fleetapi.Action) {
+	a.write(auditEntry{
+		Event:      auditEventReceived,
+		ActionID:   action.ID(),
+		ActionType: action.Type(),
+	})
+}
+
+func (a *AuditLog) duplicate(action fleetapi.Action) {
+	a.write(auditEntry{
+		Event:      auditEventDispatched,
+		ActionID:   action.ID(),
+		ActionType: action.Type(),
+		Result:     auditResultDuplicate,
+	})
+}
+
+func (a *AuditLog) unchanged(action fleetapi.Action) {
+	a.write(auditEntry{
+		Event:      auditEventDispatched,
+		ActionID:   action.ID(),
+		ActionType: action.Type(),
+		Result:     auditResultUnchanged,
+	})
+}
+
+func (a *AuditLog) dispatched(action fleetapi.Action, err error, duration time.Duration) {
+	entry := auditEntry{
+		Event:      auditEventDispatched,
+		ActionID:   action.ID(),
+		ActionType: action.Type(),
+		Result:     auditResultSuccess,
+		Duration:   duration.String(),
+	}
+	if err != nil {
+		entry.Result = auditResultFailed
+		entry.Error = err.Error()
+	}
+	a.write(entry)
+}
+
+// write appends the entry to the audit log, a nil audit log discards the entry.
+func (a *AuditLog) write(entry auditEntry) {
+	if a == nil {
+		return
+	}
+
+	entry.Timestamp = time.Now().UTC()
+	b, err := json.Marshal(entry)
+	if err != nil {
+		a.log.Errorf("failed to encode audit entry of action '%s', error: %v", entry.ActionID, err)
+		return
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if _, err := a.w.Write(append(b, '\n')); err != nil {
+		a.log.Errorf("failed to write audit entry of action '%s', error: %v", entry.ActionID, err)
+	}
+}
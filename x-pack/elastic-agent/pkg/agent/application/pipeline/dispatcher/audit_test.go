@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	log, _ := logger.New("", false)
+	audit, err := NewAuditLog(log, filepath.Join(dir, "audit", "elastic-agent-audit"))
+	require.NoError(t, err)
+
+	d, err := New(context.Background(), nil, &mockHandler{})
+	require.NoError(t, err)
+	d.SetProcessedStore(&mockProcessedStore{ids: map[string]bool{}})
+	d.SetAuditLog(audit)
+
+	d.Register(&mockAction{}, &mockHandler{})
+	d.Register(&mockActionOther{}, &mockHandler{err: errors.New("handler failed")})
+
+	require.NoError(t, d.Dispatch(&mockAcker{}, &mockAction{}))
+	require.Error(t, d.Dispatch(&mockAcker{}, &mockActionOther{}))
+	require.NoError(t, d.Dispatch(&mockAcker{}, &mockAction{}))
+	require.NoError(t, audit.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "audit", "elastic-agent-audit*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		require.False(t, entry.Timestamp.IsZero())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+
+	type result struct{ event, id, result, err string }
+	var results []result
+	for _, e := range entries {
+		results = append(results, result{e.Event, e.ActionID, e.Result, e.Error})
+	}
+	require.Equal(t, []result{
+		{auditEventReceived, "mockAction", "", ""},
+		{auditEventDispatched, "mockAction", auditResultSuccess, ""},
+		{auditEventReceived, "mockActionOther", "", ""},
+		{auditEventDispatched, "mockActionOther", auditResultFailed, "handler failed"},
+		{auditEventReceived, "mockAction", "", ""},
+		{auditEventDispatched, "mockAction", auditResultDuplicate, ""},
+	}, results)
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	var audit *AuditLog
+	audit.received(&mockAction{})
+	require.NoError(t, audit.Close())
+}
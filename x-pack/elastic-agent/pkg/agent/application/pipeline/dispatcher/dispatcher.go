@@ -8,26 +8,81 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline/actions"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/capabilities"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/systemd"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 )
 
 type actionHandlers map[string]actions.Handler
 
+// actionPriorities orders the dispatch of the actions received in the same batch, lower values
+// are dispatched first. Actions not listed share the lowest priority and keep their arrival order.
+var actionPriorities = map[string]int{
+	fleetapi.ActionTypeUnenroll:       0,
+	fleetapi.ActionTypeUpgrade:        1,
+	fleetapi.ActionTypeSettings:       2,
+	fleetapi.ActionTypePolicyReassign: 3,
+	fleetapi.ActionTypePolicyChange:   4,
+}
+
+const defaultActionPriority = 5
+
+// hungDispatchTimeout is the time after which the systemd watchdog considers a dispatch hung, it
+// leaves room for an upgrade to download and extract the new version.
+const hungDispatchTimeout = 30 * time.Minute
+
+// exclusiveActions are dispatched alone and before any other action, they change the state of the
+// whole agent and cannot run concurrently with other handlers.
+var exclusiveActions = map[string]bool{
+	fleetapi.ActionTypeUnenroll: true,
+	fleetapi.ActionTypeUpgrade:  true,
+}
+
+// processedStore keeps track of the actions already applied by the agent.
+type processedStore interface {
+	IsProcessed(id string) bool
+	MarkProcessed(id string)
+	Save() error
+}
+
+// appliedPolicy reports the revision of the policy applied by the agent.
+type appliedPolicy interface {
+	AppliedRevision() (policyID string, revision int64, ok bool)
+}
+
 // ActionDispatcher processes actions coming from fleet using registered set of handlers.
 type ActionDispatcher struct {
-	ctx      context.Context
-	log      *logger.Logger
-	handlers actionHandlers
-	def      actions.Handler
+	ctx       context.Context
+	log       *logger.Logger
+	handlers  actionHandlers
+	def       actions.Handler
+	processed processedStore
+	audit     *AuditLog
+	caps      capabilities.Capability
+	applied   appliedPolicy
+	probe     *systemd.Probe
+	metrics   *dispatcherMetrics
+
+	// middlewares are called around the handler of every executed action.
+	middlewares []Middleware
+
+	// slots limits the number of actions executed concurrently, nil when unlimited.
+	slots chan struct{}
 }
 
-// New creates a new action dispatcher.
+// New creates a new action dispatcher, the handlers registered with RegisterHandler are registered
+// on the dispatcher.
 func New(ctx context.Context, log *logger.Logger, def actions.Handler) (*ActionDispatcher, error) {
 	var err error
 	if log == nil {
@@ -41,12 +96,18 @@ func New(ctx context.Context, log *logger.Logger, def actions.Handler) (*ActionD
 		return nil, errors.New("missing default handler")
 	}
 
-	return &ActionDispatcher{
+	ad := &ActionDispatcher{
 		ctx:      ctx,
 		log:      log,
 		handlers: make(actionHandlers),
 		def:      def,
-	}, nil
+		probe:    systemd.NewProbe("action dispatcher"),
+		metrics:  newDispatcherMetrics(dispatcherRegistry()),
+	}
+	if err := ad.registerHandlers(); err != nil {
+		return nil, err
+	}
+	return ad, nil
 }
 
 // Register registers a new handler for action.
@@ -69,6 +130,58 @@ func (ad *ActionDispatcher) MustRegister(a fleetapi.Action, handler actions.Hand
 	}
 }
 
+// SetProcessedStore enables the deduplication of actions, actions already recorded in the store
+// are acknowledged again without being applied and successfully dispatched actions are recorded.
+func (ad *ActionDispatcher) SetProcessedStore(s processedStore) {
+	ad.processed = s
+}
+
+// SetAuditLog enables the audit of the actions, every received action and the result of its
+// dispatch are recorded in the audit log.
+func (ad *ActionDispatcher) SetAuditLog(a *AuditLog) {
+	ad.audit = a
+}
+
+// SetCapabilities restricts the actions which can be applied, an action blocked by the
+// capabilities is acknowledged as failed without being dispatched to its handler.
+func (ad *ActionDispatcher) SetCapabilities(caps capabilities.Capability) {
+	ad.caps = caps
+}
+
+// SetAppliedPolicy enables the skipping of the policy changes whose policy revision is already
+// applied, these actions are acknowledged without being dispatched to their handler.
+func (ad *ActionDispatcher) SetAppliedPolicy(p appliedPolicy) {
+	ad.applied = p
+}
+
+// Use appends middlewares to the chain called around the handler of every executed action, the
+// middlewares are called in the order they are added. Actions skipped as duplicates, unchanged
+// policies or blocked by the capabilities never reach the middlewares.
+func (ad *ActionDispatcher) Use(middlewares ...Middleware) {
+	ad.middlewares = append(ad.middlewares, middlewares...)
+}
+
+// AppliedRevision returns the ID and the revision of the policy applied by the agent, ok is false
+// when the revision is unknown.
+func (ad *ActionDispatcher) AppliedRevision() (policyID string, revision int64, ok bool) {
+	if ad.applied == nil {
+		return "", 0, false
+	}
+	return ad.applied.AppliedRevision()
+}
+
+// SetMaxConcurrent limits the number of actions executed concurrently, the actions exceeding the
+// limit wait for a running action to complete. A limit of 0 or less removes the limit.
+func (ad *ActionDispatcher) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		ad.slots = nil
+		ad.metrics.maxConcurrent.Set(0)
+		return
+	}
+	ad.slots = make(chan struct{}, n)
+	ad.metrics.maxConcurrent.Set(int64(n))
+}
+
 func (ad *ActionDispatcher) key(a fleetapi.Action) string {
 	return reflect.TypeOf(a).String()
 }
@@ -80,25 +193,181 @@ func (ad *ActionDispatcher) Dispatch(acker store.FleetAcker, actions ...fleetapi
 		return nil
 	}
 
+	defer ad.probe.Busy(hungDispatchTimeout)()
+
+	batch := ad.metrics.enqueue(len(actions))
+	defer batch.release()
+
 	ad.log.Debugf(
 		"Dispatch %d actions of types: %s",
 		len(actions),
 		strings.Join(detectTypes(actions), ", "),
 	)
+	for _, action := range actions {
+		ad.audit.received(action)
+	}
+
+	// handlers of different action types ack concurrently.
+	acker = &syncAcker{acker: acker}
+
+	var concurrent []fleetapi.Action
+	for _, action := range prioritize(actions) {
+		if !exclusiveActions[action.Type()] {
+			concurrent = append(concurrent, action)
+			continue
+		}
+
+		if err := ad.dispatchSequentially(acker, batch, action); err != nil {
+			return err
+		}
+	}
+
+	if err := ad.dispatchConcurrently(acker, batch, concurrent); err != nil {
+		return err
+	}
+
+	return acker.Commit(ad.ctx)
+}
 
+// dispatchSequentially dispatches the actions one after the other and stops at the first failure.
+func (ad *ActionDispatcher) dispatchSequentially(acker store.FleetAcker, batch *pendingBatch, actions ...fleetapi.Action) error {
 	for _, action := range actions {
 		if err := ad.ctx.Err(); err != nil {
 			return err
 		}
 
-		if err := ad.dispatchAction(action, acker); err != nil {
+		if ad.isDuplicate(action) {
+			ad.log.Infof("Action '%s' of type '%s' was already applied, acknowledging it again", action.ID(), action.Type())
+			ad.audit.duplicate(action)
+			if err := acker.Ack(ad.ctx, action); err != nil {
+				return err
+			}
+			batch.complete()
+			continue
+		}
+
+		if ad.isUnchangedPolicy(action) {
+			ad.log.Infof("Policy of action '%s' is already applied, acknowledging it without applying it again", action.ID())
+			ad.audit.unchanged(action)
+			if err := acker.Ack(ad.ctx, action); err != nil {
+				return err
+			}
+			ad.markProcessed(action)
+			batch.complete()
+			continue
+		}
+
+		started := time.Now()
+		if ad.isBlocked(action) {
+			err := errors.New(fmt.Sprintf("action of type '%s' is blocked by the capabilities of the agent", action.Type()), errors.TypeConfig)
+			ad.logResult(action, err, 0)
+			ad.audit.dispatched(action, err, 0)
+			ad.reportFailure(acker, action, err, started, started)
+			batch.complete()
+			continue
+		}
+
+		err := ad.executeAction(action, acker)
+		completed := time.Now()
+		batch.complete()
+		ad.logResult(action, err, completed.Sub(started))
+		ad.audit.dispatched(action, err, completed.Sub(started))
+		if err != nil {
 			ad.log.Debugf("Failed to dispatch action '%+v', error: %+v", action, err)
+			ad.reportFailure(acker, action, err, started, completed)
 			return err
 		}
 		ad.log.Debugf("Successfully dispatched action: '%+v'", action)
+		ad.markProcessed(action)
 	}
 
-	return acker.Commit(ad.ctx)
+	return nil
+}
+
+// dispatchConcurrently dispatches actions of different types concurrently, actions of the same
+// type are dispatched sequentially in the order they were received. A failure only stops the
+// dispatch of the actions of the same type.
+func (ad *ActionDispatcher) dispatchConcurrently(acker store.FleetAcker, batch *pendingBatch, actions []fleetapi.Action) error {
+	var order []string
+	byType := make(map[string][]fleetapi.Action)
+	for _, action := range actions {
+		t := action.Type()
+		if _, ok := byType[t]; !ok {
+			order = append(order, t)
+		}
+		byType[t] = append(byType[t], action)
+	}
+
+	if len(order) == 1 {
+		return ad.dispatchSequentially(acker, batch, byType[order[0]]...)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(order))
+	for i, t := range order {
+		wg.Add(1)
+		go func(i int, actions []fleetapi.Action) {
+			defer wg.Done()
+			errs[i] = ad.dispatchSequentially(acker, batch, actions...)
+		}(i, byType[t])
+	}
+	wg.Wait()
+
+	var merr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if merr == nil {
+			merr = err
+			continue
+		}
+		merr = multierror.Append(merr, err)
+	}
+	return merr
+}
+
+// executeAction dispatches the action to its handler once an execution slot is available.
+func (ad *ActionDispatcher) executeAction(a fleetapi.Action, acker store.FleetAcker) error {
+	release, err := ad.acquireSlot(a)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(ad.middlewares) == 0 {
+		return ad.dispatchAction(a, acker)
+	}
+	return chain(ad.middlewares, ad.dispatchAction)(a, acker)
+}
+
+// acquireSlot waits for a free execution slot, the returned function frees the slot.
+func (ad *ActionDispatcher) acquireSlot(a fleetapi.Action) (func(), error) {
+	slots := ad.slots
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			ad.log.Debugf("Action '%s' of type '%s' waits for one of the %d running actions to complete", a.ID(), a.Type(), cap(slots))
+			ad.metrics.throttled.Inc()
+			ad.metrics.waiting.Inc()
+			select {
+			case slots <- struct{}{}:
+				ad.metrics.waiting.Dec()
+			case <-ad.ctx.Done():
+				ad.metrics.waiting.Dec()
+				return nil, ad.ctx.Err()
+			}
+		}
+	}
+
+	ad.metrics.running.Inc()
+	return func() {
+		ad.metrics.running.Dec()
+		if slots != nil {
+			<-slots
+		}
+	}, nil
 }
 
 func (ad *ActionDispatcher) dispatchAction(a fleetapi.Action, acker store.FleetAcker) error {
@@ -110,6 +379,114 @@ func (ad *ActionDispatcher) dispatchAction(a fleetapi.Action, acker store.FleetA
 	return handler.Handle(ad.ctx, a, acker)
 }
 
+// logResult logs the outcome of the dispatch of an action with its duration.
+func (ad *ActionDispatcher) logResult(a fleetapi.Action, err error, duration time.Duration) {
+	log := ad.log.With(
+		"action_id", a.ID(),
+		"action_type", a.Type(),
+		"duration", duration.String(),
+	)
+	if err != nil {
+		log.With("status", "failed", "error", err.Error()).Errorf("Action '%s' failed", a.ID())
+		return
+	}
+	log.With("status", "success").Infof("Action '%s' applied", a.ID())
+}
+
+// isDuplicate returns true when the action was already applied, actions without an ID are
+// detected locally and are never considered duplicates.
+func (ad *ActionDispatcher) isDuplicate(a fleetapi.Action) bool {
+	return ad.processed != nil && a.ID() != "" && ad.processed.IsProcessed(a.ID())
+}
+
+// isUnchangedPolicy returns true when the action changes the policy to the revision already
+// applied, policies without a revision and dry runs are always dispatched.
+func (ad *ActionDispatcher) isUnchangedPolicy(a fleetapi.Action) bool {
+	if ad.applied == nil {
+		return false
+	}
+
+	policy, ok := a.(*fleetapi.ActionPolicyChange)
+	if !ok || policy.DryRun {
+		return false
+	}
+
+	policyID, revision, ok := policy.Revision()
+	if !ok {
+		return false
+	}
+	appliedID, appliedRevision, ok := ad.applied.AppliedRevision()
+	return ok && policyID == appliedID && revision == appliedRevision
+}
+
+func (ad *ActionDispatcher) isBlocked(a fleetapi.Action) bool {
+	if ad.caps == nil {
+		return false
+	}
+	_, err := ad.caps.Apply(a)
+	return err == capabilities.ErrBlocked
+}
+
+func (ad *ActionDispatcher) markProcessed(a fleetapi.Action) {
+	if ad.processed == nil || a.ID() == "" {
+		return
+	}
+
+	ad.processed.MarkProcessed(a.ID())
+	if err := ad.processed.Save(); err != nil {
+		ad.log.Errorf("failed to persist processed action '%s', error: %v", a.ID(), err)
+	}
+}
+
+// reportFailure acknowledges the failed action with the error of the handler and commits the
+// acks accumulated so far, this allows Fleet to surface why an action was not executed.
+func (ad *ActionDispatcher) reportFailure(acker store.FleetAcker, a fleetapi.Action, err error, started, completed time.Time) {
+	if ackErr := acker.Ack(ad.ctx, fleetapi.NewFailedAction(a, err, started, completed)); ackErr != nil {
+		ad.log.Errorf("failed to acknowledge failed action '%s', error: %v", a.ID(), ackErr)
+		return
+	}
+
+	if commitErr := acker.Commit(ad.ctx); commitErr != nil {
+		ad.log.Errorf("failed to commit acknowledgment of failed action '%s', error: %v", a.ID(), commitErr)
+	}
+}
+
+// prioritize returns a copy of the actions sorted by priority, actions with the same priority keep
+// the order in which they were received.
+func prioritize(actions []fleetapi.Action) []fleetapi.Action {
+	sorted := make([]fleetapi.Action, len(actions))
+	copy(sorted, actions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return priority(sorted[i]) < priority(sorted[j])
+	})
+	return sorted
+}
+
+func priority(a fleetapi.Action) int {
+	if p, ok := actionPriorities[a.Type()]; ok {
+		return p
+	}
+	return defaultActionPriority
+}
+
+// syncAcker serializes the calls to an acker shared by handlers running concurrently.
+type syncAcker struct {
+	mx    sync.Mutex
+	acker store.FleetAcker
+}
+
+func (a *syncAcker) Ack(ctx context.Context, action fleetapi.Action) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.acker.Ack(ctx, action)
+}
+
+func (a *syncAcker) Commit(ctx context.Context) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.acker.Commit(ctx)
+}
+
 func detectTypes(actions []fleetapi.Action) []string {
 	str := make([]string, len(actions))
 	for idx, action := range actions {
@@ -6,11 +6,17 @@ package dispatcher
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline/actions"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/capabilities"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	noopacker "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/acker/noop"
 )
@@ -27,6 +33,79 @@ func (h *mockHandler) Handle(_ context.Context, a fleetapi.Action, acker store.F
 	return h.err
 }
 
+type orderHandler struct {
+	mx       sync.Mutex
+	received []string
+}
+
+func (h *orderHandler) Handle(_ context.Context, a fleetapi.Action, acker store.FleetAcker) error {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.received = append(h.received, a.ID())
+	return nil
+}
+
+type blockingHandler struct {
+	release chan struct{}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, a fleetapi.Action, acker store.FleetAcker) error {
+	select {
+	case <-h.release:
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.New("handler was not released")
+	}
+}
+
+type releasingHandler struct {
+	release chan struct{}
+}
+
+func (h *releasingHandler) Handle(_ context.Context, a fleetapi.Action, acker store.FleetAcker) error {
+	close(h.release)
+	return nil
+}
+
+type concurrencyHandler struct {
+	mx      sync.Mutex
+	running int
+	max     int
+}
+
+func (h *concurrencyHandler) Handle(_ context.Context, a fleetapi.Action, acker store.FleetAcker) error {
+	h.mx.Lock()
+	h.running++
+	if h.running > h.max {
+		h.max = h.running
+	}
+	h.mx.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	h.mx.Lock()
+	h.running--
+	h.mx.Unlock()
+	return nil
+}
+
+type mockAppliedPolicy struct {
+	policyID string
+	revision int64
+}
+
+func (m *mockAppliedPolicy) AppliedRevision() (string, int64, bool) {
+	return m.policyID, m.revision, m.policyID != ""
+}
+
+type mockProcessedStore struct {
+	ids map[string]bool
+}
+
+func (m *mockProcessedStore) IsProcessed(id string) bool { return m.ids[id] }
+func (m *mockProcessedStore) MarkProcessed(id string)    { m.ids[id] = true }
+func (m *mockProcessedStore) Save() error                { return nil }
+
 type mockAction struct{}
 
 func (m *mockAction) ID() string     { return "mockAction" }
@@ -45,6 +124,35 @@ func (m *mockActionOther) ID() string     { return "mockActionOther" }
 func (m *mockActionOther) Type() string   { return "mockActionOther" }
 func (m *mockActionOther) String() string { return "mockActionOther" }
 
+type mockAcker struct {
+	mx        sync.Mutex
+	acked     []fleetapi.Action
+	committed bool
+}
+
+func (m *mockAcker) Ack(_ context.Context, a fleetapi.Action) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.acked = append(m.acked, a)
+	return nil
+}
+
+func (m *mockAcker) Commit(_ context.Context) error {
+	m.committed = true
+	return nil
+}
+
+type mockCapability struct {
+	blocked string
+}
+
+func (m *mockCapability) Apply(in interface{}) (interface{}, error) {
+	if a, ok := in.(fleetapi.Action); ok && a.Type() == m.blocked {
+		return in, capabilities.ErrBlocked
+	}
+	return in, nil
+}
+
 func TestActionDispatcher(t *testing.T) {
 	ack := noopacker.NewAcker()
 
@@ -89,6 +197,244 @@ func TestActionDispatcher(t *testing.T) {
 		require.Equal(t, action, def.received)
 	})
 
+	t.Run("Failed action is acked with the error of the handler", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		handlerErr := errors.New("something is bad")
+		failing := &mockHandler{err: handlerErr}
+		success := &mockHandler{}
+		d.Register(&mockAction{}, failing)
+		d.Register(&mockActionOther{}, success)
+
+		acker := &mockAcker{}
+		err = d.Dispatch(acker, &mockAction{}, &mockAction{}, &mockActionOther{})
+		require.Equal(t, handlerErr, err)
+		// actions of other types are still dispatched.
+		require.True(t, success.called)
+
+		require.Len(t, acker.acked, 1)
+		failed, ok := acker.acked[0].(*fleetapi.FailedAction)
+		require.True(t, ok)
+		require.Equal(t, "mockAction", failed.ID())
+		require.Equal(t, handlerErr, failed.Err)
+		require.True(t, acker.committed)
+	})
+
+	t.Run("Blocked action is acked as failed without being dispatched", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+		d.SetCapabilities(&mockCapability{blocked: "mockAction"})
+
+		blocked := &mockHandler{}
+		success := &mockHandler{}
+		d.Register(&mockAction{}, blocked)
+		d.Register(&mockActionOther{}, success)
+
+		acker := &mockAcker{}
+		err = d.Dispatch(acker, &mockAction{}, &mockActionOther{})
+		require.NoError(t, err)
+		require.False(t, blocked.called)
+		require.True(t, success.called)
+
+		require.Len(t, acker.acked, 1)
+		failed, ok := acker.acked[0].(*fleetapi.FailedAction)
+		require.True(t, ok)
+		require.Equal(t, "mockAction", failed.ID())
+		require.Error(t, failed.Err)
+		require.True(t, acker.committed)
+	})
+
+	t.Run("Actions are dispatched by priority", func(t *testing.T) {
+		def := &orderHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		err = d.Dispatch(ack,
+			&fleetapi.ActionPolicyChange{ActionID: "policy-1", ActionType: fleetapi.ActionTypePolicyChange},
+			&mockAction{},
+			&fleetapi.ActionUpgrade{ActionID: "upgrade", ActionType: fleetapi.ActionTypeUpgrade},
+			&fleetapi.ActionPolicyChange{ActionID: "policy-2", ActionType: fleetapi.ActionTypePolicyChange},
+			&fleetapi.ActionUnenroll{ActionID: "unenroll", ActionType: fleetapi.ActionTypeUnenroll},
+		)
+		require.NoError(t, err)
+		require.Len(t, def.received, 5)
+		require.Equal(t, []string{"unenroll", "upgrade"}, def.received[:2])
+		// policy changes are serialized but they run concurrently with the other action types.
+		var policies []string
+		for _, id := range def.received[2:] {
+			if id != "mockAction" {
+				policies = append(policies, id)
+			}
+		}
+		require.Equal(t, []string{"policy-1", "policy-2"}, policies)
+	})
+
+	t.Run("Actions of different types are dispatched concurrently", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		release := make(chan struct{})
+		slow := &blockingHandler{release: release}
+		fast := &releasingHandler{release: release}
+		d.Register(&mockAction{}, slow)
+		d.Register(&mockActionOther{}, fast)
+
+		// the slow handler only returns once the handler of the other type ran.
+		err = d.Dispatch(ack, &mockAction{}, &mockActionOther{})
+		require.NoError(t, err)
+	})
+
+	t.Run("Concurrently executed actions are limited", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+		d.SetMaxConcurrent(1)
+
+		handler := &concurrencyHandler{}
+		d.Register(&mockAction{}, handler)
+		d.Register(&mockActionOther{}, handler)
+		d.Register(&mockActionUnknown{}, handler)
+
+		err = d.Dispatch(ack, &mockAction{}, &mockActionOther{}, &mockActionUnknown{})
+		require.NoError(t, err)
+		require.Equal(t, 1, handler.max)
+		require.Equal(t, int64(0), d.metrics.pending.Get())
+		require.Equal(t, int64(0), d.metrics.running.Get())
+		require.Equal(t, int64(0), d.metrics.waiting.Get())
+	})
+
+	t.Run("Pending actions are released when the dispatch fails", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		d.Register(&mockAction{}, &mockHandler{err: errors.New("something is bad")})
+
+		err = d.Dispatch(ack, &mockAction{}, &mockAction{}, &mockAction{})
+		require.Error(t, err)
+		require.Equal(t, int64(0), d.metrics.pending.Get())
+	})
+
+	t.Run("Actions already applied are acked without being dispatched", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+		d.SetProcessedStore(&mockProcessedStore{ids: map[string]bool{}})
+
+		handler := &mockHandler{}
+		d.Register(&mockAction{}, handler)
+
+		acker := &mockAcker{}
+		require.NoError(t, d.Dispatch(acker, &mockAction{}))
+		require.True(t, handler.called)
+		require.Len(t, acker.acked, 0)
+
+		handler.called = false
+		require.NoError(t, d.Dispatch(acker, &mockAction{}))
+		require.False(t, handler.called)
+		require.Len(t, acker.acked, 1)
+		require.Equal(t, "mockAction", acker.acked[0].ID())
+	})
+
+	t.Run("Policy changes to the applied revision are acked without being dispatched", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+		d.SetAppliedPolicy(&mockAppliedPolicy{policyID: "policy-1", revision: 3})
+
+		handler := &mockHandler{}
+		d.Register(&fleetapi.ActionPolicyChange{}, handler)
+
+		policyChange := func(id string, revision int) *fleetapi.ActionPolicyChange {
+			return &fleetapi.ActionPolicyChange{
+				ActionID:   id,
+				ActionType: fleetapi.ActionTypePolicyChange,
+				Policy:     map[string]interface{}{"id": "policy-1", "revision": revision},
+			}
+		}
+
+		acker := &mockAcker{}
+		require.NoError(t, d.Dispatch(acker, policyChange("action-1", 3)))
+		require.False(t, handler.called)
+		require.Len(t, acker.acked, 1)
+		require.Equal(t, "action-1", acker.acked[0].ID())
+
+		require.NoError(t, d.Dispatch(acker, policyChange("action-2", 4)))
+		require.True(t, handler.called)
+
+		policyID, revision, ok := d.AppliedRevision()
+		require.True(t, ok)
+		require.Equal(t, "policy-1", policyID)
+		require.Equal(t, int64(3), revision)
+	})
+
+	t.Run("Middlewares are called around the handler in the order they are added", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		success := &mockHandler{}
+		d.Register(&mockActionOther{}, success)
+
+		var calls []string
+		trace := func(name string) Middleware {
+			return func(a fleetapi.Action, acker store.FleetAcker, next Next) error {
+				calls = append(calls, name+" before "+a.ID())
+				err := next(a, acker)
+				calls = append(calls, name+" after "+a.ID())
+				return err
+			}
+		}
+		d.Use(trace("first"), trace("second"))
+
+		// the action received by the handler is replaced by the middleware.
+		replaced := &mockActionOther{}
+		d.Use(func(a fleetapi.Action, acker store.FleetAcker, next Next) error {
+			return next(replaced, acker)
+		})
+
+		err = d.Dispatch(ack, &mockAction{})
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"first before mockAction",
+			"second before mockAction",
+			"second after mockAction",
+			"first after mockAction",
+		}, calls)
+		require.False(t, def.called)
+		require.True(t, success.called)
+		require.Equal(t, replaced, success.received)
+	})
+
+	t.Run("Middleware can skip the handler", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		handler := &mockHandler{}
+		d.Register(&mockAction{}, handler)
+
+		middlewareErr := errors.New("dry run failed")
+		d.Use(func(a fleetapi.Action, acker store.FleetAcker, next Next) error {
+			return middlewareErr
+		})
+
+		acker := &mockAcker{}
+		err = d.Dispatch(acker, &mockAction{})
+		require.Equal(t, middlewareErr, err)
+		require.False(t, handler.called)
+
+		// the error of the middleware is reported like the error of a handler.
+		require.Len(t, acker.acked, 1)
+		failed, ok := acker.acked[0].(*fleetapi.FailedAction)
+		require.True(t, ok)
+		require.Equal(t, middlewareErr, failed.Err)
+	})
+
 	t.Run("Could not register two handlers on the same action", func(t *testing.T) {
 		success1 := &mockHandler{}
 		success2 := &mockHandler{}
@@ -104,3 +450,57 @@ func TestActionDispatcher(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+type mockActionRegistered struct{}
+
+func (m *mockActionRegistered) ID() string     { return "mockActionRegistered" }
+func (m *mockActionRegistered) Type() string   { return "mockActionRegistered" }
+func (m *mockActionRegistered) String() string { return "mockActionRegistered" }
+
+func TestRegisterHandler(t *testing.T) {
+	registered := &mockHandler{}
+	require.NoError(t, RegisterHandler(&mockActionRegistered{}, func(log *logger.Logger) (actions.Handler, error) {
+		return registered, nil
+	}))
+	defer func() {
+		handlerRegistry.Lock()
+		handlerRegistry.handlers = make(map[string]registeredHandler)
+		handlerRegistry.Unlock()
+	}()
+
+	t.Run("registered handlers are used by new dispatchers", func(t *testing.T) {
+		def := &mockHandler{}
+		d, err := New(context.Background(), nil, def)
+		require.NoError(t, err)
+
+		action := &mockActionRegistered{}
+		require.NoError(t, d.Dispatch(noopacker.NewAcker(), action))
+		require.True(t, registered.called)
+		require.Equal(t, action, registered.received)
+		require.False(t, def.called)
+
+		// the registered handler cannot be replaced by the dispatcher.
+		require.Error(t, d.Register(&mockActionRegistered{}, &mockHandler{}))
+	})
+
+	t.Run("handlers cannot be registered twice", func(t *testing.T) {
+		err := RegisterHandler(&mockActionRegistered{}, func(log *logger.Logger) (actions.Handler, error) {
+			return &mockHandler{}, nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("dispatcher creation fails when a handler cannot be created", func(t *testing.T) {
+		require.NoError(t, RegisterHandler(&mockActionUnknown{}, func(log *logger.Logger) (actions.Handler, error) {
+			return nil, errors.New("missing configuration")
+		}))
+		defer func() {
+			handlerRegistry.Lock()
+			delete(handlerRegistry.handlers, "*dispatcher.mockActionUnknown")
+			handlerRegistry.Unlock()
+		}()
+
+		_, err := New(context.Background(), nil, &mockHandler{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package dispatcher
+
+import (
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+)
+
+// metricsRegistryName is the name of the registry holding the dispatcher metrics in the stats namespace.
+const metricsRegistryName = "action_dispatcher"
+
+type dispatcherMetrics struct {
+	pending       *monitoring.Int  // Number of actions received and not yet completed, running actions included.
+	waiting       *monitoring.Int  // Number of actions waiting for a free execution slot.
+	running       *monitoring.Int  // Number of actions being executed by their handler.
+	throttled     *monitoring.Uint // Number of actions which had to wait for a free execution slot.
+	maxConcurrent *monitoring.Int  // Maximum number of actions executed concurrently, 0 when unlimited.
+}
+
+// dispatcherRegistry returns an empty registry for the dispatcher metrics under the stats
+// namespace, metrics left by a previously created dispatcher are discarded.
+func dispatcherRegistry() *monitoring.Registry {
+	parent := monitoring.GetNamespace("stats").GetRegistry()
+	if parent.GetRegistry(metricsRegistryName) != nil {
+		parent.Remove(metricsRegistryName)
+	}
+	return parent.NewRegistry(metricsRegistryName)
+}
+
+func newDispatcherMetrics(reg *monitoring.Registry) *dispatcherMetrics {
+	return &dispatcherMetrics{
+		pending:       monitoring.NewInt(reg, "actions_pending"),
+		waiting:       monitoring.NewInt(reg, "actions_waiting"),
+		running:       monitoring.NewInt(reg, "actions_running"),
+		throttled:     monitoring.NewUint(reg, "actions_throttled_total"),
+		maxConcurrent: monitoring.NewInt(reg, "max_concurrent"),
+	}
+}
+
+// enqueue records the actions of a dispatch as pending, the returned batch removes them from the
+// pending actions as they complete.
+func (m *dispatcherMetrics) enqueue(n int) *pendingBatch {
+	m.pending.Add(int64(n))
+	return &pendingBatch{metrics: m, remaining: int64(n)}
+}
+
+// pendingBatch tracks the actions of a single dispatch which are not completed yet.
+type pendingBatch struct {
+	metrics   *dispatcherMetrics
+	remaining int64
+}
+
+// complete removes a completed action from the pending actions.
+func (b *pendingBatch) complete() {
+	if atomic.AddInt64(&b.remaining, -1) >= 0 {
+		b.metrics.pending.Dec()
+	}
+}
+
+// release removes the actions which were never completed, like the actions following a failed
+// action, from the pending actions.
+func (b *pendingBatch) release() {
+	if remaining := atomic.SwapInt64(&b.remaining, 0); remaining > 0 {
+		b.metrics.pending.Sub(remaining)
+	}
+}
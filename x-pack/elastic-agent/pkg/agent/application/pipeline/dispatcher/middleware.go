@@ -0,0 +1,30 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package dispatcher
+
+import (
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+// Next executes the rest of the middleware chain and the handler of the action.
+type Next func(a fleetapi.Action, acker store.FleetAcker) error
+
+// Middleware is called around the execution of an action by its handler. A middleware can inspect
+// or replace the action and the acker before calling next, act on the error returned by next or
+// skip the handler by not calling next, in which case it is responsible for acknowledging the action.
+type Middleware func(a fleetapi.Action, acker store.FleetAcker, next Next) error
+
+// chain composes the middlewares around the handler, the first middleware is the outermost one.
+func chain(middlewares []Middleware, handler Next) Next {
+	next := handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		mw, inner := middlewares[i], next
+		next = func(a fleetapi.Action, acker store.FleetAcker) error {
+			return mw(a, acker, inner)
+		}
+	}
+	return next
+}
@@ -0,0 +1,70 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline/actions"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+// HandlerFactory creates the handler of the actions registered with RegisterHandler, it is called
+// by every new dispatcher.
+type HandlerFactory func(log *logger.Logger) (actions.Handler, error)
+
+type registeredHandler struct {
+	action  fleetapi.Action
+	factory HandlerFactory
+}
+
+var handlerRegistry = struct {
+	sync.Mutex
+	handlers map[string]registeredHandler
+}{handlers: make(map[string]registeredHandler)}
+
+// RegisterHandler registers the factory of the handler of the actions with the same Go type as
+// the given action, this is meant to be called at init time by the packages handling their own
+// actions, the type of the action is registered with fleetapi.RegisterActionType. The handlers are
+// registered on the dispatchers created afterward.
+func RegisterHandler(a fleetapi.Action, factory HandlerFactory) error {
+	k := reflect.TypeOf(a).String()
+
+	handlerRegistry.Lock()
+	defer handlerRegistry.Unlock()
+	if _, ok := handlerRegistry.handlers[k]; ok {
+		return fmt.Errorf("handler of action with type %T is already registered", a)
+	}
+	handlerRegistry.handlers[k] = registeredHandler{action: a, factory: factory}
+	return nil
+}
+
+// MustRegisterHandler registers the factory of the handler of an action.
+// Panics if not successful.
+func MustRegisterHandler(a fleetapi.Action, factory HandlerFactory) {
+	if err := RegisterHandler(a, factory); err != nil {
+		panic("could not register action handler, error: " + err.Error())
+	}
+}
+
+// registerHandlers registers on the dispatcher the handlers of the registry.
+func (ad *ActionDispatcher) registerHandlers() error {
+	handlerRegistry.Lock()
+	defer handlerRegistry.Unlock()
+
+	for _, r := range handlerRegistry.handlers {
+		handler, err := r.factory(ad.log)
+		if err != nil {
+			return fmt.Errorf("could not create the handler of action with type %T: %w", r.action, err)
+		}
+		if err := ad.Register(r.action, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -66,46 +66,79 @@ func NewController(
 
 // Update applies config change and performes all steps necessary to apply it.
 func (e *Controller) Update(c *config.Config) error {
-	if err := info.InjectAgentConfig(c); err != nil {
+	rawAst, err := e.prepare(c)
+	if err != nil {
 		return err
 	}
 
+	e.lock.Lock()
+	e.config = c
+	e.ast = rawAst
+	e.lock.Unlock()
+
+	return e.update()
+}
+
+// Render returns the programs the configuration would run with the current variables, the
+// configuration is validated like on Update but it is not applied.
+func (e *Controller) Render(c *config.Config) (map[pipeline.RoutingKey][]program.Program, error) {
+	_, programsToRun, err := e.RenderConfig(c)
+	return programsToRun, err
+}
+
+// RenderConfig renders the configuration like Render, it also returns the agent-level
+// configuration with the capabilities and the filters applied and the inputs rendered with the
+// current variables, the configuration from which the programs are created.
+func (e *Controller) RenderConfig(c *config.Config) (*transpiler.AST, map[pipeline.RoutingKey][]program.Program, error) {
+	rawAst, err := e.prepare(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.lock.RLock()
+	varsArray := e.vars
+	e.lock.RUnlock()
+
+	return e.render(rawAst, varsArray)
+}
+
+// prepare creates the AST of the configuration with the capabilities and the filters applied.
+func (e *Controller) prepare(c *config.Config) (*transpiler.AST, error) {
+	if err := info.InjectAgentConfig(c); err != nil {
+		return nil, err
+	}
+
 	// perform and verify ast translation
 	m, err := c.ToMapStr()
 	if err != nil {
-		return errors.New(err, "could not create the AST from the configuration", errors.TypeConfig)
+		return nil, errors.New(err, "could not create the AST from the configuration", errors.TypeConfig)
 	}
 
 	rawAst, err := transpiler.NewAST(m)
 	if err != nil {
-		return errors.New(err, "could not create the AST from the configuration", errors.TypeConfig)
+		return nil, errors.New(err, "could not create the AST from the configuration", errors.TypeConfig)
 	}
 
 	if e.caps != nil {
 		var ok bool
 		updatedAst, err := e.caps.Apply(rawAst)
 		if err != nil {
-			return errors.New(err, "failed to apply capabilities")
+			return nil, errors.New(err, "failed to apply capabilities")
 		}
 
 		rawAst, ok = updatedAst.(*transpiler.AST)
 		if !ok {
-			return errors.New("failed to transform object returned from capabilities to AST", errors.TypeConfig)
+			return nil, errors.New("failed to transform object returned from capabilities to AST", errors.TypeConfig)
 		}
 	}
 
 	for _, filter := range e.modifiers.Filters {
 		if err := filter(e.logger, rawAst); err != nil {
-			return errors.New(err, "failed to filter configuration", errors.TypeConfig)
+			return nil, errors.New(err, "failed to filter configuration", errors.TypeConfig)
 		}
 	}
 
-	e.lock.Lock()
-	e.config = c
-	e.ast = rawAst
-	e.lock.Unlock()
-
-	return e.update()
+	return rawAst, nil
 }
 
 // Set sets the transpiler vars for dynamic inputs resolution.
@@ -134,16 +167,33 @@ func (e *Controller) update() error {
 	varsArray := e.vars
 	e.lock.RUnlock()
 
+	ast, programsToRun, err := e.render(rawAst, varsArray)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range e.reloadables {
+		if err := r.Reload(cfg); err != nil {
+			return err
+		}
+	}
+
+	return e.router.Route(ast.HashStr(), programsToRun)
+}
+
+// render renders the inputs with the variables and converts the configuration into the programs
+// to run.
+func (e *Controller) render(rawAst *transpiler.AST, varsArray []*transpiler.Vars) (*transpiler.AST, map[pipeline.RoutingKey][]program.Program, error) {
 	ast := rawAst.Clone()
 	inputs, ok := transpiler.Lookup(ast, "inputs")
 	if ok {
 		renderedInputs, err := transpiler.RenderInputs(inputs, varsArray)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		err = transpiler.Insert(ast, renderedInputs, "inputs")
 		if err != nil {
-			return errors.New(err, "inserting rendered inputs failed")
+			return nil, nil, errors.New(err, "inserting rendered inputs failed")
 		}
 	}
 
@@ -151,23 +201,17 @@ func (e *Controller) update() error {
 
 	programsToRun, err := program.Programs(e.agentInfo, ast)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	for _, decorator := range e.modifiers.Decorators {
 		for outputType, ptr := range programsToRun {
 			programsToRun[outputType], err = decorator(e.agentInfo, outputType, ast, ptr)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 		}
 	}
 
-	for _, r := range e.reloadables {
-		if err := r.Reload(cfg); err != nil {
-			return err
-		}
-	}
-
-	return e.router.Route(ast.HashStr(), programsToRun)
+	return ast, programsToRun, nil
 }
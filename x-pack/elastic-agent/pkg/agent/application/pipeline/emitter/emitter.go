@@ -21,6 +21,13 @@ import (
 
 // New creates a new emitter function.
 func New(ctx context.Context, log *logger.Logger, agentInfo *info.AgentInfo, controller composable.Controller, router pipeline.Router, modifiers *pipeline.ConfigModifiers, caps capabilities.Capability, reloadables ...reloadable) (pipeline.EmitterFunc, error) {
+	emit, _, err := NewWithRenderer(ctx, log, agentInfo, controller, router, modifiers, caps, reloadables...)
+	return emit, err
+}
+
+// NewWithRenderer creates a new emitter function and a function rendering the programs of a
+// configuration with the same variables, capabilities and modifiers without applying it.
+func NewWithRenderer(ctx context.Context, log *logger.Logger, agentInfo *info.AgentInfo, controller composable.Controller, router pipeline.Router, modifiers *pipeline.ConfigModifiers, caps capabilities.Capability, reloadables ...reloadable) (pipeline.EmitterFunc, pipeline.RendererFunc, error) {
 	log.Debugf("Supported programs: %s", strings.Join(program.KnownProgramNames(), ", "))
 
 	ctrl := NewController(log, agentInfo, controller, router, modifiers, caps, reloadables...)
@@ -28,9 +35,9 @@ func New(ctx context.Context, log *logger.Logger, agentInfo *info.AgentInfo, con
 		ctrl.Set(vars)
 	})
 	if err != nil {
-		return nil, errors.New(err, "failed to start composable controller")
+		return nil, nil, errors.New(err, "failed to start composable controller")
 	}
 	return func(c *config.Config) error {
 		return ctrl.Update(c)
-	}, nil
+	}, ctrl.Render, nil
 }
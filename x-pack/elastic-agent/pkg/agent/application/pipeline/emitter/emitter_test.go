@@ -3,3 +3,102 @@
 // you may not use this file except in compliance with the Elastic License.
 
 package emitter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/pipeline"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+type recordingRouter struct {
+	routed int
+}
+
+func (r *recordingRouter) Routes() *sorted.Set { return nil }
+
+func (r *recordingRouter) Route(_ string, _ map[pipeline.RoutingKey][]program.Program) error {
+	r.routed++
+	return nil
+}
+
+func (r *recordingRouter) Shutdown() {}
+
+func TestControllerRender(t *testing.T) {
+	log, _ := logger.New("", false)
+	agentInfo, err := info.NewAgentInfo(true)
+	require.NoError(t, err)
+
+	router := &recordingRouter{}
+	ctrl := NewController(log, agentInfo, nil, router, &pipeline.ConfigModifiers{}, nil)
+
+	cfg := config.MustNewConfigFrom(map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{
+				"type":  "elasticsearch",
+				"hosts": []string{"http://localhost:9200"},
+			},
+		},
+		"inputs": []map[string]interface{}{
+			{
+				"type": "logfile",
+				"streams": []map[string]interface{}{
+					{"paths": []string{"/var/log/hello.log"}},
+				},
+			},
+		},
+	})
+
+	programs, err := ctrl.Render(cfg)
+	require.NoError(t, err)
+	require.Len(t, programs["default"], 1)
+	require.Equal(t, "filebeat", programs["default"][0].Identifier())
+
+	// the configuration is only rendered, nothing is routed and no configuration is kept.
+	require.Equal(t, 0, router.routed)
+	require.Nil(t, ctrl.ast)
+
+	t.Run("agent-level configuration has the inputs rendered with the variables", func(t *testing.T) {
+		vars, err := transpiler.NewVars(map[string]interface{}{
+			"host": map[string]interface{}{"logs": "/var/log/host.log"},
+		}, nil)
+		require.NoError(t, err)
+		ctrl.Set([]*transpiler.Vars{vars})
+
+		withVars := config.MustNewConfigFrom(map[string]interface{}{
+			"outputs": map[string]interface{}{
+				"default": map[string]interface{}{
+					"type":  "elasticsearch",
+					"hosts": []string{"http://localhost:9200"},
+				},
+			},
+			"inputs": []map[string]interface{}{
+				{
+					"type": "logfile",
+					"streams": []map[string]interface{}{
+						{"paths": []string{"${host.logs}"}},
+					},
+				},
+			},
+		})
+
+		ast, programs, err := ctrl.RenderConfig(withVars)
+		require.NoError(t, err)
+		require.Len(t, programs["default"], 1)
+
+		rendered, err := ast.Map()
+		require.NoError(t, err)
+		inputs := rendered["inputs"].([]interface{})
+		require.Len(t, inputs, 1)
+		streams := inputs[0].(map[string]interface{})["streams"].([]interface{})
+		require.Equal(t, []interface{}{"/var/log/host.log"}, streams[0].(map[string]interface{})["paths"])
+		require.Equal(t, 0, router.routed)
+	})
+}
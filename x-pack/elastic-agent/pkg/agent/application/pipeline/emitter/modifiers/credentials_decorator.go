@@ -0,0 +1,141 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package modifiers
+
+import (
+	"sort"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+)
+
+const (
+	// isolateCredentialsKey enables the API keys issued for each program of an Elasticsearch output.
+	isolateCredentialsKey = "isolate_credentials"
+	// programCredentialsKey holds the credentials of the programs of an Elasticsearch output.
+	programCredentialsKey = "program_credentials"
+	indexKey              = "index"
+)
+
+// credentialKeys are the settings of an Elasticsearch output authenticating the program.
+var credentialKeys = []string{"username", "password", "api_key"}
+
+// programIndices are the indices of the programs which do not name them in their inputs.
+var programIndices = map[string][]string{
+	MonitoringName: {"logs-elastic_agent*", "metrics-elastic_agent*"},
+}
+
+// CredentialIssuer issues the credential of a program allowed to write only to its indices, the
+// output holds the shared credential used to issue it.
+type CredentialIssuer interface {
+	Issue(agentID, program string, indices []string, output map[string]interface{}) (string, error)
+}
+
+// InjectProgramCredentials replaces the credential of the Elasticsearch output of each program
+// with a credential of its own, so a compromised program can only write to its own indices.
+//
+// The credentials of a program are either given by the program_credentials of the output or, when
+// isolate_credentials is enabled, an API key issued for the indices of the program. The programs
+// without known indices keep the credential of the output.
+func InjectProgramCredentials(issuer CredentialIssuer) func(*info.AgentInfo, string, *transpiler.AST, []program.Program) ([]program.Program, error) {
+	return func(agentInfo *info.AgentInfo, outputGroup string, rootAst *transpiler.AST, programsToRun []program.Program) ([]program.Program, error) {
+		for i, p := range programsToRun {
+			cfg, err := p.Config.Map()
+			if err != nil {
+				return programsToRun, err
+			}
+
+			output, ok := elasticsearchOutput(cfg)
+			if !ok {
+				continue
+			}
+			isolate, hasIsolate := output[isolateCredentialsKey].(bool)
+			credentials, hasCredentials := output[programCredentialsKey].(map[string]interface{})
+			if !hasIsolate && !hasCredentials {
+				// unchanged configuration keeps its hash, so the program is not restarted.
+				continue
+			}
+			delete(output, isolateCredentialsKey)
+			delete(output, programCredentialsKey)
+
+			if c, ok := credentials[p.Spec.Cmd].(map[string]interface{}); ok {
+				setCredentials(output, c)
+			} else if isolate {
+				if indices := indicesOf(p.Spec.Cmd, cfg); len(indices) > 0 {
+					apiKey, err := issuer.Issue(agentInfo.AgentID(), p.Spec.Cmd, indices, output)
+					if err != nil {
+						return programsToRun, errors.New(err, "failed to issue the credential of the program", errors.M("program", p.Spec.Cmd))
+					}
+					setCredentials(output, map[string]interface{}{"api_key": apiKey})
+				}
+			}
+
+			programsToRun[i].Config, err = transpiler.NewAST(cfg)
+			if err != nil {
+				return programsToRun, err
+			}
+		}
+		return programsToRun, nil
+	}
+}
+
+func elasticsearchOutput(cfg map[string]interface{}) (map[string]interface{}, bool) {
+	output, ok := cfg[outputKey].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	es, ok := output[elasticsearchKey].(map[string]interface{})
+	return es, ok
+}
+
+// setCredentials replaces the credential of the output.
+func setCredentials(output, credentials map[string]interface{}) {
+	for _, key := range credentialKeys {
+		delete(output, key)
+		if v, ok := credentials[key]; ok {
+			output[key] = v
+		}
+	}
+}
+
+// indicesOf returns the sorted indices the program writes to.
+func indicesOf(programName string, cfg map[string]interface{}) []string {
+	if indices, ok := programIndices[programName]; ok {
+		return indices
+	}
+
+	seen := make(map[string]bool)
+	for key, v := range cfg {
+		if key != outputKey {
+			collectIndices(v, seen)
+		}
+	}
+
+	indices := make([]string, 0, len(seen))
+	for index := range seen {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices
+}
+
+func collectIndices(v interface{}, seen map[string]bool) {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		for key, child := range n {
+			if index, ok := child.(string); ok && key == indexKey {
+				seen[index] = true
+				continue
+			}
+			collectIndices(child, seen)
+		}
+	case []interface{}:
+		for _, child := range n {
+			collectIndices(child, seen)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package modifiers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
+)
+
+type fakeIssuer struct {
+	issued map[string][]string
+}
+
+func (f *fakeIssuer) Issue(_, program string, indices []string, output map[string]interface{}) (string, error) {
+	f.issued[program] = indices
+	return program + "-id:" + output["username"].(string), nil
+}
+
+func TestInjectProgramCredentials(t *testing.T) {
+	agentInfo, err := info.NewAgentInfo(true)
+	require.NoError(t, err)
+
+	render := func(t *testing.T, output map[string]interface{}) ([]program.Program, *fakeIssuer) {
+		ast, err := transpiler.NewAST(map[string]interface{}{
+			"outputs": map[string]interface{}{"default": output},
+			"inputs": []map[string]interface{}{
+				{"type": "logfile", "streams": []map[string]interface{}{{"paths": "/var/log/syslog"}}},
+				{"type": "system/metrics", "streams": []map[string]interface{}{{"metricsets": []string{"cpu"}}}},
+			},
+		})
+		require.NoError(t, err)
+
+		groups, err := program.Programs(agentInfo, ast)
+		require.NoError(t, err)
+		require.Len(t, groups["default"], 2)
+
+		issuer := &fakeIssuer{issued: make(map[string][]string)}
+		programs, err := InjectMonitoring(agentInfo, "default", ast, groups["default"])
+		require.NoError(t, err)
+		programs, err = InjectProgramCredentials(issuer)(agentInfo, "default", ast, programs)
+		require.NoError(t, err)
+		return programs, issuer
+	}
+
+	outputOf := func(t *testing.T, p program.Program) map[string]interface{} {
+		cfg, err := p.Config.Map()
+		require.NoError(t, err)
+		output, ok := elasticsearchOutput(cfg)
+		require.True(t, ok, "%s has no elasticsearch output", p.Spec.Cmd)
+		return output
+	}
+
+	t.Run("API keys are issued for the indices of each program", func(t *testing.T) {
+		programs, issuer := render(t, map[string]interface{}{
+			"type":                "elasticsearch",
+			"hosts":               []string{"localhost:9200"},
+			"username":            "elastic",
+			"password":            "changeme",
+			"isolate_credentials": true,
+			"program_credentials": map[string]interface{}{
+				"metricbeat": map[string]interface{}{"username": "metrics", "password": "secret"},
+			},
+		})
+
+		for _, p := range programs {
+			output := outputOf(t, p)
+			assert.NotContains(t, output, isolateCredentialsKey)
+			assert.NotContains(t, output, programCredentialsKey)
+
+			switch p.Spec.Cmd {
+			case "filebeat":
+				assert.Equal(t, "filebeat-id:elastic", output["api_key"])
+				assert.NotContains(t, output, "username")
+				assert.NotContains(t, output, "password")
+			case "metricbeat":
+				assert.Equal(t, "metrics", output["username"])
+				assert.Equal(t, "secret", output["password"])
+				assert.NotContains(t, output, "api_key")
+			case MonitoringName:
+				assert.Equal(t, MonitoringName+"-id:elastic", output["api_key"])
+			}
+		}
+
+		assert.Equal(t, map[string][]string{
+			"filebeat":     {"logs-generic-default"},
+			MonitoringName: programIndices[MonitoringName],
+		}, issuer.issued)
+	})
+
+	t.Run("programs keep the shared credential without isolation", func(t *testing.T) {
+		programs, issuer := render(t, map[string]interface{}{
+			"type":     "elasticsearch",
+			"hosts":    []string{"localhost:9200"},
+			"username": "elastic",
+			"password": "changeme",
+		})
+
+		for _, p := range programs {
+			output := outputOf(t, p)
+			assert.Equal(t, "elastic", output["username"])
+			assert.NotContains(t, output, "api_key")
+		}
+		assert.Empty(t, issuer.issued)
+	})
+}
@@ -0,0 +1,234 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package modifiers
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/esleg/eslegclient"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+// apiKeyPrivileges are the privileges of the API keys issued for the programs on their indices.
+var apiKeyPrivileges = []string{"auto_configure", "create_doc"}
+
+// issueTimeout bounds each request to the Elasticsearch security API.
+const issueTimeout = 30 * time.Second
+
+// issuedKey is an API key issued for a program.
+type issuedKey struct {
+	hash   string
+	id     string
+	apiKey string
+}
+
+// APIKeyIssuer issues the API keys of the programs with the Elasticsearch security API, using the
+// credential of the output. An API key is issued again only when the indices of the program or
+// the output change.
+//
+// The API key replaced by a new one is still used by the running program until the new
+// configuration is applied, it is invalidated by the next render of the program. The API keys
+// issued before a restart of the agent are invalidated the same way.
+type APIKeyIssuer struct {
+	log *logger.Logger
+
+	mx   sync.Mutex
+	keys map[string]issuedKey
+	// stale are the IDs of the replaced API keys of each program, they are invalidated by the
+	// next render of the program.
+	stale map[string][]string
+	wg    sync.WaitGroup
+}
+
+// NewAPIKeyIssuer creates an issuer of API keys.
+func NewAPIKeyIssuer(log *logger.Logger) *APIKeyIssuer {
+	return &APIKeyIssuer{
+		log:   log,
+		keys:  make(map[string]issuedKey),
+		stale: make(map[string][]string),
+	}
+}
+
+// Issue returns the API key of the program, formatted as the api_key of the output.
+func (i *APIKeyIssuer) Issue(agentID, program string, indices []string, output map[string]interface{}) (string, error) {
+	hash, err := issueHash(agentID, indices, output)
+	if err != nil {
+		return "", err
+	}
+
+	// the program runs with the API key of the previous render, the keys it replaced are not used
+	// anymore.
+	i.mx.Lock()
+	current, known := i.keys[program]
+	replaced := i.stale[program]
+	delete(i.stale, program)
+	i.mx.Unlock()
+	i.invalidate(program, replaced, output)
+
+	if known && current.hash == hash {
+		return current.apiKey, nil
+	}
+
+	cfg, err := issuerConfig(output)
+	if err != nil {
+		return "", err
+	}
+	conn, err := connect(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	name := fmt.Sprintf("elastic-agent-%s-%s", agentID, program)
+	var stale []string
+	if known {
+		stale = []string{current.id}
+	} else {
+		// the API keys issued for the program before a restart of the agent.
+		stale = i.previousKeys(conn, program, name)
+	}
+
+	status, body, err := conn.Request(http.MethodPost, "/_security/api_key", "", nil, map[string]interface{}{
+		"name": name,
+		"role_descriptors": map[string]interface{}{
+			program: map[string]interface{}{
+				"indices": []map[string]interface{}{
+					{"names": indices, "privileges": apiKeyPrivileges},
+				},
+			},
+		},
+		"metadata": map[string]interface{}{
+			"agent_id":   agentID,
+			"program":    program,
+			"managed_by": "elastic-agent",
+		},
+	})
+	if err != nil {
+		return "", errors.New(err, fmt.Sprintf("failed to create the API key, status code %d", status), errors.TypeNetwork)
+	}
+
+	var resp struct {
+		ID     string `json:"id"`
+		APIKey string `json:"api_key"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", errors.New(err, "invalid response creating the API key", errors.TypeNetwork)
+	}
+
+	apiKey := resp.ID + ":" + resp.APIKey
+	i.mx.Lock()
+	i.keys[program] = issuedKey{hash: hash, id: resp.ID, apiKey: apiKey}
+	i.stale[program] = append(i.stale[program], stale...)
+	i.mx.Unlock()
+	i.log.Infof("Issued API key '%s' of '%s' for indices %v", resp.ID, program, indices)
+	return apiKey, nil
+}
+
+// previousKeys returns the IDs of the valid API keys of the program issued by a previous run.
+func (i *APIKeyIssuer) previousKeys(conn *eslegclient.Connection, program, name string) []string {
+	params := map[string]string{"name": name, "owner": "true"}
+	status, body, err := conn.Request(http.MethodGet, "/_security/api_key", "", params, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		i.log.Warnf("Could not list the previous API keys of '%s': %v", program, err)
+		return nil
+	}
+
+	var resp struct {
+		APIKeys []struct {
+			ID          string `json:"id"`
+			Invalidated bool   `json:"invalidated"`
+		} `json:"api_keys"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		i.log.Warnf("Could not list the previous API keys of '%s': %v", program, err)
+		return nil
+	}
+	var ids []string
+	for _, k := range resp.APIKeys {
+		if !k.Invalidated {
+			ids = append(ids, k.ID)
+		}
+	}
+	return ids
+}
+
+// invalidate invalidates the API keys in the background so the rendering of the policy does not
+// wait for it, the keys failing to be invalidated expire with their owner credential.
+func (i *APIKeyIssuer) invalidate(program string, ids []string, output map[string]interface{}) {
+	if len(ids) == 0 {
+		return
+	}
+	// the output is copied as the caller replaces its credential.
+	cfg, err := issuerConfig(output)
+	if err != nil {
+		i.log.Warnf("Could not invalidate the previous API keys of '%s': %v", program, err)
+		return
+	}
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+
+		conn, err := connect(cfg)
+		if err != nil {
+			i.log.Warnf("Could not invalidate the previous API keys of '%s': %v", program, err)
+			return
+		}
+		defer conn.Close()
+
+		status, _, err := conn.Request(http.MethodDelete, "/_security/api_key", "", nil, map[string]interface{}{
+			"ids": ids,
+		})
+		if err != nil && status != http.StatusNotFound {
+			i.log.Warnf("Could not invalidate the previous API keys of '%s': %v", program, err)
+			return
+		}
+		i.log.Infof("Invalidated the previous API keys %v of '%s'", ids, program)
+	}()
+}
+
+// issuerConfig returns the configuration of the connection to the output, the requests time out
+// after issueTimeout.
+func issuerConfig(output map[string]interface{}) (*common.Config, error) {
+	cfg, err := common.NewConfigFrom(output)
+	if err != nil {
+		return nil, errors.New(err, "invalid elasticsearch output", errors.TypeConfig)
+	}
+	if err := cfg.SetString("timeout", -1, issueTimeout.String()); err != nil {
+		return nil, errors.New(err, "invalid elasticsearch output", errors.TypeConfig)
+	}
+	return cfg, nil
+}
+
+// connect connects to Elasticsearch with the credential of the output.
+func connect(cfg *common.Config) (*eslegclient.Connection, error) {
+	conn, err := eslegclient.NewConnectedClient(cfg, "elastic-agent")
+	if err != nil {
+		return nil, errors.New(err, "failed to connect to elasticsearch", errors.TypeNetwork)
+	}
+	return conn, nil
+}
+
+func issueHash(agentID string, indices []string, output map[string]interface{}) (string, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"agent_id": agentID,
+		"indices":  indices,
+		"output":   output,
+	})
+	if err != nil {
+		return "", errors.New(err, "invalid elasticsearch output", errors.TypeConfig)
+	}
+	return fmt.Sprintf("%x", md5.Sum(data)), nil
+}
@@ -0,0 +1,99 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package modifiers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func TestAPIKeyIssuer(t *testing.T) {
+	var mx sync.Mutex
+	var created []map[string]interface{}
+	var invalidated []interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		defer mx.Unlock()
+
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `{"version":{"number":"7.13.0"}}`)
+		case r.URL.Path == "/_security/api_key" && r.Method == http.MethodGet:
+			assert.Equal(t, "elastic-agent-agent-filebeat", r.URL.Query().Get("name"))
+			fmt.Fprint(w, `{"api_keys":[{"id":"old","invalidated":false},{"id":"older","invalidated":true}]}`)
+		case r.URL.Path == "/_security/api_key" && r.Method == http.MethodDelete:
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			invalidated = append(invalidated, body["ids"].([]interface{})...)
+			fmt.Fprint(w, `{"invalidated_api_keys":[]}`)
+		case r.URL.Path == "/_security/api_key" && r.Method == http.MethodPost:
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			created = append(created, body)
+			fmt.Fprintf(w, `{"id":"id%d","api_key":"key"}`, len(created))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	log, err := logger.New("", false)
+	require.NoError(t, err)
+	issuer := NewAPIKeyIssuer(log)
+	output := map[string]interface{}{
+		"hosts":    []string{srv.URL},
+		"username": "elastic",
+		"password": "changeme",
+	}
+
+	invalidatedKeys := func() []interface{} {
+		issuer.wg.Wait()
+		mx.Lock()
+		defer mx.Unlock()
+		return invalidated
+	}
+
+	apiKey, err := issuer.Issue("agent", "filebeat", []string{"logs-generic-default"}, output)
+	require.NoError(t, err)
+	assert.Equal(t, "id1:key", apiKey)
+	require.Len(t, created, 1)
+	assert.Empty(t, invalidatedKeys(), "the keys of the previous run are still used by the running program")
+	assert.Equal(t, "elastic-agent-agent-filebeat", created[0]["name"])
+	assert.Equal(t, map[string]interface{}{
+		"filebeat": map[string]interface{}{
+			"indices": []interface{}{
+				map[string]interface{}{
+					"names":      []interface{}{"logs-generic-default"},
+					"privileges": []interface{}{"auto_configure", "create_doc"},
+				},
+			},
+		},
+	}, created[0]["role_descriptors"])
+
+	apiKey, err = issuer.Issue("agent", "filebeat", []string{"logs-generic-default"}, output)
+	require.NoError(t, err)
+	assert.Equal(t, "id1:key", apiKey, "the API key is reused while the program is unchanged")
+	assert.Len(t, created, 1)
+	assert.Equal(t, []interface{}{"old"}, invalidatedKeys(), "the keys of the previous run are invalidated by the next render")
+
+	apiKey, err = issuer.Issue("agent", "filebeat", []string{"logs-generic-default", "logs-nginx-default"}, output)
+	require.NoError(t, err)
+	assert.Equal(t, "id2:key", apiKey)
+	assert.Len(t, created, 2)
+	assert.Equal(t, []interface{}{"old"}, invalidatedKeys(), "the replaced key is valid until the program runs with the new key")
+
+	_, err = issuer.Issue("agent", "filebeat", []string{"logs-generic-default", "logs-nginx-default"}, output)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"old", "id1"}, invalidatedKeys())
+}
@@ -7,6 +7,7 @@ package pipeline
 import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configrequest"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/store"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/transpiler"
@@ -23,6 +24,10 @@ type ConfigHandler interface {
 	Shutdown()
 }
 
+// ErrRolledBack is returned by the handlers which failed to apply an action and restored the
+// previous state of the agent, the agent keeps running degraded.
+var ErrRolledBack = errors.New("action was rolled back")
+
 // DefaultRK default routing keys until we implement the routing key / config matrix.
 var DefaultRK = "default"
 
@@ -49,6 +54,9 @@ type Stream interface {
 // EmitterFunc emits configuration for processing.
 type EmitterFunc func(*config.Config) error
 
+// RendererFunc returns the programs a configuration would run without applying it.
+type RendererFunc func(*config.Config) (map[RoutingKey][]program.Program, error)
+
 // DecoratorFunc is a func for decorating a retrieved configuration before processing.
 type DecoratorFunc = func(*info.AgentInfo, string, *transpiler.AST, []program.Program) ([]program.Program, error)
 
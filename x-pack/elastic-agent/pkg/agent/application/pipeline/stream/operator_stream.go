@@ -25,6 +25,10 @@ type specer interface {
 	Specs() map[string]program.Spec
 }
 
+type releaser interface {
+	Release(program string) ([]string, error)
+}
+
 func (b *operatorStream) Close() error {
 	return b.configHandler.Close()
 }
@@ -44,6 +48,14 @@ func (b *operatorStream) Specs() map[string]program.Spec {
 	return nil
 }
 
+// Release starts again the quarantined applications of the program.
+func (b *operatorStream) Release(program string) ([]string, error) {
+	if r, ok := b.configHandler.(releaser); ok {
+		return r.Release(program)
+	}
+	return nil, nil
+}
+
 func (b *operatorStream) Execute(cfg configrequest.Request) error {
 	return b.configHandler.HandleConfig(cfg)
 }
@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	reporting "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+	esreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/elasticsearch"
+	filereporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/file"
+	logreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/log"
+)
+
+// defaultEventsFile is the name of the file of the file reporter in the logs directory.
+const defaultEventsFile = "elastic-agent-events.ndjson"
+
+// newReporter creates the reporter of the events of the agent, the events are logged and sent to
+// the backends and to the additional backends enabled by the configuration.
+func newReporter(ctx context.Context, log *logger.Logger, agentInfo *info.AgentInfo, cfg *configuration.ReportingConfig, backends ...reporting.Backend) (*reporting.Reporter, error) {
+	backends = append([]reporting.Backend{logreporter.NewReporter(log)}, backends...)
+
+	if cfg != nil && cfg.File != nil && cfg.File.Enabled {
+		fileCfg := *cfg.File
+		if fileCfg.Path == "" {
+			fileCfg.Path = filepath.Join(paths.Logs(), defaultEventsFile)
+		}
+		fileR, err := filereporter.NewReporter(agentInfo, &fileCfg)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, fileR)
+	}
+
+	if cfg != nil && cfg.Elasticsearch != nil && cfg.Elasticsearch.Enabled {
+		esR, err := esreporter.NewReporter(log, agentInfo, cfg.Elasticsearch)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, esR)
+	}
+
+	return reporting.NewReporter(ctx, log, agentInfo, backends...), nil
+}
@@ -0,0 +1,322 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+// settingsRestartDelay is the time waited before restarting the agent to apply the changed local
+// settings, it leaves time to report the pending restart to Fleet and to finish editing the file.
+const settingsRestartDelay = 30 * time.Second
+
+// settingsSections are the sections of the local configuration holding the settings of the agent,
+// the other sections are the inputs and outputs reloaded with the configuration.
+var settingsSections = []string{"agent", "fleet", "path"}
+
+const (
+	// checkinFrequencySetting and checkinJitterSetting are the local settings of the time between
+	// the checkins of the fleet gateway.
+	checkinFrequencySetting = "fleet.gateway.checkin_frequency"
+	checkinJitterSetting    = "fleet.gateway.jitter"
+)
+
+// errRestartRequired is returned by a setting applier when the change can only be applied by
+// restarting the agent.
+var errRestartRequired = errors.New("restart required")
+
+// settingApplier applies the new value of a setting to the running agent, the value is nil when
+// the setting was removed.
+type settingApplier func(w *settingsWatcher, value interface{}) error
+
+// hotSettings are the local settings applied without restarting the agent, the changes of the
+// other settings are applied by restarting the agent.
+var hotSettings = map[string]settingApplier{
+	"agent.logging.level":   applyLogLevel,
+	checkinFrequencySetting: applyCheckinFrequency,
+	checkinJitterSetting:    applyCheckinJitter,
+}
+
+// settingsWatcher watches the local configuration file for changes of the settings of the agent.
+// The settings which can be changed while running are applied right away, a restart of the agent
+// is scheduled for the others and reported with the status of the agent until the restart.
+type settingsWatcher struct {
+	log      *logger.Logger
+	path     string
+	reexec   reexecManager
+	reporter status.Reporter
+	// reloaded are the prefixes of the settings applied by the reload of the configuration.
+	reloaded []string
+	// onPending is called when a restart is scheduled, nil when nothing has to be notified.
+	onPending    func()
+	setLevelFn   func(logp.Level)
+	restartDelay time.Duration
+	// gateway is the fleet gateway the checkin settings are applied to, nil when not managed.
+	gateway checkinSetter
+
+	current  common.MapStr
+	notifier *changeNotifier
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newSettingsWatcher(
+	log *logger.Logger,
+	path string,
+	reexec reexecManager,
+	statusCtrl status.Controller,
+	reloaded ...string,
+) (*settingsWatcher, error) {
+	current, err := loadLocalSettings(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settingsWatcher{
+		log:          log,
+		path:         path,
+		reexec:       reexec,
+		reporter:     statusCtrl.RegisterComponent("settings"),
+		reloaded:     reloaded,
+		setLevelFn:   logger.SetLevel,
+		restartDelay: settingsRestartDelay,
+		current:      current,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// SetGateway sets the fleet gateway and applies the local checkin settings.
+func (w *settingsWatcher) SetGateway(g checkinSetter) error {
+	w.gateway = g
+	for _, key := range []string{checkinFrequencySetting, checkinJitterSetting} {
+		value, ok := w.current[key]
+		if !ok {
+			continue
+		}
+		if err := hotSettings[key](w, value); err != nil {
+			return errors.New(err, fmt.Sprintf("invalid local setting %s", key), errors.TypeConfig)
+		}
+	}
+	return nil
+}
+
+// Start starts watching the local configuration file.
+func (w *settingsWatcher) Start() error {
+	notifier, err := newChangeNotifier(w.log, w.path)
+	if err != nil {
+		return err
+	}
+	w.notifier = notifier
+
+	w.wg.Add(1)
+	go w.run()
+	return nil
+}
+
+// Stop stops watching the local configuration file, a scheduled restart is cancelled.
+func (w *settingsWatcher) Stop() {
+	if w.notifier == nil {
+		return
+	}
+	close(w.done)
+	w.wg.Wait()
+	w.notifier.Close()
+}
+
+func (w *settingsWatcher) run() {
+	defer w.wg.Done()
+
+	var timer *time.Timer
+	var restart <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.notifier.Changes():
+			pending, err := w.reload()
+			if err != nil {
+				w.log.Errorf("Could not load the local settings from %s: %v", w.path, err)
+				continue
+			}
+
+			if len(pending) == 0 {
+				if timer != nil {
+					w.log.Info("Local settings are back to the running settings, the restart is cancelled")
+					timer.Stop()
+					timer, restart = nil, nil
+					w.reporter.Update(state.Healthy, "", nil)
+				}
+				continue
+			}
+
+			// further changes of the file postpone the restart.
+			if timer == nil {
+				timer = time.NewTimer(w.restartDelay)
+				restart = timer.C
+			} else {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(w.restartDelay)
+			}
+			w.log.Infof("Agent restarts in %s to apply the changes of the local settings: %s", w.restartDelay, strings.Join(pending, ", "))
+			w.reporter.Update(state.Degraded, fmt.Sprintf("restart pending to apply the changes of the local settings: %s", strings.Join(pending, ", ")), nil)
+			if w.onPending != nil {
+				w.onPending()
+			}
+		case <-restart:
+			w.log.Info("Agent is restarting to apply the changes of the local settings")
+			w.reexec.ReExec(nil)
+			return
+		}
+	}
+}
+
+// reload applies the changed settings which do not require a restart, it returns the changed
+// settings which require a restart.
+func (w *settingsWatcher) reload() ([]string, error) {
+	updated, err := loadLocalSettings(w.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, key := range changedSettings(w.current, updated) {
+		value := updated[key]
+
+		if apply, ok := hotSettings[key]; ok {
+			err := apply(w, value)
+			if errors.Is(err, errRestartRequired) {
+				pending = append(pending, key)
+				continue
+			}
+			if err != nil {
+				w.log.Errorf("Could not apply the changed local setting %s: %v", key, err)
+				continue
+			}
+			w.log.Infof("Local setting %s changed and applied", key)
+			w.set(key, value)
+			continue
+		}
+
+		if w.isReloaded(key) {
+			w.set(key, value)
+			continue
+		}
+
+		pending = append(pending, key)
+	}
+	return pending, nil
+}
+
+// set records the value of a setting applied to the running agent.
+func (w *settingsWatcher) set(key string, value interface{}) {
+	if value == nil {
+		delete(w.current, key)
+		return
+	}
+	w.current[key] = value
+}
+
+func (w *settingsWatcher) isReloaded(key string) bool {
+	for _, prefix := range w.reloaded {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func applyLogLevel(w *settingsWatcher, value interface{}) error {
+	level := logger.DefaultLogLevel
+	if value != nil {
+		if err := level.Unpack(fmt.Sprint(value)); err != nil {
+			return err
+		}
+	}
+	w.setLevelFn(level)
+	return nil
+}
+
+func applyCheckinFrequency(w *settingsWatcher, value interface{}) error {
+	return applyCheckin(w, value, func(d time.Duration) { w.gateway.SetCheckinFrequency(d, 0) })
+}
+
+func applyCheckinJitter(w *settingsWatcher, value interface{}) error {
+	return applyCheckin(w, value, func(d time.Duration) { w.gateway.SetCheckinFrequency(0, d) })
+}
+
+// applyCheckin applies a checkin setting to the gateway, the gateway goes back to its defaults
+// on restart when the setting is removed.
+func applyCheckin(w *settingsWatcher, value interface{}, set func(time.Duration)) error {
+	if value == nil {
+		return errRestartRequired
+	}
+	d, err := parsePositiveDuration(fmt.Sprint(value))
+	if err != nil {
+		return err
+	}
+	if w.gateway != nil {
+		set(d)
+	}
+	return nil
+}
+
+// changedSettings returns the sorted keys of the settings added, removed or changed.
+func changedSettings(current, updated common.MapStr) []string {
+	var changed []string
+	for key, value := range updated {
+		if old, ok := current[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range current {
+		if _, ok := updated[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// loadLocalSettings returns the flattened settings of the local configuration file.
+func loadLocalSettings(path string) (common.MapStr, error) {
+	rawConfig, err := config.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := rawConfig.ToMapStr()
+	if err != nil {
+		return nil, errors.New(err, "could not read the local settings", errors.TypeConfig, errors.M(errors.MetaKeyPath, path))
+	}
+
+	settings := common.MapStr{}
+	for _, section := range settingsSections {
+		if v, ok := m[section]; ok {
+			settings[section] = v
+		}
+	}
+	return settings.Flatten(), nil
+}
@@ -0,0 +1,143 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package application
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/reexec"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+type recordingReexec struct {
+	called chan struct{}
+}
+
+func (r *recordingReexec) ReExec(_ reexec.ShutdownCallbackFn, _ ...string) {
+	r.called <- struct{}{}
+}
+
+func TestSettingsWatcher(t *testing.T) {
+	log, _ := logger.New("", false)
+
+	newWatcher := func(t *testing.T, content string, reloaded ...string) (*settingsWatcher, string, status.Controller, *recordingReexec) {
+		configFile := filepath.Join(t.TempDir(), "elastic-agent.yml")
+		require.NoError(t, ioutil.WriteFile(configFile, []byte(content), 0600))
+
+		statusCtrl := status.NewController(log)
+		re := &recordingReexec{called: make(chan struct{}, 1)}
+		w, err := newSettingsWatcher(log, configFile, re, statusCtrl, reloaded...)
+		require.NoError(t, err)
+		return w, configFile, statusCtrl, re
+	}
+
+	t.Run("log level is applied without restart", func(t *testing.T) {
+		w, configFile, _, _ := newWatcher(t, "agent.logging.level: info\noutputs:\n  default:\n    type: elasticsearch\n")
+		var applied []logp.Level
+		w.setLevelFn = func(l logp.Level) { applied = append(applied, l) }
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("agent.logging.level: debug\noutputs:\n  default:\n    type: logstash\n"), 0600))
+		pending, err := w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		assert.Equal(t, []logp.Level{logp.DebugLevel}, applied)
+
+		// the default level is applied when the level is removed.
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("outputs:\n  default:\n    type: logstash\n"), 0600))
+		pending, err = w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		assert.Equal(t, []logp.Level{logp.DebugLevel, logp.InfoLevel}, applied)
+	})
+
+	t.Run("checkin frequency and jitter are applied without restart", func(t *testing.T) {
+		w, configFile, _, _ := newWatcher(t, "fleet:\n  enabled: true\n  gateway.checkin_frequency: 2m\n")
+		gateway := &checkinRecorder{}
+		require.NoError(t, w.SetGateway(gateway))
+		assert.Equal(t, 2*time.Minute, gateway.frequency)
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("fleet:\n  enabled: true\n  gateway.checkin_frequency: 5m\n"), 0600))
+		pending, err := w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		assert.Equal(t, 5*time.Minute, gateway.frequency)
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("fleet:\n  enabled: true\n  gateway:\n    checkin_frequency: 5m\n    jitter: 10s\n"), 0600))
+		pending, err = w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		assert.Equal(t, 10*time.Second, gateway.jitter)
+
+		// an invalid value is not applied.
+		*gateway = checkinRecorder{frequency: 5 * time.Minute, jitter: 10 * time.Second}
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("fleet:\n  enabled: true\n  gateway:\n    checkin_frequency: -1m\n    jitter: 10s\n"), 0600))
+		pending, err = w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		assert.Equal(t, 5*time.Minute, gateway.frequency)
+
+		// the default frequency is restored by restarting the agent.
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("fleet:\n  enabled: true\n  gateway.jitter: 10s\n"), 0600))
+		pending, err = w.reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{checkinFrequencySetting}, pending)
+	})
+
+	t.Run("settings requiring a restart are pending until reverted", func(t *testing.T) {
+		w, configFile, _, _ := newWatcher(t, "fleet:\n  enabled: true\n  hosts: [\"https://fleet-1:8220\"]\n")
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("fleet:\n  enabled: true\n  hosts: [\"https://fleet-2:8220\"]\npath.data: /data\n"), 0600))
+		pending, err := w.reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fleet.hosts", "path.data"}, pending)
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("fleet:\n  enabled: true\n  hosts: [\"https://fleet-1:8220\"]\n"), 0600))
+		pending, err = w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("settings applied by the reload of the configuration do not require a restart", func(t *testing.T) {
+		w, configFile, _, _ := newWatcher(t, "agent.monitoring.enabled: true\n", "agent.monitoring")
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("agent.monitoring:\n  enabled: false\n  logs: false\n"), 0600))
+		pending, err := w.reload()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("agent restarts once the pending restart is reported", func(t *testing.T) {
+		w, configFile, statusCtrl, re := newWatcher(t, "path.logs: /var/log\n")
+		w.restartDelay = 100 * time.Millisecond
+		notified := make(chan struct{}, 1)
+		w.onPending = func() { notified <- struct{}{} }
+
+		require.NoError(t, w.Start())
+		defer w.Stop()
+
+		require.NoError(t, ioutil.WriteFile(configFile, []byte("path.logs: /tmp/logs\n"), 0600))
+		select {
+		case <-notified:
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "pending restart not notified")
+		}
+		assert.Equal(t, status.Degraded, statusCtrl.StatusCode())
+		assert.Contains(t, statusCtrl.Status().Message, "path.logs")
+
+		select {
+		case <-re.called:
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "agent not restarted")
+		}
+	})
+}
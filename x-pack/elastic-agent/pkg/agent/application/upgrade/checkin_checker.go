@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package upgrade
+
+import (
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// ErrUpgradeNotCheckedIn is returned when the upgraded agent did not complete a successful checkin
+// with fleet within the grace period.
+var ErrUpgradeNotCheckedIn = errors.New("upgraded agent did not check in with fleet within the grace period", errors.TypeNetwork)
+
+// CheckCheckedIn returns ErrUpgradeNotCheckedIn when the upgrade was requested by fleet and the
+// upgraded agent did not complete a successful checkin yet. Upgrades started locally do not
+// require fleet and always pass the check.
+func CheckCheckedIn() error {
+	marker, err := LoadMarker()
+	if err != nil {
+		return errors.New(err, "failed to load update marker", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, markerFilePath()))
+	}
+	if marker == nil || marker.Action == nil || marker.CheckedIn {
+		return nil
+	}
+	return ErrUpgradeNotCheckedIn
+}
+
+// MarkCheckedIn records in the update marker that the upgraded agent completed a successful
+// checkin with fleet, it does nothing when no upgrade is watched.
+func MarkCheckedIn() error {
+	marker, err := LoadMarker()
+	if err != nil {
+		return errors.New(err, "failed to load update marker", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, markerFilePath()))
+	}
+	if marker == nil || marker.CheckedIn || marker.RollbackReason != "" {
+		return nil
+	}
+
+	marker.CheckedIn = true
+	if err := saveMarker(marker); err != nil {
+		return errors.New(err, "failed to update the update marker", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, markerFilePath()))
+	}
+	return nil
+}
+
+// MarkRolledBack records in the update marker the reason the upgrade is rolled back, the marker
+// of an upgrade requested by fleet is kept after the rollback so the previous agent reports the
+// reason to fleet.
+func MarkRolledBack(reason error) error {
+	marker, err := LoadMarker()
+	if err != nil {
+		return errors.New(err, "failed to load update marker", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, markerFilePath()))
+	}
+	if marker == nil {
+		return nil
+	}
+
+	marker.RollbackReason = reason.Error()
+	marker.RolledBackOn = time.Now()
+	if err := saveMarker(marker); err != nil {
+		return errors.New(err, "failed to update the update marker", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, markerFilePath()))
+	}
+	return nil
+}
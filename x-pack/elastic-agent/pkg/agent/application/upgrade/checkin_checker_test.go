@@ -0,0 +1,139 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+func TestCheckCheckedIn(t *testing.T) {
+	top := paths.Top()
+	defer paths.SetTop(top)
+	paths.SetTop(t.TempDir())
+	require.NoError(t, os.MkdirAll(paths.Data(), 0700))
+
+	t.Run("no upgrade in progress", func(t *testing.T) {
+		require.NoError(t, CleanMarker())
+		require.NoError(t, MarkCheckedIn())
+		require.NoError(t, CheckCheckedIn())
+	})
+
+	t.Run("upgrade started locally", func(t *testing.T) {
+		require.NoError(t, saveMarker(&UpdateMarker{Hash: "abcdef"}))
+		require.NoError(t, CheckCheckedIn())
+	})
+
+	t.Run("upgrade requested by fleet not checked in", func(t *testing.T) {
+		require.NoError(t, saveMarker(&UpdateMarker{
+			Hash:   "abcdef",
+			Action: &fleetapi.ActionUpgrade{ActionID: "upgrade-1", ActionType: fleetapi.ActionTypeUpgrade},
+		}))
+		require.True(t, errors.Is(CheckCheckedIn(), ErrUpgradeNotCheckedIn))
+	})
+
+	t.Run("upgrade acked without checkin", func(t *testing.T) {
+		// acks are buffered while fleet is unreachable, they do not prove the agent reached fleet.
+		require.NoError(t, saveMarker(&UpdateMarker{
+			Hash:   "abcdef",
+			Acked:  true,
+			Action: &fleetapi.ActionUpgrade{ActionID: "upgrade-1", ActionType: fleetapi.ActionTypeUpgrade},
+		}))
+		require.True(t, errors.Is(CheckCheckedIn(), ErrUpgradeNotCheckedIn))
+	})
+
+	t.Run("upgrade requested by fleet checked in", func(t *testing.T) {
+		require.NoError(t, saveMarker(&UpdateMarker{
+			Hash:   "abcdef",
+			Action: &fleetapi.ActionUpgrade{ActionID: "upgrade-1", ActionType: fleetapi.ActionTypeUpgrade},
+		}))
+		require.NoError(t, MarkCheckedIn())
+		require.NoError(t, CheckCheckedIn())
+	})
+
+	t.Run("rollback reason recorded", func(t *testing.T) {
+		require.NoError(t, saveMarker(&UpdateMarker{
+			Hash:   "abcdef",
+			Action: &fleetapi.ActionUpgrade{ActionID: "upgrade-1", ActionType: fleetapi.ActionTypeUpgrade},
+		}))
+		require.NoError(t, MarkRolledBack(fmt.Errorf("agent in a failed state")))
+		// a checkin of the agent rolled back to does not keep the upgrade.
+		require.NoError(t, MarkCheckedIn())
+
+		marker, err := LoadMarker()
+		require.NoError(t, err)
+		require.Equal(t, "agent in a failed state", marker.RollbackReason)
+		require.False(t, marker.RolledBackOn.IsZero())
+		require.False(t, marker.CheckedIn)
+	})
+}
+
+func TestAckReportsRollback(t *testing.T) {
+	top := paths.Top()
+	defer paths.SetTop(top)
+	paths.SetTop(t.TempDir())
+	require.NoError(t, os.MkdirAll(paths.Data(), 0700))
+
+	log, _ := logger.New("", false)
+	acker := &recordingAcker{}
+	reporter := &recordingReporter{}
+	u := &Upgrader{log: log, acker: acker, reporter: reporter}
+
+	require.NoError(t, saveMarker(&UpdateMarker{
+		Hash:      "abcdef",
+		UpdatedOn: time.Now().Add(-time.Minute),
+		Action:    &fleetapi.ActionUpgrade{ActionID: "upgrade-1", ActionType: fleetapi.ActionTypeUpgrade, Version: "8.0.0"},
+	}))
+	require.NoError(t, MarkRolledBack(ErrUpgradeNotCheckedIn))
+	require.NoError(t, u.Ack(context.Background()))
+
+	require.Len(t, acker.acked, 1)
+	failed, ok := acker.acked[0].(*fleetapi.FailedAction)
+	require.True(t, ok)
+	require.Equal(t, "upgrade-1", failed.ID())
+	require.Contains(t, failed.Err.Error(), "did not check in with fleet")
+	require.Equal(t, 1, acker.commits)
+	require.Len(t, reporter.states, 1)
+	require.Equal(t, state.Failed, reporter.states[0].Status)
+
+	// the marker is removed once the rollback is reported.
+	marker, err := LoadMarker()
+	require.NoError(t, err)
+	require.Nil(t, marker)
+}
+
+type recordingAcker struct {
+	acked   []fleetapi.Action
+	commits int
+}
+
+func (a *recordingAcker) Ack(_ context.Context, action fleetapi.Action) error {
+	a.acked = append(a.acked, action)
+	return nil
+}
+
+func (a *recordingAcker) Commit(_ context.Context) error {
+	a.commits++
+	return nil
+}
+
+type recordingReporter struct {
+	states []state.State
+}
+
+func (r *recordingReporter) OnStateChange(_ string, _ string, s state.State) {
+	r.states = append(r.states, s)
+}
@@ -47,8 +47,16 @@ func Rollback(ctx context.Context, prevHash, currentHash string) error {
 		return err
 	}
 
+	// the marker of an upgrade requested by fleet is kept so the previous agent reports the reason
+	// of the rollback to fleet.
+	marker, err := LoadMarker()
+	if err != nil {
+		return err
+	}
+	keepMarker := marker != nil && marker.Action != nil && marker.RollbackReason != ""
+
 	// cleanup everything except version we're rolling back into
-	return Cleanup(prevHash, true)
+	return Cleanup(prevHash, !keepMarker)
 }
 
 // Cleanup removes all artifacts and files related to a specified version.
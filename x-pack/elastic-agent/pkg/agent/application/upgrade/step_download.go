@@ -24,13 +24,8 @@ func (u *Upgrader) downloadArtifact(ctx context.Context, version, sourceURI stri
 	// do not update source config
 	settings := *u.settings
 	if sourceURI != "" {
-		if strings.HasPrefix(sourceURI, "file://") {
-			// update the DropPath so the fs.Downloader can download from this
-			// path instead of looking into the installed downloads directory
-			settings.DropPath = strings.TrimPrefix(sourceURI, "file://")
-		} else {
-			settings.SourceURI = sourceURI
-		}
+		// a file:// source is a local directory, the artifacts are then only fetched from it.
+		settings.SourceURI = sourceURI
 	}
 
 	verifier, err := newVerifier(version, u.log, &settings)
@@ -60,7 +55,7 @@ func (u *Upgrader) downloadArtifact(ctx context.Context, version, sourceURI stri
 }
 
 func newDownloader(version string, log *logger.Logger, settings *artifact.Config) (download.Downloader, error) {
-	if !strings.HasSuffix(version, "-SNAPSHOT") {
+	if _, local := settings.SourceDirectory(); local || !strings.HasSuffix(version, "-SNAPSHOT") {
 		return downloader.NewDownloader(log, settings)
 	}
 
@@ -80,7 +75,7 @@ func newDownloader(version string, log *logger.Logger, settings *artifact.Config
 
 func newVerifier(version string, log *logger.Logger, settings *artifact.Config) (download.Verifier, error) {
 	allowEmptyPgp, pgp := release.PGP()
-	if !strings.HasSuffix(version, "-SNAPSHOT") {
+	if _, local := settings.SourceDirectory(); local || !strings.HasSuffix(version, "-SNAPSHOT") {
 		return downloader.NewVerifier(log, settings, allowEmptyPgp, pgp)
 	}
 
@@ -19,8 +19,6 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 )
 
-const markerFilename = ".update-marker"
-
 // UpdateMarker is a marker holding necessary information about ongoing upgrade.
 type UpdateMarker struct {
 	// Hash agent is updated to
@@ -36,6 +34,13 @@ type UpdateMarker struct {
 	// Acked is a flag marking whether or not action was acked
 	Acked  bool                    `json:"acked" yaml:"acked"`
 	Action *fleetapi.ActionUpgrade `json:"action" yaml:"action"`
+
+	// CheckedIn is a flag marking whether or not the upgraded agent completed a successful checkin
+	CheckedIn bool `json:"checked_in" yaml:"checked_in"`
+	// RollbackReason is the reason the watcher rolled back the upgrade, empty when not rolled back
+	RollbackReason string `json:"rollback_reason,omitempty" yaml:"rollback_reason,omitempty"`
+	// RolledBackOn marks a date when the upgrade was rolled back
+	RolledBackOn time.Time `json:"rolled_back_on,omitempty" yaml:"rolled_back_on,omitempty"`
 }
 
 // markUpgrade marks update happened so we can handle grace period
@@ -121,5 +126,5 @@ func saveMarker(marker *UpdateMarker) error {
 }
 
 func markerFilePath() string {
-	return filepath.Join(paths.Data(), markerFilename)
+	return paths.AgentUpgradeMarkerFile()
 }
@@ -193,7 +193,12 @@ func (u *Upgrader) Ack(ctx context.Context) error {
 		return nil
 	}
 
-	if marker.Acked {
+	if marker.RollbackReason != "" {
+		return u.reportRollback(ctx, marker)
+	}
+
+	// upgrades started locally have no action to acknowledge.
+	if marker.Acked || marker.Action == nil {
 		return nil
 	}
 
@@ -201,6 +206,7 @@ func (u *Upgrader) Ack(ctx context.Context) error {
 		return err
 	}
 
+	marker.Acked = true
 	return saveMarker(marker)
 }
 
@@ -232,12 +238,9 @@ func (u *Upgrader) ackAction(ctx context.Context, action fleetapi.Action) error
 	return nil
 }
 
-// report failure is used when update process fails. action is acked so it won't be received again
-// and state is changed to FAILED
+// report failure is used when update process fails, state is changed to FAILED.
+// The action itself is acked with the error by the dispatcher so it won't be received again.
 func (u *Upgrader) reportFailure(ctx context.Context, action fleetapi.Action, err error) {
-	// ack action
-	u.acker.Ack(ctx, action)
-
 	// report failure
 	u.reporter.OnStateChange(
 		"",
@@ -246,6 +249,26 @@ func (u *Upgrader) reportFailure(ctx context.Context, action fleetapi.Action, er
 	)
 }
 
+// reportRollback reports to fleet the reason the watcher rolled back the upgrade, the upgrade
+// action is acknowledged as failed and the marker is removed once fleet is told.
+func (u *Upgrader) reportRollback(ctx context.Context, marker *UpdateMarker) error {
+	reason := errors.New(fmt.Sprintf("upgrade rolled back: %s", marker.RollbackReason), errors.TypeApplication)
+	u.log.Warnf("Upgrade with hash '%s' was rolled back to the previous version: %s", marker.Hash, marker.RollbackReason)
+
+	if marker.Action != nil {
+		failed := fleetapi.NewFailedAction(marker.Action, reason, marker.UpdatedOn, marker.RolledBackOn)
+		if err := u.acker.Ack(ctx, failed); err != nil {
+			return err
+		}
+		if err := u.acker.Commit(ctx); err != nil {
+			return err
+		}
+		u.reportFailure(ctx, marker.Action, reason)
+	}
+
+	return CleanMarker()
+}
+
 // reportUpdating sets state of agent to updating.
 func (u *Upgrader) reportUpdating(version string) {
 	// report failure
@@ -262,7 +285,15 @@ func rollbackInstall(ctx context.Context, hash string) {
 }
 
 func copyActionStore(newHash string) error {
-	storePaths := []string{paths.AgentActionStoreFile(), paths.AgentStateStoreFile()}
+	storePaths := []string{
+		paths.AgentActionStoreFile(),
+		paths.AgentStateStoreYmlFile(),
+		paths.AgentStateStoreFile(),
+		paths.AgentSecretFile(),
+		paths.AgentEventsStoreFile(),
+		paths.AgentSettingsStoreFile(),
+		paths.AgentAcksStoreFile(),
+	}
 
 	for _, currentActionStorePath := range storePaths {
 		newHome := filepath.Join(filepath.Dir(paths.Home()), fmt.Sprintf("%s-%s", agentName, newHash))
@@ -282,7 +313,16 @@ func copyActionStore(newHash string) error {
 		}
 	}
 
-	return nil
+	// the events spooled while fleet was unreachable are kept in their own directory.
+	spoolDir := paths.AgentEventsSpoolDir()
+	if _, err := os.Stat(spoolDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	newHome := filepath.Join(filepath.Dir(paths.Home()), fmt.Sprintf("%s-%s", agentName, newHash))
+	return copyDir(spoolDir, filepath.Join(newHome, filepath.Base(spoolDir)))
 }
 
 // shutdownCallback returns a callback function to be executing during shutdown once all processes are closed.
@@ -14,6 +14,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 )
@@ -54,3 +55,43 @@ func TestShutdownCallback(t *testing.T) {
 	require.NoError(t, err, "reading file failed")
 	require.Equal(t, content, newContent, "contents are not equal")
 }
+
+func TestCopyActionStore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "copy-store-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	prevTop := paths.Top()
+	defer paths.SetTop(prevTop)
+	paths.SetTop(tmpDir)
+
+	newCommit := "abc123"
+	newHome := filepath.Join(filepath.Dir(paths.Home()), fmt.Sprintf("%s-%s", agentName, newCommit))
+	require.NoError(t, os.MkdirAll(paths.Home(), 0755))
+	require.NoError(t, os.MkdirAll(newHome, 0755))
+
+	stores := []string{
+		paths.AgentEventsStoreFile(),
+		paths.AgentSettingsStoreFile(),
+		paths.AgentAcksStoreFile(),
+	}
+	for _, store := range stores {
+		require.NoError(t, ioutil.WriteFile(store, []byte(filepath.Base(store)), 0600))
+	}
+
+	spooled := filepath.Join(paths.AgentEventsSpoolDir(), "00000000000000000001.ndjson")
+	require.NoError(t, os.MkdirAll(paths.AgentEventsSpoolDir(), 0700))
+	require.NoError(t, ioutil.WriteFile(spooled, []byte("spooled"), 0600))
+
+	require.NoError(t, copyActionStore(newCommit))
+
+	newContent, err := ioutil.ReadFile(filepath.Join(newHome, filepath.Base(paths.AgentEventsSpoolDir()), filepath.Base(spooled)))
+	require.NoError(t, err, "spooled events are not carried over")
+	require.Equal(t, "spooled", string(newContent))
+
+	for _, store := range stores {
+		newContent, err := ioutil.ReadFile(filepath.Join(newHome, filepath.Base(store)))
+		require.NoError(t, err, "%s is not carried over", filepath.Base(store))
+		require.Equal(t, filepath.Base(store), string(newContent))
+	}
+}
@@ -60,12 +60,17 @@ func NewCommandWithArgs(args []string, streams *cli.IOStreams) *cobra.Command {
 	cmd.AddCommand(newInstallCommandWithArgs(args, streams))
 	cmd.AddCommand(newUninstallCommandWithArgs(args, streams))
 	cmd.AddCommand(newUpgradeCommandWithArgs(args, streams))
+	cmd.AddCommand(newRestartCommandWithArgs(args, streams))
+	cmd.AddCommand(newReloadCommandWithArgs(args, streams))
 	cmd.AddCommand(newEnrollCommandWithArgs(args, streams))
+	cmd.AddCommand(newUnenrollCommandWithArgs(args, streams))
+	cmd.AddCommand(newIDCommandWithArgs(args, streams))
 	cmd.AddCommand(newInspectCommandWithArgs(args, streams))
 	cmd.AddCommand(newWatchCommandWithArgs(args, streams))
 	cmd.AddCommand(newContainerCommand(args, streams))
 	cmd.AddCommand(newStatusCommand(args, streams))
 	cmd.AddCommand(newDiagnosticsCommand(args, streams))
+	cmd.AddCommand(newKeystoreCommandWithArgs(args, streams))
 
 	// windows special hidden sub-command (only added on windows)
 	reexec := newReExecWindowsCommand(args, streams)
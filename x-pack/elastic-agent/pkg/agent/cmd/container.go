@@ -33,6 +33,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact/install/tar"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
@@ -75,6 +76,7 @@ The following actions are possible and grouped based on the actions.
   FLEET_ENROLLMENT_TOKEN - token to use for enrollment. This is not needed in case FLEET_SERVER_ENABLED and FLEET_ENROLL is set. Then the token is fetched from Kibana.
   FLEET_CA - path to certificate authority to use with communicate with Fleet Server [$KIBANA_CA]
   FLEET_INSECURE - communicate with Fleet with either insecure HTTP or unverified HTTPS
+  ELASTIC_AGENT_TAGS - comma separated list of tags sent to Fleet with the checkins of the Elastic Agent
 
   The following vars are need in the scenario that Elastic Agent should automatically fetch its own token.
 
@@ -128,9 +130,10 @@ be used when the same credentials will be used across all the possible actions a
   KIBANA_CA - path to certificate authority to use with communicate with Kibana [$ELASTICSEARCH_CA]
 
 
-By default when this command starts it will check for an existing fleet.yml. If that file already exists then
-all the above actions will be skipped, because the Elastic Agent has already been enrolled. To ensure that enrollment
-occurs on every start of the container set FLEET_FORCE to 1.
+By default when this command starts it will check for an existing fleet.yml. If that file holds an enrollment then
+all the above actions will be skipped, because the Elastic Agent has already been enrolled. The fleet.yml is stored in
+STATE_PATH (or CONFIG_PATH when set), mount it as a volume to keep the enrollment across restarts of the container.
+To ensure that enrollment occurs on every start of the container set FLEET_FORCE to 1.
 `,
 		Run: func(c *cobra.Command, args []string) {
 			if err := logContainerCmd(streams, c); err != nil {
@@ -263,9 +266,13 @@ func runContainerCmd(streams *cli.IOStreams, cmd *cobra.Command, cfg setupConfig
 		return err
 	}
 
-	_, err = os.Stat(paths.AgentConfigFile())
-	if !os.IsNotExist(err) && !cfg.Fleet.Force {
+	enrolled, err := isEnrolled(storage.NewFleetConfigStore(paths.AgentConfigFile()))
+	if err != nil {
+		return err
+	}
+	if enrolled && !cfg.Fleet.Force {
 		// already enrolled, just run the standard run
+		logInfo(streams, "Elastic Agent is already enrolled, skipping enrollment")
 		return run(streams, logToStderr)
 	}
 
@@ -334,6 +341,38 @@ func runContainerCmd(streams *cli.IOStreams, cmd *cobra.Command, cfg setupConfig
 	return run(streams, logToStderr)
 }
 
+type fleetConfigLoader interface {
+	Load() (io.ReadCloser, error)
+}
+
+// isEnrolled returns true when the fleet configuration persisted in the state directory is
+// enrolled. The file alone is not enough as it is also written by a standalone agent to persist
+// its ID, or by an enrollment which did not complete.
+func isEnrolled(store fleetConfigLoader) (bool, error) {
+	reader, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.New(err, "could not read the persisted fleet configuration",
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, paths.AgentConfigFile()))
+	}
+	defer reader.Close()
+
+	rawConfig, err := config.NewConfigFrom(reader)
+	if err != nil {
+		return false, errors.New(err, "could not parse the persisted fleet configuration",
+			errors.TypeConfig,
+			errors.M(errors.MetaKeyPath, paths.AgentConfigFile()))
+	}
+	cfg, err := configuration.NewFromConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+	return cfg.Fleet.Enabled && cfg.Fleet.AccessAPIKey != "", nil
+}
+
 // TokenResp is used to decode a response for generating a service token
 type TokenResp struct {
 	Name  string `json:"name"`
@@ -458,6 +497,9 @@ func buildEnrollArgs(cfg setupConfig, token string, policyID string) ([]string,
 	if cfg.Fleet.CA != "" {
 		args = append(args, "--certificate-authorities", cfg.Fleet.CA)
 	}
+	for _, tag := range cfg.Fleet.Tags {
+		args = append(args, "--tag", tag)
+	}
 	if token != "" {
 		args = append(args, "--enrollment-token", token)
 	}
@@ -612,6 +654,17 @@ func envTimeout(keys ...string) time.Duration {
 	return 0
 }
 
+// envList returns the comma separated values of the first defined variable.
+func envList(keys ...string) []string {
+	var list []string
+	for _, v := range strings.Split(envWithDefault("", keys...), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 func envMap(key string) map[string]string {
 	m := make(map[string]string)
 	prefix := key + "="
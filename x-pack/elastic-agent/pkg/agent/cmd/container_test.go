@@ -0,0 +1,39 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
+)
+
+func TestIsEnrolled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fleetFile := filepath.Join(dir, "fleet.yml")
+	enrolled, err := isEnrolled(storage.NewDiskStore(fleetFile))
+	require.NoError(t, err)
+	assert.False(t, enrolled)
+
+	// the ID of a standalone agent is persisted in the same file.
+	require.NoError(t, ioutil.WriteFile(fleetFile, []byte("agent:\n  id: 123\n"), 0600))
+	enrolled, err = isEnrolled(storage.NewDiskStore(fleetFile))
+	require.NoError(t, err)
+	assert.False(t, enrolled)
+
+	require.NoError(t, ioutil.WriteFile(fleetFile, []byte("agent:\n  id: 123\nfleet:\n  enabled: true\n  access_api_key: key\n  hosts: [\"localhost:8220\"]\n"), 0600))
+	enrolled, err = isEnrolled(storage.NewDiskStore(fleetFile))
+	require.NoError(t, err)
+	assert.True(t, enrolled)
+}
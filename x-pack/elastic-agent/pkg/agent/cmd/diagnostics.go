@@ -8,12 +8,10 @@ import (
 	"archive/zip"
 	"context"
 	"encoding/json"
-	stderrors "errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
+	"runtime"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -27,9 +25,13 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control/client"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control/proto"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/diagnostics"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config/operations"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/beats"
+	monitoringCfg "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/config"
 )
 
 var diagOutputs = map[string]outputter{
@@ -217,7 +219,7 @@ func diagnosticsCollectCmd(streams *cli.IOStreams, fileName, outputFormat string
 		}
 	}
 
-	err = createZip(fileName, outputFormat, diag, cfg, pprofData)
+	err = createZip(innerCtx, fileName, outputFormat, diag, cfg, pprofData)
 	if err != nil {
 		return fmt.Errorf("unable to create archive %q: %w", fileName, err)
 	}
@@ -398,8 +400,9 @@ func gatherConfig() (AgentConfig, error) {
 // createZip creates a zip archive with the passed fileName.
 //
 // The passed DiagnosticsInfo and AgentConfig data is written in the specified output format.
-// Any local log files are collected and copied into the archive.
-func createZip(fileName, outputFormat string, diag DiagnosticsInfo, cfg AgentConfig, pprof map[string][]client.ProcPProf) error {
+// Any local log files are collected and copied into the archive with the metrics of the agent and
+// of its processes.
+func createZip(ctx context.Context, fileName, outputFormat string, diag DiagnosticsInfo, cfg AgentConfig, pprof map[string][]client.ProcPProf) error {
 	f, err := os.Create(fileName)
 	if err != nil {
 		return err
@@ -460,7 +463,11 @@ func createZip(fileName, outputFormat string, diag DiagnosticsInfo, cfg AgentCon
 		}
 	}
 
-	if err := zipLogs(zw); err != nil {
+	if err := diagnostics.ZipLogs(zw); err != nil {
+		return closeHandlers(err, zw, f)
+	}
+
+	if err := diagnostics.ZipMetrics(ctx, zw, agentMonitoringEndpoint(cfg), diagnosticsProcesses(diag)); err != nil {
 		return closeHandlers(err, zw, f)
 	}
 
@@ -474,51 +481,32 @@ func createZip(fileName, outputFormat string, diag DiagnosticsInfo, cfg AgentCon
 	return closeHandlers(nil, zw, f)
 }
 
-// zipLogs walks paths.Logs() and copies the file structure into zw in "logs/"
-func zipLogs(zw *zip.Writer) error {
-	_, err := zw.Create("logs/")
-	if err != nil {
-		return err
+// agentMonitoringEndpoint returns the endpoint where the agent exposes its metrics and status
+// according to the local configuration.
+func agentMonitoringEndpoint(cfg AgentConfig) string {
+	var httpCfg *monitoringCfg.MonitoringHTTPConfig
+	if cfg.ConfigLocal != nil && cfg.ConfigLocal.Settings != nil && cfg.ConfigLocal.Settings.MonitoringConfig != nil {
+		httpCfg = cfg.ConfigLocal.Settings.MonitoringConfig.HTTP
 	}
+	return beats.AgentMonitoringEndpoint(runtime.GOOS, httpCfg)
+}
 
-	// using Data() + "/logs", for some reason default paths/Logs() is the home dir...
-	logPath := filepath.Join(paths.Home(), "logs") + string(filepath.Separator)
-	return filepath.WalkDir(logPath, func(path string, d fs.DirEntry, fErr error) error {
-		if stderrors.Is(fErr, fs.ErrNotExist) {
-			return nil
-		}
-		if fErr != nil {
-			return fmt.Errorf("unable to walk log dir: %w", fErr)
-		}
-
-		name := strings.TrimPrefix(path, logPath)
-		if name == "" {
-			return nil
-		}
-
-		if d.IsDir() {
-			_, err := zw.Create("logs/" + name + "/")
-			if err != nil {
-				return fmt.Errorf("unable to create log directory in archive: %w", err)
-			}
-			return nil
-		}
-
-		lf, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("unable to open log file: %w", err)
-		}
-		zf, err := zw.Create("logs/" + name)
-		if err != nil {
-			return closeHandlers(fmt.Errorf("unable to create log file in archive: %w", err), lf)
-		}
-		_, err = io.Copy(zf, lf)
-		if err != nil {
-			return closeHandlers(fmt.Errorf("log file copy failed: %w", err), lf)
+// diagnosticsProcesses returns the processes reported by the daemon with the endpoint exposing
+// their metrics, processes of unknown programs are skipped.
+func diagnosticsProcesses(diag DiagnosticsInfo) []diagnostics.Process {
+	procs := make([]diagnostics.Process, 0, len(diag.ProcMeta))
+	for _, m := range diag.ProcMeta {
+		spec, ok := program.SupportedMap[strings.ToLower(m.Name)]
+		if !ok {
+			continue
 		}
-
-		return lf.Close()
-	})
+		procs = append(procs, diagnostics.Process{
+			Name:     m.Name,
+			RouteKey: m.RouteKey,
+			Endpoint: beats.MonitoringEndpoint(spec, runtime.GOOS, m.RouteKey),
+		})
+	}
+	return procs
 }
 
 // writeFile writes json or yaml data from the interface to the writer.
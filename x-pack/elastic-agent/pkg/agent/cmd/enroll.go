@@ -62,16 +62,22 @@ func addEnrollFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP("fleet-server-cert", "", "", "Certificate to use for exposed Fleet Server HTTPS endpoint")
 	cmd.Flags().StringP("fleet-server-cert-key", "", "", "Private key to use for exposed Fleet Server HTTPS endpoint")
 	cmd.Flags().StringSliceP("header", "", []string{}, "Headers used in communication with elasticsearch")
+	cmd.Flags().StringSliceP("fleet-header", "", []string{}, "Headers sent with every request to Fleet, like the headers required by a gateway in front of Fleet")
 	cmd.Flags().BoolP("fleet-server-insecure-http", "", false, "Expose Fleet Server over HTTP (not recommended; insecure)")
 	cmd.Flags().StringP("certificate-authorities", "a", "", "Comma separated list of root certificate for server verifications")
 	cmd.Flags().StringP("ca-sha256", "p", "", "Comma separated list of certificate authorities hash pins used for certificate verifications")
+	cmd.Flags().StringP("elastic-agent-cert", "", "", "Client certificate used by Elastic Agent to authenticate to Fleet Server (mTLS)")
+	cmd.Flags().StringP("elastic-agent-cert-key", "", "", "Private key of the client certificate used by Elastic Agent to authenticate to Fleet Server (mTLS)")
 	cmd.Flags().BoolP("insecure", "i", false, "Allow insecure connection to fleet-server")
+	cmd.Flags().StringP("policy-signing-key", "", "", "Path to the PEM encoded public key verifying the policies signed by Fleet")
 	cmd.Flags().StringP("staging", "", "", "Configures agent to download artifacts from a staging build")
 	cmd.Flags().StringP("proxy-url", "", "", "Configures the proxy url")
 	cmd.Flags().BoolP("proxy-disabled", "", false, "Disable proxy support including environment variables")
 	cmd.Flags().StringSliceP("proxy-header", "", []string{}, "Proxy headers used with CONNECT request")
+	cmd.Flags().StringSliceP("tag", "", []string{}, "Tags sent to Fleet with the checkins of the Elastic Agent, added to the agent.tags of the configuration")
 	cmd.Flags().BoolP("delay-enroll", "", false, "Delays enrollment to occur on first start of the Elastic Agent service")
 	cmd.Flags().DurationP("daemon-timeout", "", 0, "Timeout waiting for Elastic Agent daemon")
+	cmd.Flags().DurationP("enroll-timeout", "", defaultEnrollTimeout, "Timeout waiting for Fleet to be available, the enrollment is then completed when the Elastic Agent starts (0 waits forever)")
 	cmd.Flags().DurationP("fleet-server-timeout", "", 0, "Timeout waiting for Fleet Server to be ready to start enrollment")
 }
 
@@ -92,6 +98,21 @@ func validateEnrollFlags(cmd *cobra.Command) error {
 	if fCertKey != "" && !filepath.IsAbs(fCertKey) {
 		return errors.New("--fleet-server-cert-key must be provided as an absolute path", errors.M("path", fCertKey), errors.TypeConfig)
 	}
+	cert, _ := cmd.Flags().GetString("elastic-agent-cert")
+	if cert != "" && !filepath.IsAbs(cert) {
+		return errors.New("--elastic-agent-cert must be provided as an absolute path", errors.M("path", cert), errors.TypeConfig)
+	}
+	certKey, _ := cmd.Flags().GetString("elastic-agent-cert-key")
+	if certKey != "" && !filepath.IsAbs(certKey) {
+		return errors.New("--elastic-agent-cert-key must be provided as an absolute path", errors.M("path", certKey), errors.TypeConfig)
+	}
+	signingKey, _ := cmd.Flags().GetString("policy-signing-key")
+	if signingKey != "" && !filepath.IsAbs(signingKey) {
+		return errors.New("--policy-signing-key must be provided as an absolute path", errors.M("path", signingKey), errors.TypeConfig)
+	}
+	if (cert == "") != (certKey == "") {
+		return errors.New("--elastic-agent-cert and --elastic-agent-cert-key must be provided together", errors.TypeConfig)
+	}
 	return nil
 }
 
@@ -116,13 +137,19 @@ func buildEnrollmentFlags(cmd *cobra.Command, url string, token string) []string
 	fInsecure, _ := cmd.Flags().GetBool("fleet-server-insecure-http")
 	ca, _ := cmd.Flags().GetString("certificate-authorities")
 	sha256, _ := cmd.Flags().GetString("ca-sha256")
+	cert, _ := cmd.Flags().GetString("elastic-agent-cert")
+	certKey, _ := cmd.Flags().GetString("elastic-agent-cert-key")
 	insecure, _ := cmd.Flags().GetBool("insecure")
+	signingKey, _ := cmd.Flags().GetString("policy-signing-key")
 	staging, _ := cmd.Flags().GetString("staging")
 	fProxyURL, _ := cmd.Flags().GetString("proxy-url")
 	fProxyDisabled, _ := cmd.Flags().GetBool("proxy-disabled")
 	fProxyHeaders, _ := cmd.Flags().GetStringSlice("proxy-header")
+	fleetHeaders, _ := cmd.Flags().GetStringSlice("fleet-header")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
 	delayEnroll, _ := cmd.Flags().GetBool("delay-enroll")
 	daemonTimeout, _ := cmd.Flags().GetDuration("daemon-timeout")
+	enrollTimeout, _ := cmd.Flags().GetDuration("enroll-timeout")
 	fTimeout, _ := cmd.Flags().GetDuration("fleet-server-timeout")
 
 	args := []string{}
@@ -174,6 +201,10 @@ func buildEnrollmentFlags(cmd *cobra.Command, url string, token string) []string
 		args = append(args, "--daemon-timeout")
 		args = append(args, daemonTimeout.String())
 	}
+	if enrollTimeout != defaultEnrollTimeout {
+		args = append(args, "--enroll-timeout")
+		args = append(args, enrollTimeout.String())
+	}
 	if fTimeout != 0 {
 		args = append(args, "--fleet-server-timeout")
 		args = append(args, fTimeout.String())
@@ -195,9 +226,21 @@ func buildEnrollmentFlags(cmd *cobra.Command, url string, token string) []string
 		args = append(args, "--ca-sha256")
 		args = append(args, sha256)
 	}
+	if cert != "" {
+		args = append(args, "--elastic-agent-cert")
+		args = append(args, cert)
+	}
+	if certKey != "" {
+		args = append(args, "--elastic-agent-cert-key")
+		args = append(args, certKey)
+	}
 	if insecure {
 		args = append(args, "--insecure")
 	}
+	if signingKey != "" {
+		args = append(args, "--policy-signing-key")
+		args = append(args, signingKey)
+	}
 	if staging != "" {
 		args = append(args, "--staging")
 		args = append(args, staging)
@@ -215,6 +258,14 @@ func buildEnrollmentFlags(cmd *cobra.Command, url string, token string) []string
 		args = append(args, "--proxy-header")
 		args = append(args, k+"="+v)
 	}
+	for k, v := range mapFromEnvList(fleetHeaders) {
+		args = append(args, "--fleet-header")
+		args = append(args, k+"="+v)
+	}
+	for _, tag := range tags {
+		args = append(args, "--tag")
+		args = append(args, tag)
+	}
 
 	if delayEnroll {
 		args = append(args, "--delay-enroll")
@@ -252,6 +303,10 @@ func enroll(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
 			errors.M(errors.MetaKeyPath, pathConfigFile))
 	}
 
+	if err := configuration.ConfigureFIPS(cfg); err != nil {
+		return err
+	}
+
 	staging, _ := cmd.Flags().GetString("staging")
 	if staging != "" {
 		if len(staging) < 8 {
@@ -305,14 +360,20 @@ func enroll(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
 	proxyURL, _ := cmd.Flags().GetString("proxy-url")
 	proxyDisabled, _ := cmd.Flags().GetBool("proxy-disabled")
 	proxyHeaders, _ := cmd.Flags().GetStringSlice("proxy-header")
+	fleetHeaders, _ := cmd.Flags().GetStringSlice("fleet-header")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
 	delayEnroll, _ := cmd.Flags().GetBool("delay-enroll")
 	daemonTimeout, _ := cmd.Flags().GetDuration("daemon-timeout")
+	enrollTimeout, _ := cmd.Flags().GetDuration("enroll-timeout")
 	fTimeout, _ := cmd.Flags().GetDuration("fleet-server-timeout")
 
 	caStr, _ := cmd.Flags().GetString("certificate-authorities")
 	CAs := cli.StringToSlice(caStr)
 	caSHA256str, _ := cmd.Flags().GetString("ca-sha256")
 	caSHA256 := cli.StringToSlice(caSHA256str)
+	cert, _ := cmd.Flags().GetString("elastic-agent-cert")
+	certKey, _ := cmd.Flags().GetString("elastic-agent-cert-key")
+	signingKey, _ := cmd.Flags().GetString("policy-signing-key")
 
 	ctx := handleSignal(context.Background())
 
@@ -321,15 +382,21 @@ func enroll(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
 		URL:                  url,
 		CAs:                  CAs,
 		CASha256:             caSHA256,
+		Certificate:          cert,
+		Key:                  certKey,
 		Insecure:             insecure,
+		PolicySigningKey:     signingKey,
 		UserProvidedMetadata: make(map[string]interface{}),
 		Staging:              staging,
 		FixPermissions:       fromInstall,
 		ProxyURL:             proxyURL,
 		ProxyDisabled:        proxyDisabled,
 		ProxyHeaders:         mapFromEnvList(proxyHeaders),
+		Headers:              mapFromEnvList(fleetHeaders),
+		Tags:                 tags,
 		DelayEnroll:          delayEnroll,
 		DaemonTimeout:        daemonTimeout,
+		EnrollTimeout:        enrollTimeout,
 		FleetServer: enrollCmdFleetServerOption{
 			ConnStr:               fServer,
 			ElasticsearchCA:       fElasticSearchCA,
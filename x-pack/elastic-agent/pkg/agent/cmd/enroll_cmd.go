@@ -13,6 +13,7 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -50,6 +51,7 @@ const (
 	defaultFleetServerPort         = 8220
 	defaultFleetServerInternalHost = "localhost"
 	defaultFleetServerInternalPort = 8221
+	defaultEnrollTimeout           = 10 * time.Minute
 )
 
 var (
@@ -97,16 +99,23 @@ type enrollCmdOption struct {
 	InternalURL          string                     `yaml:"-"`
 	CAs                  []string                   `yaml:"ca,omitempty"`
 	CASha256             []string                   `yaml:"ca_sha256,omitempty"`
+	Certificate          string                     `yaml:"certificate,omitempty"`
+	Key                  string                     `yaml:"key,omitempty"`
 	Insecure             bool                       `yaml:"insecure,omitempty"`
 	EnrollAPIKey         string                     `yaml:"enrollment_key,omitempty"`
 	Staging              string                     `yaml:"staging,omitempty"`
 	ProxyURL             string                     `yaml:"proxy_url,omitempty"`
 	ProxyDisabled        bool                       `yaml:"proxy_disabled,omitempty"`
 	ProxyHeaders         map[string]string          `yaml:"proxy_headers,omitempty"`
+	Headers              map[string]string          `yaml:"headers,omitempty"`
 	DaemonTimeout        time.Duration              `yaml:"daemon_timeout,omitempty"`
+	EnrollTimeout        time.Duration              `yaml:"-"`
+	PolicySigningKey     string                     `yaml:"policy_signing_key,omitempty"`
 	UserProvidedMetadata map[string]interface{}     `yaml:"-"`
+	Tags                 []string                   `yaml:"-"`
 	FixPermissions       bool                       `yaml:"-"`
 	DelayEnroll          bool                       `yaml:"-"`
+	NoRestart            bool                       `yaml:"-"`
 	FleetServer          enrollCmdFleetServerOption `yaml:"-"`
 }
 
@@ -127,6 +136,12 @@ func (e *enrollCmdOption) remoteConfig() (remote.Config, error) {
 		tlsCfg.CAs = e.CAs
 		tlsCfg.CASha256 = e.CASha256
 	}
+	if e.Certificate != "" || e.Key != "" {
+		tlsCfg.Certificate = tlscommon.CertificateConfig{
+			Certificate: e.Certificate,
+			Key:         e.Key,
+		}
+	}
 	if e.Insecure {
 		tlsCfg.VerificationMode = tlscommon.VerifyNone
 	}
@@ -139,6 +154,7 @@ func (e *enrollCmdOption) remoteConfig() (remote.Config, error) {
 	}
 
 	cfg.Transport.Proxy = *proxySettings
+	cfg.Headers = e.Headers
 
 	return cfg, nil
 }
@@ -154,7 +170,7 @@ func newEnrollCmd(
 	store := storage.NewReplaceOnSuccessStore(
 		configPath,
 		application.DefaultAgentFleetConfig,
-		storage.NewDiskStore(paths.AgentConfigFile()),
+		storage.NewFleetConfigStore(paths.AgentConfigFile()),
 	)
 
 	return newEnrollCmdWithStore(
@@ -233,6 +249,11 @@ func (c *enrollCmd) Execute(ctx context.Context, streams *cli.IOStreams) error {
 	}
 
 	err = c.enrollWithBackoff(ctx, persistentConfig)
+	if err != nil && !localFleetServer && isFleetUnavailable(err) {
+		// the agent completes the enrollment on its next start once Fleet is available.
+		c.log.Warnf("Fleet is not available, the enrollment is postponed: %v", err)
+		return c.postponeEnroll(ctx, streams)
+	}
 	if err != nil {
 		return errors.New(err, "fail to enroll")
 	}
@@ -248,6 +269,10 @@ func (c *enrollCmd) Execute(ctx context.Context, streams *cli.IOStreams) error {
 		fmt.Fprintln(streams.Out, "Successfully enrolled the Elastic Agent.")
 	}()
 
+	if c.options.NoRestart {
+		// the caller restarts the running agent once the enrollment is completed.
+		return nil
+	}
 	if c.agentProc == nil {
 		if c.daemonReload(ctx) != nil {
 			c.log.Info("Elastic Agent might not be running; unable to trigger restart")
@@ -282,6 +307,20 @@ func (c *enrollCmd) writeDelayEnroll(streams *cli.IOStreams) error {
 	return nil
 }
 
+// postponeEnroll persists the enrollment options so the enrollment is completed by the agent when
+// it starts, a running agent is restarted to wait for Fleet.
+func (c *enrollCmd) postponeEnroll(ctx context.Context, streams *cli.IOStreams) error {
+	if err := c.writeDelayEnroll(streams); err != nil {
+		return err
+	}
+	fmt.Fprintln(streams.Out, "Fleet is not available, the Elastic Agent completes the enrollment once Fleet becomes available.")
+
+	if c.agentProc == nil && c.daemonReload(ctx) != nil {
+		c.log.Info("Elastic Agent might not be running; the enrollment is completed on its next start")
+	}
+	return nil
+}
+
 func (c *enrollCmd) fleetServerBootstrap(ctx context.Context, persistentConfig map[string]interface{}) (string, error) {
 	c.log.Debug("verifying communication with running Elastic Agent daemon")
 	agentRunning := true
@@ -449,35 +488,53 @@ func (c *enrollCmd) daemonReload(ctx context.Context) error {
 	return daemon.Restart(ctx)
 }
 
+// enrollWithBackoff retries the enrollment while Fleet is unavailable, it gives up once the
+// enrollment timeout is reached. Without timeout the enrollment is retried until it succeeds.
 func (c *enrollCmd) enrollWithBackoff(ctx context.Context, persistentConfig map[string]interface{}) error {
 	delay(ctx, enrollDelay)
 
+	// the timeout only bounds the waits between retries, a request in progress is not cancelled.
+	waitCtx, cancel := context.WithCancel(ctx)
+	if c.options.EnrollTimeout > 0 {
+		waitCtx, cancel = context.WithTimeout(ctx, c.options.EnrollTimeout)
+	}
+	defer cancel()
+
 	c.log.Infof("Starting enrollment to URL: %s", c.client.URI())
 	err := c.enroll(ctx, persistentConfig)
-	signal := make(chan struct{})
-	backExp := backoff.NewExpBackoff(signal, 60*time.Second, 10*time.Minute)
+	backExp := backoff.NewExpBackoff(waitCtx.Done(), 60*time.Second, 10*time.Minute)
 
-	for {
-		retry := false
-		if errors.Is(err, fleetapi.ErrTooManyRequests) {
+	for isFleetUnavailable(err) {
+		switch {
+		case errors.Is(err, fleetapi.ErrTooManyRequests):
 			c.log.Warn("Too many requests on the remote server, will retry in a moment.")
-			retry = true
-		} else if errors.Is(err, fleetapi.ErrConnRefused) {
+		case errors.Is(err, fleetapi.ErrConnRefused):
 			c.log.Warn("Remote server is not ready to accept connections, will retry in a moment.")
-			retry = true
+		default:
+			c.log.Warn("Remote server is temporarily unavailable, will retry in a moment.")
 		}
-		if !retry {
-			break
+
+		if !backExp.Wait() {
+			return errors.New(err,
+				fmt.Sprintf("enrollment was interrupted or did not complete within %s", c.options.EnrollTimeout),
+				errors.TypeNetwork,
+				errors.M(errors.MetaKeyURI, c.client.URI()))
 		}
-		backExp.Wait()
 		c.log.Infof("Retrying enrollment to URL: %s", c.client.URI())
 		err = c.enroll(ctx, persistentConfig)
 	}
 
-	close(signal)
 	return err
 }
 
+// isFleetUnavailable returns true when the enrollment failed because Fleet cannot be reached or
+// cannot handle the request yet.
+func isFleetUnavailable(err error) bool {
+	return errors.Is(err, fleetapi.ErrTooManyRequests) ||
+		errors.Is(err, fleetapi.ErrConnRefused) ||
+		errors.Is(err, fleetapi.ErrTemporaryUnavailable)
+}
+
 func (c *enrollCmd) enroll(ctx context.Context, persistentConfig map[string]interface{}) error {
 	cmd := fleetapi.NewEnrollCmd(c.client)
 
@@ -492,6 +549,7 @@ func (c *enrollCmd) enroll(ctx context.Context, persistentConfig map[string]inte
 		Metadata: fleetapi.Metadata{
 			Local:        metadata,
 			UserProvided: c.options.UserProvidedMetadata,
+			Tags:         c.tags(persistentConfig),
 		},
 	}
 
@@ -507,6 +565,14 @@ func (c *enrollCmd) enroll(ctx context.Context, persistentConfig map[string]inte
 		return err
 	}
 
+	if c.options.PolicySigningKey != "" {
+		signingKey, err := loadPolicySigningKey(c.options.PolicySigningKey)
+		if err != nil {
+			return err
+		}
+		fleetConfig.SigningKey = signingKey
+	}
+
 	agentConfig, err := c.createAgentConfig(resp.Item.ID, persistentConfig, c.options.FleetServer.Headers)
 	if err != nil {
 		return err
@@ -549,15 +615,26 @@ func (c *enrollCmd) enroll(ctx context.Context, persistentConfig map[string]inte
 		return err
 	}
 
+	// clear events not acknowledged by the previous enrollment
+	if err := os.Remove(paths.AgentEventsStoreFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	// clear action store
 	// fail only if file exists and there was a failure
-	if err := os.Remove(paths.AgentActionStoreFile()); !os.IsNotExist(err) {
+	if err := os.Remove(paths.AgentActionStoreFile()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	// clear action store
+	// clear unencrypted state store of previous versions
 	// fail only if file exists and there was a failure
-	if err := os.Remove(paths.AgentStateStoreFile()); !os.IsNotExist(err) {
+	if err := os.Remove(paths.AgentStateStoreYmlFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// clear state store
+	// fail only if file exists and there was a failure
+	if err := os.Remove(paths.AgentStateStoreFile()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
@@ -956,6 +1033,23 @@ func createFleetConfigFromEnroll(accessAPIKey string, cli remote.Config) (*confi
 	return cfg, nil
 }
 
+// loadPolicySigningKey reads the public key verifying the policies signed by Fleet, the key is
+// stored in the fleet configuration once it is parsed successfully.
+func loadPolicySigningKey(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.New(err,
+			"could not read the policy signing key",
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, path))
+	}
+
+	if _, err := fleetapi.ParseSigningKey(content); err != nil {
+		return "", errors.New(err, "invalid policy signing key", errors.M(errors.MetaKeyPath, path))
+	}
+	return string(content), nil
+}
+
 func (c *enrollCmd) createAgentConfig(agentID string, pc map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
 	agentConfig := map[string]interface{}{
 		"id": agentID,
@@ -976,9 +1070,31 @@ func (c *enrollCmd) createAgentConfig(agentID string, pc map[string]interface{},
 		agentConfig[k] = v
 	}
 
+	if tags := c.tags(pc); len(tags) > 0 {
+		agentConfig["tags"] = tags
+	}
+
 	return agentConfig, nil
 }
 
+// tags returns the tags of the configuration followed by the tags of the enrollment flags, without
+// duplicates.
+func (c *enrollCmd) tags(pc map[string]interface{}) []string {
+	configTags, _ := pc["tags"].([]string)
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range append(append([]string{}, configTags...), c.options.Tags...) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
 func getPersistentConfig(pathConfigFile string) (map[string]interface{}, error) {
 	persistentMap := make(map[string]interface{})
 	rawConfig, err := config.LoadFile(pathConfigFile)
@@ -995,6 +1111,7 @@ func getPersistentConfig(pathConfigFile string) (map[string]interface{}, error)
 	pc := &struct {
 		LogLevel       string                                 `json:"agent.logging.level,omitempty" yaml:"agent.logging.level,omitempty" config:"agent.logging.level,omitempty"`
 		MonitoringHTTP *monitoringConfig.MonitoringHTTPConfig `json:"agent.monitoring.http,omitempty" yaml:"agent.monitoring.http,omitempty" config:"agent.monitoring.http,omitempty"`
+		Tags           []string                               `json:"agent.tags,omitempty" yaml:"agent.tags,omitempty" config:"agent.tags,omitempty"`
 	}{
 		MonitoringHTTP: monitoringConfig.DefaultConfig().HTTP,
 	}
@@ -1011,6 +1128,10 @@ func getPersistentConfig(pathConfigFile string) (map[string]interface{}, error)
 		persistentMap["monitoring.http"] = pc.MonitoringHTTP
 	}
 
+	if len(pc.Tags) > 0 {
+		persistentMap["tags"] = pc.Tags
+	}
+
 	return persistentMap, nil
 }
 
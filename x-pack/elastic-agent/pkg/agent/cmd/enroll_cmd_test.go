@@ -16,6 +16,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -25,6 +26,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/authority"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	fleetclient "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/client"
 )
 
 type mockStore struct {
@@ -313,6 +315,38 @@ func TestEnroll(t *testing.T) {
 	))
 }
 
+func TestEnrollTimeout(t *testing.T) {
+	log, _ := logger.New("tst", false)
+
+	t.Run("enrollment gives up once the timeout is reached", withServer(
+		func(t *testing.T) *http.ServeMux {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/fleet/agents/enroll", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+			return mux
+		}, func(t *testing.T, host string) {
+			options := &enrollCmdOption{
+				URL:           "http://" + host,
+				Insecure:      true,
+				EnrollAPIKey:  "my-enrollment-api-key",
+				EnrollTimeout: 100 * time.Millisecond,
+			}
+			cmd, err := newEnrollCmdWithStore(log, options, "", &mockStore{})
+			require.NoError(t, err)
+
+			cmd.remoteConfig, err = options.remoteConfig()
+			require.NoError(t, err)
+			cmd.client, err = fleetclient.NewWithConfig(log, cmd.remoteConfig)
+			require.NoError(t, err)
+
+			err = cmd.enrollWithBackoff(context.Background(), map[string]interface{}{})
+			require.Error(t, err)
+			require.True(t, isFleetUnavailable(err))
+		},
+	))
+}
+
 func withServer(
 	m func(t *testing.T) *http.ServeMux,
 	test func(t *testing.T, host string),
@@ -324,6 +358,39 @@ func withServer(
 	}
 }
 
+func TestRemoteConfigWithClientCertificate(t *testing.T) {
+	options := &enrollCmdOption{
+		URL:         "https://localhost:8220",
+		CAs:         []string{"/etc/ca.crt"},
+		Certificate: "/etc/agent.crt",
+		Key:         "/etc/agent.key",
+	}
+
+	cfg, err := options.remoteConfig()
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Transport.TLS)
+	require.Equal(t, []string{"/etc/ca.crt"}, cfg.Transport.TLS.CAs)
+	require.Equal(t, "/etc/agent.crt", cfg.Transport.TLS.Certificate.Certificate)
+	require.Equal(t, "/etc/agent.key", cfg.Transport.TLS.Certificate.Key)
+}
+
+func TestEnrollTags(t *testing.T) {
+	f, err := ioutil.TempFile("", "elastic-agent.yml")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("agent.tags: [\"datacenter-1\", \"team-a\"]\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	pc, err := getPersistentConfig(f.Name())
+	require.NoError(t, err)
+
+	c := &enrollCmd{options: &enrollCmdOption{Tags: []string{"team-a", " linux ", ""}}}
+	agentConfig, err := c.createAgentConfig("agent-id", pc, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"datacenter-1", "team-a", "linux"}, agentConfig["tags"])
+}
+
 func withTLSServer(
 	m func(t *testing.T) *http.ServeMux,
 	test func(t *testing.T, caBytes []byte, host string),
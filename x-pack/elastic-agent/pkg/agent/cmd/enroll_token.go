@@ -0,0 +1,163 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+// enrollmentTokenFile is the content of the token file written by provisioning tools, the file
+// contains either the enrollment token alone or the enrollment options below.
+type enrollmentTokenFile struct {
+	URL             string   `yaml:"url"`
+	EnrollmentToken string   `yaml:"enrollment_token"`
+	CAs             []string `yaml:"ca"`
+	Insecure        bool     `yaml:"insecure"`
+	Tags            []string `yaml:"tags"`
+}
+
+// enrollmentTokenWatcher watches the token file of a standalone agent, the agent is enrolled once
+// the file is written. The file is removed after the enrollment and the agent is restarted so it
+// runs in managed mode.
+type enrollmentTokenWatcher struct {
+	log     *logger.Logger
+	path    string
+	url     string
+	period  time.Duration
+	enroll  func(ctx context.Context, options *enrollCmdOption) error
+	restart func()
+
+	// failed is the modification time of the token file which failed to enroll the agent, the
+	// enrollment is retried once the file is written again.
+	failed time.Time
+}
+
+func newEnrollmentTokenWatcher(log *logger.Logger, cfg *configuration.EnrollmentConfig, restart func()) *enrollmentTokenWatcher {
+	path := cfg.TokenFile
+	if path == "" {
+		path = paths.AgentEnrollmentTokenFile()
+	}
+	return &enrollmentTokenWatcher{
+		log:     log,
+		path:    path,
+		url:     cfg.URL,
+		period:  cfg.Period,
+		enroll:  enrollWithToken(log),
+		restart: restart,
+	}
+}
+
+// Run checks the token file periodically until the agent is enrolled or the context is cancelled.
+func (w *enrollmentTokenWatcher) Run(ctx context.Context) {
+	w.log.Infof("Waiting for an enrollment token file at %s", w.path)
+
+	t := time.NewTicker(w.period)
+	defer t.Stop()
+	for {
+		if w.check(ctx) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// check enrolls the agent when the token file exists, it returns true once the agent is enrolled.
+func (w *enrollmentTokenWatcher) check(ctx context.Context) bool {
+	info, err := os.Stat(w.path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil {
+		w.log.Error(errors.New(err, "failed to stat enrollment token file", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, w.path)))
+		return false
+	}
+	if info.ModTime().Equal(w.failed) {
+		return false
+	}
+
+	options, err := w.read()
+	if err == nil {
+		w.log.Infof("Enrollment token file found at %s, enrolling the Elastic Agent", w.path)
+		err = w.enroll(ctx, options)
+	}
+	if ctx.Err() != nil {
+		return true
+	}
+	if err != nil {
+		w.log.Error(errors.New(err, "failed to enroll with the enrollment token file, waiting for the file to change", errors.M(errors.MetaKeyPath, w.path)))
+		w.failed = info.ModTime()
+		return false
+	}
+
+	if err := os.Remove(w.path); err != nil {
+		w.log.Warn(errors.New(err, "failed to remove enrollment token file", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, w.path)))
+	}
+	w.log.Info("Successfully enrolled this Elastic Agent with the enrollment token file, restarting in managed mode.")
+	w.restart()
+	return true
+}
+
+// read returns the enrollment options of the token file.
+func (w *enrollmentTokenWatcher) read() (*enrollCmdOption, error) {
+	contents, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return nil, errors.New(err, "failed to read enrollment token file", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, w.path))
+	}
+
+	var file enrollmentTokenFile
+	var token string
+	if yaml.Unmarshal(contents, &token) == nil {
+		file.EnrollmentToken = strings.TrimSpace(token)
+	} else if err := yaml.Unmarshal(contents, &file); err != nil {
+		return nil, errors.New(err, "failed to parse enrollment token file", errors.TypeConfig, errors.M(errors.MetaKeyPath, w.path))
+	}
+
+	if file.URL == "" {
+		file.URL = w.url
+	}
+	if file.EnrollmentToken == "" {
+		return nil, errors.New("enrollment token file does not contain an enrollment token", errors.TypeConfig, errors.M(errors.MetaKeyPath, w.path))
+	}
+	if file.URL == "" {
+		return nil, errors.New("enrollment token file does not contain the URL of Fleet and agent.enrollment.url is not set", errors.TypeConfig, errors.M(errors.MetaKeyPath, w.path))
+	}
+
+	return &enrollCmdOption{
+		URL:          file.URL,
+		CAs:          file.CAs,
+		Insecure:     file.Insecure,
+		EnrollAPIKey: file.EnrollmentToken,
+		Tags:         file.Tags,
+		// the agent waits for Fleet until the enrollment succeeds.
+		EnrollTimeout: 0,
+		NoRestart:     true,
+	}, nil
+}
+
+func enrollWithToken(log *logger.Logger) func(ctx context.Context, options *enrollCmdOption) error {
+	return func(ctx context.Context, options *enrollCmdOption) error {
+		c, err := newEnrollCmd(log, options, paths.ConfigFile())
+		if err != nil {
+			return err
+		}
+		return c.Execute(ctx, cli.NewIOStreams())
+	}
+}
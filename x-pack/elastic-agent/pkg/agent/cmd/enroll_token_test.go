@@ -0,0 +1,116 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func TestEnrollmentTokenWatcher(t *testing.T) {
+	log, _ := logger.New("tst", false)
+
+	newWatcher := func(t *testing.T, enrollErr error) (*enrollmentTokenWatcher, *[]*enrollCmdOption, *int) {
+		var enrolled []*enrollCmdOption
+		restarts := 0
+		w := newEnrollmentTokenWatcher(log, &configuration.EnrollmentConfig{
+			Watch:     true,
+			TokenFile: filepath.Join(t.TempDir(), "enrollment_token.yml"),
+			URL:       "https://fleet.local:8220",
+			Period:    10 * time.Millisecond,
+		}, func() { restarts++ })
+		w.enroll = func(_ context.Context, options *enrollCmdOption) error {
+			enrolled = append(enrolled, options)
+			return enrollErr
+		}
+		return w, &enrolled, &restarts
+	}
+
+	t.Run("nothing happens without token file", func(t *testing.T) {
+		w, enrolled, restarts := newWatcher(t, nil)
+		assert.False(t, w.check(context.Background()))
+		assert.Empty(t, *enrolled)
+		assert.Equal(t, 0, *restarts)
+	})
+
+	t.Run("token alone uses the configured url", func(t *testing.T) {
+		w, enrolled, restarts := newWatcher(t, nil)
+		require.NoError(t, ioutil.WriteFile(w.path, []byte("my-enrollment-token\n"), 0600))
+
+		assert.True(t, w.check(context.Background()))
+		require.Len(t, *enrolled, 1)
+		assert.Equal(t, "my-enrollment-token", (*enrolled)[0].EnrollAPIKey)
+		assert.Equal(t, "https://fleet.local:8220", (*enrolled)[0].URL)
+		assert.True(t, (*enrolled)[0].NoRestart)
+		assert.Equal(t, 1, *restarts)
+
+		_, err := os.Stat(w.path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("token file with options", func(t *testing.T) {
+		w, enrolled, _ := newWatcher(t, nil)
+		require.NoError(t, ioutil.WriteFile(w.path, []byte(`
+url: https://other.local:8220
+enrollment_token: other-token
+insecure: true
+tags: [image, edge]
+`), 0600))
+
+		assert.True(t, w.check(context.Background()))
+		require.Len(t, *enrolled, 1)
+		assert.Equal(t, "other-token", (*enrolled)[0].EnrollAPIKey)
+		assert.Equal(t, "https://other.local:8220", (*enrolled)[0].URL)
+		assert.True(t, (*enrolled)[0].Insecure)
+		assert.Equal(t, []string{"image", "edge"}, (*enrolled)[0].Tags)
+	})
+
+	t.Run("failed enrollment is retried once the file changes", func(t *testing.T) {
+		w, enrolled, restarts := newWatcher(t, errors.New("invalid token"))
+		require.NoError(t, ioutil.WriteFile(w.path, []byte("bad-token"), 0600))
+
+		assert.False(t, w.check(context.Background()))
+		assert.False(t, w.check(context.Background()))
+		assert.Len(t, *enrolled, 1)
+		assert.Equal(t, 0, *restarts)
+
+		// the token file is kept so it can be fixed.
+		_, err := os.Stat(w.path)
+		require.NoError(t, err)
+
+		changed := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(w.path, changed, changed))
+		assert.False(t, w.check(context.Background()))
+		assert.Len(t, *enrolled, 2)
+	})
+
+	t.Run("run stops once enrolled", func(t *testing.T) {
+		w, _, restarts := newWatcher(t, nil)
+		done := make(chan struct{})
+		go func() {
+			w.Run(context.Background())
+			close(done)
+		}()
+
+		require.NoError(t, ioutil.WriteFile(w.path, []byte("my-enrollment-token"), 0600))
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("watcher did not enroll the agent")
+		}
+		assert.Equal(t, 1, *restarts)
+	})
+}
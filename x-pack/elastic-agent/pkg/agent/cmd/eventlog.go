@@ -0,0 +1,14 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !windows
+// +build !windows
+
+package cmd
+
+// logServiceEvent does nothing, only the Windows service writes to the event log.
+func logServiceEvent(_ string) {}
+
+// logServiceError does nothing, only the Windows service writes to the event log.
+func logServiceError(_ error) {}
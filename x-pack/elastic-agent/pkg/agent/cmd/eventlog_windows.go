@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+)
+
+// Identifiers of the events written to the event log, the source of the events is registered
+// when the service is installed.
+const (
+	eventIDInfo  = 1
+	eventIDError = 2
+)
+
+// logServiceEvent writes the message to the event log when the agent runs as a Windows service.
+func logServiceEvent(msg string) {
+	withEventLog(func(el *eventlog.Log) error {
+		return el.Info(eventIDInfo, msg)
+	})
+}
+
+// logServiceError writes the error to the event log when the agent runs as a Windows service, so
+// the failures of the service are visible without reading the logs of the agent.
+func logServiceError(err error) {
+	withEventLog(func(el *eventlog.Log) error {
+		return el.Error(eventIDError, fmt.Sprintf("Elastic Agent failed: %v\n%s", err, troubleshootMessage()))
+	})
+}
+
+func withEventLog(fn func(*eventlog.Log) error) {
+	if isService, err := svc.IsWindowsService(); err != nil || !isService {
+		return
+	}
+
+	el, err := eventlog.Open(paths.ServiceName)
+	if err != nil {
+		return
+	}
+	defer el.Close()
+
+	// the event log is best effort, the logs of the agent remain the reference.
+	_ = fn(el)
+}
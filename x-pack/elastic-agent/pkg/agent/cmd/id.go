@@ -0,0 +1,188 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	c "github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/filelock"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/install"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func newIDCommandWithArgs(args []string, streams *cli.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "id",
+		Short: "Show or regenerate the ID of this Elastic Agent",
+		Long: `The ID of a standalone Elastic Agent is generated on its first start, the ID of an enrolled Elastic Agent
+is assigned by Fleet. The host on which the ID is used is recorded, a standalone Elastic Agent started on another
+host, like a VM cloned from the image of an existing agent, generates a new ID.`,
+	}
+
+	cmd.AddCommand(newIDShowCommandWithArgs(args, streams))
+	cmd.AddCommand(newIDRegenerateCommandWithArgs(args, streams))
+
+	return cmd
+}
+
+func newIDShowCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the ID of this Elastic Agent",
+		Args:  cobra.ExactArgs(0),
+		Run: func(c *cobra.Command, args []string) {
+			if err := idShowCmd(streams); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+func newIDRegenerateCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "regenerate",
+		Short: "Replace the ID of this Elastic Agent with a new one",
+		Long: `This will stop the running Elastic Agent and replace its ID with a new one.
+
+The ID of an enrolled Elastic Agent is assigned by Fleet, Fleet is notified that the agent stops using its ID and the
+enrollment is removed like with the unenroll command. The agent has to be enrolled again to be assigned a new ID.
+
+Unless -f is used this command will ask confirmation before regenerating the ID.
+`,
+		Args: cobra.ExactArgs(0),
+		Run: func(c *cobra.Command, args []string) {
+			if err := idRegenerateCmd(streams, c); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolP("force", "f", false, "Force the regeneration and do not prompt for confirmation")
+	cmd.Flags().Duration("timeout", 30*time.Second, "Maximum time to wait for the agent to stop and for Fleet to be notified")
+
+	return cmd
+}
+
+func idShowCmd(streams *cli.IOStreams) error {
+	identity, err := info.LoadIdentity()
+	if err != nil {
+		return err
+	}
+
+	if identity.ID == "" {
+		fmt.Fprintln(streams.Out, "Elastic Agent has no ID yet, it is generated on its first start.")
+		return nil
+	}
+
+	mode := "standalone"
+	if identity.Managed {
+		mode = "managed by Fleet"
+	}
+	fmt.Fprintf(streams.Out, "ID: %s\n", identity.ID)
+	fmt.Fprintf(streams.Out, "Mode: %s\n", mode)
+	if identity.Cloned {
+		if identity.Managed {
+			fmt.Fprintln(streams.Out, "Warning: the ID was used on another host, run 'elastic-agent id regenerate' and enroll again to get a new ID.")
+		} else {
+			fmt.Fprintln(streams.Out, "Warning: the ID was used on another host, a new ID is generated on the next start.")
+		}
+	}
+	return nil
+}
+
+func idRegenerateCmd(streams *cli.IOStreams, cmd *cobra.Command) error {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+	managed := !configuration.IsStandalone(cfg.Fleet)
+
+	status, _ := install.Status()
+	if err := checkInstalledRights(status, "regenerated"); err != nil {
+		return err
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if !force {
+		msg := "Elastic Agent will be stopped and its ID regenerated. Do you want to continue?"
+		if managed {
+			msg = "Elastic Agent will be stopped, unenrolled from Fleet and its ID regenerated. Do you want to continue?"
+		}
+		confirm, err := c.Confirm(msg, true)
+		if err != nil {
+			return fmt.Errorf("problem reading prompt response")
+		}
+		if !confirm {
+			return fmt.Errorf("regeneration of the ID was cancelled by the user")
+		}
+	}
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if status == install.Installed {
+		fmt.Fprintln(streams.Out, "Stopping Elastic Agent service")
+		if err := install.StopService(); err != nil {
+			fmt.Fprintf(streams.Err, "Warning: %v\n", err)
+		}
+	}
+
+	// hold the lock so the agent cannot be started with the previous ID.
+	locker := filelock.NewAppLocker(paths.Data(), paths.AgentLockFileName)
+	if err := waitForLock(ctx, locker); err != nil {
+		if err == filelock.ErrAppAlreadyRunning {
+			return fmt.Errorf("elastic agent is still running, stop it before regenerating its ID")
+		}
+		return err
+	}
+	defer locker.Unlock()
+
+	if managed {
+		log, err := logger.NewFromConfig("", cfg.Settings.LoggingConfig, false)
+		if err != nil {
+			return err
+		}
+
+		event := fleetNoticeEvent{ts: time.Now(), message: "Elastic Agent is regenerating its ID and stops using this ID"}
+		if err := notifyFleet(ctx, log, cfg.Fleet, storage.NewDiskStore(paths.AgentEventsStoreFile()), event); err != nil {
+			fmt.Fprintf(streams.Err, "Warning: could not notify Fleet, %v\n", err)
+		}
+		if err := removeFleetEnrollment(streams); err != nil {
+			return err
+		}
+	}
+
+	id, err := info.RegenerateID()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(streams.Out, "Elastic Agent ID has been regenerated: %s\n", id)
+
+	if managed {
+		fmt.Fprintln(streams.Out, "Elastic Agent has been unenrolled, enroll it again to be assigned a new ID by Fleet.")
+		return nil
+	}
+	if status == install.Installed {
+		// release the lock so the service can start.
+		locker.Unlock()
+		fmt.Fprintln(streams.Out, "Starting Elastic Agent service")
+		return install.StartService()
+	}
+	return nil
+}
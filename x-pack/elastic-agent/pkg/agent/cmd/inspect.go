@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
@@ -29,9 +30,8 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config/operations"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
-	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/noop"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
-	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets"
 	"github.com/elastic/go-sysinfo"
 )
 
@@ -50,6 +50,7 @@ func newInspectCommandWithArgs(s []string, streams *cli.IOStreams) *cobra.Comman
 	}
 
 	cmd.AddCommand(newInspectOutputCommandWithArgs(s, streams))
+	cmd.AddCommand(newInspectRenderedCommandWithArgs(s, streams))
 
 	return cmd
 }
@@ -83,6 +84,32 @@ func newInspectOutputCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.
 	return cmd
 }
 
+func newInspectRenderedCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rendered",
+		Short: "Displays the effective configuration of the agent and of its programs",
+		Long: `Displays the configuration of the agent once the policy is decoded, the variables are substituted and the
+secrets are resolved, followed by the configuration of every program started by the agent grouped by output.
+This is the configuration the programs receive, use it to troubleshoot a policy.`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(c *cobra.Command, args []string) error {
+			outName, _ := c.Flags().GetString("output")
+			program, _ := c.Flags().GetString("program")
+			agentInfo, err := info.NewAgentInfo(false)
+			if err != nil {
+				return err
+			}
+
+			return inspectRendered(paths.ConfigFile(), outName, program, agentInfo)
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", "name of the output whose programs are displayed")
+	cmd.Flags().StringP("program", "p", "", "type of program to display, e.g filebeat")
+
+	return cmd
+}
+
 func inspectConfig(cfgPath string) error {
 	err := tryContainerLoadPaths()
 	if err != nil {
@@ -250,73 +277,155 @@ func printOutputFromMap(log *logger.Logger, agentInfo *info.AgentInfo, output, p
 	return printOutputFromConfig(log, agentInfo, output, programName, c, isStandalone)
 }
 
+func inspectRendered(cfgPath, output, programName string, agentInfo *info.AgentInfo) error {
+	err := tryContainerLoadPaths()
+	if err != nil {
+		return err
+	}
+
+	l, err := newErrorLogger()
+	if err != nil {
+		return err
+	}
+
+	fullCfg, err := operations.LoadFullAgentConfig(cfgPath, true)
+	if err != nil {
+		return err
+	}
+
+	isStandalone, err := isStandalone(fullCfg)
+	if err != nil {
+		return err
+	}
+
+	ast, programsGroup, err := renderConfig(l, agentInfo, fullCfg, isStandalone)
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		if _, ok := programsGroup[output]; !ok {
+			return fmt.Errorf("output '%s' is not recognized, try running `elastic-agent inspect output` to find available outputs", output)
+		}
+	}
+
+	rendered, err := ast.Map()
+	if err != nil {
+		return errors.New(err, "could not convert the rendered configuration", errors.TypeConfig)
+	}
+	fmt.Println("[agent]:")
+	if err := printMapStringConfig(rendered); err != nil {
+		return err
+	}
+	fmt.Println("---")
+
+	outputs := make([]string, 0, len(programsGroup))
+	for k := range programsGroup {
+		outputs = append(outputs, k)
+	}
+	sort.Strings(outputs)
+
+	var programFound bool
+	for _, k := range outputs {
+		if output != "" && k != output {
+			continue
+		}
+
+		for _, p := range programsGroup[k] {
+			if programName != "" && programName != p.Spec.Cmd {
+				continue
+			}
+
+			programFound = true
+			fmt.Printf("[%s] %s:\n", k, p.Spec.Cmd)
+			if err := printMapStringConfig(p.Configuration()); err != nil {
+				return err
+			}
+			fmt.Println("---")
+		}
+	}
+
+	if programName != "" && !programFound {
+		return fmt.Errorf("program '%s' is not started by the agent, try running `elastic-agent inspect output` to find available outputs", programName)
+	}
+	return nil
+}
+
 func getProgramsFromConfig(log *logger.Logger, agentInfo *info.AgentInfo, cfg *config.Config, isStandalone bool) (map[string][]program.Program, error) {
-	monitor := noop.NewMonitor()
-	router := &inmemRouter{}
+	_, programs, err := renderConfig(log, agentInfo, cfg, isStandalone)
+	return programs, err
+}
+
+// renderConfig renders the configuration like the running agent does, it returns the agent-level
+// configuration with the inputs rendered and the programs created from it grouped by output.
+func renderConfig(log *logger.Logger, agentInfo *info.AgentInfo, cfg *config.Config, isStandalone bool) (*transpiler.AST, map[string][]program.Program, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	composableCtrl, err := composable.New(log, cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	composableWaiter := newWaitForCompose(composableCtrl)
 	configModifiers := &pipeline.ConfigModifiers{
-		Decorators: []pipeline.DecoratorFunc{modifiers.InjectMonitoring},
+		Decorators: []pipeline.DecoratorFunc{modifiers.InjectMonitoring, modifiers.InjectProgramCredentials(inspectIssuer{})},
 		Filters:    []pipeline.FilterFunc{filters.StreamChecker},
 	}
 
-	if !isStandalone {
+	if isStandalone {
+		secrets, err := operations.LoadKeystore()
+		if err != nil {
+			return nil, nil, err
+		}
+		configModifiers.Filters = append(configModifiers.Filters, filters.KeystoreResolver(secrets))
+	} else {
 		sysInfo, err := sysinfo.Host()
 		if err != nil {
-			return nil, errors.New(err,
+			return nil, nil, errors.New(err,
 				"fail to get system information",
 				errors.TypeUnexpected)
 		}
 		configModifiers.Filters = append(configModifiers.Filters, modifiers.InjectFleet(cfg, sysInfo.Info(), agentInfo))
 	}
 
-	caps, err := capabilities.Load(paths.AgentCapabilitiesPath(), log, status.NewController(log))
+	// the secrets providers are configured by the local configuration, also in fleet mode.
+	localCfg, err := config.LoadFile(paths.ConfigFile())
 	if err != nil {
-		return nil, err
-	}
-
-	emit, err := emitter.New(
-		ctx,
-		log,
-		agentInfo,
-		composableWaiter,
-		router,
-		configModifiers,
-		caps,
-		monitor,
-	)
+		return nil, nil, err
+	}
+	agentCfg, err := configuration.NewFromConfig(localCfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	secretsResolver, err := secrets.NewResolver(agentCfg.Settings.SecretsConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	configModifiers.Filters = append(configModifiers.Filters, filters.SecretsResolver(secretsResolver))
+
+	caps, err := capabilities.Load(paths.AgentCapabilitiesPath(), log, status.NewController(log))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := emit(cfg); err != nil {
-		return nil, err
+	// the configuration is only rendered, nothing is routed.
+	ctrl := emitter.NewController(log, agentInfo, composableWaiter, nil, configModifiers, caps)
+	if err := composableWaiter.Run(ctx, ctrl.Set); err != nil {
+		return nil, nil, errors.New(err, "failed to start composable controller")
 	}
 	composableWaiter.Wait()
-	return router.programs, nil
-}
 
-type inmemRouter struct {
-	programs map[string][]program.Program
+	return ctrl.RenderConfig(cfg)
 }
 
-func (r *inmemRouter) Routes() *sorted.Set {
-	return nil
-}
+// inspectIssuer does not issue the API keys of the programs, inspecting has no side effect on
+// Elasticsearch.
+type inspectIssuer struct{}
 
-func (r *inmemRouter) Route(id string, grpProg map[pipeline.RoutingKey][]program.Program) error {
-	r.programs = grpProg
-	return nil
+func (inspectIssuer) Issue(_, program string, _ []string, _ map[string]interface{}) (string, error) {
+	return fmt.Sprintf("<issued for %s>", program), nil
 }
 
-func (r *inmemRouter) Shutdown() {}
-
 type waitForCompose struct {
 	controller composable.Controller
 	done       chan bool
@@ -25,6 +25,12 @@ func newInstallCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Comman
 
 Unless all the require command-line parameters are provided or -f is used this command will ask questions on how you
 would like the Agent to operate.
+
+On Windows the service starts automatically with a delayed start, it is restarted after 1s, 10s and 1m
+on consecutive failures and reports its startup and failures to the event log.
+
+On Linux the systemd service is started once the agent applies its first policy, systemd restarts the
+agent when it does not ping the watchdog for 5 minutes.
 `,
 		Run: func(c *cobra.Command, args []string) {
 			if err := installCmd(streams, c, args); err != nil {
@@ -0,0 +1,225 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	tml "golang.org/x/crypto/ssh/terminal"
+
+	"github.com/elastic/beats/v7/libbeat/keystore"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
+)
+
+func newKeystoreCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keystore",
+		Short: "Manage the secrets referenced by the standalone configuration",
+		Long: `Manage the secrets referenced by the standalone configuration.
+
+A secret added to the keystore is referenced as ${KEY} in the configuration, the Elastic Agent
+must be restarted to use the secrets added or removed while it is running.
+`,
+	}
+
+	cmd.AddCommand(newKeystoreAddCommand(streams))
+	cmd.AddCommand(newKeystoreListCommand(streams))
+	cmd.AddCommand(newKeystoreRemoveCommand(streams))
+
+	return cmd
+}
+
+func newKeystoreAddCommand(streams *cli.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <key>",
+		Short: "Add a secret to the keystore",
+		Args:  cobra.ExactArgs(1),
+		Run: func(c *cobra.Command, args []string) {
+			if err := keystoreAddCmd(streams, c, args); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().Bool("stdin", false, "Read the secret from stdin")
+	cmd.Flags().BoolP("force", "f", false, "Overwrite the existing secret")
+
+	return cmd
+}
+
+func newKeystoreListCommand(streams *cli.IOStreams) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the keys of the secrets in the keystore",
+		Args:  cobra.NoArgs,
+		Run: func(c *cobra.Command, args []string) {
+			if err := keystoreListCmd(streams); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+func newKeystoreRemoveCommand(streams *cli.IOStreams) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <key>...",
+		Short: "Remove secrets from the keystore",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(c *cobra.Command, args []string) {
+			if err := keystoreRemoveCmd(streams, args); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+func keystoreAddCmd(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
+	store, err := openKeystore()
+	if err != nil {
+		return err
+	}
+
+	key := strings.TrimSpace(args[0])
+	stdin, _ := cmd.Flags().GetBool("stdin")
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := store.Retrieve(key); err == nil && !force {
+		return fmt.Errorf("the secret %s already exists in the keystore, use --force to overwrite it", key)
+	}
+
+	var value []byte
+	if stdin {
+		value, err = ioutil.ReadAll(streams.In)
+		if err != nil {
+			return errors.New(err, "could not read the secret from stdin")
+		}
+	} else {
+		fmt.Fprintf(streams.Out, "Enter value for %s: ", key)
+		value, err = tml.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(streams.Out)
+		if err != nil {
+			return errors.New(err, "could not read the secret")
+		}
+	}
+
+	if err := keystoreAdd(store, key, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(streams.Out, "Successfully added %s to the keystore\n", key)
+	return nil
+}
+
+func keystoreListCmd(streams *cli.IOStreams) error {
+	store, err := openKeystore()
+	if err != nil {
+		return err
+	}
+	return keystoreList(streams.Out, store)
+}
+
+func keystoreRemoveCmd(streams *cli.IOStreams, args []string) error {
+	store, err := openKeystore()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(args))
+	for _, key := range args {
+		keys = append(keys, strings.TrimSpace(key))
+	}
+	if err := keystoreRemove(store, keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		fmt.Fprintf(streams.Out, "Successfully removed %s from the keystore\n", key)
+	}
+	return nil
+}
+
+func openKeystore() (keystore.Keystore, error) {
+	if err := tryContainerLoadPaths(); err != nil {
+		return nil, err
+	}
+
+	path := paths.AgentKeystoreFile()
+	store, err := keystore.NewFileKeystore(path)
+	if err != nil {
+		return nil, errors.New(err, "could not load the keystore",
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, path))
+	}
+	return store, nil
+}
+
+// keystoreAdd stores the secret, the keystore is created when it does not exist yet.
+func keystoreAdd(store keystore.Keystore, key string, value []byte) error {
+	if key == "" {
+		return errors.New("the key of the secret cannot be empty")
+	}
+
+	writable, err := keystore.AsWritableKeystore(store)
+	if err != nil {
+		return err
+	}
+	if err := writable.Store(key, value); err != nil {
+		return errors.New(err, fmt.Sprintf("could not add %s to the keystore", key))
+	}
+	if err := writable.Save(); err != nil {
+		return errors.New(err, "could not save the keystore", errors.TypeFilesystem)
+	}
+	return nil
+}
+
+func keystoreList(out io.Writer, store keystore.Keystore) error {
+	listing, err := keystore.AsListingKeystore(store)
+	if err != nil {
+		return err
+	}
+	keys, err := listing.List()
+	if err != nil {
+		return errors.New(err, "could not read the keystore", errors.TypeFilesystem)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintln(out, key)
+	}
+	return nil
+}
+
+// keystoreRemove removes the secrets, none is removed when one of them is missing.
+func keystoreRemove(store keystore.Keystore, keys ...string) error {
+	if !store.IsPersisted() {
+		return errors.New("the keystore does not exist")
+	}
+
+	writable, err := keystore.AsWritableKeystore(store)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := store.Retrieve(key); err != nil {
+			return fmt.Errorf("could not find %s in the keystore", key)
+		}
+	}
+	for _, key := range keys {
+		if err := writable.Delete(key); err != nil {
+			return errors.New(err, fmt.Sprintf("could not remove %s from the keystore", key))
+		}
+	}
+	if err := writable.Save(); err != nil {
+		return errors.New(err, "could not save the keystore", errors.TypeFilesystem)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/keystore"
+)
+
+func TestKeystore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "elastic-agent.keystore")
+	open := func() keystore.Keystore {
+		store, err := keystore.NewFileKeystore(path)
+		require.NoError(t, err)
+		return store
+	}
+
+	require.Error(t, keystoreRemove(open(), "ES_PASSWORD"))
+	require.Error(t, keystoreAdd(open(), "", []byte("secret")))
+
+	require.NoError(t, keystoreAdd(open(), "ES_PASSWORD", []byte("secret")))
+	require.NoError(t, keystoreAdd(open(), "API_KEY", []byte("key")))
+
+	secret, err := open().Retrieve("ES_PASSWORD")
+	require.NoError(t, err)
+	value, err := secret.Get()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), value)
+
+	var out bytes.Buffer
+	require.NoError(t, keystoreList(&out, open()))
+	assert.Equal(t, "API_KEY\nES_PASSWORD\n", out.String())
+
+	// nothing is removed when one of the keys is missing.
+	require.Error(t, keystoreRemove(open(), "API_KEY", "UNKNOWN"))
+	require.NoError(t, keystoreRemove(open(), "API_KEY"))
+
+	out.Reset()
+	require.NoError(t, keystoreList(&out, open()))
+	assert.Equal(t, "ES_PASSWORD\n", out.String())
+}
@@ -0,0 +1,47 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control/client"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
+)
+
+func newReloadCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reload",
+		Short: "Reload the configuration of the currently running standalone Elastic Agent",
+		Long:  "Reload reads the configuration files again and applies them right away, without waiting for the next reload period.",
+		Args:  cobra.NoArgs,
+		Run: func(c *cobra.Command, args []string) {
+			if err := reloadCmd(streams); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+func reloadCmd(streams *cli.IOStreams) error {
+	c := client.New()
+	err := c.Connect(context.Background())
+	if err != nil {
+		return errors.New(err, "Failed communicating to running daemon", errors.TypeNetwork, errors.M("socket", control.Address()))
+	}
+	defer c.Disconnect()
+	if err := c.Reload(context.Background()); err != nil {
+		return errors.New(err, "Failed reloading the configuration of daemon")
+	}
+	fmt.Fprintln(streams.Out, "Configuration of Elastic Agent reloaded")
+	return nil
+}
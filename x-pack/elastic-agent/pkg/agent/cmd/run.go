@@ -37,6 +37,8 @@ import (
 	monitoringCfg "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/config"
 	monitoringServer "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/server"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/systemd"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 )
 
@@ -46,12 +48,18 @@ const (
 
 type cfgOverrider func(cfg *configuration.Configuration)
 
+// reloader is implemented by the applications able to reload their configuration on demand.
+type reloader interface {
+	Reload() error
+}
+
 func newRunCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
 	return &cobra.Command{
 		Use:   "run",
 		Short: "Start the elastic-agent.",
 		Run: func(_ *cobra.Command, _ []string) {
 			if err := run(streams, nil); err != nil {
+				logServiceError(err)
 				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
 				os.Exit(1)
 			}
@@ -95,6 +103,14 @@ func run(streams *cli.IOStreams, override cfgOverrider) error {
 		return err
 	}
 
+	if err := configuration.ConfigureFIPS(cfg); err != nil {
+		logger.Error(err)
+		return err
+	}
+	if fips.Enabled() {
+		logger.Info("Elastic Agent is running in FIPS mode.")
+	}
+
 	cfg, err = tryDelayEnroll(ctx, logger, cfg, override)
 	if err != nil {
 		err = errors.New(err, "failed to perform delayed enrollment")
@@ -148,9 +164,12 @@ func run(streams *cli.IOStreams, override cfgOverrider) error {
 	}
 
 	control.SetRouteFn(app.Routes)
+	if r, ok := app.(reloader); ok {
+		control.SetReloadFn(r.Reload)
+	}
 	control.SetMonitoringCfg(cfg.Settings.MonitoringConfig)
 
-	serverStopFn, err := setupMetrics(agentInfo, logger, cfg.Settings.DownloadConfig.OS(), cfg.Settings.MonitoringConfig, app)
+	serverStopFn, err := setupMetrics(agentInfo, logger, cfg.Settings.DownloadConfig.OS(), cfg.Settings.MonitoringConfig, app, statusCtrl)
 	if err != nil {
 		return err
 	}
@@ -159,6 +178,16 @@ func run(streams *cli.IOStreams, override cfgOverrider) error {
 	if err := app.Start(); err != nil {
 		return err
 	}
+	logServiceEvent(fmt.Sprintf("Elastic Agent %s started.", release.Version()))
+	go systemd.RunWatchdog(ctx, logger)
+
+	if configuration.IsStandalone(cfg.Fleet) && cfg.Settings.Enrollment != nil && cfg.Settings.Enrollment.Watch {
+		// the agent enrolls once a provisioning tool writes the token file and restarts in managed mode.
+		enrollCtx, cancelEnroll := context.WithCancel(ctx)
+		defer cancelEnroll()
+		watcher := newEnrollmentTokenWatcher(logger.Named("enrollment"), cfg.Settings.Enrollment, func() { rex.ReExec(nil) })
+		go watcher.Run(enrollCtx)
+	}
 
 	// listen for signals
 	signals := make(chan os.Signal, 1)
@@ -183,6 +212,8 @@ func run(streams *cli.IOStreams, override cfgOverrider) error {
 		if breakout {
 			if !reexecing {
 				logger.Info("Shutting down Elastic Agent and sending last events...")
+				logServiceEvent("Elastic Agent is shutting down.")
+				systemd.Stopping()
 			}
 			break
 		}
@@ -250,7 +281,7 @@ func getOverwrites(rawConfig *config.Config) error {
 		return nil
 	}
 	path := paths.AgentConfigFile()
-	store := storage.NewDiskStore(path)
+	store := storage.NewFleetConfigStore(path)
 
 	reader, err := store.Load()
 	if err != nil && errors.Is(err, os.ErrNotExist) {
@@ -296,7 +327,7 @@ func defaultLogLevel(cfg *configuration.Configuration) string {
 	return defaultLogLevel
 }
 
-func setupMetrics(agentInfo *info.AgentInfo, logger *logger.Logger, operatingSystem string, cfg *monitoringCfg.MonitoringConfig, app application.Application) (func() error, error) {
+func setupMetrics(agentInfo *info.AgentInfo, logger *logger.Logger, operatingSystem string, cfg *monitoringCfg.MonitoringConfig, app application.Application, statusCtrl status.Controller) (func() error, error) {
 	// use libbeat to setup metrics
 	if err := metrics.SetupMetrics(agentName); err != nil {
 		return nil, err
@@ -308,7 +339,7 @@ func setupMetrics(agentInfo *info.AgentInfo, logger *logger.Logger, operatingSys
 		Host:    beats.AgentMonitoringEndpoint(operatingSystem, cfg.HTTP),
 	}
 
-	s, err := monitoringServer.New(logger, endpointConfig, monitoring.GetNamespace, app.Routes, isProcessStatsEnabled(cfg.HTTP))
+	s, err := monitoringServer.New(logger, endpointConfig, monitoring.GetNamespace, app.Routes, statusCtrl.Status, isProcessStatsEnabled(cfg.HTTP))
 	if err != nil {
 		return nil, errors.New(err, "could not start the HTTP server for the API")
 	}
@@ -351,6 +382,8 @@ func tryDelayEnroll(ctx context.Context, logger *logger.Logger, cfg *configurati
 	}
 	options.DelayEnroll = false
 	options.FleetServer.SpawnAgent = false
+	// the agent waits for Fleet until the enrollment succeeds.
+	options.EnrollTimeout = 0
 	c, err := newEnrollCmd(
 		logger,
 		&options,
@@ -24,6 +24,7 @@ type fleetConfig struct {
 	TokenPolicyName string        `config:"token_policy_name"`
 	URL             string        `config:"url"`
 	DaemonTimeout   time.Duration `config:"daemon_timeout"`
+	Tags            []string      `config:"tags"`
 }
 
 type fleetServerConfig struct {
@@ -85,6 +86,7 @@ func defaultAccessConfig() (setupConfig, error) {
 			TokenPolicyName: envWithDefault("", "FLEET_TOKEN_POLICY_NAME"),
 			URL:             envWithDefault("", "FLEET_URL"),
 			DaemonTimeout:   envTimeout("FLEET_DAEMON_TIMEOUT"),
+			Tags:            envList("ELASTIC_AGENT_TAGS"),
 		},
 		FleetServer: fleetServerConfig{
 			Cert:    envWithDefault("", "FLEET_SERVER_CERT"),
@@ -0,0 +1,296 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	c "github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/filelock"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/install"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/cli"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+	fleetclient "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/client"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+	fleetreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet"
+	fleetreporterConfig "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet/config"
+)
+
+// standaloneConfig is written when no backup of the configuration replaced during the enrollment
+// is found.
+const standaloneConfig = `# Elastic Agent was unenrolled from Fleet, this configuration runs it in standalone mode.
+fleet:
+  enabled: false
+`
+
+func newUnenrollCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unenroll",
+		Short: "Unenroll this Elastic Agent from Fleet",
+		Long: `This will stop the running Elastic Agent and its processes, send the pending events to Fleet
+and remove the local enrollment state, the configuration used before the enrollment is restored.
+
+The access API key of the agent is not revoked, the agent still has to be removed from Fleet.
+
+Unless -f is used this command will ask confirmation before unenrolling.
+`,
+		Run: func(c *cobra.Command, args []string) {
+			if err := unenrollCmd(streams, c, args); err != nil {
+				fmt.Fprintf(streams.Err, "Error: %v\n%s\n", err, troubleshootMessage())
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolP("force", "f", false, "Force unenroll and do not prompt for confirmation")
+	cmd.Flags().Duration("timeout", 30*time.Second, "Maximum time to wait for the agent to stop and for Fleet to receive the pending events")
+
+	return cmd
+}
+
+func unenrollCmd(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+	if configuration.IsStandalone(cfg.Fleet) {
+		return fmt.Errorf("elastic agent is not enrolled in Fleet")
+	}
+
+	status, _ := install.Status()
+	if err := checkInstalledRights(status, "unenrolled"); err != nil {
+		return err
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if !force {
+		confirm, err := c.Confirm("Elastic Agent will be stopped and unenrolled from Fleet. Do you want to continue?", true)
+		if err != nil {
+			return fmt.Errorf("problem reading prompt response")
+		}
+		if !confirm {
+			return fmt.Errorf("unenroll was cancelled by the user")
+		}
+	}
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// the agent stops the gateway and its processes and persists the pending events.
+	if status == install.Installed {
+		fmt.Fprintln(streams.Out, "Stopping Elastic Agent service")
+		if err := install.StopService(); err != nil {
+			fmt.Fprintf(streams.Err, "Warning: %v\n", err)
+		}
+	}
+
+	// hold the lock so the agent cannot be started until the enrollment state is removed.
+	locker := filelock.NewAppLocker(paths.Data(), paths.AgentLockFileName)
+	if err := waitForLock(ctx, locker); err != nil {
+		if err == filelock.ErrAppAlreadyRunning {
+			return fmt.Errorf("elastic agent is still running, stop it before unenrolling")
+		}
+		return err
+	}
+	defer locker.Unlock()
+
+	log, err := logger.NewFromConfig("", cfg.Settings.LoggingConfig, false)
+	if err != nil {
+		return err
+	}
+
+	event := fleetNoticeEvent{ts: time.Now(), message: "Elastic Agent is unenrolling from Fleet"}
+	if err := notifyFleet(ctx, log, cfg.Fleet, storage.NewDiskStore(paths.AgentEventsStoreFile()), event); err != nil {
+		fmt.Fprintf(streams.Err, "Warning: could not notify Fleet, %v\n", err)
+	}
+
+	if err := removeFleetEnrollment(streams); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(streams.Out, "Elastic Agent has been unenrolled, remove it from Fleet to revoke its access API key.")
+	if status == install.Installed {
+		fmt.Fprintln(streams.Out, "The Elastic Agent service is stopped, start it again to run in standalone mode.")
+	}
+	return nil
+}
+
+// checkInstalledRights checks that an installed agent is changed with administrator rights by its
+// installed executable.
+func checkInstalledRights(status install.StatusType, action string) error {
+	if status != install.Installed {
+		return nil
+	}
+	isAdmin, err := install.HasRoot()
+	if err != nil {
+		return fmt.Errorf("unable to perform command while checking for administrator rights, %v", err)
+	}
+	if !isAdmin {
+		return fmt.Errorf("unable to perform command, not executed with %s permissions", install.PermissionUser)
+	}
+	if !info.RunningInstalled() {
+		return fmt.Errorf("can only be %s by executing the installed Elastic Agent at: %s", action, install.ExecutablePath())
+	}
+	return nil
+}
+
+// removeFleetEnrollment removes the enrollment state and restores the configuration used before
+// the enrollment, the agent must be stopped.
+func removeFleetEnrollment(streams *cli.IOStreams) error {
+	fileLock := paths.AgentConfigFileLock()
+	if err := fileLock.TryLock(); err != nil {
+		return err
+	}
+	defer fileLock.Unlock()
+
+	// the secrets of the fleet configuration are kept in the keystore of the platform.
+	if err := storage.NewFleetConfigStore(paths.AgentConfigFile()).Delete(); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(streams.Err, "Warning: could not remove the secrets from the keystore, %v\n", err)
+	}
+
+	if err := removeEnrollment(
+		paths.AgentConfigFile(),
+		paths.AgentEnrollFile(),
+		paths.AgentStateStoreYmlFile(),
+		paths.AgentStateStoreFile(),
+		paths.AgentActionStoreFile(),
+		paths.AgentEventsStoreFile(),
+	); err != nil {
+		return err
+	}
+
+	return restoreStandaloneConfig(paths.ConfigFile())
+}
+
+// waitForLock waits for the running agent to release the lock, the lock is held when it returns
+// without error.
+func waitForLock(ctx context.Context, locker *filelock.AppLocker) error {
+	for {
+		err := locker.TryLock()
+		if err != filelock.ErrAppAlreadyRunning {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// notifyFleet sends with a last checkin the events not yet acknowledged by Fleet and an event
+// telling Fleet why the agent stops checking in.
+func notifyFleet(ctx context.Context, log *logger.Logger, cfg *configuration.FleetAgentConfig, events *storage.DiskStore, event reporter.Event) error {
+	agentInfo, err := info.NewAgentInfo(false)
+	if err != nil {
+		return err
+	}
+
+	reportingCfg := cfg.Reporting
+	if reportingCfg == nil {
+		reportingCfg = fleetreporterConfig.DefaultConfig()
+	}
+	rep, err := fleetreporter.NewReporterWithStore(agentInfo, log, reportingCfg, events)
+	if err != nil {
+		return err
+	}
+	defer rep.Close()
+
+	rep.Report(ctx, event)
+
+	client, err := fleetclient.NewAuthWithConfig(log, cfg.AccessAPIKey, cfg.Client)
+	if err != nil {
+		return errors.New(err,
+			"fail to create the fleet client",
+			errors.TypeNetwork,
+			errors.M(errors.MetaKeyURI, cfg.Client.Host))
+	}
+
+	ee, ack := rep.Events()
+	cmd := fleetapi.NewCheckinCmd(agentInfo, client)
+	if _, err := cmd.Execute(ctx, &fleetapi.CheckinRequest{
+		Status: "online",
+		Events: ee,
+	}); err != nil {
+		return err
+	}
+
+	ack()
+	return nil
+}
+
+// removeEnrollment removes the files holding the enrollment state.
+func removeEnrollment(files ...string) error {
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return errors.New(err,
+				fmt.Sprintf("could not remove %s", f),
+				errors.TypeFilesystem,
+				errors.M(errors.MetaKeyPath, f))
+		}
+	}
+	return nil
+}
+
+// restoreStandaloneConfig restores the most recent backup of the configuration made when the
+// agent was enrolled, a configuration running the agent in standalone mode is written when no
+// backup exists.
+func restoreStandaloneConfig(target string) error {
+	backups, err := filepath.Glob(target + ".*.bak")
+	if err != nil {
+		return errors.New(err, "could not list the configuration backups", errors.TypeFilesystem)
+	}
+
+	if len(backups) == 0 {
+		if err := ioutil.WriteFile(target, []byte(standaloneConfig), 0600); err != nil {
+			return errors.New(err,
+				fmt.Sprintf("could not write %s", target),
+				errors.TypeFilesystem,
+				errors.M(errors.MetaKeyPath, target))
+		}
+		return nil
+	}
+
+	// backups are suffixed with a sortable timestamp.
+	sort.Strings(backups)
+	latest := backups[len(backups)-1]
+	if err := file.SafeFileRotate(target, latest); err != nil {
+		return errors.New(err,
+			fmt.Sprintf("could not restore %s from %s", target, latest),
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, target),
+			errors.M("backup_path", latest))
+	}
+	return nil
+}
+
+// fleetNoticeEvent is the last event sent to Fleet by an agent removing its enrollment.
+type fleetNoticeEvent struct {
+	ts      time.Time
+	message string
+}
+
+func (fleetNoticeEvent) Type() string                    { return reporter.EventTypeState }
+func (fleetNoticeEvent) SubType() string                 { return reporter.EventSubTypeStopped }
+func (e fleetNoticeEvent) Time() time.Time               { return e.ts }
+func (e fleetNoticeEvent) Message() string               { return e.message }
+func (fleetNoticeEvent) Payload() map[string]interface{} { return nil }
@@ -0,0 +1,62 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveEnrollment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unenroll")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fleetFile := filepath.Join(dir, "fleet.yml")
+	require.NoError(t, ioutil.WriteFile(fleetFile, []byte("fleet:\n  enabled: true\n"), 0600))
+
+	// missing files are ignored.
+	require.NoError(t, removeEnrollment(fleetFile, filepath.Join(dir, "state.yml")))
+	_, err = os.Stat(fleetFile)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestRestoreStandaloneConfig(t *testing.T) {
+	t.Run("most recent backup is restored", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "unenroll")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		target := filepath.Join(dir, "elastic-agent.yml")
+		require.NoError(t, ioutil.WriteFile(target, []byte("fleet:\n  enabled: true\n"), 0600))
+		require.NoError(t, ioutil.WriteFile(target+".2021-06-01T10-00-00.1.bak", []byte("old"), 0600))
+		require.NoError(t, ioutil.WriteFile(target+".2021-07-01T10-00-00.1.bak", []byte("standalone"), 0600))
+
+		require.NoError(t, restoreStandaloneConfig(target))
+
+		content, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, "standalone", string(content))
+	})
+
+	t.Run("standalone configuration is written without backup", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "unenroll")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		target := filepath.Join(dir, "elastic-agent.yml")
+		require.NoError(t, ioutil.WriteFile(target, []byte("fleet:\n  enabled: true\n"), 0600))
+
+		require.NoError(t, restoreStandaloneConfig(target))
+
+		content, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, standaloneConfig, string(content))
+	})
+}
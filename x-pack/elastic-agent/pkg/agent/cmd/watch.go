@@ -27,9 +27,6 @@ import (
 )
 
 const (
-	// period during which we monitor for failures resulting in a rollback
-	gracePeriodDuration = 10 * time.Minute
-
 	watcherName     = "elastic-agent-watcher"
 	watcherLockFile = "watcher.lock"
 )
@@ -51,7 +48,7 @@ func newWatchCommandWithArgs(_ []string, streams *cli.IOStreams) *cobra.Command
 }
 
 func watchCmd(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
-	log, err := configuredLogger()
+	log, cfg, err := configuredWatcher()
 	if err != nil {
 		return err
 	}
@@ -66,6 +63,11 @@ func watchCmd(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
 		log.Debugf("update marker not present at '%s'", paths.Data())
 		return nil
 	}
+	if marker.RollbackReason != "" {
+		// the upgrade was already rolled back, the agent reports it to fleet and removes the marker.
+		log.Debugf("upgrade already rolled back: %s", marker.RollbackReason)
+		return nil
+	}
 
 	locker := filelock.NewAppLocker(paths.Top(), watcherLockFile)
 	if err := locker.TryLock(); err != nil {
@@ -79,7 +81,7 @@ func watchCmd(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
 	}
 	defer locker.Unlock()
 
-	isWithinGrace, tilGrace := gracePeriod(marker)
+	isWithinGrace, tilGrace := gracePeriod(marker, cfg.GracePeriod)
 	if !isWithinGrace {
 		log.Debugf("not within grace [updatedOn %v] %v", marker.UpdatedOn.String(), time.Since(marker.UpdatedOn).String())
 		// if it is started outside of upgrade loop
@@ -96,6 +98,9 @@ func watchCmd(streams *cli.IOStreams, cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 	if err := watch(ctx, tilGrace, log); err != nil {
 		log.Debugf("Error detected proceeding to rollback: %v", err)
+		if markErr := upgrade.MarkRolledBack(err); markErr != nil {
+			log.Error("failed to record the reason of the rollback", markErr)
+		}
 		err = upgrade.Rollback(ctx, marker.PrevHash, marker.Hash)
 		if err != nil {
 			log.Error("rollback failed", err)
@@ -157,6 +162,11 @@ WATCHLOOP:
 		// grace period passed, agent is considered stable
 		case <-t.C:
 			log.Info("Grace period passed, not watching")
+			// an upgrade requested by fleet is only kept once the new agent checked in with fleet.
+			if err := upgrade.CheckCheckedIn(); err != nil {
+				log.Error("Agent did not check in", err)
+				return err
+			}
 			break WATCHLOOP
 		// Agent in degraded state.
 		case err := <-errChan:
@@ -174,7 +184,7 @@ WATCHLOOP:
 
 // gracePeriod returns true if it is within grace period and time until grace period ends.
 // otherwise it returns false and 0
-func gracePeriod(marker *upgrade.UpdateMarker) (bool, time.Duration) {
+func gracePeriod(marker *upgrade.UpdateMarker, gracePeriodDuration time.Duration) (bool, time.Duration) {
 	sinceUpdate := time.Since(marker.UpdatedOn)
 
 	if 0 < sinceUpdate && sinceUpdate < gracePeriodDuration {
@@ -184,11 +194,12 @@ func gracePeriod(marker *upgrade.UpdateMarker) (bool, time.Duration) {
 	return false, gracePeriodDuration
 }
 
-func configuredLogger() (*logger.Logger, error) {
+// configuredWatcher returns the logger and the configuration of the watcher.
+func configuredWatcher() (*logger.Logger, *configuration.UpgradeWatcherConfig, error) {
 	pathConfigFile := paths.ConfigFile()
 	rawConfig, err := config.LoadFile(pathConfigFile)
 	if err != nil {
-		return nil, errors.New(err,
+		return nil, nil, errors.New(err,
 			fmt.Sprintf("could not read configuration file %s", pathConfigFile),
 			errors.TypeFilesystem,
 			errors.M(errors.MetaKeyPath, pathConfigFile))
@@ -196,7 +207,7 @@ func configuredLogger() (*logger.Logger, error) {
 
 	cfg, err := configuration.NewFromConfig(rawConfig)
 	if err != nil {
-		return nil, errors.New(err,
+		return nil, nil, errors.New(err,
 			fmt.Sprintf("could not parse configuration file %s", pathConfigFile),
 			errors.TypeFilesystem,
 			errors.M(errors.MetaKeyPath, pathConfigFile))
@@ -206,8 +217,12 @@ func configuredLogger() (*logger.Logger, error) {
 
 	logger, err := logger.NewFromConfig("", cfg.Settings.LoggingConfig, false)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return logger, nil
+	watcherCfg := configuration.DefaultUpgradeConfig().Watcher
+	if cfg.Settings.Upgrade != nil && cfg.Settings.Upgrade.Watcher != nil {
+		watcherCfg = cfg.Settings.Upgrade.Watcher
+	}
+	return logger, watcherCfg, nil
 }
@@ -0,0 +1,19 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package configuration
+
+// ActionsConfig defines how the actions received from Fleet are executed.
+type ActionsConfig struct {
+	// MaxConcurrent is the maximum number of actions executed at the same time, the other actions
+	// wait for a running action to complete. 0 removes the limit.
+	MaxConcurrent int `config:"max_concurrent" yaml:"max_concurrent" json:"max_concurrent"`
+}
+
+// DefaultActionsConfig creates a config with the default limit of concurrently executed actions.
+func DefaultActionsConfig() *ActionsConfig {
+	return &ActionsConfig{
+		MaxConcurrent: 4,
+	}
+}
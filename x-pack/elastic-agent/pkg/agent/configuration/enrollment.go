@@ -0,0 +1,26 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package configuration
+
+import "time"
+
+// EnrollmentConfig defines how a standalone agent waits for an enrollment token file, the agent
+// enrolls into Fleet once the file is written and then runs in managed mode.
+type EnrollmentConfig struct {
+	Watch bool `config:"watch" yaml:"watch" json:"watch"`
+	// TokenFile is the path of the token file, defaults to enrollment_token.yml in the config directory.
+	TokenFile string `config:"token_file" yaml:"token_file,omitempty" json:"token_file,omitempty"`
+	// URL is the Fleet URL used when the token file only contains the enrollment token.
+	URL    string        `config:"url" yaml:"url,omitempty" json:"url,omitempty"`
+	Period time.Duration `config:"period" yaml:"period" json:"period" validate:"positive"`
+}
+
+// DefaultEnrollmentConfig creates a config which does not watch for a token file.
+func DefaultEnrollmentConfig() *EnrollmentConfig {
+	return &EnrollmentConfig{
+		Watch:  false,
+		Period: 10 * time.Second,
+	}
+}
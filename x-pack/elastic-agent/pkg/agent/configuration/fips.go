@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package configuration
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
+)
+
+// ConfigureFIPS enables the FIPS mode when it is required by the configuration and checks the TLS
+// settings of the agent, so an agent in FIPS mode fails at startup instead of at its first
+// connection.
+func ConfigureFIPS(cfg *Configuration) error {
+	if cfg.Settings != nil {
+		fips.Configure(cfg.Settings.FIPS)
+	}
+	if !fips.Enabled() {
+		return nil
+	}
+
+	type tlsSetting struct {
+		name string
+		tls  *tlscommon.Config
+	}
+	var settings []tlsSetting
+	if cfg.Fleet != nil {
+		settings = append(settings, tlsSetting{"fleet.ssl", cfg.Fleet.Client.Transport.TLS})
+		if cfg.Fleet.Server != nil {
+			settings = append(settings,
+				tlsSetting{"fleet.server.ssl", cfg.Fleet.Server.TLS},
+				tlsSetting{"fleet.server.output.elasticsearch.ssl", cfg.Fleet.Server.Output.Elasticsearch.TLS},
+			)
+		}
+	}
+	if cfg.Settings != nil && cfg.Settings.DownloadConfig != nil {
+		settings = append(settings, tlsSetting{"agent.download.ssl", cfg.Settings.DownloadConfig.TLS})
+	}
+
+	for _, s := range settings {
+		if err := fips.CheckTLS(s.tls); err != nil {
+			return errors.New(err, fmt.Sprintf("invalid %s setting", s.name), errors.TypeConfig)
+		}
+	}
+	return nil
+}
@@ -19,6 +19,12 @@ type FleetAgentConfig struct {
 	Reporting    *fleetreporterConfig.Config `config:"reporting" yaml:"reporting"`
 	Info         *AgentInfo                  `config:"agent" yaml:"agent"`
 	Server       *FleetServerConfig          `config:"server" yaml:"server,omitempty"`
+	// SigningKey is the PEM encoded public key verifying the policies signed by Fleet, policies
+	// are not verified when empty.
+	SigningKey string `config:"signing_key" yaml:"signing_key,omitempty"`
+	// PolicyDryRun validates and renders the policies received from Fleet without applying them,
+	// the rendered programs are reported to Fleet.
+	PolicyDryRun bool `config:"policy_dry_run" yaml:"policy_dry_run,omitempty"`
 }
 
 // Valid validates the required fields for accessing the API.
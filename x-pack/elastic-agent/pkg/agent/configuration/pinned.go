@@ -0,0 +1,17 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package configuration
+
+// PinnedConfig defines the local configuration layer merged over the policy received from Fleet,
+// the settings of the layer cannot be changed by the policy.
+type PinnedConfig struct {
+	// Path is the path of the pinned layer, defaults to pinned.yml in the config directory.
+	Path string `config:"path" yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// DefaultPinnedConfig creates a config reading the pinned layer from the default path.
+func DefaultPinnedConfig() *PinnedConfig {
+	return &PinnedConfig{}
+}
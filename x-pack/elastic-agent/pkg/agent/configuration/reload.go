@@ -19,6 +19,9 @@ var (
 type ReloadConfig struct {
 	Enabled bool          `config:"enabled" yaml:"enabled"`
 	Period  time.Duration `config:"period" yaml:"period"`
+	// Watch reloads the configuration as soon as the files change, the files are still checked
+	// every period.
+	Watch bool `config:"watch" yaml:"watch"`
 }
 
 // Validate validates settings of configuration.
@@ -36,5 +39,6 @@ func DefaultReloadConfig() *ReloadConfig {
 	return &ReloadConfig{
 		Enabled: true,
 		Period:  10 * time.Second,
+		Watch:   true,
 	}
 }
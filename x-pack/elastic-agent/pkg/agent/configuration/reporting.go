@@ -0,0 +1,25 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package configuration
+
+import (
+	esreporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/elasticsearch"
+	filereporter "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/file"
+)
+
+// ReportingConfig defines the backends the events of the agent are reported to in addition to
+// the logs and to Fleet, every backend keeps track of its own events.
+type ReportingConfig struct {
+	File          *filereporter.Config `config:"file" yaml:"file" json:"file"`
+	Elasticsearch *esreporter.Config   `config:"elasticsearch" yaml:"elasticsearch" json:"elasticsearch"`
+}
+
+// DefaultReportingConfig creates a config with the additional backends disabled.
+func DefaultReportingConfig() *ReportingConfig {
+	return &ReportingConfig{
+		File:          filereporter.DefaultConfig(),
+		Elasticsearch: esreporter.DefaultConfig(),
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/process"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/retry"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/server"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets"
 )
 
 // SettingsConfig is an collection of agent settings configuration.
@@ -21,6 +23,13 @@ type SettingsConfig struct {
 	RetryConfig      *retry.Config                   `yaml:"retry" config:"retry" json:"retry"`
 	MonitoringConfig *monitoringCfg.MonitoringConfig `yaml:"monitoring" config:"monitoring" json:"monitoring"`
 	LoggingConfig    *logger.Config                  `yaml:"logging,omitempty" config:"logging,omitempty" json:"logging,omitempty"`
+	SecretsConfig    *secrets.Config                 `yaml:"secrets,omitempty" config:"secrets,omitempty" json:"secrets,omitempty"`
+	FIPS             *fips.Config                    `yaml:"fips" config:"fips" json:"fips"`
+	Actions          *ActionsConfig                  `yaml:"actions" config:"actions" json:"actions"`
+	Reporting        *ReportingConfig                `yaml:"reporting" config:"reporting" json:"reporting"`
+	Upgrade          *UpgradeConfig                  `yaml:"upgrade" config:"upgrade" json:"upgrade"`
+	Enrollment       *EnrollmentConfig               `yaml:"enrollment" config:"enrollment" json:"enrollment"`
+	Pinned           *PinnedConfig                   `yaml:"pinned" config:"pinned" json:"pinned"`
 
 	// standalone config
 	Reload *ReloadConfig `config:"reload" yaml:"reload" json:"reload"`
@@ -37,5 +46,12 @@ func DefaultSettingsConfig() *SettingsConfig {
 		MonitoringConfig: monitoringCfg.DefaultConfig(),
 		GRPC:             server.DefaultGRPCConfig(),
 		Reload:           DefaultReloadConfig(),
+		SecretsConfig:    secrets.DefaultConfig(),
+		FIPS:             fips.DefaultConfig(),
+		Actions:          DefaultActionsConfig(),
+		Reporting:        DefaultReportingConfig(),
+		Upgrade:          DefaultUpgradeConfig(),
+		Enrollment:       DefaultEnrollmentConfig(),
+		Pinned:           DefaultPinnedConfig(),
 	}
 }
@@ -0,0 +1,28 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package configuration
+
+import "time"
+
+// UpgradeConfig defines how an upgraded agent is watched before the upgrade is kept.
+type UpgradeConfig struct {
+	Watcher *UpgradeWatcherConfig `config:"watcher" yaml:"watcher" json:"watcher"`
+}
+
+// UpgradeWatcherConfig defines the window during which the watcher rolls back an upgraded agent
+// which keeps failing, an upgrade requested by Fleet is also rolled back when the upgraded agent
+// did not complete a successful checkin by the end of the window.
+type UpgradeWatcherConfig struct {
+	GracePeriod time.Duration `config:"grace_period" yaml:"grace_period" json:"grace_period" validate:"positive"`
+}
+
+// DefaultUpgradeConfig creates a config with the default grace period of the watcher.
+func DefaultUpgradeConfig() *UpgradeConfig {
+	return &UpgradeConfig{
+		Watcher: &UpgradeWatcherConfig{
+			GracePeriod: 10 * time.Minute,
+		},
+	}
+}
@@ -99,6 +99,8 @@ type Client interface {
 	Status(ctx context.Context) (*AgentStatus, error)
 	// Restart triggers restarting the current running daemon.
 	Restart(ctx context.Context) error
+	// Reload triggers reloading the configuration of the current running daemon.
+	Reload(ctx context.Context) error
 	// Upgrade triggers upgrade of the current running daemon.
 	Upgrade(ctx context.Context, version string, sourceURI string) (string, error)
 	// ProcMeta gathers running process meta-data.
@@ -123,7 +125,7 @@ func New() Client {
 // Connect connects to the running Elastic Agent.
 func (c *client) Connect(ctx context.Context) error {
 	c.ctx, c.cancel = context.WithCancel(ctx)
-	conn, err := dialContext(ctx)
+	conn, err := dialContext(ctx, tokenOptions()...)
 	if err != nil {
 		return err
 	}
@@ -201,6 +203,18 @@ func (c *client) Restart(ctx context.Context) error {
 	return nil
 }
 
+// Reload triggers reloading the configuration of the current running daemon.
+func (c *client) Reload(ctx context.Context) error {
+	res, err := c.client.Reload(ctx, &proto.Empty{})
+	if err != nil {
+		return err
+	}
+	if res.Status == proto.ActionStatus_FAILURE {
+		return fmt.Errorf(res.Error)
+	}
+	return nil
+}
+
 // Upgrade triggers upgrade of the current running daemon.
 func (c *client) Upgrade(ctx context.Context, version string, sourceURI string) (string, error) {
 	res, err := c.client.Upgrade(ctx, &proto.UpgradeRequest{
@@ -17,8 +17,8 @@ import (
 	"google.golang.org/grpc"
 )
 
-func dialContext(ctx context.Context) (*grpc.ClientConn, error) {
-	return grpc.DialContext(ctx, strings.TrimPrefix(control.Address(), "unix://"), grpc.WithInsecure(), grpc.WithContextDialer(dialer))
+func dialContext(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return grpc.DialContext(ctx, strings.TrimPrefix(control.Address(), "unix://"), append(opts, grpc.WithInsecure(), grpc.WithContextDialer(dialer))...)
 }
 
 func dialer(ctx context.Context, addr string) (net.Conn, error) {
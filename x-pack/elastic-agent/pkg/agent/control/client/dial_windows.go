@@ -18,8 +18,8 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control"
 )
 
-func dialContext(ctx context.Context) (*grpc.ClientConn, error) {
-	return grpc.DialContext(ctx, control.Address(), grpc.WithInsecure(), grpc.WithContextDialer(dialer))
+func dialContext(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return grpc.DialContext(ctx, control.Address(), append(opts, grpc.WithInsecure(), grpc.WithContextDialer(dialer))...)
 }
 
 func dialer(ctx context.Context, addr string) (net.Conn, error) {
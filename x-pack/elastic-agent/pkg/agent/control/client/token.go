@@ -0,0 +1,45 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control"
+)
+
+// tokenCredentials sends the control token with each request.
+type tokenCredentials struct {
+	token string
+}
+
+func (c tokenCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	return map[string]string{control.TokenMetadataKey: c.token}, nil
+}
+
+// RequireTransportSecurity is false as the control socket is local.
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// tokenOptions returns the options sending the control token written by the running agent, no
+// token is sent when the token cannot be read and the request is authenticated by the peer
+// credentials of the connection.
+func tokenOptions() []grpc.DialOption {
+	data, err := ioutil.ReadFile(paths.AgentControlTokenFile())
+	if err != nil {
+		return nil
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithPerRPCCredentials(tokenCredentials{token: token})}
+}
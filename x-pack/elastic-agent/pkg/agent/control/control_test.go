@@ -6,6 +6,7 @@ package control_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
@@ -66,6 +67,37 @@ func TestServerClient_Status(t *testing.T) {
 	}, status)
 }
 
+func TestServerClient_Reload(t *testing.T) {
+	srv := server.New(newErrorLogger(t), nil, nil, nil)
+	err := srv.Start()
+	require.NoError(t, err)
+	defer srv.Stop()
+
+	c := client.New()
+	err = c.Connect(context.Background())
+	require.NoError(t, err)
+	defer c.Disconnect()
+
+	// managed by Fleet
+	err = c.Reload(context.Background())
+	assert.Error(t, err)
+
+	reloaded := 0
+	srv.SetReloadFn(func() error {
+		reloaded++
+		return nil
+	})
+	err = c.Reload(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, reloaded)
+
+	srv.SetReloadFn(func() error {
+		return errors.New("invalid configuration")
+	})
+	err = c.Reload(context.Background())
+	assert.EqualError(t, err, "invalid configuration")
+}
+
 func newErrorLogger(t *testing.T) *logger.Logger {
 	t.Helper()
 
@@ -0,0 +1,102 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+// authenticator authenticates the requests of the control protocol. A request is accepted when
+// the peer credentials of the connection belong to root or to the user running the agent, or when
+// it carries the token written by the agent in a file only readable by the user running the agent.
+// Without token only the peer credentials are accepted.
+type authenticator struct {
+	log       *logger.Logger
+	tokenPath string
+	token     string
+}
+
+// newAuthenticator creates a new token and writes it to the token file, a token written by a
+// previous run is replaced.
+func newAuthenticator(log *logger.Logger, tokenPath string) (*authenticator, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, errors.New(err, "failed to generate the control token", errors.TypeUnexpected)
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := os.MkdirAll(filepath.Dir(tokenPath), 0750); err != nil {
+		return nil, errors.New(err, "failed to create the directory of the control token", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, tokenPath))
+	}
+	if err := ioutil.WriteFile(tokenPath, []byte(token), 0600); err != nil {
+		return nil, errors.New(err, "failed to write the control token", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, tokenPath))
+	}
+	// the permissions of an existing file are not changed by the write.
+	if err := os.Chmod(tokenPath, 0600); err != nil {
+		return nil, errors.New(err, "failed to restrict the permissions of the control token", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, tokenPath))
+	}
+
+	return &authenticator{log: log, tokenPath: tokenPath, token: token}, nil
+}
+
+// newPeerAuthenticator creates an authenticator only accepting the peer credentials, it is used
+// when the token cannot be written.
+func newPeerAuthenticator(log *logger.Logger) *authenticator {
+	return &authenticator{log: log}
+}
+
+// authorize is the interceptor rejecting the requests which are not authenticated.
+func (a *authenticator) authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.authenticate(ctx); err != nil {
+		a.log.Warnf("Rejected control request %s: %s", info.FullMethod, err)
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) authenticate(ctx context.Context) error {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(peerAuthInfo); ok && info.trusted() {
+			return nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, token := range md.Get(control.TokenMetadataKey) {
+		if a.token == "" {
+			break
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "request is not authenticated, run the command as the user running the agent or as an administrator")
+}
+
+// close removes the token file.
+func (a *authenticator) close() {
+	if a.tokenPath == "" {
+		return
+	}
+	if err := os.Remove(a.tokenPath); err != nil && !os.IsNotExist(err) {
+		a.log.Debugf("Failed to remove the control token %s: %s", a.tokenPath, err)
+	}
+}
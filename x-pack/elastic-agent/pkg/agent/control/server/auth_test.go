@@ -0,0 +1,85 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+func TestAuthenticator(t *testing.T) {
+	log, err := logger.New("", false)
+	require.NoError(t, err)
+
+	tokenPath := filepath.Join(t.TempDir(), "control.token")
+	a, err := newAuthenticator(log, tokenPath)
+	require.NoError(t, err)
+
+	token, err := ioutil.ReadFile(tokenPath)
+	require.NoError(t, err)
+	assert.Equal(t, a.token, string(token))
+	if info, err := os.Stat(tokenPath); assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	withPeer := func(uid int) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: peerAuthInfo{uid: uid}})
+	}
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(control.TokenMetadataKey, token))
+	}
+
+	t.Run("trusted peer", func(t *testing.T) {
+		assert.NoError(t, a.authenticate(withPeer(os.Getuid())))
+		assert.NoError(t, a.authenticate(withPeer(0)))
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		assert.NoError(t, a.authenticate(withToken(string(token))))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		for name, ctx := range map[string]context.Context{
+			"no credentials":  context.Background(),
+			"invalid token":   withToken("invalid"),
+			"untrusted peer":  withPeer(os.Getuid() + 1),
+			"empty peer info": peer.NewContext(context.Background(), &peer.Peer{}),
+		} {
+			err := a.authenticate(ctx)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err), name)
+		}
+	})
+
+	a.close()
+	_, err = os.Stat(tokenPath)
+	assert.True(t, os.IsNotExist(err))
+
+	t.Run("peer credentials only without token", func(t *testing.T) {
+		// the home is a file, the token cannot be written.
+		home := filepath.Join(t.TempDir(), "home")
+		require.NoError(t, ioutil.WriteFile(home, nil, 0600))
+		_, err := newAuthenticator(log, filepath.Join(home, "control.token"))
+		require.Error(t, err)
+
+		a := newPeerAuthenticator(log)
+		assert.NoError(t, a.authenticate(withPeer(os.Getuid())))
+		assert.Equal(t, codes.Unauthenticated, status.Code(a.authenticate(withToken(""))))
+		assert.Equal(t, codes.Unauthenticated, status.Code(a.authenticate(withToken(string(token)))))
+		a.close()
+	})
+}
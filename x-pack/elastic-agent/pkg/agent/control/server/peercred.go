@@ -0,0 +1,57 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// peerAuthInfo are the peer credentials of a connection to the control socket.
+type peerAuthInfo struct {
+	uid int
+}
+
+func (peerAuthInfo) AuthType() string {
+	return "peercred"
+}
+
+// trusted returns true when the peer is root or the user running the agent.
+func (i peerAuthInfo) trusted() bool {
+	return i.uid == 0 || i.uid == os.Getuid()
+}
+
+// peerCredentials reads the peer credentials of the connections, they do not secure the
+// connection themselves as the control socket is local. When the peer credentials are not
+// available the connection is accepted and the requests are authenticated by their token.
+type peerCredentials struct{}
+
+func (peerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, fmt.Errorf("peer credentials are only read by the server")
+}
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	uid, err := peerUID(conn)
+	if err != nil {
+		return conn, nil, nil
+	}
+	return conn, peerAuthInfo{uid: uid}, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c peerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (peerCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !linux
+// +build !linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// peerUID is not supported, the requests are authenticated by their token.
+func peerUID(_ net.Conn) (int, error) {
+	return 0, fmt.Errorf("peer credentials are not supported")
+}
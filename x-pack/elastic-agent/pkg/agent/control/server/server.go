@@ -18,6 +18,7 @@ import (
 
 	"google.golang.org/grpc"
 
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/reexec"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/upgrade"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/control"
@@ -43,8 +44,10 @@ type Server struct {
 	up            *upgrade.Upgrader
 	routeFn       func() *sorted.Set
 	monitoringCfg *monitoringCfg.MonitoringConfig
+	reloadFn      func() error
 	listener      net.Listener
 	server        *grpc.Server
+	auth          *authenticator
 	lock          sync.RWMutex
 }
 
@@ -82,6 +85,13 @@ func (s *Server) SetRouteFn(routesFetchFn func() *sorted.Set) {
 	s.routeFn = routesFetchFn
 }
 
+// SetReloadFn changes the function reloading the configuration of the running agent.
+func (s *Server) SetReloadFn(reloadFn func() error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reloadFn = reloadFn
+}
+
 // SetMonitoringCfg sets a reference to the monitoring config used by the running agent.
 // the controller references this config to find out if pprof is enabled for the agent or not
 func (s *Server) SetMonitoringCfg(cfg *monitoringCfg.MonitoringConfig) {
@@ -102,8 +112,15 @@ func (s *Server) Start() error {
 		s.logger.Errorf("unable to create listener: %s", err)
 		return err
 	}
+	auth, err := newAuthenticator(s.logger, paths.AgentControlTokenFile())
+	if err != nil {
+		// the control socket stays usable by root and by the user running the agent.
+		s.logger.Warnf("unable to create the control token, only the peer credentials are accepted: %s", err)
+		auth = newPeerAuthenticator(s.logger)
+	}
+	s.auth = auth
 	s.listener = lis
-	s.server = grpc.NewServer()
+	s.server = grpc.NewServer(grpc.Creds(peerCredentials{}), grpc.UnaryInterceptor(auth.authorize))
 	proto.RegisterElasticAgentControlServer(s.server, s)
 
 	// start serving GRPC connections
@@ -124,6 +141,8 @@ func (s *Server) Stop() {
 		s.server = nil
 		s.listener = nil
 		cleanupListener(s.logger)
+		s.auth.close()
+		s.auth = nil
 	}
 }
 
@@ -155,6 +174,30 @@ func (s *Server) Restart(_ context.Context, _ *proto.Empty) (*proto.RestartRespo
 	}, nil
 }
 
+// Reload reloads the configuration of the running agent.
+func (s *Server) Reload(_ context.Context, _ *proto.Empty) (*proto.ReloadResponse, error) {
+	s.lock.RLock()
+	reloadFn := s.reloadFn
+	s.lock.RUnlock()
+	if reloadFn == nil {
+		// not running in standalone mode (must be controlled by Fleet)
+		return &proto.ReloadResponse{
+			Status: proto.ActionStatus_FAILURE,
+			Error:  "cannot be reloaded; the policy is managed by Fleet",
+		}, nil
+	}
+
+	if err := reloadFn(); err != nil {
+		return &proto.ReloadResponse{
+			Status: proto.ActionStatus_FAILURE,
+			Error:  err.Error(),
+		}, nil
+	}
+	return &proto.ReloadResponse{
+		Status: proto.ActionStatus_SUCCESS,
+	}, nil
+}
+
 // Upgrade performs the upgrade operation.
 func (s *Server) Upgrade(ctx context.Context, request *proto.UpgradeRequest) (*proto.UpgradeResponse, error) {
 	s.lock.RLock()
@@ -0,0 +1,9 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package control
+
+// TokenMetadataKey is the metadata key of the token authenticating the clients of the control
+// protocol which cannot be authenticated from their peer credentials.
+const TokenMetadataKey = "elastic-agent-control-token"
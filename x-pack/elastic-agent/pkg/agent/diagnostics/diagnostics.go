@@ -0,0 +1,225 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package diagnostics writes the parts of a diagnostics archive which are shared by the
+// diagnostics command and by the diagnostics action received from Fleet, and reads the
+// monitoring endpoints of the agent and of its processes.
+package diagnostics
+
+import (
+	"archive/zip"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/socket"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
+)
+
+// Process is a process whose metrics are collected, identified by its application name and the
+// route key of its output.
+type Process struct {
+	Name     string
+	RouteKey string
+	Endpoint string
+}
+
+type specer interface {
+	Specs() map[string]program.Spec
+}
+
+// RouteProcesses returns the processes running for the routes of the agent.
+func RouteProcesses(routes *sorted.Set, endpointFn func(spec program.Spec, rk string) string) []Process {
+	var procs []Process
+	for _, rk := range routes.Keys() {
+		programs, ok := routes.Get(rk)
+		if !ok {
+			continue
+		}
+		sp, ok := programs.(specer)
+		if !ok {
+			continue
+		}
+		for name, spec := range sp.Specs() {
+			procs = append(procs, Process{Name: name, RouteKey: rk, Endpoint: endpointFn(spec, rk)})
+		}
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].RouteKey != procs[j].RouteKey {
+			return procs[i].RouteKey < procs[j].RouteKey
+		}
+		return procs[i].Name < procs[j].Name
+	})
+	return procs
+}
+
+// ZipLogs walks the logs directory of the agent and copies the file structure into zw in "logs/",
+// the audit log of the actions received from Fleet is copied in "logs/audit/".
+func ZipLogs(zw *zip.Writer) error {
+	// using Home() + "/logs", for some reason default paths/Logs() is the home dir...
+	if err := zipDir(zw, "logs/", filepath.Join(paths.Home(), "logs")); err != nil {
+		return err
+	}
+	return zipDir(zw, "logs/audit/", filepath.Dir(paths.AgentAuditLogFile()))
+}
+
+func zipDir(zw *zip.Writer, prefix, dir string) error {
+	dir += string(filepath.Separator)
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, fErr error) error {
+		if stderrors.Is(fErr, fs.ErrNotExist) {
+			return nil
+		}
+		if fErr != nil {
+			return fmt.Errorf("unable to walk log dir: %w", fErr)
+		}
+
+		name := filepath.ToSlash(strings.TrimPrefix(path, dir))
+		if name == "" {
+			_, err := zw.Create(prefix)
+			return err
+		}
+
+		if d.IsDir() {
+			_, err := zw.Create(prefix + name + "/")
+			if err != nil {
+				return fmt.Errorf("unable to create log directory in archive: %w", err)
+			}
+			return nil
+		}
+
+		lf, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("unable to open log file: %w", err)
+		}
+		defer lf.Close()
+
+		zf, err := zw.Create(prefix + name)
+		if err != nil {
+			return fmt.Errorf("unable to create log file in archive: %w", err)
+		}
+		if _, err := io.Copy(zf, lf); err != nil {
+			return fmt.Errorf("log file copy failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// ZipMetrics copies the stats and the status reported by the monitoring endpoint of the agent and
+// the stats of each process into zw in "metrics/". A failure to reach an endpoint does not fail
+// the archive, the error is written in place of the metrics.
+func ZipMetrics(ctx context.Context, zw *zip.Writer, agentEndpoint string, procs []Process) error {
+	if _, err := zw.Create("metrics/"); err != nil {
+		return err
+	}
+
+	agent := newRequester(agentEndpoint)
+	if err := zipPath(ctx, zw, agent, "/stats", "metrics/elastic-agent-stats"); err != nil {
+		return err
+	}
+	if err := zipPath(ctx, zw, agent, "/status", "metrics/elastic-agent-status"); err != nil {
+		return err
+	}
+
+	for _, p := range procs {
+		r := newRequester(p.Endpoint)
+		if err := zipPath(ctx, zw, r, "/stats", "metrics/"+p.Name+"_"+p.RouteKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZipGoroutines writes a dump of the stacks of all the goroutines of the current process into zw
+// in "goroutines/".
+func ZipGoroutines(zw *zip.Writer, name string) error {
+	if _, err := zw.Create("goroutines/"); err != nil {
+		return err
+	}
+	zf, err := zw.Create("goroutines/" + name + ".txt")
+	if err != nil {
+		return err
+	}
+	return pprof.Lookup("goroutine").WriteTo(zf, 2)
+}
+
+// Get reads path from the monitoring endpoint of the agent or of one of its processes.
+func Get(ctx context.Context, endpoint, path string) ([]byte, error) {
+	return newRequester(endpoint).get(ctx, path)
+}
+
+func zipPath(ctx context.Context, zw *zip.Writer, r *requester, path, name string) error {
+	body, err := r.get(ctx, path)
+	if err != nil {
+		zf, zErr := zw.Create(name + "_error.txt")
+		if zErr != nil {
+			return zErr
+		}
+		_, zErr = zf.Write([]byte(err.Error()))
+		return zErr
+	}
+
+	zf, err := zw.Create(name + ".json")
+	if err != nil {
+		return err
+	}
+	_, err = zf.Write(body)
+	return err
+}
+
+// requester reads the monitoring endpoint of the agent or of one of its processes, the endpoint
+// is either a unix socket, a named pipe or an HTTP address.
+type requester struct {
+	c    http.Client
+	host string
+}
+
+func newRequester(endpoint string) *requester {
+	r := &requester{host: strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "http+")}
+	if strings.HasPrefix(r.host, "unix://") {
+		r.c.Transport = &http.Transport{
+			Proxy:       nil,
+			DialContext: socket.DialContext(strings.TrimPrefix(r.host, "unix://")),
+		}
+		r.host = "unix"
+	} else if strings.HasPrefix(r.host, "npipe://") {
+		r.c.Transport = &http.Transport{
+			Proxy:       nil,
+			DialContext: socket.DialContext(strings.TrimPrefix(r.host, "npipe:///")),
+		}
+		r.host = "npipe"
+	}
+	return r
+}
+
+func (r *requester) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+r.host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	// the status endpoint answers 503 with the status when the agent is failed.
+	if res.StatusCode != http.StatusOK && !(path == "/status" && res.StatusCode == http.StatusServiceUnavailable) {
+		return nil, fmt.Errorf("response status is %d", res.StatusCode)
+	}
+	return body, nil
+}
@@ -0,0 +1,56 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package diagnostics
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stats":
+			w.Write([]byte(`{}`))
+		case "/status":
+			// a failed agent still reports its status.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"FAILED"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	procs := []Process{
+		{Name: "filebeat", RouteKey: "default", Endpoint: srv.URL},
+		{Name: "metricbeat", RouteKey: "default", Endpoint: "unix:///does/not/exist.sock"},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	require.NoError(t, ZipMetrics(context.Background(), zw, srv.URL, procs))
+	require.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	require.ElementsMatch(t, []string{
+		"metrics/",
+		"metrics/elastic-agent-stats.json",
+		"metrics/elastic-agent-status.json",
+		"metrics/filebeat_default.json",
+		"metrics/metricbeat_default_error.txt",
+	}, names)
+}
@@ -35,6 +35,8 @@ const (
 	MetaKeyAppID = "app_id"
 	// MetaKeyAppName is a metadata key used to specify application name related to error.
 	MetaKeyAppName = "app_name"
+	// MetaKeyRequestID is a metadata key used to identify the request related to a network error.
+	MetaKeyRequestID = "request_id"
 )
 
 var readableTypes = map[ErrorType]string{
@@ -92,6 +92,13 @@ func Install(cfgFile string) error {
 			fmt.Sprintf("failed to install service (%s)", paths.ServiceName),
 			errors.M("service", paths.ServiceName))
 	}
+	err = configureService()
+	if err != nil {
+		return errors.New(
+			err,
+			fmt.Sprintf("failed to configure service (%s)", paths.ServiceName),
+			errors.M("service", paths.ServiceName))
+	}
 	return nil
 }
 
@@ -12,3 +12,9 @@ func postInstall() error {
 	// do nothing
 	return nil
 }
+
+// configureService performs the configuration of the installed service for unix-based systems.
+func configureService() error {
+	// do nothing, the service restarts always on failure
+	return nil
+}
@@ -11,6 +11,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc/mgr"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
@@ -35,3 +38,32 @@ func postInstall() error {
 
 	return nil
 }
+
+// recoveryActions restart the service after a failure with an increasing delay, so an agent
+// failing at startup does not restart in a tight loop.
+var recoveryActions = []mgr.RecoveryAction{
+	{Type: mgr.ServiceRestart, Delay: 1 * time.Second},
+	{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+	{Type: mgr.ServiceRestart, Delay: 1 * time.Minute},
+}
+
+// recoveryResetPeriod is the period in seconds without failure after which the count of failures
+// is reset, the next failure then restarts the service after the first delay.
+const recoveryResetPeriod = uint32(24 * time.Hour / time.Second)
+
+// configureService sets the recovery actions of the installed service for Windows systems.
+func configureService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(paths.ServiceName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return s.SetRecoveryActions(recoveryActions, recoveryResetPeriod)
+}
@@ -39,11 +39,18 @@ func newService() (service.Service, error) {
 		Option: map[string]interface{}{
 			// Linux (systemd) always restart on failure
 			"Restart": "always",
+			// Linux (systemd) wait for the readiness of the agent and watch its liveness
+			"SystemdScript": systemdScript,
 
-			// Windows setup restart on failure
+			// Windows setup restart on failure, the delays of the next restarts are set by
+			// configureService
 			"OnFailure":              "restart",
 			"OnFailureDelayDuration": "1s",
 			"OnFailureResetPeriod":   10,
+
+			// Windows start automatically once the services needed for the network are started
+			"StartType":        "automatic",
+			"DelayedAutoStart": true,
 		},
 	})
 }
@@ -157,6 +157,11 @@ func (o *Operator) getMonitoringSteps(step configrequest.Step) []configrequest.S
 	return o.generateMonitoringSteps(step.Version, outputType, output)
 }
 
+// stackMonitor is implemented by the monitors shipping the logs to a monitoring cluster.
+type stackMonitor interface {
+	StackMonitoringLogsOutput() (map[string]interface{}, bool)
+}
+
 func (o *Operator) generateMonitoringSteps(version, outputType string, output interface{}) []configrequest.Step {
 	var steps []configrequest.Step
 	watchLogs := o.monitor.WatchLogs()
@@ -166,7 +171,14 @@ func (o *Operator) generateMonitoringSteps(version, outputType string, output in
 	// generate only when monitoring is running (for config refresh) or
 	// state changes (turning on/off)
 	if watchLogs != o.isMonitoringLogs() || watchLogs {
-		fbConfig, any := o.getMonitoringFilebeatConfig(outputType, output, monitoringNamespace)
+		logsOutputType, logsOutput := outputType, output
+		if m, ok := o.monitor.(stackMonitor); ok {
+			if stackOutput, ok := m.StackMonitoringLogsOutput(); ok {
+				logsOutputType, logsOutput = "elasticsearch", stackOutput
+			}
+		}
+
+		fbConfig, any := o.getMonitoringFilebeatConfig(logsOutputType, logsOutput, monitoringNamespace)
 		stepID := configrequest.StepRun
 		if !watchLogs || !any {
 			stepID = configrequest.StepRemove
@@ -8,10 +8,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
+
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configrequest"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/configuration"
@@ -385,3 +388,38 @@ func filterContextCancelled(err error) error {
 	}
 	return err
 }
+
+type releaser interface {
+	Release() (bool, error)
+}
+
+// Release starts again the applications of the program quarantined after crashing too often, all
+// the quarantined applications when program is empty. It returns the names of the released
+// applications.
+func (o *Operator) Release(program string) ([]string, error) {
+	o.appsLock.Lock()
+	apps := make([]Application, 0, len(o.apps))
+	for _, app := range o.apps {
+		apps = append(apps, app)
+	}
+	o.appsLock.Unlock()
+
+	var released []string
+	var errs []error
+	for _, app := range apps {
+		r, ok := app.(releaser)
+		if !ok || (program != "" && app.Spec().Cmd != program) {
+			continue
+		}
+
+		quarantined, err := r.Release()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if quarantined {
+			released = append(released, app.Name())
+		}
+	}
+	sort.Strings(released)
+	return released, multierror.Append(nil, errs...).ErrorOrNil()
+}
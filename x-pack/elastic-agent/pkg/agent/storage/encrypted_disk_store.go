@@ -0,0 +1,149 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/crypto"
+)
+
+// secretLength is the length in bytes of the generated secret used to encrypt the stores.
+const secretLength = 32
+
+// EncryptedDiskStore encrypts the content with a secret before saving it to the target file.
+type EncryptedDiskStore struct {
+	store     *DiskStore
+	secret    []byte
+	fallbacks [][]byte
+}
+
+// NewEncryptedDiskStore creates a disk store encrypting its content with the secret. The content
+// saved with one of the fallback secrets, e.g. by a previous version, can still be loaded and is
+// encrypted with the secret on the next save.
+func NewEncryptedDiskStore(target string, secret []byte, fallbacks ...[]byte) *EncryptedDiskStore {
+	return &EncryptedDiskStore{
+		store:     NewDiskStore(target),
+		secret:    secret,
+		fallbacks: fallbacks,
+	}
+}
+
+// Exists check if the store file exists on the disk.
+func (d *EncryptedDiskStore) Exists() (bool, error) {
+	return d.store.Exists()
+}
+
+// Delete deletes the store file on the disk.
+func (d *EncryptedDiskStore) Delete() error {
+	return d.store.Delete()
+}
+
+// Save encrypts the content of the reader and saves it to the target file.
+func (d *EncryptedDiskStore) Save(in io.Reader) error {
+	var buf bytes.Buffer
+	w, err := crypto.NewWriterWithDefaults(&buf, d.secret)
+	if err != nil {
+		return errors.New(err, "could not create the encryption writer", errors.TypeUnexpected)
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		return errors.New(err,
+			fmt.Sprintf("could not encrypt the content of %s", d.store.target),
+			errors.TypeUnexpected,
+			errors.M(errors.MetaKeyPath, d.store.target))
+	}
+
+	return d.store.Save(&buf)
+}
+
+// Load returns a io.ReadCloser decrypting the content of the target file, an empty reader is
+// returned when the file does not exist. With fallback secrets the content is decrypted when it
+// is loaded, to find the secret it was encrypted with.
+func (d *EncryptedDiskStore) Load() (io.ReadCloser, error) {
+	exists, err := d.store.Exists()
+	if err != nil {
+		return nil, errors.New(err,
+			fmt.Sprintf("could not check if %s exists", d.store.target),
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, d.store.target))
+	}
+	if !exists {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	fd, err := d.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.fallbacks) > 0 {
+		return d.loadWithFallbacks(fd)
+	}
+
+	r, err := crypto.NewReaderWithDefaults(fd, d.secret)
+	if err != nil {
+		_ = fd.Close()
+		return nil, errors.New(err, "could not create the decryption reader", errors.TypeUnexpected)
+	}
+	return r, nil
+}
+
+func (d *EncryptedDiskStore) loadWithFallbacks(fd io.ReadCloser) (io.ReadCloser, error) {
+	encrypted, err := ioutil.ReadAll(fd)
+	_ = fd.Close()
+	if err != nil {
+		return nil, errors.New(err,
+			fmt.Sprintf("could not read %s", d.store.target),
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, d.store.target))
+	}
+
+	for _, secret := range append([][]byte{d.secret}, d.fallbacks...) {
+		r, err := crypto.NewReaderWithDefaults(bytes.NewReader(encrypted), secret)
+		if err != nil {
+			return nil, errors.New(err, "could not create the decryption reader", errors.TypeUnexpected)
+		}
+		content, err := ioutil.ReadAll(r)
+		if err == nil {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		}
+	}
+	return nil, errors.New(
+		fmt.Sprintf("could not decrypt the content of %s", d.store.target),
+		errors.TypeUnexpected,
+		errors.M(errors.MetaKeyPath, d.store.target))
+}
+
+// LoadOrCreateSecret returns the secret saved in the target file, a random secret is generated
+// and saved when the file does not exist.
+func LoadOrCreateSecret(target string) ([]byte, error) {
+	secret, err := ioutil.ReadFile(target)
+	if err == nil && len(secret) > 0 {
+		return secret, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(err,
+			fmt.Sprintf("could not read the secret from %s", target),
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, target))
+	}
+
+	secret = make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.New(err, "could not generate the secret", errors.TypeUnexpected)
+	}
+
+	if err := NewDiskStore(target).Save(bytes.NewReader(secret)); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,95 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedDiskStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encrypted_store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	secret, err := LoadOrCreateSecret(filepath.Join(dir, "secret"))
+	require.NoError(t, err)
+
+	target := filepath.Join(dir, "state.enc")
+	s := NewEncryptedDiskStore(target, secret)
+
+	t.Run("missing file is loaded as empty", func(t *testing.T) {
+		r, err := s.Load()
+		require.NoError(t, err)
+		defer r.Close()
+
+		content, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Empty(t, content)
+	})
+
+	t.Run("content is encrypted on disk", func(t *testing.T) {
+		content := []byte("action_id: abc123\npolicy:\n  id: policy-1\n")
+		require.NoError(t, s.Save(bytes.NewReader(content)))
+
+		raw, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(raw, []byte("policy-1")))
+		checkPerms(t, target, perms)
+
+		r, err := s.Load()
+		require.NoError(t, err)
+		defer r.Close()
+
+		loaded, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, content, loaded)
+	})
+
+	t.Run("content cannot be read with another secret", func(t *testing.T) {
+		r, err := NewEncryptedDiskStore(target, []byte("another secret")).Load()
+		require.NoError(t, err)
+		defer r.Close()
+
+		_, err = ioutil.ReadAll(r)
+		require.Error(t, err)
+	})
+
+	t.Run("content saved with a fallback secret is loaded", func(t *testing.T) {
+		content := []byte("action_id: abc123\n")
+		require.NoError(t, NewEncryptedDiskStore(target, []byte("old secret")).Save(bytes.NewReader(content)))
+
+		s := NewEncryptedDiskStore(target, secret, []byte("another secret"), []byte("old secret"))
+		r, err := s.Load()
+		require.NoError(t, err)
+		loaded, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, content, loaded)
+
+		// saved again with the secret.
+		require.NoError(t, s.Save(bytes.NewReader(loaded)))
+		r, err = NewEncryptedDiskStore(target, secret).Load()
+		require.NoError(t, err)
+		defer r.Close()
+		loaded, err = ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, content, loaded)
+
+		_, err = NewEncryptedDiskStore(target, []byte("old secret"), []byte("another secret")).Load()
+		require.Error(t, err)
+	})
+
+	t.Run("secret is generated once", func(t *testing.T) {
+		again, err := LoadOrCreateSecret(filepath.Join(dir, "secret"))
+		require.NoError(t, err)
+		require.Equal(t, secret, again)
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/keystore"
+)
+
+// encryptedKeystore keeps the secrets in a file encrypted with a key bound to the host, it is used
+// in place of the keystore of the platform when the platform has none.
+type encryptedKeystore struct {
+	mx         sync.Mutex
+	target     string
+	secretPath string
+	store      *EncryptedDiskStore
+}
+
+func newEncryptedKeystore(target, secretPath string) *encryptedKeystore {
+	return &encryptedKeystore{target: target, secretPath: secretPath}
+}
+
+// Get returns the secret stored for key.
+func (k *encryptedKeystore) Get(key string) ([]byte, error) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	secrets, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return nil, keystore.ErrNotFound
+	}
+	return []byte(value), nil
+}
+
+// Set stores the secret for key.
+func (k *encryptedKeystore) Set(key string, value []byte) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	secrets, err := k.load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = string(value)
+	return k.save(secrets)
+}
+
+// Delete removes the secret stored for key, the file is removed with the last secret.
+func (k *encryptedKeystore) Delete(key string) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	exists, err := NewDiskStore(k.target).Exists()
+	if err != nil || !exists {
+		return err
+	}
+
+	secrets, err := k.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return nil
+	}
+	delete(secrets, key)
+	if len(secrets) == 0 {
+		return k.store.Delete()
+	}
+	return k.save(secrets)
+}
+
+func (k *encryptedKeystore) load() (map[string]string, error) {
+	if k.store == nil {
+		// the secret is only created when the keystore is used.
+		secret, err := LoadOrCreateSecret(k.secretPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err := HostKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		k.store = NewEncryptedDiskStore(k.target, key)
+	}
+
+	r, err := k.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.New(err, "could not decrypt the secrets", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, k.target))
+	}
+
+	secrets := make(map[string]string)
+	if err := yaml.Unmarshal(content, &secrets); err != nil {
+		return nil, errors.New(err, "could not parse the secrets", errors.TypeConfig, errors.M(errors.MetaKeyPath, k.target))
+	}
+	return secrets, nil
+}
+
+func (k *encryptedKeystore) save(secrets map[string]string) error {
+	content, err := yaml.Marshal(secrets)
+	if err != nil {
+		return errors.New(err, "could not serialize the secrets", errors.TypeConfig)
+	}
+	return k.store.Save(bytes.NewReader(content))
+}
@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/keystore"
+)
+
+func TestEncryptedKeystore(t *testing.T) {
+	withHostID(t, "host-1")
+	dir := t.TempDir()
+	target := filepath.Join(dir, "fleet.enc")
+	ks := newEncryptedKeystore(target, filepath.Join(dir, "fleet.secret"))
+
+	_, err := ks.Get("fleet.access_api_key")
+	require.True(t, errors.Is(err, keystore.ErrNotFound))
+
+	require.NoError(t, ks.Set("fleet.access_api_key", []byte("my-api-key")))
+	require.NoError(t, ks.Set("fleet.server.output.elasticsearch.service_token", []byte("my-token")))
+
+	raw, err := ioutil.ReadFile(target)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(raw, []byte("my-api-key")))
+
+	value, err := newEncryptedKeystore(target, filepath.Join(dir, "fleet.secret")).Get("fleet.access_api_key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("my-api-key"), value)
+
+	t.Run("secrets cannot be read on another host", func(t *testing.T) {
+		withHostID(t, "host-2")
+		_, err := newEncryptedKeystore(target, filepath.Join(dir, "fleet.secret")).Get("fleet.access_api_key")
+		require.Error(t, err)
+	})
+
+	require.NoError(t, ks.Delete("fleet.access_api_key"))
+	require.FileExists(t, target)
+	require.NoError(t, ks.Delete("fleet.server.output.elasticsearch.service_token"))
+	require.NoFileExists(t, target)
+	require.NoError(t, ks.Delete("fleet.access_api_key"))
+}
+
+func TestFleetSecretsWithoutKeystore(t *testing.T) {
+	withHostID(t, "host-1")
+	dir := t.TempDir()
+	target := filepath.Join(dir, "fleet.yml")
+	content := []byte("fleet:\n  enabled: true\n  access_api_key: my-api-key\n")
+
+	ks := keystore.WithFallback(&testKeystore{disabled: true}, newEncryptedKeystore(filepath.Join(dir, fleetSecretsFile), filepath.Join(dir, fleetSecretFile)))
+	s := NewKeystoreDiskStore(target, ks, fleetSecrets...)
+	require.NoError(t, s.Save(bytes.NewReader(content)))
+
+	raw, err := ioutil.ReadFile(target)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(raw, []byte("my-api-key")))
+
+	r, err := s.Load()
+	require.NoError(t, err)
+	loaded, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, content, loaded)
+
+	require.NoError(t, s.Delete())
+	require.NoFileExists(t, filepath.Join(dir, fleetSecretsFile))
+}
@@ -0,0 +1,98 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/elastic/go-sysinfo"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/keystore"
+)
+
+// agentSecretKey is the key of the secret of the agent in the keystore of the platform.
+const agentSecretKey = "agent.secret"
+
+// hostKeyContext binds the derived keys to their use by the agent.
+const hostKeyContext = "elastic-agent:"
+
+// hostID returns the unique ID of the host, replaced in tests.
+var hostID = func() (string, error) {
+	host, err := sysinfo.Host()
+	if err != nil {
+		return "", err
+	}
+	return host.Info().UniqueID, nil
+}
+
+// LoadOrCreatePlatformSecret returns the secret of the agent, the secret is read from the keystore
+// of the platform or from the target file written by previous versions. A new secret is kept in
+// the keystore and is saved to the target file only when the keystore cannot be used.
+func LoadOrCreatePlatformSecret(target string, ks keystore.Keystore) ([]byte, error) {
+	secret, err := ks.Get(agentSecretKey)
+	if err == nil && len(secret) > 0 {
+		return secret, nil
+	}
+
+	secret, err = ioutil.ReadFile(target)
+	if err == nil && len(secret) > 0 {
+		return secret, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(err,
+			fmt.Sprintf("could not read the secret from %s", target),
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, target))
+	}
+
+	secret = make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.New(err, "could not generate the secret", errors.TypeUnexpected)
+	}
+	if err := ks.Set(agentSecretKey, secret); err == nil {
+		return secret, nil
+	}
+
+	if err := NewDiskStore(target).Save(bytes.NewReader(secret)); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// HostKey derives from the secret a key bound to the host, the content encrypted with the key
+// cannot be read on another host even when the secret is copied. The key is only bound to the
+// secret on hosts without a unique ID.
+func HostKey(secret []byte) ([]byte, error) {
+	id, err := hostID()
+	if err != nil {
+		return nil, errors.New(err, "could not read the unique ID of the host", errors.TypeUnexpected)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(hostKeyContext + id))
+	return mac.Sum(nil), nil
+}
+
+// LoadOrCreateStateKey returns the key encrypting the state of the agent, bound to the host, and
+// the secret it is derived from, which encrypted the state saved by previous versions. The secret
+// is kept in the keystore of the platform, keystoreDir holds the keystore on Windows.
+func LoadOrCreateStateKey(target, keystoreDir string) (key []byte, legacy []byte, err error) {
+	secret, err := LoadOrCreatePlatformSecret(target, keystore.New(keystoreDir))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err = HostKey(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, secret, nil
+}
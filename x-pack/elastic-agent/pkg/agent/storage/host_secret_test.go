@@ -0,0 +1,83 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withHostID(t *testing.T, id string) {
+	previous := hostID
+	hostID = func() (string, error) { return id, nil }
+	t.Cleanup(func() { hostID = previous })
+}
+
+func TestLoadOrCreatePlatformSecret(t *testing.T) {
+	t.Run("secret kept in the keystore", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "agent.secret")
+		ks := &testKeystore{secrets: map[string][]byte{}}
+		secret, err := LoadOrCreatePlatformSecret(target, ks)
+		require.NoError(t, err)
+		require.Len(t, secret, secretLength)
+		require.Equal(t, secret, ks.secrets[agentSecretKey])
+		require.NoFileExists(t, target)
+
+		again, err := LoadOrCreatePlatformSecret(target, ks)
+		require.NoError(t, err)
+		require.Equal(t, secret, again)
+	})
+
+	t.Run("secret saved in the file without keystore", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "agent.secret")
+		ks := &testKeystore{disabled: true}
+		secret, err := LoadOrCreatePlatformSecret(target, ks)
+		require.NoError(t, err)
+
+		raw, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, secret, raw)
+
+		again, err := LoadOrCreatePlatformSecret(target, ks)
+		require.NoError(t, err)
+		require.Equal(t, secret, again)
+	})
+
+	t.Run("secret of previous versions is kept", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "agent.secret")
+		legacy, err := LoadOrCreateSecret(target)
+		require.NoError(t, err)
+
+		secret, err := LoadOrCreatePlatformSecret(target, &testKeystore{secrets: map[string][]byte{}})
+		require.NoError(t, err)
+		require.Equal(t, legacy, secret)
+	})
+}
+
+func TestHostKey(t *testing.T) {
+	secret := []byte("secret")
+
+	withHostID(t, "host-1")
+	key, err := HostKey(secret)
+	require.NoError(t, err)
+	require.Len(t, key, 32)
+	require.NotEqual(t, secret, key)
+
+	again, err := HostKey(secret)
+	require.NoError(t, err)
+	require.Equal(t, key, again)
+
+	other, err := HostKey([]byte("another secret"))
+	require.NoError(t, err)
+	require.NotEqual(t, key, other)
+
+	withHostID(t, "host-2")
+	copied, err := HostKey(secret)
+	require.NoError(t, err)
+	require.NotEqual(t, key, copied)
+}
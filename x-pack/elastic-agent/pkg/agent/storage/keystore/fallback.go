@@ -0,0 +1,59 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package keystore
+
+import (
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+type fallbackKeystore struct {
+	primary  Keystore
+	fallback Keystore
+}
+
+// WithFallback returns a keystore using the fallback keystore when the primary keystore cannot be
+// used, e.g. when the keystore of the platform is not available.
+func WithFallback(primary, fallback Keystore) Keystore {
+	return &fallbackKeystore{primary: primary, fallback: fallback}
+}
+
+// Get returns the secret from the primary keystore, or from the fallback keystore when the primary
+// does not have it.
+func (k *fallbackKeystore) Get(key string) ([]byte, error) {
+	value, err := k.primary.Get(key)
+	if err == nil {
+		return value, nil
+	}
+
+	value, fallbackErr := k.fallback.Get(key)
+	if fallbackErr == nil {
+		return value, nil
+	}
+	if errors.Is(fallbackErr, ErrNotFound) {
+		return nil, err
+	}
+	return nil, fallbackErr
+}
+
+// Set stores the secret in the primary keystore, the fallback keystore is used when it fails.
+func (k *fallbackKeystore) Set(key string, value []byte) error {
+	if err := k.primary.Set(key, value); err != nil {
+		return k.fallback.Set(key, value)
+	}
+	// do not keep an outdated copy of the secret.
+	return k.fallback.Delete(key)
+}
+
+// Delete removes the secret from both keystores.
+func (k *fallbackKeystore) Delete(key string) error {
+	err := k.primary.Delete(key)
+	if err != nil && errors.Is(err, ErrUnsupported) {
+		err = nil
+	}
+	if fallbackErr := k.fallback.Delete(key); fallbackErr != nil && !errors.Is(fallbackErr, ErrUnsupported) {
+		return fallbackErr
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package keystore keeps the secrets of the agent in the keystore of the platform: the Keychain
+// on macOS, the Secret Service through libsecret on Linux and files protected with DPAPI on
+// Windows.
+package keystore
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// service is the name under which the secrets of the agent are stored.
+const service = "elastic-agent"
+
+var (
+	// ErrNotFound is returned when the secret is not in the keystore.
+	ErrNotFound = errors.New("secret not found in the keystore", errors.TypeFilesystem)
+
+	// ErrUnsupported is returned when no keystore is available on the host.
+	ErrUnsupported = errors.New("no keystore available on this host", errors.TypeUnexpected)
+)
+
+// Keystore stores the secrets of the agent by key.
+type Keystore interface {
+	// Get returns the secret stored for key, ErrNotFound is returned when there is none.
+	Get(key string) ([]byte, error)
+	// Set stores the secret for key, replacing the existing one.
+	Set(key string, value []byte) error
+	// Delete removes the secret stored for key, removing a missing secret is not an error.
+	Delete(key string) error
+}
+
+// run executes the command handling the keystore, stdin is written to the standard input of the
+// command and its standard output and standard error are returned.
+func run(stdin []byte, name string, args ...string) ([]byte, string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), stderr.String(), errors.New(err, fmt.Sprintf("%s failed: %s", name, stderr.String()), errors.TypeUnexpected)
+	}
+	return stdout.Bytes(), stderr.String(), nil
+}
+
+// exitCode returns the exit code of the command which failed with err, -1 when the command did
+// not run.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
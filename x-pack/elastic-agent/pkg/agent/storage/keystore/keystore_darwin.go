@@ -0,0 +1,53 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build darwin
+// +build darwin
+
+package keystore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+)
+
+// errSecItemNotFound is the exit code of the security command when the item does not exist.
+const errSecItemNotFound = 44
+
+// keychain stores the secrets as generic passwords of the default keychain, the System keychain
+// when running as a daemon.
+type keychain struct{}
+
+// New returns the keystore of the platform, dir is not used on macOS.
+func New(_ string) Keystore {
+	return &keychain{}
+}
+
+func (k *keychain) Get(key string) ([]byte, error) {
+	out, _, err := run(nil, "security", "find-generic-password", "-s", service, "-a", key, "-w")
+	if exitCode(err) == errSecItemNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(out)))
+}
+
+func (k *keychain) Set(key string, value []byte) error {
+	// the command is read from the standard input so the secret does not show in the arguments
+	// of the process.
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", service, key, base64.StdEncoding.EncodeToString(value))
+	_, _, err := run([]byte(cmd), "security", "-i")
+	return err
+}
+
+func (k *keychain) Delete(key string) error {
+	_, _, err := run(nil, "security", "delete-generic-password", "-s", service, "-a", key)
+	if exitCode(err) == errSecItemNotFound {
+		return nil
+	}
+	return err
+}
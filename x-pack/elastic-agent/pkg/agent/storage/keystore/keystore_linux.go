@@ -0,0 +1,47 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build linux
+// +build linux
+
+package keystore
+
+import (
+	"bytes"
+	"encoding/base64"
+)
+
+// secretService stores the secrets in the Secret Service through secret-tool, the command line
+// client of libsecret. The Secret Service needs a session bus, which is often missing on servers.
+type secretService struct{}
+
+// New returns the keystore of the platform, dir is not used on Linux.
+func New(_ string) Keystore {
+	return &secretService{}
+}
+
+func (s *secretService) Get(key string) ([]byte, error) {
+	out, stderr, err := run(nil, "secret-tool", "lookup", "service", service, "key", key)
+	// secret-tool exits with 1 and prints nothing when the secret does not exist.
+	if exitCode(err) == 1 && len(out) == 0 && stderr == "" {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(out)))
+}
+
+func (s *secretService) Set(key string, value []byte) error {
+	// the secret is read from the standard input so it does not show in the arguments of the
+	// process.
+	_, _, err := run([]byte(base64.StdEncoding.EncodeToString(value)),
+		"secret-tool", "store", "--label="+service+" "+key, "service", service, "key", key)
+	return err
+}
+
+func (s *secretService) Delete(key string) error {
+	_, _, err := run(nil, "secret-tool", "clear", "service", service, "key", key)
+	return err
+}
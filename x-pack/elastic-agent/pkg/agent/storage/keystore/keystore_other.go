@@ -0,0 +1,19 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package keystore
+
+type unsupported struct{}
+
+// New returns the keystore of the platform, there is none on this platform.
+func New(_ string) Keystore {
+	return unsupported{}
+}
+
+func (unsupported) Get(_ string) ([]byte, error) { return nil, ErrUnsupported }
+func (unsupported) Set(_ string, _ []byte) error { return ErrUnsupported }
+func (unsupported) Delete(_ string) error        { return ErrUnsupported }
@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build windows
+// +build windows
+
+package keystore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// dpapi stores each secret in dir, encrypted with DPAPI. The secrets are bound to the machine so
+// the service and the commands run by an administrator read the same secrets, the files are only
+// readable by their owner.
+type dpapi struct {
+	dir string
+}
+
+// New returns the keystore of the platform, the secrets are written in dir.
+func New(dir string) Keystore {
+	return &dpapi{dir: dir}
+}
+
+func (d *dpapi) Get(key string) ([]byte, error) {
+	path := d.path(key)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New(err, fmt.Sprintf("could not read %s", path), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(newBlob(data), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, errors.New(err, fmt.Sprintf("could not decrypt %s", path), errors.TypeUnexpected, errors.M(errors.MetaKeyPath, path))
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return copyBlob(&out), nil
+}
+
+func (d *dpapi) Set(key string, value []byte) error {
+	var out windows.DataBlob
+	flags := uint32(windows.CRYPTPROTECT_UI_FORBIDDEN | windows.CRYPTPROTECT_LOCAL_MACHINE)
+	if err := windows.CryptProtectData(newBlob(value), nil, nil, 0, nil, flags, &out); err != nil {
+		return errors.New(err, "could not encrypt the secret", errors.TypeUnexpected)
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return errors.New(err, fmt.Sprintf("could not create %s", d.dir), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, d.dir))
+	}
+	path := d.path(key)
+	if err := ioutil.WriteFile(path, copyBlob(&out), 0600); err != nil {
+		return errors.New(err, fmt.Sprintf("could not write %s", path), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+	return nil
+}
+
+func (d *dpapi) Delete(key string) error {
+	path := d.path(key)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.New(err, fmt.Sprintf("could not remove %s", path), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+	return nil
+}
+
+func (d *dpapi) path(key string) string {
+	return filepath.Join(d.dir, key)
+}
+
+func newBlob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+func copyBlob(b *windows.DataBlob) []byte {
+	out := make([]byte, b.Size)
+	copy(out, unsafe.Slice(b.Data, b.Size))
+	return out
+}
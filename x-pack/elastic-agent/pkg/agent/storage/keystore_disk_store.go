@@ -0,0 +1,251 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/keystore"
+)
+
+// keystoreRefPrefix prefixes the value written in the file in place of a secret kept in the
+// keystore, the rest of the value is the key of the secret.
+const keystoreRefPrefix = "keystore:"
+
+const (
+	// fleetSecretsFile keeps the fleet secrets when the platform has no keystore.
+	fleetSecretsFile = "fleet.enc"
+	// fleetSecretFile is the secret the key encrypting fleetSecretsFile is derived from.
+	fleetSecretFile = "fleet.secret"
+)
+
+// fleetSecrets are the secrets of the fleet configuration kept in the keystore.
+var fleetSecrets = []string{
+	"fleet.access_api_key",
+	"fleet.server.output.elasticsearch.service_token",
+}
+
+// KeystoreDiskStore saves a YAML document to the target file and keeps the values of the secrets
+// in the keystore, a reference to the secret is written in the file instead. When the keystore
+// cannot be used the secrets are written in the file.
+type KeystoreDiskStore struct {
+	store    *DiskStore
+	keystore keystore.Keystore
+	secrets  []string
+	// canMigrate returns false when the secrets written in the file must not be moved to the
+	// keystore yet, nil when they can always be moved.
+	canMigrate func() bool
+}
+
+// NewKeystoreDiskStore creates a disk store keeping the secrets, identified by their dotted path
+// in the document, in the keystore.
+func NewKeystoreDiskStore(target string, ks keystore.Keystore, secrets ...string) *KeystoreDiskStore {
+	return &KeystoreDiskStore{
+		store:    NewDiskStore(target),
+		keystore: ks,
+		secrets:  secrets,
+	}
+}
+
+// NewFleetConfigStore creates the store of the fleet configuration, the access API key and the
+// service token of the local Fleet Server are kept in the keystore of the platform. On platforms
+// without keystore they are kept in a file encrypted with a key bound to the host.
+func NewFleetConfigStore(target string) *KeystoreDiskStore {
+	dir := filepath.Dir(target)
+	ks := keystore.WithFallback(
+		keystore.New(filepath.Join(dir, "keystore")),
+		newEncryptedKeystore(filepath.Join(dir, fleetSecretsFile), filepath.Join(dir, fleetSecretFile)),
+	)
+	s := NewKeystoreDiskStore(target, ks, fleetSecrets...)
+	s.canMigrate = upgradeCommitted
+	return s
+}
+
+// upgradeCommitted returns false while an upgrade waits to be committed by the watcher, the
+// previous version the watcher may roll back to could be unable to read the references.
+func upgradeCommitted() bool {
+	_, err := os.Stat(paths.AgentUpgradeMarkerFile())
+	return os.IsNotExist(err)
+}
+
+// Exists check if the store file exists on the disk.
+func (d *KeystoreDiskStore) Exists() (bool, error) {
+	return d.store.Exists()
+}
+
+// Delete deletes the secrets from the keystore and the store file on the disk.
+func (d *KeystoreDiskStore) Delete() error {
+	for _, key := range d.secrets {
+		if err := d.keystore.Delete(key); err != nil && !errors.Is(err, keystore.ErrUnsupported) {
+			return errors.New(err,
+				fmt.Sprintf("could not delete %s from the keystore", key),
+				errors.TypeFilesystem)
+		}
+	}
+	return d.store.Delete()
+}
+
+// Save moves the secrets of the document to the keystore and saves the document to the target
+// file. While secrets cannot be migrated only the secrets already referenced by the target file
+// are kept in the keystore, the other secrets stay in the file.
+func (d *KeystoreDiskStore) Save(in io.Reader) error {
+	content, err := ioutil.ReadAll(in)
+	if err != nil {
+		return errors.New(err, "could not read the content to save", errors.TypeFilesystem)
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil || doc == nil {
+		// not a document holding secrets, saved as is.
+		return d.store.Save(bytes.NewReader(content))
+	}
+
+	migrate := d.canMigrate == nil || d.canMigrate()
+	var referenced map[string]bool
+	if !migrate {
+		referenced = d.referenced()
+	}
+
+	changed := false
+	for _, key := range d.secrets {
+		value, ok := lookup(doc, key)
+		if !ok || value == "" || strings.HasPrefix(value, keystoreRefPrefix) {
+			continue
+		}
+		if !migrate && !referenced[key] {
+			continue
+		}
+		// the secret stays in the file when the keystore cannot be used.
+		if err := d.keystore.Set(key, []byte(value)); err != nil {
+			continue
+		}
+		replace(doc, key, keystoreRefPrefix+key)
+		changed = true
+	}
+	if !changed {
+		return d.store.Save(bytes.NewReader(content))
+	}
+
+	content, err = yaml.Marshal(doc)
+	if err != nil {
+		return errors.New(err, "could not serialize the content to save", errors.TypeConfig)
+	}
+	return d.store.Save(bytes.NewReader(content))
+}
+
+// Load returns a io.ReadCloser for the target file, the references to the secrets are replaced
+// with the secrets read from the keystore.
+func (d *KeystoreDiskStore) Load() (io.ReadCloser, error) {
+	fd, err := d.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(fd)
+	_ = fd.Close()
+	if err != nil {
+		return nil, errors.New(err,
+			fmt.Sprintf("could not read %s", d.store.target),
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, d.store.target))
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil || doc == nil {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	changed := false
+	for _, key := range d.secrets {
+		value, ok := lookup(doc, key)
+		if !ok || !strings.HasPrefix(value, keystoreRefPrefix) {
+			continue
+		}
+		ref := strings.TrimPrefix(value, keystoreRefPrefix)
+		secret, err := d.keystore.Get(ref)
+		if err != nil {
+			return nil, errors.New(err,
+				fmt.Sprintf("could not read %s of %s from the keystore", ref, d.store.target),
+				errors.TypeFilesystem,
+				errors.M(errors.MetaKeyPath, d.store.target))
+		}
+		replace(doc, key, string(secret))
+		changed = true
+	}
+	if !changed {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	content, err = yaml.Marshal(doc)
+	if err != nil {
+		return nil, errors.New(err, "could not serialize the loaded content", errors.TypeConfig)
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// referenced returns the secrets the target file references in the keystore.
+func (d *KeystoreDiskStore) referenced() map[string]bool {
+	referenced := make(map[string]bool)
+	content, err := ioutil.ReadFile(d.store.target)
+	if err != nil {
+		return referenced
+	}
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return referenced
+	}
+	for _, key := range d.secrets {
+		if value, ok := lookup(doc, key); ok && strings.HasPrefix(value, keystoreRefPrefix) {
+			referenced[key] = true
+		}
+	}
+	return referenced
+}
+
+// lookup returns the string value at the dotted path of the document.
+func lookup(doc yaml.MapSlice, path string) (string, bool) {
+	parts := strings.SplitN(path, ".", 2)
+	for _, item := range doc {
+		if k, ok := item.Key.(string); !ok || k != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			v, ok := item.Value.(string)
+			return v, ok
+		}
+		if sub, ok := item.Value.(yaml.MapSlice); ok {
+			return lookup(sub, parts[1])
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// replace sets the value at the dotted path of the document, the path must exist.
+func replace(doc yaml.MapSlice, path string, value string) {
+	parts := strings.SplitN(path, ".", 2)
+	for i, item := range doc {
+		if k, ok := item.Key.(string); !ok || k != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			doc[i].Value = value
+			return
+		}
+		if sub, ok := item.Value.(yaml.MapSlice); ok {
+			replace(sub, parts[1], value)
+		}
+		return
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/storage/keystore"
+)
+
+type testKeystore struct {
+	secrets  map[string][]byte
+	disabled bool
+}
+
+func (k *testKeystore) Get(key string) ([]byte, error) {
+	if k.disabled {
+		return nil, keystore.ErrUnsupported
+	}
+	v, ok := k.secrets[key]
+	if !ok {
+		return nil, keystore.ErrNotFound
+	}
+	return v, nil
+}
+
+func (k *testKeystore) Set(key string, value []byte) error {
+	if k.disabled {
+		return keystore.ErrUnsupported
+	}
+	k.secrets[key] = value
+	return nil
+}
+
+func (k *testKeystore) Delete(key string) error {
+	if k.disabled {
+		return keystore.ErrUnsupported
+	}
+	delete(k.secrets, key)
+	return nil
+}
+
+func TestKeystoreDiskStore(t *testing.T) {
+	content := []byte("fleet:\n  enabled: true\n  access_api_key: my-api-key\n  hosts:\n  - https://fleet:8220\nagent:\n  id: agent-1\n")
+
+	load := func(t *testing.T, s *KeystoreDiskStore) []byte {
+		r, err := s.Load()
+		require.NoError(t, err)
+		defer r.Close()
+		loaded, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		return loaded
+	}
+
+	t.Run("secret kept in the keystore", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "fleet.yml")
+		ks := &testKeystore{secrets: map[string][]byte{}}
+		s := NewKeystoreDiskStore(target, ks, fleetSecrets...)
+		require.NoError(t, s.Save(bytes.NewReader(content)))
+
+		raw, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(raw, []byte("my-api-key")))
+		require.True(t, bytes.Contains(raw, []byte("access_api_key: keystore:fleet.access_api_key")))
+		require.Equal(t, []byte("my-api-key"), ks.secrets["fleet.access_api_key"])
+
+		require.Equal(t, content, load(t, s))
+
+		// saving the loaded content again keeps the reference.
+		require.NoError(t, s.Save(bytes.NewReader(load(t, s))))
+		require.Equal(t, content, load(t, s))
+
+		require.NoError(t, s.Delete())
+		require.Empty(t, ks.secrets)
+	})
+
+	t.Run("secret kept in the file without keystore", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "fleet.yml")
+		s := NewKeystoreDiskStore(target, &testKeystore{disabled: true}, fleetSecrets...)
+		require.NoError(t, s.Save(bytes.NewReader(content)))
+
+		raw, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, content, raw)
+		require.Equal(t, content, load(t, s))
+	})
+
+	t.Run("file with a plain text secret is migrated on save", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "fleet.yml")
+		require.NoError(t, NewDiskStore(target).Save(bytes.NewReader(content)))
+
+		ks := &testKeystore{secrets: map[string][]byte{}}
+		s := NewKeystoreDiskStore(target, ks, fleetSecrets...)
+		loaded := load(t, s)
+		require.Equal(t, content, loaded)
+
+		require.NoError(t, s.Save(bytes.NewReader(loaded)))
+		require.Equal(t, []byte("my-api-key"), ks.secrets["fleet.access_api_key"])
+	})
+
+	t.Run("secrets are not migrated while an upgrade is pending", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "fleet.yml")
+		require.NoError(t, NewDiskStore(target).Save(bytes.NewReader(content)))
+
+		ks := &testKeystore{secrets: map[string][]byte{}}
+		s := NewKeystoreDiskStore(target, ks, fleetSecrets...)
+		committed := false
+		s.canMigrate = func() bool { return committed }
+
+		// the previous version can still read the file after a rollback.
+		require.NoError(t, s.Save(bytes.NewReader(load(t, s))))
+		raw, err := ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, content, raw)
+		require.Empty(t, ks.secrets)
+
+		// the secrets are migrated once the upgrade is committed.
+		committed = true
+		require.NoError(t, s.Save(bytes.NewReader(load(t, s))))
+		require.Equal(t, []byte("my-api-key"), ks.secrets["fleet.access_api_key"])
+
+		// secrets already referenced by the file stay in the keystore.
+		committed = false
+		require.NoError(t, s.Save(bytes.NewReader(load(t, s))))
+		raw, err = ioutil.ReadFile(target)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(raw, []byte("my-api-key")))
+		require.Equal(t, content, load(t, s))
+	})
+
+	t.Run("missing secret fails the load", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "fleet.yml")
+		ks := &testKeystore{secrets: map[string][]byte{}}
+		s := NewKeystoreDiskStore(target, ks, fleetSecrets...)
+		require.NoError(t, s.Save(bytes.NewReader(content)))
+
+		delete(ks.secrets, "fleet.access_api_key")
+		_, err := s.Load()
+		require.Error(t, err)
+	})
+}
@@ -136,6 +136,8 @@ type ActionPolicyChangeSerializer struct {
 	ActionID   string                 `yaml:"action_id"`
 	ActionType string                 `yaml:"action_type"`
 	Policy     map[string]interface{} `yaml:"policy"`
+	Signed     *fleetapi.Signed       `yaml:"signed,omitempty"`
+	DryRun     bool                   `yaml:"dry_run,omitempty"`
 }
 
 // Add a guards between the serializer structs and the original struct.
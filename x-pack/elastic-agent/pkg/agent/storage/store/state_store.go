@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 
@@ -19,6 +20,9 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 )
 
+// maxProcessedActions is the number of the most recently processed action IDs kept in the store.
+const maxProcessedActions = 100
+
 type dispatcher interface {
 	Dispatch(acker FleetAcker, actions ...action) error
 }
@@ -57,8 +61,23 @@ type StateStore struct {
 }
 
 type stateT struct {
-	action   action
-	ackToken string
+	action       action
+	ackToken     string
+	processedIDs []string
+	gateway      GatewayState
+}
+
+// GatewayState is the runtime state of the fleet gateway, it is restored at startup so a
+// restarted agent waits for its next checkin like it would have done without the restart.
+type GatewayState struct {
+	// LastCheckin is the time of the last checkin attempt.
+	LastCheckin time.Time `yaml:"last_checkin"`
+	// LastCheckinFailed is true when the last checkin attempt failed.
+	LastCheckinFailed bool `yaml:"last_checkin_failed,omitempty"`
+	// Backoff is the duration of the next backoff after a failed checkin.
+	Backoff time.Duration `yaml:"backoff,omitempty"`
+	// AckedSequence is the sequence of the last event acknowledged by fleet.
+	AckedSequence uint64 `yaml:"acked_sequence,omitempty"`
 }
 
 // Combined yml serializer for the ActionPolicyChange and ActionUnenroll
@@ -67,21 +86,26 @@ type actionSerializer struct {
 	Type       string                 `yaml:"action_type"`
 	Policy     map[string]interface{} `yaml:"policy,omitempty"`
 	IsDetected *bool                  `yaml:"is_detected,omitempty"`
+	Signed     *fleetapi.Signed       `yaml:"signed,omitempty"`
 }
 
 type stateSerializer struct {
-	Action   *actionSerializer `yaml:"action,omitempty"`
-	AckToken string            `yaml:"ack_token,omitempty"`
+	Action       *actionSerializer `yaml:"action,omitempty"`
+	AckToken     string            `yaml:"ack_token,omitempty"`
+	ProcessedIDs []string          `yaml:"processed_action_ids,omitempty"`
+	Gateway      *GatewayState     `yaml:"gateway,omitempty"`
 }
 
-// NewStateStoreWithMigration creates a new state store and migrates the old one.
-func NewStateStoreWithMigration(log *logger.Logger, actionStorePath, stateStorePath string) (*StateStore, error) {
-	err := migrateStateStore(log, actionStorePath, stateStorePath)
+// NewStateStoreWithMigration creates a new state store encrypted with the secret and migrates the
+// action store and the unencrypted state store of previous versions. A state store encrypted with
+// one of the legacy secrets is encrypted again with the secret on the next save.
+func NewStateStoreWithMigration(log *logger.Logger, actionStorePath, stateYmlPath, stateStorePath string, secret []byte, legacySecrets ...[]byte) (*StateStore, error) {
+	err := migrateStateStore(log, actionStorePath, stateYmlPath, stateStorePath, secret)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewStateStore(log, storage.NewDiskStore(stateStorePath))
+	return NewStateStore(log, storage.NewEncryptedDiskStore(stateStorePath, secret, legacySecrets...))
 }
 
 // NewStateStoreActionAcker creates a new state store backed action acker.
@@ -115,7 +139,11 @@ func NewStateStore(log *logger.Logger, store storeLoad) (*StateStore, error) {
 	}
 
 	state := stateT{
-		ackToken: sr.AckToken,
+		ackToken:     sr.AckToken,
+		processedIDs: sr.ProcessedIDs,
+	}
+	if sr.Gateway != nil {
+		state.gateway = *sr.Gateway
 	}
 
 	if sr.Action != nil {
@@ -130,6 +158,7 @@ func NewStateStore(log *logger.Logger, store storeLoad) (*StateStore, error) {
 				ActionID:   sr.Action.ID,
 				ActionType: sr.Action.Type,
 				Policy:     sr.Action.Policy,
+				Signed:     sr.Action.Signed,
 			}
 		}
 	}
@@ -141,14 +170,13 @@ func NewStateStore(log *logger.Logger, store storeLoad) (*StateStore, error) {
 	}, nil
 }
 
-func migrateStateStore(log *logger.Logger, actionStorePath, stateStorePath string) (err error) {
+func migrateStateStore(log *logger.Logger, actionStorePath, stateYmlPath, stateStorePath string, secret []byte) (err error) {
 	log = log.Named("state_migration")
 	actionDiskStore := storage.NewDiskStore(actionStorePath)
-	stateDiskStore := storage.NewDiskStore(stateStorePath)
+	stateDiskStore := storage.NewEncryptedDiskStore(stateStorePath, secret)
 
 	stateStoreExits, err := stateDiskStore.Exists()
 	if err != nil {
-		log.With()
 		log.Errorf("failed to check if state store %s exists: %v", stateStorePath, err)
 		return err
 	}
@@ -159,6 +187,18 @@ func migrateStateStore(log *logger.Logger, actionStorePath, stateStorePath strin
 		return nil
 	}
 
+	ymlDiskStore := storage.NewDiskStore(stateYmlPath)
+	ymlStoreExists, err := ymlDiskStore.Exists()
+	if err != nil {
+		log.Errorf("failed to check if state store %s exists: %v", stateYmlPath, err)
+		return err
+	}
+
+	// the state store of previous versions is unencrypted, its action store was already migrated.
+	if ymlStoreExists {
+		return migrateUnencryptedStateStore(log, ymlDiskStore, stateDiskStore)
+	}
+
 	actionStoreExits, err := actionDiskStore.Exists()
 	if err != nil {
 		log.Errorf("failed to check if action store %s exists: %v", actionStorePath, err)
@@ -208,6 +248,29 @@ func migrateStateStore(log *logger.Logger, actionStorePath, stateStorePath strin
 	return err
 }
 
+// migrateUnencryptedStateStore saves the unencrypted state store into the encrypted store and
+// deletes the unencrypted file upon successful migration.
+func migrateUnencryptedStateStore(log *logger.Logger, ymlDiskStore *storage.DiskStore, stateDiskStore *storage.EncryptedDiskStore) error {
+	stateStore, err := NewStateStore(log, ymlDiskStore)
+	if err != nil {
+		log.Errorf("failed to read unencrypted state store: %v", err)
+		return err
+	}
+
+	stateStore.store = stateDiskStore
+	stateStore.dirty = true
+	if err := stateStore.Save(); err != nil {
+		log.Errorf("failed to save encrypted state store: %v", err)
+		return err
+	}
+
+	if err := ymlDiskStore.Delete(); err != nil {
+		log.Errorf("failed to delete unencrypted state store: %v", err)
+		return err
+	}
+	return nil
+}
+
 // Add is only taking care of ActionPolicyChange for now and will only keep the last one it receive,
 // any other type of action will be silently ignored.
 func (s *StateStore) Add(a action) {
@@ -237,6 +300,57 @@ func (s *StateStore) SetAckToken(ackToken string) {
 	s.state.ackToken = ackToken
 }
 
+// MarkProcessed records the action ID as processed, only the most recent IDs are kept.
+func (s *StateStore) MarkProcessed(id string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, p := range s.state.processedIDs {
+		if p == id {
+			return
+		}
+	}
+
+	s.dirty = true
+	s.state.processedIDs = append(s.state.processedIDs, id)
+	if over := len(s.state.processedIDs) - maxProcessedActions; over > 0 {
+		s.state.processedIDs = s.state.processedIDs[over:]
+	}
+}
+
+// IsProcessed returns true if the action ID was recently processed.
+func (s *StateStore) IsProcessed(id string) bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	for _, p := range s.state.processedIDs {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SetGatewayState sets the runtime state of the fleet gateway.
+func (s *StateStore) SetGatewayState(state GatewayState) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.state.gateway == state {
+		return
+	}
+	s.dirty = true
+	s.state.gateway = state
+}
+
+// GatewayState returns the persisted runtime state of the fleet gateway, the last checkin is zero
+// when no state was persisted.
+func (s *StateStore) GatewayState() GatewayState {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.state.gateway
+}
+
 // Save saves the actions into a state store.
 func (s *StateStore) Save() error {
 	s.mx.Lock()
@@ -249,14 +363,19 @@ func (s *StateStore) Save() error {
 
 	var reader io.Reader
 	serialize := stateSerializer{
-		AckToken: s.state.ackToken,
+		AckToken:     s.state.ackToken,
+		ProcessedIDs: s.state.processedIDs,
+	}
+	if !s.state.gateway.LastCheckin.IsZero() {
+		gateway := s.state.gateway
+		serialize.Gateway = &gateway
 	}
 
 	if s.state.action != nil {
 		if apc, ok := s.state.action.(*fleetapi.ActionPolicyChange); ok {
-			serialize.Action = &actionSerializer{apc.ActionID, apc.ActionType, apc.Policy, nil}
+			serialize.Action = &actionSerializer{apc.ActionID, apc.ActionType, apc.Policy, nil, apc.Signed}
 		} else if aun, ok := s.state.action.(*fleetapi.ActionUnenroll); ok {
-			serialize.Action = &actionSerializer{aun.ActionID, aun.ActionType, nil, &aun.IsDetected}
+			serialize.Action = &actionSerializer{aun.ActionID, aun.ActionType, nil, &aun.IsDetected, nil}
 		} else {
 			return fmt.Errorf("incompatible type, expected ActionPolicyChange and received %T", s.state.action)
 		}
@@ -6,11 +6,13 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
@@ -132,6 +134,55 @@ func runTestStateStore(t *testing.T, ackToken string) {
 			require.Equal(t, ackToken, store.AckToken())
 		}))
 
+	t.Run("processed action IDs are persisted",
+		withFile(func(t *testing.T, file string) {
+			s := storage.NewDiskStore(file)
+			store, err := NewStateStore(log, s)
+			require.NoError(t, err)
+
+			for i := 0; i <= maxProcessedActions; i++ {
+				store.MarkProcessed(fmt.Sprintf("action-%d", i))
+			}
+			require.NoError(t, store.Save())
+
+			s = storage.NewDiskStore(file)
+			store1, err := NewStateStore(log, s)
+			require.NoError(t, err)
+
+			// the oldest ID is discarded once the limit is reached.
+			require.False(t, store1.IsProcessed("action-0"))
+			require.True(t, store1.IsProcessed("action-1"))
+			require.True(t, store1.IsProcessed(fmt.Sprintf("action-%d", maxProcessedActions)))
+			require.False(t, store1.IsProcessed("unknown"))
+		}))
+
+	t.Run("gateway state is persisted",
+		withFile(func(t *testing.T, file string) {
+			s := storage.NewDiskStore(file)
+			store, err := NewStateStore(log, s)
+			require.NoError(t, err)
+			require.True(t, store.GatewayState().LastCheckin.IsZero())
+
+			state := GatewayState{
+				LastCheckin:       time.Date(2021, 3, 4, 10, 11, 12, 0, time.UTC),
+				LastCheckinFailed: true,
+				Backoff:           4 * time.Minute,
+				AckedSequence:     42,
+			}
+			store.SetGatewayState(state)
+			require.NoError(t, store.Save())
+
+			s = storage.NewDiskStore(file)
+			store1, err := NewStateStore(log, s)
+			require.NoError(t, err)
+
+			restored := store1.GatewayState()
+			require.True(t, state.LastCheckin.Equal(restored.LastCheckin))
+			require.Equal(t, state.LastCheckinFailed, restored.LastCheckinFailed)
+			require.Equal(t, state.Backoff, restored.Backoff)
+			require.Equal(t, state.AckedSequence, restored.AckedSequence)
+		}))
+
 	t.Run("when we ACK we save to disk",
 		withFile(func(t *testing.T, file string) {
 			ActionPolicyChange := &fleetapi.ActionPolicyChange{
@@ -154,9 +205,9 @@ func runTestStateStore(t *testing.T, ackToken string) {
 	t.Run("migrate actions file does not exists",
 		withFile(func(t *testing.T, actionStorePath string) {
 			withFile(func(t *testing.T, stateStorePath string) {
-				err := migrateStateStore(log, actionStorePath, stateStorePath)
+				err := migrateStateStore(log, actionStorePath, stateStorePath+".yml", stateStorePath, testSecret)
 				require.NoError(t, err)
-				stateStore, err := NewStateStore(log, storage.NewDiskStore(stateStorePath))
+				stateStore, err := NewStateStore(log, storage.NewEncryptedDiskStore(stateStorePath, testSecret))
 				require.NoError(t, err)
 				stateStore.SetAckToken(ackToken)
 				require.Equal(t, 0, len(stateStore.Actions()))
@@ -184,10 +235,10 @@ func runTestStateStore(t *testing.T, ackToken string) {
 			require.Equal(t, 1, len(actionStore.Actions()))
 
 			withFile(func(t *testing.T, stateStorePath string) {
-				err = migrateStateStore(log, actionStorePath, stateStorePath)
+				err = migrateStateStore(log, actionStorePath, stateStorePath+".yml", stateStorePath, testSecret)
 				require.NoError(t, err)
 
-				stateStore, err := NewStateStore(log, storage.NewDiskStore(stateStorePath))
+				stateStore, err := NewStateStore(log, storage.NewEncryptedDiskStore(stateStorePath, testSecret))
 				require.NoError(t, err)
 				stateStore.SetAckToken(ackToken)
 				diff := cmp.Diff(actionStore.Actions(), stateStore.Actions())
@@ -198,8 +249,62 @@ func runTestStateStore(t *testing.T, ackToken string) {
 			})
 		}))
 
+	t.Run("migrate unencrypted state store",
+		withFile(func(t *testing.T, stateYmlPath string) {
+			ActionPolicyChange := &fleetapi.ActionPolicyChange{
+				ActionID:   "abc123",
+				ActionType: "POLICY_CHANGE",
+				Policy: map[string]interface{}{
+					"hello": "world",
+				},
+			}
+
+			ymlStore, err := NewStateStore(log, storage.NewDiskStore(stateYmlPath))
+			require.NoError(t, err)
+			ymlStore.Add(ActionPolicyChange)
+			ymlStore.SetAckToken(ackToken)
+			require.NoError(t, ymlStore.Save())
+
+			stateStorePath := filepath.Join(filepath.Dir(stateYmlPath), "state.enc")
+			err = migrateStateStore(log, filepath.Join(filepath.Dir(stateYmlPath), "action_store.yml"), stateYmlPath, stateStorePath, testSecret)
+			require.NoError(t, err)
+
+			_, err = os.Stat(stateYmlPath)
+			require.True(t, os.IsNotExist(err))
+
+			stateStore, err := NewStateStore(log, storage.NewEncryptedDiskStore(stateStorePath, testSecret))
+			require.NoError(t, err)
+			diff := cmp.Diff(ymlStore.Actions(), stateStore.Actions())
+			if diff != "" {
+				t.Error(diff)
+			}
+			require.Equal(t, ackToken, stateStore.AckToken())
+		}))
+
+	t.Run("state store encrypted with a legacy secret",
+		withFile(func(t *testing.T, stateYmlPath string) {
+			dir := filepath.Dir(stateYmlPath)
+			stateStorePath := filepath.Join(dir, "state.enc")
+			legacyStore, err := NewStateStore(log, storage.NewEncryptedDiskStore(stateStorePath, testSecret))
+			require.NoError(t, err)
+			legacyStore.SetAckToken(ackToken)
+			require.NoError(t, legacyStore.Save())
+
+			hostKey := []byte("state store host key")
+			stateStore, err := NewStateStoreWithMigration(log, filepath.Join(dir, "action_store.yml"), stateYmlPath, stateStorePath, hostKey, testSecret)
+			require.NoError(t, err)
+			require.Equal(t, ackToken, stateStore.AckToken())
+
+			stateStore.SetAckToken("new-token")
+			require.NoError(t, stateStore.Save())
+			stateStore, err = NewStateStore(log, storage.NewEncryptedDiskStore(stateStorePath, hostKey))
+			require.NoError(t, err)
+			require.Equal(t, "new-token", stateStore.AckToken())
+		}))
 }
 
+var testSecret = []byte("state store secret")
+
 type testAcker struct {
 	acked     []string
 	ackedLock sync.Mutex
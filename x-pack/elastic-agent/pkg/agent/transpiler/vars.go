@@ -15,7 +15,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 )
 
-var varsRegex = regexp.MustCompile(`\${([\p{L}\d\s\\\-_|.'"]*)}`)
+var varsRegex = regexp.MustCompile(`\${([\p{L}\d\s\\\-_|.:/'"]*)}`)
 
 // ErrNoMatch is return when the replace didn't fail, just that no vars match to perform the replace.
 var ErrNoMatch = fmt.Errorf("no matching vars")
@@ -230,7 +230,11 @@ func extractVars(i string) ([]varI, error) {
 	return res, nil
 }
 
+// varPrefixMatched returns true when the variable is prefixed by key, the prefix is followed by a
+// dot or by a colon for the providers taking a path, e.g. ${file:/run/secrets/password}.
 func varPrefixMatched(val string, key string) bool {
-	s := strings.SplitN(val, ".", 2)
-	return s[0] == key
+	if i := strings.IndexAny(val, ".:"); i >= 0 {
+		val = val[:i]
+	}
+	return val == key
 }
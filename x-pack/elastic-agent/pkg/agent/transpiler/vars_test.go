@@ -265,6 +265,7 @@ func TestVars_ReplaceWithFetchContextProvider(t *testing.T) {
 
 	fetchContextProviders := common.MapStr{
 		"kubernetes_secrets": mockFetchProvider,
+		"file":               mockFetchProvider,
 	}
 	vars, err := NewVarsWithProcessors(
 		map[string]interface{}{
@@ -319,6 +320,14 @@ func TestVars_ReplaceWithFetchContextProvider(t *testing.T) {
 	res, err = vars.Replace("${kubernetes_secrets.test_namespace.testing_secret.secret_value}")
 	require.NoError(t, err)
 	assert.Equal(t, NewStrVal("mockedFetchContent"), res)
+
+	res, err = vars.Replace("password: ${file:/run/secrets/password}")
+	require.NoError(t, err)
+	assert.Equal(t, NewStrVal("password: mockedFetchContent"), res)
+
+	// the colon only separates the name of a provider.
+	_, err = vars.Replace("${testing:/key1}")
+	assert.Equal(t, ErrNoMatch, err)
 }
 
 type contextProviderMock struct {
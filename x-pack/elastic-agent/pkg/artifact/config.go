@@ -5,6 +5,7 @@
 package artifact
 
 import (
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -13,6 +14,8 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
 )
 
+const fileScheme = "file://"
+
 // Config is a configuration used for verifier and downloader
 type Config struct {
 	// OperatingSystem: operating system [linux, windows, darwin]
@@ -21,9 +24,18 @@ type Config struct {
 	// Architecture: target architecture [32, 64]
 	Architecture string `json:"-" config:",ignore"`
 
-	// SourceURI: source of the artifacts, e.g https://artifacts.elastic.co/downloads/
+	// SourceURI: source of the artifacts, e.g https://artifacts.elastic.co/downloads/ or
+	// file:///opt/artifacts for a local directory.
 	SourceURI string `json:"sourceURI" config:"sourceURI"`
 
+	// Username and Password: credentials sent with the requests to SourceURI, e.g. for an internal
+	// mirror of the artifacts.
+	Username string `json:"username,omitempty" config:"username"`
+	Password string `json:"password,omitempty" config:"password"`
+
+	// Headers: additional headers sent with the requests to SourceURI.
+	Headers map[string]string `json:"headers,omitempty" config:"headers"`
+
 	// TargetDirectory: path to the directory containing downloaded packages
 	TargetDirectory string `json:"targetDirectory" config:"target_directory"`
 
@@ -55,6 +67,21 @@ func DefaultConfig() *Config {
 	}
 }
 
+// SourceDirectory returns the local directory of the artifacts when SourceURI uses the file
+// scheme, the artifacts are then only fetched from this directory.
+func (c *Config) SourceDirectory() (string, bool) {
+	if !strings.HasPrefix(c.SourceURI, fileScheme) {
+		return "", false
+	}
+
+	dir := strings.TrimPrefix(c.SourceURI, fileScheme)
+	if runtime.GOOS == "windows" && len(dir) > 2 && dir[0] == '/' && dir[2] == ':' {
+		// e.g file:///C:/artifacts
+		dir = dir[1:]
+	}
+	return filepath.FromSlash(dir), true
+}
+
 // OS return configured operating system or falls back to runtime.GOOS
 func (c *Config) OS() string {
 	if c.OperatingSystem != "" {
@@ -57,7 +57,16 @@ func (e *Downloader) Download(_ context.Context, spec program.Spec, version stri
 
 	hashPath, err := e.downloadHash(e.config.OS(), spec, version)
 	downloadedFiles = append(downloadedFiles, hashPath)
-	return path, err
+	if err != nil {
+		return "", err
+	}
+
+	// the signature is optional, without it the package is verified against the remote signature.
+	ascPath, err := e.downloadFile(spec.Artifact, filepath.Base(path)+ascSuffix, path+ascSuffix)
+	if err == nil {
+		downloadedFiles = append(downloadedFiles, ascPath)
+	}
+	return path, nil
 }
 
 func (e *Downloader) download(operatingSystem string, spec program.Spec, version string) (string, error) {
@@ -71,7 +80,7 @@ func (e *Downloader) download(operatingSystem string, spec program.Spec, version
 		return "", errors.New(err, "generating package path failed")
 	}
 
-	return e.downloadFile(filename, fullPath)
+	return e.downloadFile(spec.Artifact, filename, fullPath)
 }
 
 func (e *Downloader) downloadHash(operatingSystem string, spec program.Spec, version string) (string, error) {
@@ -88,11 +97,28 @@ func (e *Downloader) downloadHash(operatingSystem string, spec program.Spec, ver
 	filename = filename + ".sha512"
 	fullPath = fullPath + ".sha512"
 
-	return e.downloadFile(filename, fullPath)
+	return e.downloadFile(spec.Artifact, filename, fullPath)
 }
 
-func (e *Downloader) downloadFile(filename, fullPath string) (string, error) {
+// downloadFile copies the file from the drop path, the file is either at the root of the drop
+// path or in the directory of the artifact, e.g. beats/filebeat, as in a mirror of the remote
+// source.
+func (e *Downloader) downloadFile(artifactName, filename, fullPath string) (string, error) {
 	sourcePath := filepath.Join(e.dropPath, filename)
+	if _, err := os.Stat(sourcePath); os.IsNotExist(err) && artifactName != "" {
+		mirrorPath := filepath.Join(e.dropPath, filepath.FromSlash(artifactName), filename)
+		if _, err := os.Stat(mirrorPath); err == nil {
+			sourcePath = mirrorPath
+		}
+	}
+	if filepath.Clean(sourcePath) == filepath.Clean(fullPath) {
+		// the drop path is the target directory, the file is already in place.
+		if _, err := os.Stat(fullPath); err != nil {
+			return "", errors.New(err, fmt.Sprintf("package '%s' not found", sourcePath), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, fullPath))
+		}
+		return fullPath, nil
+	}
+
 	sourceFile, err := os.Open(sourcePath)
 	if err != nil {
 		return "", errors.New(err, fmt.Sprintf("package '%s' not found", sourcePath), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, fullPath))
@@ -21,6 +21,7 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 )
 
 const (
@@ -147,10 +148,13 @@ func (v *Verifier) verifyAsc(filename, fullPath string) (bool, error) {
 	if err != nil {
 		return false, errors.New(err, "read armored key ring", errors.TypeSecurity)
 	}
-	_, err = openpgp.CheckArmoredDetachedSignature(keyring, fileReader, ascReader)
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, fileReader, ascReader)
 	if err != nil {
 		return false, errors.New(err, "check detached signature", errors.TypeSecurity)
 	}
+	if err := fips.CheckSignature(signer, ascBytes); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
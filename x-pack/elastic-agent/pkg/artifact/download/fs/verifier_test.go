@@ -198,3 +198,40 @@ func prepareTestCase(beatSpec program.Spec, version string, cfg *artifact.Config
 
 	return ioutil.WriteFile(filepath.Join(cfg.DropPath, filename+".sha512"), []byte(hashContent), 0644)
 }
+
+func TestDownloadFromMirror(t *testing.T) {
+	targetDir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(targetDir)
+
+	dropPath := filepath.Join(targetDir, "drop")
+	config := &artifact.Config{
+		TargetDirectory: targetDir,
+		// the drop path mirrors the layout of the remote source
+		DropPath:        filepath.Join(dropPath, filepath.FromSlash(beatSpec.Artifact)),
+		OperatingSystem: "linux",
+		Architecture:    "32",
+	}
+	if err := prepareTestCase(beatSpec, version, config); err != nil {
+		t.Fatal(err)
+	}
+	filename, err := artifact.GetArtifactName(beatSpec, version, config.OperatingSystem, config.Architecture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(config.DropPath, filename+ascSuffix), []byte("signature"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.DropPath = dropPath
+
+	path, err := NewDownloader(config).Download(context.Background(), beatSpec, version)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(targetDir, filename), path)
+
+	for _, suffix := range []string{"", ".sha512", ascSuffix} {
+		_, err = os.Stat(path + suffix)
+		assert.NoError(t, err)
+	}
+}
@@ -19,11 +19,15 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 )
 
 const (
 	packagePermissions = 0660
+
+	// partialSuffix is appended to the name of the files being downloaded.
+	partialSuffix = ".part"
 )
 
 var headers = map[string]string{
@@ -38,14 +42,19 @@ type Downloader struct {
 
 // NewDownloader creates and configures Elastic Downloader
 func NewDownloader(config *artifact.Config) (*Downloader, error) {
-	client, err := config.HTTPTransportSettings.Client(
+	settings, err := fips.HTTPTransport(config.HTTPTransportSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := settings.Client(
 		httpcommon.WithAPMHTTPInstrumentation(),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	client.Transport = withHeaders(client.Transport, headers)
+	client.Transport = withCredentials(withHeaders(client.Transport, headers), config)
 	return NewDownloaderWithClient(config, *client), nil
 }
 
@@ -81,12 +90,18 @@ func (e *Downloader) Download(ctx context.Context, spec program.Spec, version st
 	return path, err
 }
 
-func (e *Downloader) composeURI(artifactName, packageName string) (string, error) {
-	upstream := e.config.SourceURI
-	if !strings.HasPrefix(upstream, "http") && !strings.HasPrefix(upstream, "file") && !strings.HasPrefix(upstream, "/") {
+// upstreamURI returns the URI of the source of the artifacts, https is used when the source has no
+// scheme.
+func upstreamURI(sourceURI string) string {
+	if !strings.HasPrefix(sourceURI, "http") && !strings.HasPrefix(sourceURI, "file") && !strings.HasPrefix(sourceURI, "/") {
 		// always default to https
-		upstream = fmt.Sprintf("https://%s", upstream)
+		return fmt.Sprintf("https://%s", sourceURI)
 	}
+	return sourceURI
+}
+
+func (e *Downloader) composeURI(artifactName, packageName string) (string, error) {
+	upstream := upstreamURI(e.config.SourceURI)
 
 	// example: https://artifacts.elastic.co/downloads/beats/filebeat/filebeat-7.1.1-x86_64.rpm
 	uri, err := url.Parse(upstream)
@@ -129,37 +144,94 @@ func (e *Downloader) downloadHash(ctx context.Context, operatingSystem string, s
 	return e.downloadFile(ctx, spec.Artifact, filename, fullPath)
 }
 
+// downloadFile fetches the file into fullPath. The file is written next to fullPath with the
+// partialSuffix until it is complete, a download interrupted by a network error is resumed from
+// the partial file by the next attempt when the source supports range requests.
 func (e *Downloader) downloadFile(ctx context.Context, artifactName, filename, fullPath string) (string, error) {
 	sourceURI, err := e.composeURI(artifactName, filename)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("GET", sourceURI, nil)
+	partialPath := fullPath + partialSuffix
+	destinationFile, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY, packagePermissions)
 	if err != nil {
-		return "", errors.New(err, "fetching package failed", errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
+		return "", errors.New(err, "creating package file failed", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, partialPath))
 	}
+	defer destinationFile.Close()
 
-	destinationFile, err := os.OpenFile(fullPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, packagePermissions)
+	offset, err := destinationFile.Seek(0, io.SeekEnd)
 	if err != nil {
-		return "", errors.New(err, "creating package file failed", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, fullPath))
+		return "", errors.New(err, "reading partial package file failed", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, partialPath))
 	}
-	defer destinationFile.Close()
 
-	resp, err := e.client.Do(req.WithContext(ctx))
+	resp, err := e.get(ctx, sourceURI, offset)
 	if err != nil {
-		return "", errors.New(err, "fetching package failed", errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file does not match the source anymore, start over.
+		resp.Body.Close()
+		offset = 0
+		resp, err = e.get(ctx, sourceURI, offset)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", errors.New(fmt.Sprintf("call to '%s' returned unsuccessful status code: %d", sourceURI, resp.StatusCode), errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
+		}
+		err = restartFile(destinationFile)
+	case http.StatusOK:
+		// the source does not support range requests or the download starts.
+		err = restartFile(destinationFile)
+	default:
 		return "", errors.New(fmt.Sprintf("call to '%s' returned unsuccessful status code: %d", sourceURI, resp.StatusCode), errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
 	}
+	if err != nil {
+		return "", errors.New(err, "truncating partial package file failed", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, partialPath))
+	}
 
 	_, err = io.Copy(destinationFile, resp.Body)
 	if err != nil {
 		return "", errors.New(err, "fetching package failed", errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
 	}
 
+	if err := destinationFile.Close(); err != nil {
+		return "", errors.New(err, "writing package file failed", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, partialPath))
+	}
+	if err := os.Rename(partialPath, fullPath); err != nil {
+		return "", errors.New(err, "moving package file failed", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, fullPath))
+	}
+
 	return fullPath, nil
 }
+
+// get requests the source from the offset, the whole source is requested when the offset is 0.
+func (e *Downloader) get(ctx context.Context, sourceURI string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequest("GET", sourceURI, nil)
+	if err != nil {
+		return nil, errors.New(err, "fetching package failed", errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.New(err, "fetching package failed", errors.TypeNetwork, errors.M(errors.MetaKeyURI, sourceURI))
+	}
+	return resp, nil
+}
+
+func restartFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
@@ -5,13 +5,18 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
 )
@@ -52,3 +57,44 @@ func TestDownloadBodyError(t *testing.T) {
 		t.Fatal("expected Download to return an error")
 	}
 }
+
+func TestDownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("elastic-agent"), 1024)
+
+	var mx sync.Mutex
+	ranges := make([]string, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mx.Unlock()
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	config := &artifact.Config{
+		SourceURI:       srv.URL,
+		TargetDirectory: t.TempDir(),
+		OperatingSystem: "linux",
+		Architecture:    "64",
+	}
+
+	fullPath, err := artifact.GetArtifactPath(beatSpec, version, config.OS(), config.Arch(), config.TargetDirectory)
+	require.NoError(t, err)
+	// a previous attempt was interrupted half way.
+	require.NoError(t, ioutil.WriteFile(fullPath+partialSuffix, content[:len(content)/2], packagePermissions))
+
+	testClient := NewDownloaderWithClient(config, *srv.Client())
+	artifactPath, err := testClient.Download(context.Background(), beatSpec, version)
+	require.NoError(t, err)
+	require.Equal(t, fullPath, artifactPath)
+
+	downloaded, err := ioutil.ReadFile(artifactPath)
+	require.NoError(t, err)
+	require.Equal(t, content, downloaded)
+	require.NoFileExists(t, fullPath+partialSuffix)
+
+	mx.Lock()
+	defer mx.Unlock()
+	// the package is resumed and the hash is downloaded from the start.
+	require.Equal(t, []string{"bytes=6656-", ""}, ranges)
+}
@@ -4,7 +4,12 @@
 
 package http
 
-import "net/http"
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
+)
 
 func withHeaders(rtt http.RoundTripper, headers map[string]string) http.RoundTripper {
 	if rtt == nil {
@@ -24,3 +29,47 @@ func (r *rttWithHeaders) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	return r.target.RoundTrip(req)
 }
+
+// withCredentials adds the credentials and the headers of the configuration to the requests to
+// the host of the source of the artifacts, they are not sent to the hosts the requests are
+// redirected to.
+func withCredentials(rtt http.RoundTripper, config *artifact.Config) http.RoundTripper {
+	if config.Username == "" && len(config.Headers) == 0 {
+		return rtt
+	}
+	if rtt == nil {
+		rtt = http.DefaultTransport
+	}
+
+	var host string
+	if u, err := url.Parse(upstreamURI(config.SourceURI)); err == nil {
+		host = u.Host
+	}
+	return &rttWithCredentials{
+		target:   rtt,
+		host:     host,
+		username: config.Username,
+		password: config.Password,
+		headers:  config.Headers,
+	}
+}
+
+type rttWithCredentials struct {
+	target   http.RoundTripper
+	host     string
+	username string
+	password string
+	headers  map[string]string
+}
+
+func (r *rttWithCredentials) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == r.host {
+		for k, v := range r.headers {
+			req.Header.Set(k, v)
+		}
+		if r.username != "" {
+			req.SetBasicAuth(r.username, r.password)
+		}
+	}
+	return r.target.RoundTrip(req)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 )
 
@@ -36,3 +37,39 @@ func TestAddingHeaders(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, b, msg)
 }
+
+func TestAddingCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		username, password, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "elastic", username)
+		assert.Equal(t, "changeme", password)
+		assert.Equal(t, "mirror", req.Header.Get("X-Mirror"))
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _, ok := req.BasicAuth()
+		assert.False(t, ok, "credentials must only be sent to the source of the artifacts")
+		assert.Empty(t, req.Header.Get("X-Mirror"))
+		w.Write([]byte("OK"))
+	}))
+	defer other.Close()
+
+	config := &artifact.Config{
+		SourceURI: server.URL + "/downloads/",
+		Username:  "elastic",
+		Password:  "changeme",
+		Headers:   map[string]string{"X-Mirror": "mirror"},
+	}
+	c := server.Client()
+	c.Transport = withCredentials(c.Transport, config)
+
+	for _, u := range []string{server.URL, other.URL} {
+		resp, err := c.Get(u)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 )
 
 const (
@@ -47,10 +48,15 @@ func NewVerifier(config *artifact.Config, allowEmptyPgp bool, pgp []byte) (*Veri
 		return nil, errors.New("expecting PGP but retrieved none", errors.TypeSecurity)
 	}
 
-	client, err := config.HTTPTransportSettings.Client(
+	settings, err := fips.HTTPTransport(config.HTTPTransportSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := settings.Client(
 		httpcommon.WithAPMHTTPInstrumentation(),
 		httpcommon.WithModRoundtripper(func(rt http.RoundTripper) http.RoundTripper {
-			return withHeaders(rt, headers)
+			return withCredentials(withHeaders(rt, headers), config)
 		}),
 	)
 	if err != nil {
@@ -182,21 +188,20 @@ func (v *Verifier) verifyAsc(spec program.Spec, version string) (bool, error) {
 	if err != nil {
 		return false, errors.New(err, "read armored key ring", errors.TypeSecurity)
 	}
-	_, err = openpgp.CheckArmoredDetachedSignature(keyring, fileReader, ascReader)
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, fileReader, ascReader)
 	if err != nil {
 		return false, errors.New(err, "check detached signature", errors.TypeSecurity)
 	}
+	if err := fips.CheckSignature(signer, ascBytes); err != nil {
+		return false, err
+	}
 
 	return true, nil
 
 }
 
 func (v *Verifier) composeURI(filename, artifactName string) (string, error) {
-	upstream := v.config.SourceURI
-	if !strings.HasPrefix(upstream, "http") && !strings.HasPrefix(upstream, "file") && !strings.HasPrefix(upstream, "/") {
-		// always default to https
-		upstream = fmt.Sprintf("https://%s", upstream)
-	}
+	upstream := upstreamURI(v.config.SourceURI)
 
 	// example: https://artifacts.elastic.co/downloads/beats/filebeat/filebeat-7.1.1-x86_64.rpm
 	uri, err := url.Parse(upstream)
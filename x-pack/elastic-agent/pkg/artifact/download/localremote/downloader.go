@@ -17,7 +17,14 @@ import (
 
 // NewDownloader creates a downloader which first checks local directory
 // and then fallbacks to remote if configured.
+//
+// When the source of the artifacts is a local directory the artifacts are only fetched from this
+// directory.
 func NewDownloader(log *logger.Logger, config *artifact.Config) (download.Downloader, error) {
+	if local, ok := localConfig(config); ok {
+		return fs.NewDownloader(local), nil
+	}
+
 	downloaders := make([]download.Downloader, 0, 3)
 	downloaders = append(downloaders, fs.NewDownloader(config))
 
@@ -39,3 +46,15 @@ func NewDownloader(log *logger.Logger, config *artifact.Config) (download.Downlo
 	downloaders = append(downloaders, httpDownloader)
 	return composed.NewDownloader(downloaders...), nil
 }
+
+// localConfig returns the configuration of the fs downloader and verifier fetching the artifacts
+// from the source directory.
+func localConfig(config *artifact.Config) (*artifact.Config, bool) {
+	dir, ok := config.SourceDirectory()
+	if !ok {
+		return nil, false
+	}
+	local := *config
+	local.DropPath = dir
+	return &local, true
+}
@@ -17,7 +17,14 @@ import (
 
 // NewVerifier creates a downloader which first checks local directory
 // and then fallbacks to remote if configured.
+//
+// When the source of the artifacts is a local directory the package is only verified with the
+// hash and the signature found in this directory.
 func NewVerifier(log *logger.Logger, config *artifact.Config, allowEmptyPgp bool, pgp []byte) (download.Verifier, error) {
+	if local, ok := localConfig(config); ok {
+		return fs.NewVerifier(local, allowEmptyPgp, pgp)
+	}
+
 	verifiers := make([]download.Verifier, 0, 3)
 
 	fsVer, err := fs.NewVerifier(config, allowEmptyPgp, pgp)
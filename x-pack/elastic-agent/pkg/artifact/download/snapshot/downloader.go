@@ -13,6 +13,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact/download"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/artifact/download/http"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 )
 
@@ -52,7 +53,12 @@ func snapshotURI(versionOverride string, config *artifact.Config) (string, error
 		version = versionOverride
 	}
 
-	client, err := config.HTTPTransportSettings.Client(httpcommon.WithAPMHTTPInstrumentation())
+	settings, err := fips.HTTPTransport(config.HTTPTransportSettings)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := settings.Client(httpcommon.WithAPMHTTPInstrumentation())
 	if err != nil {
 		return "", err
 	}
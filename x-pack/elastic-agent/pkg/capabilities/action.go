@@ -0,0 +1,123 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package capabilities
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+func newActionsCapability(log *logger.Logger, rd *ruleDefinitions, reporter status.Reporter) (Capability, error) {
+	if rd == nil {
+		return &multiActionsCapability{log: log, caps: []*actionCapability{}}, nil
+	}
+
+	caps := make([]*actionCapability, 0, len(rd.Capabilities))
+
+	for _, r := range rd.Capabilities {
+		c, err := newActionCapability(log, r, reporter)
+		if err != nil {
+			return nil, err
+		}
+
+		if c != nil {
+			caps = append(caps, c)
+		}
+	}
+
+	return &multiActionsCapability{log: log, caps: caps}, nil
+}
+
+func newActionCapability(log *logger.Logger, r ruler, reporter status.Reporter) (*actionCapability, error) {
+	cap, ok := r.(*actionCapability)
+	if !ok {
+		return nil, nil
+	}
+
+	cap.Type = strings.ToLower(cap.Type)
+	if cap.Type != allowKey && cap.Type != denyKey {
+		return nil, fmt.Errorf("'%s' is not a valid type 'allow' and 'deny' are supported", cap.Type)
+	}
+
+	cap.log = log
+	cap.reporter = reporter
+	return cap, nil
+}
+
+type actionCapability struct {
+	log      *logger.Logger
+	reporter status.Reporter
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Type     string `json:"rule" yaml:"rule"`
+	// Action is the type of the action, e.g. UNENROLL or * for all the actions.
+	Action string `json:"action" yaml:"action"`
+}
+
+func (c *actionCapability) Rule() string {
+	return c.Type
+}
+
+func (c *actionCapability) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	t := "allow"
+	if c.Type == denyKey {
+		t = "deny"
+	}
+
+	// e.g A allow(*) or A deny(UNENROLL)
+	c.Name = fmt.Sprintf("A %s(%s)", t, c.Action)
+	return c.Name
+}
+
+// matches returns true when the rule applies to the type of the action, action types are matched
+// regardless of their case.
+func (c *actionCapability) matches(actionType string) bool {
+	return matchesExpr(strings.ToUpper(c.Action), strings.ToUpper(actionType))
+}
+
+// Apply returns ErrBlocked when the action is denied.
+func (c *actionCapability) Apply(a fleetapi.Action) error {
+	if c.Type == allowKey {
+		return nil
+	}
+
+	msg := fmt.Sprintf("action '%s' of type '%s' is blocked due to capability restriction '%s'", a.ID(), a.Type(), c.name())
+	c.log.Errorf(msg)
+	c.reporter.Update(state.Degraded, msg, nil)
+	return ErrBlocked
+}
+
+type multiActionsCapability struct {
+	log  *logger.Logger
+	caps []*actionCapability
+}
+
+// Apply applies the first rule matching the type of the action, actions matching no rule are
+// allowed.
+func (c *multiActionsCapability) Apply(in interface{}) (interface{}, error) {
+	a, ok := in.(fleetapi.Action)
+	if !ok {
+		// not an action we don't alter origin
+		return in, nil
+	}
+
+	for _, cap := range c.caps {
+		if !cap.matches(a.Type()) {
+			continue
+		}
+		// action does not modify incoming action
+		return in, cap.Apply(a)
+	}
+
+	return in, nil
+}
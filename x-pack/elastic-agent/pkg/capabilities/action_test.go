@@ -0,0 +1,88 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package capabilities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+func TestAction(t *testing.T) {
+	tr := &testReporter{}
+	l, _ := logger.New("test", false)
+
+	t.Run("invalid rule", func(t *testing.T) {
+		r := &inputCapability{}
+		cap, err := newActionCapability(l, r, tr)
+		assert.NoError(t, err, "no error expected")
+		assert.Nil(t, cap, "cap should not be created")
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		rd := &ruleDefinitions{
+			Capabilities: []ruler{
+				&actionCapability{Type: "block", Action: "UNENROLL"},
+			},
+		}
+		_, err := newActionsCapability(l, rd, tr)
+		assert.Error(t, err, "error expected, the type of the rule is invalid")
+	})
+
+	t.Run("deny matching action", func(t *testing.T) {
+		rd := &ruleDefinitions{
+			Capabilities: []ruler{
+				&actionCapability{Type: "deny", Action: "unenroll"},
+			},
+		}
+		cap, err := newActionsCapability(l, rd, tr)
+		require.NoError(t, err)
+
+		a := &fleetapi.ActionUnenroll{ActionID: "1", ActionType: "UNENROLL"}
+		out, err := cap.Apply(a)
+		assert.Equal(t, ErrBlocked, err, "should be blocking")
+		assert.Equal(t, a, out)
+
+		other := &fleetapi.ActionPolicyChange{ActionID: "2", ActionType: "POLICY_CHANGE"}
+		_, err = cap.Apply(other)
+		assert.NoError(t, err, "actions of other types should not be blocked")
+	})
+
+	t.Run("first matching rule applies", func(t *testing.T) {
+		rd := &ruleDefinitions{
+			Capabilities: []ruler{
+				&actionCapability{Type: "allow", Action: "POLICY_CHANGE"},
+				&actionCapability{Type: "deny", Action: "*"},
+			},
+		}
+		cap, err := newActionsCapability(l, rd, tr)
+		require.NoError(t, err)
+
+		_, err = cap.Apply(&fleetapi.ActionPolicyChange{ActionID: "1", ActionType: "POLICY_CHANGE"})
+		assert.NoError(t, err, "should not be blocking")
+
+		_, err = cap.Apply(&fleetapi.ActionUnenroll{ActionID: "2", ActionType: "UNENROLL"})
+		assert.Equal(t, ErrBlocked, err, "should be blocking")
+	})
+
+	t.Run("not an action", func(t *testing.T) {
+		rd := &ruleDefinitions{
+			Capabilities: []ruler{
+				&actionCapability{Type: "deny", Action: "*"},
+			},
+		}
+		cap, err := newActionsCapability(l, rd, tr)
+		require.NoError(t, err)
+
+		in := map[string]interface{}{"inputs": []interface{}{}}
+		out, err := cap.Apply(in)
+		assert.NoError(t, err)
+		assert.Equal(t, in, out)
+	})
+}
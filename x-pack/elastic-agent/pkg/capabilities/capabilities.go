@@ -14,6 +14,7 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 )
 
 // Capability provides a way of applying predefined filter to object.
@@ -42,6 +43,7 @@ func Load(capsFile string, log *logger.Logger, sc status.Controller) (Capability
 		newInputsCapability,
 		newOutputsCapability,
 		newUpgradesCapability,
+		newActionsCapability,
 	}
 
 	cm := &capabilitiesManager{
@@ -86,8 +88,11 @@ func Load(capsFile string, log *logger.Logger, sc status.Controller) (Capability
 
 func (mgr *capabilitiesManager) Apply(in interface{}) (interface{}, error) {
 	var err error
-	// reset health on start, child caps will update to fail if needed
-	mgr.reporter.Update(state.Healthy, "", nil)
+	// reset health on start of a configuration, child caps will update to fail if needed. The
+	// actions are checked between two configurations and keep the health of the last one.
+	if _, ok := in.(fleetapi.Action); !ok {
+		mgr.reporter.Update(state.Healthy, "", nil)
+	}
 	for _, cap := range mgr.caps {
 		in, err = cap.Apply(in)
 		if err != nil {
@@ -62,6 +62,12 @@ func (r *capabilitiesList) UnmarshalJSON(p []byte) error {
 				return err
 			}
 			(*r) = append((*r), cap)
+		} else if _, found = mm["action"]; found {
+			cap := &actionCapability{}
+			if err := json.Unmarshal(t, &cap); err != nil {
+				return err
+			}
+			(*r) = append((*r), cap)
 		} else {
 			return fmt.Errorf("unexpected capability type for definition number '%d'", i)
 		}
@@ -103,6 +109,12 @@ func (r *capabilitiesList) UnmarshalYAML(unmarshal func(interface{}) error) erro
 				return err
 			}
 			(*r) = append((*r), cap)
+		} else if _, found = mm["action"]; found {
+			cap := &actionCapability{}
+			if err := yaml.Unmarshal(partialYaml, &cap); err != nil {
+				return err
+			}
+			(*r) = append((*r), cap)
 		} else {
 			return fmt.Errorf("unexpected capability type for definition number '%d'", i)
 		}
@@ -20,10 +20,11 @@ func TestUnmarshal(t *testing.T) {
 		err := json.Unmarshal(jsonDefinitionValid, &rr)
 
 		assert.Nil(t, err, "no error is expected")
-		assert.Equal(t, 3, len(rr.Capabilities))
+		assert.Equal(t, 4, len(rr.Capabilities))
 		assert.Equal(t, "*capabilities.upgradeCapability", reflect.TypeOf(rr.Capabilities[0]).String())
 		assert.Equal(t, "*capabilities.inputCapability", reflect.TypeOf(rr.Capabilities[1]).String())
 		assert.Equal(t, "*capabilities.outputCapability", reflect.TypeOf(rr.Capabilities[2]).String())
+		assert.Equal(t, "*capabilities.actionCapability", reflect.TypeOf(rr.Capabilities[3]).String())
 	})
 
 	t.Run("invalid json", func(t *testing.T) {
@@ -40,10 +41,11 @@ func TestUnmarshal(t *testing.T) {
 		err := yaml.Unmarshal(yamlDefinitionValid, &rr)
 
 		assert.Nil(t, err, "no error is expected")
-		assert.Equal(t, 3, len(rr.Capabilities))
+		assert.Equal(t, 4, len(rr.Capabilities))
 		assert.Equal(t, "*capabilities.upgradeCapability", reflect.TypeOf(rr.Capabilities[0]).String())
 		assert.Equal(t, "*capabilities.inputCapability", reflect.TypeOf(rr.Capabilities[1]).String())
 		assert.Equal(t, "*capabilities.outputCapability", reflect.TypeOf(rr.Capabilities[2]).String())
+		assert.Equal(t, "*capabilities.actionCapability", reflect.TypeOf(rr.Capabilities[3]).String())
 	})
 
 	t.Run("invalid yaml", func(t *testing.T) {
@@ -68,6 +70,10 @@ var jsonDefinitionValid = []byte(`{
 	{
 		"output": "elasticsearch",
 		"rule": "allow"
+	},
+	{
+		"action": "UNENROLL",
+		"rule": "deny"
 	}
 ]
 }`)
@@ -103,6 +109,9 @@ var yamlDefinitionValid = []byte(`capabilities:
 -
   output: "elasticsearch"
   rule: "allow"
+-
+  action: "UNENROLL"
+  rule: "deny"
 `)
 
 var yamlDefinitionInvalid = []byte(`
@@ -127,9 +127,11 @@ func (c *upgradeCapability) Apply(upgradeMap map[string]interface{}) (map[string
 	// if deny switch the logic
 	if c.Type == denyKey {
 		isSupported = !isSupported
-		msg := fmt.Sprintf("upgrade is blocked out due to capability restriction '%s'", c.name())
-		c.log.Errorf(msg)
-		c.reporter.Update(state.Degraded, msg, nil)
+		if !isSupported {
+			msg := fmt.Sprintf("upgrade is blocked out due to capability restriction '%s'", c.name())
+			c.log.Errorf(msg)
+			c.reporter.Update(state.Degraded, msg, nil)
+		}
 	}
 
 	if !isSupported {
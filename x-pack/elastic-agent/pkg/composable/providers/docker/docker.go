@@ -21,6 +21,11 @@ import (
 // ContainerPriority is the priority that container mappings are added to the provider.
 const ContainerPriority = 0
 
+// newWatcher creates the watcher of the containers, replaced in tests.
+var newWatcher = func(log *logger.Logger, cfg *Config) (docker.Watcher, error) {
+	return docker.NewWatcher(log, cfg.Host, cfg.TLS, false)
+}
+
 func init() {
 	composable.Providers.AddDynamicProvider("docker", DynamicProviderBuilder)
 }
@@ -37,7 +42,7 @@ type dynamicProvider struct {
 
 // Run runs the environment context provider.
 func (c *dynamicProvider) Run(comm composable.DynamicProviderComm) error {
-	watcher, err := docker.NewWatcher(c.logger, c.config.Host, c.config.TLS, false)
+	watcher, err := newWatcher(c.logger, c.config)
 	if err != nil {
 		// info only; return nil (do nothing)
 		c.logger.Infof("Docker provider skipped, unable to connect: %s", err)
@@ -60,12 +65,11 @@ func (c *dynamicProvider) Run(comm composable.DynamicProviderComm) error {
 			case <-comm.Done():
 				startListener.Stop()
 				stopListener.Stop()
+				watcher.Stop()
 
-				// Stop all timers before closing the channel
 				for _, stopper := range stoppers {
 					stopper.Stop()
 				}
-				close(stopTrigger)
 				return
 			case event := <-startListener.Events():
 				data, err := generateData(event)
@@ -77,9 +81,11 @@ func (c *dynamicProvider) Run(comm composable.DynamicProviderComm) error {
 					c.logger.Debugf("container %s is restarting, aborting pending stop", data.container.ID)
 					stopper.Stop()
 					delete(stoppers, data.container.ID)
-					return
 				}
-				comm.AddOrUpdate(data.container.ID, ContainerPriority, data.mapping, data.processors)
+				// the mapping of a restarted container is updated, e.g. with its new IP address.
+				if err := comm.AddOrUpdate(data.container.ID, ContainerPriority, data.mapping, data.processors); err != nil {
+					c.logger.Errorf("failed to add the mapping of container %s: %s", data.container.ID, err)
+				}
 			case event := <-stopListener.Events():
 				data, err := generateData(event)
 				if err != nil {
@@ -87,7 +93,10 @@ func (c *dynamicProvider) Run(comm composable.DynamicProviderComm) error {
 					continue
 				}
 				stopper := time.AfterFunc(c.config.CleanupTimeout, func() {
-					stopTrigger <- data
+					select {
+					case stopTrigger <- data:
+					case <-comm.Done():
+					}
 				})
 				stoppers[data.container.ID] = stopper
 			case data := <-stopTrigger:
@@ -126,15 +135,21 @@ func generateData(event bus.Event) (*dockerContainerData, error) {
 		processorLabelMap.Put(common.DeDot(k), v)
 	}
 
+	containerMapping := map[string]interface{}{
+		"id":     container.ID,
+		"name":   container.Name,
+		"image":  container.Image,
+		"labels": labelMap,
+	}
+	if len(container.IPAddresses) > 0 {
+		// ip is the address of the container to collect its metrics, e.g. ${docker.container.ip}:6379.
+		containerMapping["ip"] = container.IPAddresses[0]
+	}
+
 	data := &dockerContainerData{
 		container: container,
 		mapping: map[string]interface{}{
-			"container": map[string]interface{}{
-				"id":     container.ID,
-				"name":   container.Name,
-				"image":  container.Image,
-				"labels": labelMap,
-			},
+			"container": containerMapping,
 		},
 		processors: []map[string]interface{}{
 			{
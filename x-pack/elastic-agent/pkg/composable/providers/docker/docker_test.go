@@ -5,7 +5,9 @@
 package docker
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,6 +15,10 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/common/bus"
 	"github.com/elastic/beats/v7/libbeat/common/docker"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	ctesting "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/composable/testing"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 )
 
 func TestGenerateData(t *testing.T) {
@@ -62,3 +68,77 @@ func TestGenerateData(t *testing.T) {
 	assert.Equal(t, mapping, data.mapping)
 	assert.Equal(t, processors, data.processors)
 }
+
+func TestGenerateDataWithIP(t *testing.T) {
+	container := &docker.Container{
+		ID:          "abc",
+		Name:        "redis",
+		IPAddresses: []string{"172.17.0.2", "172.18.0.2"},
+	}
+
+	data, err := generateData(bus.Event{"container": container})
+	require.NoError(t, err)
+	assert.Equal(t, "172.17.0.2", data.mapping["container"].(map[string]interface{})["ip"])
+}
+
+type testWatcher struct {
+	bus bus.Bus
+}
+
+func (w *testWatcher) Start() error                         { return nil }
+func (w *testWatcher) Stop()                                {}
+func (w *testWatcher) Container(_ string) *docker.Container { return nil }
+func (w *testWatcher) Containers() map[string]*docker.Container {
+	return nil
+}
+func (w *testWatcher) ListenStart() bus.Listener { return w.bus.Subscribe("start") }
+func (w *testWatcher) ListenStop() bus.Listener  { return w.bus.Subscribe("stop") }
+
+func TestRun(t *testing.T) {
+	watcher := &testWatcher{bus: bus.New(logp.L(), "test")}
+	previous := newWatcher
+	newWatcher = func(_ *logger.Logger, _ *Config) (docker.Watcher, error) { return watcher, nil }
+	defer func() { newWatcher = previous }()
+
+	log, err := logger.New("docker", false)
+	require.NoError(t, err)
+	p, err := DynamicProviderBuilder(log, config.MustNewConfigFrom(map[string]interface{}{"cleanup_timeout": "100ms"}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	comm := ctesting.NewDynamicComm(ctx)
+	require.NoError(t, p.Run(comm))
+
+	redis := &docker.Container{ID: "redis", Name: "redis", IPAddresses: []string{"172.17.0.2"}}
+	watcher.bus.Publish(bus.Event{"start": true, "container": redis})
+	require.Eventually(t, func() bool {
+		_, ok := comm.Current("redis")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a container restarting before the cleanup timeout is kept with its new mapping.
+	watcher.bus.Publish(bus.Event{"stop": true, "container": redis})
+	restarted := &docker.Container{ID: "redis", Name: "redis", IPAddresses: []string{"172.17.0.3"}}
+	watcher.bus.Publish(bus.Event{"start": true, "container": restarted})
+	require.Eventually(t, func() bool {
+		state, ok := comm.Current("redis")
+		return ok && state.Mapping["container"].(map[string]interface{})["ip"] == "172.17.0.3"
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	_, ok := comm.Current("redis")
+	assert.True(t, ok)
+
+	// the containers are still watched after a restart.
+	nginx := &docker.Container{ID: "nginx", Name: "nginx"}
+	watcher.bus.Publish(bus.Event{"start": true, "container": nginx})
+	require.Eventually(t, func() bool {
+		_, ok := comm.Current("nginx")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	watcher.bus.Publish(bus.Event{"stop": true, "container": nginx})
+	require.Eventually(t, func() bool {
+		return comm.Deleted("nginx")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,11 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package file
+
+// Config for the file provider.
+type Config struct {
+	// Paths are the glob patterns of the files which can be read, any file can be read when empty.
+	Paths []string `config:"paths"`
+}
@@ -0,0 +1,91 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package file
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/composable"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	corecomp "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/composable"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+// prefix of the variables read by the provider, e.g. ${file:/run/secrets/password}.
+const prefix = "file:"
+
+var _ corecomp.FetchContextProvider = (*contextProvider)(nil)
+
+func init() {
+	composable.Providers.AddContextProvider("file", ContextProviderBuilder)
+}
+
+type contextProvider struct {
+	logger *logger.Logger
+	config *Config
+}
+
+// ContextProviderBuilder builds the context provider.
+func ContextProviderBuilder(logger *logger.Logger, c *config.Config) (corecomp.ContextProvider, error) {
+	var cfg Config
+	if c == nil {
+		c = config.New()
+	}
+	err := c.Unpack(&cfg)
+	if err != nil {
+		return nil, errors.New(err, "failed to unpack configuration")
+	}
+	for _, pattern := range cfg.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, errors.New(err, "invalid path pattern "+pattern, errors.TypeConfig)
+		}
+	}
+	return &contextProvider{logger, &cfg}, nil
+}
+
+// Fetch returns the content of the file, without the trailing newline.
+func (p *contextProvider) Fetch(key string) (string, bool) {
+	// key = "file:/run/secrets/password"
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	path := filepath.Clean(strings.TrimPrefix(key, prefix))
+	if !filepath.IsAbs(path) {
+		p.logger.Debugf("not valid file key: %v, the path of the file must be absolute", key)
+		return "", false
+	}
+	if !p.allowed(path) {
+		p.logger.Errorf("Could not read %v, the path does not match the paths of the file provider", path)
+		return "", false
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		p.logger.Errorf("Could not read %v: %v", path, err)
+		return "", false
+	}
+	value := strings.TrimSuffix(string(content), "\n")
+	return strings.TrimSuffix(value, "\r"), true
+}
+
+// Run runs the file context provider, the files are read when the variables are rendered.
+func (*contextProvider) Run(_ corecomp.ContextProviderComm) error {
+	return nil
+}
+
+func (p *contextProvider) allowed(path string) bool {
+	if len(p.config.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range p.config.Paths {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	corecomp "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/composable"
+)
+
+func TestContextProvider_Fetch(t *testing.T) {
+	dir := t.TempDir()
+	password := filepath.Join(dir, "secrets", "password")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("my-token"), 0600))
+	require.NoError(t, mkdirAndWrite(password, "changeme\n"))
+
+	logger := logp.NewLogger("test_file")
+	p, err := ContextProviderBuilder(logger, nil)
+	require.NoError(t, err)
+	fp := p.(corecomp.FetchContextProvider)
+	require.NoError(t, fp.Run(nil))
+
+	val, found := fp.Fetch("file:" + password)
+	assert.True(t, found)
+	assert.Equal(t, "changeme", val)
+
+	val, found = fp.Fetch("file:" + filepath.Join(dir, "token"))
+	assert.True(t, found)
+	assert.Equal(t, "my-token", val)
+
+	_, found = fp.Fetch("file:" + filepath.Join(dir, "missing"))
+	assert.False(t, found)
+	_, found = fp.Fetch("file:secrets/password")
+	assert.False(t, found)
+	_, found = fp.Fetch("env:" + password)
+	assert.False(t, found)
+
+	t.Run("paths", func(t *testing.T) {
+		cfg, err := config.NewConfigFrom(map[string]interface{}{
+			"paths": []string{filepath.Join(dir, "secrets", "*")},
+		})
+		require.NoError(t, err)
+		p, err := ContextProviderBuilder(logger, cfg)
+		require.NoError(t, err)
+		fp := p.(corecomp.FetchContextProvider)
+
+		val, found := fp.Fetch("file:" + password)
+		assert.True(t, found)
+		assert.Equal(t, "changeme", val)
+
+		_, found = fp.Fetch("file:" + filepath.Join(dir, "token"))
+		assert.False(t, found)
+		_, found = fp.Fetch("file:" + filepath.Join(dir, "secrets", "..", "token"))
+		assert.False(t, found)
+	})
+
+	t.Run("invalid paths", func(t *testing.T) {
+		cfg, err := config.NewConfigFrom(map[string]interface{}{"paths": []string{"[invalid"}})
+		require.NoError(t, err)
+		_, err = ContextProviderBuilder(logger, cfg)
+		assert.Error(t, err)
+	})
+}
+
+func mkdirAndWrite(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(content), 0600)
+}
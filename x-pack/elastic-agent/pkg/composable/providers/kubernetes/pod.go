@@ -165,17 +165,26 @@ func (p *pod) emitContainers(pod *kubernetes.Pod, namespaceAnnotations common.Ma
 func (p *pod) emitStopped(pod *kubernetes.Pod) {
 	p.comm.Remove(string(pod.GetUID()))
 
-	for _, c := range pod.Spec.Containers {
-		// ID is the combination of pod UID + container name
-		eventID := fmt.Sprintf("%s.%s", pod.GetObjectMeta().GetUID(), c.Name)
-		p.comm.Remove(eventID)
+	// the ports of the containers cannot change, the IDs are the ones of the emitted containers.
+	for _, c := range kubernetes.GetContainersInPod(pod) {
+		if len(c.Spec.Ports) == 0 {
+			p.comm.Remove(containerEventID(pod, c.Spec.Name, nil))
+			continue
+		}
+		for i := range c.Spec.Ports {
+			p.comm.Remove(containerEventID(pod, c.Spec.Name, &c.Spec.Ports[i]))
+		}
 	}
+}
 
-	for _, c := range pod.Spec.InitContainers {
-		// ID is the combination of pod UID + container name
-		eventID := fmt.Sprintf("%s.%s", pod.GetObjectMeta().GetUID(), c.Name)
-		p.comm.Remove(eventID)
+// containerEventID returns the ID of the mapping of a container, the combination of the pod UID
+// and the container name. Each port of the container has its own mapping, the port is appended
+// to the ID.
+func containerEventID(pod *kubernetes.Pod, name string, port *kubernetes.ContainerPort) string {
+	if port == nil {
+		return fmt.Sprintf("%s.%s", pod.GetObjectMeta().GetUID(), name)
 	}
+	return fmt.Sprintf("%s.%s.%d", pod.GetObjectMeta().GetUID(), name, port.ContainerPort)
 }
 
 // OnAdd ensures processing of pod objects that are newly added
@@ -284,9 +293,6 @@ func generateContainerData(
 			continue
 		}
 
-		// ID is the combination of pod UID + container name
-		eventID := fmt.Sprintf("%s.%s", pod.GetObjectMeta().GetUID(), c.Spec.Name)
-
 		meta := kubeMetaGen.Generate(pod, metadata.WithFields("container.name", c.Spec.Name))
 		kubemetaMap, err := meta.GetValue("kubernetes")
 		if err != nil {
@@ -341,15 +347,18 @@ func generateContainerData(
 			"runtime": c.Runtime,
 		}
 		if len(c.Spec.Ports) > 0 {
-			for _, port := range c.Spec.Ports {
-				containerMeta.Put("port", fmt.Sprintf("%v", port.ContainerPort))
-				containerMeta.Put("port_name", port.Name)
-				k8sMapping["container"] = containerMeta
-				comm.AddOrUpdate(eventID, ContainerPriority, k8sMapping, processors)
+			// one mapping per port, inputs can use the port of each of them
+			for i, port := range c.Spec.Ports {
+				portMeta := containerMeta.Clone()
+				portMeta.Put("port", fmt.Sprintf("%v", port.ContainerPort))
+				portMeta.Put("port_name", port.Name)
+				portMapping := map[string]interface{}(common.MapStr(k8sMapping).Clone())
+				portMapping["container"] = portMeta
+				comm.AddOrUpdate(containerEventID(pod, c.Spec.Name, &c.Spec.Ports[i]), ContainerPriority, portMapping, processors)
 			}
 		} else {
 			k8sMapping["container"] = containerMeta
-			comm.AddOrUpdate(eventID, ContainerPriority, k8sMapping, processors)
+			comm.AddOrUpdate(containerEventID(pod, c.Spec.Name, nil), ContainerPriority, k8sMapping, processors)
 		}
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,6 +19,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/common/kubernetes"
 	"github.com/elastic/beats/v7/libbeat/common/kubernetes/metadata"
+	ctesting "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/composable/testing"
 )
 
 func TestGeneratePodData(t *testing.T) {
@@ -200,7 +202,7 @@ func TestGenerateContainerPodData(t *testing.T) {
 				"name": "testpod",
 				"uid":  "005f3b90-4b9d-12f8-acf0-31020a840133"}},
 	}
-	cuid := fmt.Sprintf("%s.%s", pod.GetObjectMeta().GetUID(), "nginx")
+	cuid := fmt.Sprintf("%s.%s.80", pod.GetObjectMeta().GetUID(), "nginx")
 	data := <-providerDataChan
 	assert.Equal(t, cuid, data.uid)
 	assert.Equal(t, mapping, data.mapping)
@@ -327,6 +329,65 @@ func TestEphemeralContainers(t *testing.T) {
 }
 
 // MockDynamicComm is used in tests.
+func TestContainerPortsAndStop(t *testing.T) {
+	uid := "005f3b90-4b9d-12f8-acf0-31020a840133"
+	k8sPod := &kubernetes.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testpod",
+			UID:       types.UID(uid),
+			Namespace: "testns",
+		},
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		Spec: kubernetes.PodSpec{
+			NodeName: "testnode",
+			Containers: []kubernetes.Container{
+				{
+					Name:  "nginx",
+					Image: "nginx:1.120",
+					Ports: []kubernetes.ContainerPort{
+						{Name: "http", Protocol: v1.ProtocolTCP, ContainerPort: 80},
+						{Name: "https", Protocol: v1.ProtocolTCP, ContainerPort: 443},
+					},
+				},
+			},
+			EphemeralContainers: []v1.EphemeralContainer{
+				{
+					EphemeralContainerCommon: v1.EphemeralContainerCommon{
+						Image: "busybox",
+						Name:  "debug",
+					},
+				},
+			},
+		},
+		Status: kubernetes.PodStatus{
+			PodIP: "127.0.0.5",
+			ContainerStatuses: []kubernetes.PodContainerStatus{
+				{Name: "nginx", Ready: true, ContainerID: "crio://asdfghdeadbeef"},
+			},
+			EphemeralContainerStatuses: []kubernetes.PodContainerStatus{
+				{Name: "debug", Ready: true, ContainerID: "crio://abcdefdeadbeef"},
+			},
+		},
+	}
+
+	comm := ctesting.NewDynamicComm(context.TODO())
+	generateContainerData(comm, k8sPod, &Config{}, &podMeta{}, nil)
+
+	assert.ElementsMatch(t, []string{uid + ".nginx.80", uid + ".nginx.443", uid + ".debug"}, comm.CurrentIDs())
+	for id, port := range map[string]string{uid + ".nginx.80": "80", uid + ".nginx.443": "443"} {
+		state, ok := comm.Current(id)
+		require.True(t, ok)
+		assert.Equal(t, port, state.Mapping["container"].(map[string]interface{})["port"])
+	}
+
+	p := &pod{comm: comm}
+	p.emitStopped(k8sPod)
+	assert.Empty(t, comm.CurrentIDs())
+}
+
 type MockDynamicComm struct {
 	context.Context
 	providerDataChan chan providerData
@@ -4,7 +4,14 @@
 
 package kubernetesleaderelection
 
-import "github.com/elastic/beats/v7/libbeat/common/kubernetes"
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection"
+
+	"github.com/elastic/beats/v7/libbeat/common/kubernetes"
+)
 
 // Config for kubernetes_leaderelection provider
 type Config struct {
@@ -12,9 +19,36 @@ type Config struct {
 	KubeClientOptions kubernetes.KubeClientOptions `config:"kube_client_options"`
 	// Name of the leaderelection lease
 	LeaderLease string `config:"leader_lease"`
+	// LeaseDuration is the time the other agents wait before taking over the lease of a leader
+	// which stopped renewing it.
+	LeaseDuration time.Duration `config:"lease_duration"`
+	// RenewDeadline is the time the leader retries to renew the lease before giving up the leadership.
+	RenewDeadline time.Duration `config:"renew_deadline"`
+	// RetryPeriod is the time between two attempts to acquire or renew the lease.
+	RetryPeriod time.Duration `config:"retry_period"`
 }
 
 // InitDefaults initializes the default values for the config.
 func (c *Config) InitDefaults() {
 	c.LeaderLease = "elastic-agent-cluster-leader"
+	c.LeaseDuration = 15 * time.Second
+	c.RenewDeadline = 10 * time.Second
+	c.RetryPeriod = 2 * time.Second
+}
+
+// Validate validates the timings of the leader election.
+func (c *Config) Validate() error {
+	if c.LeaderLease == "" {
+		return fmt.Errorf("leader_lease cannot be empty")
+	}
+	if c.RetryPeriod <= 0 {
+		return fmt.Errorf("retry_period must be positive, got %s", c.RetryPeriod)
+	}
+	if float64(c.RenewDeadline) <= leaderelection.JitterFactor*float64(c.RetryPeriod) {
+		return fmt.Errorf("renew_deadline %s must be greater than %v times retry_period %s", c.RenewDeadline, leaderelection.JitterFactor, c.RetryPeriod)
+	}
+	if c.LeaseDuration <= c.RenewDeadline {
+		return fmt.Errorf("lease_duration %s must be greater than renew_deadline %s", c.LeaseDuration, c.RenewDeadline)
+	}
+	return nil
 }
@@ -7,9 +7,9 @@ package kubernetesleaderelection
 import (
 	"context"
 	"os"
-	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 
@@ -22,6 +22,8 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 )
 
+var getK8sClientFunc = getK8sClient
+
 func init() {
 	composable.Providers.AddContextProvider("kubernetes_leaderelection", ContextProviderBuilder)
 }
@@ -32,6 +34,8 @@ type contextProvider struct {
 	comm                 corecomp.ContextProviderComm
 	leaderElection       *leaderelection.LeaderElectionConfig
 	cancelLeaderElection context.CancelFunc
+	// done is closed once the leader election stopped and released the lease.
+	done chan struct{}
 }
 
 // ContextProviderBuilder builds the provider.
@@ -44,29 +48,26 @@ func ContextProviderBuilder(logger *logger.Logger, c *config.Config) (corecomp.C
 	if err != nil {
 		return nil, errors.New(err, "failed to unpack configuration")
 	}
-	return &contextProvider{logger, &cfg, nil, nil, nil}, nil
+	return &contextProvider{logger: logger, config: &cfg}, nil
 }
 
 // Run runs the leaderelection provider.
+//
+// The agent is not the leader until it acquires the lease, the inputs conditioned on
+// ${kubernetes_leaderelection.leader} are only activated on the agent holding the lease. An agent
+// losing the lease campaigns again, so another agent takes over when the leader goes away.
 func (p *contextProvider) Run(comm corecomp.ContextProviderComm) error {
-	client, err := kubernetes.GetKubernetesClient(p.config.KubeConfig, p.config.KubeClientOptions)
+	client, err := getK8sClientFunc(p.config.KubeConfig, p.config.KubeClientOptions)
 	if err != nil {
 		// info only; return nil (do nothing)
 		p.logger.Debugf("Kubernetes leaderelection provider skipped, unable to connect: %s", err)
 		return nil
 	}
 
-	agentInfo, err := info.NewAgentInfo(false)
+	id, err := identity()
 	if err != nil {
 		return err
 	}
-	var id string
-	podName, found := os.LookupEnv("POD_NAME")
-	if found {
-		id = "elastic-agent-leader-" + podName
-	} else {
-		id = "elastic-agent-leader-" + agentInfo.AgentID()
-	}
 
 	ns, err := kubernetes.InClusterNamespace()
 	if err != nil {
@@ -76,7 +77,6 @@ func (p *contextProvider) Run(comm corecomp.ContextProviderComm) error {
 		Name:      p.config.LeaderLease,
 		Namespace: ns,
 	}
-	metaUID := lease.GetObjectMeta().GetUID()
 	p.leaderElection = &leaderelection.LeaderElectionConfig{
 		Lock: &resourcelock.LeaseLock{
 			LeaseMeta: lease,
@@ -86,39 +86,50 @@ func (p *contextProvider) Run(comm corecomp.ContextProviderComm) error {
 			},
 		},
 		ReleaseOnCancel: true,
-		LeaseDuration:   15 * time.Second,
-		RenewDeadline:   10 * time.Second,
-		RetryPeriod:     2 * time.Second,
+		LeaseDuration:   p.config.LeaseDuration,
+		RenewDeadline:   p.config.RenewDeadline,
+		RetryPeriod:     p.config.RetryPeriod,
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				p.logger.Debugf("leader election lock GAINED, id %v", id)
-				p.startLeading(string(metaUID))
+				p.startLeading()
 			},
 			OnStoppedLeading: func() {
 				p.logger.Debugf("leader election lock LOST, id %v", id)
-				p.stopLeading(string(metaUID))
+				p.stopLeading()
 			},
 		},
+		Name: p.config.LeaderLease,
 	}
-	ctx, cancel := context.WithCancel(context.TODO())
-	p.cancelLeaderElection = cancel
+
+	le, err := leaderelection.NewLeaderElector(*p.leaderElection)
+	if err != nil {
+		return errors.New(err, "failed to create the leader elector", errors.TypeConfig)
+	}
+
 	p.comm = comm
-	p.startLeaderElector(ctx)
+	p.stopLeading()
+
+	// the lease is released when the agent stops, so another agent takes over right away.
+	ctx, cancel := context.WithCancel(comm)
+	p.cancelLeaderElection = cancel
+	p.done = make(chan struct{})
+	p.logger.Debugf("Starting Leader Elector")
+	go p.campaign(ctx, le)
 
 	return nil
 }
 
-// startLeaderElector starts a Leader Elector in the background with the provided config
-func (p *contextProvider) startLeaderElector(ctx context.Context) {
-	le, err := leaderelection.NewLeaderElector(*p.leaderElection)
-	if err != nil {
-		p.logger.Errorf("error while creating Leader Elector: %v", err)
+// campaign runs the leader election until the provider is stopped, the leader elector returns
+// each time the leadership is lost.
+func (p *contextProvider) campaign(ctx context.Context, le *leaderelection.LeaderElector) {
+	defer close(p.done)
+	for ctx.Err() == nil {
+		le.Run(ctx)
 	}
-	p.logger.Debugf("Starting Leader Elector")
-	go le.Run(ctx)
 }
 
-func (p *contextProvider) startLeading(metaUID string) {
+func (p *contextProvider) startLeading() {
 	mapping := map[string]interface{}{
 		"leader": true,
 	}
@@ -129,7 +140,7 @@ func (p *contextProvider) startLeading(metaUID string) {
 	}
 }
 
-func (p *contextProvider) stopLeading(metaUID string) {
+func (p *contextProvider) stopLeading() {
 	mapping := map[string]interface{}{
 		"leader": false,
 	}
@@ -140,9 +151,29 @@ func (p *contextProvider) stopLeading(metaUID string) {
 	}
 }
 
-// Stop signals the stop channel to force the leader election loop routine to stop.
+// Stop signals the stop channel to force the leader election loop routine to stop, it returns
+// once the lease is released.
 func (p *contextProvider) Stop() {
 	if p.cancelLeaderElection != nil {
 		p.cancelLeaderElection()
+		<-p.done
+	}
+}
+
+// identity returns the identity of the agent in the lease, the name of the pod when the agent
+// runs in a pod.
+func identity() (string, error) {
+	if podName, found := os.LookupEnv("POD_NAME"); found {
+		return "elastic-agent-leader-" + podName, nil
 	}
+
+	agentInfo, err := info.NewAgentInfo(false)
+	if err != nil {
+		return "", err
+	}
+	return "elastic-agent-leader-" + agentInfo.AgentID(), nil
+}
+
+func getK8sClient(kubeconfig string, opt kubernetes.KubeClientOptions) (k8sclient.Interface, error) {
+	return kubernetes.GetKubernetesClient(kubeconfig, opt)
 }
@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package kubernetesleaderelection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclient "k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/beats/v7/libbeat/common/kubernetes"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	ctesting "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/composable/testing"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+)
+
+func TestLeaderElection_Failover(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	getK8sClientFunc = func(kubeconfig string, opt kubernetes.KubeClientOptions) (k8sclient.Interface, error) {
+		return client, nil
+	}
+	defer func() { getK8sClientFunc = getK8sClient }()
+
+	cfg, err := config.NewConfigFrom(map[string]interface{}{
+		"lease_duration": "1s",
+		"renew_deadline": "500ms",
+		"retry_period":   "100ms",
+	})
+	require.NoError(t, err)
+
+	run := func(pod string) (*contextProvider, *ctesting.ContextComm) {
+		t.Setenv("POD_NAME", pod)
+		p, err := ContextProviderBuilder(logp.NewLogger("test_leaderelection"), cfg)
+		require.NoError(t, err)
+
+		comm := ctesting.NewContextComm(context.Background())
+		require.NoError(t, p.Run(comm))
+		assert.Equal(t, map[string]interface{}{"leader": false}, comm.Current())
+		return p.(*contextProvider), comm
+	}
+	isLeader := func(comm *ctesting.ContextComm) func() bool {
+		return func() bool { return comm.Current()["leader"] == true }
+	}
+
+	first, firstComm := run("first")
+	defer first.Stop()
+	require.Eventually(t, isLeader(firstComm), 5*time.Second, 50*time.Millisecond)
+
+	second, secondComm := run("second")
+	defer second.Stop()
+	time.Sleep(300 * time.Millisecond)
+	assert.False(t, isLeader(secondComm)())
+
+	// the lease is released by the stopped leader and taken over by the other agent.
+	first.Stop()
+	assert.False(t, isLeader(firstComm)())
+	require.Eventually(t, isLeader(secondComm), 5*time.Second, 50*time.Millisecond)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg, err := config.NewConfigFrom(map[string]interface{}{
+		"lease_duration": "1s",
+		"renew_deadline": "2s",
+	})
+	require.NoError(t, err)
+
+	_, err = ContextProviderBuilder(logp.NewLogger("test_leaderelection"), cfg)
+	assert.Error(t, err)
+}
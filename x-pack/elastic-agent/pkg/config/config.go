@@ -14,6 +14,8 @@ import (
 
 	"github.com/elastic/go-ucfg"
 	"github.com/elastic/go-ucfg/cfgutil"
+
+	"github.com/elastic/beats/v7/libbeat/keystore"
 )
 
 // options hold the specified options
@@ -41,6 +43,18 @@ var DefaultOptions = []interface{}{
 	VarSkipKeys("inputs"),
 }
 
+// SetKeystore makes the variables of the configurations resolved from the secrets of the keystore,
+// the keystore takes precedence over the environment like in the beats.
+func SetKeystore(store keystore.Keystore) {
+	DefaultOptions = []interface{}{
+		ucfg.PathSep("."),
+		ucfg.Resolve(keystore.ResolverWrap(store)),
+		ucfg.ResolveEnv,
+		ucfg.VarExp,
+		VarSkipKeys("inputs"),
+	}
+}
+
 // Config custom type over a ucfg.Config to add new methods on the object.
 type Config ucfg.Config
 
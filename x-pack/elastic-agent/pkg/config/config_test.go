@@ -14,6 +14,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/beats/v7/libbeat/keystore"
 )
 
 func TestConfig(t *testing.T) {
@@ -59,6 +61,59 @@ func TestInputsResolveNOOP(t *testing.T) {
 	assert.Equal(t, contents, cfgData)
 }
 
+func TestKeystore(t *testing.T) {
+	defaultOptions := DefaultOptions
+	defer func() {
+		DefaultOptions = defaultOptions
+	}()
+
+	tmp := t.TempDir()
+	store, err := keystore.NewFileKeystore(filepath.Join(tmp, "elastic-agent.keystore"))
+	require.NoError(t, err)
+	writable, err := keystore.AsWritableKeystore(store)
+	require.NoError(t, err)
+	require.NoError(t, writable.Store("ES_PASSWORD", []byte("secret")))
+	require.NoError(t, writable.Save())
+	SetKeystore(store)
+
+	cfgPath := filepath.Join(tmp, "config.yml")
+	dumpToYAML(t, cfgPath, map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{
+				"type":     "elasticsearch",
+				"password": "${ES_PASSWORD}",
+			},
+		},
+		"inputs": []interface{}{
+			map[string]interface{}{
+				"type":     "logfile",
+				"password": "${ES_PASSWORD}",
+			},
+		},
+	})
+
+	cfg, err := LoadFile(cfgPath)
+	require.NoError(t, err)
+	cfgData, err := cfg.ToMapStr()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"default": map[string]interface{}{
+				"type":     "elasticsearch",
+				"password": "secret",
+			},
+		},
+		// the inputs are resolved when they are rendered.
+		"inputs": []interface{}{
+			map[string]interface{}{
+				"type":     "logfile",
+				"password": "${ES_PASSWORD}",
+			},
+		},
+	}, cfgData)
+}
+
 func testToMapStr(t *testing.T) {
 	m := map[string]interface{}{
 		"hello": map[string]interface{}{
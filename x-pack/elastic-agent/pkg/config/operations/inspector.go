@@ -6,6 +6,7 @@ package operations
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/info"
@@ -57,6 +58,10 @@ func LoadFullAgentConfig(cfgPath string, failOnFleetMissing bool) (*config.Confi
 }
 
 func loadConfig(configPath string) (*config.Config, error) {
+	if _, err := LoadKeystore(); err != nil {
+		return nil, err
+	}
+
 	rawConfig, err := config.LoadFile(configPath)
 	if err != nil {
 		return nil, err
@@ -64,7 +69,7 @@ func loadConfig(configPath string) (*config.Config, error) {
 
 	path := paths.AgentConfigFile()
 
-	store := storage.NewDiskStore(path)
+	store := storage.NewFleetConfigStore(path)
 	reader, err := store.Load()
 	if err != nil {
 		return nil, errors.New(err, "could not initialize config store",
@@ -96,7 +101,12 @@ func loadFleetConfig(cfg *config.Config) (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	stateStore, err := store.NewStateStoreWithMigration(log, paths.AgentActionStoreFile(), paths.AgentStateStoreFile())
+	stateKey, legacySecret, err := storage.LoadOrCreateStateKey(paths.AgentSecretFile(), filepath.Join(paths.Config(), "keystore"))
+	if err != nil {
+		return nil, err
+	}
+
+	stateStore, err := store.NewStateStoreWithMigration(log, paths.AgentActionStoreFile(), paths.AgentStateStoreYmlFile(), paths.AgentStateStoreFile(), stateKey, legacySecret)
 	if err != nil {
 		return nil, err
 	}
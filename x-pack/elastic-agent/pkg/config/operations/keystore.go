@@ -0,0 +1,26 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package operations
+
+import (
+	"github.com/elastic/beats/v7/libbeat/keystore"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+)
+
+// LoadKeystore loads the keystore of the agent and resolves the variables of the configurations
+// loaded afterwards from its secrets.
+func LoadKeystore() (keystore.Keystore, error) {
+	path := paths.AgentKeystoreFile()
+	store, err := keystore.NewFileKeystore(path)
+	if err != nil {
+		return nil, errors.New(err, "could not load the keystore",
+			errors.TypeFilesystem,
+			errors.M(errors.MetaKeyPath, path))
+	}
+	config.SetKeystore(store)
+	return store, nil
+}
@@ -17,6 +17,21 @@ type factory func(<-chan struct{}) Backoff
 func TestBackoff(t *testing.T) {
 	t.Run("test close channel", testCloseChannel)
 	t.Run("test unblock after some time", testUnblockAfterInit)
+	t.Run("test equal jitter duration is restored", testEqualJitterSetDuration)
+}
+
+func testEqualJitterSetDuration(t *testing.T) {
+	b := NewEqualJitterBackoff(nil, time.Second, time.Minute).(*EqualJitterBackoff)
+	assert.Equal(t, 2*time.Second, b.Duration())
+
+	b.SetDuration(16 * time.Second)
+	assert.Equal(t, 16*time.Second, b.Duration())
+
+	// the restored duration is kept within the bounds of the backoff.
+	b.SetDuration(time.Millisecond)
+	assert.Equal(t, 2*time.Second, b.Duration())
+	b.SetDuration(time.Hour)
+	assert.Equal(t, time.Minute, b.Duration())
 }
 
 func testCloseChannel(t *testing.T) {
@@ -38,6 +38,24 @@ func (b *EqualJitterBackoff) Reset() {
 	b.duration = b.init * 2
 }
 
+// Duration returns the duration of the next wait, the wait lasts between half of the duration and
+// the duration.
+func (b *EqualJitterBackoff) Duration() time.Duration {
+	return b.duration
+}
+
+// SetDuration sets the duration of the next wait, it is kept between the duration of the first
+// wait and the maximum duration.
+func (b *EqualJitterBackoff) SetDuration(d time.Duration) {
+	if d < b.init*2 {
+		d = b.init * 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	b.duration = d
+}
+
 // Wait block until either the timer is completed or channel is done.
 func (b *EqualJitterBackoff) Wait() bool {
 	// Make sure we have always some minimal back off and jitter.
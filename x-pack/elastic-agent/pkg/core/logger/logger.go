@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"go.elastic.co/ecszap"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/yaml.v2"
 
@@ -24,11 +25,18 @@ import (
 
 const agentName = "elastic-agent"
 
+// agentDataset is the ECS event.dataset of the logs of the agent, the logs can be ingested
+// without a parsing pipeline.
+const agentDataset = "elastic_agent"
+
 const iso8601Format = "2006-01-02T15:04:05.000Z0700"
 
 // DefaultLogLevel used in agent and its processes.
 const DefaultLogLevel = logp.InfoLevel
 
+// internalLevel is the level of the internal file output, it follows the level of the configured output.
+var internalLevel = zap.NewAtomicLevelAt(DefaultLogLevel.ZapLevel())
+
 // Logger alias ecslog.Logger with Logger.
 type Logger = logp.Logger
 
@@ -55,12 +63,21 @@ func NewFromConfig(name string, cfg *Config, logInternal bool) (*Logger, error)
 	return new(name, cfg, logInternal)
 }
 
+// SetLevel changes the level of the agent logging without restarting the agent, the loggers
+// already created are affected too.
+func SetLevel(level logp.Level) {
+	internalLevel.SetLevel(level.ZapLevel())
+	logp.SetLevel(level)
+}
+
 func new(name string, cfg *Config, logInternal bool) (*Logger, error) {
 	commonCfg, err := toCommonConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	internalLevel.SetLevel(cfg.Level.ZapLevel())
+
 	var outputs []zapcore.Core
 	if logInternal {
 		internal, err := makeInternalFileOutput(cfg)
@@ -74,7 +91,7 @@ func new(name string, cfg *Config, logInternal bool) (*Logger, error) {
 	if err := configure.LoggingWithOutputs("", commonCfg, outputs...); err != nil {
 		return nil, fmt.Errorf("error initializing logging: %v", err)
 	}
-	return logp.NewLogger(name), nil
+	return logp.NewLogger(name, zap.Fields(zap.String("event.dataset", agentDataset))), nil
 }
 
 func toCommonConfig(cfg *Config) (*common.Config, error) {
@@ -131,7 +148,7 @@ func makeInternalFileOutput(cfg *Config) (zapcore.Core, error) {
 	encoderConfig := ecszap.ECSCompatibleEncoderConfig(logp.JSONEncoderConfig())
 	encoderConfig.EncodeTime = utcTimestampEncode
 	encoder := zapcore.NewJSONEncoder(encoderConfig)
-	return ecszap.WrapCore(zapcore.NewCore(encoder, rotator, cfg.Level.ZapLevel())), nil
+	return ecszap.WrapCore(zapcore.NewCore(encoder, rotator, internalLevel)), nil
 }
 
 // utcTimestampEncode is a zapcore.TimeEncoder that formats time.Time in ISO-8601 in UTC.
@@ -4,8 +4,48 @@
 
 package logger
 
-import "testing"
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
 
 func TestLogger(t *testing.T) {
 	t.Skip("only checking if test works")
 }
+
+func TestECSFields(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultLoggingConfig()
+	cfg.Files.Path = dir
+
+	log, err := NewFromConfig("test", cfg, false)
+	require.NoError(t, err)
+	log.Errorw("request failed", zap.Error(errors.New("connection refused")))
+	require.NoError(t, log.Sync())
+
+	files, err := filepath.Glob(filepath.Join(dir, agentName+"-*.ndjson"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+
+	assert.Equal(t, agentDataset, line["event.dataset"])
+	assert.Equal(t, "error", line["log.level"])
+	assert.Equal(t, "request failed", line["message"])
+	assert.Equal(t, map[string]interface{}{"message": "connection refused"}, line["error"])
+}
@@ -59,6 +59,9 @@ func (b *Monitor) Reload(rawConfig *config.Config) error {
 		if cfg.Settings.MonitoringConfig.Pprof == nil {
 			cfg.Settings.MonitoringConfig.Pprof = b.config.Pprof
 		}
+		if cfg.Settings.MonitoringConfig.StackMonitoring == nil {
+			cfg.Settings.MonitoringConfig.StackMonitoring = b.config.StackMonitoring
+		}
 		b.config = cfg.Settings.MonitoringConfig
 		logMetrics := true
 		if cfg.Settings.LoggingConfig != nil {
@@ -156,6 +159,8 @@ func (b *Monitor) EnrichArgs(spec program.Spec, pipelineID string, args []string
 		}
 	}
 
+	appendix = append(appendix, b.stackMonitoringArgs(spec, pipelineID, isSidecar)...)
+
 	return append(args, appendix...)
 }
 
@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package beats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+)
+
+// stackMonitoringSecrets are the settings of the monitoring cluster passed to the beats through
+// environment variables, so they do not show in the arguments of the processes.
+var stackMonitoringSecrets = map[string]string{
+	"password": "ELASTIC_AGENT_STACK_MONITORING_PASSWORD",
+	"api_key":  "ELASTIC_AGENT_STACK_MONITORING_API_KEY",
+}
+
+// stackMonitoringArgs returns the arguments enabling the beats to ship their metrics to the
+// monitoring cluster.
+func (b *Monitor) stackMonitoringArgs(spec program.Spec, pipelineID string, isSidecar bool) []string {
+	if !b.shipsToStackMonitoring(spec, pipelineID, isSidecar) {
+		return nil
+	}
+
+	cfg := b.config.StackMonitoring
+	args := []string{"-E", "monitoring.enabled=true"}
+	if cfg.ClusterUUID != "" {
+		args = append(args, "-E", "monitoring.cluster_uuid="+cfg.ClusterUUID)
+	}
+
+	settings := common.MapStr(cfg.Elasticsearch).Flatten()
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := settings[key]
+		if env, ok := stackMonitoringSecrets[key]; ok {
+			value = fmt.Sprintf("${%s}", env)
+		}
+		args = append(args, "-E", fmt.Sprintf("monitoring.elasticsearch.%s=%s", key, flagValue(value)))
+	}
+	return args
+}
+
+// EnrichEnv enriches the environment of the application with the secrets of the monitoring
+// cluster.
+func (b *Monitor) EnrichEnv(spec program.Spec, pipelineID string, env []string, isSidecar bool) []string {
+	if !b.shipsToStackMonitoring(spec, pipelineID, isSidecar) {
+		return env
+	}
+
+	for key, name := range stackMonitoringSecrets {
+		if value, ok := b.config.StackMonitoring.Elasticsearch[key]; ok {
+			env = append(env, fmt.Sprintf("%s=%v", name, value))
+		}
+	}
+	return env
+}
+
+// StackMonitoringLogsOutput returns the elasticsearch output of the monitoring cluster when the
+// logs collected by the monitoring are shipped to it.
+func (b *Monitor) StackMonitoringLogsOutput() (map[string]interface{}, bool) {
+	cfg := b.config.StackMonitoring
+	if !cfg.IsEnabled() || !cfg.Logs {
+		return nil, false
+	}
+	return cfg.Elasticsearch, true
+}
+
+// shipsToStackMonitoring returns true for the beats shipping their metrics to the monitoring
+// cluster, the beats monitoring the agent are not part of them.
+func (b *Monitor) shipsToStackMonitoring(spec program.Spec, pipelineID string, isSidecar bool) bool {
+	return b.config.StackMonitoring.IsEnabled() && !isSidecar && b.generateMonitoringEndpoint(spec, pipelineID) != ""
+}
+
+// flagValue formats a setting as the value of a -E flag.
+func flagValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
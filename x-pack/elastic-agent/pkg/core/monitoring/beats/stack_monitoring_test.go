@@ -0,0 +1,68 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package beats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
+	monitoringConfig "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/config"
+)
+
+func TestStackMonitoring(t *testing.T) {
+	spec := program.Spec{Name: "Filebeat", Cmd: "filebeat"}
+	stackMonitoring := &monitoringConfig.StackMonitoringConfig{
+		Enabled:     true,
+		ClusterUUID: "abc",
+		Elasticsearch: map[string]interface{}{
+			"hosts":    []interface{}{"https://monitoring:9200"},
+			"username": "agent",
+			"password": "secret",
+		},
+	}
+
+	t.Run("args without secrets", func(t *testing.T) {
+		m := &Monitor{operatingSystem: "linux", config: &monitoringConfig.MonitoringConfig{StackMonitoring: stackMonitoring}}
+
+		args := m.stackMonitoringArgs(spec, "default", false)
+		assert.Equal(t, []string{
+			"-E", "monitoring.enabled=true",
+			"-E", "monitoring.cluster_uuid=abc",
+			"-E", `monitoring.elasticsearch.hosts=["https://monitoring:9200"]`,
+			"-E", "monitoring.elasticsearch.password=${ELASTIC_AGENT_STACK_MONITORING_PASSWORD}",
+			"-E", "monitoring.elasticsearch.username=agent",
+		}, args)
+
+		env := m.EnrichEnv(spec, "default", []string{"A=B"}, false)
+		assert.Equal(t, []string{"A=B", "ELASTIC_AGENT_STACK_MONITORING_PASSWORD=secret"}, env)
+	})
+
+	t.Run("sidecar is not shipped", func(t *testing.T) {
+		m := &Monitor{operatingSystem: "linux", config: &monitoringConfig.MonitoringConfig{StackMonitoring: stackMonitoring}}
+
+		assert.Empty(t, m.stackMonitoringArgs(spec, "default", true))
+		assert.Equal(t, []string{"A=B"}, m.EnrichEnv(spec, "default", []string{"A=B"}, true))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m := &Monitor{operatingSystem: "linux", config: &monitoringConfig.MonitoringConfig{}}
+
+		assert.Empty(t, m.stackMonitoringArgs(spec, "default", false))
+		_, ok := m.StackMonitoringLogsOutput()
+		assert.False(t, ok)
+	})
+
+	t.Run("logs output", func(t *testing.T) {
+		withLogs := *stackMonitoring
+		withLogs.Logs = true
+		m := &Monitor{operatingSystem: "linux", config: &monitoringConfig.MonitoringConfig{StackMonitoring: &withLogs}}
+
+		output, ok := m.StackMonitoringLogsOutput()
+		assert.True(t, ok)
+		assert.Equal(t, "agent", output["username"])
+	})
+}
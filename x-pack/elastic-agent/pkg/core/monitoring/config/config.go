@@ -4,18 +4,22 @@
 
 package config
 
+import "time"
+
 const defaultPort = 6791
 const defaultNamespace = "default"
 
 // MonitoringConfig describes a configuration of a monitoring
 type MonitoringConfig struct {
-	Enabled        bool                  `yaml:"enabled" config:"enabled"`
-	MonitorLogs    bool                  `yaml:"logs" config:"logs"`
-	MonitorMetrics bool                  `yaml:"metrics" config:"metrics"`
-	LogMetrics     bool                  `yaml:"-" config:"-"`
-	HTTP           *MonitoringHTTPConfig `yaml:"http" config:"http"`
-	Namespace      string                `yaml:"namespace" config:"namespace"`
-	Pprof          *PprofConfig          `yaml:"pprof" config:"pprof"`
+	Enabled         bool                   `yaml:"enabled" config:"enabled"`
+	MonitorLogs     bool                   `yaml:"logs" config:"logs"`
+	MonitorMetrics  bool                   `yaml:"metrics" config:"metrics"`
+	LogMetrics      bool                   `yaml:"-" config:"-"`
+	HTTP            *MonitoringHTTPConfig  `yaml:"http" config:"http"`
+	Namespace       string                 `yaml:"namespace" config:"namespace"`
+	Pprof           *PprofConfig           `yaml:"pprof" config:"pprof"`
+	ForwardMetrics  *ForwardMetricsConfig  `yaml:"forward_metrics" config:"forward_metrics"`
+	StackMonitoring *StackMonitoringConfig `yaml:"stack_monitoring" config:"stack_monitoring"`
 }
 
 // MonitoringHTTPConfig is a config defining HTTP endpoint published by agent
@@ -34,6 +38,29 @@ type PprofConfig struct {
 	Enabled bool `yaml:"enabled" config:"enabled"`
 }
 
+// ForwardMetricsConfig is a config defining how the metrics of the processes are forwarded to
+// fleet. A summary of the metrics of each process is sent as an event with the checkins.
+type ForwardMetricsConfig struct {
+	Enabled bool          `yaml:"enabled" config:"enabled"`
+	Period  time.Duration `yaml:"period" config:"period" validate:"positive"`
+}
+
+// StackMonitoringConfig is a config defining how the metrics of the agent and of its processes
+// are shipped to a monitoring cluster in the stack monitoring format.
+// It is a nil struct by default so a policy without it keeps the locally configured one.
+type StackMonitoringConfig struct {
+	Enabled     bool   `yaml:"enabled" config:"enabled"`
+	ClusterUUID string `yaml:"cluster_uuid" config:"cluster_uuid"`
+	// Logs ships the logs collected by the monitoring to the monitoring cluster as well.
+	Logs          bool                   `yaml:"logs" config:"logs"`
+	Elasticsearch map[string]interface{} `yaml:"elasticsearch" config:"elasticsearch"`
+}
+
+// IsEnabled returns true when the metrics are shipped to a monitoring cluster.
+func (c *StackMonitoringConfig) IsEnabled() bool {
+	return c != nil && c.Enabled && len(c.Elasticsearch) > 0
+}
+
 // DefaultConfig creates a config with pre-set default values.
 func DefaultConfig() *MonitoringConfig {
 	return &MonitoringConfig{
@@ -46,5 +73,9 @@ func DefaultConfig() *MonitoringConfig {
 			Port:    defaultPort,
 		},
 		Namespace: defaultNamespace,
+		ForwardMetrics: &ForwardMetricsConfig{
+			Enabled: true,
+			Period:  5 * time.Minute,
+		},
 	}
 }
@@ -18,15 +18,17 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/monitoring"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/sorted"
 )
 
-// New creates a new server exposing metrics and process information.
+// New creates a new server exposing metrics, process information and the status of the agent.
 func New(
 	log *logger.Logger,
 	endpointConfig api.Config,
 	ns func(string) *monitoring.Namespace,
 	routesFetchFn func() *sorted.Set,
+	statusFn func() status.AgentStatus,
 	enableProcessStats bool,
 ) (*api.Server, error) {
 	if err := createAgentMonitoringDrop(endpointConfig.Host); err != nil {
@@ -39,13 +41,14 @@ func New(
 		return nil, err
 	}
 
-	return exposeMetricsEndpoint(log, cfg, ns, routesFetchFn, enableProcessStats)
+	return exposeMetricsEndpoint(log, cfg, ns, routesFetchFn, statusFn, enableProcessStats)
 }
 
-func exposeMetricsEndpoint(log *logger.Logger, config *common.Config, ns func(string) *monitoring.Namespace, routesFetchFn func() *sorted.Set, enableProcessStats bool) (*api.Server, error) {
+func exposeMetricsEndpoint(log *logger.Logger, config *common.Config, ns func(string) *monitoring.Namespace, routesFetchFn func() *sorted.Set, statusFn func() status.AgentStatus, enableProcessStats bool) (*api.Server, error) {
 	r := mux.NewRouter()
 	statsHandler := statsHandler(ns("stats"))
 	r.Handle("/stats", createHandler(statsHandler))
+	r.Handle("/status", createHandler(statusHandler(statusFn, ns("stats"))))
 
 	if enableProcessStats {
 		r.HandleFunc("/processes", processesHandler(routesFetchFn))
@@ -0,0 +1,97 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+type applicationStatus struct {
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name"`
+	Status  string                 `json:"status"`
+	Message string                 `json:"message"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+type fleetStatus struct {
+	LastCheckin        string `json:"last_checkin,omitempty"`
+	LastCheckinSuccess string `json:"last_checkin_success,omitempty"`
+	LastCheckinError   string `json:"last_checkin_error,omitempty"`
+	LastCheckinHost    string `json:"last_checkin_host,omitempty"`
+	QueuedEvents       int64  `json:"queued_events"`
+	PolicyID           string `json:"policy_id,omitempty"`
+	PolicyRevision     int64  `json:"policy_revision,omitempty"`
+}
+
+type statusResponse struct {
+	Status       string              `json:"status"`
+	Message      string              `json:"message"`
+	Applications []applicationStatus `json:"applications"`
+
+	// Fleet is only reported when the agent is managed by Fleet.
+	Fleet *fleetStatus `json:"fleet,omitempty"`
+}
+
+// statusHandler reports the status of the agent and of its applications with the state of the
+// fleet gateway, the response status is 503 when the agent is failed so it can be used as a probe.
+func statusHandler(statusFn func() status.AgentStatus, stats *monitoring.Namespace) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		agentStatus := statusFn()
+		resp := statusResponse{
+			Status:       agentStatus.Status.String(),
+			Message:      agentStatus.Message,
+			Applications: make([]applicationStatus, 0, len(agentStatus.Applications)),
+			Fleet:        fleetStatusFromStats(stats.GetRegistry()),
+		}
+		for _, app := range agentStatus.Applications {
+			resp.Applications = append(resp.Applications, applicationStatus{
+				ID:      app.ID,
+				Name:    app.Name,
+				Status:  app.Status.ToProto().String(),
+				Message: app.Message,
+				Payload: app.Payload,
+			})
+		}
+
+		if agentStatus.Status == status.Failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeResponse(w, resp)
+		return nil
+	}
+}
+
+// fleetStatusFromStats reads the state of the fleet gateway from the metrics of the gateway, of
+// the reporter and of the applied policy, nil is returned when the agent is not managed by Fleet.
+func fleetStatusFromStats(reg *monitoring.Registry) *fleetStatus {
+	gateway := reg.GetRegistry("fleet_gateway")
+	if gateway == nil {
+		return nil
+	}
+
+	s := &fleetStatus{}
+	gatewaySnapshot := monitoring.CollectFlatSnapshot(gateway, monitoring.Full, false)
+	s.LastCheckin = gatewaySnapshot.Strings["last_checkin"]
+	s.LastCheckinSuccess = gatewaySnapshot.Strings["last_checkin_success"]
+	s.LastCheckinError = gatewaySnapshot.Strings["last_checkin_error"]
+	s.LastCheckinHost = gatewaySnapshot.Strings["last_checkin_host"]
+
+	if reporter := reg.GetRegistry("fleet_reporter"); reporter != nil {
+		s.QueuedEvents = monitoring.CollectFlatSnapshot(reporter, monitoring.Full, false).Ints["events_queued"]
+	}
+
+	if policy := reg.GetRegistry("fleet_policy"); policy != nil {
+		policySnapshot := monitoring.CollectFlatSnapshot(policy, monitoring.Full, false)
+		s.PolicyID = policySnapshot.Strings["id"]
+		s.PolicyRevision = policySnapshot.Ints["revision"]
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+func TestStatusHandler(t *testing.T) {
+	agentStatus := status.AgentStatus{
+		Status: status.Degraded,
+		Applications: []status.AgentApplicationStatus{
+			{ID: "filebeat-default", Name: "filebeat", Status: state.Degraded, Message: "output unreachable"},
+		},
+	}
+	statusFn := func() status.AgentStatus { return agentStatus }
+
+	get := func(t *testing.T, ns *monitoring.Namespace) (int, statusResponse) {
+		w := httptest.NewRecorder()
+		createHandler(statusHandler(statusFn, ns)).ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+
+		var resp statusResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	t.Run("standalone agent", func(t *testing.T) {
+		code, resp := get(t, monitoring.GetNamespace("status_test_standalone"))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "degraded", resp.Status)
+		assert.Nil(t, resp.Fleet)
+		require.Len(t, resp.Applications, 1)
+		assert.Equal(t, "filebeat", resp.Applications[0].Name)
+		assert.Equal(t, "DEGRADED", resp.Applications[0].Status)
+		assert.Equal(t, "output unreachable", resp.Applications[0].Message)
+	})
+
+	t.Run("agent managed by fleet", func(t *testing.T) {
+		ns := monitoring.GetNamespace("status_test_fleet")
+		gateway := ns.GetRegistry().NewRegistry("fleet_gateway")
+		monitoring.NewString(gateway, "last_checkin_error").Set("fleet-server unavailable")
+		monitoring.NewString(gateway, "last_checkin_host").Set("fleet-1:8220")
+		monitoring.NewInt(ns.GetRegistry().NewRegistry("fleet_reporter"), "events_queued").Set(12)
+		policy := ns.GetRegistry().NewRegistry("fleet_policy")
+		monitoring.NewString(policy, "id").Set("policy-1")
+		monitoring.NewInt(policy, "revision").Set(3)
+
+		_, resp := get(t, ns)
+		require.NotNil(t, resp.Fleet)
+		assert.Equal(t, "fleet-server unavailable", resp.Fleet.LastCheckinError)
+		assert.Equal(t, "fleet-1:8220", resp.Fleet.LastCheckinHost)
+		assert.Equal(t, int64(12), resp.Fleet.QueuedEvents)
+		assert.Equal(t, "policy-1", resp.Fleet.PolicyID)
+		assert.Equal(t, int64(3), resp.Fleet.PolicyRevision)
+	})
+
+	t.Run("failed agent is reported as unavailable", func(t *testing.T) {
+		agentStatus.Status = status.Failed
+		code, resp := get(t, monitoring.GetNamespace("status_test_failed"))
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "error", resp.Status)
+	})
+}
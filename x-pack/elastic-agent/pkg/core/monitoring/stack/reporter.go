@@ -0,0 +1,141 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package stack ships the metrics of the agent to a monitoring cluster in the stack monitoring
+// format.
+package stack
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/monitoring/report"
+	// registers the elasticsearch reporter.
+	_ "github.com/elastic/beats/v7/libbeat/monitoring/report/elasticsearch"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	monitoringCfg "github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
+)
+
+const agentName = "elastic-agent"
+
+// Reporter ships the stats and state namespaces of the agent, the metrics exposed by its monitoring
+// endpoint, to the monitoring cluster. The reporter is restarted when the policy changes its
+// stack monitoring configuration.
+type Reporter struct {
+	log  *logger.Logger
+	info beat.Info
+
+	mx       sync.Mutex
+	cfg      *monitoringCfg.StackMonitoringConfig
+	reporter report.Reporter
+}
+
+// NewReporter creates a reporter for the agent from its monitoring configuration, it is not started.
+func NewReporter(log *logger.Logger, agentID string, monitoringConfig *monitoringCfg.MonitoringConfig) *Reporter {
+	var cfg *monitoringCfg.StackMonitoringConfig
+	if monitoringConfig != nil {
+		cfg = monitoringConfig.StackMonitoring
+	}
+
+	id, err := uuid.FromString(agentID)
+	if err != nil {
+		id = uuid.Must(uuid.NewV4())
+	}
+	hostname, _ := os.Hostname()
+	now := time.Now()
+
+	return &Reporter{
+		log: log,
+		info: beat.Info{
+			Beat:            agentName,
+			IndexPrefix:     agentName,
+			Version:         release.Version(),
+			ElasticLicensed: true,
+			Name:            hostname,
+			Hostname:        hostname,
+			ID:              id,
+			EphemeralID:     uuid.Must(uuid.NewV4()),
+			FirstStart:      now,
+			StartTime:       now,
+		},
+		cfg: cfg,
+	}
+}
+
+// Start starts shipping the metrics when enabled by the local configuration.
+func (r *Reporter) Start() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.start()
+}
+
+// Reload restarts the reporter when the policy changes the stack monitoring configuration, a
+// policy without it keeps the current configuration.
+func (r *Reporter) Reload(rawConfig *config.Config) error {
+	var cfg struct {
+		Agent struct {
+			Monitoring struct {
+				StackMonitoring *monitoringCfg.StackMonitoringConfig `config:"stack_monitoring"`
+			} `config:"monitoring"`
+		} `config:"agent"`
+	}
+	if err := rawConfig.Unpack(&cfg); err != nil {
+		return err
+	}
+
+	updated := cfg.Agent.Monitoring.StackMonitoring
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if updated == nil || reflect.DeepEqual(updated, r.cfg) {
+		return nil
+	}
+
+	r.stop()
+	r.cfg = updated
+	return r.start()
+}
+
+// Stop stops shipping the metrics.
+func (r *Reporter) Stop() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.stop()
+}
+
+func (r *Reporter) start() error {
+	if !r.cfg.IsEnabled() {
+		return nil
+	}
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"elasticsearch": r.cfg.Elasticsearch,
+	})
+	if err != nil {
+		return errors.New(err, "invalid stack monitoring configuration", errors.TypeConfig)
+	}
+
+	reporter, err := report.New(r.info, report.Settings{ClusterUUID: r.cfg.ClusterUUID}, cfg, common.ConfigNamespace{})
+	if err != nil {
+		return errors.New(err, "failed to start shipping the metrics to the monitoring cluster", errors.TypeConfig)
+	}
+	r.reporter = reporter
+	r.log.Info("Shipping the metrics of the agent to the monitoring cluster")
+	return nil
+}
+
+func (r *Reporter) stop() {
+	if r.reporter != nil {
+		r.reporter.Stop()
+		r.reporter = nil
+	}
+}
@@ -63,6 +63,7 @@ type Application struct {
 	appLock          sync.Mutex
 	restartCanceller context.CancelFunc
 	restartConfig    map[string]interface{}
+	restartBudget    *process.RestartBudget
 }
 
 // ArgsDecorator decorates arguments before calling an application
@@ -108,6 +109,7 @@ func NewApplication(
 		gid:            gid,
 		statusReporter: statusController.RegisterApp(id, appName),
 		watchClosers:   make(map[int]context.CancelFunc),
+		restartBudget:  process.NewRestartBudget(cfg.ProcessConfig.Restart),
 	}, nil
 }
 
@@ -135,7 +137,7 @@ func (a *Application) Name() string {
 
 // Started returns true if the application is started.
 func (a *Application) Started() bool {
-	return a.state.Status != state.Stopped && a.state.Status != state.Crashed && a.state.Status != state.Failed
+	return a.state.Status != state.Stopped && a.state.Status != state.Crashed && a.state.Status != state.Failed && a.state.Status != state.Quarantined
 }
 
 // Stop stops the current application.
@@ -222,13 +224,42 @@ func (a *Application) watch(ctx context.Context, p app.Taggable, proc *process.I
 		}
 
 		msg := fmt.Sprintf("exited with code: %d", procState.ExitCode())
+		delay, ok := a.restartBudget.Crashed(time.Now())
+		if !ok {
+			cfg := a.restartBudget.Config()
+			a.setState(state.Quarantined, fmt.Sprintf("%s, crashed more than %d times within %s, quarantined until released by Fleet", msg, cfg.MaxRestarts, cfg.Window), nil)
+			return
+		}
 		a.setState(state.Restarting, msg, nil)
 
-		// it was a crash
-		a.start(ctx, p, cfg, true)
+		// the lock is released while waiting so the application can be stopped or started again.
+		a.appLock.Unlock()
+		waited := a.waitRestart(delay)
+		a.appLock.Lock()
+		if !waited || a.state.Status != state.Restarting || a.state.ProcessInfo != nil || a.srvState != srvState {
+			return
+		}
+
+		// it was a crash, the context of the watcher is cancelled so the context of the start is used.
+		if err := a.start(a.startContext, p, cfg, true); err != nil {
+			a.setState(state.Crashed, fmt.Sprintf("failed to restart: %s", err), nil)
+		}
 	}()
 }
 
+// waitRestart waits for the delay before restarting a crashed process, it returns false when the
+// agent is stopped meanwhile.
+func (a *Application) waitRestart(delay time.Duration) bool {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-a.bgContext.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
 func (a *Application) stopWatcher(procInfo *process.Info) {
 	if procInfo != nil {
 		if closer, ok := a.watchClosers[procInfo.PID]; ok {
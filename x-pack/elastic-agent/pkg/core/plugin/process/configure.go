@@ -46,7 +46,8 @@ func (a *Application) Configure(ctx context.Context, config map[string]interface
 		return errors.New(err, errors.TypeApplication)
 	}
 
-	if isRestartNeeded {
+	// a quarantined application starts with the updated config once released.
+	if isRestartNeeded && a.state.Status != state.Quarantined {
 		a.logger.Infof("initiating restart of '%s' due to config change", a.Name())
 		a.appLock.Unlock()
 		a.Stop()
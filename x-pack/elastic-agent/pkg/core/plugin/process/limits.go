@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/process"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+)
+
+// enforceLimits applies the CPU and memory limits of the program to the process and periodically
+// checks whether the process reached them until ctx is done, a process reaching its limits is
+// reported as degraded.
+//
+// This does not grab the appLock, the caller must hold it.
+func (a *Application) enforceLimits(ctx context.Context, proc *process.Info) {
+	limits := a.processConfig.LimitsOf(a.desc.Spec().Cmd)
+	if limits.IsZero() {
+		return
+	}
+
+	limiter, err := process.LimitProcess(a.pipelineID+"-"+a.id, proc.PID, limits)
+	if err != nil {
+		a.logger.Warnf("resource limits of '%s' are not enforced: %v", a.Name(), err)
+		return
+	}
+
+	period := a.processConfig.LimitsCheckPeriod
+	if period <= 0 {
+		period = process.DefaultConfig().LimitsCheckPeriod
+	}
+
+	go func() {
+		defer limiter.Close()
+
+		t := time.NewTicker(period)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			breach, err := limiter.Breach()
+			if err != nil {
+				a.logger.Warnf("failed to check the resource limits of '%s': %v", a.Name(), err)
+				continue
+			}
+			if breach.IsZero() {
+				continue
+			}
+			a.logger.Warnf("process of '%s' %s", a.Name(), breach)
+
+			a.appLock.Lock()
+			if a.state.ProcessInfo != proc {
+				a.appLock.Unlock()
+				return
+			}
+			a.setState(state.Degraded, "process "+breach.String(), nil)
+			a.appLock.Unlock()
+		}
+	}()
+}
@@ -0,0 +1,132 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/metricbeat/mb/parse"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/monitoring/beats"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/process"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+)
+
+// probeLiveness periodically requests the monitoring endpoint of the process until ctx is done,
+// the process is restarted when it does not answer FailureThreshold times in a row.
+//
+// This does not grab the appLock, the caller must hold it.
+func (a *Application) probeLiveness(ctx context.Context, proc *process.Info, isSidecar bool) {
+	cfg := a.processConfig.Liveness
+	if !cfg.Enabled || cfg.Period <= 0 || cfg.FailureThreshold <= 0 {
+		return
+	}
+
+	endpoint := beats.MonitoringEndpoint(a.desc.Spec(), runtime.GOOS, a.pipelineID)
+	if isSidecar {
+		endpoint += "_monitor"
+	}
+	if !strings.HasPrefix(endpoint, "http") {
+		// the monitoring endpoint is a unix socket or a named pipe
+		endpoint = "http+" + endpoint
+	}
+
+	go func() {
+		t := time.NewTicker(cfg.Period)
+		defer t.Stop()
+
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			a.appLock.Lock()
+			status := a.state.Status
+			current := a.state.ProcessInfo == proc
+			a.appLock.Unlock()
+			if !current {
+				return
+			}
+			if status == state.Starting || status == state.Restarting {
+				// the monitoring endpoint may not be listening yet.
+				continue
+			}
+
+			err := ping(ctx, endpoint, cfg.Timeout)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			failures++
+			a.logger.Warnf("liveness probe of '%s' failed %d times in a row: %v", a.Name(), failures, err)
+			if failures < cfg.FailureThreshold {
+				continue
+			}
+
+			a.appLock.Lock()
+			if a.state.ProcessInfo != proc {
+				a.appLock.Unlock()
+				return
+			}
+			a.setState(state.Failed, fmt.Sprintf("not responding to liveness probe: %v", err), nil)
+			a.appLock.Unlock()
+
+			a.restart(proc)
+			return
+		}
+	}()
+}
+
+// ping requests the root path of the monitoring endpoint, the request fails when the endpoint does
+// not answer within the timeout.
+func ping(ctx context.Context, endpoint string, timeout time.Duration) error {
+	hostData, err := parse.ParseURL(endpoint, "http", "", "", "/", "")
+	if err != nil {
+		return err
+	}
+
+	dialer, err := hostData.Transport.Make(timeout)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Dial: dialer.Dial,
+		},
+	}
+
+	req, err := http.NewRequest("GET", hostData.URI, nil)
+	if err != nil {
+		return err
+	}
+	req.Close = true
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("monitoring endpoint returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"beat":"filebeat"}`))
+		case "/hung":
+			<-hung
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	// released before closing the server which waits for the handlers.
+	defer close(hung)
+
+	t.Run("responding process", func(t *testing.T) {
+		assert.NoError(t, ping(context.Background(), srv.URL, time.Second))
+	})
+
+	t.Run("hung process", func(t *testing.T) {
+		assert.Error(t, ping(context.Background(), srv.URL+"/hung", 100*time.Millisecond))
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		assert.Error(t, ping(context.Background(), srv.URL+"/unknown", time.Second))
+	})
+
+	t.Run("process not listening", func(t *testing.T) {
+		assert.Error(t, ping(context.Background(), "http+unix:///does/not/exist.sock", time.Second))
+	})
+}
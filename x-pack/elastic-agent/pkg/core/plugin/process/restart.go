@@ -0,0 +1,31 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+)
+
+// Release starts again the application quarantined after crashing too often, with a new budget of
+// restarts. It returns false when the application is not quarantined.
+func (a *Application) Release() (bool, error) {
+	a.appLock.Lock()
+	defer a.appLock.Unlock()
+
+	if a.state.Status != state.Quarantined {
+		return false, nil
+	}
+
+	a.logger.Infof("releasing quarantined application '%s'", a.Name())
+	a.restartBudget.Reset()
+	// the application is started with the last config kept by its server state.
+	if err := a.start(a.startContext, a.tag, nil, false); err != nil {
+		a.setState(state.Crashed, fmt.Sprintf("failed to start after release: %s", err), nil)
+		return true, err
+	}
+	return true, nil
+}
@@ -7,6 +7,7 @@ package process
 import (
 	"context"
 	"io"
+	"os/exec"
 	"path/filepath"
 
 	"gopkg.in/yaml.v2"
@@ -15,17 +16,28 @@ import (
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/application/paths"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/program"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/app"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/process"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/server"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
 )
 
+// envEnricher is implemented by the monitors adding settings to the environment of the process.
+type envEnricher interface {
+	EnrichEnv(spec program.Spec, pipelineID string, env []string, isSidecar bool) []string
+}
+
 // Start starts the application with a specified config.
 func (a *Application) Start(ctx context.Context, t app.Taggable, cfg map[string]interface{}) error {
 	a.appLock.Lock()
 	defer a.appLock.Unlock()
 
+	// a quarantined application is only started again when released.
+	if a.state.Status == state.Quarantined {
+		a.logger.Infof("application '%s' is quarantined, not starting it until released", a.Name())
+		return nil
+	}
 	return a.start(ctx, t, cfg, false)
 }
 
@@ -124,13 +136,23 @@ func (a *Application) start(ctx context.Context, t app.Taggable, cfg map[string]
 	// of the beat with same data path fails to start
 	spec.Args = injectDataPath(spec.Args, a.pipelineID, a.id)
 
+	var opts []process.Option
+	if e, ok := a.monitor.(envEnricher); ok {
+		if env := e.EnrichEnv(a.desc.Spec(), a.pipelineID, nil, isSidecar); len(env) > 0 {
+			opts = append(opts, func(c *exec.Cmd) {
+				c.Env = append(c.Env, env...)
+			})
+		}
+	}
+
 	a.state.ProcessInfo, err = process.Start(
 		a.logger,
 		spec.BinaryPath,
 		a.processConfig,
 		a.uid,
 		a.gid,
-		spec.Args)
+		spec.Args,
+		opts...)
 	if err != nil {
 		return err
 	}
@@ -143,6 +165,8 @@ func (a *Application) start(ctx context.Context, t app.Taggable, cfg map[string]
 	a.watchClosers[a.state.ProcessInfo.PID] = cancel
 	// setup watcher
 	a.watch(cancelCtx, t, a.state.ProcessInfo, cfg)
+	a.probeLiveness(cancelCtx, a.state.ProcessInfo, isSidecar)
+	a.enforceLimits(cancelCtx, a.state.ProcessInfo)
 
 	return nil
 }
@@ -58,6 +58,11 @@ type Application struct {
 	statusReporter status.Reporter
 
 	processConfig *process.Config
+	restartBudget *process.RestartBudget
+	// failedCanceller stops the timer recovering the failed service.
+	failedCanceller context.CancelFunc
+	// recoverFn reinstalls the failed service.
+	recoverFn func(context.Context) error
 
 	logger *logger.Logger
 
@@ -88,7 +93,7 @@ func NewApplication(
 	}
 
 	b, _ := tokenbucket.NewTokenBucket(ctx, 3, 3, 1*time.Second)
-	return &Application{
+	a := &Application{
 		bgContext:     ctx,
 		id:            id,
 		name:          appName,
@@ -97,6 +102,7 @@ func NewApplication(
 		desc:          desc,
 		srv:           srv,
 		processConfig: cfg.ProcessConfig,
+		restartBudget: process.NewRestartBudget(cfg.ProcessConfig.Restart),
 		logger:        logger,
 		limiter:       b,
 		state: state.State{
@@ -108,7 +114,9 @@ func NewApplication(
 		gid:            gid,
 		credsPort:      credsPort,
 		statusReporter: statusController.RegisterApp(id, appName),
-	}, nil
+	}
+	a.recoverFn = a.reinstall
+	return a, nil
 }
 
 // Monitor returns monitoring handler of this app.
@@ -266,6 +274,7 @@ func (a *Application) Stop() {
 	}
 	a.srvState = nil
 
+	a.stopFailedTimer()
 	a.cleanUp()
 	a.stopCredsListener()
 	a.appLock.Unlock()
@@ -286,13 +295,14 @@ func (a *Application) Shutdown() {
 	a.srvState.Destroy()
 	a.srvState = nil
 
+	a.stopFailedTimer()
 	a.cleanUp()
 	a.stopCredsListener()
 }
 
 // OnStatusChange is the handler called by the GRPC server code.
 //
-// It updates the status of the application and handles restarting the application is needed.
+// It updates the status of the application and handles recovering the service if needed.
 func (a *Application) OnStatusChange(s *server.ApplicationState, status proto.StateObserved_Status, msg string, payload map[string]interface{}) {
 	a.appLock.Lock()
 	defer a.appLock.Unlock()
@@ -304,6 +314,15 @@ func (a *Application) OnStatusChange(s *server.ApplicationState, status proto.St
 	}
 
 	a.setState(state.FromProto(status), msg, payload)
+	if status == proto.StateObserved_FAILED {
+		// ignore when expected state is stopping
+		if s.Expected() == proto.StateExpected_STOPPING {
+			return
+		}
+		a.startFailedTimer()
+	} else {
+		a.stopFailedTimer()
+	}
 }
 
 func (a *Application) setState(s state.Status, msg string, payload map[string]interface{}) {
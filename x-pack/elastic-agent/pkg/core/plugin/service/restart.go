@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/server"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+)
+
+// startFailedTimer starts a timer that recovers the service if it does not exit the failed state
+// after a period of time, a service missing its check-ins is failed by the server.
+//
+// This does not grab the appLock, that must be managed by the caller.
+func (a *Application) startFailedTimer() {
+	if a.failedCanceller != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(a.bgContext)
+	a.failedCanceller = cancel
+	srvState := a.srvState
+	t := time.NewTimer(a.processConfig.FailureTimeout)
+	go func() {
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		rearm := a.recover(srvState)
+
+		a.appLock.Lock()
+		defer a.appLock.Unlock()
+		if ctx.Err() != nil {
+			// the timer was stopped meanwhile.
+			return
+		}
+		cancel()
+		a.failedCanceller = nil
+		if rearm {
+			// the service is recovered again when it does not check in.
+			a.startFailedTimer()
+		}
+	}()
+}
+
+// stopFailedTimer stops the timer that would recover the service from reporting failure.
+//
+// This does not grab the appLock, that must be managed by the caller.
+func (a *Application) stopFailedTimer() {
+	if a.failedCanceller == nil {
+		return
+	}
+	a.failedCanceller()
+	a.failedCanceller = nil
+}
+
+// recover reinstalls the failed service with the install steps of its spec, the installer
+// restarts the service in the system service manager. Like the crashed processes the service is
+// quarantined once it failed too many times within the restart window.
+func (a *Application) recover(srvState *server.ApplicationState) bool {
+	a.appLock.Lock()
+	if a.srvState != srvState || (a.state.Status != state.Failed && a.state.Status != state.Starting) {
+		a.appLock.Unlock()
+		return false
+	}
+
+	msg := a.state.Message
+	if _, ok := a.restartBudget.Crashed(time.Now()); !ok {
+		cfg := a.restartBudget.Config()
+		a.setState(state.Quarantined, fmt.Sprintf("%s, failed more than %d times within %s, quarantined until released by Fleet", msg, cfg.MaxRestarts, cfg.Window), nil)
+		a.appLock.Unlock()
+		return false
+	}
+	a.logger.Infof("reinstalling service '%s' after it failed: %s", a.Name(), msg)
+	a.setState(state.Restarting, msg, nil)
+	a.appLock.Unlock()
+
+	// the lock is released while the installer runs, the installer is not interrupted when the
+	// service checks in meanwhile.
+	err := a.recoverFn(a.bgContext)
+
+	a.appLock.Lock()
+	defer a.appLock.Unlock()
+	if a.srvState != srvState || a.state.Status != state.Restarting {
+		return false
+	}
+	if err != nil {
+		a.setState(state.Failed, fmt.Sprintf("failed to reinstall the service: %s", err), nil)
+	} else {
+		a.setState(state.Starting, "Waiting for the service to check in", nil)
+	}
+	return true
+}
+
+// reinstall runs the install steps of the spec of the service.
+func (a *Application) reinstall(ctx context.Context) error {
+	spec := a.desc.Spec()
+	if spec.PostInstallSteps == nil {
+		return errors.New("service has no install steps", errors.TypeApplication)
+	}
+	return spec.PostInstallSteps.Execute(ctx, a.desc.Directory())
+}
+
+// Release recovers again the service quarantined after failing too often, with a new budget of
+// restarts. It returns false when the service is not quarantined.
+func (a *Application) Release() (bool, error) {
+	a.appLock.Lock()
+	defer a.appLock.Unlock()
+
+	if a.state.Status != state.Quarantined {
+		return false, nil
+	}
+
+	a.logger.Infof("releasing quarantined service '%s'", a.Name())
+	a.restartBudget.Reset()
+	a.setState(state.Starting, "Waiting for the service to check in", nil)
+	a.startFailedTimer()
+	return true, nil
+}
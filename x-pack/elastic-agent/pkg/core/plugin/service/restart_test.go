@@ -0,0 +1,90 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/process"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/state"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/status"
+)
+
+func TestRecoverFailedService(t *testing.T) {
+	log, _ := logger.New("", false)
+
+	newApp := func(maxRestarts int, recoverErr error) (*Application, *int32) {
+		cfg := process.DefaultConfig()
+		cfg.FailureTimeout = 10 * time.Millisecond
+		cfg.Restart.MaxRestarts = maxRestarts
+
+		var recovered int32
+		a := &Application{
+			bgContext:      context.Background(),
+			name:           "endpoint-security",
+			processConfig:  cfg,
+			restartBudget:  process.NewRestartBudget(cfg.Restart),
+			logger:         log,
+			statusReporter: status.NewController(log).RegisterApp("endpoint", "endpoint-security"),
+		}
+		a.recoverFn = func(context.Context) error {
+			atomic.AddInt32(&recovered, 1)
+			return recoverErr
+		}
+		return a, &recovered
+	}
+	fail := func(a *Application) {
+		a.appLock.Lock()
+		a.setState(state.Failed, "Missed two check-ins", nil)
+		a.startFailedTimer()
+		a.appLock.Unlock()
+	}
+	statusOf := func(a *Application) func() state.Status {
+		return func() state.Status { return a.State().Status }
+	}
+
+	t.Run("failed service is reinstalled", func(t *testing.T) {
+		a, recovered := newApp(10, nil)
+		fail(a)
+
+		require.Eventually(t, func() bool { return statusOf(a)() == state.Starting }, 5*time.Second, 5*time.Millisecond)
+		assert.GreaterOrEqual(t, atomic.LoadInt32(recovered), int32(1))
+
+		// the service checks in again.
+		a.appLock.Lock()
+		a.setState(state.Healthy, "Running", nil)
+		a.stopFailedTimer()
+		a.appLock.Unlock()
+		count := atomic.LoadInt32(recovered)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, count, atomic.LoadInt32(recovered))
+		assert.Equal(t, state.Healthy, statusOf(a)())
+	})
+
+	t.Run("service failing too often is quarantined", func(t *testing.T) {
+		a, recovered := newApp(2, errors.New("install failed"))
+		fail(a)
+
+		require.Eventually(t, func() bool { return statusOf(a)() == state.Quarantined }, 5*time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(2), atomic.LoadInt32(recovered))
+
+		released, err := a.Release()
+		require.NoError(t, err)
+		assert.True(t, released)
+		require.Eventually(t, func() bool { return atomic.LoadInt32(recovered) > 2 }, 5*time.Second, 5*time.Millisecond)
+
+		released, err = (&Application{state: state.State{Status: state.Healthy}}).Release()
+		require.NoError(t, err)
+		assert.False(t, released)
+	})
+}
@@ -12,7 +12,44 @@ type Config struct {
 	StopTimeout    time.Duration `yaml:"stop_timeout" config:"stop_timeout"`
 	FailureTimeout time.Duration `yaml:"failure_timeout" config:"failure_timeout"`
 
-	// TODO: cgroups and namespaces
+	// Restart configures how crashed processes are restarted.
+	Restart RestartConfig `yaml:"restart" config:"restart"`
+
+	// Liveness configures the probing of the monitoring endpoint of the processes.
+	Liveness LivenessConfig `yaml:"liveness" config:"liveness"`
+
+	// Limits are the CPU and memory limits of the processes by program name, enforced with
+	// cgroups on Linux and Job Objects on Windows. The limits of the policy replace them.
+	Limits map[string]Limits `yaml:"limits,omitempty" config:"limits"`
+
+	// LimitsCheckPeriod is the time between two checks of the limits reached by the processes.
+	LimitsCheckPeriod time.Duration `yaml:"limits_check_period" config:"limits_check_period"`
+
+	// policy are the limits configured by the policy.
+	policy policyLimits
+
+	// TODO: namespaces
+}
+
+// RestartConfig configures the restart of crashed processes, the delay between restarts doubles
+// while the process keeps crashing within the window.
+type RestartConfig struct {
+	BackoffInit time.Duration `yaml:"backoff_init" config:"backoff_init"`
+	BackoffMax  time.Duration `yaml:"backoff_max" config:"backoff_max"`
+
+	// MaxRestarts is the number of restarts allowed within Window, once exceeded the process is
+	// not restarted anymore and its program is reported as failed. 0 disables the limit.
+	MaxRestarts int           `yaml:"max_restarts" config:"max_restarts"`
+	Window      time.Duration `yaml:"window" config:"window"`
+}
+
+// LivenessConfig configures the probing of a running process, a process which does not answer on
+// its monitoring endpoint FailureThreshold times in a row is considered hung and is restarted.
+type LivenessConfig struct {
+	Enabled          bool          `yaml:"enabled" config:"enabled"`
+	Period           time.Duration `yaml:"period" config:"period"`
+	Timeout          time.Duration `yaml:"timeout" config:"timeout"`
+	FailureThreshold int           `yaml:"failure_threshold" config:"failure_threshold"`
 }
 
 // DefaultConfig creates a config with pre-set default values.
@@ -21,5 +58,18 @@ func DefaultConfig() *Config {
 		SpawnTimeout:   30 * time.Second,
 		StopTimeout:    30 * time.Second,
 		FailureTimeout: 10 * time.Second,
+		Restart: RestartConfig{
+			BackoffInit: 1 * time.Second,
+			BackoffMax:  30 * time.Second,
+			MaxRestarts: 10,
+			Window:      10 * time.Minute,
+		},
+		Liveness: LivenessConfig{
+			Enabled:          true,
+			Period:           30 * time.Second,
+			Timeout:          10 * time.Second,
+			FailureThreshold: 3,
+		},
+		LimitsCheckPeriod: 30 * time.Second,
 	}
 }
@@ -0,0 +1,109 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+)
+
+// Limits are the CPU and memory limits of the processes of a program, a zero value means no limit.
+type Limits struct {
+	// CPU is the number of CPUs the process can use, 0.5 is half of a CPU.
+	CPU float64 `yaml:"cpu" config:"cpu"`
+	// Memory is the maximum memory used by the process.
+	Memory cfgtype.ByteSize `yaml:"memory" config:"memory"`
+}
+
+// Validate validates the limits.
+func (l *Limits) Validate() error {
+	if l.CPU < 0 {
+		return fmt.Errorf("cpu limit must be positive, got %v", l.CPU)
+	}
+	if l.Memory < 0 {
+		return fmt.Errorf("memory limit must be positive, got %d", l.Memory)
+	}
+	return nil
+}
+
+// IsZero returns true when the limits do not limit anything.
+func (l Limits) IsZero() bool {
+	return l.CPU == 0 && l.Memory == 0
+}
+
+// Breach describes the limits reached by a process since the last check.
+type Breach struct {
+	// MemoryLimited is the number of times the process reached its memory limit.
+	MemoryLimited uint64
+	// OOMKilled is the number of processes killed for exceeding the memory limit.
+	OOMKilled uint64
+	// CPUThrottled is the number of times the process was throttled by its CPU limit.
+	CPUThrottled uint64
+}
+
+// IsZero returns true when no limit was reached.
+func (b Breach) IsZero() bool {
+	return b.MemoryLimited == 0 && b.OOMKilled == 0 && b.CPUThrottled == 0
+}
+
+func (b Breach) String() string {
+	switch {
+	case b.OOMKilled > 0:
+		return fmt.Sprintf("killed for exceeding its memory limit %d times", b.OOMKilled)
+	case b.MemoryLimited > 0:
+		return fmt.Sprintf("reached its memory limit %d times", b.MemoryLimited)
+	default:
+		return fmt.Sprintf("throttled by its CPU limit %d times", b.CPUThrottled)
+	}
+}
+
+// policyLimits holds the limits of the programs configured by the policy.
+type policyLimits struct {
+	mx     sync.RWMutex
+	limits map[string]Limits
+}
+
+// LimitsOf returns the limits of the processes of the program, the limits of the policy replace
+// the local limits of the program.
+func (c *Config) LimitsOf(program string) Limits {
+	c.policy.mx.RLock()
+	l, ok := c.policy.limits[program]
+	c.policy.mx.RUnlock()
+	if ok {
+		return l
+	}
+	return c.Limits[program]
+}
+
+// Reload reads the limits of the programs from the policy, the limits are applied when the
+// processes are started.
+func (c *Config) Reload(rawConfig *config.Config) error {
+	var cfg struct {
+		Agent struct {
+			Process struct {
+				Limits map[string]Limits `config:"limits"`
+			} `config:"process"`
+		} `config:"agent"`
+	}
+	if err := rawConfig.Unpack(&cfg); err != nil {
+		return err
+	}
+
+	c.policy.mx.Lock()
+	c.policy.limits = cfg.Agent.Process.Limits
+	c.policy.mx.Unlock()
+	return nil
+}
+
+// Limiter enforces the limits of a process.
+type Limiter interface {
+	// Breach returns the limits reached by the process since the previous call.
+	Breach() (Breach, error)
+	// Close stops enforcing the limits, the limits of a running process are left in place.
+	Close() error
+}
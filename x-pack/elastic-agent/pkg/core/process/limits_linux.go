@@ -0,0 +1,157 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// cgroupRoot is the mount point of the unified cgroup hierarchy.
+var cgroupRoot = "/sys/fs/cgroup"
+
+const (
+	// agentCgroup is the cgroup holding the cgroups of the processes started by the agent.
+	agentCgroup = "elastic-agent"
+	// cpuPeriod is the period in microseconds of the CPU quota of the processes.
+	cpuPeriod = 100000
+)
+
+// cgroupLimiter enforces the limits of a process with a cgroup of the unified hierarchy.
+type cgroupLimiter struct {
+	dir  string
+	last Breach
+}
+
+// LimitProcess moves the process into a cgroup enforcing its limits, the cgroup is named after
+// the ID of the application so a restarted process reuses it.
+func LimitProcess(id string, pid int, limits Limits) (Limiter, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, errors.New(err, "resource limits require the unified cgroup hierarchy", errors.TypeApplication)
+	}
+
+	parent := filepath.Join(cgroupRoot, agentCgroup)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, errors.New(err, "failed to create the cgroup of the agent", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, parent))
+	}
+	// the controllers are enabled for the children of the cgroups, the root usually has them.
+	for _, dir := range []string{cgroupRoot, parent} {
+		if err := writeCgroupFile(dir, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+			return nil, err
+		}
+	}
+
+	dir := filepath.Join(parent, strings.ReplaceAll(id, string(os.PathSeparator), "_"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New(err, "failed to create the cgroup of the process", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, dir))
+	}
+
+	cpuMax := "max " + strconv.Itoa(cpuPeriod)
+	if limits.CPU > 0 {
+		cpuMax = fmt.Sprintf("%d %d", int64(limits.CPU*cpuPeriod), cpuPeriod)
+	}
+	memoryMax := "max"
+	if limits.Memory > 0 {
+		memoryMax = strconv.FormatInt(int64(limits.Memory), 10)
+	}
+	if err := writeCgroupFile(dir, "cpu.max", cpuMax); err != nil {
+		return nil, err
+	}
+	if err := writeCgroupFile(dir, "memory.max", memoryMax); err != nil {
+		return nil, err
+	}
+	if err := writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		return nil, err
+	}
+
+	l := &cgroupLimiter{dir: dir}
+	// the limits reached by a previous process of the cgroup are not reported.
+	if _, err := l.Breach(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *cgroupLimiter) Breach() (Breach, error) {
+	memory, err := readCgroupCounters(l.dir, "memory.events")
+	if err != nil {
+		return Breach{}, err
+	}
+	cpu, err := readCgroupCounters(l.dir, "cpu.stat")
+	if err != nil {
+		return Breach{}, err
+	}
+
+	current := Breach{
+		MemoryLimited: memory["max"],
+		OOMKilled:     memory["oom_kill"],
+		CPUThrottled:  cpu["nr_throttled"],
+	}
+	b := Breach{
+		MemoryLimited: counterDelta(l.last.MemoryLimited, current.MemoryLimited),
+		OOMKilled:     counterDelta(l.last.OOMKilled, current.OOMKilled),
+		CPUThrottled:  counterDelta(l.last.CPUThrottled, current.CPUThrottled),
+	}
+	l.last = current
+	return b, nil
+}
+
+func (l *cgroupLimiter) Close() error {
+	// the cgroup is only removed once its processes exited, it is reused by the next process.
+	_ = os.Remove(l.dir)
+	return nil
+}
+
+func writeCgroupFile(dir, name, content string) error {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		return errors.New(err, fmt.Sprintf("failed to write '%s' to the cgroup file", content), errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+	return nil
+}
+
+// readCgroupCounters reads a flat keyed cgroup file, a missing file means no limit was reached.
+func readCgroupCounters(dir, name string) (map[string]uint64, error) {
+	path := filepath.Join(dir, name)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(err, "failed to read the cgroup file", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+	defer f.Close()
+
+	counters := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			counters[fields[0]] = v
+		}
+	}
+	return counters, scanner.Err()
+}
+
+func counterDelta(previous, current uint64) uint64 {
+	if current < previous {
+		// the cgroup was recreated.
+		return current
+	}
+	return current - previous
+}
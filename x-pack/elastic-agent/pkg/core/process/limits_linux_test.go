@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitProcess(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	defer func(r string) { cgroupRoot = r }(cgroupRoot)
+	cgroupRoot = root
+
+	_, err = LimitProcess("default-filebeat", 42, Limits{CPU: 0.5})
+	require.Error(t, err, "a hierarchy without controllers is not the unified hierarchy")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory"), 0644))
+	limiter, err := LimitProcess("default-filebeat", 42, Limits{CPU: 0.5, Memory: 1024})
+	require.NoError(t, err)
+
+	dir := filepath.Join(root, agentCgroup, "default-filebeat")
+	assertFile(t, "50000 100000", filepath.Join(dir, "cpu.max"))
+	assertFile(t, "1024", filepath.Join(dir, "memory.max"))
+	assertFile(t, "42", filepath.Join(dir, "cgroup.procs"))
+	assertFile(t, "+cpu +memory", filepath.Join(root, agentCgroup, "cgroup.subtree_control"))
+
+	breach, err := limiter.Breach()
+	require.NoError(t, err)
+	assert.True(t, breach.IsZero())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 100\nnr_periods 10\nnr_throttled 2\n"), 0644))
+	breach, err = limiter.Breach()
+	require.NoError(t, err)
+	assert.Equal(t, Breach{MemoryLimited: 3, OOMKilled: 1, CPUThrottled: 2}, breach)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 200\nnr_periods 20\nnr_throttled 5\n"), 0644))
+	breach, err = limiter.Breach()
+	require.NoError(t, err)
+	assert.Equal(t, Breach{CPUThrottled: 3}, breach, "only the limits reached since the previous check are reported")
+
+	t.Run("a restarted process does not report the limits of the previous process", func(t *testing.T) {
+		limiter, err := LimitProcess("default-filebeat", 43, Limits{Memory: 1024})
+		require.NoError(t, err)
+		assertFile(t, "max 100000", filepath.Join(dir, "cpu.max"))
+
+		breach, err := limiter.Breach()
+		require.NoError(t, err)
+		assert.True(t, breach.IsZero())
+	})
+}
+
+func assertFile(t *testing.T, expected, path string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
@@ -0,0 +1,20 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package process
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// LimitProcess is not supported on this platform.
+func LimitProcess(id string, pid int, limits Limits) (Limiter, error) {
+	return nil, errors.New(fmt.Sprintf("resource limits are not supported on %s", runtime.GOOS), errors.TypeApplication)
+}
@@ -0,0 +1,46 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+)
+
+func TestLimitsOf(t *testing.T) {
+	cfg := DefaultConfig()
+	require.NoError(t, config.MustNewConfigFrom(map[string]interface{}{
+		"limits": map[string]interface{}{
+			"filebeat":   map[string]interface{}{"cpu": 0.5, "memory": "512MiB"},
+			"metricbeat": map[string]interface{}{"cpu": 1},
+		},
+	}).Unpack(cfg))
+
+	assert.Equal(t, Limits{CPU: 0.5, Memory: 512 * 1024 * 1024}, cfg.LimitsOf("filebeat"))
+	assert.True(t, cfg.LimitsOf("heartbeat").IsZero())
+
+	t.Run("limits of the policy replace the local limits", func(t *testing.T) {
+		require.NoError(t, cfg.Reload(config.MustNewConfigFrom(map[string]interface{}{
+			"agent.process.limits.filebeat.memory": "1GiB",
+		})))
+
+		assert.Equal(t, Limits{Memory: 1024 * 1024 * 1024}, cfg.LimitsOf("filebeat"))
+		assert.Equal(t, Limits{CPU: 1}, cfg.LimitsOf("metricbeat"))
+
+		require.NoError(t, cfg.Reload(config.MustNewConfigFrom(map[string]interface{}{})))
+		assert.Equal(t, Limits{CPU: 0.5, Memory: 512 * 1024 * 1024}, cfg.LimitsOf("filebeat"))
+	})
+
+	t.Run("negative limits are rejected", func(t *testing.T) {
+		err := config.MustNewConfigFrom(map[string]interface{}{
+			"limits.filebeat.cpu": -1,
+		}).Unpack(DefaultConfig())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build windows
+// +build windows
+
+package process
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+const (
+	jobObjectCPURateControlEnable  = 0x1
+	jobObjectCPURateControlHardCap = 0x4
+)
+
+// jobObjectCPURateControlInformation is JOBOBJECT_CPU_RATE_CONTROL_INFORMATION, the rate is the
+// percentage of the CPU cycles of all the CPUs multiplied by 100.
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32
+}
+
+// jobLimiter enforces the limits of a process with a Job Object.
+type jobLimiter struct {
+	job     windows.Handle
+	memory  uint64
+	reached bool
+}
+
+// LimitProcess assigns the process to a Job Object enforcing its limits.
+func LimitProcess(id string, pid int, limits Limits) (Limiter, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, errors.New(err, "failed to create the job object of the process", errors.TypeApplication)
+	}
+
+	if limits.Memory > 0 {
+		info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+		info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = uintptr(limits.Memory)
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+			windows.CloseHandle(job)
+			return nil, errors.New(err, "failed to set the memory limit of the process", errors.TypeApplication)
+		}
+	}
+
+	if limits.CPU > 0 {
+		rate := uint32(limits.CPU / float64(runtime.NumCPU()) * 10000)
+		if rate < 1 {
+			rate = 1
+		} else if rate > 10000 {
+			rate = 10000
+		}
+		info := jobObjectCPURateControlInformation{
+			ControlFlags: jobObjectCPURateControlEnable | jobObjectCPURateControlHardCap,
+			CPURate:      rate,
+		}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+			windows.CloseHandle(job)
+			return nil, errors.New(err, "failed to set the CPU limit of the process", errors.TypeApplication)
+		}
+	}
+
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, errors.New(err, "failed to open the process", errors.TypeApplication)
+	}
+	defer windows.CloseHandle(proc)
+
+	if err := windows.AssignProcessToJobObject(job, proc); err != nil {
+		windows.CloseHandle(job)
+		return nil, errors.New(err, "failed to assign the process to its job object", errors.TypeApplication)
+	}
+	return &jobLimiter{job: job, memory: uint64(limits.Memory)}, nil
+}
+
+// Breach reports the memory limit once the peak memory of the process reaches it, the throttling
+// of the CPU is not reported by the job object.
+func (l *jobLimiter) Breach() (Breach, error) {
+	if l.memory == 0 || l.reached {
+		return Breach{}, nil
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	if err := windows.QueryInformationJobObject(l.job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil); err != nil {
+		return Breach{}, errors.New(err, "failed to query the job object of the process", errors.TypeApplication)
+	}
+	if uint64(info.PeakProcessMemoryUsed) < l.memory {
+		return Breach{}, nil
+	}
+	l.reached = true
+	return Breach{MemoryLimited: 1}, nil
+}
+
+func (l *jobLimiter) Close() error {
+	return windows.CloseHandle(l.job)
+}
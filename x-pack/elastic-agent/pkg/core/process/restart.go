@@ -0,0 +1,56 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import "time"
+
+// RestartBudget tracks the crashes of a program to decide when it is restarted and when it is
+// given up on.
+type RestartBudget struct {
+	cfg     RestartConfig
+	crashes []time.Time // crashes within the window, oldest first.
+	delay   time.Duration
+}
+
+// NewRestartBudget creates a budget of restarts following the restart config.
+func NewRestartBudget(cfg RestartConfig) *RestartBudget {
+	return &RestartBudget{cfg: cfg}
+}
+
+// Config returns the restart config of the budget.
+func (b *RestartBudget) Config() RestartConfig {
+	return b.cfg
+}
+
+// Crashed records a crash at the given time, it returns the delay to wait before restarting the
+// program and false when the program crashed too many times within the window.
+func (b *RestartBudget) Crashed(now time.Time) (time.Duration, bool) {
+	i := 0
+	for i < len(b.crashes) && now.Sub(b.crashes[i]) > b.cfg.Window {
+		i++
+	}
+	b.crashes = append(b.crashes[i:], now)
+
+	if len(b.crashes) == 1 {
+		// the program was stable for the whole window.
+		b.delay = b.cfg.BackoffInit
+	} else {
+		b.delay *= 2
+		if b.delay > b.cfg.BackoffMax {
+			b.delay = b.cfg.BackoffMax
+		}
+	}
+
+	if b.cfg.MaxRestarts > 0 && len(b.crashes) > b.cfg.MaxRestarts {
+		return 0, false
+	}
+	return b.delay, true
+}
+
+// Reset forgets the previous crashes.
+func (b *RestartBudget) Reset() {
+	b.crashes = nil
+	b.delay = 0
+}
@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartBudget(t *testing.T) {
+	cfg := RestartConfig{
+		BackoffInit: time.Second,
+		BackoffMax:  5 * time.Second,
+		MaxRestarts: 4,
+		Window:      time.Minute,
+	}
+	now := time.Now()
+
+	t.Run("delay doubles up to the maximum", func(t *testing.T) {
+		b := NewRestartBudget(cfg)
+		for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+			delay, ok := b.Crashed(now)
+			assert.True(t, ok)
+			assert.Equal(t, expected, delay)
+		}
+	})
+
+	t.Run("budget exceeded within the window", func(t *testing.T) {
+		b := NewRestartBudget(cfg)
+		for i := 0; i < cfg.MaxRestarts; i++ {
+			_, ok := b.Crashed(now.Add(time.Duration(i) * time.Second))
+			assert.True(t, ok)
+		}
+		_, ok := b.Crashed(now.Add(10 * time.Second))
+		assert.False(t, ok)
+
+		b.Reset()
+		delay, ok := b.Crashed(now.Add(11 * time.Second))
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, delay)
+	})
+
+	t.Run("crashes out of the window are forgotten", func(t *testing.T) {
+		b := NewRestartBudget(cfg)
+		for i := 0; i < cfg.MaxRestarts; i++ {
+			_, ok := b.Crashed(now.Add(time.Duration(i) * time.Second))
+			assert.True(t, ok)
+		}
+		delay, ok := b.Crashed(now.Add(2 * time.Minute))
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, delay)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		unlimited := cfg
+		unlimited.MaxRestarts = 0
+		b := NewRestartBudget(unlimited)
+		for i := 0; i < 100; i++ {
+			_, ok := b.Crashed(now)
+			assert.True(t, ok)
+		}
+	})
+}
@@ -17,6 +17,8 @@ import (
 type Status int
 
 const (
+	// Quarantined is status describing application not started anymore after crashing too often.
+	Quarantined Status = -5
 	// Stopped is status describing not running application.
 	Stopped Status = -4
 	// Crashed is status describing application is crashed.
@@ -58,7 +60,7 @@ func (s Status) ToProto() proto.StateObserved_Status {
 	if s == Updating || s == Restarting {
 		return proto.StateObserved_STARTING
 	}
-	if s == Crashed {
+	if s == Crashed || s == Quarantined {
 		return proto.StateObserved_FAILED
 	}
 	if s == Stopped {
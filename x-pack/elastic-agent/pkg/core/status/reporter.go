@@ -5,7 +5,10 @@
 package status
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
@@ -162,6 +165,7 @@ func (r *controller) Status() AgentStatus {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 	apps := make([]AgentApplicationStatus, 0, len(r.appReporters))
+	var unhealthy []string
 	for key, rep := range r.appReporters {
 		rep.mx.Lock()
 		apps = append(apps, AgentApplicationStatus{
@@ -171,15 +175,40 @@ func (r *controller) Status() AgentStatus {
 			Message: rep.message,
 			Payload: rep.payload,
 		})
+		if msg, ok := unhealthyMessage(rep); ok {
+			unhealthy = append(unhealthy, msg)
+		}
+		rep.mx.Unlock()
+	}
+	for _, rep := range r.reporters {
+		rep.mx.Lock()
+		if msg, ok := unhealthyMessage(rep); ok {
+			unhealthy = append(unhealthy, msg)
+		}
 		rep.mx.Unlock()
 	}
+	sort.Strings(unhealthy)
+
 	return AgentStatus{
 		Status:       r.status,
-		Message:      "",
+		Message:      strings.Join(unhealthy, "; "),
 		Applications: apps,
 	}
 }
 
+// unhealthyMessage describes the reporter when it is degraded or failed, the caller must hold the
+// lock of the reporter.
+func unhealthyMessage(rep *reporter) (string, bool) {
+	s := statusToAgentStatus(rep.status)
+	if s == Healthy {
+		return "", false
+	}
+	if rep.message == "" {
+		return fmt.Sprintf("%s is %s", rep.name, s), true
+	}
+	return fmt.Sprintf("%s: %s", rep.name, rep.message), true
+}
+
 // StatusCode retrieves current agent status code.
 func (r *controller) StatusCode() AgentStatusCode {
 	r.mx.Lock()
@@ -98,4 +98,25 @@ func TestReporter(t *testing.T) {
 		assert.Equal(t, Degraded, r.StatusCode())
 		assert.Equal(t, "degraded", r.StatusString())
 	})
+
+	t.Run("message lists the unhealthy components", func(t *testing.T) {
+		r := NewController(l)
+		r1 := r.RegisterComponent("gateway")
+		r2 := r.RegisterComponent("r2")
+		a1 := r.RegisterApp("app-1", "filebeat")
+		a2 := r.RegisterApp("app-2", "metricbeat")
+
+		r1.Update(state.Failed, "failed to dispatch actions", nil)
+		r2.Update(state.Healthy, "", nil)
+		a1.Update(state.Degraded, "", nil)
+		a2.Update(state.Healthy, "", nil)
+
+		s := r.Status()
+		assert.Equal(t, Failed, s.Status)
+		assert.Equal(t, "filebeat is degraded; gateway: failed to dispatch actions", s.Message)
+
+		r1.Update(state.Healthy, "", nil)
+		a1.Update(state.Healthy, "", nil)
+		assert.Equal(t, "", r.Status().Message)
+	})
 }
@@ -0,0 +1,198 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package systemd notifies systemd of the readiness and the liveness of the agent when it runs as
+// a service of Type=notify, the notifications are ignored when the agent is not started by
+// systemd.
+package systemd
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+)
+
+var defaultNotifier = newNotifier(func(state string) (bool, error) {
+	return daemon.SdNotify(false, state)
+})
+
+// Ready notifies systemd that the agent is started, only the first call sends the notification.
+func Ready() {
+	defaultNotifier.Ready()
+}
+
+// Stopping notifies systemd that the agent is shutting down.
+func Stopping() {
+	defaultNotifier.notify(daemon.SdNotifyStopping)
+}
+
+// NewProbe registers a probe whose operations are checked by the watchdog.
+func NewProbe(name string) *Probe {
+	return defaultNotifier.NewProbe(name)
+}
+
+// RunWatchdog pings the systemd watchdog until ctx is cancelled, the pings stop as long as an
+// operation of a probe runs for longer than its timeout so systemd restarts a hung agent.
+//
+// RunWatchdog returns immediately when the watchdog of the service is not enabled.
+func RunWatchdog(ctx context.Context, log *logger.Logger) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.Errorf("Could not read the systemd watchdog settings: %s", err)
+		return
+	}
+	if interval <= 0 {
+		return
+	}
+
+	log.Infof("Systemd watchdog is enabled with a timeout of %s", interval)
+	defaultNotifier.runWatchdog(ctx, log, interval/2)
+}
+
+type notifier struct {
+	send      func(string) (bool, error)
+	now       func() time.Time
+	readyOnce sync.Once
+
+	mx     sync.Mutex
+	probes map[*Probe]struct{}
+}
+
+func newNotifier(send func(string) (bool, error)) *notifier {
+	return &notifier{
+		send:   send,
+		now:    time.Now,
+		probes: make(map[*Probe]struct{}),
+	}
+}
+
+func (n *notifier) Ready() {
+	n.readyOnce.Do(func() {
+		n.notify(daemon.SdNotifyReady)
+	})
+}
+
+func (n *notifier) notify(state string) {
+	// the error is not actionable and systemd reports a service which does not notify it as
+	// failing to start or hung.
+	_, _ = n.send(state)
+}
+
+func (n *notifier) NewProbe(name string) *Probe {
+	p := &Probe{
+		notifier:  n,
+		name:      name,
+		deadlines: make(map[uint64]time.Time),
+	}
+	n.mx.Lock()
+	n.probes[p] = struct{}{}
+	n.mx.Unlock()
+	return p
+}
+
+func (n *notifier) remove(p *Probe) {
+	n.mx.Lock()
+	delete(n.probes, p)
+	n.mx.Unlock()
+}
+
+// stuck returns the names of the probes with an operation running past its timeout.
+func (n *notifier) stuck() []string {
+	now := n.now()
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	var names []string
+	for p := range n.probes {
+		if p.stuck(now) {
+			names = append(names, p.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check pings the watchdog when no probe is stuck and returns the stuck probes.
+func (n *notifier) check() []string {
+	stuck := n.stuck()
+	if len(stuck) == 0 {
+		n.notify(daemon.SdNotifyWatchdog)
+	}
+	return stuck
+}
+
+func (n *notifier) runWatchdog(ctx context.Context, log *logger.Logger, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	wasStuck := false
+	for {
+		stuck := n.check()
+		if len(stuck) > 0 && !wasStuck {
+			log.Errorf("Systemd watchdog is not notified, %s did not complete in time", strings.Join(stuck, ", "))
+		} else if len(stuck) == 0 && wasStuck {
+			log.Info("Systemd watchdog is notified again")
+		}
+		wasStuck = len(stuck) > 0
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Probe tracks the operations of a loop of the agent, an operation running for longer than its
+// timeout means that the loop is hung.
+type Probe struct {
+	notifier *notifier
+	name     string
+
+	mx        sync.Mutex
+	next      uint64
+	deadlines map[uint64]time.Time
+}
+
+// Busy marks the start of an operation which is expected to complete within timeout, the
+// returned function marks its completion. An operation with no timeout is never stuck.
+func (p *Probe) Busy(timeout time.Duration) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	p.mx.Lock()
+	id := p.next
+	p.next++
+	p.deadlines[id] = p.notifier.now().Add(timeout)
+	p.mx.Unlock()
+
+	return func() {
+		p.mx.Lock()
+		delete(p.deadlines, id)
+		p.mx.Unlock()
+	}
+}
+
+// Close unregisters the probe from the watchdog.
+func (p *Probe) Close() {
+	p.notifier.remove(p)
+}
+
+func (p *Probe) stuck(now time.Time) bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for _, deadline := range p.deadlines {
+		if now.After(deadline) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package systemd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/stretchr/testify/assert"
+)
+
+type testNotifier struct {
+	*notifier
+	sent []string
+	now  time.Time
+}
+
+func newTestNotifier() *testNotifier {
+	n := &testNotifier{now: time.Now()}
+	n.notifier = newNotifier(func(state string) (bool, error) {
+		n.sent = append(n.sent, state)
+		return true, nil
+	})
+	n.notifier.now = func() time.Time { return n.now }
+	return n
+}
+
+func TestReady(t *testing.T) {
+	n := newTestNotifier()
+	n.Ready()
+	n.Ready()
+	assert.Equal(t, []string{daemon.SdNotifyReady}, n.sent)
+}
+
+func TestWatchdog(t *testing.T) {
+	n := newTestNotifier()
+	gateway := n.NewProbe("fleet gateway")
+	dispatcher := n.NewProbe("action dispatcher")
+
+	assert.Empty(t, n.check())
+	assert.Equal(t, []string{daemon.SdNotifyWatchdog}, n.sent)
+
+	done := gateway.Busy(time.Minute)
+	dispatcher.Busy(0)
+	n.now = n.now.Add(30 * time.Second)
+	assert.Empty(t, n.check())
+
+	n.now = n.now.Add(time.Minute)
+	assert.Equal(t, []string{"fleet gateway"}, n.check())
+	assert.Len(t, n.sent, 2)
+
+	done()
+	assert.Empty(t, n.check())
+	assert.Len(t, n.sent, 3)
+
+	t.Run("closed probe", func(t *testing.T) {
+		dispatcher.Busy(time.Minute)
+		dispatcher.Close()
+		n.now = n.now.Add(2 * time.Minute)
+		assert.Empty(t, n.check())
+	})
+}
@@ -16,6 +16,15 @@ import (
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
+)
+
+// Minimal options allowed in FIPS mode, as recommended by NIST SP 800-132 for PBKDF2.
+const (
+	fipsMinIterationsCount = 1000
+	fipsMinSaltLength      = 16
+	fipsMinKeyLength       = 16
 )
 
 // Option is the default options used to generate the encrypt and decrypt writer.
@@ -49,6 +58,18 @@ func (o *Option) Validate() error {
 		return errors.New("KeyLength must be superior to 0")
 	}
 
+	if fips.Enabled() {
+		if o.IterationsCount < fipsMinIterationsCount {
+			return fmt.Errorf("IterationsCount must be at least %d in FIPS mode", fipsMinIterationsCount)
+		}
+		if o.SaltLength < fipsMinSaltLength {
+			return fmt.Errorf("Salt length must be at least %d in FIPS mode", fipsMinSaltLength)
+		}
+		if o.KeyLength < fipsMinKeyLength {
+			return fmt.Errorf("KeyLength must be at least %d in FIPS mode", fipsMinKeyLength)
+		}
+	}
+
 	return nil
 }
 
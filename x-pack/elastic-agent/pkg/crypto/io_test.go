@@ -12,6 +12,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 )
 
 func TestIO(t *testing.T) {
@@ -194,3 +196,18 @@ func TestIO(t *testing.T) {
 		require.Equal(t, expected, content)
 	})
 }
+
+func TestOptionValidateFIPS(t *testing.T) {
+	weak := *DefaultOptions
+	weak.SaltLength = 8
+	require.NoError(t, weak.Validate())
+
+	fips.Configure(&fips.Config{Enabled: true})
+	defer fips.Configure(nil)
+
+	require.NoError(t, DefaultOptions.Validate())
+	require.Error(t, weak.Validate())
+
+	_, err := NewWriter(new(bytes.Buffer), []byte("hello"), &weak)
+	require.Error(t, err)
+}
@@ -0,0 +1,53 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package fips restricts the cryptography used by the agent to the algorithms approved by
+// FIPS 140-2. The mode is enabled by the agent.fips.enabled setting, an agent built with the
+// requirefips build tag always runs in this mode.
+//
+// In FIPS mode the TLS connections of the agent are restricted to TLS 1.2 with approved cipher
+// suites and curves, the signatures of the artifacts must use an approved hash and key, and the
+// options of the encrypted stores are checked against SP 800-132.
+package fips
+
+import (
+	"sync/atomic"
+)
+
+// Config is the configuration of the FIPS mode.
+type Config struct {
+	Enabled bool `config:"enabled" yaml:"enabled" json:"enabled"`
+}
+
+// DefaultConfig creates a config with the FIPS mode disabled unless the agent was built with
+// the requirefips build tag.
+func DefaultConfig() *Config {
+	return &Config{Enabled: required}
+}
+
+var enabled = boolToInt(required)
+
+// Configure enables the FIPS mode when it is enabled by the configuration, the mode cannot be
+// disabled by the configuration of an agent built with the requirefips build tag.
+func Configure(cfg *Config) {
+	on := required || (cfg != nil && cfg.Enabled)
+	atomic.StoreInt32(&enabled, boolToInt(on))
+}
+
+// Enabled returns true when the agent runs in FIPS mode.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// Required returns true when the agent was built with the requirefips build tag.
+func Required() bool {
+	return required
+}
+
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
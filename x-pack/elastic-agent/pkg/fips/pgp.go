@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fips
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// minRSABits is the minimal size of the RSA keys approved to verify signatures.
+const minRSABits = 2048
+
+var approvedHashes = map[crypto.Hash]struct{}{
+	crypto.SHA256: {},
+	crypto.SHA384: {},
+	crypto.SHA512: {},
+}
+
+// CheckSignature returns an error when the FIPS mode is enabled and the armored detached
+// signature of an artifact, or the key of its signer, uses an algorithm which is not approved.
+func CheckSignature(signer *openpgp.Entity, armored []byte) error {
+	if !Enabled() {
+		return nil
+	}
+
+	block, err := armor.Decode(bytes.NewReader(armored))
+	if err != nil {
+		return errors.New(err, "could not decode the signature", errors.TypeSecurity)
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return errors.New(err, "could not read the signature", errors.TypeSecurity)
+	}
+
+	var hash crypto.Hash
+	switch sig := p.(type) {
+	case *packet.Signature:
+		hash = sig.Hash
+	case *packet.SignatureV3:
+		hash = sig.Hash
+	default:
+		return errors.New("the signature of the artifact is not a signature packet", errors.TypeSecurity)
+	}
+	if _, ok := approvedHashes[hash]; !ok {
+		return errors.New(fmt.Sprintf("the signature of the artifact uses the hash %s which is not allowed in FIPS mode", hash), errors.TypeSecurity)
+	}
+
+	if signer == nil || signer.PrimaryKey == nil {
+		return nil
+	}
+	key := signer.PrimaryKey
+	switch key.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly:
+		bits, err := key.BitLength()
+		if err != nil {
+			return errors.New(err, "could not read the size of the signing key", errors.TypeSecurity)
+		}
+		if bits < minRSABits {
+			return errors.New(fmt.Sprintf("the signing key of %d bits is not allowed in FIPS mode, at least %d bits are required", bits, minRSABits), errors.TypeSecurity)
+		}
+	case packet.PubKeyAlgoECDSA:
+	default:
+		return errors.New(fmt.Sprintf("the algorithm %d of the signing key is not allowed in FIPS mode", key.PubKeyAlgo), errors.TypeSecurity)
+	}
+	return nil
+}
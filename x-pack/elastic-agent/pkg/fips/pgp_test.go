@@ -0,0 +1,41 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fips
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1" // registers SHA-1 to sign with a hash which is not approved
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func sign(t *testing.T, signer *openpgp.Entity, hash crypto.Hash) []byte {
+	var sig bytes.Buffer
+	err := openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader([]byte("artifact")), &packet.Config{DefaultHash: hash})
+	require.NoError(t, err)
+	return sig.Bytes()
+}
+
+func TestCheckSignature(t *testing.T) {
+	signer, err := openpgp.NewEntity("test", "", "test@elastic.co", nil)
+	require.NoError(t, err)
+
+	sha1Sig := sign(t, signer, crypto.SHA1)
+	assert.NoError(t, CheckSignature(signer, sha1Sig), "signatures are not checked when the FIPS mode is disabled")
+
+	enable(t)
+	assert.NoError(t, CheckSignature(signer, sign(t, signer, crypto.SHA512)))
+	assert.Error(t, CheckSignature(signer, sha1Sig))
+	assert.Error(t, CheckSignature(signer, []byte("not a signature")))
+
+	weak, err := openpgp.NewEntity("test", "", "test@elastic.co", &packet.Config{RSABits: 1024})
+	require.NoError(t, err)
+	assert.Error(t, CheckSignature(weak, sign(t, weak, crypto.SHA256)))
+}
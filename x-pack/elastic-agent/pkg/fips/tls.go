@@ -0,0 +1,153 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fips
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/transport/httpcommon"
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// approvedCipherSuites are the TLS 1.2 cipher suites approved by FIPS 140-2, the first ones are
+// used when no cipher suite is configured.
+var approvedCipherSuites = []tlscommon.CipherSuite{
+	tlscommon.CipherSuite(tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384),
+	tlscommon.CipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384),
+	tlscommon.CipherSuite(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256),
+	tlscommon.CipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256),
+	tlscommon.CipherSuite(tls.TLS_RSA_WITH_AES_256_GCM_SHA384),
+	tlscommon.CipherSuite(tls.TLS_RSA_WITH_AES_128_GCM_SHA256),
+}
+
+const defaultCipherSuites = 4
+
+var approvedCurves = map[tls.CurveID]struct{}{
+	tls.CurveP256: {},
+	tls.CurveP384: {},
+	tls.CurveP521: {},
+}
+
+// defaultTLS holds the curves used when no curve is configured, the type of the curves of the
+// TLS configuration is not exported so they are unpacked from their names.
+var defaultTLS = func() *tlscommon.Config {
+	var cfg tlscommon.Config
+	raw := common.MustNewConfigFrom(map[string]interface{}{
+		"curve_types": []string{"P-256", "P-384", "P-521"},
+	})
+	if err := raw.Unpack(&cfg); err != nil {
+		panic(err)
+	}
+	return &cfg
+}()
+
+// TLS returns the TLS configuration restricted to FIPS approved protocols and algorithms when
+// the FIPS mode is enabled, the protocols, cipher suites and curves left empty are set to their
+// approved values. An error is returned when the configuration requires an algorithm which is
+// not approved.
+//
+// The configuration is returned unchanged when the FIPS mode is disabled.
+func TLS(cfg *tlscommon.Config) (*tlscommon.Config, error) {
+	if !Enabled() {
+		return cfg, nil
+	}
+
+	restricted := &tlscommon.Config{}
+	if cfg != nil {
+		*restricted = *cfg
+	}
+	if !restricted.IsEnabled() {
+		return restricted, nil
+	}
+
+	if err := checkTLS(restricted); err != nil {
+		return nil, err
+	}
+
+	if len(restricted.Versions) == 0 {
+		restricted.Versions = []tlscommon.TLSVersion{tlscommon.TLSVersion12}
+	}
+	if len(restricted.CipherSuites) == 0 {
+		restricted.CipherSuites = append([]tlscommon.CipherSuite{}, approvedCipherSuites[:defaultCipherSuites]...)
+	}
+	if len(restricted.CurveTypes) == 0 {
+		restricted.CurveTypes = defaultTLS.CurveTypes
+	}
+	return restricted, nil
+}
+
+// CheckTLS returns an error when the FIPS mode is enabled and the TLS configuration requires an
+// algorithm which is not approved.
+func CheckTLS(cfg *tlscommon.Config) error {
+	_, err := TLS(cfg)
+	return err
+}
+
+func checkTLS(cfg *tlscommon.Config) error {
+	for _, v := range cfg.Versions {
+		// the cipher suites of TLS 1.3 cannot be restricted, only TLS 1.2 is allowed.
+		if v != tlscommon.TLSVersion12 {
+			return errorf("the protocol %s is not allowed in FIPS mode, only TLSv1.2 is supported", v)
+		}
+	}
+
+	for _, cs := range cfg.CipherSuites {
+		if !isApprovedCipherSuite(cs) {
+			return errorf("the cipher suite %s is not allowed in FIPS mode", cs)
+		}
+	}
+
+	for _, c := range cfg.CurveTypes {
+		if _, ok := approvedCurves[tls.CurveID(c)]; !ok {
+			return errorf("the curve %s is not allowed in FIPS mode", tls.CurveID(c))
+		}
+	}
+
+	if cfg.CATrustedFingerprint != "" {
+		fingerprint, err := hex.DecodeString(cfg.CATrustedFingerprint)
+		if err != nil || len(fingerprint) != sha256.Size {
+			return errorf("ca_trusted_fingerprint must be a SHA-256 fingerprint in FIPS mode")
+		}
+	}
+
+	for _, pin := range cfg.CASha256 {
+		fingerprint, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(fingerprint) != sha256.Size {
+			return errorf("ca_sha256 must only contain SHA-256 pins in FIPS mode")
+		}
+	}
+
+	return nil
+}
+
+func isApprovedCipherSuite(cs tlscommon.CipherSuite) bool {
+	for _, approved := range approvedCipherSuites {
+		if cs == approved {
+			return true
+		}
+	}
+	return false
+}
+
+func errorf(format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf(format, args...), errors.TypeConfig)
+}
+
+// HTTPTransport returns a copy of the HTTP transport settings with their TLS configuration
+// restricted by TLS.
+func HTTPTransport(settings httpcommon.HTTPTransportSettings) (httpcommon.HTTPTransportSettings, error) {
+	tlsCfg, err := TLS(settings.TLS)
+	if err != nil {
+		return settings, err
+	}
+	settings.TLS = tlsCfg
+	return settings, nil
+}
@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fips
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+)
+
+func enable(t *testing.T) {
+	Configure(&Config{Enabled: true})
+	t.Cleanup(func() { Configure(nil) })
+}
+
+func unpackTLS(t *testing.T, raw map[string]interface{}) *tlscommon.Config {
+	var cfg tlscommon.Config
+	require.NoError(t, common.MustNewConfigFrom(raw).Unpack(&cfg))
+	return &cfg
+}
+
+func TestTLS(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		cfg := unpackTLS(t, map[string]interface{}{"supported_protocols": []string{"TLSv1.1"}})
+		restricted, err := TLS(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, cfg, restricted)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		enable(t)
+		restricted, err := TLS(nil)
+		require.NoError(t, err)
+		assert.Equal(t, []tlscommon.TLSVersion{tlscommon.TLSVersion12}, restricted.Versions)
+		assert.Len(t, restricted.CipherSuites, defaultCipherSuites)
+		require.Len(t, restricted.CurveTypes, 3)
+		assert.Equal(t, tls.CurveP256, tls.CurveID(restricted.CurveTypes[0]))
+	})
+
+	t.Run("configuration is not modified", func(t *testing.T) {
+		enable(t)
+		cfg := unpackTLS(t, map[string]interface{}{"certificate_authorities": []string{"/ca.crt"}})
+		_, err := TLS(cfg)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Versions)
+		assert.Empty(t, cfg.CipherSuites)
+	})
+
+	t.Run("approved settings", func(t *testing.T) {
+		enable(t)
+		cfg := unpackTLS(t, map[string]interface{}{
+			"supported_protocols":    []string{"TLSv1.2"},
+			"cipher_suites":          []string{"ECDHE-RSA-AES-256-GCM-SHA384"},
+			"curve_types":            []string{"P-384"},
+			"ca_trusted_fingerprint": "3b24d33844d777b3fcb28fc2b3b9c2ccb2579bfa83d1f5e0df4ba4ee7c2a38a3",
+		})
+		restricted, err := TLS(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, cfg.CipherSuites, restricted.CipherSuites)
+		assert.Equal(t, cfg.CurveTypes, restricted.CurveTypes)
+	})
+
+	invalid := map[string]map[string]interface{}{
+		"protocol":     {"supported_protocols": []string{"TLSv1.1", "TLSv1.2"}},
+		"tls 1.3":      {"supported_protocols": []string{"TLSv1.3"}},
+		"cipher suite": {"cipher_suites": []string{"ECDHE-ECDSA-CHACHA20-POLY1305"}},
+		"curve":        {"curve_types": []string{"X25519"}},
+		"md5 fingerprint": {
+			"ca_trusted_fingerprint": "9e107d9d372bb6826bd81d3542a419d6",
+		},
+		"sha1 pin": {"ca_sha256": []string{"L9ThxnotKPzthJ7hu3bnORuT6xI="}},
+	}
+	for name, raw := range invalid {
+		t.Run("invalid "+name, func(t *testing.T) {
+			enable(t)
+			assert.Error(t, CheckTLS(unpackTLS(t, raw)))
+		})
+	}
+
+	t.Run("tls disabled", func(t *testing.T) {
+		enable(t)
+		assert.NoError(t, CheckTLS(unpackTLS(t, map[string]interface{}{
+			"enabled":             false,
+			"supported_protocols": []string{"TLSv1.1"},
+		})))
+	})
+}
@@ -90,27 +90,33 @@ func (e *AckCmd) Execute(ctx context.Context, r *AckRequest) (*AckResponse, erro
 			errors.TypeUnexpected)
 	}
 
+	reqID := newRequestID()
 	ap := fmt.Sprintf(ackPath, e.info.AgentID())
-	resp, err := e.client.Send(ctx, "POST", ap, nil, nil, bytes.NewBuffer(b))
+	resp, err := e.client.Send(ctx, "POST", ap, nil, withRequestID(nil, reqID), bytes.NewBuffer(b))
 	if err != nil {
 		return nil, errors.New(err,
-			"fail to ack to fleet",
+			fmt.Sprintf("fail to ack to fleet (request ID %s)", reqID),
 			errors.TypeNetwork,
-			errors.M(errors.MetaKeyURI, ap))
+			errors.M(errors.MetaKeyURI, ap),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, client.ExtractError(resp.Body)
+		return nil, errors.New(client.ExtractError(resp.Body),
+			fmt.Sprintf("ack request %s failed", reqID),
+			errors.M(errors.MetaKeyURI, ap),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	ackResponse := &AckResponse{}
 	decoder := json.NewDecoder(resp.Body)
 	if err := decoder.Decode(ackResponse); err != nil {
 		return nil, errors.New(err,
-			"fail to decode ack response",
+			fmt.Sprintf("fail to decode ack response (request ID %s)", reqID),
 			errors.TypeNetwork,
-			errors.M(errors.MetaKeyURI, ap))
+			errors.M(errors.MetaKeyURI, ap),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	if err := ackResponse.Validate(); err != nil {
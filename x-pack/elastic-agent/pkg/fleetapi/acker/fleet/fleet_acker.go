@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
@@ -27,6 +28,14 @@ type Acker struct {
 	log       *logger.Logger
 	client    client.Sender
 	agentInfo agentInfo
+
+	mx sync.Mutex
+	// store keeps the acks buffered while fleet is unreachable, nil when the acks are not buffered.
+	store ackStore
+	// pending are the acks not yet received by fleet, they are sent first with the next acks.
+	pending []fleetapi.AckEvent
+	// rejections counts the requests carrying the buffered acks which were rejected by fleet.
+	rejections int
 }
 
 // NewAcker creates a new fleet acker.
@@ -42,6 +51,25 @@ func NewAcker(
 	}, nil
 }
 
+// NewAckerWithStore creates a new fleet acker which buffers the acks in the store while fleet is
+// unreachable, the buffered acks are sent in order before the next acks or with the next commit.
+// Acks buffered by a previous run are loaded back.
+func NewAckerWithStore(
+	log *logger.Logger,
+	agentInfo agentInfo,
+	client client.Sender,
+	store ackStore,
+) (*Acker, error) {
+	acker, err := NewAcker(log, agentInfo, client)
+	if err != nil {
+		return nil, err
+	}
+
+	acker.store = store
+	acker.pending = acker.load()
+	return acker, nil
+}
+
 // SetClient sets client to be used for http communication.
 func (f *Acker) SetClient(c client.Sender) {
 	f.client = c
@@ -51,15 +79,11 @@ func (f *Acker) SetClient(c client.Sender) {
 func (f *Acker) Ack(ctx context.Context, action fleetapi.Action) error {
 	// checkin
 	agentID := f.agentInfo.AgentID()
-	cmd := fleetapi.NewAckCmd(f.agentInfo, f.client)
-	req := &fleetapi.AckRequest{
-		Events: []fleetapi.AckEvent{
-			constructEvent(action, agentID),
-		},
+	events := []fleetapi.AckEvent{
+		constructEvent(action, agentID),
 	}
 
-	_, err := cmd.Execute(ctx, req)
-	if err != nil {
+	if err := f.send(ctx, events); err != nil {
 		return errors.New(err, fmt.Sprintf("acknowledge action '%s' for elastic-agent '%s' failed", action.ID(), agentID), errors.TypeNetwork)
 	}
 
@@ -79,31 +103,77 @@ func (f *Acker) AckBatch(ctx context.Context, actions []fleetapi.Action) error {
 		ids = append(ids, action.ID())
 	}
 
-	if len(events) == 0 {
+	if len(events) > 0 {
+		f.log.Debugf("%d actions with ids '%s' acknowledging", len(ids), strings.Join(ids, ","))
+	}
+
+	if err := f.send(ctx, events); err != nil {
+		return errors.New(err, fmt.Sprintf("acknowledge %d actions '%v' for elastic-agent '%s' failed", len(actions), actions, agentID), errors.TypeNetwork)
+	}
+	return nil
+}
+
+// Commit commits ack actions, the acks buffered while fleet was unreachable are sent.
+func (f *Acker) Commit(ctx context.Context) error {
+	if err := f.send(ctx, nil); err != nil {
+		return errors.New(err, fmt.Sprintf("sending the buffered acknowledgments for elastic-agent '%s' failed", f.agentInfo.AgentID()), errors.TypeNetwork)
+	}
+	return nil
+}
+
+// send sends the buffered acks followed by the events. When fleet cannot be reached the events
+// are buffered and no error is returned, they are sent with the next acks or the next commit.
+func (f *Acker) send(ctx context.Context, events []fleetapi.AckEvent) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if len(events) == 0 && len(f.pending) == 0 {
 		// no events to send (nothing to do)
 		return nil
 	}
 
+	batch := append(f.pending[:len(f.pending):len(f.pending)], events...)
 	cmd := fleetapi.NewAckCmd(f.agentInfo, f.client)
 	req := &fleetapi.AckRequest{
-		Events: events,
+		Events: batch,
 	}
 
-	f.log.Debugf("%d actions with ids '%s' acknowledging", len(ids), strings.Join(ids, ","))
-
 	_, err := cmd.Execute(ctx, req)
-	if err != nil {
-		return errors.New(err, fmt.Sprintf("acknowledge %d actions '%v' for elastic-agent '%s' failed", len(actions), actions, agentID), errors.TypeNetwork)
+	if err == nil {
+		if len(f.pending) > 0 {
+			f.log.Infof("%d acknowledgments buffered while fleet was unreachable were sent", len(f.pending))
+			f.pending = nil
+			f.rejections = 0
+			f.persist()
+		}
+		return nil
 	}
-	return nil
-}
 
-// Commit commits ack actions.
-func (f *Acker) Commit(ctx context.Context) error {
+	if !isUnreachable(err) {
+		f.rejected()
+		return err
+	}
+	if f.store == nil || len(events) == 0 {
+		return err
+	}
+
+	f.buffer(events)
+	f.log.Warnf("fleet is unreachable, %d acknowledgments are buffered until the next successful checkin: %v", len(events), err)
 	return nil
 }
 
 func constructEvent(action fleetapi.Action, agentID string) fleetapi.AckEvent {
+	var failed *fleetapi.FailedAction
+	if a, ok := action.(*fleetapi.FailedAction); ok {
+		failed = a
+		action = a.Action
+	}
+	var dryRun *fleetapi.DryRunAction
+	if a, ok := action.(*fleetapi.DryRunAction); ok {
+		dryRun = a
+		action = a.Action
+	}
+
 	ackev := fleetapi.AckEvent{
 		EventType: "ACTION_RESULT",
 		SubType:   "ACKNOWLEDGED",
@@ -113,6 +183,27 @@ func constructEvent(action fleetapi.Action, agentID string) fleetapi.AckEvent {
 		Message:   fmt.Sprintf("Action '%s' of type '%s' acknowledged.", action.ID(), action.Type()),
 	}
 
+	if failed != nil {
+		ackev.Message = fmt.Sprintf("Action '%s' of type '%s' failed after %s.", action.ID(), action.Type(), failed.CompletedAt.Sub(failed.StartedAt))
+		ackev.Error = failed.Err.Error()
+		ackev.StartedAt = failed.StartedAt.Format(fleetTimeFormat)
+		ackev.CompletedAt = failed.CompletedAt.Format(fleetTimeFormat)
+	}
+
+	if dryRun != nil {
+		ackev.Message = fmt.Sprintf("Action '%s' of type '%s' validated without being applied.", action.ID(), action.Type())
+		ackev.ActionResponse = map[string]interface{}{
+			"dry_run":  true,
+			"programs": dryRun.Programs,
+		}
+	}
+
+	if a, ok := action.(*fleetapi.ActionDiagnostics); ok && a.Archive != "" {
+		ackev.ActionResponse = map[string]interface{}{
+			"archive": a.Archive,
+		}
+	}
+
 	if a, ok := action.(*fleetapi.ActionApp); ok {
 		ackev.ActionData = a.Data
 		ackev.ActionResponse = a.Response
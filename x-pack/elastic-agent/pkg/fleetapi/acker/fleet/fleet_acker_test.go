@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,6 +16,7 @@ import (
 	"net/url"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -119,6 +121,140 @@ func TestAcker_AckBatch(t *testing.T) {
 	}
 }
 
+func TestAcker_AckFailedAction(t *testing.T) {
+	type ackRequest struct {
+		Events []fleetapi.AckEvent `json:"events"`
+	}
+
+	log, _ := logger.New("fleet_acker", false)
+	client := newTestingClient()
+	agentInfo := &testAgentInfo{}
+	acker, err := NewAcker(log, agentInfo, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testID := "ack-test-action-id"
+	startedAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	completedAt := startedAt.Add(2 * time.Second)
+	testAction := fleetapi.NewFailedAction(&fleetapi.ActionUnknown{ActionID: testID}, errors.New("something is bad"), startedAt, completedAt)
+
+	ch := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		cr := &ackRequest{}
+		err = json.Unmarshal(content, &cr)
+		assert.NoError(t, err)
+
+		assert.EqualValues(t, 1, len(cr.Events))
+		assert.EqualValues(t, testID, cr.Events[0].ActionID)
+		assert.EqualValues(t, "something is bad", cr.Events[0].Error)
+		assert.EqualValues(t, startedAt.Format(fleetTimeFormat), cr.Events[0].StartedAt)
+		assert.EqualValues(t, completedAt.Format(fleetTimeFormat), cr.Events[0].CompletedAt)
+		assert.Contains(t, cr.Events[0].Message, "failed after 2s")
+
+		resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
+		return resp, nil
+	})
+
+	go func() {
+		for range ch {
+		}
+	}()
+
+	if err := acker.Ack(context.Background(), testAction); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcker_AckDryRunAction(t *testing.T) {
+	type ackRequest struct {
+		Events []fleetapi.AckEvent `json:"events"`
+	}
+
+	log, _ := logger.New("fleet_acker", false)
+	client := newTestingClient()
+	agentInfo := &testAgentInfo{}
+	acker, err := NewAcker(log, agentInfo, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testID := "ack-test-action-id"
+	programs := map[string]interface{}{
+		"default": map[string]interface{}{"filebeat": map[string]interface{}{"hello": "world"}},
+	}
+	testAction := fleetapi.NewDryRunAction(&fleetapi.ActionPolicyChange{ActionID: testID, ActionType: fleetapi.ActionTypePolicyChange}, programs)
+
+	ch := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		cr := &ackRequest{}
+		err = json.Unmarshal(content, &cr)
+		assert.NoError(t, err)
+
+		assert.EqualValues(t, 1, len(cr.Events))
+		assert.EqualValues(t, testID, cr.Events[0].ActionID)
+		assert.EqualValues(t, "", cr.Events[0].Error)
+		assert.Contains(t, cr.Events[0].Message, "validated without being applied")
+		assert.Equal(t, map[string]interface{}{"dry_run": true, "programs": programs}, cr.Events[0].ActionResponse)
+
+		resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
+		return resp, nil
+	})
+
+	go func() {
+		for range ch {
+		}
+	}()
+
+	if err := acker.Ack(context.Background(), testAction); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcker_AckDiagnosticsAction(t *testing.T) {
+	type ackRequest struct {
+		Events []fleetapi.AckEvent `json:"events"`
+	}
+
+	log, _ := logger.New("fleet_acker", false)
+	client := newTestingClient()
+	agentInfo := &testAgentInfo{}
+	acker, err := NewAcker(log, agentInfo, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testID := "ack-test-action-id"
+	archive := "/data/diagnostics/elastic-agent-diagnostics.zip"
+	testAction := &fleetapi.ActionDiagnostics{ActionID: testID, ActionType: fleetapi.ActionTypeDiagnostics, Archive: archive}
+
+	ch := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		cr := &ackRequest{}
+		err = json.Unmarshal(content, &cr)
+		assert.NoError(t, err)
+
+		assert.EqualValues(t, 1, len(cr.Events))
+		assert.EqualValues(t, testID, cr.Events[0].ActionID)
+		assert.Equal(t, map[string]interface{}{"archive": archive}, cr.Events[0].ActionResponse)
+
+		resp := wrapStrToResp(http.StatusOK, `{ "actions": [] }`)
+		return resp, nil
+	})
+
+	go func() {
+		for range ch {
+		}
+	}()
+
+	if err := acker.Ack(context.Background(), testAction); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestAcker_AckBatch_Empty(t *testing.T) {
 	log, _ := logger.New("fleet_acker", false)
 	client := newNotCalledClient()
@@ -143,6 +279,96 @@ func TestAcker_AckBatch_Empty(t *testing.T) {
 	}
 }
 
+func TestAcker_BufferWhileUnreachable(t *testing.T) {
+	type ackRequest struct {
+		Events []fleetapi.AckEvent `json:"events"`
+	}
+
+	log, _ := logger.New("fleet_acker", false)
+	client := newTestingClient()
+	agentInfo := &testAgentInfo{}
+	store := &memStore{}
+	acker, err := NewAckerWithStore(log, agentInfo, client, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	go func() {
+		for range ch {
+		}
+	}()
+
+	assert.NoError(t, acker.AckBatch(context.Background(), []fleetapi.Action{&fleetapi.ActionUnknown{ActionID: "first"}}))
+	assert.NoError(t, acker.Ack(context.Background(), &fleetapi.ActionUnknown{ActionID: "second"}))
+	assert.Error(t, acker.Commit(context.Background()))
+
+	// the buffered acks survive a restart of the agent.
+	acker, err = NewAckerWithStore(log, agentInfo, client, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []string
+	client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+		cr := &ackRequest{}
+		assert.NoError(t, json.NewDecoder(body).Decode(cr))
+		for _, e := range cr.Events {
+			received = append(received, e.ActionID)
+		}
+		return wrapStrToResp(http.StatusOK, `{ "actions": [] }`), nil
+	})
+
+	assert.NoError(t, acker.Ack(context.Background(), &fleetapi.ActionUnknown{ActionID: "third"}))
+	assert.Equal(t, []string{"first", "second", "third"}, received)
+
+	received = nil
+	assert.NoError(t, acker.Commit(context.Background()))
+	assert.Empty(t, received)
+	assert.Equal(t, "[]", store.data)
+}
+
+func TestAcker_DropRejectedBufferedAcks(t *testing.T) {
+	log, _ := logger.New("fleet_acker", false)
+	client := newTestingClient()
+	agentInfo := &testAgentInfo{}
+	store := &memStore{data: `[{"type":"ACTION_RESULT","subtype":"ACKNOWLEDGED","timestamp":"","action_id":"bad","agent_id":"agent-secret"}]`}
+	acker, err := NewAckerWithStore(log, agentInfo, client, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := client.Answer(func(headers http.Header, body io.Reader) (*http.Response, error) {
+		return wrapStrToResp(http.StatusBadRequest, `{"statusCode": 400, "error": "bad request"}`), nil
+	})
+	go func() {
+		for range ch {
+		}
+	}()
+
+	for i := 0; i < maxAckRejections; i++ {
+		assert.Error(t, acker.Commit(context.Background()))
+	}
+	assert.Empty(t, acker.pending)
+	assert.NoError(t, acker.Commit(context.Background()))
+}
+
+type memStore struct {
+	data string
+}
+
+func (m *memStore) Save(in io.Reader) error {
+	data, err := ioutil.ReadAll(in)
+	m.data = string(data)
+	return err
+}
+
+func (m *memStore) Load() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewBufferString(m.data)), nil
+}
+
 type clientCallbackFunc func(headers http.Header, body io.Reader) (*http.Response, error)
 
 type testingClient struct {
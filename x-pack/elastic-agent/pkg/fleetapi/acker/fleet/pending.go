@@ -0,0 +1,138 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
+)
+
+const (
+	// maxPendingAcks is the number of acks buffered while fleet is unreachable, the oldest acks
+	// are dropped first.
+	maxPendingAcks = 1000
+
+	// maxAckRejections is the number of times fleet can reject the buffered acks before they are dropped.
+	maxAckRejections = 3
+)
+
+type ackStore interface {
+	Save(io.Reader) error
+	Load() (io.ReadCloser, error)
+}
+
+// buffer appends the events to the pending acks and persists them, an event acknowledging an
+// action already pending is not buffered again. Must be called with the acker locked.
+func (f *Acker) buffer(events []fleetapi.AckEvent) {
+	for _, e := range events {
+		if f.isPending(e) {
+			continue
+		}
+		f.pending = append(f.pending, e)
+	}
+
+	if dropped := len(f.pending) - maxPendingAcks; dropped > 0 {
+		f.log.Warnf("fleet acker dropped the %d oldest buffered acknowledgments, at most %d are kept", dropped, maxPendingAcks)
+		f.pending = append([]fleetapi.AckEvent(nil), f.pending[dropped:]...)
+	}
+	f.persist()
+}
+
+func (f *Acker) isPending(e fleetapi.AckEvent) bool {
+	for _, p := range f.pending {
+		if p.ActionID == e.ActionID && p.SubType == e.SubType && p.Error == e.Error {
+			return true
+		}
+	}
+	return false
+}
+
+// rejected records a rejection by fleet of a request carrying the buffered acks, they are dropped
+// once rejected too many times so they do not block the next acks. Must be called with the acker
+// locked.
+func (f *Acker) rejected() {
+	if len(f.pending) == 0 {
+		return
+	}
+
+	f.rejections++
+	if f.rejections < maxAckRejections {
+		return
+	}
+
+	f.log.Warnf("fleet acker dropped %d buffered acknowledgments rejected %d times by fleet", len(f.pending), f.rejections)
+	f.pending = nil
+	f.rejections = 0
+	f.persist()
+}
+
+// load returns the acks persisted in the store, if the store cannot be read we start without
+// buffered acks.
+func (f *Acker) load() []fleetapi.AckEvent {
+	if f.store == nil {
+		return nil
+	}
+
+	reader, err := f.store.Load()
+	if err != nil {
+		f.log.Errorf("fleet acker failed to load buffered acknowledgments: %v", err)
+		return nil
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		f.log.Errorf("fleet acker failed to read buffered acknowledgments: %v", err)
+		return nil
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	var pending []fleetapi.AckEvent
+	if err := json.Unmarshal(data, &pending); err != nil {
+		f.log.Errorf("fleet acker failed to decode buffered acknowledgments: %v", err)
+		return nil
+	}
+
+	if len(pending) > 0 {
+		f.log.Infof("fleet acker restored %d acknowledgments buffered while fleet was unreachable", len(pending))
+	}
+	return pending
+}
+
+// persist saves the pending acks into the store, must be called with the acker locked.
+func (f *Acker) persist() {
+	if f.store == nil {
+		return
+	}
+
+	pending := f.pending
+	if pending == nil {
+		pending = []fleetapi.AckEvent{}
+	}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		f.log.Errorf("fleet acker failed to encode buffered acknowledgments: %v", err)
+		return
+	}
+
+	if err := f.store.Save(bytes.NewReader(data)); err != nil {
+		f.log.Errorf("fleet acker failed to persist buffered acknowledgments: %v", err)
+	}
+}
+
+// isUnreachable returns true when the acks did not reach fleet, the acks rejected by fleet are not
+// buffered.
+func isUnreachable(err error) bool {
+	var agentErr errors.Error
+	return errors.As(err, &agentErr) && agentErr.Type() == errors.TypeNetwork
+}
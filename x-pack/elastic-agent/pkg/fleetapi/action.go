@@ -7,7 +7,9 @@ package fleetapi
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 
@@ -27,6 +29,10 @@ const (
 	ActionTypeSettings = "SETTINGS"
 	// ActionTypeInputAction specifies agent action.
 	ActionTypeInputAction = "INPUT_ACTION"
+	// ActionTypeDiagnostics specifies a request to collect a diagnostics archive.
+	ActionTypeDiagnostics = "REQUEST_DIAGNOSTICS"
+	// ActionTypeUnquarantine specifies a request to start again the programs quarantined after crashing too often.
+	ActionTypeUnquarantine = "UNQUARANTINE"
 )
 
 // Action base interface for all the implemented action from the fleet API.
@@ -104,6 +110,10 @@ type ActionPolicyChange struct {
 	ActionID   string
 	ActionType string
 	Policy     map[string]interface{} `json:"policy"`
+	Signed     *Signed                `json:"signed,omitempty"`
+
+	// DryRun requests the policy to be validated and rendered without being applied.
+	DryRun bool `json:"dry_run,omitempty"`
 }
 
 func (a *ActionPolicyChange) String() string {
@@ -125,6 +135,32 @@ func (a *ActionPolicyChange) ID() string {
 	return a.ActionID
 }
 
+// Revision returns the ID and the revision of the policy, ok is false when the policy has no
+// revision.
+func (a *ActionPolicyChange) Revision() (policyID string, revision int64, ok bool) {
+	return PolicyRevision(a.Policy)
+}
+
+// PolicyRevision returns the ID and the revision of a policy, ok is false when the policy has no
+// revision.
+func PolicyRevision(policy map[string]interface{}) (policyID string, revision int64, ok bool) {
+	if id, found := policy["id"]; found && id != nil {
+		policyID = fmt.Sprint(id)
+	}
+
+	switch v := policy["revision"].(type) {
+	case int:
+		return policyID, int64(v), true
+	case int64:
+		return policyID, v, true
+	case uint64:
+		return policyID, int64(v), true
+	case float64:
+		return policyID, int64(v), true
+	}
+	return policyID, 0, false
+}
+
 // ActionUpgrade is a request for agent to upgrade.
 type ActionUpgrade struct {
 	ActionID   string `json:"id" yaml:"id"`
@@ -183,6 +219,46 @@ type ActionSettings struct {
 	ActionID   string
 	ActionType string
 	LogLevel   string `json:"log_level"`
+	// ApplyToProcesses restarts the processes spawned by the agent so they log at the new level,
+	// otherwise only the agent changes its level.
+	ApplyToProcesses bool `json:"apply_to_processes"`
+	AgentSettings
+}
+
+// AgentSettings are the per-agent settings changed by Fleet that replace the settings of the
+// policy, a nil or empty setting keeps its current value.
+type AgentSettings struct {
+	Monitoring *MonitoringSettings `json:"monitoring,omitempty" yaml:"monitoring,omitempty"`
+	Reporting  *ReportingSettings  `json:"reporting,omitempty" yaml:"reporting,omitempty"`
+	Gateway    *GatewaySettings    `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+}
+
+// IsZero returns true when no setting is changed.
+func (s AgentSettings) IsZero() bool {
+	return s.Monitoring == nil && s.Reporting == nil && s.Gateway == nil
+}
+
+// MonitoringSettings toggles the self-monitoring of the agent.
+type MonitoringSettings struct {
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Logs    *bool `json:"logs,omitempty" yaml:"logs,omitempty"`
+	Metrics *bool `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+}
+
+// ReportingSettings changes the events reported to Fleet.
+type ReportingSettings struct {
+	// MinSeverity is the minimum severity of the events reported to Fleet, info|warning|error.
+	MinSeverity string `json:"min_severity,omitempty" yaml:"min_severity,omitempty"`
+}
+
+// GatewaySettings changes the timing of the checkins, the durations are in the format of
+// time.ParseDuration.
+type GatewaySettings struct {
+	// CheckinFrequency is the time between checkins, it takes precedence over the frequency
+	// suggested by fleet-server.
+	CheckinFrequency string `json:"checkin_frequency,omitempty" yaml:"checkin_frequency,omitempty"`
+	// Jitter is the maximum random delay added to the time between checkins.
+	Jitter string `json:"jitter,omitempty" yaml:"jitter,omitempty"`
 }
 
 // ID returns the ID of the Action.
@@ -203,6 +279,87 @@ func (a *ActionSettings) String() string {
 	s.WriteString(a.ActionType)
 	s.WriteString(", log_level: ")
 	s.WriteString(a.LogLevel)
+	s.WriteString(", apply_to_processes: ")
+	s.WriteString(strconv.FormatBool(a.ApplyToProcesses))
+	if m := a.Monitoring; m != nil {
+		s.WriteString(", monitoring: ")
+		s.WriteString(fmt.Sprintf("{enabled: %s, logs: %s, metrics: %s}", formatOptionalBool(m.Enabled), formatOptionalBool(m.Logs), formatOptionalBool(m.Metrics)))
+	}
+	if r := a.Reporting; r != nil {
+		s.WriteString(", reporting.min_severity: ")
+		s.WriteString(r.MinSeverity)
+	}
+	if g := a.Gateway; g != nil {
+		s.WriteString(", gateway: ")
+		s.WriteString(fmt.Sprintf("{checkin_frequency: %s, jitter: %s}", g.CheckinFrequency, g.Jitter))
+	}
+	return s.String()
+}
+
+func formatOptionalBool(b *bool) string {
+	if b == nil {
+		return "unchanged"
+	}
+	return strconv.FormatBool(*b)
+}
+
+// ActionDiagnostics is a request to collect a diagnostics archive on the host of the agent.
+type ActionDiagnostics struct {
+	ActionID   string
+	ActionType string
+
+	// Archive is the path of the collected archive, it is reported to Fleet when the action is
+	// acknowledged.
+	Archive string `json:"-"`
+}
+
+// ID returns the ID of the Action.
+func (a *ActionDiagnostics) ID() string {
+	return a.ActionID
+}
+
+// Type returns the type of the Action.
+func (a *ActionDiagnostics) Type() string {
+	return a.ActionType
+}
+
+func (a *ActionDiagnostics) String() string {
+	var s strings.Builder
+	s.WriteString("action_id: ")
+	s.WriteString(a.ActionID)
+	s.WriteString(", type: ")
+	s.WriteString(a.ActionType)
+	return s.String()
+}
+
+// ActionUnquarantine is a request to start again the programs quarantined after crashing too
+// often.
+type ActionUnquarantine struct {
+	ActionID   string
+	ActionType string
+	// Program is the name of the quarantined program, all the quarantined programs are released
+	// when empty.
+	Program string `json:"program"`
+}
+
+// ID returns the ID of the Action.
+func (a *ActionUnquarantine) ID() string {
+	return a.ActionID
+}
+
+// Type returns the type of the Action.
+func (a *ActionUnquarantine) Type() string {
+	return a.ActionType
+}
+
+func (a *ActionUnquarantine) String() string {
+	var s strings.Builder
+	s.WriteString("action_id: ")
+	s.WriteString(a.ActionID)
+	s.WriteString(", type: ")
+	s.WriteString(a.ActionType)
+	s.WriteString(", program: ")
+	s.WriteString(a.Program)
 	return s.String()
 }
 
@@ -247,6 +404,49 @@ func (a *ActionApp) MarshalMap() (map[string]interface{}, error) {
 	return res, err
 }
 
+// FailedAction wraps an action which could not be executed by the agent, the error is reported
+// to Fleet when the action is acknowledged.
+type FailedAction struct {
+	Action
+	Err         error
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+// NewFailedAction returns an action to acknowledge carrying the error of the execution and the
+// time range during which the agent tried to execute it.
+func NewFailedAction(a Action, err error, startedAt, completedAt time.Time) *FailedAction {
+	return &FailedAction{Action: a, Err: err, StartedAt: startedAt, CompletedAt: completedAt}
+}
+
+func (a *FailedAction) String() string {
+	var s strings.Builder
+	s.WriteString(a.Action.String())
+	s.WriteString(", error: ")
+	s.WriteString(a.Err.Error())
+	return s.String()
+}
+
+// DryRunAction wraps a policy change which was validated and rendered without being applied, the
+// programs rendered from the policy are reported to Fleet when the action is acknowledged.
+type DryRunAction struct {
+	Action
+	Programs map[string]interface{}
+}
+
+// NewDryRunAction returns an action to acknowledge carrying the programs rendered from the policy,
+// keyed by output and by program name.
+func NewDryRunAction(a Action, programs map[string]interface{}) *DryRunAction {
+	return &DryRunAction{Action: a, Programs: programs}
+}
+
+func (a *DryRunAction) String() string {
+	var s strings.Builder
+	s.WriteString(a.Action.String())
+	s.WriteString(", dry run")
+	return s.String()
+}
+
 // Actions is a list of Actions to executes and allow to unmarshal heterogenous action type.
 type Actions []Action
 
@@ -317,11 +517,41 @@ func (a *Actions) UnmarshalJSON(data []byte) error {
 					"fail to decode SETTINGS_ACTION action",
 					errors.TypeConfig)
 			}
+		case ActionTypeDiagnostics:
+			action = &ActionDiagnostics{
+				ActionID:   response.ActionID,
+				ActionType: response.ActionType,
+			}
+		case ActionTypeUnquarantine:
+			action = &ActionUnquarantine{
+				ActionID:   response.ActionID,
+				ActionType: response.ActionType,
+			}
+
+			if len(response.Data) > 0 {
+				if err := json.Unmarshal(response.Data, action); err != nil {
+					return errors.New(err,
+						"fail to decode UNQUARANTINE action",
+						errors.TypeConfig)
+				}
+			}
 		default:
-			action = &ActionUnknown{
-				ActionID:     response.ActionID,
-				ActionType:   "UNKNOWN",
-				originalType: response.ActionType,
+			decoder, ok := registeredDecoder(response.ActionType)
+			if !ok {
+				action = &ActionUnknown{
+					ActionID:     response.ActionID,
+					ActionType:   "UNKNOWN",
+					originalType: response.ActionType,
+				}
+				break
+			}
+
+			var err error
+			action, err = decoder(response.ActionID, response.Data)
+			if err != nil {
+				return errors.New(err,
+					fmt.Sprintf("fail to decode %s action", response.ActionType),
+					errors.TypeConfig)
 			}
 		}
 		actions = append(actions, action)
@@ -0,0 +1,64 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ActionDecoder creates the action of a type registered with RegisterActionType from the ID of
+// the action and the data sent by fleet.
+type ActionDecoder func(id string, data json.RawMessage) (Action, error)
+
+// builtinActionTypes are the action types decoded by the agent itself, they cannot be registered.
+var builtinActionTypes = map[string]bool{
+	ActionTypeUpgrade:        true,
+	ActionTypeUnenroll:       true,
+	ActionTypePolicyChange:   true,
+	ActionTypePolicyReassign: true,
+	ActionTypeSettings:       true,
+	ActionTypeInputAction:    true,
+	ActionTypeDiagnostics:    true,
+	ActionTypeUnquarantine:   true,
+}
+
+var actionDecoders = struct {
+	sync.RWMutex
+	decoders map[string]ActionDecoder
+}{decoders: make(map[string]ActionDecoder)}
+
+// RegisterActionType registers the decoder of an action type not known by the agent, this is
+// meant to be called at init time by the packages handling their own actions. Actions of a type
+// without decoder are decoded as unknown actions.
+func RegisterActionType(actionType string, decoder ActionDecoder) error {
+	if builtinActionTypes[actionType] {
+		return fmt.Errorf("action type '%s' is handled by the agent and cannot be registered", actionType)
+	}
+
+	actionDecoders.Lock()
+	defer actionDecoders.Unlock()
+	if _, ok := actionDecoders.decoders[actionType]; ok {
+		return fmt.Errorf("action type '%s' is already registered", actionType)
+	}
+	actionDecoders.decoders[actionType] = decoder
+	return nil
+}
+
+// MustRegisterActionType registers the decoder of an action type not known by the agent.
+// Panics if not successful.
+func MustRegisterActionType(actionType string, decoder ActionDecoder) {
+	if err := RegisterActionType(actionType, decoder); err != nil {
+		panic("could not register action type, error: " + err.Error())
+	}
+}
+
+func registeredDecoder(actionType string) (ActionDecoder, bool) {
+	actionDecoders.RLock()
+	defer actionDecoders.RUnlock()
+	decoder, ok := actionDecoders.decoders[actionType]
+	return decoder, ok
+}
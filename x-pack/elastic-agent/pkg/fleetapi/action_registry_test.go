@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRegisteredAction struct {
+	ActionID string
+	Command  string `json:"command"`
+}
+
+func (a *testRegisteredAction) Type() string   { return "TEST_REGISTERED" }
+func (a *testRegisteredAction) ID() string     { return a.ActionID }
+func (a *testRegisteredAction) String() string { return "action_id: " + a.ActionID }
+
+func TestRegisterActionType(t *testing.T) {
+	require.NoError(t, RegisterActionType("TEST_REGISTERED", func(id string, data json.RawMessage) (Action, error) {
+		a := &testRegisteredAction{ActionID: id}
+		if err := json.Unmarshal(data, a); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}))
+	defer func() {
+		actionDecoders.Lock()
+		delete(actionDecoders.decoders, "TEST_REGISTERED")
+		actionDecoders.Unlock()
+	}()
+
+	t.Run("registered actions are decoded by their decoder", func(t *testing.T) {
+		var actions Actions
+		err := json.Unmarshal([]byte(`[
+			{ "id": "1", "type": "TEST_REGISTERED", "data": { "command": "isolate" } },
+			{ "id": "2", "type": "NOT_REGISTERED", "data": { "command": "isolate" } }
+		]`), &actions)
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+
+		require.Equal(t, &testRegisteredAction{ActionID: "1", Command: "isolate"}, actions[0])
+		unknown, ok := actions[1].(*ActionUnknown)
+		require.True(t, ok)
+		require.Equal(t, "NOT_REGISTERED", unknown.OriginalType())
+	})
+
+	t.Run("decoding errors are returned", func(t *testing.T) {
+		var actions Actions
+		err := json.Unmarshal([]byte(`[{ "id": "1", "type": "TEST_REGISTERED", "data": "isolate" }]`), &actions)
+		require.Error(t, err)
+	})
+
+	t.Run("types cannot be registered twice", func(t *testing.T) {
+		err := RegisterActionType("TEST_REGISTERED", func(string, json.RawMessage) (Action, error) { return nil, nil })
+		require.Error(t, err)
+	})
+
+	t.Run("builtin types cannot be registered", func(t *testing.T) {
+		err := RegisterActionType(ActionTypeUpgrade, func(string, json.RawMessage) (Action, error) { return nil, nil })
+		require.Error(t, err)
+	})
+}
@@ -63,6 +63,53 @@ func TestActionSerialization(t *testing.T) {
 	}
 }
 
+func TestActionUnquarantineDecoding(t *testing.T) {
+	var actions Actions
+	err := json.Unmarshal([]byte(`[
+		{"id": "1", "type": "UNQUARANTINE", "data": {"program": "filebeat"}},
+		{"id": "2", "type": "UNQUARANTINE"}
+	]`), &actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := cmp.Diff(Actions{
+		&ActionUnquarantine{ActionID: "1", ActionType: ActionTypeUnquarantine, Program: "filebeat"},
+		&ActionUnquarantine{ActionID: "2", ActionType: ActionTypeUnquarantine},
+	}, actions)
+	if diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestActionSettingsDecoding(t *testing.T) {
+	var actions Actions
+	err := json.Unmarshal([]byte(`[
+		{"id": "1", "type": "SETTINGS", "data": {"log_level": "debug"}},
+		{"id": "2", "type": "SETTINGS", "data": {
+			"monitoring": {"enabled": false},
+			"reporting": {"min_severity": "warning"},
+			"gateway": {"checkin_frequency": "5m", "jitter": "30s"}
+		}}
+	]`), &actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := false
+	diff := cmp.Diff(Actions{
+		&ActionSettings{ActionID: "1", ActionType: ActionTypeSettings, LogLevel: "debug"},
+		&ActionSettings{ActionID: "2", ActionType: ActionTypeSettings, AgentSettings: AgentSettings{
+			Monitoring: &MonitoringSettings{Enabled: &disabled},
+			Reporting:  &ReportingSettings{MinSeverity: "warning"},
+			Gateway:    &GatewaySettings{CheckinFrequency: "5m", Jitter: "30s"},
+		}},
+	}, actions)
+	if diff != "" {
+		t.Error(diff)
+	}
+}
+
 func mapStringVal(m map[string]interface{}, key string) string {
 	if v, ok := m[key]; ok {
 		if s, ok := v.(string); ok {
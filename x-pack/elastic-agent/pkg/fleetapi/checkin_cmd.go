@@ -23,9 +23,23 @@ const checkingPath = "/api/fleet/agents/%s/checkin"
 // CheckinRequest consists of multiple events reported to fleet ui.
 type CheckinRequest struct {
 	Status   string              `json:"status"`
+	Message  string              `json:"message,omitempty"`
 	AckToken string              `json:"ack_token,omitempty"`
 	Events   []SerializableEvent `json:"events"`
 	Metadata *info.ECSMeta       `json:"local_metadata,omitempty"`
+	Tags     []string            `json:"tags,omitempty"`
+
+	// EventSchemaVersion is the version of the schema of the events of the request.
+	EventSchemaVersion int `json:"event_schema_version,omitempty"`
+
+	// PolicyID and PolicyRevision identify the last policy applied by the agent, Fleet does not
+	// need to send this revision again.
+	PolicyID       string `json:"agent_policy_id,omitempty"`
+	PolicyRevision int64  `json:"policy_revision_idx,omitempty"`
+
+	// PollTimeout asks Fleet to answer within the duration instead of holding the checkin for the
+	// whole long poll.
+	PollTimeout string `json:"poll_timeout,omitempty"`
 }
 
 // SerializableEvent is a representation of the event to be send to the Fleet Server API via the checkin
@@ -53,6 +67,34 @@ func (e *CheckinRequest) Validate() error {
 type CheckinResponse struct {
 	AckToken string  `json:"ack_token"`
 	Actions  Actions `json:"actions"`
+
+	// CheckinFrequencySec is the time in seconds suggested by the server between two checkins,
+	// zero means the agent keeps its current checkin frequency.
+	CheckinFrequencySec int `json:"checkin_frequency_sec,omitempty"`
+
+	// RejectedEvents are the events of the request which were not accepted by the server, the
+	// other events of the request are acknowledged.
+	RejectedEvents []RejectedEvent `json:"rejected_events,omitempty"`
+
+	// EventSchemaVersion is the most recent version of the schema of the events supported by the
+	// server, the agent downgrades its events when it is older than the version of the agent. Zero
+	// when the server does not negotiate the version.
+	EventSchemaVersion int `json:"event_schema_version,omitempty"`
+
+	// Host is the fleet-server host which served the checkin.
+	Host string `json:"-"`
+
+	// ServerTime is the time of fleet-server when it answered the checkin, taken from the Date
+	// header of the response. It is zero when the header is missing or invalid.
+	ServerTime time.Time `json:"-"`
+}
+
+// RejectedEvent identifies an event of a checkin request rejected by the server.
+type RejectedEvent struct {
+	// Index is the position of the event in the events of the request.
+	Index int `json:"index"`
+	// Reason explains why the event was rejected.
+	Reason string `json:"reason,omitempty"`
 }
 
 // Validate validates the response send from the server.
@@ -91,37 +133,57 @@ func (e *CheckinCmd) Execute(ctx context.Context, r *CheckinRequest) (*CheckinRe
 			errors.TypeUnexpected)
 	}
 
+	reqID := newRequestID()
 	cp := fmt.Sprintf(checkingPath, e.info.AgentID())
-	resp, err := e.client.Send(ctx, "POST", cp, nil, nil, bytes.NewBuffer(b))
+	resp, err := e.client.Send(ctx, "POST", cp, nil, withRequestID(nil, reqID), bytes.NewBuffer(b))
 	if err != nil {
 		return nil, errors.New(err,
-			"fail to checkin to fleet-server",
+			fmt.Sprintf("fail to checkin to fleet-server (request ID %s)", reqID),
 			errors.TypeNetwork,
-			errors.M(errors.MetaKeyURI, cp))
+			errors.M(errors.MetaKeyURI, cp),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, newThrottledError(resp, reqID, time.Now())
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, client.ExtractError(resp.Body)
+		return nil, errors.New(client.ExtractError(resp.Body),
+			fmt.Sprintf("checkin request %s failed", reqID),
+			errors.M(errors.MetaKeyURI, cp),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	rs, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New(err, "failed to read checkin response")
+		return nil, errors.New(err,
+			fmt.Sprintf("failed to read checkin response (request ID %s)", reqID),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	checkinResponse := &CheckinResponse{}
 	decoder := json.NewDecoder(bytes.NewReader(rs))
 	if err := decoder.Decode(checkinResponse); err != nil {
 		return nil, errors.New(err,
-			"fail to decode checkin response",
+			fmt.Sprintf("fail to decode checkin response (request ID %s)", reqID),
 			errors.TypeNetwork,
-			errors.M(errors.MetaKeyURI, cp))
+			errors.M(errors.MetaKeyURI, cp),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	if err := checkinResponse.Validate(); err != nil {
 		return nil, err
 	}
 
+	if resp.Request != nil && resp.Request.URL != nil {
+		checkinResponse.Host = resp.Request.URL.Host
+	}
+	if date := resp.Header.Get("Date"); date != "" {
+		if serverTime, err := http.ParseTime(date); err == nil {
+			checkinResponse.ServerTime = serverTime
+		}
+	}
 	return checkinResponse, nil
 }
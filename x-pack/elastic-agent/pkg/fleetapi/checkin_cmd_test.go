@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -52,6 +53,32 @@ func TestCheckin(t *testing.T) {
 		},
 	))
 
+	t.Run("Errors carry the request ID", func(t *testing.T) {
+		requestIDs := make(chan string, 1)
+		withServerWithAuthClient(
+			func(t *testing.T) *http.ServeMux {
+				mux := http.NewServeMux()
+				path := fmt.Sprintf("/api/fleet/agents/%s/checkin", agentInfo.AgentID())
+				mux.HandleFunc(path, authHandler(func(w http.ResponseWriter, r *http.Request) {
+					requestIDs <- r.Header.Get("X-Request-ID")
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"statusCode": 400, "error": "Bad Request"}`)
+				}, withAPIKey))
+				return mux
+			}, withAPIKey,
+			func(t *testing.T, client client.Sender) {
+				cmd := NewCheckinCmd(agentInfo, client)
+
+				_, err := cmd.Execute(ctx, &CheckinRequest{})
+				require.Error(t, err)
+
+				reqID := <-requestIDs
+				require.NotEmpty(t, reqID)
+				assert.Contains(t, err.Error(), reqID)
+			},
+		)(t)
+	})
+
 	t.Run("Checkin receives a PolicyChange", withServerWithAuthClient(
 		func(t *testing.T) *http.ServeMux {
 			raw := `
@@ -107,6 +134,27 @@ func TestCheckin(t *testing.T) {
 		},
 	))
 
+	t.Run("Checkin reads the server time from the response", withServerWithAuthClient(
+		func(t *testing.T) *http.ServeMux {
+			mux := http.NewServeMux()
+			path := fmt.Sprintf("/api/fleet/agents/%s/checkin", agentInfo.AgentID())
+			mux.HandleFunc(path, authHandler(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", "Tue, 15 Nov 1994 08:12:31 GMT")
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{ "actions": [] }`)
+			}, withAPIKey))
+			return mux
+		}, withAPIKey,
+		func(t *testing.T, client client.Sender) {
+			cmd := NewCheckinCmd(agentInfo, client)
+
+			r, err := cmd.Execute(ctx, &CheckinRequest{})
+			require.NoError(t, err)
+
+			require.Equal(t, time.Date(1994, time.November, 15, 8, 12, 31, 0, time.UTC), r.ServerTime)
+		},
+	))
+
 	t.Run("Checkin receives known and unknown action type", withServerWithAuthClient(
 		func(t *testing.T) *http.ServeMux {
 			raw := `
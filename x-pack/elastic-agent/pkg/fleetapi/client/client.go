@@ -35,7 +35,7 @@ type Sender interface {
 }
 
 var baseRoundTrippers = func(rt http.RoundTripper) (http.RoundTripper, error) {
-	rt = NewFleetUserAgentRoundTripper(rt, release.Version())
+	rt = NewFleetUserAgentRoundTripper(rt, release.Info())
 	return rt, nil
 }
 
@@ -5,10 +5,12 @@
 package client
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -17,6 +19,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/remote"
 )
 
@@ -83,7 +86,10 @@ func TestHTTPClient(t *testing.T) {
 			mux.HandleFunc("/echo-hello", func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 				fmt.Fprint(w, msg)
-				require.Equal(t, r.Header.Get("User-Agent"), "Elastic Agent v8.0.0")
+				require.Equal(t, fmt.Sprintf("Elastic Agent v8.0.0-SNAPSHOT (%s; %s; commit 1a2b3c; built 2021-03-01T10:00:00Z)", runtime.GOOS, runtime.GOARCH), r.Header.Get("User-Agent"))
+				require.Equal(t,
+					fmt.Sprintf(`version="8.0.0", snapshot=?1, commit="1a2b3c4d5e6f", build_time="2021-03-01T10:00:00Z", os="%s", arch="%s"`, runtime.GOOS, runtime.GOARCH),
+					r.Header.Get(BuildHeader))
 			})
 			return mux
 		}, func(t *testing.T, host string) {
@@ -92,7 +98,12 @@ func TestHTTPClient(t *testing.T) {
 			})
 
 			client, err := remote.NewWithRawConfig(nil, cfg, func(wrapped http.RoundTripper) (http.RoundTripper, error) {
-				return NewFleetUserAgentRoundTripper(wrapped, "8.0.0"), nil
+				return NewFleetUserAgentRoundTripper(wrapped, release.VersionInfo{
+					Version:   "8.0.0",
+					Commit:    "1a2b3c4d5e6f",
+					BuildTime: time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+					Snapshot:  true,
+				}), nil
 			})
 
 			require.NoError(t, err)
@@ -106,6 +117,37 @@ func TestHTTPClient(t *testing.T) {
 		},
 	))
 
+	t.Run("Request body is compressed", withServer(
+		func(t *testing.T) *http.ServeMux {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/echo-body", func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+				zr, err := gzip.NewReader(r.Body)
+				require.NoError(t, err)
+				body, err := ioutil.ReadAll(zr)
+				require.NoError(t, err)
+				w.WriteHeader(http.StatusOK)
+				w.Write(body)
+			})
+			return mux
+		}, func(t *testing.T, host string) {
+			cfg := config.MustNewConfigFrom(map[string]interface{}{
+				"host": host,
+			})
+
+			client, err := remote.NewWithRawConfig(nil, cfg, nil)
+			require.NoError(t, err)
+
+			resp, err := NewGzipSender(client).Send(ctx, "POST", "/echo-body", nil, nil, strings.NewReader(`{ message: "hello" }`))
+			require.NoError(t, err)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, `{ message: "hello" }`, string(body))
+		},
+	))
+
 	t.Run("Fleet endpoint is not responding", func(t *testing.T) {
 		cfg := config.MustNewConfigFrom(map[string]interface{}{
 			"host": "127.0.0.0:7278",
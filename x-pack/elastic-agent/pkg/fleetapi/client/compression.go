@@ -0,0 +1,60 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// GzipSender wraps a Sender and compresses the body of every request using the gzip encoding,
+// the encoding is announced to fleet-server with the Content-Encoding header.
+type GzipSender struct {
+	Sender
+}
+
+// NewGzipSender returns a Sender compressing request bodies before handing them to the wrapped
+// Sender.
+func NewGzipSender(wrapped Sender) *GzipSender {
+	return &GzipSender{Sender: wrapped}
+}
+
+// Send compresses the body and sends the request using the wrapped Sender, requests without a
+// body or for which an encoding is already set are sent untouched.
+func (s *GzipSender) Send(
+	ctx context.Context,
+	method string,
+	path string,
+	params url.Values,
+	headers http.Header,
+	body io.Reader,
+) (*http.Response, error) {
+	if body == nil || headers.Get("Content-Encoding") != "" {
+		return s.Sender.Send(ctx, method, path, params, headers, body)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, errors.New(err, "fail to compress the request body")
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.New(err, "fail to compress the request body")
+	}
+
+	h := make(http.Header, len(headers)+1)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h.Set("Content-Encoding", "gzip")
+
+	return s.Sender.Send(ctx, method, path, params, h, &buf)
+}
@@ -6,31 +6,80 @@ package client
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/release"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/remote"
 )
 
 // ErrInvalidAPIKey is returned when authentication fail to fleet.
 var ErrInvalidAPIKey = errors.New("invalid api key to authenticate with fleet")
 
-// FleetUserAgentRoundTripper adds the Fleet user agent.
+// BuildHeader is the header describing the build of the agent sending a request, its value is a
+// structured field dictionary (RFC 8941) so it can be parsed by the server.
+const BuildHeader = "Elastic-Agent-Build"
+
+// FleetUserAgentRoundTripper adds the Fleet user agent and the build of the agent.
 type FleetUserAgentRoundTripper struct {
-	rt http.RoundTripper
+	rt    http.RoundTripper
+	build string
 }
 
-// RoundTrip adds the Fleet user agent string to every request.
+// RoundTrip adds the Fleet user agent string and the build header to every request.
 func (r *FleetUserAgentRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get(BuildHeader)) == 0 {
+		req.Header.Set(BuildHeader, r.build)
+	}
 	return r.rt.RoundTrip(req)
 }
 
 // NewFleetUserAgentRoundTripper returns a  FleetUserAgentRoundTripper that actually wrap the
-// existing UserAgentRoundTripper with a specific string.
-func NewFleetUserAgentRoundTripper(wrapped http.RoundTripper, version string) http.RoundTripper {
-	const name = "Elastic Agent"
+// existing UserAgentRoundTripper with a string describing the version, the build, the OS and the
+// architecture of the agent.
+func NewFleetUserAgentRoundTripper(wrapped http.RoundTripper, info release.VersionInfo) http.RoundTripper {
 	return &FleetUserAgentRoundTripper{
-		rt: remote.NewUserAgentRoundTripper(wrapped, name+" v"+version),
+		rt:    remote.NewUserAgentRoundTripper(wrapped, userAgent(info)),
+		build: buildHeader(info),
+	}
+}
+
+// userAgent returns the user agent of the agent, like
+// 'Elastic Agent v8.0.0 (linux; amd64; commit 1a2b3c; built 2021-03-01T10:00:00Z)'.
+func userAgent(info release.VersionInfo) string {
+	const name = "Elastic Agent"
+
+	version := info.Version
+	if info.Snapshot {
+		version += "-SNAPSHOT"
 	}
+	details := []string{runtime.GOOS, runtime.GOARCH}
+	if commit := release.TrimCommit(info.Commit); commit != "" {
+		details = append(details, "commit "+commit)
+	}
+	if !info.BuildTime.IsZero() {
+		details = append(details, "built "+info.BuildTime.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s v%s (%s)", name, version, strings.Join(details, "; "))
+}
+
+// buildHeader returns the value of the build header, like
+// 'version="8.0.0", snapshot=?0, commit="1a2b3c4d5e6f...", build_time="2021-03-01T10:00:00Z", os="linux", arch="amd64"'.
+func buildHeader(info release.VersionInfo) string {
+	snapshot := "?0"
+	if info.Snapshot {
+		snapshot = "?1"
+	}
+	var buildTime string
+	if !info.BuildTime.IsZero() {
+		buildTime = info.BuildTime.UTC().Format(time.RFC3339)
+	}
+
+	return fmt.Sprintf("version=%q, snapshot=%s, commit=%q, build_time=%q, os=%q, arch=%q",
+		info.Version, snapshot, info.Commit, buildTime, runtime.GOOS, runtime.GOARCH)
 }
 
 // FleetAuthRoundTripper allow all calls to be authenticated using the api key.
@@ -18,3 +18,19 @@ type Time time.Time
 func (t Time) MarshalJSON() ([]byte, error) {
 	return json.Marshal(time.Time(t).Format(timeFormat))
 }
+
+// UnmarshalJSON reads a time serialized with the RFC3339 format.
+func (t *Time) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.Parse(timeFormat, s)
+	if err != nil {
+		return err
+	}
+
+	*t = Time(v)
+	return nil
+}
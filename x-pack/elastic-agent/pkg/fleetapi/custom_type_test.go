@@ -21,3 +21,14 @@ func TestTimeSerialized(t *testing.T) {
 
 	require.Equal(t, "\"2020-01-08T06:30:00.651387237Z\"", string(b))
 }
+
+func TestTimeDeserialized(t *testing.T) {
+	then := time.Date(
+		2020, 1, 8, 6, 30, 00, 651387237, time.UTC)
+
+	var v Time
+	err := json.Unmarshal([]byte("\"2020-01-08T06:30:00.651387237Z\""), &v)
+	require.NoError(t, err)
+
+	require.True(t, then.Equal(time.Time(v)))
+}
@@ -30,6 +30,10 @@ var ErrTooManyRequests = errors.New("too many requests received (429)")
 // ErrConnRefused is returned when the connection to the server is refused.
 var ErrConnRefused = errors.New("connection refused")
 
+// ErrTemporaryUnavailable is returned when the remote server or a proxy in front of it is
+// temporarily unable to handle the request (502, 503 or 504).
+var ErrTemporaryUnavailable = errors.New("remote server is temporarily unavailable")
+
 const (
 	// PermanentEnroll is default enrollment type, by default an Agent is permanently enroll to Agent.
 	PermanentEnroll = EnrollType("PERMANENT")
@@ -95,6 +99,7 @@ type EnrollRequest struct {
 type Metadata struct {
 	Local        *info.ECSMeta          `json:"local"`
 	UserProvided map[string]interface{} `json:"user_provided"`
+	Tags         []string               `json:"tags,omitempty"`
 }
 
 // Validate validates the enrollment request before sending it to the API.
@@ -181,9 +186,10 @@ func (e *EnrollCmd) Execute(ctx context.Context, r *EnrollRequest) (*EnrollRespo
 		return nil, err
 	}
 
-	headers := map[string][]string{
+	reqID := newRequestID()
+	headers := withRequestID(map[string][]string{
 		key: []string{prefix + r.EnrollAPIKey},
-	}
+	}, reqID)
 
 	b, err := json.Marshal(r)
 	if err != nil {
@@ -201,22 +207,34 @@ func (e *EnrollCmd) Execute(ctx context.Context, r *EnrollRequest) (*EnrollRespo
 		case *net.OpError:
 			return nil, ErrConnRefused
 		}
-		return nil, err
+		return nil, errors.New(err,
+			fmt.Sprintf("fail to enroll (request ID %s)", reqID),
+			errors.TypeNetwork,
+			errors.M(errors.MetaKeyURI, p),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusTooManyRequests {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
 		return nil, ErrTooManyRequests
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, ErrTemporaryUnavailable
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, client.ExtractError(resp.Body)
+		return nil, errors.New(client.ExtractError(resp.Body),
+			fmt.Sprintf("enrollment request %s failed", reqID),
+			errors.M(errors.MetaKeyURI, p),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	enrollResponse := &EnrollResponse{}
 	decoder := json.NewDecoder(resp.Body)
 	if err := decoder.Decode(enrollResponse); err != nil {
-		return nil, errors.New(err, "fail to decode enrollment response")
+		return nil, errors.New(err,
+			fmt.Sprintf("fail to decode enrollment response (request ID %s)", reqID),
+			errors.M(errors.MetaKeyRequestID, reqID))
 	}
 
 	if err := enrollResponse.Validate(); err != nil {
@@ -121,6 +121,36 @@ func TestEnroll(t *testing.T) {
 			require.True(t, strings.Index(err.Error(), "Something is really bad here") > 0)
 		},
 	))
+
+	t.Run("Unavailable server is reported as temporary", withServer(
+		func(t *testing.T) *http.ServeMux {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/fleet/agents/enroll", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+			return mux
+		}, func(t *testing.T, host string) {
+			cfg := config.MustNewConfigFrom(map[string]interface{}{
+				"host": host,
+			})
+
+			client, err := remote.NewWithRawConfig(nil, cfg, nil)
+			require.NoError(t, err)
+
+			req := &EnrollRequest{
+				Type:         PermanentEnroll,
+				EnrollAPIKey: "my-enrollment-api-key",
+				Metadata: Metadata{
+					Local:        testMetadata(),
+					UserProvided: make(map[string]interface{}),
+				},
+			}
+
+			cmd := &EnrollCmd{client: client}
+			_, err = cmd.Execute(context.Background(), req)
+			require.Equal(t, ErrTemporaryUnavailable, err)
+		},
+	))
 }
 
 func testMetadata() *info.ECSMeta {
@@ -0,0 +1,83 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"encoding/json"
+)
+
+const (
+	// EventSchemaV1 is the shape of the events understood by every version of Fleet.
+	EventSchemaV1 = 1
+	// EventSchemaV2 adds the sequence of the events.
+	EventSchemaV2 = 2
+
+	// EventSchemaVersion is the version of the events serialized by the agent, Fleet can ask the
+	// agent to downgrade to an older version with the checkin response.
+	EventSchemaVersion = EventSchemaV2
+)
+
+// eventFieldsSince lists the fields of the events added by each version of the schema.
+var eventFieldsSince = map[int][]string{
+	EventSchemaV2: {"sequence"},
+}
+
+// NegotiateEventSchema returns the version of the schema to use with a Fleet which supports up to
+// the version hinted in its response, a zero hint keeps the current version.
+func NegotiateEventSchema(current, hint int) int {
+	if hint <= 0 {
+		return current
+	}
+	if hint > EventSchemaVersion {
+		return EventSchemaVersion
+	}
+	if hint < EventSchemaV1 {
+		return EventSchemaV1
+	}
+	return hint
+}
+
+// EventsWithSchema returns the events serialized with the version of the schema, the fields added
+// by more recent versions are left out.
+func EventsWithSchema(events []SerializableEvent, version int) []SerializableEvent {
+	if version >= EventSchemaVersion || len(events) == 0 {
+		return events
+	}
+
+	var removed []string
+	for v := version + 1; v <= EventSchemaVersion; v++ {
+		removed = append(removed, eventFieldsSince[v]...)
+	}
+
+	downgraded := make([]SerializableEvent, 0, len(events))
+	for _, e := range events {
+		downgraded = append(downgraded, &schemaEvent{SerializableEvent: e, removed: removed})
+	}
+	return downgraded
+}
+
+// schemaEvent removes the fields unknown to an older version of the schema when the event is
+// serialized.
+type schemaEvent struct {
+	SerializableEvent
+	removed []string
+}
+
+func (e *schemaEvent) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(e.SerializableEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, f := range e.removed {
+		delete(fields, f)
+	}
+	return json.Marshal(fields)
+}
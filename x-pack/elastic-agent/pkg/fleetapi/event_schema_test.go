@@ -0,0 +1,47 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sequencedEvent struct {
+	EventType string `json:"type"`
+	Msg       string `json:"message"`
+	Sequence  uint64 `json:"sequence,omitempty"`
+}
+
+func (e *sequencedEvent) Type() string         { return e.EventType }
+func (e *sequencedEvent) Timestamp() time.Time { return time.Time{} }
+func (e *sequencedEvent) Message() string      { return e.Msg }
+
+func TestNegotiateEventSchema(t *testing.T) {
+	assert.Equal(t, EventSchemaV2, NegotiateEventSchema(EventSchemaV2, 0))
+	assert.Equal(t, EventSchemaV1, NegotiateEventSchema(EventSchemaV2, EventSchemaV1))
+	assert.Equal(t, EventSchemaVersion, NegotiateEventSchema(EventSchemaV1, EventSchemaVersion+1))
+	assert.Equal(t, EventSchemaV1, NegotiateEventSchema(EventSchemaV1, 0))
+}
+
+func TestEventsWithSchema(t *testing.T) {
+	events := []SerializableEvent{&sequencedEvent{EventType: "STATE", Msg: "hello", Sequence: 3}}
+
+	current := EventsWithSchema(events, EventSchemaVersion)
+	b, err := json.Marshal(current)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"type":"STATE","message":"hello","sequence":3}]`, string(b))
+
+	downgraded := EventsWithSchema(events, EventSchemaV1)
+	require.Len(t, downgraded, 1)
+	assert.Equal(t, "hello", downgraded[0].Message())
+	b, err = json.Marshal(downgraded)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"type":"STATE","message":"hello"}]`, string(b))
+}
@@ -0,0 +1,35 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"net/http"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/id"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/remote"
+)
+
+// newRequestID returns the ID of a request sent to fleet-server, the ID is sent in the
+// X-Request-ID header and is part of the errors of the request so a failure seen by the agent can
+// be found in the logs of the server.
+func newRequestID() string {
+	u, err := id.Generate()
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// withRequestID returns the headers of a request with its ID, without an ID the client generates
+// one.
+func withRequestID(headers http.Header, reqID string) http.Header {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if reqID != "" {
+		headers.Set(remote.RequestIDHeader, reqID)
+	}
+	return headers
+}
@@ -0,0 +1,63 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// ErrInvalidSignature is returned when the signature does not match the signed data.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signed is the content signed by Fleet, the data and its signature are base64 encoded. The
+// signature is the ASN.1 encoded ECDSA signature of the SHA-256 digest of the data.
+type Signed struct {
+	Data      string `json:"data" yaml:"data"`
+	Signature string `json:"signature" yaml:"signature"`
+}
+
+// Verify verifies the signature with the public key and returns the decoded data.
+func (s *Signed) Verify(key *ecdsa.PublicKey) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(s.Data)
+	if err != nil {
+		return nil, errors.New(err, "fail to decode the signed data", errors.TypeSecurity)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return nil, errors.New(err, "fail to decode the signature", errors.TypeSecurity)
+	}
+
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return nil, errors.New(ErrInvalidSignature, errors.TypeSecurity)
+	}
+	return data, nil
+}
+
+// ParseSigningKey parses the PEM encoded ECDSA public key used to verify the content signed by Fleet.
+func ParseSigningKey(key []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("fail to decode the PEM encoded signing key", errors.TypeSecurity)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New(err, "fail to parse the signing key", errors.TypeSecurity)
+	}
+
+	ecdsaKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("signing key is not an ECDSA public key", errors.TypeSecurity)
+	}
+	return ecdsaKey, nil
+}
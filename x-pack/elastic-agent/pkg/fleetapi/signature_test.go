@@ -0,0 +1,88 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	data := []byte(`{"id":"policy-1"}`)
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	signed := &Signed{
+		Data:      base64.StdEncoding.EncodeToString(data),
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}
+
+	t.Run("valid signature returns the data", func(t *testing.T) {
+		verified, err := signed.Verify(&key.PublicKey)
+		require.NoError(t, err)
+		require.Equal(t, data, verified)
+	})
+
+	t.Run("tampered data is rejected", func(t *testing.T) {
+		tampered := &Signed{
+			Data:      base64.StdEncoding.EncodeToString([]byte(`{"id":"policy-2"}`)),
+			Signature: signed.Signature,
+		}
+		_, err := tampered.Verify(&key.PublicKey)
+		require.True(t, errors.Is(err, ErrInvalidSignature))
+	})
+
+	t.Run("signature of another key is rejected", func(t *testing.T) {
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		_, err = signed.Verify(&other.PublicKey)
+		require.True(t, errors.Is(err, ErrInvalidSignature))
+	})
+}
+
+func TestParseSigningKey(t *testing.T) {
+	t.Run("ECDSA public key", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		parsed, err := ParseSigningKey(encodePublicKey(t, &key.PublicKey))
+		require.NoError(t, err)
+		require.True(t, key.PublicKey.Equal(parsed))
+	})
+
+	t.Run("not a PEM encoded key", func(t *testing.T) {
+		_, err := ParseSigningKey([]byte("not a key"))
+		require.Error(t, err)
+	})
+
+	t.Run("not an ECDSA key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		_, err = ParseSigningKey(encodePublicKey(t, &key.PublicKey))
+		require.Error(t, err)
+	})
+}
+
+func encodePublicKey(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// ThrottledError is returned when fleet-server rate limits a request with a 429 response.
+type ThrottledError struct {
+	// RetryAfter is the time fleet-server asks to wait before the next request, zero when the
+	// response has no valid Retry-After header.
+	RetryAfter time.Duration
+	RequestID  string
+}
+
+// Error returns the description of the error.
+func (e *ThrottledError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("request %s was throttled by fleet-server, retry after %s", e.RequestID, e.RetryAfter)
+	}
+	return fmt.Sprintf("request %s was throttled by fleet-server", e.RequestID)
+}
+
+// Is makes the error match ErrTooManyRequests.
+func (e *ThrottledError) Is(target error) bool {
+	return errors.Is(ErrTooManyRequests, target)
+}
+
+// newThrottledError creates the error of a 429 response.
+func newThrottledError(resp *http.Response, reqID string, now time.Time) *ThrottledError {
+	return &ThrottledError{
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), now),
+		RequestID:  reqID,
+	}
+}
+
+// parseRetryAfter parses a Retry-After header holding either a number of seconds or an HTTP date,
+// an invalid value or a date in the past returns zero.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if sec <= 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleetapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi/client"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		" 5 ":                           5 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 01 Mar 2021 10:00:30 GMT": 30 * time.Second,
+		"Mon, 01 Mar 2021 09:00:00 GMT": 0,
+	}
+	for value, expected := range testCases {
+		assert.Equal(t, expected, parseRetryAfter(value, now), "Retry-After %q", value)
+	}
+}
+
+func TestCheckinThrottled(t *testing.T) {
+	const withAPIKey = "secret"
+	agentInfo := &agentinfo{}
+
+	withServerWithAuthClient(
+		func(t *testing.T) *http.ServeMux {
+			mux := http.NewServeMux()
+			path := fmt.Sprintf("/api/fleet/agents/%s/checkin", agentInfo.AgentID())
+			mux.HandleFunc(path, authHandler(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}, withAPIKey))
+			return mux
+		}, withAPIKey,
+		func(t *testing.T, client client.Sender) {
+			cmd := NewCheckinCmd(agentInfo, client)
+
+			_, err := cmd.Execute(context.Background(), &CheckinRequest{})
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrTooManyRequests))
+
+			var throttled *ThrottledError
+			require.True(t, errors.As(err, &throttled))
+			assert.Equal(t, 30*time.Second, throttled.RetryAfter)
+			assert.NotEmpty(t, throttled.RequestID)
+		},
+	)(t)
+}
@@ -5,8 +5,11 @@
 package remote
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -20,13 +23,19 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/transport/httpcommon"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fips"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/id"
 )
 
+// RequestIDHeader is the header carrying the ID of a request, the ID is logged by the agent and by
+// the server so a request can be correlated on both sides.
+const RequestIDHeader = "X-Request-ID"
+
 const (
 	defaultPort = 8220
 
-	retryOnBadConnTimeout = 5 * time.Minute
+	// defaultHostCooldown is the time a host is excluded after a failed request.
+	defaultHostCooldown = 5 * time.Minute
 )
 
 var hasScheme = regexp.MustCompile(`^([a-z][a-z0-9+\-.]*)://`)
@@ -35,6 +44,7 @@ type requestFunc func(string, string, url.Values, io.Reader) (*http.Request, err
 type wrapperFunc func(rt http.RoundTripper) (http.RoundTripper, error)
 
 type requestClient struct {
+	host       string
 	request    requestFunc
 	client     http.Client
 	lastUsed   time.Time
@@ -51,6 +61,9 @@ type Client struct {
 	lock    sync.Mutex
 	clients []*requestClient
 	config  Config
+
+	// lastHost is the host which served the last successful request.
+	lastHost string
 }
 
 // NewConfigFromURL returns a Config based on a received host.
@@ -101,6 +114,11 @@ func NewWithConfig(log *logger.Logger, cfg Config, wrapper wrapperFunc) (*Client
 		p = p + "/"
 	}
 
+	settings, err := fips.HTTPTransport(cfg.Transport)
+	if err != nil {
+		return nil, err
+	}
+
 	hosts := cfg.GetHosts()
 	clients := make([]*requestClient, len(hosts))
 	for i, host := range cfg.GetHosts() {
@@ -109,7 +127,7 @@ func NewWithConfig(log *logger.Logger, cfg Config, wrapper wrapperFunc) (*Client
 			return nil, errors.Wrap(err, "invalid fleet-server endpoint")
 		}
 
-		transport, err := cfg.Transport.RoundTripper(
+		transport, err := settings.RoundTripper(
 			httpcommon.WithAPMHTTPInstrumentation(),
 			httpcommon.WithForceAttemptHTTP2(true),
 		)
@@ -130,6 +148,7 @@ func NewWithConfig(log *logger.Logger, cfg Config, wrapper wrapperFunc) (*Client
 		}
 
 		clients[i] = &requestClient{
+			host:    host,
 			request: prefixRequestFactory(connStr),
 			client:  httpClient,
 		}
@@ -153,17 +172,69 @@ func (c *Client) Send(
 	headers http.Header,
 	body io.Reader,
 ) (*http.Response, error) {
-	// Generate a request ID for tracking
-	var reqID string
-	if u, err := id.Generate(); err == nil {
-		reqID = u.String()
+	// Generate a request ID for tracking, unless the caller already assigned one to the request.
+	reqID := headers.Get(RequestIDHeader)
+	if reqID == "" {
+		if u, err := id.Generate(); err == nil {
+			reqID = u.String()
+		}
 	}
 
 	c.log.Debugf("Request method: %s, path: %s, reqID: %s", method, path, reqID)
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	requester := c.nextRequester()
 
+	// keep the body so the request can be sent again to another host.
+	var content []byte
+	if body != nil && len(c.clients) > 1 {
+		var err error
+		content, err = ioutil.ReadAll(body)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fail to read the body of the request using method %s to %s", method, path)
+		}
+	}
+
+	tried := make(map[*requestClient]bool, len(c.clients))
+	for {
+		c.lock.Lock()
+		requester := c.selectRequester(tried)
+		requester.lastUsed = time.Now().UTC()
+		c.lock.Unlock()
+		tried[requester] = true
+
+		if content != nil {
+			body = bytes.NewReader(content)
+		}
+
+		resp, err := c.sendTo(ctx, requester, reqID, method, path, params, headers, body)
+		if err != nil {
+			c.log.Debugf("Request failed, method: %s, path: %s, host: %s, reqID: %s: %v", method, path, requester.host, reqID, err)
+		} else {
+			c.log.Debugf("Response status: %d, method: %s, path: %s, host: %s, reqID: %s", resp.StatusCode, method, path, requester.host, reqID)
+		}
+		failure := err
+		if err == nil && isUnavailable(resp) {
+			failure = fmt.Errorf("host %s is unavailable (%s)", requester.host, resp.Status)
+		}
+		c.recordResult(requester, failure)
+
+		if failure == nil || ctx.Err() != nil || len(tried) == len(c.clients) {
+			return resp, err
+		}
+
+		c.log.Warnf("Request to %s failed, failing over to the next host: %v", requester.host, failure)
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func (c *Client) sendTo(
+	ctx context.Context,
+	requester *requestClient,
+	reqID, method, path string,
+	params url.Values,
+	headers http.Header,
+	body io.Reader,
+) (*http.Response, error) {
 	req, err := requester.request(method, path, params, body)
 	if err != nil {
 		return nil, errors.Wrapf(err, "fail to create HTTP request using method %s to %s", method, path)
@@ -178,27 +249,75 @@ func (c *Client) Send(
 
 	// If available, add the request id as an HTTP header
 	if reqID != "" {
-		req.Header.Add("X-Request-ID", reqID)
+		req.Header.Set(RequestIDHeader, reqID)
+	}
+
+	for header, v := range c.config.Headers {
+		if len(headers.Values(header)) > 0 {
+			continue
+		}
+		req.Header.Set(header, v)
 	}
 
 	// copy headers.
 	for header, values := range headers {
+		if http.CanonicalHeaderKey(header) == RequestIDHeader {
+			continue
+		}
 		for _, v := range values {
 			req.Header.Add(header, v)
 		}
 	}
 
-	requester.lastUsed = time.Now().UTC()
+	return requester.client.Do(req.WithContext(ctx))
+}
+
+// recordResult updates the health of the host, a host is excluded for the cooldown period after a
+// failed request.
+func (c *Client) recordResult(requester *requestClient, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	resp, err := requester.client.Do(req.WithContext(ctx))
 	if err != nil {
 		requester.lastErr = err
 		requester.lastErrOcc = time.Now().UTC()
-	} else {
-		requester.lastErr = nil
-		requester.lastErrOcc = time.Time{}
+		return
+	}
+	requester.lastErr = nil
+	requester.lastErrOcc = time.Time{}
+	c.lastHost = requester.host
+}
+
+// LastHost returns the host which served the last successful request, it is empty until a request
+// succeeds.
+func (c *Client) LastHost() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lastHost
+}
+
+// HostsHealth returns the health of every host, a host is unhealthy during the cooldown period
+// following a failed request.
+func (c *Client) HostsHealth() map[string]bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now().UTC()
+	health := make(map[string]bool, len(c.clients))
+	for _, requester := range c.clients {
+		health[requester.host] = requester.lastErr == nil || now.Sub(requester.lastErrOcc) > c.hostCooldown()
 	}
-	return resp, err
+	return health
+}
+
+// isUnavailable returns true when the response tells the host cannot serve the request, the
+// request can be sent to another host.
+func isUnavailable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
 }
 
 // URI returns the remote URI.
@@ -223,13 +342,22 @@ func new(
 
 // nextRequester returns the requester to use.
 //
-// It excludes clients that have errored in the last 5 minutes.
+// It excludes clients that have errored during the cooldown period, 5 minutes by default.
 func (c *Client) nextRequester() *requestClient {
+	return c.selectRequester(nil)
+}
+
+// selectRequester returns the requester to use, requesters already tried for the request are
+// excluded unless all of them were tried.
+func (c *Client) selectRequester(tried map[*requestClient]bool) *requestClient {
 	var selected *requestClient
 
 	now := time.Now().UTC()
 	for _, requester := range c.clients {
-		if requester.lastErr != nil && now.Sub(requester.lastErrOcc) > retryOnBadConnTimeout {
+		if tried[requester] {
+			continue
+		}
+		if requester.lastErr != nil && now.Sub(requester.lastErrOcc) > c.hostCooldown() {
 			requester.lastErr = nil
 			requester.lastErrOcc = time.Time{}
 		}
@@ -252,6 +380,9 @@ func (c *Client) nextRequester() *requestClient {
 	if selected == nil {
 		// all are erroring; select the oldest one that errored
 		for _, requester := range c.clients {
+			if tried[requester] && len(tried) < len(c.clients) {
+				continue
+			}
 			if selected == nil {
 				selected = requester
 				continue
@@ -264,6 +395,13 @@ func (c *Client) nextRequester() *requestClient {
 	return selected
 }
 
+func (c *Client) hostCooldown() time.Duration {
+	if c.config.HostCooldown <= 0 {
+		return defaultHostCooldown
+	}
+	return c.config.HostCooldown
+}
+
 func prefixRequestFactory(URL string) requestFunc {
 	return func(method, path string, params url.Values, body io.Reader) (*http.Request, error) {
 		path = strings.TrimPrefix(path, "/")
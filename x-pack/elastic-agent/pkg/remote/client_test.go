@@ -254,6 +254,33 @@ func TestHTTPClient(t *testing.T) {
 			assert.Equal(t, `{ message: "hello" }`, string(body))
 		},
 	))
+
+	t.Run("Configured headers", withServer(
+		func(t *testing.T) *http.ServeMux {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/echo-hello", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "tenant-1", r.Header.Get("X-Tenant-ID"))
+				assert.Equal(t, []string{"request"}, r.Header.Values("X-Token"))
+				w.WriteHeader(http.StatusOK)
+			})
+			return mux
+		}, func(t *testing.T, host string) {
+			cfg := config.MustNewConfigFrom(map[string]interface{}{
+				"host": host,
+				"headers": map[string]interface{}{
+					"X-Tenant-ID": "tenant-1",
+					"X-Token":     "configured",
+				},
+			})
+
+			client, err := NewWithRawConfig(nil, cfg, nil)
+			require.NoError(t, err)
+			resp, err := client.Send(ctx, "GET", "/echo-hello", nil, http.Header{"X-Token": []string{"request"}}, nil)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		},
+	))
 }
 
 func TestNextRequester(t *testing.T) {
@@ -340,6 +367,78 @@ func TestNextRequester(t *testing.T) {
 	})
 }
 
+func TestHostFailover(t *testing.T) {
+	ctx := context.Background()
+	l, err := logger.New("", false)
+	require.NoError(t, err)
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	available := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	defer available.Close()
+
+	unavailableHost := unavailable.Listener.Addr().String()
+	availableHost := available.Listener.Addr().String()
+
+	t.Run("request fails over to the next host with the same body", func(t *testing.T) {
+		cfg := config.MustNewConfigFrom(map[string]interface{}{
+			"hosts": []string{unavailableHost, availableHost},
+		})
+		client, err := NewWithRawConfig(l, cfg, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Send(ctx, "POST", "/echo", nil, nil, strings.NewReader("hello"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+		assert.Equal(t, availableHost, client.LastHost())
+		assert.Equal(t, map[string]bool{unavailableHost: false, availableHost: true}, client.HostsHealth())
+
+		// the unhealthy host is not used again during the cooldown period.
+		for i := 0; i < 3; i++ {
+			resp, err := client.Send(ctx, "GET", "/echo", nil, nil, nil)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, availableHost, resp.Request.URL.Host)
+		}
+	})
+
+	t.Run("last response is returned when all hosts are unavailable", func(t *testing.T) {
+		cfg := config.MustNewConfigFrom(map[string]interface{}{
+			"hosts": []string{unavailableHost, unavailableHost},
+		})
+		client, err := NewWithRawConfig(l, cfg, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Send(ctx, "GET", "/echo", nil, nil, nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Empty(t, client.LastHost())
+	})
+
+	t.Run("unhealthy host is used again after the cooldown period", func(t *testing.T) {
+		one := &requestClient{
+			lastErr:    fmt.Errorf("fake error"),
+			lastErrOcc: time.Now().Add(-2 * time.Second),
+		}
+		two := &requestClient{lastUsed: time.Now()}
+		client, err := new(nil, Config{HostCooldown: time.Second}, one, two)
+		require.NoError(t, err)
+		assert.Equal(t, one, client.nextRequester())
+	})
+}
+
 func withServer(m func(t *testing.T) *http.ServeMux, test func(t *testing.T, host string)) func(t *testing.T) {
 	return func(t *testing.T) {
 		s := httptest.NewServer(m(t))
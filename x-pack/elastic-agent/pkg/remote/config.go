@@ -19,6 +19,14 @@ type Config struct {
 	Host     string   `config:"host" yaml:"host,omitempty"`
 	Hosts    []string `config:"hosts" yaml:"hosts,omitempty"`
 
+	// HostCooldown is the time a host is excluded after a failed request, requests are sent to the
+	// other hosts in the meantime.
+	HostCooldown time.Duration `config:"host_cooldown" yaml:"host_cooldown,omitempty"`
+
+	// Headers are added to every request, like the headers required by a gateway in front of
+	// Fleet. The headers of a request take precedence.
+	Headers map[string]string `config:"headers" yaml:"headers,omitempty"`
+
 	Transport httpcommon.HTTPTransportSettings `config:",inline" yaml:",inline"`
 }
 
@@ -49,11 +57,12 @@ func DefaultClientConfig() Config {
 	transport.Timeout = 10 * time.Minute
 
 	return Config{
-		Protocol:  ProtocolHTTP,
-		Host:      "localhost:5601",
-		Path:      "",
-		SpaceID:   "",
-		Transport: transport,
+		Protocol:     ProtocolHTTP,
+		Host:         "localhost:5601",
+		Path:         "",
+		SpaceID:      "",
+		HostCooldown: defaultHostCooldown,
+		Transport:    transport,
 	}
 }
 
@@ -0,0 +1,316 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package elasticsearch reports the events of the agent directly into an index of Elasticsearch,
+// the events stay observable when Fleet cannot be reached.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/remote"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+)
+
+// closeTimeout is the time given to the last bulk request sent when the reporter is closed.
+const closeTimeout = 5 * time.Second
+
+// Config is the configuration of the Elasticsearch reporter.
+type Config struct {
+	Enabled bool `yaml:"enabled" config:"enabled"`
+	// Index is the index or the data stream the events are created in.
+	Index    string `yaml:"index" config:"index" validate:"required"`
+	APIKey   string `yaml:"api_key,omitempty" config:"api_key"`
+	Username string `yaml:"username,omitempty" config:"username"`
+	Password string `yaml:"password,omitempty" config:"password"`
+	// QueueSize is the number of events waiting to be indexed, the oldest events are dropped once
+	// the queue is full.
+	QueueSize int `yaml:"queue_size" config:"queue_size" validate:"min=1"`
+	// BulkMaxSize is the maximum number of events of a bulk request.
+	BulkMaxSize int `yaml:"bulk_max_size" config:"bulk_max_size" validate:"min=1"`
+	// FlushInterval is the time between two bulk requests.
+	FlushInterval time.Duration `yaml:"flush_interval" config:"flush_interval" validate:"positive"`
+
+	Client remote.Config `yaml:",inline" config:",inline"`
+}
+
+// DefaultConfig creates a config with the Elasticsearch reporter disabled.
+func DefaultConfig() *Config {
+	client := remote.DefaultClientConfig()
+	client.Host = "localhost:9200"
+	client.Transport.Timeout = 90 * time.Second
+
+	return &Config{
+		Index:         "logs-elastic_agent.events-default",
+		QueueSize:     1000,
+		BulkMaxSize:   100,
+		FlushInterval: 10 * time.Second,
+		Client:        client,
+	}
+}
+
+type sender interface {
+	Send(
+		ctx context.Context,
+		method, path string,
+		params url.Values,
+		headers http.Header,
+		body io.Reader,
+	) (*http.Response, error)
+}
+
+type agentInfo interface {
+	AgentID() string
+}
+
+// document is the source of an event indexed in Elasticsearch.
+type document struct {
+	Timestamp time.Time              `json:"@timestamp"`
+	AgentID   string                 `json:"agent.id"`
+	Type      string                 `json:"event.type"`
+	SubType   string                 `json:"event.subtype"`
+	Message   string                 `json:"message"`
+	Payload   map[string]interface{} `json:"payload,omitempty"`
+}
+
+// bulkResponse is the part of the response of a bulk request telling which items failed.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error,omitempty"`
+	} `json:"items"`
+}
+
+// Reporter queues the events and indexes them with bulk requests, the events are only removed
+// from the queue once Elasticsearch acknowledged them. The events rejected with a retryable
+// status are kept and sent again with the next bulk request.
+type Reporter struct {
+	log     *logger.Logger
+	info    agentInfo
+	client  sender
+	config  *Config
+	headers http.Header
+
+	mx      sync.Mutex
+	queue   [][]byte
+	dropped int
+	// evicted counts the events removed from the head of the queue because it was full.
+	evicted int
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReporter creates a reporter indexing the events into Elasticsearch.
+func NewReporter(log *logger.Logger, info agentInfo, c *Config) (*Reporter, error) {
+	client, err := remote.NewWithConfig(log, c.Client, nil)
+	if err != nil {
+		return nil, errors.New(err, "failed to create the Elasticsearch client", errors.TypeConfig)
+	}
+	return newReporter(log, info, client, c), nil
+}
+
+func newReporter(log *logger.Logger, info agentInfo, client sender, c *Config) *Reporter {
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case c.APIKey != "":
+		headers.Set("Authorization", "ApiKey "+base64.StdEncoding.EncodeToString([]byte(c.APIKey)))
+	case c.Username != "":
+		headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password)))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Reporter{
+		log:     log,
+		info:    info,
+		client:  client,
+		config:  c,
+		headers: headers,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go r.run(ctx)
+	return r
+}
+
+// Report queues the event, it is indexed by the next bulk request.
+func (r *Reporter) Report(_ context.Context, e reporter.Event) error {
+	data, err := json.Marshal(document{
+		Timestamp: e.Time(),
+		AgentID:   r.info.AgentID(),
+		Type:      e.Type(),
+		SubType:   e.SubType(),
+		Message:   e.Message(),
+		Payload:   e.Payload(),
+	})
+	if err != nil {
+		return errors.New(err, "failed to encode the event", errors.TypeUnexpected)
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.queue = append(r.queue, data)
+	if over := len(r.queue) - r.config.QueueSize; over > 0 {
+		r.queue = r.queue[over:]
+		r.dropped += over
+		r.evicted += over
+	}
+	return nil
+}
+
+// Close stops the bulk requests, the queued events are sent one last time.
+func (r *Reporter) Close() error {
+	r.closeOnce.Do(func() {
+		r.cancel()
+		<-r.done
+
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := r.flush(ctx); err != nil {
+			r.log.Errorf("Elasticsearch reporter failed to index the remaining events: %v", err)
+		}
+	})
+	return nil
+}
+
+func (r *Reporter) run(ctx context.Context) {
+	defer close(r.done)
+
+	t := time.NewTicker(r.config.FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := r.flush(ctx); err != nil {
+				r.log.Errorf("Elasticsearch reporter failed to index the events, they are sent again in %s: %v", r.config.FlushInterval, err)
+			}
+		}
+	}
+}
+
+// flush sends the queued events with bulk requests until the queue is empty or a request fails.
+func (r *Reporter) flush(ctx context.Context) error {
+	for {
+		r.mx.Lock()
+		batch := r.queue
+		evicted := r.evicted
+		if len(batch) > r.config.BulkMaxSize {
+			batch = batch[:r.config.BulkMaxSize]
+		}
+		if dropped := r.dropped; dropped > 0 {
+			r.dropped = 0
+			r.log.Warnf("Elasticsearch reporter dropped %d events because the queue was full", dropped)
+		}
+		r.mx.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		retry, err := r.send(ctx, batch)
+		if err != nil {
+			return err
+		}
+		r.acked(batch, retry, evicted)
+		if len(retry) > 0 {
+			return fmt.Errorf("%d events were rejected with a retryable status", len(retry))
+		}
+	}
+}
+
+// send indexes the batch with a bulk request, it returns the events to send again.
+func (r *Reporter) send(ctx context.Context, batch [][]byte) ([][]byte, error) {
+	var body bytes.Buffer
+	action, err := json.Marshal(map[string]interface{}{"create": map[string]string{"_index": r.config.Index}})
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range batch {
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+
+	resp, err := r.client.Send(ctx, "POST", "/_bulk", nil, r.headers.Clone(), &body)
+	if err != nil {
+		return nil, errors.New(err, "bulk request failed", errors.TypeNetwork)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New(err, "failed to read the bulk response", errors.TypeNetwork)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("bulk request failed with status %d: %s", resp.StatusCode, content), errors.TypeNetwork)
+	}
+
+	var br bulkResponse
+	if err := json.Unmarshal(content, &br); err != nil {
+		return nil, errors.New(err, "failed to decode the bulk response", errors.TypeNetwork)
+	}
+	if !br.Errors {
+		return nil, nil
+	}
+
+	var retry [][]byte
+	for i, item := range br.Items {
+		if i >= len(batch) {
+			break
+		}
+		for _, result := range item {
+			switch {
+			case result.Status < 300:
+			case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+				retry = append(retry, batch[i])
+			default:
+				r.log.Errorf("Elasticsearch reporter dropped an event rejected with status %d: %s", result.Status, result.Error)
+			}
+		}
+	}
+	return retry, nil
+}
+
+// acked removes the batch from the queue, the events to retry are put back at the front of the
+// queue. The events of the batch evicted while it was sent are already out of the queue.
+func (r *Reporter) acked(batch, retry [][]byte, evicted int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	remaining := r.queue
+	if sent := len(batch) - (r.evicted - evicted); sent > 0 {
+		remaining = remaining[sent:]
+	}
+
+	queue := make([][]byte, 0, len(retry)+len(remaining))
+	queue = append(queue, retry...)
+	queue = append(queue, remaining...)
+	if over := len(queue) - r.config.QueueSize; over > 0 {
+		queue = queue[over:]
+		r.dropped += over
+		r.evicted += over
+	}
+	r.queue = queue
+}
+
+// Check it is reporter.Backend.
+var _ reporter.Backend = &Reporter{}
@@ -0,0 +1,166 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package elasticsearch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+)
+
+type testInfo struct{}
+
+func (testInfo) AgentID() string { return "agent-1" }
+
+type testEvent struct {
+	message string
+}
+
+func (testEvent) Type() string                    { return reporter.EventTypeState }
+func (testEvent) SubType() string                 { return reporter.EventSubTypeRunning }
+func (testEvent) Time() time.Time                 { return time.Unix(10, 0).UTC() }
+func (e testEvent) Message() string               { return e.message }
+func (testEvent) Payload() map[string]interface{} { return nil }
+
+// testSender answers the bulk requests with the statuses of the next response, the messages of
+// the indexed events are recorded.
+type testSender struct {
+	statuses [][]int
+	headers  http.Header
+	indexed  []string
+	requests int
+}
+
+func (s *testSender) Send(_ context.Context, _, path string, _ url.Values, headers http.Header, body io.Reader) (*http.Response, error) {
+	s.requests++
+	s.headers = headers
+	if path != "/_bulk" {
+		return nil, fmt.Errorf("unexpected path %s", path)
+	}
+
+	var statuses []int
+	if len(s.statuses) > 0 {
+		statuses, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+
+	var messages []string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var doc document
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("bulk action without document")
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			return nil, err
+		}
+		messages = append(messages, doc.Message)
+	}
+
+	var resp bulkResponse
+	for i, msg := range messages {
+		status := http.StatusCreated
+		if i < len(statuses) {
+			status = statuses[i]
+		}
+		if status >= 300 {
+			resp.Errors = true
+		} else {
+			s.indexed = append(s.indexed, msg)
+		}
+		resp.Items = append(resp.Items, map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error,omitempty"`
+		}{"create": {Status: status}})
+	}
+
+	content, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(content))}, nil
+}
+
+func newTestReporter(t *testing.T, s *testSender, c *Config) *Reporter {
+	log, err := logger.New("", false)
+	require.NoError(t, err)
+
+	c.FlushInterval = time.Hour
+	r := newReporter(log, testInfo{}, s, c)
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func report(t *testing.T, r *Reporter, messages ...string) {
+	for _, msg := range messages {
+		require.NoError(t, r.Report(context.Background(), testEvent{message: msg}))
+	}
+}
+
+func TestReporter(t *testing.T) {
+	t.Run("events are indexed by batches", func(t *testing.T) {
+		s := &testSender{}
+		c := DefaultConfig()
+		c.BulkMaxSize = 2
+		r := newTestReporter(t, s, c)
+
+		report(t, r, "a", "b", "c")
+		require.NoError(t, r.flush(context.Background()))
+		assert.Equal(t, []string{"a", "b", "c"}, s.indexed)
+		assert.Equal(t, 2, s.requests)
+		assert.Empty(t, r.queue)
+	})
+
+	t.Run("retryable rejections are sent again", func(t *testing.T) {
+		s := &testSender{statuses: [][]int{{http.StatusCreated, http.StatusTooManyRequests, http.StatusBadRequest}}}
+		r := newTestReporter(t, s, DefaultConfig())
+
+		report(t, r, "a", "b", "c")
+		assert.Error(t, r.flush(context.Background()))
+		assert.Equal(t, []string{"a"}, s.indexed)
+		assert.Len(t, r.queue, 1)
+
+		report(t, r, "d")
+		require.NoError(t, r.flush(context.Background()))
+		assert.Equal(t, []string{"a", "b", "d"}, s.indexed)
+	})
+
+	t.Run("oldest events are dropped when the queue is full", func(t *testing.T) {
+		s := &testSender{}
+		c := DefaultConfig()
+		c.QueueSize = 2
+		r := newTestReporter(t, s, c)
+
+		report(t, r, "a", "b", "c")
+		require.NoError(t, r.flush(context.Background()))
+		assert.Equal(t, []string{"b", "c"}, s.indexed)
+	})
+
+	t.Run("remaining events are sent on close", func(t *testing.T) {
+		s := &testSender{}
+		c := DefaultConfig()
+		c.APIKey = "id:key"
+		r := newTestReporter(t, s, c)
+
+		report(t, r, "a")
+		require.NoError(t, r.Close())
+		assert.Equal(t, []string{"a"}, s.indexed)
+		assert.True(t, strings.HasPrefix(s.headers.Get("Authorization"), "ApiKey "))
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package file reports the events of the agent into local files, one JSON document per line, the
+// events stay observable on the host when Fleet cannot be reached.
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+)
+
+// Config is the configuration of the file reporter.
+type Config struct {
+	Enabled bool `yaml:"enabled" config:"enabled"`
+	// Path of the file, the rotated files are suffixed with a number. Defaults to
+	// elastic-agent-events.ndjson in the logs directory of the agent.
+	Path string `yaml:"path,omitempty" config:"path"`
+	// MaxSize is the size in bytes of a file before it is rotated.
+	MaxSize uint `yaml:"max_size" config:"max_size" validate:"min=1"`
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups uint `yaml:"max_backups" config:"max_backups"`
+}
+
+// DefaultConfig creates a config with the file reporter disabled.
+func DefaultConfig() *Config {
+	return &Config{
+		MaxSize:    10 * 1024 * 1024,
+		MaxBackups: 7,
+	}
+}
+
+// document is a line of the file.
+type document struct {
+	Timestamp time.Time              `json:"@timestamp"`
+	AgentID   string                 `json:"agent_id"`
+	Type      string                 `json:"type"`
+	SubType   string                 `json:"subtype"`
+	Message   string                 `json:"message"`
+	Payload   map[string]interface{} `json:"payload,omitempty"`
+}
+
+type agentInfo interface {
+	AgentID() string
+}
+
+// Reporter appends the events to a file rotated on its size, an event is written before Report
+// returns so the reporter has no events waiting to be acknowledged.
+type Reporter struct {
+	info agentInfo
+
+	mx sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewReporter opens the file the events are appended to.
+func NewReporter(info agentInfo, c *Config) (*Reporter, error) {
+	rotator, err := file.NewFileRotator(c.Path,
+		file.MaxSizeBytes(c.MaxSize),
+		file.MaxBackups(c.MaxBackups),
+		file.Permissions(0600),
+		file.RotateOnStartup(false),
+	)
+	if err != nil {
+		return nil, errors.New(err, "failed to open the events file", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, c.Path))
+	}
+	return newReporter(info, rotator), nil
+}
+
+func newReporter(info agentInfo, w io.WriteCloser) *Reporter {
+	return &Reporter{info: info, w: w}
+}
+
+// Report writes the event to the file.
+func (r *Reporter) Report(_ context.Context, e reporter.Event) error {
+	data, err := json.Marshal(document{
+		Timestamp: e.Time(),
+		AgentID:   r.info.AgentID(),
+		Type:      e.Type(),
+		SubType:   e.SubType(),
+		Message:   e.Message(),
+		Payload:   e.Payload(),
+	})
+	if err != nil {
+		return errors.New(err, "failed to encode the event", errors.TypeUnexpected)
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		return errors.New(err, "failed to write the event", errors.TypeFilesystem)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (r *Reporter) Close() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.w.Close()
+}
+
+// Check it is reporter.Backend.
+var _ reporter.Backend = &Reporter{}
@@ -0,0 +1,66 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+)
+
+type testInfo struct{}
+
+func (testInfo) AgentID() string { return "agent-1" }
+
+type testEvent struct {
+	message string
+}
+
+func (testEvent) Type() string                    { return reporter.EventTypeState }
+func (testEvent) SubType() string                 { return reporter.EventSubTypeRunning }
+func (testEvent) Time() time.Time                 { return time.Unix(10, 0).UTC() }
+func (e testEvent) Message() string               { return e.message }
+func (testEvent) Payload() map[string]interface{} { return nil }
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	var buf bufferCloser
+	r := newReporter(testInfo{}, &buf)
+
+	require.NoError(t, r.Report(context.Background(), testEvent{message: "first"}))
+	require.NoError(t, r.Report(context.Background(), testEvent{message: "second"}))
+	require.NoError(t, r.Close())
+	assert.True(t, buf.closed)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var doc document
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &doc))
+	assert.Equal(t, document{
+		Timestamp: time.Unix(10, 0).UTC(),
+		AgentID:   "agent-1",
+		Type:      reporter.EventTypeState,
+		SubType:   reporter.EventSubTypeRunning,
+		Message:   "second",
+	}, doc)
+}
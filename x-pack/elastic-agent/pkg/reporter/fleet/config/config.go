@@ -4,10 +4,109 @@
 
 package config
 
+import (
+	"fmt"
+	"time"
+)
+
+// Severity is the importance of an event reported to Fleet.
+type Severity string
+
+const (
+	// SeverityInfo is the severity of the events describing regular state changes.
+	SeverityInfo Severity = "info"
+	// SeverityWarning is the severity of the events describing applications stopping.
+	SeverityWarning Severity = "warning"
+	// SeverityError is the severity of the events describing errors and failures.
+	SeverityError Severity = "error"
+)
+
+var severityLevels = map[Severity]int{
+	SeverityInfo:    0,
+	SeverityWarning: 1,
+	SeverityError:   2,
+}
+
+// Unpack the severity.
+func (s *Severity) Unpack(from string) error {
+	if _, ok := severityLevels[Severity(from)]; !ok {
+		return fmt.Errorf("invalid severity %s, accepted values are 'info', 'warning' and 'error'", from)
+	}
+
+	*s = Severity(from)
+	return nil
+}
+
+// AtLeast returns true when the severity is equal or above the other severity, an empty severity
+// is considered as info.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityLevels[s] >= severityLevels[other]
+}
+
+// DropPolicy selects the events dropped when the queue of events is full.
+type DropPolicy string
+
+const (
+	// DropOldest drops the oldest events first.
+	DropOldest DropPolicy = "drop_oldest"
+	// DropNewest drops the newest events first.
+	DropNewest DropPolicy = "drop_newest"
+)
+
+// Unpack the drop policy.
+func (p *DropPolicy) Unpack(from string) error {
+	switch DropPolicy(from) {
+	case DropOldest, DropNewest:
+		*p = DropPolicy(from)
+		return nil
+	default:
+		return fmt.Errorf("invalid drop policy %s, accepted values are 'drop_oldest' and 'drop_newest'", from)
+	}
+}
+
+// RateLimit limits the number of events accepted for Fleet during a period, events over the limit
+// are dropped. Error events are never dropped by the limit. Zero events disables the rate limiting.
+type RateLimit struct {
+	Events int           `yaml:"events" config:"events" validate:"min=0"`
+	Period time.Duration `yaml:"period" config:"period" validate:"positive"`
+}
+
+// Spool keeps on disk the events over the threshold instead of dropping them, the oldest spooled
+// events are dropped once the spool reaches its maximum size.
+type Spool struct {
+	Enabled bool `yaml:"enabled" config:"enabled"`
+	// Path is the directory of the segment files, defaults to events_spool in the data directory.
+	Path string `yaml:"path,omitempty" config:"path"`
+	// MaxSize is the maximum size in bytes of the spooled events.
+	MaxSize int64 `yaml:"max_size" config:"max_size" validate:"min=1"`
+	// SegmentSize is the size in bytes of a segment file, whole segments are evicted.
+	SegmentSize int64 `yaml:"segment_size" config:"segment_size" validate:"min=1"`
+}
+
+// Validate the spool, a segment cannot be larger than the spool.
+func (s *Spool) Validate() error {
+	if s.SegmentSize > s.MaxSize {
+		return fmt.Errorf("spool segment_size %d cannot be larger than max_size %d", s.SegmentSize, s.MaxSize)
+	}
+	return nil
+}
+
 // Config is a configuration describing fleet connected parts
 type Config struct {
-	Threshold               int `yaml:"threshold" config:"threshold" validate:"min=1"`
-	ReportingCheckFrequency int `yaml:"check_frequency_sec" config:"check_frequency_sec" validate:"min=1"`
+	Threshold               int       `yaml:"threshold" config:"threshold" validate:"min=1"`
+	ReportingCheckFrequency int       `yaml:"check_frequency_sec" config:"check_frequency_sec" validate:"min=1"`
+	MinSeverity             Severity  `yaml:"min_severity,omitempty" config:"min_severity"`
+	RateLimit               RateLimit `yaml:"rate_limit" config:"rate_limit"`
+	// DropPolicy selects the events dropped once the threshold is reached, error events are
+	// always dropped last.
+	DropPolicy DropPolicy `yaml:"drop_policy,omitempty" config:"drop_policy"`
+	// CollapseWindow is the period during which identical events are collapsed into a single
+	// event, zero disables the collapsing.
+	CollapseWindow time.Duration `yaml:"collapse_window" config:"collapse_window" validate:"min=0"`
+	Spool          Spool         `yaml:"spool" config:"spool"`
+	// DrainTimeout bounds the last checkin sending the pending events when the agent stops, zero
+	// leaves the pending events to the next run.
+	DrainTimeout time.Duration `yaml:"drain_timeout" config:"drain_timeout" validate:"min=0"`
 }
 
 // DefaultConfig initiates FleetManagementConfig with default values
@@ -15,5 +114,17 @@ func DefaultConfig() *Config {
 	return &Config{
 		Threshold:               10000,
 		ReportingCheckFrequency: 30,
+		MinSeverity:             SeverityInfo,
+		RateLimit: RateLimit{
+			Events: 0,
+			Period: time.Minute,
+		},
+		CollapseWindow: 0,
+		DropPolicy:     DropOldest,
+		Spool: Spool{
+			MaxSize:     100 * 1024 * 1024,
+			SegmentSize: 1024 * 1024,
+		},
+		DrainTimeout: 5 * time.Second,
 	}
 }
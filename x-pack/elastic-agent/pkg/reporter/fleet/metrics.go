@@ -0,0 +1,38 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+)
+
+// metricsRegistryName is the name of the registry holding the reporter metrics in the stats namespace.
+const metricsRegistryName = "fleet_reporter"
+
+type reporterMetrics struct {
+	eventsQueued   *monitoring.Int  // Number of events waiting to be sent to fleet.
+	eventsDropped  *monitoring.Uint // Number of events dropped because of the threshold, of the rate limit or of their rejections.
+	eventsRejected *monitoring.Uint // Number of events rejected by fleet, an event is counted once per rejection.
+	eventsSpooled  *monitoring.Int  // Number of events kept on disk waiting for room in the queue.
+}
+
+// reporterRegistry returns an empty registry for the reporter metrics under the stats namespace,
+// metrics left by a previously created reporter are discarded.
+func reporterRegistry() *monitoring.Registry {
+	parent := monitoring.GetNamespace("stats").GetRegistry()
+	if parent.GetRegistry(metricsRegistryName) != nil {
+		parent.Remove(metricsRegistryName)
+	}
+	return parent.NewRegistry(metricsRegistryName)
+}
+
+func newReporterMetrics(reg *monitoring.Registry) *reporterMetrics {
+	return &reporterMetrics{
+		eventsQueued:   monitoring.NewInt(reg, "events_queued"),
+		eventsDropped:  monitoring.NewUint(reg, "events_dropped_total"),
+		eventsRejected: monitoring.NewUint(reg, "events_rejected_total"),
+		eventsSpooled:  monitoring.NewInt(reg, "events_spooled"),
+	}
+}
@@ -5,7 +5,12 @@
 package fleet
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"sync"
 	"time"
 
@@ -13,6 +18,24 @@ import (
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet/config"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/tokenbucket"
+)
+
+const (
+	// payload keys describing the identical events collapsed into a single event.
+	occurrencesKey = "occurrences"
+	firstSeenKey   = "first_seen"
+	lastSeenKey    = "last_seen"
+
+	// droppedEventsKey is the payload key of the number of dropped events.
+	droppedEventsKey = "dropped_events"
+
+	// maxEventRejections is the number of times fleet can reject an event before it is dropped.
+	maxEventRejections = 3
+
+	// defaultPersistDelay is the time during which the changes of the queue are batched before the
+	// queue is persisted.
+	defaultPersistDelay = time.Second
 )
 
 type event struct {
@@ -22,6 +45,18 @@ type event struct {
 	SubType   string                 `json:"subtype"`
 	Msg       string                 `json:"message"`
 	Payload   map[string]interface{} `json:"payload,omitempty"`
+
+	// Sequence orders the events reported by the agent and lets fleet detect the events received
+	// more than once, sequences are never reused by the agent even after a restart. The event
+	// telling fleet about the dropped events has no sequence.
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// persistedEvents is the content of the store, the last sequence is kept with the events so it
+// survives the acknowledgment of all the events.
+type persistedEvents struct {
+	Sequence uint64   `json:"sequence"`
+	Events   []*event `json:"events"`
 }
 
 func (e *event) Type() string {
@@ -38,69 +73,259 @@ func (e *event) Message() string {
 
 // Reporter is a reporter without any effects, serves just as a showcase for further implementations.
 type Reporter struct {
-	info      agentInfo
-	logger    *logger.Logger
-	queue     []fleetapi.SerializableEvent
-	qlock     sync.Mutex
-	threshold int
-	lastAck   time.Time
+	info        agentInfo
+	logger      *logger.Logger
+	queue       []fleetapi.SerializableEvent
+	qlock       sync.Mutex
+	threshold   int
+	dropPolicy  config.DropPolicy
+	minSeverity config.Severity
+	metrics     *reporterMetrics
+	lastAck     time.Time
+	store       eventStore
+	// spool keeps on disk the events over the threshold, nil when the spool is disabled.
+	spool *spool
+	// sequence is the sequence of the last reported event.
+	sequence uint64
+	// acked is the highest sequence of the events acknowledged by fleet.
+	acked uint64
+
+	limiter     *tokenbucket.Bucket
+	rateLimited int
+	// unreported is the number of dropped events fleet was not told about yet.
+	unreported     int
+	collapseWindow time.Duration
+	// handedOut contains the events of the last batch returned by EventsBatch, they are not
+	// modified anymore as they may be on their way to fleet.
+	handedOut map[fleetapi.SerializableEvent]struct{}
+	// rejections counts the times fleet rejected the events still in the queue.
+	rejections map[fleetapi.SerializableEvent]int
+	closeOnce  sync.Once
+
+	// the queue is persisted from a timer so reporting does not wait for the disk, persistMx
+	// orders the writes to the store.
+	persistDelay time.Duration
+	persistMx    sync.Mutex
+	persistTimer *time.Timer
+	dirty        bool
 }
 
 type agentInfo interface {
 	AgentID() string
 }
 
+type eventStore interface {
+	Save(io.Reader) error
+	Load() (io.ReadCloser, error)
+}
+
 // NewReporter creates a new fleet reporter.
 func NewReporter(agentInfo agentInfo, l *logger.Logger, c *config.Config) (*Reporter, error) {
 	r := &Reporter{
-		info:      agentInfo,
-		queue:     make([]fleetapi.SerializableEvent, 0),
-		logger:    l,
-		threshold: c.Threshold,
+		info:        agentInfo,
+		queue:       make([]fleetapi.SerializableEvent, 0),
+		logger:      l,
+		threshold:   c.Threshold,
+		dropPolicy:  c.DropPolicy,
+		minSeverity: c.MinSeverity,
+		metrics:     newReporterMetrics(reporterRegistry()),
+
+		collapseWindow: c.CollapseWindow,
+		handedOut:      make(map[fleetapi.SerializableEvent]struct{}),
+		persistDelay:   defaultPersistDelay,
+	}
+
+	if c.RateLimit.Events > 0 {
+		limiter, err := tokenbucket.NewTokenBucket(
+			context.Background(),
+			c.RateLimit.Events,
+			c.RateLimit.Events,
+			c.RateLimit.Period,
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.limiter = limiter
+	}
+
+	return r, nil
+}
+
+// NewReporterWithStore creates a new fleet reporter which keeps the events not yet acknowledged
+// by fleet in the store, events persisted by a previous run are loaded back in the queue. When
+// the spool is enabled the events over the threshold are spooled on disk instead of being dropped.
+func NewReporterWithStore(agentInfo agentInfo, l *logger.Logger, c *config.Config, store eventStore) (*Reporter, error) {
+	r, err := NewReporter(agentInfo, l, c)
+	if err != nil {
+		return nil, err
 	}
 
+	if c.Spool.Enabled {
+		r.spool, err = newSpool(c.Spool.Path, c.Spool.MaxSize, c.Spool.SegmentSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.store = store
+	r.queue = r.load()
+	if r.spool != nil && r.spool.len() > 0 {
+		r.logger.Infof("fleet reporter restored %d spooled events", r.spool.len())
+		r.refill()
+		r.queueChanged()
+	}
+	r.metrics.eventsQueued.Set(int64(len(r.queue)))
 	return r, nil
 }
 
+// SetMinSeverity changes the minimum severity of the events reported, the events already queued
+// are still sent.
+func (r *Reporter) SetMinSeverity(s config.Severity) {
+	r.qlock.Lock()
+	defer r.qlock.Unlock()
+	r.minSeverity = s
+}
+
 // Report enqueue event into reporter queue.
 func (r *Reporter) Report(ctx context.Context, e reporter.Event) error {
 	r.qlock.Lock()
 	defer r.qlock.Unlock()
 
-	r.queue = append(r.queue, &event{
+	sev := severity(e)
+	if !sev.AtLeast(r.minSeverity) {
+		return nil
+	}
+
+	if r.collapse(e) {
+		r.queueChanged()
+		return nil
+	}
+
+	// errors, failures and action results are what fleet needs during a burst like a crash loop.
+	if r.limiter != nil && sev != config.SeverityError && !r.limiter.TryAdd() {
+		r.rateLimited++
+		r.recordDrop()
+		return nil
+	}
+
+	if r.rateLimited > 0 {
+		r.logger.Warnf("fleet reporter dropped %d events because the rate limit was reached", r.rateLimited)
+		r.rateLimited = 0
+	}
+
+	r.sequence++
+	ev := &event{
 		AgentID:   r.info.AgentID(),
 		EventType: e.Type(),
 		Ts:        fleetapi.Time(e.Time()),
 		SubType:   e.SubType(),
 		Msg:       e.Message(),
 		Payload:   e.Payload(),
-	})
+		Sequence:  r.sequence,
+	}
+	if r.spooled(ev) {
+		r.queueChanged()
+		return nil
+	}
+	r.queue = append(r.queue, ev)
 
 	if r.threshold > 0 && len(r.queue) > r.threshold {
 		// drop some low importance event if needed
 		r.dropEvent()
 	}
 
+	r.queueChanged()
 	return nil
 }
 
+// Pending returns the number of events waiting to be sent to fleet, the spooled events and the
+// report of the dropped events included.
+func (r *Reporter) Pending() int {
+	r.qlock.Lock()
+	defer r.qlock.Unlock()
+
+	n := len(r.queue)
+	if r.spool != nil {
+		n += r.spool.len()
+	}
+	if r.unreported > 0 {
+		n++
+	}
+	return n
+}
+
+// AckedSequence returns the highest sequence of the events acknowledged by fleet.
+func (r *Reporter) AckedSequence() uint64 {
+	r.qlock.Lock()
+	defer r.qlock.Unlock()
+	return r.acked
+}
+
+// RestoreSequence restores the highest sequence acknowledged by fleet before a restart, the
+// sequences of the next events follow it even when the persisted events were lost.
+func (r *Reporter) RestoreSequence(acked uint64) {
+	r.qlock.Lock()
+	defer r.qlock.Unlock()
+
+	if acked > r.acked {
+		r.acked = acked
+	}
+	if acked > r.sequence {
+		r.logger.Infof("fleet reporter resumes the event sequence after %d", acked)
+		r.sequence = acked
+	}
+}
+
 // Events returns a list of event from a queue and a ack function
 // which clears those events once caller is done with processing.
-func (r *Reporter) Events() ([]fleetapi.SerializableEvent, func()) {
+func (r *Reporter) Events() ([]fleetapi.SerializableEvent, func(rejected ...int)) {
+	return r.EventsBatch(0)
+}
+
+// EventsBatch returns at most size of the oldest events from the queue and a ack function
+// which clears those events once caller is done with processing, the remaining events are kept
+// for a later batch. A size of zero returns all the events.
+//
+// The ack function receives the indexes in the batch of the events rejected by fleet, these
+// events are kept in the queue and sent again with the next batch. An event rejected 3 times is
+// dropped.
+func (r *Reporter) EventsBatch(size int) ([]fleetapi.SerializableEvent, func(rejected ...int)) {
 	r.qlock.Lock()
 	defer r.qlock.Unlock()
 
-	cp := r.queueCopy()
+	cp := r.queueCopy(size)
+	// events of a batch never acked are part of the next batch, only the latest one matters.
+	r.handedOut = make(map[fleetapi.SerializableEvent]struct{}, len(cp))
+	for _, e := range cp {
+		r.handedOut[e] = struct{}{}
+	}
+
+	// drops are reported with every batch until one of them is acked.
+	unreported := r.unreported
+	batch := cp
+	if unreported > 0 {
+		batch = append(cp[:len(cp):len(cp)], r.droppedEvent(unreported))
+	}
+
+	ackFn := func(rejected ...int) {
+		isRejected := make(map[int]bool, len(rejected))
+		for _, idx := range rejected {
+			isRejected[idx] = true
+		}
 
-	ackFn := func() {
 		// as time is monotonic and this is on single machine this should be ok.
-		r.clear(cp, time.Now())
+		r.clear(cp, isRejected, time.Now())
+		if unreported > 0 && !isRejected[len(cp)] {
+			r.reported(unreported)
+		}
 	}
 
-	return cp, ackFn
+	return batch, ackFn
 }
 
-func (r *Reporter) clear(items []fleetapi.SerializableEvent, ackTime time.Time) {
+// clear removes the acked events of the batch from the queue, the events rejected by fleet are
+// kept unless they were rejected too many times.
+func (r *Reporter) clear(items []fleetapi.SerializableEvent, rejected map[int]bool, ackTime time.Time) {
 	r.qlock.Lock()
 	defer r.qlock.Unlock()
 
@@ -111,31 +336,153 @@ func (r *Reporter) clear(items []fleetapi.SerializableEvent, ackTime time.Time)
 		return
 	}
 
-	var dropIdx int
 	r.lastAck = ackTime
-	itemsLen := len(items)
-
-OUTER:
-	for idx := itemsLen - 1; idx >= 0; idx-- {
-		for i, v := range r.queue {
-			if v == items[idx] {
-				dropIdx = i
-				break OUTER
-			}
+	acked := make(map[fleetapi.SerializableEvent]struct{}, len(items))
+	for idx, e := range items {
+		if rejected[idx] && !r.rejected(e) {
+			continue
+		}
+		acked[e] = struct{}{}
+		if ev, ok := e.(*event); ok && ev.Sequence > r.acked {
+			r.acked = ev.Sequence
+		}
+	}
+
+	queue := make([]fleetapi.SerializableEvent, 0, len(r.queue))
+	kept := make(map[fleetapi.SerializableEvent]struct{}, len(r.queue))
+	for _, e := range r.queue {
+		if _, ok := acked[e]; ok {
+			delete(r.handedOut, e)
+			continue
+		}
+		queue = append(queue, e)
+		kept[e] = struct{}{}
+	}
+	r.queue = queue
+	r.refill()
+
+	// rejections of the events which left the queue, acked or dropped, are forgotten.
+	for e := range r.rejections {
+		if _, ok := kept[e]; !ok {
+			delete(r.rejections, e)
 		}
 	}
+	r.queueChanged()
+}
+
+// rejected records a rejection of the event by fleet, it returns false when the event is kept to
+// be sent again and true when it was rejected too many times and is dropped. Must be called with
+// the queue locked.
+func (r *Reporter) rejected(e fleetapi.SerializableEvent) bool {
+	if r.metrics != nil {
+		r.metrics.eventsRejected.Inc()
+	}
+
+	if r.rejections == nil {
+		r.rejections = make(map[fleetapi.SerializableEvent]int)
+	}
+	r.rejections[e]++
+	if r.rejections[e] < maxEventRejections {
+		return false
+	}
 
-	r.queue = r.queue[dropIdx+1:]
+	r.logger.Warnf("fleet reporter dropped event rejected %d times by fleet: %v", r.rejections[e], e)
+	r.recordDrop()
+	return true
 }
 
-// Close stops all the background jobs reporter is running.
+// Close stops all the background jobs reporter is running, the pending changes of the queue are
+// persisted.
 // Guards agains panic of closing channel multiple times.
 func (r *Reporter) Close() error {
+	r.qlock.Lock()
+	if r.persistTimer != nil {
+		r.persistTimer.Stop()
+		r.persistTimer = nil
+	}
+	r.qlock.Unlock()
+	r.flush()
+
+	r.closeOnce.Do(func() {
+		if r.limiter != nil {
+			r.qlock.Lock()
+			r.limiter.Close()
+			r.limiter = nil
+			r.qlock.Unlock()
+		}
+		if r.spool != nil {
+			r.qlock.Lock()
+			r.spool.close()
+			r.qlock.Unlock()
+		}
+	})
 	return nil
 }
 
-func (r *Reporter) queueCopy() []fleetapi.SerializableEvent {
+// collapse merges the event into an identical event still waiting in the queue, it returns
+// false when no such event was seen during the collapse window. Must be called with the queue
+// locked.
+func (r *Reporter) collapse(e reporter.Event) bool {
+	if r.collapseWindow <= 0 {
+		return false
+	}
+
+	for i := len(r.queue) - 1; i >= 0; i-- {
+		queued, ok := r.queue[i].(*event)
+		if !ok || r.isHandedOut(queued) {
+			continue
+		}
+
+		if queued.EventType != e.Type() || queued.SubType != e.SubType() || queued.Msg != e.Message() {
+			continue
+		}
+
+		firstSeen := queued.Timestamp()
+		if e.Time().Sub(firstSeen) > r.collapseWindow {
+			return false
+		}
+
+		// the queued event is replaced and never modified in place.
+		payload := make(map[string]interface{}, len(queued.Payload)+3)
+		for k, v := range queued.Payload {
+			payload[k] = v
+		}
+		payload[occurrencesKey] = occurrences(queued) + 1
+		payload[firstSeenKey] = fleetapi.Time(firstSeen)
+		payload[lastSeenKey] = fleetapi.Time(e.Time())
+
+		collapsed := *queued
+		collapsed.Payload = payload
+		r.queue[i] = &collapsed
+		return true
+	}
+
+	return false
+}
+
+func (r *Reporter) isHandedOut(e fleetapi.SerializableEvent) bool {
+	_, ok := r.handedOut[e]
+	return ok
+}
+
+// occurrences returns the number of identical events collapsed into the event, events restored
+// from the store carry the count decoded as a float.
+func occurrences(e *event) int {
+	switch v := e.Payload[occurrencesKey].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 1
+	}
+}
+
+func (r *Reporter) queueCopy(max int) []fleetapi.SerializableEvent {
 	size := len(r.queue)
+	if max > 0 && size > max {
+		size = max
+	}
 	batch := make([]fleetapi.SerializableEvent, size)
 
 	copy(batch, r.queue)
@@ -143,32 +490,264 @@ func (r *Reporter) queueCopy() []fleetapi.SerializableEvent {
 }
 
 func (r *Reporter) dropEvent() {
-	if dropped := r.tryDropInfo(); !dropped {
-		r.dropFirst()
+	if len(r.queue) == 0 {
+		return
 	}
+
+	idx := r.dropCandidate()
+	dropped := r.queue[idx]
+	r.queue = append(r.queue[:idx], r.queue[idx+1:]...)
+	r.logger.Infof("fleet reporter dropped event because threshold[%d] was reached: %v", r.threshold, dropped)
+	r.recordDrop()
 }
 
-// tryDropInfo returns true if info was found and dropped.
-func (r *Reporter) tryDropInfo() bool {
-	for i, e := range r.queue {
-		if e.Type() != reporter.EventTypeError {
-			r.queue = append(r.queue[:i], r.queue[i+1:]...)
-			r.logger.Infof("fleet reporter dropped event because threshold[%d] was reached: %v", r.threshold, e)
-			return true
+// dropCandidate returns the index of the event to drop according to the drop policy, events
+// which are not errors are dropped first.
+func (r *Reporter) dropCandidate() int {
+	last := len(r.queue) - 1
+	newest := r.dropPolicy == config.DropNewest
+
+	for i := range r.queue {
+		idx := i
+		if newest {
+			idx = last - i
+		}
+
+		if r.queue[idx].Type() != reporter.EventTypeError {
+			return idx
 		}
 	}
 
-	return false
+	if newest {
+		return last
+	}
+	return 0
 }
 
-func (r *Reporter) dropFirst() {
-	if len(r.queue) == 0 {
+func (r *Reporter) reported(drops int) {
+	if drops == 0 {
+		return
+	}
+
+	r.qlock.Lock()
+	defer r.qlock.Unlock()
+	r.unreported -= drops
+	if r.unreported < 0 {
+		r.unreported = 0
+	}
+}
+
+func (r *Reporter) recordDrop() {
+	r.unreported++
+	if r.metrics != nil {
+		r.metrics.eventsDropped.Inc()
+	}
+}
+
+// droppedEvent returns an event telling fleet how many events were dropped.
+func (r *Reporter) droppedEvent(count int) *event {
+	return &event{
+		AgentID:   r.info.AgentID(),
+		EventType: reporter.EventTypeState,
+		Ts:        fleetapi.Time(time.Now()),
+		SubType:   reporter.EventSubTypeRunning,
+		Msg:       fmt.Sprintf("Fleet reporter dropped %d events", count),
+		Payload:   map[string]interface{}{droppedEventsKey: count},
+	}
+}
+
+// spooled writes the event to the spool when the queue is full or when older events are already
+// spooled, it returns false when the event has to be queued. Must be called with the queue locked.
+func (r *Reporter) spooled(e *event) bool {
+	if r.spool == nil || (r.spool.len() == 0 && (r.threshold <= 0 || len(r.queue) < r.threshold)) {
+		return false
+	}
+
+	evicted, err := r.spool.append(e)
+	if err != nil {
+		r.logger.Errorf("fleet reporter failed to spool event, the event is queued: %v", err)
+		return false
+	}
+	if evicted > 0 {
+		r.logger.Warnf("fleet reporter dropped %d spooled events because the spool reached its maximum size", evicted)
+		for i := 0; i < evicted; i++ {
+			r.recordDrop()
+		}
+	}
+	return true
+}
+
+// refill moves the oldest spooled events into the queue while the queue is below the threshold,
+// the segments are removed from the spool once the queue is persisted by flush. Must be called with the
+// queue locked.
+func (r *Reporter) refill() {
+	for r.spool != nil && r.spool.len() > 0 && (r.threshold <= 0 || len(r.queue) < r.threshold) {
+		events, err := r.spool.next()
+		if err != nil {
+			r.logger.Errorf("fleet reporter lost spooled events: %v", err)
+			continue
+		}
+		for _, e := range events {
+			if e.Sequence > r.sequence {
+				r.sequence = e.Sequence
+			}
+			r.queue = append(r.queue, e)
+		}
+	}
+}
+
+// queueChanged updates the metrics and schedules the persistence of the queue, the changes made
+// until the timer fires are persisted together. Must be called with the queue locked.
+func (r *Reporter) queueChanged() {
+	if r.metrics != nil {
+		r.metrics.eventsQueued.Set(int64(len(r.queue)))
+		if r.spool != nil {
+			r.metrics.eventsSpooled.Set(int64(r.spool.len()))
+		}
+	}
+
+	if r.store == nil {
+		// the refilled events are only kept in the queue.
+		if r.spool != nil {
+			r.spool.commit(r.spool.uncommitted())
+		}
+		return
+	}
+
+	r.dirty = true
+	if r.persistTimer == nil {
+		r.persistTimer = time.AfterFunc(r.persistDelay, r.flush)
+	}
+}
+
+// flush persists the queue when it changed since the last flush, the queue is encoded with the
+// queue locked and written without holding the lock.
+func (r *Reporter) flush() {
+	r.persistMx.Lock()
+	defer r.persistMx.Unlock()
+
+	r.qlock.Lock()
+	r.persistTimer = nil
+	if !r.dirty || r.store == nil {
+		r.qlock.Unlock()
 		return
 	}
+	r.dirty = false
+	data, err := r.encode()
+	refilled := 0
+	if r.spool != nil {
+		refilled = r.spool.uncommitted()
+	}
+	r.qlock.Unlock()
+
+	if err != nil {
+		r.logger.Errorf("fleet reporter failed to encode events: %v", err)
+		return
+	}
+	if err := r.store.Save(bytes.NewReader(data)); err != nil {
+		r.logger.Errorf("fleet reporter failed to persist events: %v", err)
+		// the queue is persisted again with the next change.
+		r.qlock.Lock()
+		r.dirty = true
+		r.qlock.Unlock()
+		return
+	}
+
+	if refilled > 0 {
+		// the refilled events are persisted with the queue, their segments are not needed anymore.
+		r.qlock.Lock()
+		r.spool.commit(refilled)
+		r.qlock.Unlock()
+	}
+}
+
+// severity returns the severity of the event, action results are always sent to Fleet.
+func severity(e reporter.Event) config.Severity {
+	switch {
+	case e.Type() == reporter.EventTypeError,
+		e.Type() == reporter.EventTypeActionResult,
+		e.SubType() == reporter.EventSubTypeFailed:
+		return config.SeverityError
+	case e.SubType() == reporter.EventSubTypeStopping,
+		e.SubType() == reporter.EventSubTypeStopped:
+		return config.SeverityWarning
+	default:
+		return config.SeverityInfo
+	}
+}
+
+// load returns the events persisted in the store, if the store cannot be read we start with an
+// empty queue.
+func (r *Reporter) load() []fleetapi.SerializableEvent {
+	queue := make([]fleetapi.SerializableEvent, 0)
+	if r.store == nil {
+		return queue
+	}
+
+	reader, err := r.store.Load()
+	if err != nil {
+		r.logger.Errorf("fleet reporter failed to load persisted events: %v", err)
+		return queue
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		r.logger.Errorf("fleet reporter failed to read persisted events: %v", err)
+		return queue
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return queue
+	}
+
+	var persisted persistedEvents
+	if data[0] == '[' {
+		// previous versions only persisted the events.
+		err = json.Unmarshal(data, &persisted.Events)
+	} else {
+		err = json.Unmarshal(data, &persisted)
+	}
+	if err != nil {
+		r.logger.Errorf("fleet reporter failed to decode persisted events: %v", err)
+		return queue
+	}
+
+	r.sequence = persisted.Sequence
+	for _, e := range persisted.Events {
+		if e.Sequence > r.sequence {
+			r.sequence = e.Sequence
+		}
+	}
+	for _, e := range persisted.Events {
+		// events persisted by previous versions were never sent with a sequence.
+		if e.Sequence == 0 {
+			r.sequence++
+			e.Sequence = r.sequence
+		}
+		queue = append(queue, e)
+	}
+
+	if len(queue) > 0 {
+		r.logger.Infof("fleet reporter restored %d events not yet acknowledged", len(queue))
+	}
+	return queue
+}
+
+// encode returns the content of the store for the current queue and the last sequence, must be
+// called with the queue locked.
+func (r *Reporter) encode() ([]byte, error) {
+	persisted := persistedEvents{
+		Sequence: r.sequence,
+		Events:   make([]*event, 0, len(r.queue)),
+	}
+	for _, e := range r.queue {
+		if queued, ok := e.(*event); ok {
+			persisted.Events = append(persisted.Events, queued)
+		}
+	}
 
-	first := r.queue[0]
-	r.logger.Infof("fleet reporter dropped event because threshold[%d] was reached: %v", r.threshold, first)
-	r.queue = r.queue[1:]
+	return json.Marshal(persisted)
 }
 
 // Check it is reporter.Backend.
@@ -5,13 +5,21 @@
 package fleet
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"io/ioutil"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/core/logger"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/fleetapi"
 	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/reporter/fleet/config"
 )
 
 func TestEventsHaveAgentID(t *testing.T) {
@@ -74,11 +82,210 @@ func TestReporting(t *testing.T) {
 		r.Report(context.Background(), e)
 	}
 
-	// check events are dropped
+	// check events are dropped and the drop is reported
 	reportedEvents, _ = r.Events()
-	if reportedCount := len(reportedEvents); reportedCount != threshold {
-		t.Fatalf("expected %v events got %v", secondBatchSize, reportedCount)
+	if reportedCount := len(reportedEvents); reportedCount != threshold+1 {
+		t.Fatalf("expected %v events got %v", threshold+1, reportedCount)
+	}
+	requireDroppedEvent(t, reportedEvents[threshold], 1)
+}
+
+func TestEventsBatch(t *testing.T) {
+	r := newTestReporter(1*time.Second, 10)
+
+	for _, e := range getEvents(5) {
+		r.Report(context.Background(), e)
+	}
+
+	batch, ack := r.EventsBatch(3)
+	if batchSize := len(batch); batchSize != 3 {
+		t.Fatalf("expected %v events got %v", 3, batchSize)
+	}
+	ack()
+
+	// the remaining events are carried over to the next batch.
+	batch, ack = r.EventsBatch(3)
+	if batchSize := len(batch); batchSize != 2 {
+		t.Fatalf("expected %v events got %v", 2, batchSize)
+	}
+	ack()
+
+	if remaining, _ := r.Events(); len(remaining) != 0 {
+		t.Fatalf("expected no events got %v", len(remaining))
+	}
+}
+
+func TestRejectedEvents(t *testing.T) {
+	r := newTestReporter(1*time.Second, 10)
+
+	for _, e := range getEvents(3) {
+		r.Report(context.Background(), e)
+	}
+
+	batch, ack := r.EventsBatch(0)
+	require.Len(t, batch, 3)
+	ack(1)
+
+	// only the rejected event is kept and sent again.
+	remaining, ack := r.EventsBatch(0)
+	require.Len(t, remaining, 1)
+	require.Equal(t, batch[1], remaining[0])
+	require.Equal(t, uint64(1), r.metrics.eventsRejected.Get())
+
+	t.Run("event rejected too many times is dropped", func(t *testing.T) {
+		ack(0)
+		remaining, ack := r.EventsBatch(0)
+		require.Len(t, remaining, 1)
+		ack(0)
+
+		reportedEvents, _ := r.Events()
+		require.Len(t, reportedEvents, 1)
+		requireDroppedEvent(t, reportedEvents[0], 1)
+	})
+}
+
+func TestMinSeverity(t *testing.T) {
+	log, _ := logger.New("", false)
+	c := config.DefaultConfig()
+	c.MinSeverity = config.SeverityWarning
+	r, err := NewReporter(&testInfo{}, log, c)
+	require.NoError(t, err)
+
+	r.Report(context.Background(), testStateEvent{})
+	r.Report(context.Background(), testErrorEvent{})
+
+	reportedEvents, _ := r.Events()
+	require.Len(t, reportedEvents, 1)
+	require.Equal(t, reporter.EventTypeError, reportedEvents[0].Type())
+
+	r.SetMinSeverity(config.SeverityInfo)
+	r.Report(context.Background(), testStateEvent{})
+
+	reportedEvents, _ = r.Events()
+	require.Len(t, reportedEvents, 2)
+	require.Equal(t, reporter.EventTypeState, reportedEvents[1].Type())
+}
+
+func TestSeverityUnpack(t *testing.T) {
+	var s config.Severity
+	require.NoError(t, s.Unpack("warning"))
+	require.Equal(t, config.SeverityWarning, s)
+	require.Error(t, s.Unpack("debug"))
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	log, _ := logger.New("", false)
+	c := config.DefaultConfig()
+	c.CollapseWindow = time.Minute
+	r, err := NewReporter(&testInfo{}, log, c)
+	require.NoError(t, err)
+	defer r.Close()
+
+	r.Report(context.Background(), testStateEvent{})
+	r.Report(context.Background(), testErrorEvent{})
+	r.Report(context.Background(), testStateEvent{})
+	r.Report(context.Background(), testStateEvent{})
+
+	reportedEvents, ack := r.Events()
+	require.Len(t, reportedEvents, 2)
+
+	collapsed := reportedEvents[0].(*event)
+	require.Equal(t, reporter.EventTypeState, collapsed.Type())
+	require.Equal(t, 3, collapsed.Payload[occurrencesKey])
+	require.Equal(t, 1, collapsed.Payload["key"])
+	require.Equal(t, fleetapi.Time(time.Unix(0, 1)), collapsed.Payload[firstSeenKey])
+	require.Equal(t, fleetapi.Time(time.Unix(0, 1)), collapsed.Payload[lastSeenKey])
+
+	// events handed out are not modified anymore.
+	r.Report(context.Background(), testStateEvent{})
+	require.Equal(t, 3, collapsed.Payload[occurrencesKey])
+
+	ack()
+	reportedEvents, _ = r.Events()
+	require.Len(t, reportedEvents, 1)
+	require.Nil(t, reportedEvents[0].(*event).Payload[occurrencesKey])
+}
+
+func TestRateLimit(t *testing.T) {
+	log, _ := logger.New("", false)
+	c := config.DefaultConfig()
+	c.RateLimit = config.RateLimit{Events: 2, Period: time.Hour}
+	r, err := NewReporter(&testInfo{}, log, c)
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, e := range getEvents(5) {
+		r.Report(context.Background(), e)
 	}
+
+	reportedEvents, _ := r.Events()
+	require.Len(t, reportedEvents, 3)
+	require.Equal(t, 3, r.rateLimited)
+	requireDroppedEvent(t, reportedEvents[2], 3)
+
+	t.Run("error events are not rate limited", func(t *testing.T) {
+		r.Report(context.Background(), testErrorEvent{})
+
+		reportedEvents, _ := r.Events()
+		require.Len(t, reportedEvents, 4)
+		require.Equal(t, reporter.EventTypeError, reportedEvents[2].Type())
+	})
+}
+
+func TestDropPolicy(t *testing.T) {
+	queued := func(policy config.DropPolicy) []fleetapi.SerializableEvent {
+		r := newTestReporter(1*time.Second, 2)
+		r.dropPolicy = policy
+
+		r.Report(context.Background(), testStateEvent{})
+		r.Report(context.Background(), testErrorEvent{})
+		r.Report(context.Background(), testNamedStateEvent("newest"))
+
+		r.qlock.Lock()
+		defer r.qlock.Unlock()
+		return r.queueCopy(0)
+	}
+
+	t.Run("drop oldest", func(t *testing.T) {
+		events := queued(config.DropOldest)
+		require.Len(t, events, 2)
+		require.Equal(t, reporter.EventTypeError, events[0].Type())
+		require.Equal(t, "newest", events[1].Message())
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		events := queued(config.DropNewest)
+		require.Len(t, events, 2)
+		require.Equal(t, "hello", events[0].Message())
+		require.Equal(t, reporter.EventTypeError, events[1].Type())
+	})
+}
+
+func TestDroppedEventsAreReported(t *testing.T) {
+	r := newTestReporter(1*time.Second, 1)
+
+	r.Report(context.Background(), testStateEvent{})
+	r.Report(context.Background(), testStateEvent{})
+	require.Equal(t, uint64(1), r.metrics.eventsDropped.Get())
+
+	// the drop is reported until a batch is acked.
+	reportedEvents, _ := r.Events()
+	require.Len(t, reportedEvents, 2)
+	reportedEvents, ack := r.Events()
+	require.Len(t, reportedEvents, 2)
+	requireDroppedEvent(t, reportedEvents[1], 1)
+
+	ack()
+	reportedEvents, _ = r.Events()
+	require.Len(t, reportedEvents, 0)
+	require.Equal(t, int64(0), r.metrics.eventsQueued.Get())
+}
+
+func TestDropPolicyUnpack(t *testing.T) {
+	var p config.DropPolicy
+	require.NoError(t, p.Unpack("drop_newest"))
+	require.Equal(t, config.DropNewest, p)
+	require.Error(t, p.Unpack("drop_all"))
 }
 
 func TestInfoDrop(t *testing.T) {
@@ -95,9 +302,10 @@ func TestInfoDrop(t *testing.T) {
 
 	// check after delay for output
 	reportedEvents, _ := r.Events()
-	if reportedCount := len(reportedEvents); reportedCount != 2 {
-		t.Fatalf("expected %v events got %v", 2, reportedCount)
+	if reportedCount := len(reportedEvents); reportedCount != 3 {
+		t.Fatalf("expected %v events got %v", 3, reportedCount)
 	}
+	requireDroppedEvent(t, reportedEvents[2], 1)
 
 	// check both are errors
 	if reportedEvents[0].Type() != reportedEvents[1].Type() || reportedEvents[0].Type() != reporter.EventTypeError {
@@ -185,20 +393,192 @@ func TestAfterDrop(t *testing.T) {
 
 	// check all events are returned
 	reportedEvents2, _ := r.Events()
-	if reportedCount := len(reportedEvents2); reportedCount != threshold {
-		t.Fatalf("expected %v events got %v", secondBatchSize, reportedCount)
+	if reportedCount := len(reportedEvents2); reportedCount != threshold+1 {
+		t.Fatalf("expected %v events got %v", threshold+1, reportedCount)
 	}
 
 	// remove first batch from queue
 	ack1()
 
+	// the drops are still reported as the second batch was not acked.
 	reportedEvents, _ := r.Events()
-	if reportedCount := len(reportedEvents); reportedCount != secondBatchSize {
+	if reportedCount := len(reportedEvents); reportedCount != secondBatchSize+1 {
 		t.Fatalf("expected all events from first batch are removed, got %v events", reportedCount)
 	}
 
 }
 
+func TestPersistedEvents(t *testing.T) {
+	log, _ := logger.New("", false)
+	store := &memoryStore{}
+	c := config.DefaultConfig()
+
+	r, err := NewReporterWithStore(&testInfo{}, log, c, store)
+	require.NoError(t, err)
+
+	for _, e := range getEvents(3) {
+		r.Report(context.Background(), e)
+	}
+	// the changes of the queue are persisted together.
+	require.Equal(t, 0, store.saveCount())
+	require.NoError(t, r.Close())
+	require.Equal(t, 1, store.saveCount())
+
+	// simulate a restart before events are acked.
+	restored, err := NewReporterWithStore(&testInfo{}, log, c, store)
+	require.NoError(t, err)
+
+	reportedEvents, ack := restored.Events()
+	require.Len(t, reportedEvents, 3)
+	require.Equal(t, "hello", reportedEvents[0].Message())
+	require.Equal(t, time.Unix(0, 1).UTC(), reportedEvents[0].Timestamp().UTC())
+
+	// acked events are removed from the store.
+	ack()
+	require.NoError(t, restored.Close())
+	restored, err = NewReporterWithStore(&testInfo{}, log, c, store)
+	require.NoError(t, err)
+
+	reportedEvents, _ = restored.Events()
+	require.Len(t, reportedEvents, 0)
+
+	t.Run("queue is persisted by the timer", func(t *testing.T) {
+		store := &memoryStore{}
+		r, err := NewReporterWithStore(&testInfo{}, log, c, store)
+		require.NoError(t, err)
+		r.persistDelay = 10 * time.Millisecond
+
+		for _, e := range getEvents(3) {
+			r.Report(context.Background(), e)
+		}
+		require.Eventually(t, func() bool { return store.saveCount() == 1 }, time.Second, time.Millisecond)
+
+		restored, err := NewReporterWithStore(&testInfo{}, log, c, store)
+		require.NoError(t, err)
+		reportedEvents, _ := restored.Events()
+		require.Len(t, reportedEvents, 3)
+		require.NoError(t, r.Close())
+		require.Equal(t, 1, store.saveCount())
+	})
+}
+
+func TestEventSequence(t *testing.T) {
+	log, _ := logger.New("", false)
+	store := &memoryStore{}
+	c := config.DefaultConfig()
+
+	sequences := func(events []fleetapi.SerializableEvent) []uint64 {
+		var seqs []uint64
+		for _, e := range events {
+			seqs = append(seqs, e.(*event).Sequence)
+		}
+		return seqs
+	}
+
+	r, err := NewReporterWithStore(&testInfo{}, log, c, store)
+	require.NoError(t, err)
+	for _, e := range getEvents(2) {
+		r.Report(context.Background(), e)
+	}
+	reportedEvents, ack := r.Events()
+	require.Equal(t, []uint64{1, 2}, sequences(reportedEvents))
+	ack()
+	require.NoError(t, r.Close())
+
+	// the sequence survives a restart once all the events are acked.
+	restored, err := NewReporterWithStore(&testInfo{}, log, c, store)
+	require.NoError(t, err)
+	restored.Report(context.Background(), testStateEvent{})
+	reportedEvents, _ = restored.Events()
+	require.Equal(t, []uint64{3}, sequences(reportedEvents))
+
+	t.Run("events persisted without sequence", func(t *testing.T) {
+		store := &memoryStore{data: []byte(`[{"type":"STATE","message":"hello"},{"type":"STATE","message":"world"}]`)}
+		r, err := NewReporterWithStore(&testInfo{}, log, c, store)
+		require.NoError(t, err)
+
+		r.Report(context.Background(), testStateEvent{})
+		reportedEvents, _ := r.Events()
+		require.Equal(t, []uint64{1, 2, 3}, sequences(reportedEvents))
+	})
+
+	t.Run("acked sequence is restored", func(t *testing.T) {
+		r, err := NewReporter(&testInfo{}, log, c)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), r.AckedSequence())
+		for _, e := range getEvents(2) {
+			r.Report(context.Background(), e)
+		}
+		_, ack := r.Events()
+		ack()
+		require.Equal(t, uint64(2), r.AckedSequence())
+
+		// the events store was lost, the sequence follows the acked sequence.
+		restored, err := NewReporter(&testInfo{}, log, c)
+		require.NoError(t, err)
+		restored.RestoreSequence(r.AckedSequence())
+		restored.Report(context.Background(), testStateEvent{})
+		reportedEvents, _ := restored.Events()
+		require.Equal(t, []uint64{3}, sequences(reportedEvents))
+
+		// an older acked sequence does not move the sequence back.
+		restored.RestoreSequence(1)
+		require.Equal(t, uint64(2), restored.AckedSequence())
+	})
+}
+
+func TestSpooledEvents(t *testing.T) {
+	log, _ := logger.New("", false)
+	store := &memoryStore{}
+	c := config.DefaultConfig()
+	c.Threshold = 2
+	c.Spool.Enabled = true
+	c.Spool.Path = t.TempDir()
+
+	messages := func(events []fleetapi.SerializableEvent) []string {
+		var msgs []string
+		for _, e := range events {
+			msgs = append(msgs, e.Message())
+		}
+		return msgs
+	}
+
+	r, err := NewReporterWithStore(&testInfo{}, log, c, store)
+	require.NoError(t, err)
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		r.Report(context.Background(), testNamedStateEvent(msg))
+	}
+
+	// events over the threshold are spooled and not dropped.
+	reportedEvents, ack := r.Events()
+	require.Equal(t, []string{"a", "b"}, messages(reportedEvents))
+	require.Equal(t, 0, r.unreported)
+	require.Equal(t, 3, r.spool.len())
+
+	// the acked events make room for the spooled events, in order.
+	ack()
+	reportedEvents, _ = r.Events()
+	require.Equal(t, []string{"c", "d", "e"}, messages(reportedEvents))
+	require.Equal(t, 0, r.spool.len())
+	require.NoError(t, r.Close())
+
+	t.Run("spooled events survive a restart", func(t *testing.T) {
+		r.Report(context.Background(), testNamedStateEvent("f"))
+		require.Equal(t, 1, r.spool.len())
+		require.NoError(t, r.Close())
+
+		restored, err := NewReporterWithStore(&testInfo{}, log, c, store)
+		require.NoError(t, err)
+		require.Equal(t, 1, restored.spool.len())
+		reportedEvents, ack := restored.Events()
+		require.Equal(t, []string{"c", "d", "e"}, messages(reportedEvents))
+		ack()
+		reportedEvents, _ = restored.Events()
+		require.Equal(t, []string{"f"}, messages(reportedEvents))
+		require.NoError(t, restored.Close())
+	})
+}
+
 func getEvents(count int) []reporter.Event {
 	ee := make([]reporter.Event, 0, count)
 	for i := 0; i < count; i++ {
@@ -215,11 +595,19 @@ func newTestReporter(frequency time.Duration, threshold int) *Reporter {
 		queue:     make([]fleetapi.SerializableEvent, 0),
 		logger:    log,
 		threshold: threshold,
+		metrics:   newReporterMetrics(monitoring.NewRegistry()),
 	}
 
 	return r
 }
 
+func requireDroppedEvent(t *testing.T, e fleetapi.SerializableEvent, count int) {
+	t.Helper()
+	dropped, ok := e.(*event)
+	require.True(t, ok)
+	require.Equal(t, count, dropped.Payload[droppedEventsKey])
+}
+
 type testInfo struct{}
 
 func (*testInfo) AgentID() string { return "agentID" }
@@ -232,6 +620,14 @@ func (testStateEvent) Time() time.Time                 { return time.Unix(0, 1)
 func (testStateEvent) Message() string                 { return "hello" }
 func (testStateEvent) Payload() map[string]interface{} { return map[string]interface{}{"key": 1} }
 
+type testNamedStateEvent string
+
+func (testNamedStateEvent) Type() string                    { return reporter.EventTypeState }
+func (testNamedStateEvent) SubType() string                 { return reporter.EventSubTypeInProgress }
+func (testNamedStateEvent) Time() time.Time                 { return time.Unix(0, 1) }
+func (e testNamedStateEvent) Message() string               { return string(e) }
+func (testNamedStateEvent) Payload() map[string]interface{} { return map[string]interface{}{"key": 1} }
+
 type testErrorEvent struct{}
 
 func (testErrorEvent) Type() string                    { return reporter.EventTypeError }
@@ -239,3 +635,33 @@ func (testErrorEvent) SubType() string                 { return "PATH" }
 func (testErrorEvent) Time() time.Time                 { return time.Unix(0, 1) }
 func (testErrorEvent) Message() string                 { return "hello" }
 func (testErrorEvent) Payload() map[string]interface{} { return map[string]interface{}{"key": 1} }
+
+type memoryStore struct {
+	mx    sync.Mutex
+	data  []byte
+	saves int
+}
+
+func (m *memoryStore) Save(in io.Reader) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.data = data
+	m.saves++
+	return nil
+}
+
+func (m *memoryStore) saveCount() int {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.saves
+}
+
+func (m *memoryStore) Load() (io.ReadCloser, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(m.data)), nil
+}
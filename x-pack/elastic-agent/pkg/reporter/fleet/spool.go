@@ -0,0 +1,228 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// segmentExt is the extension of the segment files of the spool.
+const segmentExt = ".ndjson"
+
+// segment is a file of the spool, the events are appended one JSON document per line.
+type segment struct {
+	id    uint64
+	size  int64
+	count int
+}
+
+// spool keeps on disk the events which do not fit in the queue of the reporter. The events are
+// appended to the newest segment, a segment is closed once it reaches the segment size and the
+// oldest segments are evicted to keep the spool under its maximum size.
+//
+// The spool is not safe for concurrent use, the reporter calls it with the queue locked.
+type spool struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+
+	segments []*segment
+	size     int64
+	count    int
+	// tail is the open file of the newest segment, nil when the next event starts a new segment.
+	tail *os.File
+	// read is the number of oldest segments returned by next and not yet committed.
+	read int
+}
+
+func newSpool(dir string, maxSize, segmentSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, errors.New(err, "failed to create the events spool", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, dir))
+	}
+
+	s := &spool{dir: dir, maxSize: maxSize, segmentSize: segmentSize}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New(err, "failed to read the events spool", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, dir))
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		events, err := s.readSegment(id)
+		if err != nil {
+			// the unreadable segment is kept and counted as empty, it is removed once consumed.
+			events = nil
+		}
+		s.segments = append(s.segments, &segment{id: id, size: entry.Size(), count: len(events)})
+		s.size += entry.Size()
+		s.count += len(events)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].id < s.segments[j].id })
+	return s, nil
+}
+
+// len returns the number of spooled events.
+func (s *spool) len() int {
+	return s.count
+}
+
+// append writes the event to the newest segment, it returns the number of events evicted to keep
+// the spool under its maximum size.
+func (s *spool) append(e *event) (int, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0, errors.New(err, "failed to encode the spooled event", errors.TypeUnexpected)
+	}
+	data = append(data, '\n')
+
+	if s.tail == nil {
+		if err := s.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := s.tail.Write(data); err != nil {
+		return 0, errors.New(err, "failed to write the spooled event", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, s.tail.Name()))
+	}
+
+	last := s.segments[len(s.segments)-1]
+	last.size += int64(len(data))
+	last.count++
+	s.size += int64(len(data))
+	s.count++
+	if last.size >= s.segmentSize {
+		s.closeTail()
+	}
+
+	return s.evict(), nil
+}
+
+// next returns the events of the oldest segment not yet returned, the segment is only removed
+// by commit once the events are safely kept by the reporter. It returns no events when all the
+// segments were returned.
+func (s *spool) next() ([]*event, error) {
+	if s.read >= len(s.segments) {
+		return nil, nil
+	}
+
+	seg := s.segments[s.read]
+	if s.read == len(s.segments)-1 {
+		// the segment being written is closed, the next event starts a new segment.
+		s.closeTail()
+	}
+
+	// an unreadable segment is skipped so it does not block the segments following it.
+	s.read++
+	s.count -= seg.count
+	return s.readSegment(seg.id)
+}
+
+// commit removes the n oldest segments returned by next.
+func (s *spool) commit(n int) {
+	if n > s.read {
+		n = s.read
+	}
+	for _, seg := range s.segments[:n] {
+		s.size -= seg.size
+		os.Remove(s.segmentPath(seg.id))
+	}
+	s.segments = s.segments[n:]
+	s.read -= n
+}
+
+// uncommitted returns the number of segments returned by next and not yet committed.
+func (s *spool) uncommitted() int {
+	return s.read
+}
+
+// close closes the segment being written, the spooled events stay on disk.
+func (s *spool) close() {
+	s.closeTail()
+}
+
+// evict removes the oldest segments while the spool is over its maximum size, the segment being
+// written and the segments returned by next are never evicted.
+func (s *spool) evict() int {
+	evicted := 0
+	for s.size > s.maxSize && len(s.segments)-s.read > 1 {
+		seg := s.segments[s.read]
+		os.Remove(s.segmentPath(seg.id))
+		s.segments = append(s.segments[:s.read], s.segments[s.read+1:]...)
+		s.size -= seg.size
+		s.count -= seg.count
+		evicted += seg.count
+	}
+	return evicted
+}
+
+func (s *spool) openSegment() error {
+	var id uint64 = 1
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1].id + 1
+	}
+
+	f, err := os.OpenFile(s.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.New(err, "failed to create a segment of the events spool", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, s.segmentPath(id)))
+	}
+	s.tail = f
+	s.segments = append(s.segments, &segment{id: id})
+	return nil
+}
+
+func (s *spool) closeTail() {
+	if s.tail != nil {
+		s.tail.Close()
+		s.tail = nil
+	}
+}
+
+func (s *spool) readSegment(id uint64) ([]*event, error) {
+	path := s.segmentPath(id)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New(err, "failed to open a segment of the events spool", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+	defer f.Close()
+
+	var events []*event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(s.segmentSize)+64*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e event
+		if err := json.Unmarshal(line, &e); err != nil {
+			// a line truncated by a crash only loses its own event.
+			continue
+		}
+		events = append(events, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New(err, "failed to read a segment of the events spool", errors.TypeFilesystem, errors.M(errors.MetaKeyPath, path))
+	}
+	return events, nil
+}
+
+func (s *spool) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
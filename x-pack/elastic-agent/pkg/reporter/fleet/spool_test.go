@@ -0,0 +1,86 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package fleet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	messages := func(events []*event) []string {
+		var msgs []string
+		for _, e := range events {
+			msgs = append(msgs, e.Msg)
+		}
+		return msgs
+	}
+
+	eventSize := func(msg string) int64 {
+		s := &spool{dir: t.TempDir(), maxSize: 1 << 20, segmentSize: 1 << 20}
+		_, err := s.append(&event{Msg: msg})
+		require.NoError(t, err)
+		s.close()
+		return s.size
+	}
+
+	t.Run("segments are consumed in order", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := newSpool(dir, 1<<20, 2*eventSize("a"))
+		require.NoError(t, err)
+
+		for _, msg := range []string{"a", "b", "c"} {
+			_, err := s.append(&event{Msg: msg})
+			require.NoError(t, err)
+		}
+		require.Equal(t, 3, s.len())
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		events, err := s.next()
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b"}, messages(events))
+		events, err = s.next()
+		require.NoError(t, err)
+		require.Equal(t, []string{"c"}, messages(events))
+		require.Equal(t, 0, s.len())
+
+		// events appended after the last segment was read go to a new segment.
+		_, err = s.append(&event{Msg: "d"})
+		require.NoError(t, err)
+		s.commit(2)
+		s.close()
+
+		restored, err := newSpool(dir, 1<<20, 2*eventSize("a"))
+		require.NoError(t, err)
+		require.Equal(t, 1, restored.len())
+		events, err = restored.next()
+		require.NoError(t, err)
+		require.Equal(t, []string{"d"}, messages(events))
+	})
+
+	t.Run("oldest segments are evicted", func(t *testing.T) {
+		size := eventSize("0")
+		s, err := newSpool(t.TempDir(), 3*size, size)
+		require.NoError(t, err)
+
+		evicted := 0
+		for i := 0; i < 5; i++ {
+			n, err := s.append(&event{Msg: fmt.Sprint(i)})
+			require.NoError(t, err)
+			evicted += n
+		}
+		require.Equal(t, 2, evicted)
+		require.Equal(t, 3, s.len())
+
+		events, err := s.next()
+		require.NoError(t, err)
+		require.Equal(t, []string{"2"}, messages(events))
+	})
+}
@@ -40,6 +40,8 @@ const (
 	EventSubTypeStopping = "STOPPING"
 	// EventSubTypeUpdating is an event type indicating update process in progress.
 	EventSubTypeUpdating = "UPDATING"
+	// EventSubTypeDataDump is an event type carrying the metrics of an application.
+	EventSubTypeDataDump = "DATA_DUMP"
 )
 
 type agentInfo interface {
@@ -93,6 +95,20 @@ func (r *Reporter) report(ctx context.Context, e event) {
 	}
 }
 
+// NewMetricsRecord creates an event carrying the metrics of an application running for the output
+// identified by routeKey.
+func NewMetricsRecord(name string, routeKey string, metrics map[string]interface{}) Event {
+	return event{
+		eventype:  EventTypeState,
+		subType:   EventSubTypeDataDump,
+		timestamp: time.Now(),
+		message:   fmt.Sprintf("Application: %s[%s]: Metrics", name, routeKey),
+		payload: map[string]interface{}{
+			name: metrics,
+		},
+	}
+}
+
 func generateRecord(agentID string, id string, name string, s state.State) event {
 	eventType := EventTypeState
 
@@ -123,6 +139,10 @@ func generateRecord(agentID string, id string, name string, s state.State) event
 		eventType = EventTypeError
 		subType = EventSubTypeFailed
 		subTypeText = "CRASHED"
+	case state.Quarantined:
+		eventType = EventTypeError
+		subType = EventSubTypeFailed
+		subTypeText = "QUARANTINED"
 	case state.Stopping:
 		subType = EventSubTypeStopping
 		subTypeText = EventSubTypeStopping
@@ -89,6 +89,13 @@ func TestTypes(t *testing.T) {
 			EventSubType:  EventSubTypeFailed,
 			EventMessage:  "Application: a-crashed[id]: State changed to CRASHED: Crashed",
 		},
+		{
+			Status:        state.Quarantined,
+			StatusMessage: "Quarantined",
+			EventType:     EventTypeError,
+			EventSubType:  EventSubTypeFailed,
+			EventMessage:  "Application: a-quarantined[id]: State changed to QUARANTINED: Quarantined",
+		},
 		{
 			Status:        state.Stopping,
 			StatusMessage: "Stopping",
@@ -118,3 +125,11 @@ func TestTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsRecord(t *testing.T) {
+	e := NewMetricsRecord("filebeat", "default", map[string]interface{}{"route_key": "default"})
+	assert.Equal(t, EventTypeState, e.Type())
+	assert.Equal(t, EventSubTypeDataDump, e.SubType())
+	assert.Equal(t, "Application: filebeat[default]: Metrics", e.Message())
+	assert.Equal(t, map[string]interface{}{"filebeat": map[string]interface{}{"route_key": "default"}}, e.Payload())
+}
@@ -5,15 +5,29 @@
 package scheduler
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
+	"sync"
 	"time"
+
+	"github.com/gorhill/cronexpr"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
 )
 
 // Scheduler simple interface that encapsulate the scheduling logic, this is useful if you want to
 // test asynchronous code in a synchronous way.
 type Scheduler interface {
-	WaitTick() <-chan time.Time
+	// WaitTick returns a channel unblocked on the next tick. A wait on a timer started by WaitTick
+	// is abandoned as soon as the context is cancelled, the channel is then never unblocked so
+	// callers also select on the context.
+	WaitTick(ctx context.Context) <-chan time.Time
 	Stop()
+
+	// Trigger unblocks the pending WaitTick immediately, or the next one when none is pending,
+	// without waiting for the scheduled time.
+	Trigger()
 }
 
 // Stepper is a scheduler where each Tick is manually triggered, this is useful in scenario
@@ -28,13 +42,18 @@ func (s *Stepper) Next() {
 }
 
 // WaitTick returns a channel to watch for ticks.
-func (s *Stepper) WaitTick() <-chan time.Time {
+func (s *Stepper) WaitTick(_ context.Context) <-chan time.Time {
 	return s.C
 }
 
 // Stop is stopping the scheduler, in the case of the Stepper scheduler nothing is done.
 func (s *Stepper) Stop() {}
 
+// Trigger unblocks the WaitTick like Next but without waiting for the tick to be received.
+func (s *Stepper) Trigger() {
+	go s.Next()
+}
+
 // NewStepper returns a new Stepper scheduler where the tick is manually controlled.
 func NewStepper() *Stepper {
 	return &Stepper{
@@ -44,21 +63,30 @@ func NewStepper() *Stepper {
 
 // Periodic wraps a time.Timer as the scheduler.
 type Periodic struct {
-	Ticker *time.Ticker
-	ran    bool
+	Ticker   *time.Ticker
+	C        chan time.Time
+	ran      bool
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewPeriodic returns a Periodic scheduler that will unblock the WaitTick based on a duration.
 // The timer will do an initial tick, sleep for the defined period and tick again.
 func NewPeriodic(d time.Duration) *Periodic {
-	return &Periodic{Ticker: time.NewTicker(d)}
+	p := &Periodic{
+		Ticker: time.NewTicker(d),
+		C:      make(chan time.Time, 1),
+		done:   make(chan struct{}),
+	}
+	go p.forward()
+	return p
 }
 
 // WaitTick wait on the duration to be experied to unblock the channel.
 // Note: you should not keep a reference to the channel.
-func (p *Periodic) WaitTick() <-chan time.Time {
+func (p *Periodic) WaitTick(_ context.Context) <-chan time.Time {
 	if p.ran {
-		return p.Ticker.C
+		return p.C
 	}
 
 	rC := make(chan time.Time, 1)
@@ -73,62 +101,384 @@ func (p *Periodic) WaitTick() <-chan time.Time {
 // using another mechanism.
 func (p *Periodic) Stop() {
 	p.Ticker.Stop()
+	p.stopOnce.Do(func() { close(p.done) })
+}
+
+// Trigger unblocks the WaitTick without waiting for the ticker, ticks are not accumulated.
+func (p *Periodic) Trigger() {
+	tick(p.C, time.Now())
+}
+
+// forward relays the ticks of the ticker, like the ticker it drops ticks for slow receivers.
+func (p *Periodic) forward() {
+	for {
+		select {
+		case t := <-p.Ticker.C:
+			tick(p.C, t)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// JitterDistribution is the distribution of the jitter added by the PeriodicJitter scheduler.
+type JitterDistribution string
+
+const (
+	// JitterUniform draws the jitter uniformly between zero and the variance.
+	JitterUniform JitterDistribution = "uniform"
+	// JitterExponential draws the jitter from an exponential distribution with a mean of half the
+	// variance, capped at the variance. Most ticks get a small jitter and a few get a large one.
+	JitterExponential JitterDistribution = "exponential"
+	// JitterDecorrelated draws the jitter uniformly between a quarter of the variance and three
+	// times the previous jitter, capped at the variance, so consecutive jitters are not independent.
+	JitterDecorrelated JitterDistribution = "decorrelated"
+)
+
+// Unpack the jitter distribution.
+func (d *JitterDistribution) Unpack(from string) error {
+	switch JitterDistribution(from) {
+	case JitterUniform, JitterExponential, JitterDecorrelated:
+	default:
+		return fmt.Errorf("invalid jitter distribution %s, accepted values are 'uniform', 'exponential' and 'decorrelated'", from)
+	}
+
+	*d = JitterDistribution(from)
+	return nil
+}
+
+// JitterOption configures a PeriodicJitter scheduler.
+type JitterOption func(*PeriodicJitter)
+
+// WithJitterDistribution sets the distribution of the jitter, the jitter is uniform by default.
+func WithJitterDistribution(d JitterDistribution) JitterOption {
+	return func(p *PeriodicJitter) {
+		p.distribution = d
+	}
+}
+
+// WithInitialSpread sets the window over which the first tick is uniformly spread, so agents
+// started at the same time do not tick together. By default the first tick only waits for the
+// jitter.
+func WithInitialSpread(spread time.Duration) JitterOption {
+	return func(p *PeriodicJitter) {
+		p.spread = spread
+	}
 }
 
 // PeriodicJitter is as scheduler that will periodically create a timer ticker and sleep, to
 // better distribute the load on the network and remote endpoint the timer will introduce variance
 // on each sleep.
 type PeriodicJitter struct {
-	C        chan time.Time
-	ran      bool
-	d        time.Duration
-	variance time.Duration
-	done     chan struct{}
+	ran          bool
+	d            time.Duration
+	variance     time.Duration
+	distribution JitterDistribution
+	spread       time.Duration
+	initial      time.Duration // set by SetInitialDelay, replaces the spread of the first tick.
+	last         time.Duration // previous jitter, used by the decorrelated distribution.
+	done         chan struct{}
+	trigger      chan struct{}
+	stopOnce     sync.Once
+	mx           sync.Mutex
 }
 
 // NewPeriodicJitter creates a new PeriodicJitter.
-func NewPeriodicJitter(d, variance time.Duration) *PeriodicJitter {
-	return &PeriodicJitter{
-		C:        make(chan time.Time, 1),
-		d:        d,
-		variance: variance,
-		done:     make(chan struct{}),
+func NewPeriodicJitter(d, variance time.Duration, opts ...JitterOption) *PeriodicJitter {
+	p := &PeriodicJitter{
+		d:            d,
+		variance:     variance,
+		distribution: JitterUniform,
+		done:         make(chan struct{}),
+		trigger:      make(chan struct{}, 1),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // WaitTick wait on the duration plus some jitter to unblock the channel.
 // Note: you should not keep a reference to the channel.
-func (p *PeriodicJitter) WaitTick() <-chan time.Time {
+func (p *PeriodicJitter) WaitTick(ctx context.Context) <-chan time.Time {
+	rC := make(chan time.Time, 1)
+
+	var d time.Duration
 	if !p.ran {
 		// Sleep for only the variance, this will smooth the initial bootstrap of all the agents.
-		select {
-		case <-time.After(p.delay()):
-			p.C <- time.Now()
-		case <-p.done:
-			p.C <- time.Now()
-			close(p.C)
-		}
+		d = p.initialDelay()
 		p.ran = true
-		return p.C
+	} else {
+		d = p.duration() + p.delay()
 	}
 
-	select {
-	case <-time.After(p.d + p.delay()):
-		p.C <- time.Now()
-	case <-p.done:
-		p.C <- time.Now()
-		close(p.C)
-	}
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case t := <-timer.C:
+			rC <- t
+		case <-p.trigger:
+			rC <- time.Now()
+		case <-p.done:
+			rC <- time.Now()
+		case <-ctx.Done():
+		}
+	}()
 
-	return p.C
+	return rC
 }
 
 // Stop stops the PeriodicJitter scheduler.
 func (p *PeriodicJitter) Stop() {
-	close(p.done)
+	p.stopOnce.Do(func() { close(p.done) })
+}
+
+// Trigger unblocks the WaitTick without waiting for the duration and the jitter.
+func (p *PeriodicJitter) Trigger() {
+	signal(p.trigger)
+}
+
+// SetDuration changes the duration between ticks, the change is applied starting with the next
+// call to WaitTick.
+func (p *PeriodicJitter) SetDuration(d time.Duration) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.d = d
+}
+
+// SetInitialDelay sets the time before the first tick, the jitter is added to the delay. It has no
+// effect once the scheduler ticked.
+func (p *PeriodicJitter) SetInitialDelay(d time.Duration) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.initial = d
+}
+
+// SetVariance changes the maximum jitter added to the duration between ticks, the change is
+// applied starting with the next call to WaitTick.
+func (p *PeriodicJitter) SetVariance(variance time.Duration) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.variance = variance
+}
+
+func (p *PeriodicJitter) duration() time.Duration {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.d
+}
+
+// initialDelay is the time before the first tick, spread over the initial window when set.
+func (p *PeriodicJitter) initialDelay() time.Duration {
+	p.mx.Lock()
+	initial := p.initial
+	p.mx.Unlock()
+	if initial > 0 {
+		return initial + p.delay()
+	}
+	if p.spread > 0 {
+		return time.Duration(rand.Int63n(int64(p.spread)))
+	}
+	return p.delay()
 }
 
 func (p *PeriodicJitter) delay() time.Duration {
-	t := int64(p.variance)
-	return time.Duration(rand.Int63n(t))
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.variance <= 0 {
+		return 0
+	}
+
+	var d time.Duration
+	switch p.distribution {
+	case JitterExponential:
+		d = time.Duration(rand.ExpFloat64() * float64(p.variance) / 2)
+	case JitterDecorrelated:
+		base := p.variance / 4
+		upper := 3 * p.last
+		if upper <= base {
+			upper = base + 1
+		}
+		d = base + time.Duration(rand.Int63n(int64(upper-base)))
+	default:
+		return time.Duration(rand.Int63n(int64(p.variance)))
+	}
+
+	if d > p.variance {
+		d = p.variance
+	}
+	p.last = d
+	return d
+}
+
+// Cron is a scheduler that ticks at the wall-clock times matched by a cron expression, standard 5
+// fields expressions and expressions with an additional seconds or years field are supported.
+type Cron struct {
+	expr     *cronexpr.Expression
+	done     chan struct{}
+	trigger  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCron parses the cron expression and returns a new Cron scheduler.
+func NewCron(expression string) (*Cron, error) {
+	expr, err := cronexpr.Parse(expression)
+	if err != nil {
+		return nil, errors.New(err, "invalid cron expression", errors.TypeConfig, errors.M("expression", expression))
+	}
+
+	return &Cron{
+		expr:    expr,
+		done:    make(chan struct{}),
+		trigger: make(chan struct{}, 1),
+	}, nil
+}
+
+// WaitTick returns a channel unblocked at the next time matching the expression, when the
+// expression does not match any time in the future the channel is only unblocked by Stop.
+// Note: you should not keep a reference to the channel.
+func (c *Cron) WaitTick(ctx context.Context) <-chan time.Time {
+	rC := make(chan time.Time, 1)
+
+	var timer *time.Timer
+	var tick <-chan time.Time
+	if next := c.expr.Next(time.Now()); !next.IsZero() {
+		timer = time.NewTimer(time.Until(next))
+		tick = timer.C
+	}
+
+	go func() {
+		select {
+		case t := <-tick:
+			rC <- t
+		case <-c.trigger:
+			if timer != nil {
+				timer.Stop()
+			}
+			rC <- time.Now()
+		case <-c.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			rC <- time.Now()
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}()
+
+	return rC
+}
+
+// Stop stops the Cron scheduler.
+func (c *Cron) Stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+// Trigger unblocks the WaitTick without waiting for the next time matching the expression.
+func (c *Cron) Trigger() {
+	signal(c.trigger)
+}
+
+// Backoff is a scheduler where the time between ticks doubles after each reported failure, up to
+// a maximum, and goes back to the initial duration after a reported success.
+type Backoff struct {
+	init     time.Duration
+	max      time.Duration
+	current  time.Duration
+	ran      bool
+	done     chan struct{}
+	trigger  chan struct{}
+	stopOnce sync.Once
+	mx       sync.Mutex
+}
+
+// NewBackoff returns a Backoff scheduler which initially ticks every init duration.
+func NewBackoff(init, max time.Duration) *Backoff {
+	return &Backoff{
+		init:    init,
+		max:     max,
+		current: init,
+		done:    make(chan struct{}),
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// WaitTick returns a channel unblocked after the current duration, the first tick is immediate.
+// Note: you should not keep a reference to the channel.
+func (b *Backoff) WaitTick(ctx context.Context) <-chan time.Time {
+	rC := make(chan time.Time, 1)
+
+	b.mx.Lock()
+	d := b.current
+	if !b.ran {
+		d = 0
+		b.ran = true
+	}
+	b.mx.Unlock()
+
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case t := <-timer.C:
+			rC <- t
+		case <-b.trigger:
+			rC <- time.Now()
+		case <-b.done:
+			rC <- time.Now()
+		case <-ctx.Done():
+		}
+	}()
+
+	return rC
+}
+
+// Success resets the time between ticks to the initial duration.
+func (b *Backoff) Success() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.current = b.init
+}
+
+// Failure doubles the time between ticks without exceeding the maximum duration.
+func (b *Backoff) Failure() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+}
+
+// Stop stops the Backoff scheduler.
+func (b *Backoff) Stop() {
+	b.stopOnce.Do(func() { close(b.done) })
+}
+
+// Trigger unblocks the WaitTick without waiting for the current duration.
+func (b *Backoff) Trigger() {
+	signal(b.trigger)
+}
+
+// tick sends the time on the channel unless a tick is already pending.
+func tick(c chan time.Time, t time.Time) {
+	select {
+	case c <- t:
+	default:
+	}
+}
+
+// signal notifies the channel unless a notification is already pending.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
 }
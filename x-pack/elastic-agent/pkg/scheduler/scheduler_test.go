@@ -5,6 +5,7 @@
 package scheduler
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -26,7 +27,7 @@ type tickRecorder struct {
 func (m *tickRecorder) Start() {
 	for {
 		select {
-		case t := <-m.scheduler.WaitTick():
+		case t := <-m.scheduler.WaitTick(context.Background()):
 			m.count = m.count + 1
 			m.recorder <- e{count: m.count, at: t}
 		case <-m.done:
@@ -43,6 +44,8 @@ func TestScheduler(t *testing.T) {
 	t.Run("Step scheduler", testStepScheduler)
 	t.Run("Periodic scheduler", testPeriodic)
 	t.Run("PeriodicJitter scheduler", testPeriodicJitter)
+	t.Run("Cron scheduler", testCron)
+	t.Run("Backoff scheduler", testBackoff)
 }
 
 func newTickRecorder(scheduler Scheduler) *tickRecorder {
@@ -90,6 +93,15 @@ func testPeriodic(t *testing.T) {
 		require.True(t, nE.at.Sub(startedAt) < duration)
 	})
 
+	t.Run("unblock on trigger", func(t *testing.T) {
+		scheduler := NewPeriodic(30 * time.Minute)
+		defer scheduler.Stop()
+
+		<-scheduler.WaitTick(context.Background())
+		scheduler.Trigger()
+		<-scheduler.WaitTick(context.Background())
+	})
+
 	t.Run("multiple ticks", func(t *testing.T) {
 		duration := 1 * time.Millisecond
 		scheduler := NewPeriodic(duration)
@@ -109,6 +121,15 @@ func testPeriodic(t *testing.T) {
 }
 
 func testPeriodicJitter(t *testing.T) {
+	t.Run("unblock on trigger", func(t *testing.T) {
+		scheduler := NewPeriodicJitter(30*time.Minute, 1*time.Millisecond)
+		defer scheduler.Stop()
+
+		<-scheduler.WaitTick(context.Background())
+		scheduler.Trigger()
+		<-scheduler.WaitTick(context.Background())
+	})
+
 	t.Run("tick than wait", func(t *testing.T) {
 		duration := 5 * time.Second
 		variance := 2 * time.Second
@@ -167,7 +188,7 @@ func testPeriodicJitter(t *testing.T) {
 			scheduler.Stop()
 		}()
 
-		<-scheduler.WaitTick()
+		<-scheduler.WaitTick(context.Background())
 	})
 
 	t.Run("unblock on any tick", func(t *testing.T) {
@@ -175,10 +196,10 @@ func testPeriodicJitter(t *testing.T) {
 		variance := 2 * time.Second
 		scheduler := NewPeriodicJitter(duration, variance)
 
-		<-scheduler.WaitTick()
+		<-scheduler.WaitTick(context.Background())
 
 		// Increase time between next tick
-		scheduler.d = 20 * time.Minute
+		scheduler.SetDuration(20 * time.Minute)
 		scheduler.variance = 20 * time.Minute
 
 		go func() {
@@ -188,6 +209,169 @@ func testPeriodicJitter(t *testing.T) {
 			scheduler.Stop()
 		}()
 
-		<-scheduler.WaitTick()
+		<-scheduler.WaitTick(context.Background())
+	})
+
+	t.Run("cancelled context abandons the wait", func(t *testing.T) {
+		scheduler := NewPeriodicJitter(30*time.Minute, 30*time.Minute)
+		defer scheduler.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		startedAt := time.Now()
+		tick := scheduler.WaitTick(ctx)
+		require.True(t, time.Since(startedAt) < 5*time.Second)
+
+		cancel()
+		select {
+		case <-tick:
+			t.Fatal("cancelled wait must not tick")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// the cancelled wait does not consume the trigger of the next wait.
+		scheduler.Trigger()
+		<-scheduler.WaitTick(context.Background())
+	})
+
+	t.Run("first tick is spread over the initial window", func(t *testing.T) {
+		duration := 30 * time.Minute
+		variance := 30 * time.Minute
+		scheduler := NewPeriodicJitter(duration, variance, WithInitialSpread(10*time.Millisecond))
+		defer scheduler.Stop()
+
+		startedAt := time.Now()
+		<-scheduler.WaitTick(context.Background())
+		require.True(t, time.Since(startedAt) < 5*time.Second)
+	})
+
+	t.Run("first tick waits for the initial delay", func(t *testing.T) {
+		scheduler := NewPeriodicJitter(time.Millisecond, time.Millisecond, WithInitialSpread(time.Millisecond))
+		defer scheduler.Stop()
+		scheduler.SetInitialDelay(100 * time.Millisecond)
+
+		startedAt := time.Now()
+		<-scheduler.WaitTick(context.Background())
+		require.True(t, time.Since(startedAt) >= 100*time.Millisecond)
+
+		// the next ticks are not delayed.
+		startedAt = time.Now()
+		<-scheduler.WaitTick(context.Background())
+		require.True(t, time.Since(startedAt) < 100*time.Millisecond)
+	})
+
+	t.Run("jitter distributions stay within the variance", func(t *testing.T) {
+		variance := 1 * time.Second
+		for _, d := range []JitterDistribution{JitterUniform, JitterExponential, JitterDecorrelated} {
+			scheduler := NewPeriodicJitter(time.Minute, variance, WithJitterDistribution(d))
+			for i := 0; i < 1000; i++ {
+				delay := scheduler.delay()
+				require.True(t, delay >= 0 && delay <= variance, "%s jitter %s is out of bounds", d, delay)
+				if d == JitterDecorrelated {
+					require.True(t, delay >= variance/4, "decorrelated jitter %s is below the base", delay)
+				}
+			}
+		}
+	})
+
+	t.Run("jitter follows the variance set", func(t *testing.T) {
+		scheduler := NewPeriodicJitter(time.Minute, time.Hour)
+		scheduler.SetVariance(10 * time.Millisecond)
+		for i := 0; i < 100; i++ {
+			require.True(t, scheduler.delay() <= 10*time.Millisecond)
+		}
+	})
+
+	t.Run("invalid jitter distribution", func(t *testing.T) {
+		var d JitterDistribution
+		require.Error(t, d.Unpack("gaussian"))
+		require.NoError(t, d.Unpack("exponential"))
+		require.Equal(t, JitterExponential, d)
+	})
+}
+
+func testCron(t *testing.T) {
+	t.Run("invalid expression", func(t *testing.T) {
+		_, err := NewCron("not a cron expression")
+		require.Error(t, err)
+	})
+
+	t.Run("multiple ticks", func(t *testing.T) {
+		// every second, using the optional seconds field.
+		scheduler, err := NewCron("* * * * * * *")
+		require.NoError(t, err)
+		defer scheduler.Stop()
+
+		recorder := newTickRecorder(scheduler)
+		go recorder.Start()
+		defer recorder.Stop()
+
+		nE := <-recorder.recorder
+		require.Equal(t, 1, nE.count)
+		first := nE.at
+		nE = <-recorder.recorder
+		require.Equal(t, 2, nE.count)
+		require.True(t, nE.at.Sub(first) >= 500*time.Millisecond)
+	})
+
+	t.Run("unblock on trigger", func(t *testing.T) {
+		// once a year, at midnight on the first of January.
+		scheduler, err := NewCron("0 0 1 1 *")
+		require.NoError(t, err)
+		defer scheduler.Stop()
+
+		scheduler.Trigger()
+		<-scheduler.WaitTick(context.Background())
+	})
+
+	t.Run("unblock on stop", func(t *testing.T) {
+		// once a year, at midnight on the first of January.
+		scheduler, err := NewCron("0 0 1 1 *")
+		require.NoError(t, err)
+
+		go func() {
+			// Not a fan of introducing sync-timing-code but
+			// give us a chance to be waiting.
+			<-time.After(500 * time.Millisecond)
+			scheduler.Stop()
+		}()
+
+		<-scheduler.WaitTick(context.Background())
+	})
+}
+
+func testBackoff(t *testing.T) {
+	t.Run("grows on failure and resets on success", func(t *testing.T) {
+		scheduler := NewBackoff(10*time.Millisecond, 40*time.Millisecond)
+		defer scheduler.Stop()
+
+		<-scheduler.WaitTick(context.Background())
+		require.Equal(t, 10*time.Millisecond, scheduler.current)
+
+		scheduler.Failure()
+		require.Equal(t, 20*time.Millisecond, scheduler.current)
+		scheduler.Failure()
+		scheduler.Failure()
+		require.Equal(t, 40*time.Millisecond, scheduler.current)
+
+		startedAt := time.Now()
+		<-scheduler.WaitTick(context.Background())
+		require.True(t, time.Since(startedAt) >= 40*time.Millisecond)
+
+		scheduler.Success()
+		require.Equal(t, 10*time.Millisecond, scheduler.current)
+	})
+
+	t.Run("unblock on stop", func(t *testing.T) {
+		scheduler := NewBackoff(30*time.Minute, 30*time.Minute)
+		<-scheduler.WaitTick(context.Background())
+
+		go func() {
+			// Not a fan of introducing sync-timing-code but
+			// give us a chance to be waiting.
+			<-time.After(500 * time.Millisecond)
+			scheduler.Stop()
+		}()
+
+		<-scheduler.WaitTick(context.Background())
 	})
 }
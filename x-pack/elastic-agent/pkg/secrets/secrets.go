@@ -0,0 +1,89 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package secrets resolves the references to the secrets of external providers found in the
+// configuration, a reference has the form <provider>:<path>#<field>, e.g.
+// vault:secret/elasticsearch#password.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/secrets/vault"
+)
+
+const vaultScheme = "vault"
+
+// schemes are the providers which can be configured, a reference to a provider which is not
+// configured is an error.
+var schemes = map[string]struct{}{
+	vaultScheme: {},
+}
+
+var referenceRegex = regexp.MustCompile(`^([a-z][a-z0-9_]*):([^#\s]+)#(\S+)$`)
+
+// Provider fetches the secrets of an external provider.
+type Provider interface {
+	// Fetch returns the field of the secret at path.
+	Fetch(ctx context.Context, path, field string) (string, error)
+}
+
+// Config is the configuration of the secrets providers.
+type Config struct {
+	Vault *vault.Config `config:"vault" yaml:"vault,omitempty" json:"vault,omitempty"`
+}
+
+// DefaultConfig creates a config with no provider.
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+// Resolver resolves the references to the secrets with the configured providers.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver creates a resolver with the providers of the configuration.
+func NewResolver(cfg *Config) (*Resolver, error) {
+	providers := make(map[string]Provider)
+	if cfg != nil && cfg.Vault != nil {
+		client, err := vault.New(cfg.Vault)
+		if err != nil {
+			return nil, err
+		}
+		providers[vaultScheme] = client
+	}
+	return NewResolverWithProviders(providers), nil
+}
+
+// NewResolverWithProviders creates a resolver with the providers indexed by their scheme.
+func NewResolverWithProviders(providers map[string]Provider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+// Resolve returns the secret referenced by value, values which are not a reference are returned
+// unchanged.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	match := referenceRegex.FindStringSubmatch(value)
+	if match == nil {
+		return value, nil
+	}
+	scheme, path, field := match[1], match[2], match[3]
+	if _, ok := schemes[scheme]; !ok {
+		return value, nil
+	}
+
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("%s references a secret but the %s secrets provider is not configured", value, scheme), errors.TypeConfig)
+	}
+	secret, err := provider.Fetch(ctx, path, field)
+	if err != nil {
+		return "", errors.New(err, fmt.Sprintf("could not resolve %s", value))
+	}
+	return secret, nil
+}
@@ -0,0 +1,51 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider map[string]string
+
+func (p testProvider) Fetch(_ context.Context, path, field string) (string, error) {
+	secret, ok := p[path+"#"+field]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return secret, nil
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	r := NewResolverWithProviders(map[string]Provider{
+		vaultScheme: testProvider{"secret/elasticsearch#password": "changeme"},
+	})
+
+	for _, value := range []string{"changeme", "vault:8200", "http://localhost:9200#home", "vault:secret path#password"} {
+		resolved, err := r.Resolve(ctx, value)
+		require.NoError(t, err)
+		assert.Equal(t, value, resolved)
+	}
+
+	resolved, err := r.Resolve(ctx, "vault:secret/elasticsearch#password")
+	require.NoError(t, err)
+	assert.Equal(t, "changeme", resolved)
+
+	_, err = r.Resolve(ctx, "vault:secret/elasticsearch#username")
+	assert.Error(t, err)
+
+	t.Run("provider not configured", func(t *testing.T) {
+		r, err := NewResolver(DefaultConfig())
+		require.NoError(t, err)
+		_, err = r.Resolve(ctx, "vault:secret/elasticsearch#password")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package vault
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/transport/httpcommon"
+)
+
+// Config is the configuration of the Vault secrets provider.
+type Config struct {
+	// Address is the address of the Vault server, e.g. https://vault:8200.
+	Address string `config:"address" yaml:"address"`
+
+	// Namespace is the Vault Enterprise namespace of the secrets.
+	Namespace string `config:"namespace" yaml:"namespace,omitempty"`
+
+	// Token authenticates the requests with a Vault token.
+	Token string `config:"token" yaml:"token,omitempty"`
+
+	// AppRole authenticates the requests with a token obtained from the AppRole auth method.
+	AppRole *AppRoleConfig `config:"approle" yaml:"approle,omitempty"`
+
+	// KVVersion is the version of the KV secrets engine, the first element of the path of the
+	// secrets is the mount of the engine.
+	KVVersion int `config:"kv_version" yaml:"kv_version"`
+
+	Transport httpcommon.HTTPTransportSettings `config:",inline" yaml:",inline"`
+}
+
+// AppRoleConfig is the configuration of the AppRole auth method.
+type AppRoleConfig struct {
+	Mount    string `config:"mount" yaml:"mount"`
+	RoleID   string `config:"role_id" yaml:"role_id"`
+	SecretID string `config:"secret_id" yaml:"secret_id"`
+}
+
+// InitDefaults initializes the default values for the config.
+func (c *Config) InitDefaults() {
+	c.KVVersion = 2
+	c.Transport = httpcommon.DefaultHTTPTransportSettings()
+	c.Transport.Timeout = 10 * time.Second
+}
+
+// InitDefaults initializes the default values for the config.
+func (c *AppRoleConfig) InitDefaults() {
+	c.Mount = "approle"
+}
+
+// Validate ensures correctness of config.
+func (c *Config) Validate() error {
+	if c.Address == "" {
+		return errors.New("vault address is required")
+	}
+	if c.KVVersion != 1 && c.KVVersion != 2 {
+		return errors.New("vault kv_version must be 1 or 2")
+	}
+	if (c.Token == "") == (c.AppRole == nil) {
+		return errors.New("vault requires either a token or an approle")
+	}
+	if c.AppRole != nil && (c.AppRole.RoleID == "" || c.AppRole.SecretID == "") {
+		return errors.New("vault approle requires a role_id and a secret_id")
+	}
+	return nil
+}
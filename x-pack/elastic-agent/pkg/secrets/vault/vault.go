@@ -0,0 +1,175 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/agent/errors"
+)
+
+// Client reads the secrets from Vault.
+type Client struct {
+	config *Config
+	client *http.Client
+
+	// token obtained from the AppRole auth method, empty until the first login.
+	lock  sync.Mutex
+	token string
+}
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Auth   *auth                  `json:"auth"`
+	Errors []string               `json:"errors"`
+}
+
+type auth struct {
+	ClientToken string `json:"client_token"`
+}
+
+// New creates a client for the Vault server.
+func New(config *Config) (*Client, error) {
+	client, err := config.Transport.Client()
+	if err != nil {
+		return nil, errors.New(err, "could not create the vault client", errors.TypeNetwork)
+	}
+	return &Client{config: config, client: client}, nil
+}
+
+// Fetch returns the field of the secret at path.
+func (c *Client) Fetch(ctx context.Context, path, field string) (string, error) {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	resp, status, err := c.do(ctx, http.MethodGet, c.secretPath(path), token, nil)
+	if status == http.StatusForbidden && c.config.AppRole != nil {
+		// the token obtained from the AppRole expired, login again.
+		c.resetToken(token)
+		if token, err = c.authToken(ctx); err != nil {
+			return "", err
+		}
+		resp, _, err = c.do(ctx, http.MethodGet, c.secretPath(path), token, nil)
+	}
+	if err != nil {
+		return "", errors.New(err, fmt.Sprintf("could not read the vault secret %s", path), errors.TypeNetwork)
+	}
+
+	data := resp.Data
+	if c.config.KVVersion == 2 {
+		data, _ = data["data"].(map[string]interface{})
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("the vault secret %s has no field %s", path, field), errors.TypeConfig)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// secretPath returns the API path of the secret, the data of the version 2 of the KV engine is
+// read from the data path under the mount.
+func (c *Client) secretPath(path string) string {
+	path = strings.Trim(path, "/")
+	if c.config.KVVersion != 2 {
+		return path
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 1 {
+		return parts[0] + "/data"
+	}
+	return parts[0] + "/data/" + parts[1]
+}
+
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	if c.config.AppRole == nil {
+		return c.config.Token, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.token != "" {
+		return c.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"role_id":   c.config.AppRole.RoleID,
+		"secret_id": c.config.AppRole.SecretID,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, _, err := c.do(ctx, http.MethodPost, "auth/"+strings.Trim(c.config.AppRole.Mount, "/")+"/login", "", bytes.NewReader(body))
+	if err != nil {
+		return "", errors.New(err, "could not login to vault with the approle", errors.TypeNetwork)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", errors.New("vault approle login returned no token", errors.TypeNetwork)
+	}
+	c.token = resp.Auth.ClientToken
+	return c.token, nil
+}
+
+// resetToken forgets the token unless another request already replaced it.
+func (c *Client) resetToken(token string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string, body io.Reader) (*response, int, error) {
+	url := strings.TrimRight(c.config.Address, "/") + "/v1/" + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, res.StatusCode, err
+	}
+	var resp response
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return nil, res.StatusCode, fmt.Errorf("invalid response from vault: %w", err)
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		if len(resp.Errors) > 0 {
+			return nil, res.StatusCode, fmt.Errorf("vault returned %s: %s", res.Status, strings.Join(resp.Errors, ", "))
+		}
+		return nil, res.StatusCode, fmt.Errorf("vault returned %s", res.Status)
+	}
+	return &resp, res.StatusCode, nil
+}
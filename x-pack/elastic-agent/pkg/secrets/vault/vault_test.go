@@ -0,0 +1,145 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/elastic-agent/pkg/config"
+)
+
+func newTestServer(t *testing.T, logins *int) *httptest.Server {
+	validToken := "s.token"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body["role_id"] != "role" || body["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+				return
+			}
+			*logins++
+			w.Write([]byte(`{"auth":{"client_token":"s.token"}}`))
+		case "/v1/secret/data/elasticsearch":
+			if r.Header.Get("X-Vault-Token") != validToken {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"data":{"password":"changeme","port":9200},"metadata":{"version":1}}}`))
+		case "/v1/kv/elasticsearch":
+			w.Write([]byte(`{"data":{"password":"changeme-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+}
+
+func newTestClient(t *testing.T, raw map[string]interface{}) *Client {
+	var cfg Config
+	require.NoError(t, config.MustNewConfigFrom(raw).Unpack(&cfg))
+	c, err := New(&cfg)
+	require.NoError(t, err)
+	return c
+}
+
+func TestFetch(t *testing.T) {
+	logins := 0
+	srv := newTestServer(t, &logins)
+	defer srv.Close()
+	ctx := context.Background()
+
+	t.Run("token", func(t *testing.T) {
+		c := newTestClient(t, map[string]interface{}{"address": srv.URL, "token": "s.token"})
+		secret, err := c.Fetch(ctx, "secret/elasticsearch", "password")
+		require.NoError(t, err)
+		assert.Equal(t, "changeme", secret)
+
+		secret, err = c.Fetch(ctx, "secret/elasticsearch", "port")
+		require.NoError(t, err)
+		assert.Equal(t, "9200", secret)
+
+		_, err = c.Fetch(ctx, "secret/elasticsearch", "username")
+		assert.Error(t, err)
+		_, err = c.Fetch(ctx, "secret/missing", "password")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		c := newTestClient(t, map[string]interface{}{"address": srv.URL, "token": "s.invalid"})
+		_, err := c.Fetch(ctx, "secret/elasticsearch", "password")
+		assert.Error(t, err)
+	})
+
+	t.Run("kv version 1", func(t *testing.T) {
+		c := newTestClient(t, map[string]interface{}{"address": srv.URL, "token": "s.token", "kv_version": 1})
+		secret, err := c.Fetch(ctx, "kv/elasticsearch", "password")
+		require.NoError(t, err)
+		assert.Equal(t, "changeme-v1", secret)
+	})
+
+	t.Run("approle", func(t *testing.T) {
+		c := newTestClient(t, map[string]interface{}{
+			"address": srv.URL,
+			"approle": map[string]interface{}{"role_id": "role", "secret_id": "secret"},
+		})
+		secret, err := c.Fetch(ctx, "secret/elasticsearch", "password")
+		require.NoError(t, err)
+		assert.Equal(t, "changeme", secret)
+		assert.Equal(t, 1, logins)
+
+		// the token is reused until it is rejected.
+		_, err = c.Fetch(ctx, "secret/elasticsearch", "password")
+		require.NoError(t, err)
+		assert.Equal(t, 1, logins)
+
+		c.token = "s.expired"
+		secret, err = c.Fetch(ctx, "secret/elasticsearch", "password")
+		require.NoError(t, err)
+		assert.Equal(t, "changeme", secret)
+		assert.Equal(t, 2, logins)
+	})
+
+	t.Run("approle invalid secret", func(t *testing.T) {
+		c := newTestClient(t, map[string]interface{}{
+			"address": srv.URL,
+			"approle": map[string]interface{}{"role_id": "role", "secret_id": "wrong"},
+		})
+		_, err := c.Fetch(ctx, "secret/elasticsearch", "password")
+		assert.Error(t, err)
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"token": "s.token"},
+		{"address": "http://vault:8200"},
+		{"address": "http://vault:8200", "token": "s.token", "approle": map[string]interface{}{"role_id": "role", "secret_id": "secret"}},
+		{"address": "http://vault:8200", "approle": map[string]interface{}{"role_id": "role"}},
+		{"address": "http://vault:8200", "token": "s.token", "kv_version": 3},
+	}
+	for _, raw := range invalid {
+		var cfg Config
+		assert.Error(t, config.MustNewConfigFrom(raw).Unpack(&cfg), raw)
+	}
+
+	var cfg Config
+	require.NoError(t, config.MustNewConfigFrom(map[string]interface{}{
+		"address": "http://vault:8200",
+		"approle": map[string]interface{}{"role_id": "role", "secret_id": "secret"},
+	}).Unpack(&cfg))
+	assert.Equal(t, 2, cfg.KVVersion)
+	assert.Equal(t, "approle", cfg.AppRole.Mount)
+}
@@ -58,6 +58,17 @@ func (b *Bucket) Add() {
 	b.rateChan <- struct{}{}
 }
 
+// TryAdd adds item into a bucket when there is room for it, it returns false without blocking
+// when the bucket is full.
+func (b *Bucket) TryAdd() bool {
+	select {
+	case b.rateChan <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
 // Close stops the rate limiting and does not let pass anything anymore.
 func (b *Bucket) Close() {
 	close(b.closeChan)
@@ -69,7 +80,7 @@ func (b *Bucket) Close() {
 func (b *Bucket) run(ctx context.Context) {
 	for {
 		select {
-		case <-b.scheduler.WaitTick():
+		case <-b.scheduler.WaitTick(ctx):
 			for i := 0; i < b.dropAmount; i++ {
 				select {
 				case <-b.rateChan:
@@ -106,4 +106,26 @@ func TestTokenBucket(t *testing.T) {
 		b.Add()
 		b.Add() // Should block and be unblocked, if not unblock test will timeout.
 	})
+
+	t.Run("when we hit the bucket size TryAdd should not block", func(t *testing.T) {
+		stepper := scheduler.NewStepper()
+
+		b, err := newTokenBucketWithScheduler(
+			context.Background(),
+			bucketSize,
+			dropAmount,
+			stepper,
+		)
+
+		assert.NoError(t, err, "initiating a bucket failed")
+		defer b.Close()
+
+		for i := 0; i < bucketSize; i++ {
+			assert.True(t, b.TryAdd())
+		}
+		assert.False(t, b.TryAdd())
+
+		stepper.Next()
+		assert.Eventually(t, b.TryAdd, time.Second, 10*time.Millisecond)
+	})
 }
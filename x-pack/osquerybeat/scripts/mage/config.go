@@ -16,6 +16,7 @@ func XPackConfigFileParams() devtools.ConfigFileParams {
 		"ExcludeConsole":             false,
 		"ExcludeFileOutput":          true,
 		"ExcludeKafka":               true,
+		"ExcludePulsar":              true,
 		"ExcludeRedis":               true,
 		"UseDockerMetadataProcessor": false,
 	}